@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/controllers"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/middleware"
+	"github.com/teapartydev/storage/server/s3gateway"
+	"github.com/teapartydev/storage/server/services"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// App wires together the http server, job client and their dependencies and owns their lifecycle
+type App struct {
+	mode           Mode
+	config         *config.Config
+	logger         *zap.Logger
+	metrics        *metrics.Metrics
+	tracerProvider *sdktrace.TracerProvider
+	db             *pgxpool.Pool
+	storage        *storage.Storage
+	replicas       storage.Replicas
+	job            *river.Client[pgx.Tx]
+	server         *fiber.App
+	gateway        *fiber.App
+	metricsServer  *fiber.App
+	healthService  *services.HealthService
+
+	listener        net.Listener
+	gatewayListener net.Listener
+	metricsListener net.Listener
+	serverErrors    chan error
+	jobStarted      bool
+	cancelJobEvents func()
+}
+
+func NewApp(mode Mode, config *config.Config, logger *zap.Logger) (*App, error) {
+	if mode == ModeMigrate {
+		return nil, fmt.Errorf("mode %q does not run an app, use Migrate instead", mode)
+	}
+
+	a := &App{
+		mode:         mode,
+		config:       config,
+		logger:       logger,
+		metrics:      metrics.NewMetrics(logger),
+		serverErrors: make(chan error, 3),
+	}
+
+	a.tracerProvider = tracing.NewTracerProvider(config, logger)
+
+	var err error
+
+	a.db, err = NewDatabasePool(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.metrics.Register(
+		metrics.NewDatabaseCollector(a.db),
+		metrics.NewCatalogCollector(a.db, logger),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error registering metrics collectors: %w", err)
+	}
+
+	s3Client, err := NewS3Client(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	a.storage = storage.NewStorage(s3Client, config, a.metrics, logger)
+
+	a.replicas, err = NewReplicas(context.Background(), config, a.metrics, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = a.setupJobs(); err != nil {
+		return nil, err
+	}
+
+	a.setupServer()
+
+	a.setupMetricsServer()
+
+	return a, nil
+}
+
+func (a *App) setupJobs() error {
+	workers, err := NewWorkers(a.db, a.storage, a.replicas, NewScanner(a.config), a.config, a.metrics, a.logger)
+	if err != nil {
+		return err
+	}
+
+	riverConfig := &river.Config{
+		Workers: workers,
+	}
+
+	if a.mode.worksJobs() {
+		queues, err := jobs.NewQueues(a.config)
+		if err != nil {
+			return fmt.Errorf("error configuring job queues: %w", err)
+		}
+
+		riverConfig.Queues = queues
+		riverConfig.PeriodicJobs = jobs.NewPeriodicJobs()
+	}
+
+	job, err := river.NewClient[pgx.Tx](riverpgxv5.New(a.db), riverConfig)
+	if err != nil {
+		return fmt.Errorf("error creating river client: %w", err)
+	}
+
+	a.job = job
+
+	if !a.mode.worksJobs() {
+		return nil
+	}
+
+	jobEvents, cancelJobEvents := a.job.Subscribe(
+		river.EventKindJobCompleted,
+		river.EventKindJobFailed,
+		river.EventKindJobCancelled,
+		river.EventKindJobSnoozed,
+	)
+	go a.metrics.CollectJobEvents(jobEvents)
+
+	a.cancelJobEvents = cancelJobEvents
+
+	return nil
+}
+
+func (a *App) setupServer() {
+	a.server = fiber.New(fiber.Config{
+		ErrorHandler:             middleware.ErrorHandler,
+		Immutable:                true,
+		EnablePrintRoutes:        true,
+		EnableSplittingOnParsers: true,
+	})
+
+	a.server.Use(middleware.Metrics(a.metrics))
+
+	a.server.Use(middleware.Logger(a.logger))
+
+	a.server.Use(middleware.RequestId())
+
+	a.server.Use(middleware.RequestContext())
+
+	a.server.Use(middleware.Tracing())
+
+	a.healthService = services.NewHealthService(a.db, a.storage, a.job, a.mode.worksJobs(), a.logger)
+	controllers.NewHealthController(a.healthService).RegisterHealthRoutes(a.server)
+
+	if !a.mode.servesApi() {
+		return
+	}
+
+	controllers.NewOpenApiController().RegisterOpenApiRoutes(a.server)
+
+	apiKeyService := services.NewApiKeyService(a.db, a.config, a.logger)
+
+	a.server.Use(middleware.KeyAuth(a.config, apiKeyService))
+
+	bucketService := services.NewBucketService(a.db, a.job, a.config, a.logger)
+	controllers.NewBucketController(bucketService).RegisterBucketRoutes(a.server)
+
+	objectService := services.NewObjectService(a.db, a.storage, a.replicas, a.job, a.config, a.logger)
+	controllers.NewObjectController(objectService).RegisterObjectRoutes(a.server)
+
+	jobService := services.NewJobService(a.db, a.job, a.logger)
+	controllers.NewJobController(jobService).RegisterJobRoutes(a.server)
+
+	operationService := services.NewOperationService(a.db, a.job, a.logger)
+	controllers.NewOperationController(operationService).RegisterOperationRoutes(a.server)
+
+	reconciliationService := services.NewReconciliationService(a.db, a.storage, a.config, a.logger)
+	controllers.NewReconciliationController(reconciliationService).RegisterReconciliationRoutes(a.server)
+
+	archiveService := services.NewArchiveService(a.db, a.storage, a.job, a.config, a.logger)
+	controllers.NewArchiveController(archiveService).RegisterArchiveRoutes(a.server)
+
+	if a.config.S3GatewayEnabled {
+		a.setupGateway(bucketService, objectService, apiKeyService)
+	}
+}
+
+// setupGateway serves the s3 compatible api on its own port since s3 clients own the whole path space
+func (a *App) setupGateway(bucketService *services.BucketService, objectService *services.ObjectService, apiKeyService *services.ApiKeyService) {
+	a.gateway = fiber.New(fiber.Config{
+		ErrorHandler: s3gateway.ErrorHandler,
+		Immutable:    true,
+		// request bodies larger than the body limit are streamed into storage instead of being buffered
+		StreamRequestBody:     true,
+		BodyLimit:             4 * 1024 * 1024,
+		DisableStartupMessage: true,
+	})
+
+	a.gateway.Use(middleware.Metrics(a.metrics))
+
+	a.gateway.Use(middleware.Logger(a.logger))
+
+	a.gateway.Use(middleware.RequestId())
+
+	a.gateway.Use(middleware.RequestContext())
+
+	a.gateway.Use(middleware.Tracing())
+
+	s3gateway.NewGateway(bucketService, objectService, apiKeyService, a.config, a.logger).RegisterRoutes(a.gateway)
+}
+
+// setupMetricsServer serves the prometheus metrics on their own port, they are scraped without an api key and so are
+// kept off the listeners clients reach
+func (a *App) setupMetricsServer() {
+	a.metricsServer = fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+
+	a.metricsServer.Get("/metrics", adaptor.HTTPHandler(a.metrics.Handler()))
+}
+
+// Server exposes the fiber app so tests can drive requests through the full middleware chain
+func (a *App) Server() *fiber.App {
+	return a.server
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/metrics"
+	"go.uber.org/zap"
+)
+
+func TestMetrics_ServedApartFromApi(t *testing.T) {
+	logger := zap.NewNop()
+
+	a := &App{
+		mode:    ModeServe,
+		config:  &config.Config{},
+		logger:  logger,
+		metrics: metrics.NewMetrics(logger),
+	}
+	a.setupServer()
+	a.setupMetricsServer()
+
+	// the api answers requests without a key with unauthorized, metrics are not among its routes
+	response, err := a.server.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, err = a.metricsServer.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
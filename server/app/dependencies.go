@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/archive"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/scanning"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"go.uber.org/zap"
+)
+
+// NewDatabasePool connects to postgres with the storage schema on the search path. It is shared by the server and
+// the admin cli so both see the same tables
+func NewDatabasePool(ctx context.Context, config *config.Config) (*pgxpool.Pool, error) {
+	pgxPoolConfig, err := pgxpool.ParseConfig(config.PostgresUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing postgres url: %w", err)
+	}
+
+	pgxPoolConfig.ConnConfig.RuntimeParams["search_path"] = "storage"
+
+	pgxPoolConfig.ConnConfig.Tracer = tracing.NewDatabaseTracer()
+
+	pgxPool, err := pgxpool.NewWithConfig(ctx, pgxPoolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to postgres: %w", err)
+	}
+
+	return pgxPool, nil
+}
+
+func NewS3Client(ctx context.Context, config *config.Config) (*s3.Client, error) {
+	return newS3Client(ctx, config.S3Endpoint, config.S3Region, config.S3AccessKeyId, config.S3SecretAccessKey, config.S3ForcePathStyle, config.S3DisableSSL)
+}
+
+// NewReplicas connects to every replica in the config
+func NewReplicas(ctx context.Context, config *config.Config, metrics *metrics.Metrics, logger *zap.Logger) (storage.Replicas, error) {
+	replicas := make(storage.Replicas, len(config.Replicas))
+
+	for name, replica := range config.Replicas {
+		s3Client, err := newS3Client(ctx, replica.S3Endpoint, replica.S3Region, replica.S3AccessKeyId, replica.S3SecretAccessKey, replica.S3ForcePathStyle, replica.S3DisableSSL)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to replica '%s': %w", name, err)
+		}
+
+		replicas[name] = storage.NewReplicaStorage(s3Client, name, replica, config, metrics, logger)
+	}
+
+	return replicas, nil
+}
+
+func newS3Client(ctx context.Context, endpoint string, region string, accessKeyId string, secretAccessKey string, forcePathStyle bool, disableSSL bool) (*s3.Client, error) {
+	s3Config, err := awsConfig.LoadDefaultConfig(
+		ctx,
+		awsConfig.WithRegion(region),
+		awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyId, secretAccessKey, ""),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws s3 config: %w", err)
+	}
+
+	s3Client := s3.NewFromConfig(
+		s3Config,
+		func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = forcePathStyle
+			o.EndpointOptions.DisableHTTPS = disableSSL
+		},
+	)
+
+	return s3Client, nil
+}
+
+// NewScanner returns the malware scanner uploads are held for, or nil when malware scanning is turned off
+func NewScanner(config *config.Config) scanning.Scanner {
+	switch config.MalwareScanner {
+	case "clamd":
+		return scanning.NewClamdScanner(config.MalwareClamdAddress, time.Duration(config.MalwareClamdTimeout)*time.Second)
+	case "fake":
+		return scanning.NewFakeScanner()
+	default:
+		return nil
+	}
+}
+
+// NewWorkers registers every job worker. Workers are registered even for clients that only insert jobs so inserts
+// of unknown job kinds are rejected. scanner is nil when malware scanning is turned off
+func NewWorkers(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, scanner scanning.Scanner, config *config.Config, metrics *metrics.Metrics, logger *zap.Logger) (*river.Workers, error) {
+	workers := river.NewWorkers()
+	archives := archive.NewStore(storage, config)
+
+	if err := river.AddWorkerSafely[jobs.BucketDeletion](workers, jobs.NewBucketDeletionWorker(db, storage, replicas, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket deletion worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketEmptying](workers, jobs.NewBucketEmptyingWorker(db, storage, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket emptying worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketExport](workers, jobs.NewBucketExportWorker(db, storage, archives, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket export worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketImport](workers, jobs.NewBucketImportWorker(db, storage, scanner != nil, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket import worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketLockReconciliation](workers, jobs.NewBucketLockReconciliationWorker(db, config, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket lock reconciliation worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketRelocation](workers, jobs.NewBucketRelocationWorker(db, storage, replicas, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket relocation worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.BucketRestore](workers, jobs.NewBucketRestoreWorker(db, storage, archives, scanner != nil, logger)); err != nil {
+		return nil, fmt.Errorf("error adding bucket restore worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.PreSignedUploadSessionCompletion](workers, jobs.NewPreSignedUploadSessionCompletionWorker(db, storage, scanner != nil, logger)); err != nil {
+		return nil, fmt.Errorf("error adding pre signed upload session completion worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectDeletion](workers, jobs.NewObjectDeletionWorker(db, storage, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object deletion worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectBatch](workers, jobs.NewObjectBatchWorker(db, storage, scanner != nil, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object batch worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectProcessing](workers, jobs.NewObjectProcessingWorker(db, storage, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object processing worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectReplication](workers, jobs.NewObjectReplicationWorker(db, storage, replicas, metrics, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object replication worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectReplicaDeletion](workers, jobs.NewObjectReplicaDeletionWorker(replicas, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object replica deletion worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectScan](workers, jobs.NewObjectScanWorker(db, storage, scanner, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object scan worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectLifecycle](workers, jobs.NewObjectLifecycleWorker(db, storage, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object lifecycle worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectArchival](workers, jobs.NewObjectArchivalWorker(db, storage, config, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object archival worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.ObjectTierRestore](workers, jobs.NewObjectTierRestoreWorker(db, storage, logger)); err != nil {
+		return nil, fmt.Errorf("error adding object tier restore worker: %w", err)
+	}
+
+	if err := river.AddWorkerSafely[jobs.StorageReconciliation](workers, jobs.NewStorageReconciliationWorker(db, storage, replicas, config, logger)); err != nil {
+		return nil, fmt.Errorf("error adding storage reconciliation worker: %w", err)
+	}
+
+	return workers, nil
+}
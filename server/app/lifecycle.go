@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// Start starts the job client when the mode works jobs and begins serving http on the configured host and port. It returns once the
+// listener is bound; errors from the running server are delivered through Errors
+func (a *App) Start(ctx context.Context) error {
+	const op = "app.Start"
+
+	if a.mode.worksJobs() {
+		if err := a.job.Start(context.Background()); err != nil {
+			return fmt.Errorf("error starting river client: %w", err)
+		}
+
+		a.jobStarted = true
+
+		a.healthService.MarkJobClientStarted()
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", net.JoinHostPort(a.config.ServiceHost, a.config.ServicePort))
+	if err != nil {
+		return fmt.Errorf("error listening on %s:%s: %w", a.config.ServiceHost, a.config.ServicePort, err)
+	}
+
+	a.listener = listener
+
+	go func() {
+		if err := a.server.Listener(listener); err != nil {
+			a.logger.Error("fiber server stopped unexpectedly", zap.Error(err), zapfield.Operation(op))
+			a.serverErrors <- err
+		}
+	}()
+
+	a.logger.Info("server started",
+		zap.String("mode", string(a.mode)),
+		zap.String("address", listener.Addr().String()),
+		zapfield.Operation(op),
+	)
+
+	metricsListener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", net.JoinHostPort(a.config.ServiceHost, a.config.MetricsPort))
+	if err != nil {
+		return fmt.Errorf("error listening on %s:%s: %w", a.config.ServiceHost, a.config.MetricsPort, err)
+	}
+
+	a.metricsListener = metricsListener
+
+	go func() {
+		if err := a.metricsServer.Listener(metricsListener); err != nil {
+			a.logger.Error("metrics server stopped unexpectedly", zap.Error(err), zapfield.Operation(op))
+			a.serverErrors <- err
+		}
+	}()
+
+	a.logger.Info("metrics server started",
+		zap.String("address", metricsListener.Addr().String()),
+		zapfield.Operation(op),
+	)
+
+	if a.gateway == nil {
+		return nil
+	}
+
+	gatewayListener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", net.JoinHostPort(a.config.ServiceHost, a.config.S3GatewayPort))
+	if err != nil {
+		return fmt.Errorf("error listening on %s:%s: %w", a.config.ServiceHost, a.config.S3GatewayPort, err)
+	}
+
+	a.gatewayListener = gatewayListener
+
+	go func() {
+		if err := a.gateway.Listener(gatewayListener); err != nil {
+			a.logger.Error("s3 gateway stopped unexpectedly", zap.Error(err), zapfield.Operation(op))
+			a.serverErrors <- err
+		}
+	}()
+
+	a.logger.Info("s3 gateway started",
+		zap.String("address", gatewayListener.Addr().String()),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+// Addr returns the address the http server is listening on, which is useful when binding to port 0 in tests
+func (a *App) Addr() net.Addr {
+	if a.listener == nil {
+		return nil
+	}
+
+	return a.listener.Addr()
+}
+
+// Errors receives an error if the http server stops without Shutdown being called
+func (a *App) Errors() <-chan error {
+	return a.serverErrors
+}
+
+// Shutdown stops the app in dependency order: readiness is failed first so load balancers drain traffic, then
+// in-flight http requests are drained, the job client is soft stopped and hard stopped once its deadline passes,
+// and finally the database pool is closed and telemetry and logs are flushed
+func (a *App) Shutdown(ctx context.Context) error {
+	const op = "app.Shutdown"
+
+	var errs []error
+
+	a.healthService.MarkShuttingDown()
+
+	if a.config.ShutdownReadinessDelay > 0 {
+		a.logger.Info("waiting for readiness to propagate before draining", zapfield.Operation(op))
+
+		select {
+		case <-time.After(time.Duration(a.config.ShutdownReadinessDelay) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+
+	if a.listener != nil {
+		httpCtx, cancel := context.WithTimeout(ctx, time.Duration(a.config.ShutdownHttpTimeout)*time.Second)
+		err := a.server.ShutdownWithContext(httpCtx)
+		cancel()
+		if err != nil {
+			a.logger.Error("error draining http server", zap.Error(err), zapfield.Operation(op))
+			errs = append(errs, fmt.Errorf("error draining http server: %w", err))
+		}
+	}
+
+	if a.gatewayListener != nil {
+		gatewayCtx, cancel := context.WithTimeout(ctx, time.Duration(a.config.ShutdownHttpTimeout)*time.Second)
+		err := a.gateway.ShutdownWithContext(gatewayCtx)
+		cancel()
+		if err != nil {
+			a.logger.Error("error draining s3 gateway", zap.Error(err), zapfield.Operation(op))
+			errs = append(errs, fmt.Errorf("error draining s3 gateway: %w", err))
+		}
+	}
+
+	// metrics are served until the requests they record are drained
+	if a.metricsListener != nil {
+		metricsCtx, cancel := context.WithTimeout(ctx, time.Duration(a.config.ShutdownHttpTimeout)*time.Second)
+		err := a.metricsServer.ShutdownWithContext(metricsCtx)
+		cancel()
+		if err != nil {
+			a.logger.Error("error draining metrics server", zap.Error(err), zapfield.Operation(op))
+			errs = append(errs, fmt.Errorf("error draining metrics server: %w", err))
+		}
+	}
+
+	if a.jobStarted {
+		if err := a.stopJobs(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if a.cancelJobEvents != nil {
+		a.cancelJobEvents()
+	}
+
+	a.db.Close()
+
+	if err := a.tracerProvider.Shutdown(ctx); err != nil {
+		a.logger.Error("error shutting down tracer provider", zap.Error(err), zapfield.Operation(op))
+		errs = append(errs, fmt.Errorf("error shutting down tracer provider: %w", err))
+	}
+
+	a.logger.Info("shutdown completed", zapfield.Operation(op))
+
+	// syncing stdout and stderr fails on some platforms, there is nothing useful to do with that error
+	_ = a.logger.Sync()
+
+	return errors.Join(errs...)
+}
+
+func (a *App) stopJobs(ctx context.Context) error {
+	const op = "app.stopJobs"
+
+	softCtx, cancel := context.WithTimeout(ctx, time.Duration(a.config.ShutdownJobTimeout)*time.Second)
+	defer cancel()
+
+	err := a.job.Stop(softCtx)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		a.logger.Error("error stopping river client", zap.Error(err), zapfield.Operation(op))
+		return fmt.Errorf("error stopping river client: %w", err)
+	}
+
+	a.logger.Warn("jobs did not finish before the shutdown deadline, cancelling remaining work", zapfield.Operation(op))
+
+	hardCtx, hardCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer hardCancel()
+
+	if err = a.job.StopAndCancel(hardCtx); err != nil {
+		a.logger.Error("error cancelling river client work", zap.Error(err), zapfield.Operation(op))
+		return fmt.Errorf("error cancelling river client work: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivermigrate"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// Migrate brings both the storage schema and the river job schema up to date
+func Migrate(ctx context.Context, config *config.Config, logger *zap.Logger) error {
+	const op = "app.Migrate"
+
+	database.NewMigrations(config, logger)
+
+	pgxPool, err := NewDatabasePool(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer pgxPool.Close()
+
+	riverMigrator := rivermigrate.New[pgx.Tx](riverpgxv5.New(pgxPool), nil)
+
+	if _, err = riverMigrator.Migrate(ctx, rivermigrate.DirectionUp, nil); err != nil {
+		return fmt.Errorf("error migrating river jobs schema: %w", err)
+	}
+
+	logger.Info("river job migrations done successfully", zapfield.Operation(op))
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"fmt"
+)
+
+// Mode selects which parts of the service a process runs so api replicas and job workers can be scaled independently
+type Mode string
+
+const (
+	// ModeServe serves the http api and only inserts jobs
+	ModeServe Mode = "serve"
+	// ModeWorker works jobs and serves nothing but health and metrics endpoints
+	ModeWorker Mode = "worker"
+	// ModeAll runs migrations, the http api and the job workers in a single process
+	ModeAll Mode = "all"
+	// ModeMigrate runs database and job schema migrations and exits
+	ModeMigrate Mode = "migrate"
+)
+
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case ModeServe, ModeWorker, ModeAll, ModeMigrate:
+		return Mode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected one of 'serve', 'worker', 'all' or 'migrate'", mode)
+	}
+}
+
+func (m Mode) servesApi() bool {
+	return m == ModeServe || m == ModeAll
+}
+
+func (m Mode) worksJobs() bool {
+	return m == ModeWorker || m == ModeAll
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/openapi"
+	"go.uber.org/zap"
+)
+
+// undocumentedRoutes are served outside the json api and are intentionally left out of the document
+var undocumentedRoutes = map[string]bool{}
+
+func TestOpenApi_DocumentsEveryRoute(t *testing.T) {
+	logger := zap.NewNop()
+
+	a := &App{
+		mode:    ModeServe,
+		config:  &config.Config{},
+		logger:  logger,
+		metrics: metrics.NewMetrics(logger),
+	}
+	a.setupServer()
+
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec(), &document))
+
+	registered := make(map[string]bool)
+
+	for _, route := range a.server.GetRoutes(true) {
+		// fiber registers a HEAD route alongside every GET route
+		if route.Method == http.MethodHead || undocumentedRoutes[route.Path] {
+			continue
+		}
+
+		path := fiberPathToOpenApiPath(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		_, ok := document.Paths[path][method]
+		assert.True(t, ok, "route %s %s is registered but missing from the openapi document, annotate the handler and run `go generate ./openapi`", route.Method, route.Path)
+	}
+
+	for path, operations := range document.Paths {
+		for method := range operations {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", strings.ToUpper(method), path)
+		}
+	}
+}
+
+// fiberPathToOpenApiPath rewrites fiber's `:param` segments to openapi's `{param}`
+func fiberPathToOpenApiPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + strings.TrimSuffix(strings.TrimPrefix(segment, ":"), "?") + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
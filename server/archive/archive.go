@@ -0,0 +1,295 @@
+// Package archive writes buckets into tar or zip archives and reads them back. an archive starts with its manifest,
+// followed by every object as a json entry with its catalog row right before an entry with its content
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/teapartydev/storage/server/models"
+)
+
+const (
+	// Version is bumped whenever the layout of archives changes, archives of another version are not restored
+	Version = 1
+
+	manifestEntryName = "manifest.json"
+)
+
+var ErrInvalidArchive = errors.New("invalid bucket archive")
+
+type Manifest struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Bucket     *models.Bucket `json:"bucket"`
+	// ObjectCount is the number of objects the bucket held when the export started, objects deleted while it ran are
+	// missing from the archive
+	ObjectCount int64 `json:"object_count"`
+}
+
+type Object struct {
+	Id            string            `json:"id"`
+	Name          string            `json:"name"`
+	MimeType      string            `json:"mime_type"`
+	Size          int64             `json:"size"`
+	Metadata      json.RawMessage   `json:"metadata"`
+	Tags          map[string]string `json:"tags"`
+	LegalHold     bool              `json:"legal_hold"`
+	RetentionMode *string           `json:"retention_mode"`
+	RetainUntil   *time.Time        `json:"retain_until"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+func objectEntryName(id string) string {
+	return "objects/" + id + ".json"
+}
+
+func contentEntryName(id string) string {
+	return "objects/" + id
+}
+
+// Writer writes an archive entry by entry, nothing is readable before Close
+type Writer struct {
+	tar *tar.Writer
+	zip *zip.Writer
+	// commit and abort finish or discard the destination the archive is written to
+	commit func() error
+	abort  func()
+}
+
+func NewWriter(format string, w io.Writer) *Writer {
+	if format == models.BucketArchiveFormatZip {
+		return &Writer{zip: zip.NewWriter(w)}
+	}
+	return &Writer{tar: tar.NewWriter(w)}
+}
+
+func (w *Writer) WriteManifest(manifest *Manifest) error {
+	return w.writeJson(manifestEntryName, manifest.ExportedAt, manifest)
+}
+
+// WriteObject writes the catalog row of an object followed by its content, content must hold exactly object.Size bytes
+func (w *Writer) WriteObject(object *Object, content io.Reader) error {
+	if err := w.writeJson(objectEntryName(object.Id), object.CreatedAt, object); err != nil {
+		return err
+	}
+
+	return w.writeEntry(contentEntryName(object.Id), object.Size, object.CreatedAt, zip.Store, content)
+}
+
+// Close finishes the archive and its destination
+func (w *Writer) Close() error {
+	var err error
+	if w.zip != nil {
+		err = w.zip.Close()
+	} else {
+		err = w.tar.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.commit != nil {
+		return w.commit()
+	}
+
+	return nil
+}
+
+// Abort discards an archive that is not going to be closed
+func (w *Writer) Abort() {
+	if w.abort != nil {
+		w.abort()
+	}
+}
+
+func (w *Writer) writeJson(name string, modified time.Time, v any) error {
+	entry, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return w.writeEntry(name, int64(len(entry)), modified, zip.Deflate, bytes.NewReader(entry))
+}
+
+// writeEntry copies exactly size bytes of content, content that ends early fails the entry
+func (w *Writer) writeEntry(name string, size int64, modified time.Time, method uint16, content io.Reader) error {
+	var entry io.Writer
+	var err error
+
+	if w.zip != nil {
+		entry, err = w.zip.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   method,
+			Modified: modified,
+		})
+	} else {
+		err = w.tar.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     size,
+			Mode:     0o644,
+			ModTime:  modified,
+			Format:   tar.FormatPAX,
+		})
+		entry = w.tar
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.CopyN(entry, content, size); err != nil {
+		return fmt.Errorf("failed to write archive entry '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+type entry struct {
+	name    string
+	size    int64
+	content io.Reader
+}
+
+// Reader reads an archive entry by entry in the order they were written
+type Reader struct {
+	tar  *tar.Reader
+	zip  *zip.Reader
+	next int
+	// open is the content of the current zip entry, it is closed when moving to the next one
+	open  io.ReadCloser
+	close func() error
+}
+
+func NewTarReader(r io.Reader) *Reader {
+	return &Reader{tar: tar.NewReader(r)}
+}
+
+func NewZipReader(r io.ReaderAt, size int64) (*Reader, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	return &Reader{zip: zipReader}, nil
+}
+
+// ReadManifest reads the manifest, it has to be read before any object
+func (r *Reader) ReadManifest() (*Manifest, error) {
+	manifestEntry, err := r.nextEntry()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: archive is empty", ErrInvalidArchive)
+		}
+		return nil, err
+	}
+
+	if manifestEntry.name != manifestEntryName {
+		return nil, fmt.Errorf("%w: archive does not start with a manifest", ErrInvalidArchive)
+	}
+
+	var manifest Manifest
+	if err = json.NewDecoder(manifestEntry.content).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: unreadable manifest: %w", ErrInvalidArchive, err)
+	}
+
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("%w: archive version %d is not supported", ErrInvalidArchive, manifest.Version)
+	}
+
+	if manifest.Bucket == nil {
+		return nil, fmt.Errorf("%w: manifest has no bucket", ErrInvalidArchive)
+	}
+
+	return &manifest, nil
+}
+
+// NextObject reads the catalog row of the next object and returns it with its content, the content can only be read
+// until NextObject is called again. it returns io.EOF after the last object
+func (r *Reader) NextObject() (*Object, io.Reader, error) {
+	objectEntry, err := r.nextEntry()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var object Object
+	if err = json.NewDecoder(objectEntry.content).Decode(&object); err != nil {
+		return nil, nil, fmt.Errorf("%w: unreadable object entry '%s': %w", ErrInvalidArchive, objectEntry.name, err)
+	}
+
+	if object.Id == "" || objectEntry.name != objectEntryName(object.Id) {
+		return nil, nil, fmt.Errorf("%w: unexpected entry '%s'", ErrInvalidArchive, objectEntry.name)
+	}
+
+	contentEntry, err := r.nextEntry()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("%w: object '%s' has no content", ErrInvalidArchive, object.Id)
+		}
+		return nil, nil, err
+	}
+
+	if contentEntry.name != contentEntryName(object.Id) || contentEntry.size != object.Size {
+		return nil, nil, fmt.Errorf("%w: content of object '%s' is missing or has the wrong size", ErrInvalidArchive, object.Id)
+	}
+
+	return &object, contentEntry.content, nil
+}
+
+// Close releases the source the archive was read from
+func (r *Reader) Close() error {
+	if r.open != nil {
+		_ = r.open.Close()
+	}
+
+	if r.close != nil {
+		return r.close()
+	}
+
+	return nil
+}
+
+func (r *Reader) nextEntry() (*entry, error) {
+	if r.zip != nil {
+		if r.open != nil {
+			_ = r.open.Close()
+			r.open = nil
+		}
+
+		if r.next >= len(r.zip.File) {
+			return nil, io.EOF
+		}
+
+		file := r.zip.File[r.next]
+		r.next++
+
+		content, err := file.Open()
+		if err != nil {
+			return nil, invalid(err)
+		}
+		r.open = content
+
+		return &entry{name: file.Name, size: int64(file.UncompressedSize64), content: content}, nil
+	}
+
+	header, err := r.tar.Next()
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	return &entry{name: header.Name, size: header.Size, content: r.tar}, nil
+}
+
+// invalid marks the errors of malformed archives, errors reading the archive from its location are left as they are
+func invalid(err error) error {
+	if errors.Is(err, tar.ErrHeader) || errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrAlgorithm) {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/models"
+)
+
+func testManifest() *Manifest {
+	return &Manifest{
+		Version:     Version,
+		ExportedAt:  time.Date(2024, 2, 13, 8, 14, 49, 0, time.UTC),
+		Bucket:      &models.Bucket{Id: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar"},
+		ObjectCount: 2,
+	}
+}
+
+func testObjects() []*Object {
+	return []*Object{
+		{
+			Id:       "object_01HPG4GN5JY2Z6S0638ERSG375",
+			Name:     "avatars/a.png",
+			MimeType: "image/png",
+			Size:     5,
+			Metadata: []byte(`{"width":1}`),
+			Tags:     map[string]string{"team": "core"},
+		},
+		{
+			Id:       "object_01HPG4GN5JY2Z6S0638ERSG376",
+			Name:     "avatars/b.txt",
+			MimeType: "text/plain",
+			Size:     3,
+		},
+	}
+}
+
+func writeArchive(t *testing.T, writer *Writer) {
+	require.NoError(t, writer.WriteManifest(testManifest()))
+	require.NoError(t, writer.WriteObject(testObjects()[0], strings.NewReader("hello")))
+	require.NoError(t, writer.WriteObject(testObjects()[1], strings.NewReader("bye")))
+	require.NoError(t, writer.Close())
+}
+
+func readArchive(t *testing.T, reader *Reader) {
+	manifest, err := reader.ReadManifest()
+	require.NoError(t, err)
+	assert.Equal(t, testManifest().Bucket.Id, manifest.Bucket.Id)
+	assert.Equal(t, int64(2), manifest.ObjectCount)
+
+	var contents []string
+	for _, expected := range testObjects() {
+		object, content, err := reader.NextObject()
+		require.NoError(t, err)
+		assert.Equal(t, expected.Id, object.Id)
+		assert.Equal(t, expected.Name, object.Name)
+		assert.Equal(t, expected.MimeType, object.MimeType)
+		assert.Equal(t, expected.Tags, object.Tags)
+
+		body, err := io.ReadAll(content)
+		require.NoError(t, err)
+		contents = append(contents, string(body))
+	}
+	assert.Equal(t, []string{"hello", "bye"}, contents)
+
+	_, _, err = reader.NextObject()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, reader.Close())
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	t.Run("Tar", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writeArchive(t, NewWriter(models.BucketArchiveFormatTar, &buffer))
+		readArchive(t, NewTarReader(&buffer))
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writeArchive(t, NewWriter(models.BucketArchiveFormatZip, &buffer))
+
+		reader, err := NewZipReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		require.NoError(t, err)
+		readArchive(t, reader)
+	})
+}
+
+func TestArchive_ShortContent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewWriter(models.BucketArchiveFormatTar, &buffer)
+	require.NoError(t, writer.WriteManifest(testManifest()))
+
+	err := writer.WriteObject(testObjects()[0], strings.NewReader("hel"))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestArchive_Invalid(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewWriter(models.BucketArchiveFormatTar, &buffer)
+	require.NoError(t, writer.WriteObject(testObjects()[1], strings.NewReader("bye")))
+	require.NoError(t, writer.Close())
+
+	_, err := NewTarReader(&buffer).ReadManifest()
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	_, err = NewZipReader(strings.NewReader("not a zip"), 9)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+}
+
+func TestStore_Local(t *testing.T) {
+	store := NewStore(nil, &config.Config{BucketArchiveDirectory: t.TempDir()})
+	ctx := context.Background()
+
+	for _, path := range []string{"offboarding/avatar.tar", "offboarding/avatar.zip"} {
+		t.Run(path, func(t *testing.T) {
+			bucketArchive := &models.BucketArchive{Location: models.BucketArchiveLocationLocal, Path: path}
+
+			_, err := store.Open(ctx, bucketArchive)
+			assert.ErrorIs(t, err, ErrArchiveNotFound)
+
+			writer, err := store.Create(ctx, bucketArchive)
+			require.NoError(t, err)
+			writeArchive(t, writer)
+
+			reader, err := store.Open(ctx, bucketArchive)
+			require.NoError(t, err)
+			readArchive(t, reader)
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := NewStore(nil, &config.Config{}).Create(ctx, &models.BucketArchive{Location: models.BucketArchiveLocationLocal, Path: "avatar.tar"})
+		assert.ErrorIs(t, err, ErrLocalArchivesDisabled)
+	})
+}
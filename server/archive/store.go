@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+)
+
+// localBufferSize buffers the reads and writes of local archives, entries are mostly written in small pieces
+const localBufferSize = 1 << 20
+
+var (
+	ErrArchiveNotFound       = errors.New("bucket archive not found")
+	ErrLocalArchivesDisabled = errors.New("local bucket archives are disabled, bucket_archive_directory is not set")
+)
+
+// Store creates and opens archives at the location they are kept in. archives in storage are kept under
+// storage.ArchiveBucket, local archives under the configured archive directory
+type Store struct {
+	storage   *storage.Storage
+	directory string
+}
+
+func NewStore(storage *storage.Storage, config *config.Config) *Store {
+	return &Store{
+		storage:   storage,
+		directory: config.BucketArchiveDirectory,
+	}
+}
+
+// Create starts writing an archive. an archive already at the path is only replaced once the writer is closed
+func (s *Store) Create(ctx context.Context, archive *models.BucketArchive) (*Writer, error) {
+	if archive.Location == models.BucketArchiveLocationLocal {
+		path, err := s.localPath(archive.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			return nil, err
+		}
+
+		file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+		if err != nil {
+			return nil, err
+		}
+
+		buffer := bufio.NewWriterSize(file, localBufferSize)
+
+		writer := NewWriter(archive.Format(), buffer)
+		writer.commit = func() error {
+			err := buffer.Flush()
+			if err == nil {
+				err = file.Sync()
+			}
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil {
+				err = os.Rename(file.Name(), path)
+			}
+			if err != nil {
+				_ = os.Remove(file.Name())
+			}
+			return err
+		}
+		writer.abort = func() {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+
+		return writer, nil
+	}
+
+	objectWriter, err := s.storage.NewObjectWriter(ctx, &storage.ObjectWrite{
+		Bucket:      storage.ArchiveBucket,
+		Name:        archive.Path,
+		ContentType: contentType(archive.Format()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writer := NewWriter(archive.Format(), objectWriter)
+	writer.commit = objectWriter.Close
+	writer.abort = func() {
+		// the upload is aborted after the job failed or was cancelled, its context may be done already
+		_ = objectWriter.Abort(context.WithoutCancel(ctx))
+	}
+
+	return writer, nil
+}
+
+// Open opens an archive for reading, tar archives in storage are streamed and zip archives are read through range
+// requests
+func (s *Store) Open(ctx context.Context, archive *models.BucketArchive) (*Reader, error) {
+	if archive.Location == models.BucketArchiveLocationLocal {
+		path, err := s.localPath(archive.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, ErrArchiveNotFound
+			}
+			return nil, err
+		}
+
+		var reader *Reader
+		if archive.Format() == models.BucketArchiveFormatZip {
+			info, err := file.Stat()
+			if err == nil {
+				reader, err = NewZipReader(file, info.Size())
+			}
+			if err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+		} else {
+			reader = NewTarReader(bufio.NewReaderSize(file, localBufferSize))
+		}
+		reader.close = file.Close
+
+		return reader, nil
+	}
+
+	if archive.Format() == models.BucketArchiveFormatZip {
+		readerAt, err := s.storage.NewObjectReaderAt(ctx, &storage.ObjectHead{
+			Bucket: storage.ArchiveBucket,
+			Name:   archive.Path,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				return nil, ErrArchiveNotFound
+			}
+			return nil, err
+		}
+
+		return NewZipReader(readerAt, readerAt.Size())
+	}
+
+	content, err := s.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: storage.ArchiveBucket,
+		Name:   archive.Path,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+
+	reader := NewTarReader(content.Body)
+	reader.close = content.Body.Close
+
+	return reader, nil
+}
+
+func (s *Store) localPath(path string) (string, error) {
+	if s.directory == "" {
+		return "", ErrLocalArchivesDisabled
+	}
+
+	return filepath.Join(s.directory, filepath.FromSlash(path)), nil
+}
+
+func contentType(format string) string {
+	if format == models.BucketArchiveFormatZip {
+		return "application/zip"
+	}
+	return "application/x-tar"
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/teapartydev/storage/server/models"
+)
+
+func (c *Client) CreateBucket(ctx context.Context, bucketCreate *models.BucketCreate) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets", nil, bucketCreate, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (c *Client) UpdateBucket(ctx context.Context, bucketUpdate *models.BucketUpdate) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPatch, "/api/v1/buckets/"+url.PathEscape(bucketUpdate.Id), nil, bucketUpdate, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// RenameBucket renames a bucket stored under its id, buckets stored under their name have to be relocated first
+func (c *Client) RenameBucket(ctx context.Context, bucketRename *models.BucketRename) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(bucketRename.Id)+"/rename", nil, bucketRename, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// RelocateBucket locks the bucket and moves its content under its id in the background, it requires an admin api key
+func (c *Client) RelocateBucket(ctx context.Context, id string) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/relocate", nil, nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// EmptyBucket locks the bucket and deletes its objects in the background, the returned operation reports the progress.
+// bypassGovernance deletes objects under governance retention as well, it requires an admin api key
+func (c *Client) EmptyBucket(ctx context.Context, id string, bypassGovernance bool) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/empty", governanceBypassQuery(bypassGovernance), nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (c *Client) DisableBucket(ctx context.Context, id string) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/disable", nil, nil, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (c *Client) EnableBucket(ctx context.Context, id string) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/enable", nil, nil, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// UnlockBucket force unlocks a bucket and cancels the job holding its lock, it requires an admin api key
+func (c *Client) UnlockBucket(ctx context.Context, id string) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/unlock", nil, nil, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// ImportBucket imports content already in storage into the catalog of the bucket in the background
+func (c *Client) ImportBucket(ctx context.Context, id string, bucketImport *models.BucketImport) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/import", nil, bucketImport, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// DeleteBucket locks the bucket and deletes it along with its objects in the background, the returned operation
+// reports the progress. bypassGovernance deletes objects under governance retention as well, it requires an admin api
+// key
+func (c *Client) DeleteBucket(ctx context.Context, id string, bypassGovernance bool) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/buckets/"+url.PathEscape(id), governanceBypassQuery(bypassGovernance), nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// governanceBypassQuery asks the server to bypass governance retention, nothing is sent when it is not bypassed
+func governanceBypassQuery(bypassGovernance bool) url.Values {
+	if !bypassGovernance {
+		return nil
+	}
+	return url.Values{"bypass_governance_retention": {"true"}}
+}
+
+func (c *Client) ListAllBuckets(ctx context.Context) ([]*models.Bucket, error) {
+	var buckets []*models.Bucket
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets", nil, nil, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+func (c *Client) SearchBuckets(ctx context.Context, name string) ([]*models.Bucket, error) {
+	var buckets []*models.Bucket
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets/search", url.Values{"name": {name}}, nil, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+func (c *Client) GetBucket(ctx context.Context, id string) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets/"+url.PathEscape(id), nil, nil, &bucket); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (c *Client) GetBucketSize(ctx context.Context, id string) (*models.BucketSize, error) {
+	var bucketSize models.BucketSize
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets/"+url.PathEscape(id)+"/size", nil, nil, &bucketSize); err != nil {
+		return nil, err
+	}
+	return &bucketSize, nil
+}
+
+// ReconcileBucket compares a bucket in storage with its catalog without fixing anything, it requires an admin api key
+func (c *Client) ReconcileBucket(ctx context.Context, id string) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets/"+url.PathEscape(id)+"/reconciliation", nil, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ExportBucket exports a bucket into an archive in the background, it requires an admin api key
+func (c *Client) ExportBucket(ctx context.Context, id string, bucketArchive *models.BucketArchive) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(id)+"/export", nil, bucketArchive, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// RestoreBucket recreates the bucket of an archive and restores its objects in the background, it requires an admin
+// api key
+func (c *Client) RestoreBucket(ctx context.Context, bucketRestore *models.BucketRestore) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/restore", nil, bucketRestore, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (c *Client) CreateTagRule(ctx context.Context, tagRuleCreate *models.TagRuleCreate) (*models.TagRule, error) {
+	var tagRule models.TagRule
+	if err := c.do(ctx, http.MethodPost, "/api/v1/buckets/"+url.PathEscape(tagRuleCreate.BucketId)+"/tag-rules", nil, tagRuleCreate, &tagRule); err != nil {
+		return nil, err
+	}
+	return &tagRule, nil
+}
+
+func (c *Client) ListTagRules(ctx context.Context, bucketId string) ([]*models.TagRule, error) {
+	var tagRules []*models.TagRule
+	if err := c.do(ctx, http.MethodGet, "/api/v1/buckets/"+url.PathEscape(bucketId)+"/tag-rules", nil, nil, &tagRules); err != nil {
+		return nil, err
+	}
+	return tagRules, nil
+}
+
+func (c *Client) DeleteTagRule(ctx context.Context, bucketId string, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/buckets/"+url.PathEscape(bucketId)+"/tag-rules/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,185 @@
+// Package client is a typed Go client for the storage http api. Errors returned by the api are decoded into
+// *HttpError values that match the srverr error codes, so callers can use errors.Is(err, srverr.NotFoundError)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiKeyHeader = "X-STORAGE-API-KEY"
+
+type Client struct {
+	baseUrl      string
+	apiKey       string
+	httpClient   *http.Client
+	maxRetries   int
+	minRetryWait time.Duration
+	maxRetryWait time.Duration
+	userAgent    string
+}
+
+type Option func(c *Client)
+
+// WithHttpClient replaces the default http client, for example to set timeouts or a custom transport
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times idempotent requests are retried and the bounds of the backoff between attempts
+func WithRetries(maxRetries int, minWait time.Duration, maxWait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minRetryWait = minWait
+		c.maxRetryWait = maxWait
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func NewClient(baseUrl string, apiKey string, options ...Option) *Client {
+	c := &Client{
+		baseUrl:      strings.TrimRight(baseUrl, "/"),
+		apiKey:       apiKey,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		maxRetries:   3,
+		minRetryWait: 200 * time.Millisecond,
+		maxRetryWait: 5 * time.Second,
+		userAgent:    "hyperdrift-storage-go-client",
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// do sends a request to the api and decodes a json response into result when it is not nil. GET, PUT and DELETE
+// requests are retried on network errors and on 429, 502, 503 and 504 responses
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, result any) error {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request body: %w", err)
+		}
+	}
+
+	requestUrl := c.baseUrl + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	retryable := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, requestUrl, bytes.NewReader(bodyBytes))
+		if err != nil {
+			return fmt.Errorf("error creating request: %w", err)
+		}
+
+		request.Header.Set(apiKeyHeader, c.apiKey)
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("User-Agent", c.userAgent)
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		response, err := c.httpClient.Do(request)
+		if err != nil {
+			if retryable && attempt < c.maxRetries && ctx.Err() == nil {
+				if waitErr := c.wait(ctx, attempt, ""); waitErr != nil {
+					return waitErr
+				}
+				continue
+			}
+			return fmt.Errorf("error sending request to %s %s: %w", method, path, err)
+		}
+
+		if retryable && attempt < c.maxRetries && isRetryableStatus(response.StatusCode) {
+			retryAfter := response.Header.Get("Retry-After")
+			drainAndClose(response)
+			if waitErr := c.wait(ctx, attempt, retryAfter); waitErr != nil {
+				return waitErr
+			}
+			continue
+		}
+
+		return decodeResponse(response, result)
+	}
+}
+
+func decodeResponse(response *http.Response, result any) error {
+	defer drainAndClose(response)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return newHttpError(response)
+	}
+
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding response body: %w", err)
+	}
+
+	return nil
+}
+
+// wait sleeps before the next attempt using exponential backoff with jitter, or the server's Retry-After in seconds
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter string) error {
+	delay := c.minRetryWait << attempt
+	if delay > c.maxRetryWait || delay <= 0 {
+		delay = c.maxRetryWait
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+		if delay > c.maxRetryWait {
+			delay = c.maxRetryWait
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func drainAndClose(response *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	_ = response.Body.Close()
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/app"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"go.uber.org/zap"
+)
+
+// newAppTestClient serves the real app, wired to the postgres and s3 from the config at HYPERDRIFT_TEST_CONFIG,
+// through httptest. The test is skipped when the variable is not set
+func newAppTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	configPath := os.Getenv("HYPERDRIFT_TEST_CONFIG")
+	if configPath == "" {
+		t.Skip("HYPERDRIFT_TEST_CONFIG is not set, skipping tests against the real app")
+	}
+
+	testConfig, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+
+	logger := zap.NewNop()
+
+	require.NoError(t, app.Migrate(context.Background(), testConfig, logger))
+
+	testApp, err := app.NewApp(app.ModeServe, testConfig, logger)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(adaptor.FiberApp(testApp.Server()))
+
+	t.Cleanup(func() {
+		server.Close()
+		_ = testApp.Shutdown(context.Background())
+	})
+
+	return NewClient(server.URL, testConfig.ServiceApiKey)
+}
+
+func TestApp_BucketAndObjectLifecycle(t *testing.T) {
+	c := newAppTestClient(t)
+	ctx := context.Background()
+
+	bucketName := fmt.Sprintf("client-test-%d", time.Now().UnixNano())
+
+	bucket, err := c.CreateBucket(ctx, &models.BucketCreate{Name: bucketName})
+	require.NoError(t, err)
+	assert.Equal(t, bucketName, bucket.Name)
+
+	_, err = c.CreateBucket(ctx, &models.BucketCreate{Name: bucketName})
+	assert.True(t, errors.Is(err, srverr.ConflictError))
+
+	_, err = c.CreateBucket(ctx, &models.BucketCreate{Name: "Invalid Name!"})
+	assert.True(t, errors.Is(err, srverr.InvalidInputError))
+
+	fetchedBucket, err := c.GetBucket(ctx, bucket.Id)
+	require.NoError(t, err)
+	assert.Equal(t, bucket.Id, fetchedBucket.Id)
+
+	_, err = c.GetBucket(ctx, "bucket_does_not_exist")
+	assert.True(t, errors.Is(err, srverr.NotFoundError))
+
+	var uploadedIds []string
+	for i := 0; i < 3; i++ {
+		body := []byte(fmt.Sprintf("object body %d", i))
+		object, err := c.UploadObject(ctx, bucket.Id, &ObjectUpload{
+			Name: fmt.Sprintf("reports/%d.txt", i),
+			Size: int64(len(body)),
+			Body: bytes.NewReader(body),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, models.ObjectUploadStatusCompleted, object.UploadStatus)
+		uploadedIds = append(uploadedIds, object.Id)
+	}
+
+	iterator := c.SearchObjectsIterator(bucket.Id, "reports/", 2)
+	var listedIds []string
+	for iterator.Next(ctx) {
+		listedIds = append(listedIds, iterator.Object().Id)
+	}
+	require.NoError(t, iterator.Err())
+	assert.ElementsMatch(t, uploadedIds, listedIds)
+
+	var downloaded bytes.Buffer
+	_, err = c.DownloadObject(ctx, bucket.Id, uploadedIds[0], &downloaded)
+	require.NoError(t, err)
+	assert.Equal(t, "object body 0", downloaded.String())
+
+	require.NoError(t, c.DeleteObject(ctx, bucket.Id, uploadedIds[0]))
+
+	_, err = c.DeleteBucket(ctx, bucket.Id, false)
+	require.NoError(t, err)
+
+	_, err = c.EmptyBucket(ctx, bucket.Id, false)
+	assert.True(t, errors.Is(err, srverr.ForbiddenError), "locked bucket should not be emptied")
+
+	_, err = NewClient(c.baseUrl, "invalid-key").ListAllBuckets(ctx)
+	assert.True(t, errors.Is(err, UnauthorizedError))
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/middleware"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+const testApiKey = "test-api-key"
+
+// newTestServer serves a fiber app with the server's error handler and request id middleware so error responses
+// are encoded exactly like the real api encodes them
+func newTestServer(t *testing.T, register func(app *fiber.App, server *httptest.Server)) (*Client, *httptest.Server) {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          middleware.ErrorHandler,
+		DisableStartupMessage: true,
+	})
+	app.Use(middleware.RequestId())
+	app.Use(func(ctx *fiber.Ctx) error {
+		// /storage stands in for the s3 endpoint pre-signed urls point at, it is authorized by the url signature
+		if strings.HasPrefix(ctx.Path(), "/storage/") {
+			return ctx.Next()
+		}
+		if ctx.Get(apiKeyHeader) != testApiKey {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&middleware.HttpError{
+				StatusCode: fiber.StatusUnauthorized,
+				Message:    "invalid api key access denied. please provide a valid api key",
+				Path:       ctx.Path(),
+			})
+		}
+		return ctx.Next()
+	})
+
+	server := httptest.NewServer(adaptor.FiberApp(app))
+	t.Cleanup(server.Close)
+
+	register(app, server)
+
+	return NewClient(server.URL, testApiKey, WithRetries(3, time.Millisecond, 5*time.Millisecond)), server
+}
+
+func TestClient_DecodesServiceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		errorCode  srverr.ErrorCode
+		statusCode int
+	}{
+		{name: "Not Found", errorCode: srverr.NotFoundError, statusCode: http.StatusNotFound},
+		{name: "Conflict", errorCode: srverr.ConflictError, statusCode: http.StatusConflict},
+		{name: "Invalid Input", errorCode: srverr.InvalidInputError, statusCode: http.StatusUnprocessableEntity},
+		{name: "Bad Request", errorCode: srverr.BadRequestError, statusCode: http.StatusBadRequest},
+		{name: "Forbidden", errorCode: srverr.ForbiddenError, statusCode: http.StatusForbidden},
+		{name: "Unknown", errorCode: srverr.UnknownError, statusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+				app.Get("/api/v1/buckets/:bucket_id", func(ctx *fiber.Ctx) error {
+					return srverr.NewServiceError(tt.errorCode, "bucket failure", "test", "req_123", nil)
+				})
+			})
+
+			_, err := c.GetBucket(context.Background(), "bucket_1")
+
+			var httpError *HttpError
+			require.True(t, errors.As(err, &httpError))
+			assert.True(t, errors.Is(err, tt.errorCode))
+			assert.Equal(t, tt.statusCode, httpError.StatusCode)
+			assert.Equal(t, "bucket failure", httpError.Message)
+			assert.Equal(t, "req_123", httpError.RequestId)
+			assert.Equal(t, "/api/v1/buckets/bucket_1", httpError.Path)
+		})
+	}
+}
+
+func TestClient_Unauthorized(t *testing.T) {
+	_, server := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Get("/api/v1/buckets", func(ctx *fiber.Ctx) error {
+			return ctx.JSON([]*models.Bucket{})
+		})
+	})
+
+	_, err := NewClient(server.URL, "wrong-key").ListAllBuckets(context.Background())
+
+	assert.True(t, errors.Is(err, UnauthorizedError))
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	var attempts atomic.Int32
+
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Get("/api/v1/buckets/:bucket_id", func(ctx *fiber.Ctx) error {
+			if attempts.Add(1) < 3 {
+				return ctx.SendStatus(fiber.StatusServiceUnavailable)
+			}
+			return ctx.JSON(&models.Bucket{Id: ctx.Params("bucket_id"), Name: "avatars"})
+		})
+	})
+
+	bucket, err := c.GetBucket(context.Background(), "bucket_1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "avatars", bucket.Name)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestClient_DoesNotRetryPost(t *testing.T) {
+	var attempts atomic.Int32
+
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Post("/api/v1/buckets", func(ctx *fiber.Ctx) error {
+			attempts.Add(1)
+			return ctx.SendStatus(fiber.StatusServiceUnavailable)
+		})
+	})
+
+	_, err := c.CreateBucket(context.Background(), &models.BucketCreate{Name: "avatars"})
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Delete("/api/v1/buckets/:bucket_id", func(ctx *fiber.Ctx) error {
+			attempts.Add(1)
+			return ctx.SendStatus(fiber.StatusBadGateway)
+		})
+	})
+
+	_, err := c.DeleteBucket(context.Background(), "bucket_1", false)
+
+	var httpError *HttpError
+	require.True(t, errors.As(err, &httpError))
+	assert.Equal(t, http.StatusBadGateway, httpError.StatusCode)
+	assert.Equal(t, int32(4), attempts.Load())
+}
+
+func TestClient_EmptyBucketReturnsOperation(t *testing.T) {
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Post("/api/v1/buckets/:bucket_id/empty", func(ctx *fiber.Ctx) error {
+			if !ctx.QueryBool("bypass_governance_retention", false) {
+				return ctx.SendStatus(fiber.StatusBadRequest)
+			}
+			return ctx.Status(fiber.StatusAccepted).JSON(models.Operation{Id: "operation_1", BucketId: ctx.Params("bucket_id")})
+		})
+	})
+
+	operation, err := c.EmptyBucket(context.Background(), "bucket_1", true)
+
+	require.NoError(t, err)
+	assert.Equal(t, "operation_1", operation.Id)
+	assert.Equal(t, "bucket_1", operation.BucketId)
+}
+
+func TestObjectIterator(t *testing.T) {
+	var objects []*models.Object
+	for i := 0; i < 7; i++ {
+		objects = append(objects, &models.Object{Id: fmt.Sprintf("object_%d", i), Name: fmt.Sprintf("avatars/%d.png", i)})
+	}
+
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Get("/api/v1/objects/search/:bucket_id", func(ctx *fiber.Ctx) error {
+			limit := ctx.QueryInt("limit")
+			offset := ctx.QueryInt("offset")
+			if offset >= len(objects) {
+				return srverr.NewServiceError(srverr.NotFoundError, "no objects found", "test", "", nil)
+			}
+			return ctx.JSON(objects[offset:min(offset+limit, len(objects))])
+		})
+	})
+
+	iterator := c.SearchObjectsIterator("bucket_1", "avatars/", 3)
+
+	var ids []string
+	for iterator.Next(context.Background()) {
+		ids = append(ids, iterator.Object().Id)
+	}
+
+	require.NoError(t, iterator.Err())
+	assert.Equal(t, []string{"object_0", "object_1", "object_2", "object_3", "object_4", "object_5", "object_6"}, ids)
+}
+
+func TestObjectIterator_ExactPages(t *testing.T) {
+	var requests atomic.Int32
+
+	c, _ := newTestServer(t, func(app *fiber.App, _ *httptest.Server) {
+		app.Get("/api/v1/objects/search/:bucket_id", func(ctx *fiber.Ctx) error {
+			requests.Add(1)
+			if ctx.QueryInt("offset") >= 2 {
+				return srverr.NewServiceError(srverr.NotFoundError, "no objects found", "test", "", nil)
+			}
+			return ctx.JSON([]*models.Object{{Id: "object_0"}, {Id: "object_1"}})
+		})
+	})
+
+	iterator := c.SearchObjectsIterator("bucket_1", "avatars/", 2)
+
+	count := 0
+	for iterator.Next(context.Background()) {
+		count++
+	}
+
+	require.NoError(t, iterator.Err())
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestClient_UploadObject(t *testing.T) {
+	var stored bytes.Buffer
+	var storedContentType string
+	var storedApiKey string
+	var completed atomic.Bool
+
+	c, _ := newTestServer(t, func(app *fiber.App, server *httptest.Server) {
+		object := &models.Object{Id: "object_1", BucketId: "bucket_1", Name: "avatars/1.png", MimeType: "image/png", UploadStatus: models.ObjectUploadStatusPending}
+
+		app.Post("/api/v1/objects/pre-signed/upload/:bucket_id", func(ctx *fiber.Ctx) error {
+			var preSignedUploadSessionCreate models.PreSignedUploadSessionCreate
+			if err := ctx.BodyParser(&preSignedUploadSessionCreate); err != nil {
+				return err
+			}
+			object.Size = preSignedUploadSessionCreate.Size
+			return ctx.Status(fiber.StatusCreated).JSON(&models.PreSignedUploadSession{
+				Id:     object.Id,
+				Url:    server.URL + "/storage/avatars/1.png?X-Amz-Signature=signature",
+				Method: http.MethodPut,
+			})
+		})
+		app.Put("/storage/*", func(ctx *fiber.Ctx) error {
+			storedContentType = ctx.Get(fiber.HeaderContentType)
+			storedApiKey = ctx.Get(apiKeyHeader)
+			stored.Write(ctx.Body())
+			return ctx.SendStatus(fiber.StatusOK)
+		})
+		app.Post("/api/v1/objects/pre-signed/upload/:bucket_id/:object_id/complete", func(ctx *fiber.Ctx) error {
+			completed.Store(true)
+			object.UploadStatus = models.ObjectUploadStatusCompleted
+			return ctx.SendStatus(fiber.StatusOK)
+		})
+		app.Get("/api/v1/objects/:bucket_id/:object_id", func(ctx *fiber.Ctx) error {
+			return ctx.JSON(object)
+		})
+	})
+
+	body := "not really a png"
+	object, err := c.UploadObject(context.Background(), "bucket_1", &ObjectUpload{
+		Name: "avatars/1.png",
+		Size: int64(len(body)),
+		Body: strings.NewReader(body),
+	})
+
+	require.NoError(t, err)
+	assert.True(t, completed.Load())
+	assert.Equal(t, body, stored.String())
+	assert.Equal(t, "image/png", storedContentType)
+	assert.Empty(t, storedApiKey, "Api key must not be sent to storage")
+	assert.Equal(t, models.ObjectUploadStatusCompleted, object.UploadStatus)
+}
+
+func TestClient_DownloadObject(t *testing.T) {
+	c, _ := newTestServer(t, func(app *fiber.App, server *httptest.Server) {
+		app.Get("/api/v1/objects/pre-signed/download/:bucket_id/:object_id", func(ctx *fiber.Ctx) error {
+			return ctx.JSON(&models.PreSignedDownloadSession{
+				Url:    server.URL + "/storage/avatars/1.png?X-Amz-Signature=signature",
+				Method: http.MethodGet,
+			})
+		})
+		app.Get("/storage/*", func(ctx *fiber.Ctx) error {
+			return ctx.SendString("object bytes")
+		})
+	})
+
+	var downloaded bytes.Buffer
+	written, err := c.DownloadObject(context.Background(), "bucket_1", "object_1", &downloaded)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("object bytes")), written)
+	assert.Equal(t, "object bytes", downloaded.String())
+}
+
+func TestClient_RenderObject(t *testing.T) {
+	c, _ := newTestServer(t, func(app *fiber.App, server *httptest.Server) {
+		app.Get("/api/v1/objects/:bucket_id/:object_id/render", func(ctx *fiber.Ctx) error {
+			ctx.Set(fiber.HeaderContentType, "image/"+ctx.Query("format"))
+			return ctx.SendString(ctx.Query("width") + "x" + ctx.Query("height"))
+		})
+	})
+
+	width, height, format := 320, 240, "webp"
+
+	var rendered bytes.Buffer
+	mimeType, written, err := c.RenderObject(context.Background(), &models.ObjectRender{
+		BucketId: "bucket_1",
+		ObjectId: "object_1",
+		Width:    &width,
+		Height:   &height,
+		Format:   &format,
+	}, &rendered)
+
+	require.NoError(t, err)
+	assert.Equal(t, "image/webp", mimeType)
+	assert.Equal(t, int64(len("320x240")), written)
+	assert.Equal(t, "320x240", rendered.String())
+}
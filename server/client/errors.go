@@ -0,0 +1,69 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+// UnauthorizedError is the error code of responses rejected because the api key is missing or invalid
+var UnauthorizedError srverr.ErrorCode = errors.New("unauthorized error")
+
+// HttpError is an error response from the api. It unwraps to the matching srverr error code
+type HttpError struct {
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+	Path       string `json:"path"`
+	RequestId  string `json:"request_id"`
+	// ErrorCode is derived from the status code the same way the server maps srverr codes to status codes
+	ErrorCode srverr.ErrorCode `json:"-"`
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("status_code: %d, error_code: %s, message: %s, path: %s, request_id: %s", e.StatusCode, e.ErrorCode, e.Message, e.Path, e.RequestId)
+}
+
+func (e *HttpError) Unwrap() error {
+	return e.ErrorCode
+}
+
+func newHttpError(response *http.Response) *HttpError {
+	httpError := &HttpError{
+		StatusCode: response.StatusCode,
+		Path:       response.Request.URL.Path,
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err := json.Unmarshal(body, httpError); err != nil || httpError.Message == "" {
+		httpError.Message = http.StatusText(response.StatusCode)
+	}
+
+	// the body may carry its own status code, the one on the response is authoritative
+	httpError.StatusCode = response.StatusCode
+	httpError.ErrorCode = errorCodeFromStatus(response.StatusCode)
+
+	return httpError
+}
+
+func errorCodeFromStatus(statusCode int) srverr.ErrorCode {
+	switch statusCode {
+	case http.StatusNotFound:
+		return srverr.NotFoundError
+	case http.StatusConflict:
+		return srverr.ConflictError
+	case http.StatusUnprocessableEntity:
+		return srverr.InvalidInputError
+	case http.StatusBadRequest:
+		return srverr.BadRequestError
+	case http.StatusForbidden:
+		return srverr.ForbiddenError
+	case http.StatusUnauthorized:
+		return UnauthorizedError
+	default:
+		return srverr.UnknownError
+	}
+}
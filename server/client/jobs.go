@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/teapartydev/storage/server/models"
+)
+
+// GetJob returns the state of a background job, long running jobs such as emptying a bucket report their progress
+func (c *Client) GetJob(ctx context.Context, id int64) (*models.Job, error) {
+	var job models.Job
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+strconv.FormatInt(id, 10), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
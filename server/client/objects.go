@@ -0,0 +1,420 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+func (c *Client) CreatePreSignedUploadSession(ctx context.Context, preSignedUploadSessionCreate *models.PreSignedUploadSessionCreate) (*models.PreSignedUploadSession, error) {
+	var preSignedUploadSession models.PreSignedUploadSession
+	path := "/api/v1/objects/pre-signed/upload/" + url.PathEscape(preSignedUploadSessionCreate.BucketId)
+	if err := c.do(ctx, http.MethodPost, path, nil, preSignedUploadSessionCreate, &preSignedUploadSession); err != nil {
+		return nil, err
+	}
+	return &preSignedUploadSession, nil
+}
+
+func (c *Client) CompletePreSignedUploadSession(ctx context.Context, bucketId string, objectId string) error {
+	path := "/api/v1/objects/pre-signed/upload/" + url.PathEscape(bucketId) + "/" + url.PathEscape(objectId) + "/complete"
+	return c.do(ctx, http.MethodPost, path, nil, nil, nil)
+}
+
+// CreatePreSignedDownloadSession creates a download url valid for expiresIn seconds, 0 uses the server default. an
+// archived object is refused, restore starts its restore to the hot tier as well
+func (c *Client) CreatePreSignedDownloadSession(ctx context.Context, bucketId string, objectId string, expiresIn int64, restore bool) (*models.PreSignedDownloadSession, error) {
+	query := url.Values{}
+	if expiresIn != 0 {
+		query.Set("expires_in", strconv.FormatInt(expiresIn, 10))
+	}
+	if restore {
+		query.Set("restore", "true")
+	}
+
+	var preSignedDownloadSession models.PreSignedDownloadSession
+	path := "/api/v1/objects/pre-signed/download/" + url.PathEscape(bucketId) + "/" + url.PathEscape(objectId)
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &preSignedDownloadSession); err != nil {
+		return nil, err
+	}
+	return &preSignedDownloadSession, nil
+}
+
+// DeleteObject deletes an uploaded object in the background
+func (c *Client) DeleteObject(ctx context.Context, bucketId string, objectId string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/objects/"+url.PathEscape(bucketId)+"/"+url.PathEscape(objectId), nil, nil, nil)
+}
+
+func (c *Client) SearchObjects(ctx context.Context, bucketId string, objectPath string, limit int32, offset int32) ([]*models.Object, error) {
+	query := url.Values{
+		"object_path": {objectPath},
+		"limit":       {strconv.FormatInt(int64(limit), 10)},
+		"offset":      {strconv.FormatInt(int64(offset), 10)},
+	}
+
+	var objects []*models.Object
+	if err := c.do(ctx, http.MethodGet, "/api/v1/objects/search/"+url.PathEscape(bucketId), query, nil, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (c *Client) GetObject(ctx context.Context, bucketId string, objectId string) (*models.Object, error) {
+	var object models.Object
+	if err := c.do(ctx, http.MethodGet, "/api/v1/objects/"+url.PathEscape(bucketId)+"/"+url.PathEscape(objectId), nil, nil, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// UpdateObject replaces or merges the metadata and changes the mime type of an object. a conflict error means the
+// object changed since objectUpdate.Version, get it again and retry
+func (c *Client) UpdateObject(ctx context.Context, objectUpdate *models.ObjectUpdate) (*models.Object, error) {
+	var object models.Object
+	path := "/api/v1/objects/" + url.PathEscape(objectUpdate.BucketId) + "/" + url.PathEscape(objectUpdate.Id)
+	if err := c.do(ctx, http.MethodPatch, path, nil, objectUpdate, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+func (c *Client) SearchObjectsByMetadata(ctx context.Context, objectMetadataSearch *models.ObjectMetadataSearch) ([]*models.Object, error) {
+	var objects []*models.Object
+	path := "/api/v1/objects/search/" + url.PathEscape(objectMetadataSearch.BucketId) + "/metadata"
+	if err := c.do(ctx, http.MethodPost, path, nil, objectMetadataSearch, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (c *Client) GetObjectTags(ctx context.Context, bucketId string, objectId string) (*models.ObjectTags, error) {
+	var objectTags models.ObjectTags
+	path := "/api/v1/objects/" + url.PathEscape(bucketId) + "/" + url.PathEscape(objectId) + "/tags"
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &objectTags); err != nil {
+		return nil, err
+	}
+	return &objectTags, nil
+}
+
+// PutObjectTags replaces every tag of an object
+func (c *Client) PutObjectTags(ctx context.Context, objectTagsPut *models.ObjectTagsPut) (*models.ObjectTags, error) {
+	var objectTags models.ObjectTags
+	path := "/api/v1/objects/" + url.PathEscape(objectTagsPut.BucketId) + "/" + url.PathEscape(objectTagsPut.ObjectId) + "/tags"
+	if err := c.do(ctx, http.MethodPut, path, nil, objectTagsPut, &objectTags); err != nil {
+		return nil, err
+	}
+	return &objectTags, nil
+}
+
+func (c *Client) DeleteObjectTags(ctx context.Context, bucketId string, objectId string) error {
+	path := "/api/v1/objects/" + url.PathEscape(bucketId) + "/" + url.PathEscape(objectId) + "/tags"
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// PutObjectLegalHold places or releases the legal hold of an object
+func (c *Client) PutObjectLegalHold(ctx context.Context, objectLegalHoldPut *models.ObjectLegalHoldPut) (*models.Object, error) {
+	var object models.Object
+	path := "/api/v1/objects/" + url.PathEscape(objectLegalHoldPut.BucketId) + "/" + url.PathEscape(objectLegalHoldPut.ObjectId) + "/legal-hold"
+	if err := c.do(ctx, http.MethodPut, path, nil, objectLegalHoldPut, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// PutObjectRetention sets the retention of an object, shortening governance retention needs an admin key
+func (c *Client) PutObjectRetention(ctx context.Context, objectRetentionPut *models.ObjectRetentionPut) (*models.Object, error) {
+	var object models.Object
+	path := "/api/v1/objects/" + url.PathEscape(objectRetentionPut.BucketId) + "/" + url.PathEscape(objectRetentionPut.ObjectId) + "/retention"
+	if err := c.do(ctx, http.MethodPut, path, nil, objectRetentionPut, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// RestoreObject starts the restore of an archived object to the hot tier, poll GetObject until its tier is hot
+func (c *Client) RestoreObject(ctx context.Context, bucketId string, objectId string) (*models.Object, error) {
+	var object models.Object
+	path := "/api/v1/objects/" + url.PathEscape(bucketId) + "/" + url.PathEscape(objectId) + "/restore"
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &object); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// CreateObjectBatch deletes, copies or tags many objects in a single background job, poll GetObjectBatch for results
+func (c *Client) CreateObjectBatch(ctx context.Context, objectBatchCreate *models.ObjectBatchCreate) (*models.ObjectBatch, error) {
+	var objectBatch models.ObjectBatch
+	path := "/api/v1/objects/" + url.PathEscape(objectBatchCreate.BucketId) + "/batches"
+	if err := c.do(ctx, http.MethodPost, path, nil, objectBatchCreate, &objectBatch); err != nil {
+		return nil, err
+	}
+	return &objectBatch, nil
+}
+
+// GetObjectBatch returns the state of a batch and the results of up to limit of its items, an empty status returns
+// items of any status
+func (c *Client) GetObjectBatch(ctx context.Context, bucketId string, batchId string, status string, limit int32, offset int32) (*models.ObjectBatch, error) {
+	query := url.Values{
+		"limit":  {strconv.FormatInt(int64(limit), 10)},
+		"offset": {strconv.FormatInt(int64(offset), 10)},
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+
+	var objectBatch models.ObjectBatch
+	path := "/api/v1/objects/" + url.PathEscape(bucketId) + "/batches/" + url.PathEscape(batchId)
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &objectBatch); err != nil {
+		return nil, err
+	}
+	return &objectBatch, nil
+}
+
+// ObjectIterator pages through search results. The api answers an empty page with not found, which ends iteration
+//
+//	iterator := c.SearchObjectsIterator(bucketId, "avatars/", 100)
+//	for iterator.Next(ctx) {
+//		object := iterator.Object()
+//	}
+//	if err := iterator.Err(); err != nil {
+//	}
+type ObjectIterator struct {
+	client     *Client
+	bucketId   string
+	objectPath string
+	pageSize   int32
+	offset     int32
+	page       []*models.Object
+	index      int
+	done       bool
+	err        error
+}
+
+func (c *Client) SearchObjectsIterator(bucketId string, objectPath string, pageSize int32) *ObjectIterator {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	return &ObjectIterator{
+		client:     c,
+		bucketId:   bucketId,
+		objectPath: objectPath,
+		pageSize:   pageSize,
+		index:      -1,
+	}
+}
+
+// Next advances to the next object, fetching the next page when the current one is exhausted
+func (it *ObjectIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+
+	if it.done {
+		return false
+	}
+
+	page, err := it.client.SearchObjects(ctx, it.bucketId, it.objectPath, it.pageSize, it.offset)
+	if err != nil {
+		if errors.Is(err, srverr.NotFoundError) {
+			it.done = true
+			return false
+		}
+		it.err = err
+		return false
+	}
+
+	it.page = page
+	it.index = 0
+	it.offset += int32(len(page))
+	it.done = int32(len(page)) < it.pageSize
+
+	return len(page) > 0
+}
+
+func (it *ObjectIterator) Object() *models.Object {
+	if it.index < 0 || it.index >= len(it.page) {
+		return nil
+	}
+	return it.page[it.index]
+}
+
+func (it *ObjectIterator) Err() error {
+	return it.err
+}
+
+type ObjectUpload struct {
+	Name     string
+	MimeType *string
+	Metadata map[string]any
+	// Size must be the exact number of bytes Body yields, the pre-signed url is signed for it
+	Size int64
+	// Body is retried on transient storage errors when it is also an io.Seeker
+	Body io.Reader
+}
+
+// UploadObject runs the full pre-signed upload flow: it creates a session, puts the bytes to storage, completes the
+// session and returns the uploaded object
+func (c *Client) UploadObject(ctx context.Context, bucketId string, objectUpload *ObjectUpload) (*models.Object, error) {
+	preSignedUploadSession, err := c.CreatePreSignedUploadSession(ctx, &models.PreSignedUploadSessionCreate{
+		BucketId: bucketId,
+		Name:     objectUpload.Name,
+		MimeType: objectUpload.MimeType,
+		Size:     objectUpload.Size,
+		Metadata: objectUpload.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := c.GetObject(ctx, bucketId, preSignedUploadSession.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.putObject(ctx, preSignedUploadSession, object.MimeType, objectUpload); err != nil {
+		return nil, err
+	}
+
+	if err = c.CompletePreSignedUploadSession(ctx, bucketId, preSignedUploadSession.Id); err != nil {
+		return nil, err
+	}
+
+	return c.GetObject(ctx, bucketId, preSignedUploadSession.Id)
+}
+
+func (c *Client) putObject(ctx context.Context, preSignedUploadSession *models.PreSignedUploadSession, mimeType string, objectUpload *ObjectUpload) error {
+	seeker, seekable := objectUpload.Body.(io.Seeker)
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, preSignedUploadSession.Method, preSignedUploadSession.Url, io.NopCloser(objectUpload.Body))
+		if err != nil {
+			return fmt.Errorf("error creating upload request: %w", err)
+		}
+		request.ContentLength = objectUpload.Size
+		request.Header.Set("Content-Type", mimeType)
+
+		response, err := c.httpClient.Do(request)
+		if err == nil && response.StatusCode >= 200 && response.StatusCode <= 299 {
+			drainAndClose(response)
+			return nil
+		}
+
+		retryAfter := ""
+		if err == nil {
+			retryAfter = response.Header.Get("Retry-After")
+			body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+			drainAndClose(response)
+			err = fmt.Errorf("storage responded with status %d: %s", response.StatusCode, body)
+			if !isRetryableStatus(response.StatusCode) && response.StatusCode != http.StatusInternalServerError {
+				return fmt.Errorf("error uploading object '%s': %w", preSignedUploadSession.Id, err)
+			}
+		}
+
+		if !seekable || attempt >= c.maxRetries || ctx.Err() != nil {
+			return fmt.Errorf("error uploading object '%s': %w", preSignedUploadSession.Id, err)
+		}
+
+		if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
+			return fmt.Errorf("error rewinding upload body: %w", seekErr)
+		}
+
+		if waitErr := c.wait(ctx, attempt, retryAfter); waitErr != nil {
+			return waitErr
+		}
+	}
+}
+
+// DownloadObject creates a pre-signed download session and copies the object's bytes to writer
+func (c *Client) DownloadObject(ctx context.Context, bucketId string, objectId string, writer io.Writer) (int64, error) {
+	preSignedDownloadSession, err := c.CreatePreSignedDownloadSession(ctx, bucketId, objectId, 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, preSignedDownloadSession.Method, preSignedDownloadSession.Url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating download request: %w", err)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("error downloading object '%s': %w", objectId, err)
+	}
+	defer drainAndClose(response)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0, fmt.Errorf("error downloading object '%s': storage responded with status %d", objectId, response.StatusCode)
+	}
+
+	written, err := io.Copy(writer, response.Body)
+	if err != nil {
+		return written, fmt.Errorf("error downloading object '%s': %w", objectId, err)
+	}
+
+	return written, nil
+}
+
+// RenderObject renders an image object with the options of objectRender and copies the image to writer, it returns the
+// mime type of the image
+func (c *Client) RenderObject(ctx context.Context, objectRender *models.ObjectRender, writer io.Writer) (string, int64, error) {
+	query := url.Values{}
+	setQueryInt := func(name string, value *int) {
+		if value != nil {
+			query.Set(name, strconv.Itoa(*value))
+		}
+	}
+	setQueryInt("width", objectRender.Width)
+	setQueryInt("height", objectRender.Height)
+	setQueryInt("crop_x", objectRender.CropX)
+	setQueryInt("crop_y", objectRender.CropY)
+	setQueryInt("crop_width", objectRender.CropWidth)
+	setQueryInt("crop_height", objectRender.CropHeight)
+	setQueryInt("quality", objectRender.Quality)
+	if objectRender.Fit != nil {
+		query.Set("fit", *objectRender.Fit)
+	}
+	if objectRender.Format != nil {
+		query.Set("format", *objectRender.Format)
+	}
+
+	requestUrl := c.baseUrl + "/api/v1/objects/" + url.PathEscape(objectRender.BucketId) + "/" + url.PathEscape(objectRender.ObjectId) + "/render"
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set(apiKeyHeader, c.apiKey)
+	request.Header.Set("User-Agent", c.userAgent)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return "", 0, fmt.Errorf("error rendering object '%s': %w", objectRender.ObjectId, err)
+	}
+	defer drainAndClose(response)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", 0, newHttpError(response)
+	}
+
+	written, err := io.Copy(writer, response.Body)
+	if err != nil {
+		return "", written, fmt.Errorf("error rendering object '%s': %w", objectRender.ObjectId, err)
+	}
+
+	return response.Header.Get("Content-Type"), written, nil
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/teapartydev/storage/server/models"
+)
+
+// GetOperation returns the state of an operation such as emptying or deleting a bucket
+func (c *Client) GetOperation(ctx context.Context, id string) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodGet, "/api/v1/operations/"+url.PathEscape(id), nil, nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// CancelOperation cancels an operation that has not finished, a running one keeps its bucket locked until it stopped
+func (c *Client) CancelOperation(ctx context.Context, id string) (*models.Operation, error) {
+	var operation models.Operation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/operations/"+url.PathEscape(id)+"/cancel", nil, nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
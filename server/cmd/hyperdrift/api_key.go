@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/models"
+)
+
+func newApiKeyCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "api-key",
+		Aliases: []string{"apikey"},
+		Short:   "Issue, list and revoke api keys",
+	}
+
+	cmd.AddCommand(
+		newApiKeyCreateCommand(flags),
+		newApiKeyListCommand(flags),
+		newApiKeyRevokeCommand(flags),
+		newApiKeyS3CredentialsCommand(flags),
+	)
+
+	return cmd
+}
+
+func newApiKeyCreateCommand(flags *globalFlags) *cobra.Command {
+	var admin bool
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Issue a new api key, the key is only shown once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				apiKey, err := env.apiKeyService.CreateApiKey(ctx, &models.ApiKeyCreate{Name: args[0], Admin: admin})
+				if err != nil {
+					return err
+				}
+
+				if flags.output == outputTable {
+					fmt.Fprintln(os.Stderr, "store this key now, it cannot be shown again")
+				}
+
+				t := apiKeyTable([]*models.ApiKey{&apiKey.ApiKey})
+				t.headers = append(t.headers, "KEY")
+				t.rows[0] = append(t.rows[0], apiKey.Key)
+
+				if apiKey.S3Credentials != nil {
+					t.headers = append(t.headers, "S3 SECRET ACCESS KEY")
+					t.rows[0] = append(t.rows[0], apiKey.S3Credentials.SecretAccessKey)
+				}
+
+				return render(flags, apiKey, t)
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&admin, "admin", false, "allow the key to bypass governance retention")
+
+	return cmd
+}
+
+func newApiKeyListCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List api keys, including revoked ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				apiKeys, err := env.apiKeyService.ListAllApiKeys(ctx)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, apiKeys, apiKeyTable(apiKeys))
+			})
+		},
+	}
+}
+
+func newApiKeyRevokeCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "revoke <api_key_id>",
+		Short: "Revoke an api key, requests using it are rejected right away",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("revoke api key '%s'?", args[0])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				if err := env.apiKeyService.RevokeApiKey(ctx, args[0]); err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("api key '%s' revoked", args[0]), map[string]any{"api_key_id": args[0]})
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func newApiKeyS3CredentialsCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "s3-credentials <api_key_id>",
+		Short: "Show the credentials an api key signs s3 gateway requests with",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				credentials, err := env.apiKeyService.GetS3Credentials(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				t := &table{headers: []string{"ACCESS KEY ID", "SECRET ACCESS KEY"}}
+				t.add(credentials.AccessKeyId, credentials.SecretAccessKey)
+
+				return render(flags, credentials, t)
+			})
+		},
+	}
+}
+
+func apiKeyTable(apiKeys []*models.ApiKey) *table {
+	t := &table{headers: []string{"ID", "NAME", "PREFIX", "ADMIN", "LAST USED AT", "REVOKED AT", "CREATED AT"}}
+
+	for _, apiKey := range apiKeys {
+		t.add(
+			apiKey.Id,
+			apiKey.Name,
+			apiKey.KeyPrefix,
+			strconv.FormatBool(apiKey.Admin),
+			formatTime(apiKey.LastUsedAt),
+			formatTime(apiKey.RevokedAt),
+			formatTime(&apiKey.CreatedAt),
+		)
+	}
+
+	return t
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+func newBucketCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bucket",
+		Short: "Create, list, rename, relocate, empty, delete, unlock, reconcile, import, export and restore buckets",
+	}
+
+	cmd.AddCommand(
+		newBucketCreateCommand(flags),
+		newBucketListCommand(flags),
+		newBucketRenameCommand(flags),
+		newBucketRelocateCommand(flags),
+		newBucketEmptyCommand(flags),
+		newBucketDeleteCommand(flags),
+		newBucketUnlockCommand(flags),
+		newBucketReconcileCommand(flags),
+		newBucketImportCommand(flags),
+		newBucketExportCommand(flags),
+		newBucketRestoreCommand(flags),
+	)
+
+	return cmd
+}
+
+func newBucketCreateCommand(flags *globalFlags) *cobra.Command {
+	var allowedMimeTypes []string
+	var maxAllowedObjectSize int64
+	var public bool
+	var processors []string
+	var defaultRetentionMode string
+	var defaultRetentionDays int32
+	var replica string
+	var archiveAfterDays int32
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a bucket",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				bucketCreate := &models.BucketCreate{
+					Name:             args[0],
+					AllowedMimeTypes: allowedMimeTypes,
+					Public:           public,
+					Processors:       processors,
+				}
+
+				if cmd.Flags().Changed("max-allowed-object-size") {
+					bucketCreate.MaxAllowedObjectSize = &maxAllowedObjectSize
+				}
+
+				if cmd.Flags().Changed("default-retention-mode") {
+					bucketCreate.DefaultRetentionMode = &defaultRetentionMode
+				}
+
+				if cmd.Flags().Changed("default-retention-days") {
+					bucketCreate.DefaultRetentionDays = &defaultRetentionDays
+				}
+
+				if cmd.Flags().Changed("replica") {
+					bucketCreate.Replica = &replica
+				}
+
+				if cmd.Flags().Changed("archive-after-days") {
+					bucketCreate.ArchiveAfterDays = &archiveAfterDays
+				}
+
+				bucket, err := env.bucketService.CreateBucket(ctx, bucketCreate)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, bucket, bucketTable([]*models.Bucket{bucket}))
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&allowedMimeTypes, "allowed-mime-types", nil, "mime types allowed in the bucket, all types are allowed when empty")
+	cmd.Flags().Int64Var(&maxAllowedObjectSize, "max-allowed-object-size", 0, "max object size in bytes, unlimited when not set")
+	cmd.Flags().BoolVar(&public, "public", false, "make the bucket publicly readable")
+	cmd.Flags().StringSliceVar(&processors, "processors", nil, "processors to run on every object once its upload completes")
+	cmd.Flags().StringVar(&defaultRetentionMode, "default-retention-mode", "", "retention mode of uploaded objects, 'governance' or 'compliance'")
+	cmd.Flags().Int32Var(&defaultRetentionDays, "default-retention-days", 0, "days uploaded objects are retained for")
+	cmd.Flags().StringVar(&replica, "replica", "", "replica from the config completed objects are copied to")
+	cmd.Flags().Int32Var(&archiveAfterDays, "archive-after-days", 0, "days without downloads after which objects move to the cold tier, never when not set")
+
+	return cmd
+}
+
+func newBucketListCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all buckets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				buckets, err := env.bucketService.ListAllBuckets(ctx)
+				if err != nil && !errors.Is(serviceErrorCode(err), srverr.NotFoundError) {
+					return err
+				}
+
+				if buckets == nil {
+					buckets = []*models.Bucket{}
+				}
+
+				return render(flags, buckets, bucketTable(buckets))
+			})
+		},
+	}
+}
+
+func newBucketRenameCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <bucket_id> <name>",
+		Short: "Rename a bucket, buckets still stored under their name have to be relocated first",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				bucket, err := env.bucketService.RenameBucket(ctx, &models.BucketRename{Id: args[0], Name: args[1]})
+				if err != nil {
+					return err
+				}
+
+				return render(flags, bucket, bucketTable([]*models.Bucket{bucket}))
+			})
+		},
+	}
+}
+
+func newBucketRelocateCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "relocate <bucket_id>",
+		Short: "Move the content of a bucket stored under its name under its id in the background",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.bucketService.RelocateBucket(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is locked and queued for relocation by operation '%s'", args[0], operation.Id), map[string]any{"bucket_id": args[0], "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+}
+
+func newBucketEmptyCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+	var bypassGovernance bool
+
+	cmd := &cobra.Command{
+		Use:   "empty <bucket_id>",
+		Short: "Delete every object in a bucket in the background",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("empty bucket '%s'? every object in it will be deleted", args[0])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.bucketService.EmptyBucket(ctx, args[0], bypassGovernance)
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is locked and queued for emptying by operation '%s'", args[0], operation.Id), map[string]any{"bucket_id": args[0], "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	cmd.Flags().BoolVar(&bypassGovernance, "bypass-governance-retention", false, "delete objects even under governance retention, the bypass is audited")
+
+	return cmd
+}
+
+func newBucketDeleteCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+	var bypassGovernance bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <bucket_id>",
+		Short: "Delete a bucket and every object in it in the background",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("delete bucket '%s' and every object in it?", args[0])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.bucketService.DeleteBucket(ctx, args[0], bypassGovernance)
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is locked and queued for deletion by operation '%s'", args[0], operation.Id), map[string]any{"bucket_id": args[0], "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	cmd.Flags().BoolVar(&bypassGovernance, "bypass-governance-retention", false, "delete objects even under governance retention, the bypass is audited")
+
+	return cmd
+}
+
+func newBucketUnlockCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "unlock <bucket_id>",
+		Short: "Force unlock a bucket and cancel the job holding its lock",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("force unlock bucket '%s'? the emptying or deletion holding the lock is cancelled", args[0])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				bucket, err := env.bucketService.UnlockBucket(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				return render(flags, bucket, bucketTable([]*models.Bucket{bucket}))
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func newBucketReconcileCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reconcile <bucket_id>",
+		Short: "Report content in storage without an object and objects without content, nothing is fixed",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				report, err := env.reconciliationService.ReconcileBucket(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				return render(flags, report, reconciliationTable(report))
+			})
+		},
+	}
+}
+
+func newBucketImportCommand(flags *globalFlags) *cobra.Command {
+	var bucketImport models.BucketImport
+
+	cmd := &cobra.Command{
+		Use:   "import <bucket_id>",
+		Short: "Import content already in storage into the catalog of a bucket in the background",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.bucketService.ImportBucket(ctx, args[0], &bucketImport)
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is queued for import by operation '%s'", args[0], operation.Id), map[string]any{"bucket_id": args[0], "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&bucketImport.SourcePrefix, "source-prefix", "", "storage prefix to import content from, defaults to the bucket name")
+	cmd.Flags().StringVar(&bucketImport.Prefix, "prefix", "", "only import object names starting with the prefix")
+	cmd.Flags().BoolVar(&bucketImport.Overwrite, "overwrite", false, "overwrite objects of the same name instead of skipping them")
+
+	return cmd
+}
+
+func newBucketExportCommand(flags *globalFlags) *cobra.Command {
+	var bucketArchive models.BucketArchive
+
+	cmd := &cobra.Command{
+		Use:   "export <bucket_id> <path>",
+		Short: "Export a bucket and the content of its objects into a tar or zip archive in the background",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bucketArchive.Path = args[1]
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.archiveService.ExportBucket(ctx, args[0], &bucketArchive)
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is queued for export to '%s' by operation '%s'", args[0], bucketArchive.Path, operation.Id), map[string]any{"bucket_id": args[0], "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&bucketArchive.Location, "location", models.BucketArchiveLocationStorage, "where the archive is kept, 'storage' or 'local', its path ends in .tar or .zip")
+
+	return cmd
+}
+
+func newBucketRestoreCommand(flags *globalFlags) *cobra.Command {
+	var bucketRestore models.BucketRestore
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "restore <path>",
+		Short: "Recreate the bucket of an archive and restore its objects in the background",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bucketRestore.Archive.Path = args[0]
+			if name != "" {
+				bucketRestore.Name = &name
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.archiveService.RestoreBucket(ctx, &bucketRestore)
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("bucket '%s' is restored from '%s' by operation '%s'", operation.BucketId, bucketRestore.Archive.Path, operation.Id), map[string]any{"bucket_id": operation.BucketId, "operation_id": operation.Id, "job_id": operation.JobId})
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&bucketRestore.Archive.Location, "location", models.BucketArchiveLocationStorage, "where the archive is kept, 'storage' or 'local', its path ends in .tar or .zip")
+	cmd.Flags().StringVar(&name, "name", "", "restore the bucket under a new name instead of its archived one")
+
+	return cmd
+}
+
+func reconciliationTable(report *models.ReconciliationReport) *table {
+	t := &table{headers: []string{"KIND", "NAME", "OBJECT ID", "SIZE", "AT"}}
+
+	for _, orphan := range report.Orphans {
+		t.add("orphan", orphan.Name, "-", formatSize(orphan.Size), formatTime(orphan.LastModified))
+	}
+
+	for _, missing := range report.Missing {
+		kind := "missing"
+		if missing.Kept {
+			kind = "missing (kept)"
+		}
+		t.add(kind, missing.Name, missing.ObjectId, "-", formatTime(&missing.CreatedAt))
+	}
+
+	return t
+}
+
+func bucketTable(buckets []*models.Bucket) *table {
+	t := &table{headers: []string{"ID", "NAME", "PUBLIC", "DISABLED", "LOCKED", "ALLOWED MIME TYPES", "MAX OBJECT SIZE", "PROCESSORS", "DEFAULT RETENTION", "REPLICA", "ARCHIVE AFTER", "CREATED AT"}}
+
+	for _, bucket := range buckets {
+		maxAllowedObjectSize := "-"
+		if bucket.MaxAllowedObjectSize != nil {
+			maxAllowedObjectSize = formatSize(*bucket.MaxAllowedObjectSize)
+		}
+
+		defaultRetention := "-"
+		if bucket.DefaultRetentionMode != nil && bucket.DefaultRetentionDays != nil {
+			defaultRetention = fmt.Sprintf("%s %dd", *bucket.DefaultRetentionMode, *bucket.DefaultRetentionDays)
+		}
+
+		archiveAfter := "-"
+		if bucket.ArchiveAfterDays != nil {
+			archiveAfter = fmt.Sprintf("%dd", *bucket.ArchiveAfterDays)
+		}
+
+		locked := strconv.FormatBool(bucket.Locked)
+		if bucket.Locked {
+			locked = formatString(bucket.LockReason)
+		}
+
+		t.add(
+			bucket.Id,
+			bucket.Name,
+			strconv.FormatBool(bucket.Public),
+			strconv.FormatBool(bucket.Disabled),
+			locked,
+			strings.Join(bucket.AllowedMimeTypes, ","),
+			maxAllowedObjectSize,
+			strings.Join(bucket.Processors, ","),
+			defaultRetention,
+			formatString(bucket.Replica),
+			archiveAfter,
+			formatTime(&bucket.CreatedAt),
+		)
+	}
+
+	return t
+}
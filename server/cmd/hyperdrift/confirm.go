@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var errAborted = errors.New("aborted")
+
+// confirm asks before destructive commands unless --yes was passed, which scripts are expected to do
+func confirm(cmd *cobra.Command, yes bool, question string) error {
+	if yes {
+		return nil
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil {
+		return errAborted
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return errAborted
+	}
+
+	return nil
+}
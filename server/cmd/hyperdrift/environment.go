@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/teapartydev/storage/server/app"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/services"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// environment holds everything a command needs. The job client only inserts jobs, the running workers pick them up
+type environment struct {
+	config  *config.Config
+	logger  *zap.Logger
+	db      *pgxpool.Pool
+	storage *storage.Storage
+	job     *river.Client[pgx.Tx]
+
+	bucketService    *services.BucketService
+	objectService    *services.ObjectService
+	jobService       *services.JobService
+	operationService *services.OperationService
+	apiKeyService    *services.ApiKeyService
+
+	reconciliationService *services.ReconciliationService
+	archiveService        *services.ArchiveService
+}
+
+func newEnvironment(ctx context.Context, flags *globalFlags) (*environment, error) {
+	newConfig, err := config.LoadConfig(flags.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// logs go to stderr and only from warnings up so they never mix with table or json output on stdout
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stderr),
+		zapcore.WarnLevel,
+	))
+
+	db, err := app.NewDatabasePool(ctx, newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s3Client, err := app.NewS3Client(ctx, newConfig)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	newMetrics := metrics.NewMetrics(logger)
+	newStorage := storage.NewStorage(s3Client, newConfig, newMetrics, logger)
+
+	replicas, err := app.NewReplicas(ctx, newConfig, newMetrics, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	workers, err := app.NewWorkers(db, newStorage, replicas, app.NewScanner(newConfig), newConfig, newMetrics, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	job, err := river.NewClient[pgx.Tx](riverpgxv5.New(db), &river.Config{Workers: workers})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating river client: %w", err)
+	}
+
+	return &environment{
+		config:           newConfig,
+		logger:           logger,
+		db:               db,
+		storage:          newStorage,
+		job:              job,
+		bucketService:    services.NewBucketService(db, job, newConfig, logger),
+		objectService:    services.NewObjectService(db, newStorage, replicas, job, newConfig, logger),
+		jobService:       services.NewJobService(db, job, logger),
+		operationService: services.NewOperationService(db, job, logger),
+		apiKeyService:    services.NewApiKeyService(db, newConfig, logger),
+
+		reconciliationService: services.NewReconciliationService(db, newStorage, newConfig, logger),
+		archiveService:        services.NewArchiveService(db, newStorage, job, newConfig, logger),
+	}, nil
+}
+
+func (e *environment) close() {
+	e.db.Close()
+	_ = e.logger.Sync()
+}
+
+// withEnvironment builds the environment for a single command run and tags its work with a request id so it can be
+// found in the server side logs of any jobs it enqueues
+func withEnvironment(flags *globalFlags, run func(ctx context.Context, env *environment) error) error {
+	ctx := utils.WithRequestId(context.Background(), "cli_"+ulid.Make().String())
+	ctx = utils.WithPrincipal(ctx, utils.Principal{Id: utils.PrincipalCli, Admin: true})
+
+	env, err := newEnvironment(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	return run(ctx, env)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/models"
+)
+
+func newJobCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Inspect, retry and cancel background jobs",
+	}
+
+	cmd.AddCommand(
+		newJobListCommand(flags),
+		newJobGetCommand(flags),
+		newJobRetryCommand(flags),
+		newJobCancelCommand(flags),
+	)
+
+	return cmd
+}
+
+func newJobListCommand(flags *globalFlags) *cobra.Command {
+	var state string
+	var queue string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List jobs in a state, most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				jobs, err := env.jobService.ListJobs(ctx, state, queue, limit)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, jobs, jobTable(jobs))
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&state, "state", "discarded", "job state: available, cancelled, completed, discarded, retryable, running or scheduled")
+	cmd.Flags().StringVar(&queue, "queue", "", "only list jobs in this queue")
+	cmd.Flags().IntVar(&limit, "limit", 50, "max number of jobs to list")
+
+	return cmd
+}
+
+func newJobGetCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <job_id>",
+		Short: "Show a job along with the errors of its attempts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobId(args[0])
+			if err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				job, err := env.jobService.GetJob(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, job, jobDetailTable(job))
+			})
+		},
+	}
+}
+
+func newJobRetryCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <job_id>",
+		Short: "Make a failed, cancelled or scheduled job available to run again now",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobId(args[0])
+			if err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				job, err := env.jobService.RetryJob(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, job, jobTable([]*models.Job{job}))
+			})
+		},
+	}
+}
+
+func newJobCancelCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "cancel <job_id>",
+		Short: "Cancel a job that has not finished",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobId(args[0])
+			if err != nil {
+				return err
+			}
+
+			if err = confirm(cmd, yes, fmt.Sprintf("cancel job %d?", id)); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				job, err := env.jobService.CancelJob(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, job, jobTable([]*models.Job{job}))
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func parseJobId(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid job id %q, job ids are numeric", value)
+	}
+	return id, nil
+}
+
+func jobTable(jobs []*models.Job) *table {
+	t := &table{headers: []string{"ID", "KIND", "QUEUE", "STATE", "ATTEMPT", "CREATED AT", "FINALIZED AT", "LAST ERROR"}}
+
+	for _, job := range jobs {
+		lastError := "-"
+		if len(job.Errors) > 0 {
+			lastError = job.Errors[len(job.Errors)-1].Error
+		}
+
+		t.add(
+			strconv.FormatInt(job.Id, 10),
+			job.Kind,
+			job.Queue,
+			job.State,
+			fmt.Sprintf("%d/%d", job.Attempt, job.MaxAttempts),
+			formatTime(&job.CreatedAt),
+			formatTime(job.FinalizedAt),
+			lastError,
+		)
+	}
+
+	return t
+}
+
+func jobDetailTable(job *models.Job) *table {
+	t := &table{headers: []string{"FIELD", "VALUE"}}
+
+	t.add("id", strconv.FormatInt(job.Id, 10))
+	t.add("kind", job.Kind)
+	t.add("queue", job.Queue)
+	t.add("state", job.State)
+	t.add("attempt", fmt.Sprintf("%d/%d", job.Attempt, job.MaxAttempts))
+	t.add("args", string(job.Args))
+	t.add("created at", formatTime(&job.CreatedAt))
+	t.add("scheduled at", formatTime(&job.ScheduledAt))
+	t.add("attempted at", formatTime(job.AttemptedAt))
+	t.add("finalized at", formatTime(job.FinalizedAt))
+
+	if job.Progress != nil {
+		t.add("progress", fmt.Sprintf("%d/%d processed, %d skipped, updated %s", job.Progress.Processed, job.Progress.Total, job.Progress.Skipped, formatTime(&job.Progress.UpdatedAt)))
+	}
+
+	for _, jobError := range job.Errors {
+		t.add(fmt.Sprintf("error attempt %d", jobError.Attempt), fmt.Sprintf("%s %s", formatTime(&jobError.At), jobError.Error))
+	}
+
+	return t
+}
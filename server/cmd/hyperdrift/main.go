@@ -0,0 +1,70 @@
+// Command hyperdrift is the admin cli for operating the storage service. It talks to postgres and s3 directly
+// through the same services the http api uses, so it is meant to be run from a host with access to both
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+type globalFlags struct {
+	configPath string
+	output     string
+}
+
+func main() {
+	flags := &globalFlags{}
+
+	rootCmd := &cobra.Command{
+		Use:           "hyperdrift",
+		Short:         "Operate the hyperdrift storage service",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.output != outputTable && flags.output != outputJson {
+				return fmt.Errorf("invalid output %q, expected 'table' or 'json'", flags.output)
+			}
+			return nil
+		},
+	}
+
+	rootCmd.PersistentFlags().StringVar(&flags.configPath, "config", "", "path to config.json, defaults to config.json in the working directory or its parent")
+	rootCmd.PersistentFlags().StringVarP(&flags.output, "output", "o", outputTable, "output format, 'table' or 'json'")
+
+	rootCmd.AddCommand(
+		newBucketCommand(flags),
+		newObjectCommand(flags),
+		newMigrateCommand(flags),
+		newJobCommand(flags),
+		newOperationCommand(flags),
+		newApiKeyCommand(flags),
+	)
+
+	if err := rootCmd.Execute(); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+}
+
+func printError(err error) {
+	var serviceError srverr.ServiceError
+	if errors.As(err, &serviceError) {
+		fmt.Fprintf(os.Stderr, "error: %s (%s)\n", serviceError.Message, serviceError.ErrorCode)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+}
+
+// serviceErrorCode returns the error code of a service error so list commands can treat not found as an empty result
+func serviceErrorCode(err error) error {
+	var serviceError srverr.ServiceError
+	if errors.As(err, &serviceError) {
+		return serviceError.ErrorCode
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/app"
+	"github.com/teapartydev/storage/server/database"
+)
+
+func newMigrateCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run database migrations",
+	}
+
+	cmd.AddCommand(
+		newMigrateUpCommand(flags),
+		newMigrateDownCommand(flags),
+		newMigrateStatusCommand(flags),
+	)
+
+	return cmd
+}
+
+func newMigrateUpCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "up",
+		Short: "Apply every pending storage and job schema migration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				if err := app.Migrate(ctx, env.config, env.logger); err != nil {
+					return err
+				}
+
+				return renderMigrationStatus(ctx, flags, env)
+			})
+		},
+	}
+}
+
+func newMigrateDownCommand(flags *globalFlags) *cobra.Command {
+	var to int64
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the latest storage migration, or every migration after --to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			question := "roll back the latest storage migration?"
+			if cmd.Flags().Changed("to") {
+				question = fmt.Sprintf("roll back every storage migration after version %d?", to)
+			}
+
+			if err := confirm(cmd, yes, question); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				var err error
+				if cmd.Flags().Changed("to") {
+					err = database.MigrateDownTo(ctx, env.db, to)
+				} else {
+					err = database.MigrateDown(ctx, env.db)
+				}
+				if err != nil {
+					return fmt.Errorf("error rolling back migrations: %w", err)
+				}
+
+				return renderMigrationStatus(ctx, flags, env)
+			})
+		},
+	}
+
+	cmd.Flags().Int64Var(&to, "to", 0, "version to roll back to, 0 rolls back every migration")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func newMigrateStatusCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the applied and latest storage migration versions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				return renderMigrationStatus(ctx, flags, env)
+			})
+		},
+	}
+}
+
+func renderMigrationStatus(ctx context.Context, flags *globalFlags, env *environment) error {
+	migrationStatus, err := database.GetMigrationStatus(ctx, env.db)
+	if err != nil {
+		return fmt.Errorf("error getting migration status: %w", err)
+	}
+
+	t := &table{headers: []string{"CURRENT VERSION", "LATEST VERSION", "UP TO DATE"}}
+	t.add(
+		fmt.Sprint(migrationStatus.CurrentVersion),
+		fmt.Sprint(migrationStatus.LatestVersion),
+		fmt.Sprint(migrationStatus.IsUpToDate()),
+	)
+
+	return render(flags, map[string]any{
+		"current_version": migrationStatus.CurrentVersion,
+		"latest_version":  migrationStatus.LatestVersion,
+		"up_to_date":      migrationStatus.IsUpToDate(),
+	}, t)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+func newObjectCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "object",
+		Short: "List, upload, download and delete objects",
+	}
+
+	cmd.AddCommand(
+		newObjectListCommand(flags),
+		newObjectUploadCommand(flags),
+		newObjectDownloadCommand(flags),
+		newObjectRestoreCommand(flags),
+		newObjectDeleteCommand(flags),
+	)
+
+	return cmd
+}
+
+func newObjectListCommand(flags *globalFlags) *cobra.Command {
+	var path string
+	var tagFilters []string
+	var limit int32
+	var offset int32
+
+	cmd := &cobra.Command{
+		Use:   "list <bucket_id>",
+		Short: "List objects in a bucket, optionally filtered by a path fragment and tags",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tags, err := models.ParseTagFilters(tagFilters)
+			if err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				var objects []*models.Object
+				var err error
+
+				if path != "" {
+					objects, err = env.objectService.SearchObjects(ctx, args[0], path, tags, limit, offset)
+				} else {
+					objects, err = env.objectService.ListObjects(ctx, args[0], tags, limit, offset)
+				}
+				if err != nil && !errors.Is(serviceErrorCode(err), srverr.NotFoundError) {
+					return err
+				}
+
+				if objects == nil {
+					objects = []*models.Object{}
+				}
+
+				return render(flags, objects, objectTable(objects))
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "path", "", "only list objects whose name contains this path")
+	cmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "only list objects tagged key=value, repeat to require several tags")
+	cmd.Flags().Int32Var(&limit, "limit", 100, "max number of objects to list")
+	cmd.Flags().Int32Var(&offset, "offset", 0, "number of objects to skip")
+
+	return cmd
+}
+
+func newObjectUploadCommand(flags *globalFlags) *cobra.Command {
+	var name string
+	var mimeType string
+
+	cmd := &cobra.Command{
+		Use:   "upload <bucket_id> <file>",
+		Short: "Upload a local file as an object",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			fileInfo, err := file.Stat()
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = filepath.Base(args[1])
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				preSignedUploadSessionCreate := &models.PreSignedUploadSessionCreate{
+					BucketId: args[0],
+					Name:     name,
+					Size:     fileInfo.Size(),
+				}
+
+				if mimeType != "" {
+					preSignedUploadSessionCreate.MimeType = &mimeType
+				}
+
+				preSignedUploadSession, err := env.objectService.CreatePreSignedUploadSession(ctx, preSignedUploadSessionCreate)
+				if err != nil {
+					return err
+				}
+
+				request, err := http.NewRequestWithContext(ctx, preSignedUploadSession.Method, preSignedUploadSession.Url, file)
+				if err != nil {
+					return err
+				}
+				request.ContentLength = fileInfo.Size()
+				request.Header.Set("Content-Type", *preSignedUploadSessionCreate.MimeType)
+
+				if err = doStorageRequest(request, nil); err != nil {
+					return fmt.Errorf("error uploading object '%s': %w", preSignedUploadSession.Id, err)
+				}
+
+				if err = env.objectService.CompletePreSignedUploadSession(ctx, args[0], preSignedUploadSession.Id); err != nil {
+					return err
+				}
+
+				object, err := env.objectService.GetObject(ctx, args[0], preSignedUploadSession.Id)
+				if err != nil {
+					return err
+				}
+
+				return render(flags, object, objectTable([]*models.Object{object}))
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "object name, defaults to the file name")
+	cmd.Flags().StringVar(&mimeType, "mime-type", "", "object mime type, inferred from the name when not set")
+
+	return cmd
+}
+
+func newObjectDownloadCommand(flags *globalFlags) *cobra.Command {
+	var output string
+	var restore bool
+
+	cmd := &cobra.Command{
+		Use:   "download <bucket_id> <object_id>",
+		Short: "Download an object to a local file or stdout",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				preSignedDownloadSession, err := env.objectService.CreatePreSignedDownloadSession(ctx, args[0], args[1], env.config.DefaultPreSignedDownloadUrlExpiry, restore)
+				if err != nil {
+					return err
+				}
+
+				request, err := http.NewRequestWithContext(ctx, preSignedDownloadSession.Method, preSignedDownloadSession.Url, nil)
+				if err != nil {
+					return err
+				}
+
+				var writer io.Writer = os.Stdout
+				if output != "-" {
+					if output == "" {
+						object, err := env.objectService.GetObject(ctx, args[0], args[1])
+						if err != nil {
+							return err
+						}
+						output = filepath.Base(object.Name)
+					}
+
+					file, err := os.Create(output)
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+
+					writer = file
+				}
+
+				if err = doStorageRequest(request, writer); err != nil {
+					return fmt.Errorf("error downloading object '%s': %w", args[1], err)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output-file", "", "file to write to, defaults to the object's base name, '-' writes to stdout")
+	cmd.Flags().BoolVar(&restore, "restore", false, "start the restore of an archived object to the hot tier, download it again once it is restored")
+
+	return cmd
+}
+
+func newObjectRestoreCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <bucket_id> <object_id>",
+		Short: "Restore an archived object to the hot tier in the background",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				object, err := env.objectService.RestoreObject(ctx, args[0], args[1])
+				if err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("object '%s' is restoring to the hot tier", object.Id), map[string]any{"object_id": object.Id, "tier": object.Tier})
+			})
+		},
+	}
+
+	return cmd
+}
+
+func newObjectDeleteCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+	var bypassGovernance bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <bucket_id> <object_id>",
+		Short: "Delete an object in the background",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("delete object '%s'?", args[1])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				if err := env.objectService.DeleteObject(ctx, args[0], args[1], bypassGovernance); err != nil {
+					return err
+				}
+
+				return renderMessage(flags, fmt.Sprintf("object '%s' is queued for deletion", args[1]), map[string]any{"object_id": args[1]})
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	cmd.Flags().BoolVar(&bypassGovernance, "bypass-governance-retention", false, "delete the object even under governance retention, the bypass is audited")
+
+	return cmd
+}
+
+// doStorageRequest performs a request against a pre-signed url and copies the response body to writer when set
+func doStorageRequest(request *http.Request, writer io.Writer) error {
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("storage responded with status %d: %s", response.StatusCode, body)
+	}
+
+	if writer != nil {
+		_, err = io.Copy(writer, response.Body)
+	}
+
+	return err
+}
+
+func objectTable(objects []*models.Object) *table {
+	t := &table{headers: []string{"ID", "NAME", "MIME TYPE", "SIZE", "UPLOAD STATUS", "TIER", "CREATED AT"}}
+
+	for _, object := range objects {
+		t.add(
+			object.Id,
+			object.Name,
+			object.MimeType,
+			formatSize(object.Size),
+			object.UploadStatus,
+			object.Tier,
+			formatTime(&object.CreatedAt),
+		)
+	}
+
+	return t
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/teapartydev/storage/server/models"
+)
+
+func newOperationCommand(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "operation",
+		Short: "Inspect and cancel bucket operations such as emptying or deleting a bucket",
+	}
+
+	cmd.AddCommand(
+		newOperationGetCommand(flags),
+		newOperationCancelCommand(flags),
+	)
+
+	return cmd
+}
+
+func newOperationGetCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <operation_id>",
+		Short: "Show an operation along with its progress and errors",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.operationService.GetOperation(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				return render(flags, operation, operationDetailTable(operation))
+			})
+		},
+	}
+}
+
+func newOperationCancelCommand(flags *globalFlags) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "cancel <operation_id>",
+		Short: "Cancel an operation that has not finished and unlock its bucket once it stopped",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirm(cmd, yes, fmt.Sprintf("cancel operation '%s'?", args[0])); err != nil {
+				return err
+			}
+
+			return withEnvironment(flags, func(ctx context.Context, env *environment) error {
+				operation, err := env.operationService.CancelOperation(ctx, args[0])
+				if err != nil {
+					return err
+				}
+
+				return render(flags, operation, operationDetailTable(operation))
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func operationDetailTable(operation *models.Operation) *table {
+	t := &table{headers: []string{"FIELD", "VALUE"}}
+
+	t.add("id", operation.Id)
+	t.add("type", operation.Type)
+	t.add("bucket id", operation.BucketId)
+	t.add("job id", strconv.FormatInt(operation.JobId, 10))
+	t.add("state", operation.State)
+	t.add("created at", formatTime(&operation.CreatedAt))
+	t.add("started at", formatTime(operation.StartedAt))
+	t.add("cancel requested at", formatTime(operation.CancelRequestedAt))
+	t.add("finished at", formatTime(operation.FinishedAt))
+
+	if operation.Progress != nil {
+		t.add("progress", fmt.Sprintf("%d/%d processed, %d skipped, updated %s", operation.Progress.Processed, operation.Progress.Total, operation.Progress.Skipped, formatTime(&operation.Progress.UpdatedAt)))
+	}
+
+	for _, operationError := range operation.Errors {
+		t.add(fmt.Sprintf("error attempt %d", operationError.Attempt), fmt.Sprintf("%s %s", formatTime(&operationError.At), operationError.Error))
+	}
+
+	return t
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+// table is the tabular rendering of a command result, json output ignores it and encodes the result itself
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func (t *table) add(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+func render(flags *globalFlags, result any, t *table) error {
+	if flags.output == outputJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// renderMessage prints a confirmation for commands that have no result of their own
+func renderMessage(flags *globalFlags, message string, fields map[string]any) error {
+	if flags.output == outputJson {
+		result := map[string]any{"message": message}
+		for key, value := range fields {
+			result[key] = value
+		}
+		return render(flags, result, nil)
+	}
+
+	_, err := fmt.Fprintln(os.Stdout, message)
+	return err
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatString(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return *s
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
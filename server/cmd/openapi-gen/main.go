@@ -0,0 +1,28 @@
+// Command openapi-gen writes the openapi document generated from the controller annotations, it is run through
+// `go generate ./openapi` from the server module
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/teapartydev/storage/server/openapi/generator"
+)
+
+func main() {
+	root := flag.String("root", ".", "path to the server module")
+	out := flag.String("out", "openapi/openapi.json", "path the document is written to")
+	flag.Parse()
+
+	document, err := generator.Render(*root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error generating openapi document: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err = os.WriteFile(*out, document, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing openapi document: %s\n", err)
+		os.Exit(1)
+	}
+}
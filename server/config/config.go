@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"github.com/teapartydev/storage/server/zapfield"
@@ -25,6 +26,63 @@ type Config struct {
 	S3Region          string `json:"s3_region" mapstructure:"s3_region"`
 	S3ForcePathStyle  bool   `json:"s3_force_path_style" mapstructure:"s3_force_path_style"`
 	S3DisableSSL      bool   `json:"s3_disable_ssl" mapstructure:"s3_disable_ssl"`
+	// S3ColdStorageClass is the storage class objects archived to the cold tier are moved to, content in classes like
+	// GLACIER has to be restored before it can be read again
+	S3ColdStorageClass string `json:"s3_cold_storage_class" mapstructure:"s3_cold_storage_class"`
+
+	// Replicas are the secondary s3 compatible endpoints buckets can replicate their completed objects to, keyed by the
+	// name buckets refer to them with
+	Replicas map[string]ReplicaConfig `json:"replicas" mapstructure:"replicas"`
+
+	S3GatewayEnabled bool   `json:"s3_gateway_enabled" mapstructure:"s3_gateway_enabled"`
+	S3GatewayPort    string `json:"s3_gateway_port" mapstructure:"s3_gateway_port"`
+	S3GatewayRegion  string `json:"s3_gateway_region" mapstructure:"s3_gateway_region"`
+	// S3GatewaySecret derives the s3 secret access key of every api key, changing it invalidates all issued s3 credentials
+	S3GatewaySecret string `json:"s3_gateway_secret" mapstructure:"s3_gateway_secret"`
+
+	// ImageRenderMaxInputDimension and ImageRenderMaxInputSize bound the sources the render endpoint decodes, a
+	// decoded image takes width * height * 4 bytes of memory whatever its size on disk
+	ImageRenderMaxInputDimension int   `json:"image_render_max_input_dimension" mapstructure:"image_render_max_input_dimension"`
+	ImageRenderMaxInputSize      int64 `json:"image_render_max_input_size" mapstructure:"image_render_max_input_size"`
+
+	// MalwareScanner holds completed uploads in scanning until they are scanned, 'fake' only flags the eicar test file
+	// and is meant for local development
+	MalwareScanner      string `json:"malware_scanner" mapstructure:"malware_scanner"`
+	MalwareClamdAddress string `json:"malware_clamd_address" mapstructure:"malware_clamd_address"`
+	MalwareClamdTimeout int64  `json:"malware_clamd_timeout" mapstructure:"malware_clamd_timeout"`
+
+	// MetricsPort serves the prometheus metrics apart from the api, they are not authenticated and must not be
+	// reachable from where the api is
+	MetricsPort string `json:"metrics_port" mapstructure:"metrics_port"`
+
+	TracingExporter     string `json:"tracing_exporter" mapstructure:"tracing_exporter"`
+	TracingOtlpEndpoint string `json:"tracing_otlp_endpoint" mapstructure:"tracing_otlp_endpoint"`
+	// TracingSampleRatio is the share of root traces sampled, 0 only follows the sampling decision of the parent.
+	// every trace is sampled when it is not set
+	TracingSampleRatio *float64 `json:"tracing_sample_ratio" mapstructure:"tracing_sample_ratio"`
+
+	JobQueueConcurrency map[string]int `json:"job_queue_concurrency" mapstructure:"job_queue_concurrency"`
+
+	// BucketLockLeaseTimeout is how many seconds a bucket lock outlives the last heartbeat of its job before the lock
+	// reconciler looks into it, BucketLockRecoveryRetries is how often a discarded job is retried before its lock is
+	// released and its operation recorded as failed
+	BucketLockLeaseTimeout    int64 `json:"bucket_lock_lease_timeout" mapstructure:"bucket_lock_lease_timeout"`
+	BucketLockRecoveryRetries int32 `json:"bucket_lock_recovery_retries" mapstructure:"bucket_lock_recovery_retries"`
+
+	// StorageReconciliationOrphans and StorageReconciliationMissing tell the daily reconciliation to 'report' or
+	// 'delete' content without an object and objects without content, anything changed within
+	// StorageReconciliationGracePeriod seconds is left alone
+	StorageReconciliationOrphans     string `json:"storage_reconciliation_orphans" mapstructure:"storage_reconciliation_orphans"`
+	StorageReconciliationMissing     string `json:"storage_reconciliation_missing" mapstructure:"storage_reconciliation_missing"`
+	StorageReconciliationGracePeriod int64  `json:"storage_reconciliation_grace_period" mapstructure:"storage_reconciliation_grace_period"`
+
+	// BucketArchiveDirectory is the directory bucket archives with the 'local' location are written to and read from,
+	// local archives are refused when it is empty
+	BucketArchiveDirectory string `json:"bucket_archive_directory" mapstructure:"bucket_archive_directory"`
+
+	ShutdownReadinessDelay int64 `json:"shutdown_readiness_delay" mapstructure:"shutdown_readiness_delay"`
+	ShutdownHttpTimeout    int64 `json:"shutdown_http_timeout" mapstructure:"shutdown_http_timeout"`
+	ShutdownJobTimeout     int64 `json:"shutdown_job_timeout" mapstructure:"shutdown_job_timeout"`
 
 	DefaultBuckets []struct {
 		Id                   string   `json:"id" mapstructure:"id"`
@@ -39,6 +97,16 @@ type Config struct {
 	DefaultPreSignedDownloadUrlExpiry int64 `json:"default_pre_signed_download_url_expiry" mapstructure:"default_pre_signed_download_url_expiry"`
 }
 
+type ReplicaConfig struct {
+	S3Endpoint        string `json:"s3_endpoint" mapstructure:"s3_endpoint"`
+	S3AccessKeyId     string `json:"s3_access_key_id" mapstructure:"s3_access_key_id"`
+	S3SecretAccessKey string `json:"s3_secret_access_key" mapstructure:"s3_secret_access_key"`
+	S3Bucket          string `json:"s3_bucket" mapstructure:"s3_bucket"`
+	S3Region          string `json:"s3_region" mapstructure:"s3_region"`
+	S3ForcePathStyle  bool   `json:"s3_force_path_style" mapstructure:"s3_force_path_style"`
+	S3DisableSSL      bool   `json:"s3_disable_ssl" mapstructure:"s3_disable_ssl"`
+}
+
 func (c *Config) SetDefaults() {
 	if c.ServiceId == "" {
 		c.ServiceId = uuid.New().String()
@@ -64,6 +132,82 @@ func (c *Config) SetDefaults() {
 		c.S3Region = "us-east-1"
 	}
 
+	if c.S3ColdStorageClass == "" {
+		c.S3ColdStorageClass = "GLACIER"
+	}
+
+	for name, replica := range c.Replicas {
+		if replica.S3Region == "" {
+			replica.S3Region = "us-east-1"
+			c.Replicas[name] = replica
+		}
+	}
+
+	if c.S3GatewayPort == "" {
+		c.S3GatewayPort = "3002"
+	}
+
+	if c.S3GatewayRegion == "" {
+		c.S3GatewayRegion = "us-east-1"
+	}
+
+	if c.MetricsPort == "" {
+		c.MetricsPort = "3003"
+	}
+
+	if c.ImageRenderMaxInputDimension == 0 {
+		c.ImageRenderMaxInputDimension = 8192
+	}
+
+	if c.ImageRenderMaxInputSize == 0 {
+		c.ImageRenderMaxInputSize = 52428800
+	}
+
+	if c.MalwareScanner == "" {
+		c.MalwareScanner = "none"
+	}
+
+	if c.MalwareClamdAddress == "" {
+		c.MalwareClamdAddress = "localhost:3310"
+	}
+
+	if c.MalwareClamdTimeout == 0 {
+		c.MalwareClamdTimeout = 120
+	}
+
+	if c.TracingExporter == "" {
+		c.TracingExporter = "none"
+	}
+
+	if c.TracingSampleRatio == nil {
+		tracingSampleRatio := 1.0
+		c.TracingSampleRatio = &tracingSampleRatio
+	}
+
+	if c.BucketLockLeaseTimeout == 0 {
+		c.BucketLockLeaseTimeout = 900
+	}
+
+	if c.StorageReconciliationOrphans == "" {
+		c.StorageReconciliationOrphans = "report"
+	}
+
+	if c.StorageReconciliationMissing == "" {
+		c.StorageReconciliationMissing = "report"
+	}
+
+	if c.StorageReconciliationGracePeriod == 0 {
+		c.StorageReconciliationGracePeriod = 86400
+	}
+
+	if c.ShutdownHttpTimeout == 0 {
+		c.ShutdownHttpTimeout = 30
+	}
+
+	if c.ShutdownJobTimeout == 0 {
+		c.ShutdownJobTimeout = 60
+	}
+
 	if c.DefaultPreSignedUploadUrlExpiry == 0 {
 		c.DefaultPreSignedUploadUrlExpiry = 120
 	}
@@ -98,39 +242,125 @@ func (c *Config) IsValid() error {
 		return errors.New("s3_bucket_name is a required")
 	}
 
+	for name, replica := range c.Replicas {
+		if name == "" {
+			return errors.New("replicas cannot have an empty name")
+		}
+
+		if replica.S3Endpoint == "" || replica.S3AccessKeyId == "" || replica.S3SecretAccessKey == "" || replica.S3Bucket == "" {
+			return fmt.Errorf("replica '%s' requires s3_endpoint, s3_access_key_id, s3_secret_access_key and s3_bucket", name)
+		}
+	}
+
+	if c.S3GatewayEnabled && len(c.S3GatewaySecret) < 32 {
+		return errors.New("s3_gateway_secret must be at least 32 characters when s3_gateway_enabled is true")
+	}
+
+	if c.ImageRenderMaxInputDimension < 0 || c.ImageRenderMaxInputSize < 0 {
+		return errors.New("image_render_max_input_dimension and image_render_max_input_size must not be negative")
+	}
+
+	if c.MalwareScanner != "none" && c.MalwareScanner != "clamd" && c.MalwareScanner != "fake" {
+		return errors.New("malware_scanner must be one of 'none', 'clamd' or 'fake'")
+	}
+
+	if c.MalwareClamdTimeout < 0 {
+		return errors.New("malware_clamd_timeout must not be negative")
+	}
+
+	if c.TracingExporter != "none" && c.TracingExporter != "stdout" && c.TracingExporter != "otlp" {
+		return errors.New("tracing_exporter must be one of 'none', 'stdout' or 'otlp'")
+	}
+
+	if c.TracingExporter == "otlp" && c.TracingOtlpEndpoint == "" {
+		return errors.New("tracing_otlp_endpoint is a required when tracing_exporter is 'otlp'")
+	}
+
+	if *c.TracingSampleRatio < 0 || *c.TracingSampleRatio > 1 {
+		return errors.New("tracing_sample_ratio must be between 0 and 1")
+	}
+
+	for queue, maxWorkers := range c.JobQueueConcurrency {
+		if maxWorkers < 1 || maxWorkers > 10000 {
+			return fmt.Errorf("job_queue_concurrency for queue '%s' must be between 1 and 10000", queue)
+		}
+	}
+
+	if c.BucketLockLeaseTimeout < 60 {
+		return errors.New("bucket_lock_lease_timeout must be at least 60 seconds")
+	}
+
+	if c.BucketLockRecoveryRetries < 0 || c.BucketLockRecoveryRetries > 10 {
+		return errors.New("bucket_lock_recovery_retries must be between 0 and 10")
+	}
+
+	if c.StorageReconciliationOrphans != "report" && c.StorageReconciliationOrphans != "delete" {
+		return errors.New("storage_reconciliation_orphans must be one of 'report' or 'delete'")
+	}
+
+	if c.StorageReconciliationMissing != "report" && c.StorageReconciliationMissing != "delete" {
+		return errors.New("storage_reconciliation_missing must be one of 'report' or 'delete'")
+	}
+
+	if c.StorageReconciliationGracePeriod < 3600 {
+		return errors.New("storage_reconciliation_grace_period must be at least 3600 seconds")
+	}
+
+	if c.ShutdownReadinessDelay < 0 || c.ShutdownHttpTimeout < 0 || c.ShutdownJobTimeout < 0 {
+		return errors.New("shutdown_readiness_delay, shutdown_http_timeout and shutdown_job_timeout must not be negative")
+	}
+
 	return nil
 }
 
 func NewConfig() *Config {
 	const op = "config.NewConfig"
 
-	var config Config
-
-	v := viper.New()
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		panic(err)
 	}
 
-	v.AddConfigPath(".")
-	v.AddConfigPath("../")
-
-	err = v.ReadInConfig()
+	config, err := LoadConfig("")
 	if err != nil {
-		logger.Fatal("error reading config", zap.Error(err), zapfield.Operation(op))
+		logger.Fatal("error loading config", zap.Error(err), zapfield.Operation(op))
 	}
 
-	err = v.Unmarshal(&config)
-	if err != nil {
-		logger.Fatal("error unmarshaling config", zap.Error(err), zapfield.Operation(op))
+	return config
+}
+
+// LoadConfig reads, defaults and validates the config at path, or config.json from the working directory or its
+// parent when path is empty
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+
+	v := viper.New()
+
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.AddConfigPath(".")
+		v.AddConfigPath("../")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
 	config.SetDefaults()
 
-	err = config.IsValid()
-	if err != nil {
-		logger.Fatal("error validating config", zap.Error(err), zapfield.Operation(op))
+	if err := config.IsValid(); err != nil {
+		return nil, fmt.Errorf("error validating config: %w", err)
 	}
 
-	return &config
+	return &config, nil
+}
+
+// MalwareScanningEnabled reports whether completed uploads are scanned before they can be downloaded
+func (c *Config) MalwareScanningEnabled() bool {
+	return c.MalwareScanner != "none"
 }
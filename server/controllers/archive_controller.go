@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/services"
+)
+
+type ArchiveController struct {
+	archiveService *services.ArchiveService
+}
+
+func NewArchiveController(archiveService *services.ArchiveService) *ArchiveController {
+	return &ArchiveController{
+		archiveService: archiveService,
+	}
+}
+
+func (ac *ArchiveController) RegisterArchiveRoutes(app *fiber.App) {
+	routes := app.Group("/api")
+
+	routesV1 := routes.Group("/v1")
+
+	routesV1.Post("/buckets/restore", ac.RestoreBucket)
+	routesV1.Post("/buckets/:bucket_id/export", ac.ExportBucket)
+}
+
+// ExportBucket is used to export a bucket into an archive
+// @Summary Export a bucket
+// @Description Export the catalog rows and content of the completed objects of a bucket into a tar or zip archive in the background, the returned operation reports the progress. Objects archived in the cold tier are skipped, restore them first to export their content. Archives are kept in storage or in the archive directory of the server. Admin api keys only
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param archive body models.BucketArchive true "Archive"
+// @Success 202 {object} models.Operation
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/export [post]
+func (ac *ArchiveController) ExportBucket(ctx *fiber.Ctx) error {
+	var bucketArchive models.BucketArchive
+	if err := ctx.BodyParser(&bucketArchive); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	operation, err := ac.archiveService.ExportBucket(ctx.UserContext(), ctx.Params("bucket_id"), &bucketArchive)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(operation)
+}
+
+// RestoreBucket is used to restore a bucket from an archive
+// @Summary Restore a bucket
+// @Description Recreate the bucket of an archive, optionally under a new name, and restore its objects in the background under their archived ids with their metadata and mime types. A bucket restored next to the archived one gets a new id and so do its objects. The bucket is locked until the returned operation finished. Admin api keys only
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param restore body models.BucketRestore true "Restore"
+// @Success 202 {object} models.Operation
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/restore [post]
+func (ac *ArchiveController) RestoreBucket(ctx *fiber.Ctx) error {
+	var bucketRestore models.BucketRestore
+	if err := ctx.BodyParser(&bucketRestore); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	operation, err := ac.archiveService.RestoreBucket(ctx.UserContext(), &bucketRestore)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(operation)
+}
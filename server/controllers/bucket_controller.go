@@ -22,14 +22,21 @@ func (bc *BucketController) RegisterBucketRoutes(app *fiber.App) {
 
 	routesV1.Post("/buckets", bc.CreateBucket)
 	routesV1.Patch("/buckets/:bucket_id", bc.UpdateBucket)
+	routesV1.Post("/buckets/:bucket_id/rename", bc.RenameBucket)
+	routesV1.Post("/buckets/:bucket_id/relocate", bc.RelocateBucket)
 	routesV1.Post("/buckets/:bucket_id/empty", bc.EmptyBucket)
 	routesV1.Post("/buckets/:bucket_id/disable", bc.DisableBucket)
 	routesV1.Post("/buckets/:bucket_id/enable", bc.EnableBucket)
+	routesV1.Post("/buckets/:bucket_id/unlock", bc.UnlockBucket)
+	routesV1.Post("/buckets/:bucket_id/import", bc.ImportBucket)
 	routesV1.Delete("/buckets/:bucket_id", bc.DeleteBucket)
 	routesV1.Get("/buckets", bc.ListAllBuckets)
 	routesV1.Get("/buckets/search", bc.SearchBuckets)
 	routesV1.Get("/buckets/:bucket_id", bc.GetBucket)
 	routesV1.Get("/buckets/:bucket_id/size", bc.GetBucketSize)
+	routesV1.Post("/buckets/:bucket_id/tag-rules", bc.CreateTagRule)
+	routesV1.Get("/buckets/:bucket_id/tag-rules", bc.ListTagRules)
+	routesV1.Delete("/buckets/:bucket_id/tag-rules/:tag_rule_id", bc.DeleteTagRule)
 }
 
 // CreateBucket is used to create a bucket
@@ -42,6 +49,7 @@ func (bc *BucketController) RegisterBucketRoutes(app *fiber.App) {
 // @Success 201 {object} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets [post]
 func (bc *BucketController) CreateBucket(ctx *fiber.Ctx) error {
 	var bucketCreate models.BucketCreate
@@ -51,7 +59,7 @@ func (bc *BucketController) CreateBucket(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	createdBucket, err := bc.bucketService.CreateBucket(ctx.Context(), &bucketCreate)
+	createdBucket, err := bc.bucketService.CreateBucket(ctx.UserContext(), &bucketCreate)
 	if err != nil {
 		return err
 	}
@@ -70,6 +78,7 @@ func (bc *BucketController) CreateBucket(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id} [patch]
 func (bc *BucketController) UpdateBucket(ctx *fiber.Ctx) error {
 	var bucketUpdate models.BucketUpdate
@@ -81,7 +90,7 @@ func (bc *BucketController) UpdateBucket(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	updatedBucket, err := bc.bucketService.UpdateBucket(ctx.Context(), &bucketUpdate)
+	updatedBucket, err := bc.bucketService.UpdateBucket(ctx.UserContext(), &bucketUpdate)
 	if err != nil {
 		return err
 	}
@@ -89,26 +98,89 @@ func (bc *BucketController) UpdateBucket(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(updatedBucket)
 }
 
+// RenameBucket is used to rename a bucket
+// @Summary Rename a bucket
+// @Description Rename a bucket, its content stays where it is. Buckets still stored under their name have to be relocated first
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param bucket body models.BucketRename true "Bucket Rename"
+// @Success 200 {object} models.Bucket
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/rename [post]
+func (bc *BucketController) RenameBucket(ctx *fiber.Ctx) error {
+	var bucketRename models.BucketRename
+
+	if err := ctx.BodyParser(&bucketRename); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	bucketRename.Id = ctx.Params("bucket_id")
+
+	renamedBucket, err := bc.bucketService.RenameBucket(ctx.UserContext(), &bucketRename)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(renamedBucket)
+}
+
+// RelocateBucket is used to move the content of a bucket stored under its name under its id
+// @Summary Relocate a bucket
+// @Description Move the content of a bucket stored under its name to keys under its id in the background, so the bucket can be renamed. The bucket is locked until the returned operation finishes, admin api keys only
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Success 202 {object} models.Operation
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/relocate [post]
+func (bc *BucketController) RelocateBucket(ctx *fiber.Ctx) error {
+	id := ctx.Params("bucket_id")
+
+	operation, err := bc.bucketService.RelocateBucket(ctx.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(operation)
+}
+
 // EmptyBucket is used to empty a bucket
 // @Summary Empty a bucket
-// @Description Empty a bucket
+// @Description Empty a bucket in the background, the returned operation reports the progress and can be cancelled through the operations endpoints
 // @Tags buckets
 // @Accept json
 // @Produce json
 // @Param bucket_id path string true "Bucket ID"
-// @Success 202
+// @Param bypass_governance_retention query bool false "Bypass governance retention of the objects, admin api keys only"
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id}/empty [post]
 func (bc *BucketController) EmptyBucket(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
+	bypassGovernance := ctx.QueryBool("bypass_governance_retention", false)
 
-	err := bc.bucketService.EmptyBucket(ctx.Context(), id)
+	job, err := bc.bucketService.EmptyBucket(ctx.UserContext(), id, bypassGovernance)
 	if err != nil {
 		return err
 	}
 
-	return ctx.SendStatus(fiber.StatusAccepted)
+	return ctx.Status(fiber.StatusAccepted).JSON(job)
 }
 
 // DisableBucket is used to disable an enabled bucket
@@ -121,11 +193,12 @@ func (bc *BucketController) EmptyBucket(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id}/disable [post]
 func (bc *BucketController) DisableBucket(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
 
-	disabledBucket, err := bc.bucketService.DisableBucket(ctx.Context(), id)
+	disabledBucket, err := bc.bucketService.DisableBucket(ctx.UserContext(), id)
 	if err != nil {
 		return err
 	}
@@ -143,11 +216,12 @@ func (bc *BucketController) DisableBucket(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id}/enable [post]
 func (bc *BucketController) EnableBucket(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
 
-	enabledBucket, err := bc.bucketService.EnableBucket(ctx.Context(), id)
+	enabledBucket, err := bc.bucketService.EnableBucket(ctx.UserContext(), id)
 	if err != nil {
 		return err
 	}
@@ -155,26 +229,87 @@ func (bc *BucketController) EnableBucket(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(enabledBucket)
 }
 
+// ImportBucket is used to import content already in storage into a bucket
+// @Summary Import content into a bucket
+// @Description Import content already in storage into the catalog of the bucket in the background, content is read from the bucket name unless a source prefix is given and copied into the bucket, the returned operation reports the progress and can be cancelled through the operations endpoints. Every imported object is scanned and processed like an upload
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param import body models.BucketImport true "Import"
+// @Success 202 {object} models.Operation
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/import [post]
+func (bc *BucketController) ImportBucket(ctx *fiber.Ctx) error {
+	id := ctx.Params("bucket_id")
+
+	var bucketImport models.BucketImport
+	if err := ctx.BodyParser(&bucketImport); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	operation, err := bc.bucketService.ImportBucket(ctx.UserContext(), id, &bucketImport)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(operation)
+}
+
+// UnlockBucket is used to force unlock a locked bucket
+// @Summary Force unlock a bucket
+// @Description Force unlock a bucket left locked by an emptying or deletion, the job holding the lock is cancelled and its operation recorded as cancelled. Admin api keys only
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Success 200 {object} models.Bucket
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/unlock [post]
+func (bc *BucketController) UnlockBucket(ctx *fiber.Ctx) error {
+	id := ctx.Params("bucket_id")
+
+	unlockedBucket, err := bc.bucketService.UnlockBucket(ctx.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(unlockedBucket)
+}
+
 // DeleteBucket is used to delete a bucket
 // @Summary Delete a bucket
-// @Description Delete a bucket
+// @Description Delete a bucket in the background, the returned operation reports the progress and can be cancelled through the operations endpoints
 // @Tags buckets
 // @Accept json
 // @Produce json
 // @Param bucket_id path string true "Bucket ID"
-// @Success 204
+// @Param bypass_governance_retention query bool false "Bypass governance retention of the objects, admin api keys only"
+// @Success 202 {object} models.Operation
 // @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id} [delete]
 func (bc *BucketController) DeleteBucket(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
+	bypassGovernance := ctx.QueryBool("bypass_governance_retention", false)
 
-	err := bc.bucketService.DeleteBucket(ctx.Context(), id)
+	job, err := bc.bucketService.DeleteBucket(ctx.UserContext(), id, bypassGovernance)
 	if err != nil {
 		return err
 	}
 
-	return ctx.SendStatus(fiber.StatusNoContent)
+	return ctx.Status(fiber.StatusAccepted).JSON(job)
 }
 
 // ListAllBuckets is used to list all buckets
@@ -186,9 +321,10 @@ func (bc *BucketController) DeleteBucket(ctx *fiber.Ctx) error {
 // @Success 200 {array} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets [get]
 func (bc *BucketController) ListAllBuckets(ctx *fiber.Ctx) error {
-	buckets, err := bc.bucketService.ListAllBuckets(ctx.Context())
+	buckets, err := bc.bucketService.ListAllBuckets(ctx.UserContext())
 	if err != nil {
 		return err
 	}
@@ -206,11 +342,12 @@ func (bc *BucketController) ListAllBuckets(ctx *fiber.Ctx) error {
 // @Success 200 {array} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/search [get]
 func (bc *BucketController) SearchBuckets(ctx *fiber.Ctx) error {
 	name := ctx.Query("name")
 
-	buckets, err := bc.bucketService.SearchBuckets(ctx.Context(), name)
+	buckets, err := bc.bucketService.SearchBuckets(ctx.UserContext(), name)
 	if err != nil {
 		return err
 	}
@@ -228,11 +365,12 @@ func (bc *BucketController) SearchBuckets(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.Bucket
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id} [get]
 func (bc *BucketController) GetBucket(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
 
-	bucket, err := bc.bucketService.GetBucket(ctx.Context(), id)
+	bucket, err := bc.bucketService.GetBucket(ctx.UserContext(), id)
 	if err != nil {
 		return err
 	}
@@ -250,14 +388,96 @@ func (bc *BucketController) GetBucket(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.BucketSize
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/buckets/{bucket_id}/size [get]
 func (bc *BucketController) GetBucketSize(ctx *fiber.Ctx) error {
 	id := ctx.Params("bucket_id")
 
-	bucketSize, err := bc.bucketService.GetBucketSize(ctx.Context(), id)
+	bucketSize, err := bc.bucketService.GetBucketSize(ctx.UserContext(), id)
 	if err != nil {
 		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(bucketSize)
 }
+
+// CreateTagRule is used to create a tag rule of a bucket
+// @Summary Create a tag rule
+// @Description Create a rule applying an action to the objects of a bucket that carry a tag. deny_delete rules refuse deleting or overwriting the objects and emptying or deleting the bucket, expire rules delete the objects once they are older than expire_after_days
+// @Tags buckets
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param tag_rule body models.TagRuleCreate true "Tag Rule Create"
+// @Success 201 {object} models.TagRule
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/tag-rules [post]
+func (bc *BucketController) CreateTagRule(ctx *fiber.Ctx) error {
+	var tagRuleCreate models.TagRuleCreate
+
+	tagRuleCreate.BucketId = ctx.Params("bucket_id")
+
+	err := ctx.BodyParser(&tagRuleCreate)
+	if err != nil {
+		return err
+	}
+
+	tagRule, err := bc.bucketService.CreateTagRule(ctx.UserContext(), &tagRuleCreate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(tagRule)
+}
+
+// ListTagRules is used to list the tag rules of a bucket
+// @Summary List the tag rules of a bucket
+// @Description List the tag rules of a bucket
+// @Tags buckets
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Success 200 {array} models.TagRule
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/tag-rules [get]
+func (bc *BucketController) ListTagRules(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+
+	tagRules, err := bc.bucketService.ListTagRules(ctx.UserContext(), bucketId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(tagRules)
+}
+
+// DeleteTagRule is used to delete a tag rule of a bucket
+// @Summary Delete a tag rule
+// @Description Delete a tag rule of a bucket
+// @Tags buckets
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param tag_rule_id path string true "Tag Rule ID"
+// @Success 204
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/tag-rules/{tag_rule_id} [delete]
+func (bc *BucketController) DeleteTagRule(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+	tagRuleId := ctx.Params("tag_rule_id")
+
+	err := bc.bucketService.DeleteTagRule(ctx.UserContext(), bucketId, tagRuleId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/services"
+)
+
+type HealthController struct {
+	healthService *services.HealthService
+}
+
+func NewHealthController(healthService *services.HealthService) *HealthController {
+	return &HealthController{
+		healthService: healthService,
+	}
+}
+
+// RegisterHealthRoutes registers the probe endpoints. they must be registered before the key auth
+// middleware since kubernetes probes do not send an api key
+func (hc *HealthController) RegisterHealthRoutes(app *fiber.App) {
+	app.Get("/healthz", hc.Liveness)
+	app.Get("/readyz", hc.Readiness)
+}
+
+// Liveness is used to check if the process is up
+// @Summary Liveness probe
+// @Description Reports that the process is up and able to serve http requests
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.Health
+// @Router /healthz [get]
+func (hc *HealthController) Liveness(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(hc.healthService.Liveness())
+}
+
+// Readiness is used to check if the service and its dependencies are ready to serve traffic
+// @Summary Readiness probe
+// @Description Reports the status of the database, storage, migrations and job client
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.Health
+// @Failure 503 {object} models.Health
+// @Router /readyz [get]
+func (hc *HealthController) Readiness(ctx *fiber.Ctx) error {
+	health := hc.healthService.Readiness(ctx.UserContext())
+
+	if health.Status != models.HealthStatusUp {
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(health)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(health)
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/services"
+)
+
+type JobController struct {
+	jobService *services.JobService
+}
+
+func NewJobController(jobService *services.JobService) *JobController {
+	return &JobController{
+		jobService: jobService,
+	}
+}
+
+func (jc *JobController) RegisterJobRoutes(app *fiber.App) {
+	routes := app.Group("/api")
+
+	routesV1 := routes.Group("/v1")
+
+	routesV1.Get("/jobs/:job_id", jc.GetJob)
+}
+
+// GetJob is used to get the status of a background job
+// @Summary Get a job
+// @Description Get the state of a background job along with the errors of its attempts and the progress it reported
+// @Tags jobs
+// @Produce json
+// @Param job_id path int true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/jobs/{job_id} [get]
+func (jc *JobController) GetJob(ctx *fiber.Ctx) error {
+	id, err := strconv.ParseInt(ctx.Params("job_id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid job id, job ids are numeric")
+	}
+
+	job, err := jc.jobService.GetJob(ctx.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(job)
+}
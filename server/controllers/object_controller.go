@@ -25,7 +25,18 @@ func (oc *ObjectController) RegisterObjectRoutes(app *fiber.App) {
 	routesV1.Get("/objects/pre-signed/download/:bucket_id/:object_id", oc.CreatePreSignedDownloadSession)
 	routesV1.Delete("/objects/:bucket_id/:object_id", oc.DeleteObject)
 	routesV1.Get("/objects/search/:bucket_id", oc.SearchObjects)
+	routesV1.Post("/objects/search/:bucket_id/metadata", oc.SearchObjectsByMetadata)
 	routesV1.Get("/objects/:bucket_id/:object_id", oc.GetObject)
+	routesV1.Patch("/objects/:bucket_id/:object_id", oc.UpdateObject)
+	routesV1.Get("/objects/:bucket_id/:object_id/render", oc.RenderObject)
+	routesV1.Get("/objects/:bucket_id/:object_id/tags", oc.GetObjectTags)
+	routesV1.Put("/objects/:bucket_id/:object_id/tags", oc.PutObjectTags)
+	routesV1.Delete("/objects/:bucket_id/:object_id/tags", oc.DeleteObjectTags)
+	routesV1.Put("/objects/:bucket_id/:object_id/legal-hold", oc.PutObjectLegalHold)
+	routesV1.Put("/objects/:bucket_id/:object_id/retention", oc.PutObjectRetention)
+	routesV1.Post("/objects/:bucket_id/:object_id/restore", oc.RestoreObject)
+	routesV1.Post("/objects/:bucket_id/batches", oc.CreateObjectBatch)
+	routesV1.Get("/objects/:bucket_id/batches/:batch_id", oc.GetObjectBatch)
 }
 
 // CreatePreSignedUploadSession is used to create a pre signed upload session
@@ -39,6 +50,7 @@ func (oc *ObjectController) RegisterObjectRoutes(app *fiber.App) {
 // @Success 201 {object} models.PreSignedUploadSession
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/objects/pre-signed/upload/{bucket_id} [post]
 func (oc *ObjectController) CreatePreSignedUploadSession(ctx *fiber.Ctx) error {
 	var preSignedUploadObjectCreate models.PreSignedUploadSessionCreate
@@ -50,7 +62,7 @@ func (oc *ObjectController) CreatePreSignedUploadSession(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	preSignedUploadObject, err := oc.objectService.CreatePreSignedUploadSession(ctx.Context(), &preSignedUploadObjectCreate)
+	preSignedUploadObject, err := oc.objectService.CreatePreSignedUploadSession(ctx.UserContext(), &preSignedUploadObjectCreate)
 	if err != nil {
 		return err
 	}
@@ -69,12 +81,13 @@ func (oc *ObjectController) CreatePreSignedUploadSession(ctx *fiber.Ctx) error {
 // @Success 200
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/objects/pre-signed/upload/{bucket_id}/{object_id}/complete [post]
 func (oc *ObjectController) CompletePreSignedUploadSession(ctx *fiber.Ctx) error {
 	bucketId := ctx.Params("bucket_id")
 	objectId := ctx.Params("object_id")
 
-	err := oc.objectService.CompletePreSignedUploadSession(ctx.Context(), bucketId, objectId)
+	err := oc.objectService.CompletePreSignedUploadSession(ctx.UserContext(), bucketId, objectId)
 	if err != nil {
 		return err
 	}
@@ -91,17 +104,21 @@ func (oc *ObjectController) CompletePreSignedUploadSession(ctx *fiber.Ctx) error
 // @Param bucket_id path string true "Bucket ID"
 // @Param object_id path string true "Object ID"
 // @Param expires_in query int true "Expires In"
+// @Param restore query bool false "Start the restore of an archived object to the hot tier instead of only refusing it"
 // @Success 200 {object} models.PreSignedDownloadSession
 // @Failure 400 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
-// @Router /api/v1/objects/pre-signed/download/{bucket_id}/{object_id} [post]
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/pre-signed/download/{bucket_id}/{object_id} [get]
 func (oc *ObjectController) CreatePreSignedDownloadSession(ctx *fiber.Ctx) error {
 	bucketId := ctx.Params("bucket_id")
 	objectId := ctx.Params("object_id")
 
 	expiresIn := ctx.QueryInt("expires_in")
+	restore := ctx.QueryBool("restore", false)
 
-	preSignedDownloadObject, err := oc.objectService.CreatePreSignedDownloadSession(ctx.Context(), bucketId, objectId, int64(expiresIn))
+	preSignedDownloadObject, err := oc.objectService.CreatePreSignedDownloadSession(ctx.UserContext(), bucketId, objectId, int64(expiresIn), restore)
 	if err != nil {
 		return err
 	}
@@ -109,6 +126,32 @@ func (oc *ObjectController) CreatePreSignedDownloadSession(ctx *fiber.Ctx) error
 	return ctx.Status(fiber.StatusOK).JSON(preSignedDownloadObject)
 }
 
+// RestoreObject is used to restore an archived object to the hot tier
+// @Summary Restore an archived object
+// @Description Start the restore of an object archived in the cold tier, the object is restoring and cannot be downloaded until it is back in the hot tier
+// @Tags objects
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Success 202 {object} models.Object
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/restore [post]
+func (oc *ObjectController) RestoreObject(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+	objectId := ctx.Params("object_id")
+
+	object, err := oc.objectService.RestoreObject(ctx.UserContext(), bucketId, objectId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(object)
+}
+
 // DeleteObject is used to delete an object
 // @Summary Delete an object
 // @Description Delete an object
@@ -117,15 +160,19 @@ func (oc *ObjectController) CreatePreSignedDownloadSession(ctx *fiber.Ctx) error
 // @Produce json
 // @Param bucket_id path string true "Bucket ID"
 // @Param object_id path string true "Object ID"
+// @Param bypass_governance_retention query bool false "Bypass governance retention of the object, admin api keys only"
 // @Success 204
 // @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/objects/{bucket_id}/{object_id} [delete]
 func (oc *ObjectController) DeleteObject(ctx *fiber.Ctx) error {
 	bucketId := ctx.Params("bucket_id")
 	objectId := ctx.Params("object_id")
+	bypassGovernance := ctx.QueryBool("bypass_governance_retention", false)
 
-	err := oc.objectService.DeleteObject(ctx.Context(), bucketId, objectId)
+	err := oc.objectService.DeleteObject(ctx.UserContext(), bucketId, objectId, bypassGovernance)
 	if err != nil {
 		return err
 	}
@@ -141,11 +188,13 @@ func (oc *ObjectController) DeleteObject(ctx *fiber.Ctx) error {
 // @Produce json
 // @Param bucket_id path string true "Bucket ID"
 // @Param object_path query string true "Object Path"
+// @Param tag query string false "Only objects tagged key=value, repeat to require several tags"
 // @Param limit query int true "Limit"
 // @Param offset query int true "Offset"
 // @Success 200 {array} models.Object
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/objects/search/{bucket_id} [get]
 func (oc *ObjectController) SearchObjects(ctx *fiber.Ctx) error {
 	bucketId := ctx.Params("bucket_id")
@@ -154,7 +203,49 @@ func (oc *ObjectController) SearchObjects(ctx *fiber.Ctx) error {
 	limit := ctx.QueryInt("limit")
 	offset := ctx.QueryInt("offset")
 
-	objects, err := oc.objectService.SearchObjects(ctx.Context(), bucketId, objectPath, int32(limit), int32(offset))
+	var tagFilters []string
+	for _, tagFilter := range ctx.Context().QueryArgs().PeekMulti("tag") {
+		tagFilters = append(tagFilters, string(tagFilter))
+	}
+
+	tags, err := models.ParseTagFilters(tagFilters)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	objects, err := oc.objectService.SearchObjects(ctx.UserContext(), bucketId, objectPath, tags, int32(limit), int32(offset))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(objects)
+}
+
+// SearchObjectsByMetadata is used to search objects by their metadata
+// @Summary Search objects by metadata
+// @Description Search the objects of a bucket whose metadata contains a json document and matches every condition, ordered by name
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param search body models.ObjectMetadataSearch true "Object Metadata Search"
+// @Success 200 {array} models.Object
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/search/{bucket_id}/metadata [post]
+func (oc *ObjectController) SearchObjectsByMetadata(ctx *fiber.Ctx) error {
+	var objectMetadataSearch models.ObjectMetadataSearch
+
+	objectMetadataSearch.BucketId = ctx.Params("bucket_id")
+
+	err := ctx.BodyParser(&objectMetadataSearch)
+	if err != nil {
+		return err
+	}
+
+	objects, err := oc.objectService.SearchObjectsByMetadata(ctx.UserContext(), &objectMetadataSearch)
 	if err != nil {
 		return err
 	}
@@ -173,15 +264,312 @@ func (oc *ObjectController) SearchObjects(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.Object
 // @Failure 400 {object} middleware.HttpError
 // @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
 // @Router /api/v1/objects/{bucket_id}/{object_id} [get]
 func (oc *ObjectController) GetObject(ctx *fiber.Ctx) error {
 	bucketId := ctx.Params("bucket_id")
 	objectId := ctx.Params("object_id")
 
-	object, err := oc.objectService.GetObject(ctx.Context(), bucketId, objectId)
+	object, err := oc.objectService.GetObject(ctx.UserContext(), bucketId, objectId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(object)
+}
+
+// UpdateObject is used to update the mime type and metadata of an object
+// @Summary Update an object
+// @Description Replace or merge the metadata and change the mime type of an object. the version the update is based on is required and a conflict is returned when the object changed since
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Param object body models.ObjectUpdate true "Object Update"
+// @Success 200 {object} models.Object
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id} [patch]
+func (oc *ObjectController) UpdateObject(ctx *fiber.Ctx) error {
+	var objectUpdate models.ObjectUpdate
+
+	objectUpdate.BucketId = ctx.Params("bucket_id")
+	objectUpdate.Id = ctx.Params("object_id")
+
+	err := ctx.BodyParser(&objectUpdate)
+	if err != nil {
+		return err
+	}
+
+	object, err := oc.objectService.UpdateObject(ctx.UserContext(), &objectUpdate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(object)
+}
+
+// RenderObject is used to render an image object
+// @Summary Render an image object
+// @Description Resize, crop or convert a jpeg, png, gif or webp object. renders are cached until the object is deleted or overwritten
+// @Tags objects
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Param width query int false "Width in pixels up to 4096, the height follows the aspect ratio when it is not given"
+// @Param height query int false "Height in pixels up to 4096, the width follows the aspect ratio when it is not given"
+// @Param fit query string false "One of contain (default, never enlarges), cover (crops the overflow) or fill (stretches)"
+// @Param crop_x query int false "Left edge of the crop in source pixels, the crop is applied before resizing"
+// @Param crop_y query int false "Top edge of the crop in source pixels"
+// @Param crop_width query int false "Width of the crop in source pixels"
+// @Param crop_height query int false "Height of the crop in source pixels"
+// @Param format query string false "One of jpeg, png or webp, defaults to the format of the source and png for gif"
+// @Param quality query int false "JPEG quality between 1 and 100, defaults to 80. webp output is lossless"
+// @Success 200 "The rendered image"
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/render [get]
+func (oc *ObjectController) RenderObject(ctx *fiber.Ctx) error {
+	var objectRender models.ObjectRender
+
+	err := ctx.QueryParser(&objectRender)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	objectRender.BucketId = ctx.Params("bucket_id")
+	objectRender.ObjectId = ctx.Params("object_id")
+
+	rendered, err := oc.objectService.RenderObject(ctx.UserContext(), &objectRender)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentType, rendered.MimeType)
+	ctx.Set(fiber.HeaderETag, rendered.ETag)
+
+	return ctx.Status(fiber.StatusOK).SendStream(rendered.Body, int(rendered.ContentLength))
+}
+
+// GetObjectTags is used to get the tags of an object
+// @Summary Get the tags of an object
+// @Description Get the tags of an object, an object without tags has an empty set of them
+// @Tags objects
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Success 200 {object} models.ObjectTags
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/tags [get]
+func (oc *ObjectController) GetObjectTags(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+	objectId := ctx.Params("object_id")
+
+	objectTags, err := oc.objectService.GetObjectTags(ctx.UserContext(), bucketId, objectId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(objectTags)
+}
+
+// PutObjectTags is used to replace the tags of an object
+// @Summary Replace the tags of an object
+// @Description Replace every tag of an object, tag rules of the bucket select objects by their tags
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Param tags body models.ObjectTagsPut true "Object Tags Put"
+// @Success 200 {object} models.ObjectTags
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/tags [put]
+func (oc *ObjectController) PutObjectTags(ctx *fiber.Ctx) error {
+	var objectTagsPut models.ObjectTagsPut
+
+	objectTagsPut.BucketId = ctx.Params("bucket_id")
+	objectTagsPut.ObjectId = ctx.Params("object_id")
+
+	err := ctx.BodyParser(&objectTagsPut)
+	if err != nil {
+		return err
+	}
+
+	objectTags, err := oc.objectService.PutObjectTags(ctx.UserContext(), &objectTagsPut)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(objectTags)
+}
+
+// DeleteObjectTags is used to remove the tags of an object
+// @Summary Remove the tags of an object
+// @Description Remove every tag of an object
+// @Tags objects
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Success 204
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/tags [delete]
+func (oc *ObjectController) DeleteObjectTags(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+	objectId := ctx.Params("object_id")
+
+	err := oc.objectService.DeleteObjectTags(ctx.UserContext(), bucketId, objectId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// PutObjectLegalHold is used to place or release the legal hold of an object
+// @Summary Place or release the legal hold of an object
+// @Description Place or release the legal hold of an object, an object under legal hold cannot be deleted or overwritten. every change is audited
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Param legal_hold body models.ObjectLegalHoldPut true "Object Legal Hold Put"
+// @Success 200 {object} models.Object
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/legal-hold [put]
+func (oc *ObjectController) PutObjectLegalHold(ctx *fiber.Ctx) error {
+	var objectLegalHoldPut models.ObjectLegalHoldPut
+
+	objectLegalHoldPut.BucketId = ctx.Params("bucket_id")
+	objectLegalHoldPut.ObjectId = ctx.Params("object_id")
+
+	err := ctx.BodyParser(&objectLegalHoldPut)
+	if err != nil {
+		return err
+	}
+
+	object, err := oc.objectService.PutObjectLegalHold(ctx.UserContext(), &objectLegalHoldPut)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(object)
+}
+
+// PutObjectRetention is used to set the retention of an object
+// @Summary Set the retention of an object
+// @Description Set the retention of an object, an object under retention cannot be deleted or overwritten. retention can always be extended, governance retention can only be shortened or removed by admin api keys bypassing it and compliance retention never. every change is audited
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param object_id path string true "Object ID"
+// @Param retention body models.ObjectRetentionPut true "Object Retention Put"
+// @Success 200 {object} models.Object
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/{object_id}/retention [put]
+func (oc *ObjectController) PutObjectRetention(ctx *fiber.Ctx) error {
+	var objectRetentionPut models.ObjectRetentionPut
+
+	objectRetentionPut.BucketId = ctx.Params("bucket_id")
+	objectRetentionPut.ObjectId = ctx.Params("object_id")
+
+	err := ctx.BodyParser(&objectRetentionPut)
+	if err != nil {
+		return err
+	}
+
+	object, err := oc.objectService.PutObjectRetention(ctx.UserContext(), &objectRetentionPut)
 	if err != nil {
 		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(object)
 }
+
+// CreateObjectBatch is used to delete, copy or tag many objects at once
+// @Summary Create an object batch
+// @Description Delete, copy or tag the objects listed by id or selected by prefix in a single background job. the request and the objects are checked up front, objects the operation is refused for later on are reported as failed items of the batch
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param batch body models.ObjectBatchCreate true "Object Batch Create"
+// @Success 202 {object} models.ObjectBatch
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/batches [post]
+func (oc *ObjectController) CreateObjectBatch(ctx *fiber.Ctx) error {
+	var objectBatchCreate models.ObjectBatchCreate
+
+	objectBatchCreate.BucketId = ctx.Params("bucket_id")
+
+	err := ctx.BodyParser(&objectBatchCreate)
+	if err != nil {
+		return err
+	}
+
+	objectBatch, err := oc.objectService.CreateObjectBatch(ctx.UserContext(), &objectBatchCreate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(objectBatch)
+}
+
+// GetObjectBatch is used to get the results of an object batch
+// @Summary Get an object batch
+// @Description Get the state of an object batch, the number of its items by status and the results of its items
+// @Tags objects
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Param batch_id path string true "Batch ID"
+// @Param status query string false "Only items of this status, one of pending, succeeded or failed"
+// @Param limit query int false "Limit of items, 100 by default and 0 to leave them out"
+// @Param offset query int false "Offset of items"
+// @Success 200 {object} models.ObjectBatch
+// @Failure 400 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/objects/{bucket_id}/batches/{batch_id} [get]
+func (oc *ObjectController) GetObjectBatch(ctx *fiber.Ctx) error {
+	bucketId := ctx.Params("bucket_id")
+	batchId := ctx.Params("batch_id")
+	status := ctx.Query("status")
+	limit := ctx.QueryInt("limit", 100)
+	offset := ctx.QueryInt("offset")
+
+	objectBatch, err := oc.objectService.GetObjectBatch(ctx.UserContext(), bucketId, batchId, status, int32(limit), int32(offset))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(objectBatch)
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"github.com/teapartydev/storage/server/openapi"
+)
+
+type OpenApiController struct{}
+
+func NewOpenApiController() *OpenApiController {
+	return &OpenApiController{}
+}
+
+// RegisterOpenApiRoutes registers the document and the docs ui. they are registered before the key auth middleware
+// since the browser loading the docs ui cannot send an api key, the key is entered in the ui to try requests out
+func (oc *OpenApiController) RegisterOpenApiRoutes(app *fiber.App) {
+	routes := app.Group("/api")
+
+	routesV1 := routes.Group("/v1")
+
+	routesV1.Get("/openapi.json", oc.GetOpenApiSpec)
+	routesV1.Get("/docs", oc.GetDocs)
+	routesV1.Use("/docs", filesystem.New(filesystem.Config{
+		Root: http.FS(swaggerFiles.FS),
+	}))
+}
+
+// GetOpenApiSpec is used to get the openapi document of the api
+// @Summary Get the openapi document
+// @Description Get the openapi 3 document generated from the api annotations
+// @Tags docs
+// @Produce json
+// @Success 200 "OpenAPI document"
+// @Router /api/v1/openapi.json [get]
+func (oc *OpenApiController) GetOpenApiSpec(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return ctx.Status(fiber.StatusOK).Send(openapi.Spec())
+}
+
+// GetDocs is used to browse the api documentation
+// @Summary Browse the api documentation
+// @Description Serves a swagger ui page rendering the openapi document
+// @Tags docs
+// @Produce html
+// @Success 200 "Swagger UI page"
+// @Router /api/v1/docs [get]
+func (oc *OpenApiController) GetDocs(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return ctx.Status(fiber.StatusOK).Send(openapi.Docs())
+}
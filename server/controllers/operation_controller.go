@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/services"
+)
+
+type OperationController struct {
+	operationService *services.OperationService
+}
+
+func NewOperationController(operationService *services.OperationService) *OperationController {
+	return &OperationController{
+		operationService: operationService,
+	}
+}
+
+func (oc *OperationController) RegisterOperationRoutes(app *fiber.App) {
+	routes := app.Group("/api")
+
+	routesV1 := routes.Group("/v1")
+
+	routesV1.Get("/operations/:operation_id", oc.GetOperation)
+	routesV1.Post("/operations/:operation_id/cancel", oc.CancelOperation)
+}
+
+// GetOperation is used to get the status of a bucket operation
+// @Summary Get an operation
+// @Description Get the state of an operation such as emptying or deleting a bucket along with its progress and errors
+// @Tags operations
+// @Produce json
+// @Param operation_id path string true "Operation ID"
+// @Success 200 {object} models.Operation
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/operations/{operation_id} [get]
+func (oc *OperationController) GetOperation(ctx *fiber.Ctx) error {
+	operation, err := oc.operationService.GetOperation(ctx.UserContext(), ctx.Params("operation_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(operation)
+}
+
+// CancelOperation is used to cancel a bucket operation
+// @Summary Cancel an operation
+// @Description Cancel an operation that has not finished. a queued operation is cancelled and its bucket unlocked right away, a running one is cancelling until it stopped and then unlocks its bucket. objects already deleted stay deleted
+// @Tags operations
+// @Produce json
+// @Param operation_id path string true "Operation ID"
+// @Success 200 {object} models.Operation
+// @Failure 404 {object} middleware.HttpError
+// @Failure 409 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/operations/{operation_id}/cancel [post]
+func (oc *OperationController) CancelOperation(ctx *fiber.Ctx) error {
+	operation, err := oc.operationService.CancelOperation(ctx.UserContext(), ctx.Params("operation_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(operation)
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/services"
+)
+
+type ReconciliationController struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationController(reconciliationService *services.ReconciliationService) *ReconciliationController {
+	return &ReconciliationController{
+		reconciliationService: reconciliationService,
+	}
+}
+
+func (rc *ReconciliationController) RegisterReconciliationRoutes(app *fiber.App) {
+	routes := app.Group("/api")
+
+	routesV1 := routes.Group("/v1")
+
+	routesV1.Get("/buckets/:bucket_id/reconciliation", rc.ReconcileBucket)
+}
+
+// ReconcileBucket is used to compare a bucket in storage with its catalog
+// @Summary Reconcile a bucket
+// @Description Dry run of the storage reconciliation of a bucket. Lists content in storage without an object and completed objects without content, nothing is fixed. Admin api keys only
+// @Tags buckets
+// @Produce json
+// @Param bucket_id path string true "Bucket ID"
+// @Success 200 {object} models.ReconciliationReport
+// @Failure 400 {object} middleware.HttpError
+// @Failure 403 {object} middleware.HttpError
+// @Failure 404 {object} middleware.HttpError
+// @Failure 500 {object} middleware.HttpError
+// @Security ApiKeyAuth
+// @Router /api/v1/buckets/{bucket_id}/reconciliation [get]
+func (rc *ReconciliationController) ReconcileBucket(ctx *fiber.Ctx) error {
+	report, err := rc.reconciliationService.ReconcileBucket(ctx.UserContext(), ctx.Params("bucket_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(report)
+}
@@ -7,8 +7,27 @@ package database
 
 import (
 	"context"
+	"time"
 )
 
+const bucketClearStaleStoragePrefix = `-- name: BucketClearStaleStoragePrefix :exec
+update storage.buckets
+set stale_storage_prefix = null
+where id = $1
+  and stale_storage_prefix = $2
+`
+
+type BucketClearStaleStoragePrefixParams struct {
+	ID                 string
+	StaleStoragePrefix *string
+}
+
+// clears the stale prefix once its content is deleted, unless it was replaced since
+func (q *Queries) BucketClearStaleStoragePrefix(ctx context.Context, arg *BucketClearStaleStoragePrefixParams) error {
+	_, err := q.db.Exec(ctx, bucketClearStaleStoragePrefix, arg.ID, arg.StaleStoragePrefix)
+	return err
+}
+
 const bucketCount = `-- name: BucketCount :one
 select count(1) as count
 from storage.buckets
@@ -23,11 +42,17 @@ func (q *Queries) BucketCount(ctx context.Context) (int64, error) {
 
 const bucketCreate = `-- name: BucketCreate :one
 insert into storage.buckets
-    (name, allowed_mime_types, max_allowed_object_size, public)
+    (name, allowed_mime_types, max_allowed_object_size, public, processors, default_retention_mode,
+     default_retention_days, replica, archive_after_days)
 values ($1,
         $2,
         $3,
-        $4)
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9)
 returning id
 `
 
@@ -36,6 +61,11 @@ type BucketCreateParams struct {
 	AllowedMimeTypes     []string
 	MaxAllowedObjectSize *int64
 	Public               bool
+	Processors           []string
+	DefaultRetentionMode *string
+	DefaultRetentionDays *int32
+	Replica              *string
+	ArchiveAfterDays     *int32
 }
 
 func (q *Queries) BucketCreate(ctx context.Context, arg *BucketCreateParams) (string, error) {
@@ -44,6 +74,11 @@ func (q *Queries) BucketCreate(ctx context.Context, arg *BucketCreateParams) (st
 		arg.AllowedMimeTypes,
 		arg.MaxAllowedObjectSize,
 		arg.Public,
+		arg.Processors,
+		arg.DefaultRetentionMode,
+		arg.DefaultRetentionDays,
+		arg.Replica,
+		arg.ArchiveAfterDays,
 	)
 	var id string
 	err := row.Scan(&id)
@@ -95,7 +130,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where id = $1
 limit 1
@@ -117,6 +162,16 @@ func (q *Queries) BucketGetById(ctx context.Context, id string) (*StorageBucket,
 		&i.LockedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Processors,
+		&i.DefaultRetentionMode,
+		&i.DefaultRetentionDays,
+		&i.LockJobID,
+		&i.LockHeartbeatAt,
+		&i.LockRecoveryRetries,
+		&i.StoragePrefix,
+		&i.Replica,
+		&i.ArchiveAfterDays,
+		&i.StaleStoragePrefix,
 	)
 	return &i, err
 }
@@ -133,7 +188,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where name = $1
 limit 1
@@ -155,6 +220,52 @@ func (q *Queries) BucketGetByName(ctx context.Context, name string) (*StorageBuc
 		&i.LockedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Processors,
+		&i.DefaultRetentionMode,
+		&i.DefaultRetentionDays,
+		&i.LockJobID,
+		&i.LockHeartbeatAt,
+		&i.LockRecoveryRetries,
+		&i.StoragePrefix,
+		&i.Replica,
+		&i.ArchiveAfterDays,
+		&i.StaleStoragePrefix,
+	)
+	return &i, err
+}
+
+const bucketGetLockForUpdate = `-- name: BucketGetLockForUpdate :one
+select id,
+       locked,
+       lock_reason,
+       locked_at,
+       lock_job_id,
+       lock_heartbeat_at
+from storage.buckets
+where id = $1
+limit 1 for update
+`
+
+type BucketGetLockForUpdateRow struct {
+	ID              string
+	Locked          bool
+	LockReason      *string
+	LockedAt        *time.Time
+	LockJobID       *int64
+	LockHeartbeatAt *time.Time
+}
+
+// holds the lock of a bucket until the transaction ends, so that it cannot be released or taken over meanwhile
+func (q *Queries) BucketGetLockForUpdate(ctx context.Context, id string) (*BucketGetLockForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, bucketGetLockForUpdate, id)
+	var i BucketGetLockForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.Locked,
+		&i.LockReason,
+		&i.LockedAt,
+		&i.LockJobID,
+		&i.LockHeartbeatAt,
 	)
 	return &i, err
 }
@@ -211,7 +322,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 `
 
@@ -237,6 +358,127 @@ func (q *Queries) BucketListAll(ctx context.Context) ([]*StorageBucket, error) {
 			&i.LockedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Processors,
+			&i.DefaultRetentionMode,
+			&i.DefaultRetentionDays,
+			&i.LockJobID,
+			&i.LockHeartbeatAt,
+			&i.LockRecoveryRetries,
+			&i.StoragePrefix,
+			&i.Replica,
+			&i.ArchiveAfterDays,
+			&i.StaleStoragePrefix,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const bucketListClaimingPrefix = `-- name: BucketListClaimingPrefix :many
+select id,
+       name,
+       storage_prefix,
+       stale_storage_prefix
+from storage.buckets
+where id <> $1
+  and (name = $2
+    or id = $2
+    or storage_prefix = $2
+    or stale_storage_prefix = $2)
+`
+
+type BucketListClaimingPrefixParams struct {
+	BucketID string
+	Prefix   string
+}
+
+type BucketListClaimingPrefixRow struct {
+	ID                 string
+	Name               string
+	StoragePrefix      string
+	StaleStoragePrefix *string
+}
+
+// other buckets whose name, id or content in storage a prefix could read
+func (q *Queries) BucketListClaimingPrefix(ctx context.Context, arg *BucketListClaimingPrefixParams) ([]*BucketListClaimingPrefixRow, error) {
+	rows, err := q.db.Query(ctx, bucketListClaimingPrefix, arg.BucketID, arg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*BucketListClaimingPrefixRow
+	for rows.Next() {
+		var i BucketListClaimingPrefixRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.StoragePrefix,
+			&i.StaleStoragePrefix,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const bucketListExpiredLocks = `-- name: BucketListExpiredLocks :many
+select id,
+       name,
+       lock_reason,
+       locked_at,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries
+from storage.buckets
+where locked
+  and coalesce(lock_heartbeat_at, locked_at) < now() - make_interval(secs => $1::bigint)
+  and id > $2
+order by id
+limit $3
+`
+
+type BucketListExpiredLocksParams struct {
+	LeaseTimeout int64
+	AfterID      string
+	Limit        int32
+}
+
+type BucketListExpiredLocksRow struct {
+	ID                  string
+	Name                string
+	LockReason          *string
+	LockedAt            *time.Time
+	LockJobID           *int64
+	LockHeartbeatAt     *time.Time
+	LockRecoveryRetries int32
+}
+
+func (q *Queries) BucketListExpiredLocks(ctx context.Context, arg *BucketListExpiredLocksParams) ([]*BucketListExpiredLocksRow, error) {
+	rows, err := q.db.Query(ctx, bucketListExpiredLocks, arg.LeaseTimeout, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*BucketListExpiredLocksRow
+	for rows.Next() {
+		var i BucketListExpiredLocksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.LockReason,
+			&i.LockedAt,
+			&i.LockJobID,
+			&i.LockHeartbeatAt,
+			&i.LockRecoveryRetries,
 		); err != nil {
 			return nil, err
 		}
@@ -260,7 +502,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where id >= $1
 limit $2
@@ -293,6 +545,16 @@ func (q *Queries) BucketListPaginated(ctx context.Context, arg *BucketListPagina
 			&i.LockedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Processors,
+			&i.DefaultRetentionMode,
+			&i.DefaultRetentionDays,
+			&i.LockJobID,
+			&i.LockHeartbeatAt,
+			&i.LockRecoveryRetries,
+			&i.StoragePrefix,
+			&i.Replica,
+			&i.ArchiveAfterDays,
+			&i.StaleStoragePrefix,
 		); err != nil {
 			return nil, err
 		}
@@ -304,24 +566,196 @@ func (q *Queries) BucketListPaginated(ctx context.Context, arg *BucketListPagina
 	return items, nil
 }
 
-const bucketLock = `-- name: BucketLock :exec
+const bucketListSizes = `-- name: BucketListSizes :many
+select bucket.id                             as id,
+       bucket.name                           as name,
+       coalesce(sum(object.size), 0)::bigint as size,
+       count(object.id)                      as count
+from storage.buckets as bucket
+         left join storage.objects as object on object.bucket_id = bucket.id
+group by bucket.id, bucket.name
+`
+
+type BucketListSizesRow struct {
+	ID    string
+	Name  string
+	Size  int64
+	Count int64
+}
+
+func (q *Queries) BucketListSizes(ctx context.Context) ([]*BucketListSizesRow, error) {
+	rows, err := q.db.Query(ctx, bucketListSizes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*BucketListSizesRow
+	for rows.Next() {
+		var i BucketListSizesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const bucketLock = `-- name: BucketLock :execrows
 update storage.buckets
-set locked      = true,
-    lock_reason = $1::text,
-    locked_at   = now()
-where id = $2
+set locked                = true,
+    lock_reason           = $1::text,
+    locked_at             = now(),
+    lock_job_id           = $2,
+    lock_heartbeat_at     = now(),
+    lock_recovery_retries = 0
+where id = $3
+  and not locked
 `
 
 type BucketLockParams struct {
 	LockReason string
+	LockJobID  *int64
 	ID         string
 }
 
-func (q *Queries) BucketLock(ctx context.Context, arg *BucketLockParams) error {
-	_, err := q.db.Exec(ctx, bucketLock, arg.LockReason, arg.ID)
+// locks a bucket only when no other operation holds it, no rows means a concurrent request locked it first
+func (q *Queries) BucketLock(ctx context.Context, arg *BucketLockParams) (int64, error) {
+	result, err := q.db.Exec(ctx, bucketLock, arg.LockReason, arg.LockJobID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const bucketLockHeartbeat = `-- name: BucketLockHeartbeat :execrows
+update storage.buckets
+set lock_heartbeat_at = now()
+where id = $1
+  and locked
+  and (lock_job_id is null or lock_job_id = $2::bigint)
+`
+
+type BucketLockHeartbeatParams struct {
+	ID        string
+	LockJobID int64
+}
+
+func (q *Queries) BucketLockHeartbeat(ctx context.Context, arg *BucketLockHeartbeatParams) (int64, error) {
+	result, err := q.db.Exec(ctx, bucketLockHeartbeat, arg.ID, arg.LockJobID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const bucketLockRecoveryRetry = `-- name: BucketLockRecoveryRetry :exec
+update storage.buckets
+set lock_heartbeat_at     = now(),
+    lock_recovery_retries = lock_recovery_retries + 1
+where id = $1
+  and locked
+  and lock_job_id = $2::bigint
+`
+
+type BucketLockRecoveryRetryParams struct {
+	ID        string
+	LockJobID int64
+}
+
+func (q *Queries) BucketLockRecoveryRetry(ctx context.Context, arg *BucketLockRecoveryRetryParams) error {
+	_, err := q.db.Exec(ctx, bucketLockRecoveryRetry, arg.ID, arg.LockJobID)
+	return err
+}
+
+const bucketRelocate = `-- name: BucketRelocate :exec
+update storage.buckets
+set storage_prefix       = id,
+    stale_storage_prefix = storage_prefix
+where id = $1
+  and storage_prefix <> id
+`
+
+// switches the bucket over to the content copied under its id, the content under its old prefix is left to delete
+func (q *Queries) BucketRelocate(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, bucketRelocate, id)
+	return err
+}
+
+const bucketRename = `-- name: BucketRename :exec
+update storage.buckets
+set name = $1
+where id = $2
+  and storage_prefix = id
+`
+
+type BucketRenameParams struct {
+	Name string
+	ID   string
+}
+
+// only buckets stored under their id can be renamed, the name of the others is part of the keys of their content
+func (q *Queries) BucketRename(ctx context.Context, arg *BucketRenameParams) error {
+	_, err := q.db.Exec(ctx, bucketRename, arg.Name, arg.ID)
 	return err
 }
 
+const bucketRestore = `-- name: BucketRestore :one
+insert into storage.buckets
+    (id, name, allowed_mime_types, max_allowed_object_size, public, processors, default_retention_mode,
+     default_retention_days, replica, archive_after_days)
+values ($1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9,
+        $10)
+returning id
+`
+
+type BucketRestoreParams struct {
+	ID                   *string
+	Name                 string
+	AllowedMimeTypes     []string
+	MaxAllowedObjectSize *int64
+	Public               bool
+	Processors           []string
+	DefaultRetentionMode *string
+	DefaultRetentionDays *int32
+	Replica              *string
+	ArchiveAfterDays     *int32
+}
+
+// recreates an archived bucket, a null id gives it a new one
+func (q *Queries) BucketRestore(ctx context.Context, arg *BucketRestoreParams) (string, error) {
+	row := q.db.QueryRow(ctx, bucketRestore,
+		arg.ID,
+		arg.Name,
+		arg.AllowedMimeTypes,
+		arg.MaxAllowedObjectSize,
+		arg.Public,
+		arg.Processors,
+		arg.DefaultRetentionMode,
+		arg.DefaultRetentionDays,
+		arg.Replica,
+		arg.ArchiveAfterDays,
+	)
+	var id string
+	err := row.Scan(&id)
+	return id, err
+}
+
 const bucketSearch = `-- name: BucketSearch :many
 select id,
        version,
@@ -334,7 +768,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where name ilike '%' || $1::text || '%'
 `
@@ -361,6 +805,16 @@ func (q *Queries) BucketSearch(ctx context.Context, name string) ([]*StorageBuck
 			&i.LockedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Processors,
+			&i.DefaultRetentionMode,
+			&i.DefaultRetentionDays,
+			&i.LockJobID,
+			&i.LockHeartbeatAt,
+			&i.LockRecoveryRetries,
+			&i.StoragePrefix,
+			&i.Replica,
+			&i.ArchiveAfterDays,
+			&i.StaleStoragePrefix,
 		); err != nil {
 			return nil, err
 		}
@@ -372,11 +826,30 @@ func (q *Queries) BucketSearch(ctx context.Context, name string) ([]*StorageBuck
 	return items, nil
 }
 
+const bucketSetStaleStoragePrefix = `-- name: BucketSetStaleStoragePrefix :exec
+update storage.buckets
+set stale_storage_prefix = $1
+where id = $2
+`
+
+type BucketSetStaleStoragePrefixParams struct {
+	StaleStoragePrefix *string
+	ID                 string
+}
+
+func (q *Queries) BucketSetStaleStoragePrefix(ctx context.Context, arg *BucketSetStaleStoragePrefixParams) error {
+	_, err := q.db.Exec(ctx, bucketSetStaleStoragePrefix, arg.StaleStoragePrefix, arg.ID)
+	return err
+}
+
 const bucketUnlock = `-- name: BucketUnlock :exec
 update storage.buckets
-set locked      = false,
-    lock_reason = null,
-    locked_at   = null
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
 where id = $1
 `
 
@@ -385,19 +858,102 @@ func (q *Queries) BucketUnlock(ctx context.Context, id string) error {
 	return err
 }
 
+const bucketUnlockByLockJobId = `-- name: BucketUnlockByLockJobId :execrows
+update storage.buckets
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
+where id = $1
+  and locked
+  and (lock_job_id is null or lock_job_id = $2::bigint)
+`
+
+type BucketUnlockByLockJobIdParams struct {
+	ID        string
+	LockJobID int64
+}
+
+// no rows means the bucket was unlocked or locked by another job since the lock was read
+func (q *Queries) BucketUnlockByLockJobId(ctx context.Context, arg *BucketUnlockByLockJobIdParams) (int64, error) {
+	result, err := q.db.Exec(ctx, bucketUnlockByLockJobId, arg.ID, arg.LockJobID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const bucketUnlockExpired = `-- name: BucketUnlockExpired :execrows
+update storage.buckets
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
+where id = $1
+  and locked
+  and lock_job_id is not distinct from $2::bigint
+`
+
+type BucketUnlockExpiredParams struct {
+	ID        string
+	LockJobID *int64
+}
+
+// releases an expired lock only while the same job still holds it, no rows means it was unlocked or locked again since
+func (q *Queries) BucketUnlockExpired(ctx context.Context, arg *BucketUnlockExpiredParams) (int64, error) {
+	result, err := q.db.Exec(ctx, bucketUnlockExpired, arg.ID, arg.LockJobID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const bucketUpdate = `-- name: BucketUpdate :exec
 update storage.buckets
 set max_allowed_object_size = coalesce($1, max_allowed_object_size),
     public                  = coalesce($2, public),
-    allowed_mime_types      = coalesce($3, allowed_mime_types)
-where id = $4
+    allowed_mime_types      = coalesce($3, allowed_mime_types),
+    processors              = coalesce($4, processors),
+    default_retention_mode  = case
+                                  when $5::boolean
+                                      then $6
+                                  else default_retention_mode
+        end,
+    default_retention_days  = case
+                                  when $5::boolean
+                                      then $7
+                                  else default_retention_days
+        end,
+    replica                 = case
+                                  when $8::boolean
+                                      then $9
+                                  else replica
+        end,
+    archive_after_days      = case
+                                  when $10::boolean
+                                      then $11
+                                  else archive_after_days
+        end
+where id = $12
 `
 
 type BucketUpdateParams struct {
-	MaxAllowedObjectSize *int64
-	Public               *bool
-	AllowedMimeTypes     []string
-	ID                   string
+	MaxAllowedObjectSize   *int64
+	Public                 *bool
+	AllowedMimeTypes       []string
+	Processors             []string
+	UpdateDefaultRetention bool
+	DefaultRetentionMode   *string
+	DefaultRetentionDays   *int32
+	UpdateReplica          bool
+	Replica                *string
+	UpdateArchiveAfterDays bool
+	ArchiveAfterDays       *int32
+	ID                     string
 }
 
 func (q *Queries) BucketUpdate(ctx context.Context, arg *BucketUpdateParams) error {
@@ -405,6 +961,14 @@ func (q *Queries) BucketUpdate(ctx context.Context, arg *BucketUpdateParams) err
 		arg.MaxAllowedObjectSize,
 		arg.Public,
 		arg.AllowedMimeTypes,
+		arg.Processors,
+		arg.UpdateDefaultRetention,
+		arg.DefaultRetentionMode,
+		arg.DefaultRetentionDays,
+		arg.UpdateReplica,
+		arg.Replica,
+		arg.UpdateArchiveAfterDays,
+		arg.ArchiveAfterDays,
 		arg.ID,
 	)
 	return err
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/riverqueue/river/rivertype"
+)
+
+// River does not expose lookups or retries of single jobs yet, these queries read and update its job table directly.
+// They are kept out of sqlc since the job table belongs to river's own migrations
+
+const jobGetById = `-- name: JobGetById :one
+select id, state, attempt, max_attempts, attempted_at, created_at, finalized_at, scheduled_at, priority, args,
+       errors, kind, metadata, queue, tags
+from river_job
+where id = $1
+limit 1
+`
+
+func (q *Queries) JobGetById(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return scanJobRow(q.db.QueryRow(ctx, jobGetById, id))
+}
+
+// jobRetry makes a finalized or waiting job available immediately, granting one more attempt when it ran out of them
+const jobRetry = `-- name: JobRetry :one
+update river_job
+set state        = 'available'::river_job_state,
+    scheduled_at = now(),
+    max_attempts = greatest(max_attempts, attempt + 1),
+    finalized_at = null
+where id = $1
+  and state in ('cancelled', 'discarded', 'retryable', 'scheduled', 'completed')
+returning id, state, attempt, max_attempts, attempted_at, created_at, finalized_at, scheduled_at, priority, args,
+    errors, kind, metadata, queue, tags
+`
+
+func (q *Queries) JobRetry(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return scanJobRow(q.db.QueryRow(ctx, jobRetry, id))
+}
+
+// jobUpdateProgress stores the progress of a job in its metadata, river only ever sets other keys of it
+const jobUpdateProgress = `-- name: JobUpdateProgress :exec
+update river_job
+set metadata = jsonb_set(metadata, '{progress}', $2::jsonb, true)
+where id = $1
+`
+
+func (q *Queries) JobUpdateProgress(ctx context.Context, id int64, progress []byte) error {
+	_, err := q.db.Exec(ctx, jobUpdateProgress, id, progress)
+	return err
+}
+
+func scanJobRow(row interface{ Scan(dest ...any) error }) (*rivertype.JobRow, error) {
+	var i rivertype.JobRow
+	var state string
+	var errors [][]byte
+	err := row.Scan(
+		&i.ID,
+		&state,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.AttemptedAt,
+		&i.CreatedAt,
+		&i.FinalizedAt,
+		&i.ScheduledAt,
+		&i.Priority,
+		&i.EncodedArgs,
+		&errors,
+		&i.Kind,
+		&i.Metadata,
+		&i.Queue,
+		&i.Tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	i.State = rivertype.JobState(state)
+
+	for _, encodedError := range errors {
+		var attemptError rivertype.AttemptError
+		if err = json.Unmarshal(encodedError, &attemptError); err != nil {
+			return nil, err
+		}
+		i.Errors = append(i.Errors, attemptError)
+	}
+
+	return &i, nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+type MigrationStatus struct {
+	CurrentVersion int64
+	LatestVersion  int64
+}
+
+func (m *MigrationStatus) IsUpToDate() bool {
+	return m.CurrentVersion >= m.LatestVersion
+}
+
+// GetMigrationStatus compares the version applied to the database against the latest embedded migration
+func GetMigrationStatus(ctx context.Context, db *pgxpool.Pool) (*MigrationStatus, error) {
+	if err := configureGoose(); err != nil {
+		return nil, err
+	}
+
+	sqlDb := stdlib.OpenDBFromPool(db)
+	defer sqlDb.Close()
+
+	currentVersion, err := goose.GetDBVersionContext(ctx, sqlDb)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	latestMigration, err := migrations.Last()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MigrationStatus{
+		CurrentVersion: currentVersion,
+		LatestVersion:  latestMigration.Version,
+	}, nil
+}
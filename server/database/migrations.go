@@ -1,9 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/teapartydev/storage/server/config"
+	"sync"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
@@ -32,11 +36,7 @@ func NewMigrations(config *config.Config, logger *zap.Logger) {
 		}
 	}(db)
 
-	goose.SetTableName("storage.goose_db_version")
-
-	goose.SetBaseFS(embedMigrations)
-
-	if err = goose.SetDialect("postgres"); err != nil {
+	if err = configureGoose(); err != nil {
 		logger.Fatal("failed to set migration dialect",
 			zap.Error(err),
 			zap.String("operation", op),
@@ -52,3 +52,46 @@ func NewMigrations(config *config.Config, logger *zap.Logger) {
 
 	logger.Info("database migrations done successfully", zap.String("operation", op))
 }
+
+// MigrateDown rolls back the latest applied migration
+func MigrateDown(ctx context.Context, db *pgxpool.Pool) error {
+	if err := configureGoose(); err != nil {
+		return err
+	}
+
+	sqlDb := stdlib.OpenDBFromPool(db)
+	defer sqlDb.Close()
+
+	return goose.DownContext(ctx, sqlDb, "migrations")
+}
+
+// MigrateDownTo rolls back migrations until version is the latest applied one, 0 rolls back every migration
+func MigrateDownTo(ctx context.Context, db *pgxpool.Pool, version int64) error {
+	if err := configureGoose(); err != nil {
+		return err
+	}
+
+	sqlDb := stdlib.OpenDBFromPool(db)
+	defer sqlDb.Close()
+
+	return goose.DownToContext(ctx, sqlDb, "migrations", version)
+}
+
+var (
+	gooseOnce sync.Once
+	gooseErr  error
+)
+
+// configureGoose sets the package level table, dialect and migrations of goose the first time it is called. readiness
+// probes read the migration status concurrently with each other and with migrations, so it is never set again
+func configureGoose() error {
+	gooseOnce.Do(func() {
+		goose.SetTableName("storage.goose_db_version")
+
+		goose.SetBaseFS(embedMigrations)
+
+		gooseErr = goose.SetDialect("postgres")
+	})
+
+	return gooseErr
+}
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create or replace function storage.on_api_key_create()
+    returns trigger as
+$$
+begin
+    new.id = 'apikey_' || storage.gen_random_ulid();
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+create table if not exists storage.api_keys
+(
+    id           text                      not null,
+    name         text                      not null,
+    key_prefix   text                      not null,
+    key_hash     text                      not null,
+    last_used_at timestamptz               null,
+    revoked_at   timestamptz               null,
+    created_at   timestamptz default now() not null,
+    constraint api_keys_id_primary_key primary key (id),
+    constraint api_keys_name_unique unique (name),
+    constraint api_keys_key_hash_unique unique (key_hash),
+    constraint api_keys_id_check check ( trim(id) <> '' ),
+    constraint api_keys_name_check check ( trim(name) <> '' ),
+    constraint api_keys_key_prefix_check check ( trim(key_prefix) <> '' ),
+    constraint api_keys_key_hash_check check ( trim(key_hash) <> '' )
+);
+
+create or replace trigger api_key_on_create
+    before insert
+    on storage.api_keys
+    for each row
+execute function storage.on_api_key_create();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop trigger if exists api_key_on_create on storage.api_keys;
+
+drop table if exists storage.api_keys;
+
+drop function if exists storage.on_api_key_create;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the s3 gateway lists keys in utf-8 binary order, which only matches the "C" collation
+create index if not exists objects_bucket_id_name_binary_index on storage.objects using btree (bucket_id, name collate "C");
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists storage.objects_bucket_id_name_binary_index;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- names of the processors that run on objects once their upload completes, null runs none
+alter table storage.buckets
+    add column if not exists processors text[] null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table storage.buckets
+    drop column if exists processors;
+
+-- +goose StatementEnd
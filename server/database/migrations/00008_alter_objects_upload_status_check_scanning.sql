@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- uploads are held in scanning until the malware scan passes and quarantined when it finds something
+alter table storage.objects
+    drop constraint if exists objects_upload_status_check;
+
+alter table storage.objects
+    add constraint objects_upload_status_check check ( upload_status in ('pending', 'scanning', 'completed', 'quarantined') );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- objects still being scanned go back to being served unscanned like every upload before scanning, quarantined ones
+-- are dropped from the catalog so they cannot be served. their content stays in storage and has to be removed by hand
+update storage.objects
+set upload_status = 'completed'
+where upload_status = 'scanning';
+
+delete
+from storage.objects
+where upload_status = 'quarantined';
+
+alter table storage.objects
+    drop constraint if exists objects_upload_status_check;
+
+alter table storage.objects
+    add constraint objects_upload_status_check check ( upload_status in ('pending', 'completed') );
+
+-- +goose StatementEnd
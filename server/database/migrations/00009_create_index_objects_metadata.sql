@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- jsonb_path_ops only serves containment but is a fraction of the size of the default operator class, metadata
+-- searches turn equality conditions into containment so they use it too
+create index if not exists objects_metadata_index on storage.objects using gin (metadata jsonb_path_ops);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists storage.objects_metadata_index;
+
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists storage.object_tags
+(
+    object_id  text                      not null,
+    key        text                      not null,
+    value      text                      not null,
+    created_at timestamptz default now() not null,
+    constraint object_tags_primary_key primary key (object_id, key),
+    constraint object_tags_object_id_foreign_key foreign key (object_id) references storage.objects (id) on delete cascade,
+    constraint object_tags_key_check check ( trim(key) <> '' )
+);
+
+-- serves tag filters and rule selectors, which look objects up by key and value
+create index if not exists object_tags_key_value_index on storage.object_tags using btree (key, value, object_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists storage.object_tags_key_value_index;
+
+drop table if exists storage.object_tags;
+
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create or replace function storage.on_tag_rule_create()
+    returns trigger as
+$$
+begin
+    new.id = 'tagrule_' || storage.gen_random_ulid();
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+create table if not exists storage.tag_rules
+(
+    id                text                      not null,
+    bucket_id         text                      not null,
+    tag_key           text                      not null,
+    tag_value         text                      not null,
+    action            text                      not null,
+    expire_after_days int                       null,
+    created_at        timestamptz default now() not null,
+    constraint tag_rules_id_primary_key primary key (id),
+    constraint tag_rules_bucket_id_foreign_key foreign key (bucket_id) references storage.buckets (id) on delete cascade,
+    constraint tag_rules_selector_action_unique unique (bucket_id, tag_key, tag_value, action),
+    constraint tag_rules_id_check check ( trim(id) <> '' ),
+    constraint tag_rules_tag_key_check check ( trim(tag_key) <> '' ),
+    constraint tag_rules_action_check check ( action in ('deny_delete', 'expire') ),
+    constraint tag_rules_expire_after_days_check check ( (action = 'expire' and expire_after_days > 0) or
+                                                         (action <> 'expire' and expire_after_days is null) )
+);
+
+create or replace trigger tag_rule_on_create
+    before insert
+    on storage.tag_rules
+    for each row
+execute function storage.on_tag_rule_create();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop trigger if exists tag_rule_on_create on storage.tag_rules;
+
+drop table if exists storage.tag_rules;
+
+drop function if exists storage.on_tag_rule_create;
+
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- retention every object of the bucket takes once its upload completes, null keeps objects unprotected
+alter table storage.buckets
+    add column if not exists default_retention_mode text null,
+    add column if not exists default_retention_days int  null,
+    add constraint buckets_default_retention_check check (
+        (default_retention_mode is null and default_retention_days is null) or
+        (default_retention_mode in ('governance', 'compliance') and default_retention_days > 0) );
+
+-- objects cannot be deleted or overwritten while under legal hold or before retain_until. governance retention can
+-- be bypassed by admin keys, compliance retention cannot be bypassed at all
+alter table storage.objects
+    add column if not exists legal_hold     boolean default false not null,
+    add column if not exists retention_mode text                  null,
+    add column if not exists retain_until   timestamptz           null,
+    add constraint objects_retention_check check (
+        (retention_mode is null and retain_until is null) or
+        (retention_mode in ('governance', 'compliance') and retain_until is not null) );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table storage.objects
+    drop constraint if exists objects_retention_check,
+    drop column if exists retain_until,
+    drop column if exists retention_mode,
+    drop column if exists legal_hold;
+
+alter table storage.buckets
+    drop constraint if exists buckets_default_retention_check,
+    drop column if exists default_retention_days,
+    drop column if exists default_retention_mode;
+
+-- +goose StatementEnd
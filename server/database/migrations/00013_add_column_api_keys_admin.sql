@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- admin keys may bypass governance retention, every bypass is recorded in storage.audit_events
+alter table storage.api_keys
+    add column if not exists admin boolean default false not null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table storage.api_keys
+    drop column if exists admin;
+
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create or replace function storage.on_audit_event_create()
+    returns trigger as
+$$
+begin
+    new.id = 'audit_' || storage.gen_random_ulid();
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- audit events are never updated or deleted by the service and keep no foreign keys so they outlive what they are
+-- about
+create table if not exists storage.audit_events
+(
+    id         text                      not null,
+    action     text                      not null,
+    principal  text                      not null,
+    bucket_id  text                      null,
+    object_id  text                      null,
+    details    jsonb                     null,
+    request_id text                      null,
+    created_at timestamptz default now() not null,
+    constraint audit_events_id_primary_key primary key (id),
+    constraint audit_events_id_check check ( trim(id) <> '' ),
+    constraint audit_events_action_check check ( trim(action) <> '' ),
+    constraint audit_events_principal_check check ( trim(principal) <> '' )
+);
+
+create index if not exists audit_events_bucket_id_created_at_index on storage.audit_events using btree (bucket_id, created_at);
+
+create or replace trigger audit_event_on_create
+    before insert
+    on storage.audit_events
+    for each row
+execute function storage.on_audit_event_create();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop trigger if exists audit_event_on_create on storage.audit_events;
+
+drop index if exists storage.audit_events_bucket_id_created_at_index;
+
+drop table if exists storage.audit_events;
+
+drop function if exists storage.on_audit_event_create;
+
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create or replace function storage.on_object_batch_create()
+    returns trigger as
+$$
+begin
+    new.id = 'batch_' || storage.gen_random_ulid();
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+create table if not exists storage.object_batches
+(
+    id         text                      not null,
+    bucket_id  text                      not null,
+    operation  text                      not null,
+    options    jsonb                     null,
+    job_id     bigint                    null,
+    created_at timestamptz default now() not null,
+    constraint object_batches_id_primary_key primary key (id),
+    constraint object_batches_bucket_id_foreign_key foreign key (bucket_id) references storage.buckets (id) on delete cascade,
+    constraint object_batches_id_check check ( trim(id) <> '' ),
+    constraint object_batches_operation_check check ( operation in ('delete', 'copy', 'tag') )
+);
+
+create index if not exists object_batches_bucket_id_index on storage.object_batches using btree (bucket_id);
+
+create or replace trigger object_batch_on_create
+    before insert
+    on storage.object_batches
+    for each row
+execute function storage.on_object_batch_create();
+
+-- items keep no foreign key to the objects since deleting them is what a batch may be about
+create table if not exists storage.object_batch_items
+(
+    batch_id   text                      not null,
+    object_id  text                      not null,
+    name       text                      not null,
+    status     text default 'pending'    not null,
+    error      text                      null,
+    updated_at timestamptz               null,
+    constraint object_batch_items_primary_key primary key (batch_id, object_id),
+    constraint object_batch_items_batch_id_foreign_key foreign key (batch_id) references storage.object_batches (id) on delete cascade,
+    constraint object_batch_items_status_check check ( status in ('pending', 'succeeded', 'failed') )
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists storage.object_batch_items;
+
+drop trigger if exists object_batch_on_create on storage.object_batches;
+
+drop index if exists storage.object_batches_bucket_id_index;
+
+drop table if exists storage.object_batches;
+
+drop function if exists storage.on_object_batch_create;
+
+-- +goose StatementEnd
@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create or replace function storage.on_operation_create()
+    returns trigger as
+$$
+begin
+    new.id = 'operation_' || storage.gen_random_ulid();
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+create or replace function storage.on_operation_update()
+    returns trigger as
+$$
+begin
+    new.updated_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- operations outlive the job working them, river prunes finalized jobs, and the bucket they are about, so they keep
+-- no foreign keys. the state is only recorded once the operation finished, until then it follows the job
+create table if not exists storage.operations
+(
+    id                  text                      not null,
+    type                text                      not null,
+    bucket_id           text                      not null,
+    job_id              bigint                    not null,
+    state               text                      null,
+    error               text                      null,
+    cancel_requested_at timestamptz               null,
+    finished_at         timestamptz               null,
+    created_at          timestamptz default now() not null,
+    updated_at          timestamptz               null,
+    constraint operations_id_primary_key primary key (id),
+    constraint operations_job_id_unique unique (job_id),
+    constraint operations_id_check check ( trim(id) <> '' ),
+    constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete') ),
+    constraint operations_state_check check ( state in ('succeeded', 'failed', 'cancelled') )
+);
+
+create or replace trigger operation_on_create
+    before insert
+    on storage.operations
+    for each row
+execute function storage.on_operation_create();
+
+create or replace trigger operation_on_update
+    before update
+    on storage.operations
+    for each row
+execute function storage.on_operation_update();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop trigger if exists operation_on_create on storage.operations;
+
+drop trigger if exists operation_on_update on storage.operations;
+
+drop table if exists storage.operations;
+
+drop function if exists storage.on_operation_update;
+
+drop function if exists storage.on_operation_create;
+
+-- +goose StatementEnd
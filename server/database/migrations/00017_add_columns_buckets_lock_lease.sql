@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a lock is leased to the job holding it, the job renews lock_heartbeat_at while it works and the lock reconciler
+-- releases or retries locks whose lease ran out while their job is gone or discarded
+alter table storage.buckets
+    add column if not exists lock_job_id           bigint                   null,
+    add column if not exists lock_heartbeat_at     timestamptz              null,
+    add column if not exists lock_recovery_retries integer default 0        not null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table storage.buckets
+    drop column if exists lock_job_id,
+    drop column if exists lock_heartbeat_at,
+    drop column if exists lock_recovery_retries;
+
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- imports of content already in storage are tracked as operations of their bucket
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete', 'bucket.import') );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+delete
+from storage.operations
+where type = 'bucket.import';
+
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete') );
+
+-- +goose StatementEnd
@@ -0,0 +1,84 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- restored buckets and objects keep the ids they were archived with, ids are only generated when none is given
+create or replace function storage.on_bucket_create()
+    returns trigger as
+$$
+begin
+    new.id = coalesce(new.id, 'bucket_' || storage.gen_random_ulid());
+    new.version = 0;
+    new.created_at = now();
+
+    if new.allowed_mime_types is not null then
+        new.allowed_mime_types = array(select distinct unnest(new.allowed_mime_types));
+    end if;
+
+    return new;
+end;
+$$ language plpgsql;
+
+create or replace function storage.on_object_create()
+    returns trigger as
+$$
+begin
+    new.id = coalesce(new.id, 'object_' || storage.gen_random_ulid());
+    new.version = 0;
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- exports and restores of bucket archives are tracked as operations of their bucket
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete', 'bucket.import',
+                                                          'bucket.export', 'bucket.restore') );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+delete
+from storage.operations
+where type in ('bucket.export', 'bucket.restore');
+
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete', 'bucket.import') );
+
+create or replace function storage.on_object_create()
+    returns trigger as
+$$
+begin
+    new.id = 'object_' || storage.gen_random_ulid();
+    new.version = 0;
+    new.created_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+create or replace function storage.on_bucket_create()
+    returns trigger as
+$$
+begin
+    new.id = 'bucket_' || storage.gen_random_ulid();
+    new.version = 0;
+    new.created_at = now();
+
+    if new.allowed_mime_types is not null then
+        new.allowed_mime_types = array(select distinct unnest(new.allowed_mime_types));
+    end if;
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- +goose StatementEnd
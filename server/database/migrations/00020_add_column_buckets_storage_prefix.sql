@@ -0,0 +1,91 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the content of a bucket is stored under storage_prefix. new buckets are stored under their id so they can be renamed,
+-- buckets created before keep their name until a relocation moves their content under their id. bucket names cannot
+-- contain underscores and ids always do, so a name and an id never share a prefix
+alter table storage.buckets
+    add column if not exists storage_prefix text null;
+
+update storage.buckets
+set storage_prefix = name
+where storage_prefix is null;
+
+alter table storage.buckets
+    alter column storage_prefix set not null,
+    add constraint buckets_storage_prefix_unique unique (storage_prefix),
+    add constraint buckets_storage_prefix_check check ( trim(storage_prefix) <> '' );
+
+create or replace function storage.on_bucket_create()
+    returns trigger as
+$$
+begin
+    new.id = coalesce(new.id, 'bucket_' || storage.gen_random_ulid());
+    new.storage_prefix = coalesce(new.storage_prefix, new.id);
+    new.version = 0;
+    new.created_at = now();
+
+    if new.allowed_mime_types is not null then
+        new.allowed_mime_types = array(select distinct unnest(new.allowed_mime_types));
+    end if;
+
+    return new;
+end;
+$$ language plpgsql;
+
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete', 'bucket.import',
+                                                          'bucket.export', 'bucket.restore', 'bucket.relocate') );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- buckets are found in storage under their name once storage_prefix is gone, the content of buckets stored under
+-- another prefix has to be relocated back under their name by hand before rolling back
+do
+$$
+    begin
+        if exists(select 1 from storage.buckets where storage_prefix <> name) then
+            raise exception 'buckets stored under another prefix than their name cannot be rolled back';
+        end if;
+    end
+$$;
+
+delete
+from storage.operations
+where type = 'bucket.relocate';
+
+alter table storage.operations
+    drop constraint if exists operations_type_check;
+
+alter table storage.operations
+    add constraint operations_type_check check ( type in ('bucket.empty', 'bucket.delete', 'bucket.import',
+                                                          'bucket.export', 'bucket.restore') );
+
+create or replace function storage.on_bucket_create()
+    returns trigger as
+$$
+begin
+    new.id = coalesce(new.id, 'bucket_' || storage.gen_random_ulid());
+    new.version = 0;
+    new.created_at = now();
+
+    if new.allowed_mime_types is not null then
+        new.allowed_mime_types = array(select distinct unnest(new.allowed_mime_types));
+    end if;
+
+    return new;
+end;
+$$ language plpgsql;
+
+alter table storage.buckets
+    drop constraint if exists buckets_storage_prefix_check,
+    drop constraint if exists buckets_storage_prefix_unique,
+    drop column if exists storage_prefix;
+
+-- +goose StatementEnd
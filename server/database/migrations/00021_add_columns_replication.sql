@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- replica names one of the replicas in the config that completed objects of the bucket are copied to
+alter table storage.buckets
+    add column if not exists replica text null,
+    add constraint buckets_replica_check check ( replica is null or trim(replica) <> '' );
+
+-- replication_status is null for objects of buckets without a replica, it is pending from the moment the content of
+-- the object is completed until the copy on the replica is made
+alter table storage.objects
+    add column if not exists replication_status text        null,
+    add column if not exists replicated_at      timestamptz null,
+    add constraint objects_replication_status_check check ( replication_status is null or
+                                                            replication_status in ('pending', 'replicated', 'failed') );
+
+create index if not exists objects_replication_status_pending_index on storage.objects using btree (bucket_id)
+    where replication_status = 'pending';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists storage.objects_replication_status_pending_index;
+
+alter table storage.objects
+    drop constraint if exists objects_replication_status_check,
+    drop column if exists replication_status,
+    drop column if exists replicated_at;
+
+alter table storage.buckets
+    drop constraint if exists buckets_replica_check,
+    drop column if exists replica;
+
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- archive_after_days moves completed objects of the bucket that were not accessed for that many days to the cold tier,
+-- objects never accessed count from their creation
+alter table storage.buckets
+    add column if not exists archive_after_days integer null,
+    add constraint buckets_archive_after_days_check check ( archive_after_days is null or archive_after_days > 0 );
+
+-- tier is where the content of an object is stored. cold content is kept in the cold storage class and cannot be
+-- downloaded until it is restored, restoring content is on its way back to the hot tier
+alter table storage.objects
+    add column if not exists tier text not null default 'hot',
+    add constraint objects_tier_check check ( tier in ('hot', 'cold', 'restoring') );
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table storage.objects
+    drop constraint if exists objects_tier_check,
+    drop column if exists tier;
+
+alter table storage.buckets
+    drop constraint if exists buckets_archive_after_days_check,
+    drop column if exists archive_after_days;
+
+-- +goose StatementEnd
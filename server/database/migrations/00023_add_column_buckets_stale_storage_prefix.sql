@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- stale_storage_prefix holds content of the bucket left behind by a relocation, the keys copied under its id by a
+-- relocation that did not switch over or the keys under its name once it did. the content is deleted by the relocation
+-- when it finishes, or by the storage reconciliation when the relocation stopped before
+alter table storage.buckets
+    add column if not exists stale_storage_prefix text null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- the content under a stale prefix would be left in storage with nothing to delete it
+do
+$$
+    begin
+        if exists(select 1 from storage.buckets where stale_storage_prefix is not null) then
+            raise exception 'buckets with content left under a stale storage prefix cannot be rolled back';
+        end if;
+    end
+$$;
+
+alter table storage.buckets
+    drop column if exists stale_storage_prefix;
+
+-- +goose StatementEnd
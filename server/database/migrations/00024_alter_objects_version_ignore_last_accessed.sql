@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- reading an object only records when it was last accessed, which is no change to the object. its version and
+-- updated_at stay as they are so that a metadata update based on the version read is not refused for the read itself
+create or replace function storage.on_object_update()
+    returns trigger as
+$$
+begin
+    if to_jsonb(new) - 'last_accessed_at' = to_jsonb(old) - 'last_accessed_at' then
+        return new;
+    end if;
+
+    new.version = new.version + 1;
+    new.updated_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+create or replace function storage.on_object_update()
+    returns trigger as
+$$
+begin
+    new.version = new.version + 1;
+    new.updated_at = now();
+
+    return new;
+end;
+$$ language plpgsql;
+
+-- +goose StatementEnd
@@ -8,6 +8,28 @@ import (
 	"time"
 )
 
+type StorageApiKey struct {
+	ID         string
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	Admin      bool
+}
+
+type StorageAuditEvent struct {
+	ID        string
+	Action    string
+	Principal string
+	BucketID  *string
+	ObjectID  *string
+	Details   []byte
+	RequestID *string
+	CreatedAt time.Time
+}
+
 type StorageBucket struct {
 	ID                   string
 	Version              int32
@@ -21,18 +43,82 @@ type StorageBucket struct {
 	LockedAt             *time.Time
 	CreatedAt            time.Time
 	UpdatedAt            *time.Time
+	Processors           []string
+	DefaultRetentionMode *string
+	DefaultRetentionDays *int32
+	LockJobID            *int64
+	LockHeartbeatAt      *time.Time
+	LockRecoveryRetries  int32
+	StoragePrefix        string
+	Replica              *string
+	ArchiveAfterDays     *int32
+	StaleStoragePrefix   *string
 }
 
 type StorageObject struct {
-	ID             string
-	Version        int32
-	BucketID       string
-	Name           string
-	MimeType       string
-	Size           int64
-	Metadata       []byte
-	UploadStatus   string
-	LastAccessedAt *time.Time
-	CreatedAt      time.Time
-	UpdatedAt      *time.Time
+	ID                string
+	Version           int32
+	BucketID          string
+	Name              string
+	MimeType          string
+	Size              int64
+	Metadata          []byte
+	UploadStatus      string
+	LastAccessedAt    *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         *time.Time
+	LegalHold         bool
+	RetentionMode     *string
+	RetainUntil       *time.Time
+	ReplicationStatus *string
+	ReplicatedAt      *time.Time
+	Tier              string
+}
+
+type StorageObjectBatch struct {
+	ID        string
+	BucketID  string
+	Operation string
+	Options   []byte
+	JobID     *int64
+	CreatedAt time.Time
+}
+
+type StorageObjectBatchItem struct {
+	BatchID   string
+	ObjectID  string
+	Name      string
+	Status    string
+	Error     *string
+	UpdatedAt *time.Time
+}
+
+type StorageObjectTag struct {
+	ObjectID  string
+	Key       string
+	Value     string
+	CreatedAt time.Time
+}
+
+type StorageOperation struct {
+	ID                string
+	Type              string
+	BucketID          string
+	JobID             int64
+	State             *string
+	Error             *string
+	CancelRequestedAt *time.Time
+	FinishedAt        *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         *time.Time
+}
+
+type StorageTagRule struct {
+	ID              string
+	BucketID        string
+	TagKey          string
+	TagValue        string
+	Action          string
+	ExpireAfterDays *int32
+	CreatedAt       time.Time
 }
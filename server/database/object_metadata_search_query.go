@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metadata searches take any number of conditions so their query is built here instead of by sqlc
+
+const (
+	MetadataOperatorEqual          = "eq"
+	MetadataOperatorNotEqual       = "ne"
+	MetadataOperatorGreater        = "gt"
+	MetadataOperatorGreaterOrEqual = "gte"
+	MetadataOperatorLess           = "lt"
+	MetadataOperatorLessOrEqual    = "lte"
+	MetadataOperatorExists         = "exists"
+	MetadataOperatorNotExists      = "not_exists"
+)
+
+// metadataOrderingOperators compare values of the same json type only, jsonb orders values of different types by
+// type so "10" would otherwise be less than 5
+var metadataOrderingOperators = map[string]string{
+	MetadataOperatorGreater:        ">",
+	MetadataOperatorGreaterOrEqual: ">=",
+	MetadataOperatorLess:           "<",
+	MetadataOperatorLessOrEqual:    "<=",
+}
+
+type ObjectMetadataCondition struct {
+	// Path is the key of the value, one element per level of nesting
+	Path     []string
+	Operator string
+	// Value is the json the value is compared to, unused by the exists operators
+	Value []byte
+}
+
+type ObjectSearchByMetadataParams struct {
+	BucketID string
+	// Contains is a json document the metadata must contain as the @> operator defines it, nil matches any metadata
+	Contains   []byte
+	Conditions []ObjectMetadataCondition
+	// TagKeys and TagValues pair up into the tags objects must carry
+	TagKeys   []string
+	TagValues []string
+	Limit     int32
+	Offset    int32
+}
+
+const objectSearchByMetadataColumns = `select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+`
+
+func (q *Queries) ObjectSearchByMetadata(ctx context.Context, arg *ObjectSearchByMetadataParams) ([]*StorageObject, error) {
+	query, args, err := buildObjectSearchByMetadata(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*StorageObject{}
+	for rows.Next() {
+		var i StorageObject
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.BucketID,
+			&i.Name,
+			&i.MimeType,
+			&i.Size,
+			&i.Metadata,
+			&i.UploadStatus,
+			&i.LastAccessedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+			&i.ReplicationStatus,
+			&i.ReplicatedAt,
+			&i.Tier,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func buildObjectSearchByMetadata(arg *ObjectSearchByMetadataParams) (string, []any, error) {
+	args := []any{arg.BucketID}
+	where := []string{"bucket_id = $1"}
+
+	placeholder := func(value any, cast string) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args)) + cast
+	}
+
+	if arg.Contains != nil {
+		where = append(where, "metadata @> "+placeholder(string(arg.Contains), "::jsonb"))
+	}
+
+	for _, condition := range arg.Conditions {
+		if len(condition.Path) == 0 {
+			return "", nil, fmt.Errorf("metadata condition has an empty path")
+		}
+
+		path := "metadata #> " + placeholder(condition.Path, "::text[]")
+
+		switch condition.Operator {
+		case MetadataOperatorExists:
+			where = append(where, path+" is not null")
+		case MetadataOperatorNotExists:
+			where = append(where, path+" is null")
+		case MetadataOperatorEqual:
+			// the containment lets the gin index narrow the rows down, the comparison keeps arrays and objects from
+			// matching values they merely contain
+			if contains, ok := containmentOf(condition.Path, condition.Value); ok {
+				where = append(where, "metadata @> "+placeholder(contains, "::jsonb"))
+			}
+			where = append(where, path+" = "+placeholder(string(condition.Value), "::jsonb"))
+		case MetadataOperatorNotEqual:
+			where = append(where, path+" is distinct from "+placeholder(string(condition.Value), "::jsonb"))
+		default:
+			operator, ok := metadataOrderingOperators[condition.Operator]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown metadata operator %q", condition.Operator)
+			}
+			value := placeholder(string(condition.Value), "::jsonb")
+			where = append(where, fmt.Sprintf("jsonb_typeof(%s) = jsonb_typeof(%s) and %s %s %s", path, value, path, operator, value))
+		}
+	}
+
+	if len(arg.TagKeys) > 0 {
+		where = append(where, fmt.Sprintf(
+			"%d = (select count(1) from storage.object_tags as tag where tag.object_id = objects.id and (tag.key, tag.value) in (select unnest(%s), unnest(%s)))",
+			len(arg.TagKeys), placeholder(arg.TagKeys, "::text[]"), placeholder(arg.TagValues, "::text[]"),
+		))
+	}
+
+	query := objectSearchByMetadataColumns +
+		"where " + strings.Join(where, "\n  and ") + "\n" +
+		"order by name\n" +
+		"limit " + placeholder(arg.Limit, "") + " offset " + placeholder(arg.Offset, "")
+
+	return query, args, nil
+}
+
+// containmentOf nests value under path into the document an equality implies the metadata contains. numeric keys may
+// index into arrays, which a document of objects cannot express, so paths holding them are left to the comparison
+func containmentOf(path []string, value []byte) (string, bool) {
+	document := json.RawMessage(value)
+
+	for i := len(path) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(path[i]); err == nil {
+			return "", false
+		}
+
+		nested, err := json.Marshal(map[string]json.RawMessage{path[i]: document})
+		if err != nil {
+			return "", false
+		}
+		document = nested
+	}
+
+	return string(document), true
+}
@@ -10,6 +10,73 @@ import (
 	"time"
 )
 
+const objectCountByUploadStatus = `-- name: ObjectCountByUploadStatus :one
+select count(1) as count
+from storage.objects
+where upload_status = $1
+`
+
+func (q *Queries) ObjectCountByUploadStatus(ctx context.Context, uploadStatus string) (int64, error) {
+	row := q.db.QueryRow(ctx, objectCountByUploadStatus, uploadStatus)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const objectCountCompletedByBucketIdAndPrefix = `-- name: ObjectCountCompletedByBucketIdAndPrefix :one
+select count(*)::bigint
+from storage.objects
+where bucket_id = $1
+  and upload_status = 'completed'
+  and starts_with(name, $2::text)
+`
+
+type ObjectCountCompletedByBucketIdAndPrefixParams struct {
+	BucketID string
+	Prefix   string
+}
+
+func (q *Queries) ObjectCountCompletedByBucketIdAndPrefix(ctx context.Context, arg *ObjectCountCompletedByBucketIdAndPrefixParams) (int64, error) {
+	row := q.db.QueryRow(ctx, objectCountCompletedByBucketIdAndPrefix, arg.BucketID, arg.Prefix)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const objectCountPendingReplicationByReplica = `-- name: ObjectCountPendingReplicationByReplica :many
+select bucket.replica::text as replica, count(object.id) as count
+from storage.objects as object
+         inner join storage.buckets as bucket on object.bucket_id = bucket.id
+where object.replication_status = 'pending'
+  and bucket.replica is not null
+group by bucket.replica
+`
+
+type ObjectCountPendingReplicationByReplicaRow struct {
+	Replica string
+	Count   int64
+}
+
+func (q *Queries) ObjectCountPendingReplicationByReplica(ctx context.Context) ([]*ObjectCountPendingReplicationByReplicaRow, error) {
+	rows, err := q.db.Query(ctx, objectCountPendingReplicationByReplica)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ObjectCountPendingReplicationByReplicaRow
+	for rows.Next() {
+		var i ObjectCountPendingReplicationByReplicaRow
+		if err := rows.Scan(&i.Replica, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const objectCreate = `-- name: ObjectCreate :one
 insert into storage.objects
     (bucket_id, name, mime_type, size, metadata, upload_status)
@@ -56,6 +123,17 @@ func (q *Queries) ObjectDelete(ctx context.Context, id string) error {
 	return err
 }
 
+const objectDeleteMany = `-- name: ObjectDeleteMany :exec
+delete
+from storage.objects
+where id = any ($1::text[])
+`
+
+func (q *Queries) ObjectDeleteMany(ctx context.Context, ids []string) error {
+	_, err := q.db.Exec(ctx, objectDeleteMany, ids)
+	return err
+}
+
 const objectGetByBucketIdAndId = `-- name: ObjectGetByBucketIdAndId :one
 select id,
        version,
@@ -67,7 +145,13 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where bucket_id = $1
   and id = $2
@@ -94,6 +178,66 @@ func (q *Queries) ObjectGetByBucketIdAndId(ctx context.Context, arg *ObjectGetBy
 		&i.LastAccessedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+		&i.ReplicationStatus,
+		&i.ReplicatedAt,
+		&i.Tier,
+	)
+	return &i, err
+}
+
+const objectGetByBucketIdAndName = `-- name: ObjectGetByBucketIdAndName :one
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = $1
+  and name = $2
+limit 1
+`
+
+type ObjectGetByBucketIdAndNameParams struct {
+	BucketID string
+	Name     string
+}
+
+func (q *Queries) ObjectGetByBucketIdAndName(ctx context.Context, arg *ObjectGetByBucketIdAndNameParams) (*StorageObject, error) {
+	row := q.db.QueryRow(ctx, objectGetByBucketIdAndName, arg.BucketID, arg.Name)
+	var i StorageObject
+	err := row.Scan(
+		&i.ID,
+		&i.Version,
+		&i.BucketID,
+		&i.Name,
+		&i.MimeType,
+		&i.Size,
+		&i.Metadata,
+		&i.UploadStatus,
+		&i.LastAccessedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+		&i.ReplicationStatus,
+		&i.ReplicatedAt,
+		&i.Tier,
 	)
 	return &i, err
 }
@@ -109,7 +253,13 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where id = $1
 limit 1
@@ -130,6 +280,12 @@ func (q *Queries) ObjectGetById(ctx context.Context, id string) (*StorageObject,
 		&i.LastAccessedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+		&i.ReplicationStatus,
+		&i.ReplicatedAt,
+		&i.Tier,
 	)
 	return &i, err
 }
@@ -139,6 +295,7 @@ select object.id,
        object.version,
        object.bucket_id,
        bucket.name as bucket_name,
+       bucket.storage_prefix as bucket_storage_prefix,
        object.name,
        object.mime_type,
        object.size,
@@ -146,7 +303,13 @@ select object.id,
        object.upload_status,
        object.last_accessed_at,
        object.created_at,
-       object.updated_at
+       object.updated_at,
+       object.legal_hold,
+       object.retention_mode,
+       object.retain_until,
+       object.replication_status,
+       object.tier,
+       bucket.replica as bucket_replica
 from storage.objects as object
          inner join storage.buckets as bucket on object.bucket_id = bucket.id
 where object.id = $1
@@ -154,18 +317,25 @@ limit 1
 `
 
 type ObjectGetByIdWithBucketNameRow struct {
-	ID             string
-	Version        int32
-	BucketID       string
-	BucketName     string
-	Name           string
-	MimeType       string
-	Size           int64
-	Metadata       []byte
-	UploadStatus   string
-	LastAccessedAt *time.Time
-	CreatedAt      time.Time
-	UpdatedAt      *time.Time
+	ID                  string
+	Version             int32
+	BucketID            string
+	BucketName          string
+	BucketStoragePrefix string
+	Name                string
+	MimeType            string
+	Size                int64
+	Metadata            []byte
+	UploadStatus        string
+	LastAccessedAt      *time.Time
+	CreatedAt           time.Time
+	UpdatedAt           *time.Time
+	LegalHold           bool
+	RetentionMode       *string
+	RetainUntil         *time.Time
+	ReplicationStatus   *string
+	Tier                string
+	BucketReplica       *string
 }
 
 func (q *Queries) ObjectGetByIdWithBucketName(ctx context.Context, id string) (*ObjectGetByIdWithBucketNameRow, error) {
@@ -176,6 +346,7 @@ func (q *Queries) ObjectGetByIdWithBucketName(ctx context.Context, id string) (*
 		&i.Version,
 		&i.BucketID,
 		&i.BucketName,
+		&i.BucketStoragePrefix,
 		&i.Name,
 		&i.MimeType,
 		&i.Size,
@@ -184,6 +355,12 @@ func (q *Queries) ObjectGetByIdWithBucketName(ctx context.Context, id string) (*
 		&i.LastAccessedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+		&i.ReplicationStatus,
+		&i.Tier,
+		&i.BucketReplica,
 	)
 	return &i, err
 }
@@ -199,7 +376,13 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where name = $1
 limit 1
@@ -220,61 +403,137 @@ func (q *Queries) ObjectGetByName(ctx context.Context, name string) (*StorageObj
 		&i.LastAccessedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+		&i.ReplicationStatus,
+		&i.ReplicatedAt,
+		&i.Tier,
 	)
 	return &i, err
 }
 
-const objectSearchByBucketIdAndObjectPath = `-- name: ObjectSearchByBucketIdAndObjectPath :many
+const objectGetLockForUpdate = `-- name: ObjectGetLockForUpdate :one
+select id,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where id = $1
+limit 1 for update
+`
+
+type ObjectGetLockForUpdateRow struct {
+	ID            string
+	LegalHold     bool
+	RetentionMode *string
+	RetainUntil   *time.Time
+}
+
+// holds an object until the transaction ends, so that no legal hold or retention is placed on it meanwhile
+func (q *Queries) ObjectGetLockForUpdate(ctx context.Context, id string) (*ObjectGetLockForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, objectGetLockForUpdate, id)
+	var i ObjectGetLockForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+	)
+	return &i, err
+}
+
+const objectGetLockedByBucketId = `-- name: ObjectGetLockedByBucketId :one
+select id,
+       name,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where bucket_id = $1
+  and (legal_hold or
+       (retain_until > now() and (retention_mode = 'compliance' or not $2::boolean)))
+order by legal_hold desc, id
+limit 1
+`
+
+type ObjectGetLockedByBucketIdParams struct {
+	BucketID         string
+	BypassGovernance bool
+}
+
+type ObjectGetLockedByBucketIdRow struct {
+	ID            string
+	Name          string
+	LegalHold     bool
+	RetentionMode *string
+	RetainUntil   *time.Time
+}
+
+// returns an object of the bucket that cannot be deleted yet, legal holds first. governance retention does not count
+// when it is bypassed
+func (q *Queries) ObjectGetLockedByBucketId(ctx context.Context, arg *ObjectGetLockedByBucketIdParams) (*ObjectGetLockedByBucketIdRow, error) {
+	row := q.db.QueryRow(ctx, objectGetLockedByBucketId, arg.BucketID, arg.BypassGovernance)
+	var i ObjectGetLockedByBucketIdRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.LegalHold,
+		&i.RetentionMode,
+		&i.RetainUntil,
+	)
+	return &i, err
+}
+
+const objectListArchivable = `-- name: ObjectListArchivable :many
 select object.id,
-       object.version,
        object.bucket_id,
+       bucket.storage_prefix as bucket_storage_prefix,
        object.name,
-       object.mime_type,
-       object.size,
-       object.metadata,
-       object.upload_status,
-       object.last_accessed_at,
-       object.created_at,
-       object.updated_at
+       object.size
 from storage.objects as object
-where object.bucket_id = $1
-  and object.name ilike '%' || $2::text || '%'
-limit $4 offset $3
+         inner join storage.buckets as bucket on bucket.id = object.bucket_id
+where bucket.archive_after_days is not null
+  and not bucket.disabled
+  and not bucket.locked
+  and object.upload_status = 'completed'
+  and object.tier = 'hot'
+  and coalesce(object.last_accessed_at, object.created_at) < now() - make_interval(days => bucket.archive_after_days)
+  and object.id > $1
+order by object.id
+limit $2
 `
 
-type ObjectSearchByBucketIdAndObjectPathParams struct {
-	BucketID   string
-	ObjectPath string
-	Offset     int32
-	Limit      int32
+type ObjectListArchivableParams struct {
+	AfterID string
+	Limit   int32
 }
 
-func (q *Queries) ObjectSearchByBucketIdAndObjectPath(ctx context.Context, arg *ObjectSearchByBucketIdAndObjectPathParams) ([]*StorageObject, error) {
-	rows, err := q.db.Query(ctx, objectSearchByBucketIdAndObjectPath,
-		arg.BucketID,
-		arg.ObjectPath,
-		arg.Offset,
-		arg.Limit,
-	)
+type ObjectListArchivableRow struct {
+	ID                  string
+	BucketID            string
+	BucketStoragePrefix string
+	Name                string
+	Size                int64
+}
+
+// completed objects in the hot tier that were not accessed for the archive_after_days of their bucket, objects never
+// accessed count from their creation
+func (q *Queries) ObjectListArchivable(ctx context.Context, arg *ObjectListArchivableParams) ([]*ObjectListArchivableRow, error) {
+	rows, err := q.db.Query(ctx, objectListArchivable, arg.AfterID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []*StorageObject
+	var items []*ObjectListArchivableRow
 	for rows.Next() {
-		var i StorageObject
+		var i ObjectListArchivableRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.Version,
 			&i.BucketID,
+			&i.BucketStoragePrefix,
 			&i.Name,
-			&i.MimeType,
 			&i.Size,
-			&i.Metadata,
-			&i.UploadStatus,
-			&i.LastAccessedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -286,59 +545,7 @@ func (q *Queries) ObjectSearchByBucketIdAndObjectPath(ctx context.Context, arg *
 	return items, nil
 }
 
-const objectUpdate = `-- name: ObjectUpdate :exec
-update storage.objects
-set size      = coalesce($1, size),
-    mime_type = coalesce($2, mime_type),
-    metadata  = coalesce($3, metadata)
-where id = $4
-`
-
-type ObjectUpdateParams struct {
-	Size     *int64
-	MimeType *string
-	Metadata []byte
-	ID       string
-}
-
-func (q *Queries) ObjectUpdate(ctx context.Context, arg *ObjectUpdateParams) error {
-	_, err := q.db.Exec(ctx, objectUpdate,
-		arg.Size,
-		arg.MimeType,
-		arg.Metadata,
-		arg.ID,
-	)
-	return err
-}
-
-const objectUpdateLastAccessedAt = `-- name: ObjectUpdateLastAccessedAt :exec
-update storage.objects
-set last_accessed_at = now()
-where id = $1
-`
-
-func (q *Queries) ObjectUpdateLastAccessedAt(ctx context.Context, id string) error {
-	_, err := q.db.Exec(ctx, objectUpdateLastAccessedAt, id)
-	return err
-}
-
-const objectUpdateUploadStatus = `-- name: ObjectUpdateUploadStatus :exec
-update storage.objects
-set upload_status = $1
-where id = $2
-`
-
-type ObjectUpdateUploadStatusParams struct {
-	UploadStatus string
-	ID           string
-}
-
-func (q *Queries) ObjectUpdateUploadStatus(ctx context.Context, arg *ObjectUpdateUploadStatusParams) error {
-	_, err := q.db.Exec(ctx, objectUpdateUploadStatus, arg.UploadStatus, arg.ID)
-	return err
-}
-
-const objectsListBucketIdPaged = `-- name: ObjectsListBucketIdPaged :many
+const objectListByBucketIdAfterId = `-- name: ObjectListByBucketIdAfterId :many
 select id,
        bucket_id,
        name,
@@ -348,19 +555,24 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until
 from storage.objects
 where bucket_id = $1
-limit $3 offset $2
+  and id > $2
+order by id
+limit $3
 `
 
-type ObjectsListBucketIdPagedParams struct {
+type ObjectListByBucketIdAfterIdParams struct {
 	BucketID string
-	Offset   int32
+	AfterID  string
 	Limit    int32
 }
 
-type ObjectsListBucketIdPagedRow struct {
+type ObjectListByBucketIdAfterIdRow struct {
 	ID             string
 	BucketID       string
 	Name           string
@@ -371,17 +583,21 @@ type ObjectsListBucketIdPagedRow struct {
 	LastAccessedAt *time.Time
 	CreatedAt      time.Time
 	UpdatedAt      *time.Time
+	LegalHold      bool
+	RetentionMode  *string
+	RetainUntil    *time.Time
 }
 
-func (q *Queries) ObjectsListBucketIdPaged(ctx context.Context, arg *ObjectsListBucketIdPagedParams) ([]*ObjectsListBucketIdPagedRow, error) {
-	rows, err := q.db.Query(ctx, objectsListBucketIdPaged, arg.BucketID, arg.Offset, arg.Limit)
+// pages through the objects of a bucket by id, rows deleted between pages never shift the next page
+func (q *Queries) ObjectListByBucketIdAfterId(ctx context.Context, arg *ObjectListByBucketIdAfterIdParams) ([]*ObjectListByBucketIdAfterIdRow, error) {
+	rows, err := q.db.Query(ctx, objectListByBucketIdAfterId, arg.BucketID, arg.AfterID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []*ObjectsListBucketIdPagedRow
+	var items []*ObjectListByBucketIdAfterIdRow
 	for rows.Next() {
-		var i ObjectsListBucketIdPagedRow
+		var i ObjectListByBucketIdAfterIdRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.BucketID,
@@ -393,6 +609,75 @@ func (q *Queries) ObjectsListBucketIdPaged(ctx context.Context, arg *ObjectsList
 			&i.LastAccessedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectListByBucketIdAfterName = `-- name: ObjectListByBucketIdAfterName :many
+select id,
+       name,
+       size,
+       upload_status,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where bucket_id = $1
+  and name collate "C" > $2::text collate "C"
+order by name collate "C"
+limit $3
+`
+
+type ObjectListByBucketIdAfterNameParams struct {
+	BucketID  string
+	AfterName string
+	Limit     int32
+}
+
+type ObjectListByBucketIdAfterNameRow struct {
+	ID            string
+	Name          string
+	Size          int64
+	UploadStatus  string
+	CreatedAt     time.Time
+	UpdatedAt     *time.Time
+	LegalHold     bool
+	RetentionMode *string
+	RetainUntil   *time.Time
+}
+
+// pages through the objects of a bucket in the byte order of their names, the order s3 lists keys in
+func (q *Queries) ObjectListByBucketIdAfterName(ctx context.Context, arg *ObjectListByBucketIdAfterNameParams) ([]*ObjectListByBucketIdAfterNameRow, error) {
+	rows, err := q.db.Query(ctx, objectListByBucketIdAfterName, arg.BucketID, arg.AfterName, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ObjectListByBucketIdAfterNameRow
+	for rows.Next() {
+		var i ObjectListByBucketIdAfterNameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.UploadStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
 		); err != nil {
 			return nil, err
 		}
@@ -403,3 +688,689 @@ func (q *Queries) ObjectsListBucketIdPaged(ctx context.Context, arg *ObjectsList
 	}
 	return items, nil
 }
+
+const objectListByBucketIdAndIds = `-- name: ObjectListByBucketIdAndIds :many
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = $1
+  and id = any ($2::text[])
+`
+
+type ObjectListByBucketIdAndIdsParams struct {
+	BucketID string
+	Ids      []string
+}
+
+func (q *Queries) ObjectListByBucketIdAndIds(ctx context.Context, arg *ObjectListByBucketIdAndIdsParams) ([]*StorageObject, error) {
+	rows, err := q.db.Query(ctx, objectListByBucketIdAndIds, arg.BucketID, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*StorageObject
+	for rows.Next() {
+		var i StorageObject
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.BucketID,
+			&i.Name,
+			&i.MimeType,
+			&i.Size,
+			&i.Metadata,
+			&i.UploadStatus,
+			&i.LastAccessedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+			&i.ReplicationStatus,
+			&i.ReplicatedAt,
+			&i.Tier,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectListByBucketIdPaged = `-- name: ObjectListByBucketIdPaged :many
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = $1
+  -- objects must carry every tag_keys[i] = tag_values[i] pair, no pairs matches every object
+  and coalesce(cardinality($2::text[]), 0) = (select count(1)
+                                                               from storage.object_tags as tag
+                                                               where tag.object_id = objects.id
+                                                                 and (tag.key, tag.value) in
+                                                                     (select unnest($2::text[]),
+                                                                             unnest($3::text[])))
+order by name
+limit $5 offset $4
+`
+
+type ObjectListByBucketIdPagedParams struct {
+	BucketID  string
+	TagKeys   []string
+	TagValues []string
+	Offset    int32
+	Limit     int32
+}
+
+func (q *Queries) ObjectListByBucketIdPaged(ctx context.Context, arg *ObjectListByBucketIdPagedParams) ([]*StorageObject, error) {
+	rows, err := q.db.Query(ctx, objectListByBucketIdPaged,
+		arg.BucketID,
+		arg.TagKeys,
+		arg.TagValues,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*StorageObject
+	for rows.Next() {
+		var i StorageObject
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.BucketID,
+			&i.Name,
+			&i.MimeType,
+			&i.Size,
+			&i.Metadata,
+			&i.UploadStatus,
+			&i.LastAccessedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+			&i.ReplicationStatus,
+			&i.ReplicatedAt,
+			&i.Tier,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectListCompletedByBucketIdAndPrefix = `-- name: ObjectListCompletedByBucketIdAndPrefix :many
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = $1
+  and upload_status = 'completed'
+  and starts_with(name, $2::text)
+  and name collate "C" > $3::text
+order by name collate "C"
+limit $4
+`
+
+type ObjectListCompletedByBucketIdAndPrefixParams struct {
+	BucketID   string
+	Prefix     string
+	StartAfter string
+	Limit      int32
+}
+
+func (q *Queries) ObjectListCompletedByBucketIdAndPrefix(ctx context.Context, arg *ObjectListCompletedByBucketIdAndPrefixParams) ([]*StorageObject, error) {
+	rows, err := q.db.Query(ctx, objectListCompletedByBucketIdAndPrefix,
+		arg.BucketID,
+		arg.Prefix,
+		arg.StartAfter,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*StorageObject
+	for rows.Next() {
+		var i StorageObject
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.BucketID,
+			&i.Name,
+			&i.MimeType,
+			&i.Size,
+			&i.Metadata,
+			&i.UploadStatus,
+			&i.LastAccessedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+			&i.ReplicationStatus,
+			&i.ReplicatedAt,
+			&i.Tier,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectListNamesByBucketIdAndNames = `-- name: ObjectListNamesByBucketIdAndNames :many
+select name
+from storage.objects
+where bucket_id = $1
+  and name = any ($2::text[])
+`
+
+type ObjectListNamesByBucketIdAndNamesParams struct {
+	BucketID string
+	Names    []string
+}
+
+func (q *Queries) ObjectListNamesByBucketIdAndNames(ctx context.Context, arg *ObjectListNamesByBucketIdAndNamesParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, objectListNamesByBucketIdAndNames, arg.BucketID, arg.Names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectListPendingReplicationByBucketIdAfterId = `-- name: ObjectListPendingReplicationByBucketIdAfterId :many
+select id
+from storage.objects
+where bucket_id = $1
+  and replication_status = 'pending'
+  and id > $2
+order by id
+limit $3
+`
+
+type ObjectListPendingReplicationByBucketIdAfterIdParams struct {
+	BucketID string
+	AfterID  string
+	Limit    int32
+}
+
+func (q *Queries) ObjectListPendingReplicationByBucketIdAfterId(ctx context.Context, arg *ObjectListPendingReplicationByBucketIdAfterIdParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, objectListPendingReplicationByBucketIdAfterId, arg.BucketID, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectMergeMetadata = `-- name: ObjectMergeMetadata :exec
+update storage.objects
+set metadata = coalesce(metadata, '{}'::jsonb) || $1::jsonb
+where id = $2
+`
+
+type ObjectMergeMetadataParams struct {
+	Metadata []byte
+	ID       string
+}
+
+// merges at the top level of the document in one statement so concurrent processors do not drop each other's keys
+func (q *Queries) ObjectMergeMetadata(ctx context.Context, arg *ObjectMergeMetadataParams) error {
+	_, err := q.db.Exec(ctx, objectMergeMetadata, arg.Metadata, arg.ID)
+	return err
+}
+
+const objectResetReplicationByBucketId = `-- name: ObjectResetReplicationByBucketId :exec
+update storage.objects
+set replication_status = 'pending'
+where bucket_id = $1
+  and replication_status = 'replicated'
+`
+
+// replicated objects of a bucket that moved to another storage prefix have no copy under it on the replica yet
+func (q *Queries) ObjectResetReplicationByBucketId(ctx context.Context, bucketID string) error {
+	_, err := q.db.Exec(ctx, objectResetReplicationByBucketId, bucketID)
+	return err
+}
+
+const objectRestore = `-- name: ObjectRestore :one
+insert into storage.objects
+    (id, bucket_id, name, mime_type, size, metadata, upload_status, legal_hold, retention_mode, retain_until)
+values ($1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9,
+        $10)
+returning id
+`
+
+type ObjectRestoreParams struct {
+	ID            *string
+	BucketID      string
+	Name          string
+	MimeType      string
+	Size          int64
+	Metadata      []byte
+	UploadStatus  string
+	LegalHold     bool
+	RetentionMode *string
+	RetainUntil   *time.Time
+}
+
+// recreates an archived object along with its legal hold and retention, a null id gives it a new one
+func (q *Queries) ObjectRestore(ctx context.Context, arg *ObjectRestoreParams) (string, error) {
+	row := q.db.QueryRow(ctx, objectRestore,
+		arg.ID,
+		arg.BucketID,
+		arg.Name,
+		arg.MimeType,
+		arg.Size,
+		arg.Metadata,
+		arg.UploadStatus,
+		arg.LegalHold,
+		arg.RetentionMode,
+		arg.RetainUntil,
+	)
+	var id string
+	err := row.Scan(&id)
+	return id, err
+}
+
+const objectSearchByBucketIdAndObjectPath = `-- name: ObjectSearchByBucketIdAndObjectPath :many
+select object.id,
+       object.version,
+       object.bucket_id,
+       object.name,
+       object.mime_type,
+       object.size,
+       object.metadata,
+       object.upload_status,
+       object.last_accessed_at,
+       object.created_at,
+       object.updated_at,
+       object.legal_hold,
+       object.retention_mode,
+       object.retain_until,
+       object.replication_status,
+       object.replicated_at,
+       object.tier
+from storage.objects as object
+where object.bucket_id = $1
+  and object.name ilike '%' || $2::text || '%'
+  and coalesce(cardinality($3::text[]), 0) = (select count(1)
+                                                               from storage.object_tags as tag
+                                                               where tag.object_id = object.id
+                                                                 and (tag.key, tag.value) in
+                                                                     (select unnest($3::text[]),
+                                                                             unnest($4::text[])))
+limit $6 offset $5
+`
+
+type ObjectSearchByBucketIdAndObjectPathParams struct {
+	BucketID   string
+	ObjectPath string
+	TagKeys    []string
+	TagValues  []string
+	Offset     int32
+	Limit      int32
+}
+
+func (q *Queries) ObjectSearchByBucketIdAndObjectPath(ctx context.Context, arg *ObjectSearchByBucketIdAndObjectPathParams) ([]*StorageObject, error) {
+	rows, err := q.db.Query(ctx, objectSearchByBucketIdAndObjectPath,
+		arg.BucketID,
+		arg.ObjectPath,
+		arg.TagKeys,
+		arg.TagValues,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*StorageObject
+	for rows.Next() {
+		var i StorageObject
+		if err := rows.Scan(
+			&i.ID,
+			&i.Version,
+			&i.BucketID,
+			&i.Name,
+			&i.MimeType,
+			&i.Size,
+			&i.Metadata,
+			&i.UploadStatus,
+			&i.LastAccessedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LegalHold,
+			&i.RetentionMode,
+			&i.RetainUntil,
+			&i.ReplicationStatus,
+			&i.ReplicatedAt,
+			&i.Tier,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const objectUpdate = `-- name: ObjectUpdate :exec
+update storage.objects
+set size      = coalesce($1, size),
+    mime_type = coalesce($2, mime_type),
+    metadata  = coalesce($3, metadata)
+where id = $4
+`
+
+type ObjectUpdateParams struct {
+	Size     *int64
+	MimeType *string
+	Metadata []byte
+	ID       string
+}
+
+func (q *Queries) ObjectUpdate(ctx context.Context, arg *ObjectUpdateParams) error {
+	_, err := q.db.Exec(ctx, objectUpdate,
+		arg.Size,
+		arg.MimeType,
+		arg.Metadata,
+		arg.ID,
+	)
+	return err
+}
+
+const objectUpdateLastAccessedAt = `-- name: ObjectUpdateLastAccessedAt :exec
+update storage.objects
+set last_accessed_at = now()
+where id = $1
+`
+
+func (q *Queries) ObjectUpdateLastAccessedAt(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, objectUpdateLastAccessedAt, id)
+	return err
+}
+
+const objectUpdateLegalHold = `-- name: ObjectUpdateLegalHold :execrows
+update storage.objects
+set legal_hold = $1
+where id = $2
+  and (not legal_hold or $1::boolean or $3::boolean)
+`
+
+type ObjectUpdateLegalHoldParams struct {
+	LegalHold    bool
+	ID           string
+	AllowRelease bool
+}
+
+// a hold is only released when allowed, no rows means a hold placed since the object was read was not released
+func (q *Queries) ObjectUpdateLegalHold(ctx context.Context, arg *ObjectUpdateLegalHoldParams) (int64, error) {
+	result, err := q.db.Exec(ctx, objectUpdateLegalHold, arg.LegalHold, arg.ID, arg.AllowRelease)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const objectUpdateMetadata = `-- name: ObjectUpdateMetadata :execrows
+update storage.objects
+set mime_type = coalesce($1, mime_type),
+    metadata  = case
+                    when $2::jsonb is null then metadata
+                    when $3::boolean then coalesce(metadata, '{}'::jsonb) || $2::jsonb
+                    else $2::jsonb
+        end
+where id = $4
+  and version = $5
+`
+
+type ObjectUpdateMetadataParams struct {
+	MimeType      *string
+	Metadata      []byte
+	MergeMetadata bool
+	ID            string
+	Version       int32
+}
+
+// only updates the object when it is still at the version the update was based on, no rows means it changed since
+func (q *Queries) ObjectUpdateMetadata(ctx context.Context, arg *ObjectUpdateMetadataParams) (int64, error) {
+	result, err := q.db.Exec(ctx, objectUpdateMetadata,
+		arg.MimeType,
+		arg.Metadata,
+		arg.MergeMetadata,
+		arg.ID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const objectUpdateReplicationStatus = `-- name: ObjectUpdateReplicationStatus :exec
+update storage.objects
+set replication_status = $1::text,
+    replicated_at      = case
+                             when $1::text = 'replicated' then now()
+                             else replicated_at
+        end
+where id = $2
+  and replication_status is not null
+`
+
+type ObjectUpdateReplicationStatusParams struct {
+	ReplicationStatus string
+	ID                string
+}
+
+// objects of buckets whose replica was removed since keep no replication status
+func (q *Queries) ObjectUpdateReplicationStatus(ctx context.Context, arg *ObjectUpdateReplicationStatusParams) error {
+	_, err := q.db.Exec(ctx, objectUpdateReplicationStatus, arg.ReplicationStatus, arg.ID)
+	return err
+}
+
+const objectUpdateRetention = `-- name: ObjectUpdateRetention :exec
+update storage.objects
+set retention_mode = $1,
+    retain_until   = $2
+where id = $3
+`
+
+type ObjectUpdateRetentionParams struct {
+	RetentionMode *string
+	RetainUntil   *time.Time
+	ID            string
+}
+
+func (q *Queries) ObjectUpdateRetention(ctx context.Context, arg *ObjectUpdateRetentionParams) error {
+	_, err := q.db.Exec(ctx, objectUpdateRetention, arg.RetentionMode, arg.RetainUntil, arg.ID)
+	return err
+}
+
+const objectUpdateScannedUploadStatus = `-- name: ObjectUpdateScannedUploadStatus :execrows
+update storage.objects as object
+set upload_status      = $1,
+    replication_status = case
+                             when $1 = 'completed' and bucket.replica is not null
+                                 then 'pending'
+                             else object.replication_status
+        end
+from storage.buckets as bucket
+where object.id = $2
+  and bucket.id = object.bucket_id
+  and object.upload_status = 'scanning'
+  and object.version = $3
+`
+
+type ObjectUpdateScannedUploadStatusParams struct {
+	UploadStatus string
+	ID           string
+	Version      int32
+}
+
+// records the verdict of a malware scan only for the content that was scanned, no rows means the object left scanning
+// or was overwritten since. retention and tier were already applied when the content entered scanning
+func (q *Queries) ObjectUpdateScannedUploadStatus(ctx context.Context, arg *ObjectUpdateScannedUploadStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, objectUpdateScannedUploadStatus, arg.UploadStatus, arg.ID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const objectUpdateTier = `-- name: ObjectUpdateTier :execrows
+update storage.objects
+set tier = $1
+where id = $2
+  and tier = $3
+`
+
+type ObjectUpdateTierParams struct {
+	Tier     string
+	ID       string
+	FromTier string
+}
+
+// moves an object between tiers only from the tier it is expected in, so a transition that raced with new content or
+// another transition changes nothing
+func (q *Queries) ObjectUpdateTier(ctx context.Context, arg *ObjectUpdateTierParams) (int64, error) {
+	result, err := q.db.Exec(ctx, objectUpdateTier, arg.Tier, arg.ID, arg.FromTier)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const objectUpdateUploadStatus = `-- name: ObjectUpdateUploadStatus :exec
+update storage.objects as object
+set upload_status      = $1,
+    tier               = case
+                             when $1 in ('scanning', 'completed') then 'hot'
+                             else object.tier
+        end,
+    replication_status = case
+                             when $1 = 'completed' and bucket.replica is not null
+                                 then 'pending'
+                             else object.replication_status
+        end,
+    retention_mode = case
+                         when $1 in ('scanning', 'completed') and
+                              (object.retain_until is null or object.retain_until < now()) and
+                              bucket.default_retention_mode is not null
+                             then bucket.default_retention_mode
+                         else object.retention_mode
+        end,
+    retain_until   = case
+                         when $1 in ('scanning', 'completed') and
+                              (object.retain_until is null or object.retain_until < now()) and
+                              bucket.default_retention_mode is not null
+                             then now() + make_interval(days => bucket.default_retention_days)
+                         else object.retain_until
+        end
+from storage.buckets as bucket
+where object.id = $2
+  and bucket.id = object.bucket_id
+`
+
+type ObjectUpdateUploadStatusParams struct {
+	UploadStatus string
+	ID           string
+}
+
+// new content takes the default retention of its bucket once it is in storage, content still under retention keeps it.
+// new content is always stored in the hot tier
+func (q *Queries) ObjectUpdateUploadStatus(ctx context.Context, arg *ObjectUpdateUploadStatusParams) error {
+	_, err := q.db.Exec(ctx, objectUpdateUploadStatus, arg.UploadStatus, arg.ID)
+	return err
+}
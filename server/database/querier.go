@@ -9,6 +9,15 @@ import (
 )
 
 type Querier interface {
+	ApiKeyCreate(ctx context.Context, arg *ApiKeyCreateParams) (string, error)
+	ApiKeyGetActiveByKeyHash(ctx context.Context, keyHash string) (*StorageApiKey, error)
+	ApiKeyGetById(ctx context.Context, id string) (*StorageApiKey, error)
+	ApiKeyListAll(ctx context.Context) ([]*StorageApiKey, error)
+	ApiKeyRevoke(ctx context.Context, id string) (int64, error)
+	ApiKeyUpdateLastUsedAt(ctx context.Context, id string) error
+	AuditEventCreate(ctx context.Context, arg *AuditEventCreateParams) error
+	// clears the stale prefix once its content is deleted, unless it was replaced since
+	BucketClearStaleStoragePrefix(ctx context.Context, arg *BucketClearStaleStoragePrefixParams) error
 	BucketCount(ctx context.Context) (int64, error)
 	BucketCreate(ctx context.Context, arg *BucketCreateParams) (string, error)
 	BucketDelete(ctx context.Context, id string) error
@@ -16,25 +25,120 @@ type Querier interface {
 	BucketEnable(ctx context.Context, id string) error
 	BucketGetById(ctx context.Context, id string) (*StorageBucket, error)
 	BucketGetByName(ctx context.Context, name string) (*StorageBucket, error)
+	// holds the lock of a bucket until the transaction ends, so that it cannot be released or taken over meanwhile
+	BucketGetLockForUpdate(ctx context.Context, id string) (*BucketGetLockForUpdateRow, error)
 	BucketGetObjectCountById(ctx context.Context, id string) (*BucketGetObjectCountByIdRow, error)
 	BucketGetSizeById(ctx context.Context, id string) (*BucketGetSizeByIdRow, error)
 	BucketListAll(ctx context.Context) ([]*StorageBucket, error)
+	// other buckets whose name, id or content in storage a prefix could read
+	BucketListClaimingPrefix(ctx context.Context, arg *BucketListClaimingPrefixParams) ([]*BucketListClaimingPrefixRow, error)
+	BucketListExpiredLocks(ctx context.Context, arg *BucketListExpiredLocksParams) ([]*BucketListExpiredLocksRow, error)
 	BucketListPaginated(ctx context.Context, arg *BucketListPaginatedParams) ([]*StorageBucket, error)
-	BucketLock(ctx context.Context, arg *BucketLockParams) error
+	BucketListSizes(ctx context.Context) ([]*BucketListSizesRow, error)
+	// locks a bucket only when no other operation holds it, no rows means a concurrent request locked it first
+	BucketLock(ctx context.Context, arg *BucketLockParams) (int64, error)
+	BucketLockHeartbeat(ctx context.Context, arg *BucketLockHeartbeatParams) (int64, error)
+	BucketLockRecoveryRetry(ctx context.Context, arg *BucketLockRecoveryRetryParams) error
+	// switches the bucket over to the content copied under its id, the content under its old prefix is left to delete
+	BucketRelocate(ctx context.Context, id string) error
+	// only buckets stored under their id can be renamed, the name of the others is part of the keys of their content
+	BucketRename(ctx context.Context, arg *BucketRenameParams) error
+	// recreates an archived bucket, a null id gives it a new one
+	BucketRestore(ctx context.Context, arg *BucketRestoreParams) (string, error)
 	BucketSearch(ctx context.Context, name string) ([]*StorageBucket, error)
+	BucketSetStaleStoragePrefix(ctx context.Context, arg *BucketSetStaleStoragePrefixParams) error
 	BucketUnlock(ctx context.Context, id string) error
+	// no rows means the bucket was unlocked or locked by another job since the lock was read
+	BucketUnlockByLockJobId(ctx context.Context, arg *BucketUnlockByLockJobIdParams) (int64, error)
+	// releases an expired lock only while the same job still holds it, no rows means it was unlocked or locked again since
+	BucketUnlockExpired(ctx context.Context, arg *BucketUnlockExpiredParams) (int64, error)
 	BucketUpdate(ctx context.Context, arg *BucketUpdateParams) error
+	ObjectBatchCreate(ctx context.Context, arg *ObjectBatchCreateParams) (string, error)
+	ObjectBatchGetByBucketIdAndId(ctx context.Context, arg *ObjectBatchGetByBucketIdAndIdParams) (*StorageObjectBatch, error)
+	ObjectBatchGetById(ctx context.Context, id string) (*StorageObjectBatch, error)
+	ObjectBatchItemCountByBatchId(ctx context.Context, batchID string) ([]*ObjectBatchItemCountByBatchIdRow, error)
+	ObjectBatchItemCreateByPrefix(ctx context.Context, arg *ObjectBatchItemCreateByPrefixParams) (int64, error)
+	// unnest in the select list pairs the ids and names up by position
+	ObjectBatchItemCreateMany(ctx context.Context, arg *ObjectBatchItemCreateManyParams) error
+	ObjectBatchItemListByBatchId(ctx context.Context, arg *ObjectBatchItemListByBatchIdParams) ([]*StorageObjectBatchItem, error)
+	ObjectBatchItemListPendingAfterObjectId(ctx context.Context, arg *ObjectBatchItemListPendingAfterObjectIdParams) ([]*StorageObjectBatchItem, error)
+	ObjectBatchItemUpdateStatus(ctx context.Context, arg *ObjectBatchItemUpdateStatusParams) error
+	ObjectBatchUpdateJobId(ctx context.Context, arg *ObjectBatchUpdateJobIdParams) error
+	ObjectCountByUploadStatus(ctx context.Context, uploadStatus string) (int64, error)
+	ObjectCountCompletedByBucketIdAndPrefix(ctx context.Context, arg *ObjectCountCompletedByBucketIdAndPrefixParams) (int64, error)
+	ObjectCountPendingReplicationByReplica(ctx context.Context) ([]*ObjectCountPendingReplicationByReplicaRow, error)
 	ObjectCreate(ctx context.Context, arg *ObjectCreateParams) (string, error)
 	ObjectDelete(ctx context.Context, id string) error
+	ObjectDeleteMany(ctx context.Context, ids []string) error
 	ObjectGetByBucketIdAndId(ctx context.Context, arg *ObjectGetByBucketIdAndIdParams) (*StorageObject, error)
+	ObjectGetByBucketIdAndName(ctx context.Context, arg *ObjectGetByBucketIdAndNameParams) (*StorageObject, error)
 	ObjectGetById(ctx context.Context, id string) (*StorageObject, error)
 	ObjectGetByIdWithBucketName(ctx context.Context, id string) (*ObjectGetByIdWithBucketNameRow, error)
 	ObjectGetByName(ctx context.Context, name string) (*StorageObject, error)
+	// holds an object until the transaction ends, so that no legal hold or retention is placed on it meanwhile
+	ObjectGetLockForUpdate(ctx context.Context, id string) (*ObjectGetLockForUpdateRow, error)
+	// returns an object of the bucket that cannot be deleted yet, legal holds first. governance retention does not count
+	// when it is bypassed
+	ObjectGetLockedByBucketId(ctx context.Context, arg *ObjectGetLockedByBucketIdParams) (*ObjectGetLockedByBucketIdRow, error)
+	// completed objects in the hot tier that were not accessed for the archive_after_days of their bucket, objects never
+	// accessed count from their creation
+	ObjectListArchivable(ctx context.Context, arg *ObjectListArchivableParams) ([]*ObjectListArchivableRow, error)
+	// pages through the objects of a bucket by id, rows deleted between pages never shift the next page
+	ObjectListByBucketIdAfterId(ctx context.Context, arg *ObjectListByBucketIdAfterIdParams) ([]*ObjectListByBucketIdAfterIdRow, error)
+	// pages through the objects of a bucket in the byte order of their names, the order s3 lists keys in
+	ObjectListByBucketIdAfterName(ctx context.Context, arg *ObjectListByBucketIdAfterNameParams) ([]*ObjectListByBucketIdAfterNameRow, error)
+	ObjectListByBucketIdAndIds(ctx context.Context, arg *ObjectListByBucketIdAndIdsParams) ([]*StorageObject, error)
+	ObjectListByBucketIdPaged(ctx context.Context, arg *ObjectListByBucketIdPagedParams) ([]*StorageObject, error)
+	ObjectListCompletedByBucketIdAndPrefix(ctx context.Context, arg *ObjectListCompletedByBucketIdAndPrefixParams) ([]*StorageObject, error)
+	// objects past the expire rule of one of their tags, objects a deny_delete rule, a legal hold or retention protects are
+	// kept
+	ObjectListExpiredByTagRules(ctx context.Context, limit int32) ([]*ObjectListExpiredByTagRulesRow, error)
+	ObjectListNamesByBucketIdAndNames(ctx context.Context, arg *ObjectListNamesByBucketIdAndNamesParams) ([]string, error)
+	ObjectListPendingReplicationByBucketIdAfterId(ctx context.Context, arg *ObjectListPendingReplicationByBucketIdAfterIdParams) ([]string, error)
+	// merges at the top level of the document in one statement so concurrent processors do not drop each other's keys
+	ObjectMergeMetadata(ctx context.Context, arg *ObjectMergeMetadataParams) error
+	// replicated objects of a bucket that moved to another storage prefix have no copy under it on the replica yet
+	ObjectResetReplicationByBucketId(ctx context.Context, bucketID string) error
+	// recreates an archived object along with its legal hold and retention, a null id gives it a new one
+	ObjectRestore(ctx context.Context, arg *ObjectRestoreParams) (string, error)
 	ObjectSearchByBucketIdAndObjectPath(ctx context.Context, arg *ObjectSearchByBucketIdAndObjectPathParams) ([]*StorageObject, error)
+	// unnest in the select list pairs the keys and values up by position
+	ObjectTagCreateMany(ctx context.Context, arg *ObjectTagCreateManyParams) error
+	ObjectTagDeleteByObjectId(ctx context.Context, objectID string) error
+	ObjectTagListByObjectId(ctx context.Context, objectID string) ([]*StorageObjectTag, error)
 	ObjectUpdate(ctx context.Context, arg *ObjectUpdateParams) error
 	ObjectUpdateLastAccessedAt(ctx context.Context, id string) error
+	// a hold is only released when allowed, no rows means a hold placed since the object was read was not released
+	ObjectUpdateLegalHold(ctx context.Context, arg *ObjectUpdateLegalHoldParams) (int64, error)
+	// only updates the object when it is still at the version the update was based on, no rows means it changed since
+	ObjectUpdateMetadata(ctx context.Context, arg *ObjectUpdateMetadataParams) (int64, error)
+	// objects of buckets whose replica was removed since keep no replication status
+	ObjectUpdateReplicationStatus(ctx context.Context, arg *ObjectUpdateReplicationStatusParams) error
+	ObjectUpdateRetention(ctx context.Context, arg *ObjectUpdateRetentionParams) error
+	// records the verdict of a malware scan only for the content that was scanned, no rows means the object left scanning
+	// or was overwritten since. retention and tier were already applied when the content entered scanning
+	ObjectUpdateScannedUploadStatus(ctx context.Context, arg *ObjectUpdateScannedUploadStatusParams) (int64, error)
+	// moves an object between tiers only from the tier it is expected in, so a transition that raced with new content or
+	// another transition changes nothing
+	ObjectUpdateTier(ctx context.Context, arg *ObjectUpdateTierParams) (int64, error)
+	// new content takes the default retention of its bucket once it is in storage, content still under retention keeps it.
+	// new content is always stored in the hot tier
 	ObjectUpdateUploadStatus(ctx context.Context, arg *ObjectUpdateUploadStatusParams) error
-	ObjectsListBucketIdPaged(ctx context.Context, arg *ObjectsListBucketIdPagedParams) ([]*ObjectsListBucketIdPagedRow, error)
+	OperationCreate(ctx context.Context, arg *OperationCreateParams) (string, error)
+	// the first state recorded sticks, a late report of a job that was already cancelled does not overwrite it
+	OperationFinishByJobId(ctx context.Context, arg *OperationFinishByJobIdParams) error
+	OperationGetById(ctx context.Context, id string) (*StorageOperation, error)
+	// a job retried after it was discarded works its operation again, the failure it recorded is no longer its outcome
+	OperationReopenByJobId(ctx context.Context, jobID int64) error
+	OperationRequestCancel(ctx context.Context, id string) error
+	TagRuleCreate(ctx context.Context, arg *TagRuleCreateParams) (string, error)
+	TagRuleDelete(ctx context.Context, arg *TagRuleDeleteParams) (int64, error)
+	TagRuleGetByBucketIdAndId(ctx context.Context, arg *TagRuleGetByBucketIdAndIdParams) (*StorageTagRule, error)
+	// returns a deny_delete rule protecting any object of a bucket, no rows means the bucket can be emptied
+	TagRuleGetDenyDeleteByBucketId(ctx context.Context, bucketID string) (*StorageTagRule, error)
+	// returns the deny_delete rule protecting an object, no rows means the object can be deleted
+	TagRuleGetDenyDeleteByObjectId(ctx context.Context, objectID string) (*StorageTagRule, error)
+	TagRuleListByBucketId(ctx context.Context, bucketID string) ([]*StorageTagRule, error)
 }
 
 var _ Querier = (*Queries)(nil)
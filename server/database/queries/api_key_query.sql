@@ -0,0 +1,59 @@
+-- name: ApiKeyCreate :one
+insert into storage.api_keys
+    (name, key_prefix, key_hash, admin)
+values (sqlc.arg('name'),
+        sqlc.arg('key_prefix'),
+        sqlc.arg('key_hash'),
+        sqlc.arg('admin'))
+returning id;
+
+-- name: ApiKeyGetById :one
+select id,
+       name,
+       key_prefix,
+       key_hash,
+       last_used_at,
+       revoked_at,
+       created_at,
+       admin
+from storage.api_keys
+where id = sqlc.arg('id')
+limit 1;
+
+-- name: ApiKeyGetActiveByKeyHash :one
+select id,
+       name,
+       key_prefix,
+       key_hash,
+       last_used_at,
+       revoked_at,
+       created_at,
+       admin
+from storage.api_keys
+where key_hash = sqlc.arg('key_hash')
+  and revoked_at is null
+limit 1;
+
+-- name: ApiKeyListAll :many
+select id,
+       name,
+       key_prefix,
+       key_hash,
+       last_used_at,
+       revoked_at,
+       created_at,
+       admin
+from storage.api_keys
+order by created_at;
+
+-- name: ApiKeyRevoke :execrows
+update storage.api_keys
+set revoked_at = now()
+where id = sqlc.arg('id')
+  and revoked_at is null;
+
+-- name: ApiKeyUpdateLastUsedAt :exec
+update storage.api_keys
+set last_used_at = now()
+where id = sqlc.arg('id')
+  and (last_used_at is null or last_used_at < now() - interval '1 minute');
@@ -0,0 +1,9 @@
+-- name: AuditEventCreate :exec
+insert into storage.audit_events
+    (action, principal, bucket_id, object_id, details, request_id)
+values (sqlc.arg('action'),
+        sqlc.arg('principal'),
+        sqlc.narg('bucket_id'),
+        sqlc.narg('object_id'),
+        sqlc.narg('details'),
+        sqlc.narg('request_id'));
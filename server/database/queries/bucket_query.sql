@@ -1,19 +1,90 @@
 -- name: BucketCreate :one
 insert into storage.buckets
-    (name, allowed_mime_types, max_allowed_object_size, public)
+    (name, allowed_mime_types, max_allowed_object_size, public, processors, default_retention_mode,
+     default_retention_days, replica, archive_after_days)
 values (sqlc.arg('name'),
         sqlc.narg('allowed_mime_types'),
         sqlc.narg('max_allowed_object_size'),
-        sqlc.arg('public'))
+        sqlc.arg('public'),
+        sqlc.narg('processors'),
+        sqlc.narg('default_retention_mode'),
+        sqlc.narg('default_retention_days'),
+        sqlc.narg('replica'),
+        sqlc.narg('archive_after_days'))
+returning id;
+
+-- name: BucketRestore :one
+-- recreates an archived bucket, a null id gives it a new one
+insert into storage.buckets
+    (id, name, allowed_mime_types, max_allowed_object_size, public, processors, default_retention_mode,
+     default_retention_days, replica, archive_after_days)
+values (sqlc.narg('id'),
+        sqlc.arg('name'),
+        sqlc.narg('allowed_mime_types'),
+        sqlc.narg('max_allowed_object_size'),
+        sqlc.arg('public'),
+        sqlc.narg('processors'),
+        sqlc.narg('default_retention_mode'),
+        sqlc.narg('default_retention_days'),
+        sqlc.narg('replica'),
+        sqlc.narg('archive_after_days'))
 returning id;
 
 -- name: BucketUpdate :exec
 update storage.buckets
 set max_allowed_object_size = coalesce(sqlc.narg('max_allowed_object_size'), max_allowed_object_size),
     public                  = coalesce(sqlc.narg('public'), public),
-    allowed_mime_types      = coalesce(sqlc.narg('allowed_mime_types'), allowed_mime_types)
+    allowed_mime_types      = coalesce(sqlc.narg('allowed_mime_types'), allowed_mime_types),
+    processors              = coalesce(sqlc.narg('processors'), processors),
+    default_retention_mode  = case
+                                  when sqlc.arg('update_default_retention')::boolean
+                                      then sqlc.narg('default_retention_mode')
+                                  else default_retention_mode
+        end,
+    default_retention_days  = case
+                                  when sqlc.arg('update_default_retention')::boolean
+                                      then sqlc.narg('default_retention_days')
+                                  else default_retention_days
+        end,
+    replica                 = case
+                                  when sqlc.arg('update_replica')::boolean
+                                      then sqlc.narg('replica')
+                                  else replica
+        end,
+    archive_after_days      = case
+                                  when sqlc.arg('update_archive_after_days')::boolean
+                                      then sqlc.narg('archive_after_days')
+                                  else archive_after_days
+        end
+where id = sqlc.arg('id');
+
+-- name: BucketRename :exec
+-- only buckets stored under their id can be renamed, the name of the others is part of the keys of their content
+update storage.buckets
+set name = sqlc.arg('name')
+where id = sqlc.arg('id')
+  and storage_prefix = id;
+
+-- name: BucketRelocate :exec
+-- switches the bucket over to the content copied under its id, the content under its old prefix is left to delete
+update storage.buckets
+set storage_prefix       = id,
+    stale_storage_prefix = storage_prefix
+where id = sqlc.arg('id')
+  and storage_prefix <> id;
+
+-- name: BucketSetStaleStoragePrefix :exec
+update storage.buckets
+set stale_storage_prefix = sqlc.arg('stale_storage_prefix')
 where id = sqlc.arg('id');
 
+-- name: BucketClearStaleStoragePrefix :exec
+-- clears the stale prefix once its content is deleted, unless it was replaced since
+update storage.buckets
+set stale_storage_prefix = null
+where id = sqlc.arg('id')
+  and stale_storage_prefix = sqlc.arg('stale_storage_prefix');
+
 -- name: BucketDisable :exec
 update storage.buckets
 set disabled = true
@@ -24,21 +95,84 @@ update storage.buckets
 set disabled = false
 where id = sqlc.arg('id');
 
-
--- name: BucketLock :exec
+-- name: BucketLock :execrows
+-- locks a bucket only when no other operation holds it, no rows means a concurrent request locked it first
 update storage.buckets
-set locked      = true,
-    lock_reason = sqlc.arg('lock_reason')::text,
-    locked_at   = now()
-where id = sqlc.arg('id');
+set locked                = true,
+    lock_reason           = sqlc.arg('lock_reason')::text,
+    locked_at             = now(),
+    lock_job_id           = sqlc.narg('lock_job_id'),
+    lock_heartbeat_at     = now(),
+    lock_recovery_retries = 0
+where id = sqlc.arg('id')
+  and not locked;
 
 -- name: BucketUnlock :exec
 update storage.buckets
-set locked      = false,
-    lock_reason = null,
-    locked_at   = null
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
 where id = sqlc.arg('id');
 
+-- name: BucketUnlockByLockJobId :execrows
+-- no rows means the bucket was unlocked or locked by another job since the lock was read
+update storage.buckets
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
+where id = sqlc.arg('id')
+  and locked
+  and (lock_job_id is null or lock_job_id = sqlc.arg('lock_job_id')::bigint);
+
+-- name: BucketUnlockExpired :execrows
+-- releases an expired lock only while the same job still holds it, no rows means it was unlocked or locked again since
+update storage.buckets
+set locked                = false,
+    lock_reason           = null,
+    locked_at             = null,
+    lock_job_id           = null,
+    lock_heartbeat_at     = null,
+    lock_recovery_retries = 0
+where id = sqlc.arg('id')
+  and locked
+  and lock_job_id is not distinct from sqlc.narg('lock_job_id')::bigint;
+
+-- name: BucketLockHeartbeat :execrows
+update storage.buckets
+set lock_heartbeat_at = now()
+where id = sqlc.arg('id')
+  and locked
+  and (lock_job_id is null or lock_job_id = sqlc.arg('lock_job_id')::bigint);
+
+-- name: BucketLockRecoveryRetry :exec
+update storage.buckets
+set lock_heartbeat_at     = now(),
+    lock_recovery_retries = lock_recovery_retries + 1
+where id = sqlc.arg('id')
+  and locked
+  and lock_job_id = sqlc.arg('lock_job_id')::bigint;
+
+-- name: BucketListExpiredLocks :many
+select id,
+       name,
+       lock_reason,
+       locked_at,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries
+from storage.buckets
+where locked
+  and coalesce(lock_heartbeat_at, locked_at) < now() - make_interval(secs => sqlc.arg('lease_timeout')::bigint)
+  and id > sqlc.arg('after_id')
+order by id
+limit sqlc.arg('limit');
+
 -- name: BucketDelete :exec
 delete
 from storage.buckets
@@ -56,11 +190,46 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where id = sqlc.arg('id')
 limit 1;
 
+-- name: BucketGetLockForUpdate :one
+-- holds the lock of a bucket until the transaction ends, so that it cannot be released or taken over meanwhile
+select id,
+       locked,
+       lock_reason,
+       locked_at,
+       lock_job_id,
+       lock_heartbeat_at
+from storage.buckets
+where id = sqlc.arg('id')
+limit 1 for update;
+
+-- name: BucketListClaimingPrefix :many
+-- other buckets whose name, id or content in storage a prefix could read
+select id,
+       name,
+       storage_prefix,
+       stale_storage_prefix
+from storage.buckets
+where id <> sqlc.arg('bucket_id')
+  and (name = sqlc.arg('prefix')
+    or id = sqlc.arg('prefix')
+    or storage_prefix = sqlc.arg('prefix')
+    or stale_storage_prefix = sqlc.arg('prefix'));
+
 -- name: BucketGetByName :one
 select id,
        version,
@@ -73,7 +242,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where name = sqlc.arg('name')
 limit 1;
@@ -90,7 +269,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets;
 
 -- name: BucketListPaginated :many
@@ -105,7 +294,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where id >= sqlc.arg('cursor')
 limit sqlc.arg('limit');
@@ -122,7 +321,17 @@ select id,
        lock_reason,
        locked_at,
        created_at,
-       updated_at
+       updated_at,
+       processors,
+       default_retention_mode,
+       default_retention_days,
+       lock_job_id,
+       lock_heartbeat_at,
+       lock_recovery_retries,
+       storage_prefix,
+       replica,
+       archive_after_days,
+       stale_storage_prefix
 from storage.buckets
 where name ilike '%' || sqlc.arg('name')::text || '%';
 
@@ -141,4 +350,13 @@ group by object.bucket_id, bucket.name;
 select bucket_id as id, count(1) as count
 from storage.objects
 where bucket_id = sqlc.arg('id')
-group by bucket_id;
\ No newline at end of file
+group by bucket_id;
+
+-- name: BucketListSizes :many
+select bucket.id                             as id,
+       bucket.name                           as name,
+       coalesce(sum(object.size), 0)::bigint as size,
+       count(object.id)                      as count
+from storage.buckets as bucket
+         left join storage.objects as object on object.bucket_id = bucket.id
+group by bucket.id, bucket.name;
@@ -0,0 +1,92 @@
+-- name: ObjectBatchCreate :one
+insert into storage.object_batches
+    (bucket_id, operation, options)
+values (sqlc.arg('bucket_id'),
+        sqlc.arg('operation'),
+        sqlc.narg('options'))
+returning id;
+
+-- name: ObjectBatchUpdateJobId :exec
+update storage.object_batches
+set job_id = sqlc.arg('job_id')
+where id = sqlc.arg('id');
+
+-- name: ObjectBatchGetById :one
+select id,
+       bucket_id,
+       operation,
+       options,
+       job_id,
+       created_at
+from storage.object_batches
+where id = sqlc.arg('id')
+limit 1;
+
+-- name: ObjectBatchGetByBucketIdAndId :one
+select id,
+       bucket_id,
+       operation,
+       options,
+       job_id,
+       created_at
+from storage.object_batches
+where bucket_id = sqlc.arg('bucket_id')
+  and id = sqlc.arg('id')
+limit 1;
+
+-- name: ObjectBatchItemCreateMany :exec
+insert into storage.object_batch_items
+    (batch_id, object_id, name)
+-- unnest in the select list pairs the ids and names up by position
+select sqlc.arg('batch_id'), unnest(sqlc.arg('object_ids')::text[]), unnest(sqlc.arg('names')::text[]);
+
+-- name: ObjectBatchItemCreateByPrefix :execrows
+insert into storage.object_batch_items
+    (batch_id, object_id, name)
+select sqlc.arg('batch_id'), id, name
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and upload_status = 'completed'
+  and starts_with(name, sqlc.arg('prefix')::text);
+
+-- name: ObjectBatchItemListPendingAfterObjectId :many
+select batch_id,
+       object_id,
+       name,
+       status,
+       error,
+       updated_at
+from storage.object_batch_items
+where batch_id = sqlc.arg('batch_id')
+  and status = 'pending'
+  and object_id > sqlc.arg('after_object_id')::text
+order by object_id
+limit sqlc.arg('limit');
+
+-- name: ObjectBatchItemListByBatchId :many
+select batch_id,
+       object_id,
+       name,
+       status,
+       error,
+       updated_at
+from storage.object_batch_items
+where batch_id = sqlc.arg('batch_id')
+  and (sqlc.narg('status')::text is null or status = sqlc.narg('status')::text)
+order by object_id
+limit sqlc.arg('limit') offset sqlc.arg('offset');
+
+-- name: ObjectBatchItemCountByBatchId :many
+select status,
+       count(*)::bigint as count
+from storage.object_batch_items
+where batch_id = sqlc.arg('batch_id')
+group by status;
+
+-- name: ObjectBatchItemUpdateStatus :exec
+update storage.object_batch_items
+set status     = sqlc.arg('status'),
+    error      = sqlc.narg('error'),
+    updated_at = now()
+where batch_id = sqlc.arg('batch_id')
+  and object_id = sqlc.arg('object_id');
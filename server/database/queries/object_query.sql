@@ -9,9 +9,144 @@ values (sqlc.arg('bucket_id'),
         sqlc.arg('upload_status'))
 returning id;
 
+-- name: ObjectRestore :one
+-- recreates an archived object along with its legal hold and retention, a null id gives it a new one
+insert into storage.objects
+    (id, bucket_id, name, mime_type, size, metadata, upload_status, legal_hold, retention_mode, retain_until)
+values (sqlc.narg('id'),
+        sqlc.arg('bucket_id'),
+        sqlc.arg('name'),
+        sqlc.arg('mime_type'),
+        sqlc.arg('size'),
+        sqlc.arg('metadata'),
+        sqlc.arg('upload_status'),
+        sqlc.arg('legal_hold'),
+        sqlc.narg('retention_mode'),
+        sqlc.narg('retain_until'))
+returning id;
+
 -- name: ObjectUpdateUploadStatus :exec
+-- new content takes the default retention of its bucket once it is in storage, content still under retention keeps it.
+-- new content is always stored in the hot tier
+update storage.objects as object
+set upload_status      = sqlc.arg('upload_status'),
+    tier               = case
+                             when sqlc.arg('upload_status') in ('scanning', 'completed') then 'hot'
+                             else object.tier
+        end,
+    replication_status = case
+                             when sqlc.arg('upload_status') = 'completed' and bucket.replica is not null
+                                 then 'pending'
+                             else object.replication_status
+        end,
+    retention_mode = case
+                         when sqlc.arg('upload_status') in ('scanning', 'completed') and
+                              (object.retain_until is null or object.retain_until < now()) and
+                              bucket.default_retention_mode is not null
+                             then bucket.default_retention_mode
+                         else object.retention_mode
+        end,
+    retain_until   = case
+                         when sqlc.arg('upload_status') in ('scanning', 'completed') and
+                              (object.retain_until is null or object.retain_until < now()) and
+                              bucket.default_retention_mode is not null
+                             then now() + make_interval(days => bucket.default_retention_days)
+                         else object.retain_until
+        end
+from storage.buckets as bucket
+where object.id = sqlc.arg('id')
+  and bucket.id = object.bucket_id;
+
+-- name: ObjectUpdateScannedUploadStatus :execrows
+-- records the verdict of a malware scan only for the content that was scanned, no rows means the object left scanning
+-- or was overwritten since. retention and tier were already applied when the content entered scanning
+update storage.objects as object
+set upload_status      = sqlc.arg('upload_status'),
+    replication_status = case
+                             when sqlc.arg('upload_status') = 'completed' and bucket.replica is not null
+                                 then 'pending'
+                             else object.replication_status
+        end
+from storage.buckets as bucket
+where object.id = sqlc.arg('id')
+  and bucket.id = object.bucket_id
+  and object.upload_status = 'scanning'
+  and object.version = sqlc.arg('version');
+
+-- name: ObjectUpdateReplicationStatus :exec
+-- objects of buckets whose replica was removed since keep no replication status
+update storage.objects
+set replication_status = sqlc.arg('replication_status')::text,
+    replicated_at      = case
+                             when sqlc.arg('replication_status')::text = 'replicated' then now()
+                             else replicated_at
+        end
+where id = sqlc.arg('id')
+  and replication_status is not null;
+
+-- name: ObjectResetReplicationByBucketId :exec
+-- replicated objects of a bucket that moved to another storage prefix have no copy under it on the replica yet
 update storage.objects
-set upload_status = sqlc.arg('upload_status')
+set replication_status = 'pending'
+where bucket_id = sqlc.arg('bucket_id')
+  and replication_status = 'replicated';
+
+-- name: ObjectListPendingReplicationByBucketIdAfterId :many
+select id
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and replication_status = 'pending'
+  and id > sqlc.arg('after_id')
+order by id
+limit sqlc.arg('limit');
+
+-- name: ObjectCountPendingReplicationByReplica :many
+select bucket.replica::text as replica, count(object.id) as count
+from storage.objects as object
+         inner join storage.buckets as bucket on object.bucket_id = bucket.id
+where object.replication_status = 'pending'
+  and bucket.replica is not null
+group by bucket.replica;
+
+-- name: ObjectListArchivable :many
+-- completed objects in the hot tier that were not accessed for the archive_after_days of their bucket, objects never
+-- accessed count from their creation
+select object.id,
+       object.bucket_id,
+       bucket.storage_prefix as bucket_storage_prefix,
+       object.name,
+       object.size
+from storage.objects as object
+         inner join storage.buckets as bucket on bucket.id = object.bucket_id
+where bucket.archive_after_days is not null
+  and not bucket.disabled
+  and not bucket.locked
+  and object.upload_status = 'completed'
+  and object.tier = 'hot'
+  and coalesce(object.last_accessed_at, object.created_at) < now() - make_interval(days => bucket.archive_after_days)
+  and object.id > sqlc.arg('after_id')
+order by object.id
+limit sqlc.arg('limit');
+
+-- name: ObjectUpdateTier :execrows
+-- moves an object between tiers only from the tier it is expected in, so a transition that raced with new content or
+-- another transition changes nothing
+update storage.objects
+set tier = sqlc.arg('tier')
+where id = sqlc.arg('id')
+  and tier = sqlc.arg('from_tier');
+
+-- name: ObjectUpdateLegalHold :execrows
+-- a hold is only released when allowed, no rows means a hold placed since the object was read was not released
+update storage.objects
+set legal_hold = sqlc.arg('legal_hold')
+where id = sqlc.arg('id')
+  and (not legal_hold or sqlc.arg('legal_hold')::boolean or sqlc.arg('allow_release')::boolean);
+
+-- name: ObjectUpdateRetention :exec
+update storage.objects
+set retention_mode = sqlc.narg('retention_mode'),
+    retain_until   = sqlc.narg('retain_until')
 where id = sqlc.arg('id');
 
 -- name: ObjectUpdateLastAccessedAt :exec
@@ -26,11 +161,34 @@ set size      = coalesce(sqlc.narg('size'), size),
     metadata  = coalesce(sqlc.narg('metadata'), metadata)
 where id = sqlc.arg('id');
 
+-- name: ObjectUpdateMetadata :execrows
+-- only updates the object when it is still at the version the update was based on, no rows means it changed since
+update storage.objects
+set mime_type = coalesce(sqlc.narg('mime_type'), mime_type),
+    metadata  = case
+                    when sqlc.narg('metadata')::jsonb is null then metadata
+                    when sqlc.arg('merge_metadata')::boolean then coalesce(metadata, '{}'::jsonb) || sqlc.narg('metadata')::jsonb
+                    else sqlc.narg('metadata')::jsonb
+        end
+where id = sqlc.arg('id')
+  and version = sqlc.arg('version');
+
+-- name: ObjectMergeMetadata :exec
+-- merges at the top level of the document in one statement so concurrent processors do not drop each other's keys
+update storage.objects
+set metadata = coalesce(metadata, '{}'::jsonb) || sqlc.arg('metadata')::jsonb
+where id = sqlc.arg('id');
+
 -- name: ObjectDelete :exec
 delete
 from storage.objects
 where id = sqlc.arg('id');
 
+-- name: ObjectDeleteMany :exec
+delete
+from storage.objects
+where id = any (sqlc.arg('ids')::text[]);
+
 -- name: ObjectGetById :one
 select id,
        version,
@@ -42,7 +200,13 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where id = sqlc.arg('id')
 limit 1;
@@ -52,6 +216,7 @@ select object.id,
        object.version,
        object.bucket_id,
        bucket.name as bucket_name,
+       bucket.storage_prefix as bucket_storage_prefix,
        object.name,
        object.mime_type,
        object.size,
@@ -59,7 +224,13 @@ select object.id,
        object.upload_status,
        object.last_accessed_at,
        object.created_at,
-       object.updated_at
+       object.updated_at,
+       object.legal_hold,
+       object.retention_mode,
+       object.retain_until,
+       object.replication_status,
+       object.tier,
+       bucket.replica as bucket_replica
 from storage.objects as object
          inner join storage.buckets as bucket on object.bucket_id = bucket.id
 where object.id = sqlc.arg('id')
@@ -76,7 +247,13 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where name = sqlc.arg('name')
 limit 1;
@@ -92,14 +269,98 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where bucket_id = sqlc.arg('bucket_id')
   and id = sqlc.arg('id')
 limit 1;
 
--- name: ObjectsListBucketIdPaged :many
+-- name: ObjectGetByBucketIdAndName :one
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and name = sqlc.arg('name')
+limit 1;
+
+-- name: ObjectGetLockForUpdate :one
+-- holds an object until the transaction ends, so that no legal hold or retention is placed on it meanwhile
+select id,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where id = sqlc.arg('id')
+limit 1 for update;
+
+-- name: ObjectListByBucketIdAfterId :many
+-- pages through the objects of a bucket by id, rows deleted between pages never shift the next page
+select id,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and id > sqlc.arg('after_id')
+order by id
+limit sqlc.arg('limit');
+
+-- name: ObjectListByBucketIdAfterName :many
+-- pages through the objects of a bucket in the byte order of their names, the order s3 lists keys in
 select id,
+       name,
+       size,
+       upload_status,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and name collate "C" > sqlc.arg('after_name')::text collate "C"
+order by name collate "C"
+limit sqlc.arg('limit');
+
+-- name: ObjectListNamesByBucketIdAndNames :many
+select name
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and name = any (sqlc.arg('names')::text[]);
+
+-- name: ObjectListByBucketIdPaged :many
+select id,
+       version,
        bucket_id,
        name,
        mime_type,
@@ -108,11 +369,51 @@ select id,
        upload_status,
        last_accessed_at,
        created_at,
-       updated_at
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
 from storage.objects
 where bucket_id = sqlc.arg('bucket_id')
+  -- objects must carry every tag_keys[i] = tag_values[i] pair, no pairs matches every object
+  and coalesce(cardinality(sqlc.arg('tag_keys')::text[]), 0) = (select count(1)
+                                                               from storage.object_tags as tag
+                                                               where tag.object_id = objects.id
+                                                                 and (tag.key, tag.value) in
+                                                                     (select unnest(sqlc.arg('tag_keys')::text[]),
+                                                                             unnest(sqlc.arg('tag_values')::text[])))
+order by name
 limit sqlc.arg('limit') offset sqlc.arg('offset');
 
+-- name: ObjectListCompletedByBucketIdAndPrefix :many
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and upload_status = 'completed'
+  and starts_with(name, sqlc.arg('prefix')::text)
+  and name collate "C" > sqlc.arg('start_after')::text
+order by name collate "C"
+limit sqlc.arg('limit');
+
 -- name: ObjectSearchByBucketIdAndObjectPath :many
 select object.id,
        object.version,
@@ -124,8 +425,69 @@ select object.id,
        object.upload_status,
        object.last_accessed_at,
        object.created_at,
-       object.updated_at
+       object.updated_at,
+       object.legal_hold,
+       object.retention_mode,
+       object.retain_until,
+       object.replication_status,
+       object.replicated_at,
+       object.tier
 from storage.objects as object
 where object.bucket_id = sqlc.arg('bucket_id')
   and object.name ilike '%' || sqlc.arg('object_path')::text || '%'
-limit sqlc.arg('limit') offset sqlc.arg('offset');
\ No newline at end of file
+  and coalesce(cardinality(sqlc.arg('tag_keys')::text[]), 0) = (select count(1)
+                                                               from storage.object_tags as tag
+                                                               where tag.object_id = object.id
+                                                                 and (tag.key, tag.value) in
+                                                                     (select unnest(sqlc.arg('tag_keys')::text[]),
+                                                                             unnest(sqlc.arg('tag_values')::text[])))
+limit sqlc.arg('limit') offset sqlc.arg('offset');
+
+-- name: ObjectCountByUploadStatus :one
+select count(1) as count
+from storage.objects
+where upload_status = sqlc.arg('upload_status');
+
+-- name: ObjectGetLockedByBucketId :one
+-- returns an object of the bucket that cannot be deleted yet, legal holds first. governance retention does not count
+-- when it is bypassed
+select id,
+       name,
+       legal_hold,
+       retention_mode,
+       retain_until
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and (legal_hold or
+       (retain_until > now() and (retention_mode = 'compliance' or not sqlc.arg('bypass_governance')::boolean)))
+order by legal_hold desc, id
+limit 1;
+
+-- name: ObjectListByBucketIdAndIds :many
+select id,
+       version,
+       bucket_id,
+       name,
+       mime_type,
+       size,
+       metadata,
+       upload_status,
+       last_accessed_at,
+       created_at,
+       updated_at,
+       legal_hold,
+       retention_mode,
+       retain_until,
+       replication_status,
+       replicated_at,
+       tier
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and id = any (sqlc.arg('ids')::text[]);
+
+-- name: ObjectCountCompletedByBucketIdAndPrefix :one
+select count(*)::bigint
+from storage.objects
+where bucket_id = sqlc.arg('bucket_id')
+  and upload_status = 'completed'
+  and starts_with(name, sqlc.arg('prefix')::text);
@@ -0,0 +1,19 @@
+-- name: ObjectTagListByObjectId :many
+select object_id,
+       key,
+       value,
+       created_at
+from storage.object_tags
+where object_id = sqlc.arg('object_id')
+order by key;
+
+-- name: ObjectTagCreateMany :exec
+insert into storage.object_tags
+    (object_id, key, value)
+-- unnest in the select list pairs the keys and values up by position
+select sqlc.arg('object_id'), unnest(sqlc.arg('keys')::text[]), unnest(sqlc.arg('values')::text[]);
+
+-- name: ObjectTagDeleteByObjectId :exec
+delete
+from storage.object_tags
+where object_id = sqlc.arg('object_id');
@@ -0,0 +1,46 @@
+-- name: OperationCreate :one
+insert into storage.operations
+    (type, bucket_id, job_id)
+values (sqlc.arg('type'),
+        sqlc.arg('bucket_id'),
+        sqlc.arg('job_id'))
+returning id;
+
+-- name: OperationGetById :one
+select id,
+       type,
+       bucket_id,
+       job_id,
+       state,
+       error,
+       cancel_requested_at,
+       finished_at,
+       created_at,
+       updated_at
+from storage.operations
+where id = sqlc.arg('id')
+limit 1;
+
+-- name: OperationRequestCancel :exec
+update storage.operations
+set cancel_requested_at = coalesce(cancel_requested_at, now())
+where id = sqlc.arg('id')
+  and finished_at is null;
+
+-- name: OperationFinishByJobId :exec
+-- the first state recorded sticks, a late report of a job that was already cancelled does not overwrite it
+update storage.operations
+set state       = sqlc.arg('state'),
+    error       = sqlc.narg('error'),
+    finished_at = now()
+where job_id = sqlc.arg('job_id')
+  and finished_at is null;
+
+-- name: OperationReopenByJobId :exec
+-- a job retried after it was discarded works its operation again, the failure it recorded is no longer its outcome
+update storage.operations
+set state       = null,
+    error       = null,
+    finished_at = null
+where job_id = sqlc.arg('job_id')
+  and state = 'failed';
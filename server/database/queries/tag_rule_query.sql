@@ -0,0 +1,111 @@
+-- name: TagRuleCreate :one
+insert into storage.tag_rules
+    (bucket_id, tag_key, tag_value, action, expire_after_days)
+values (sqlc.arg('bucket_id'),
+        sqlc.arg('tag_key'),
+        sqlc.arg('tag_value'),
+        sqlc.arg('action'),
+        sqlc.narg('expire_after_days'))
+returning id;
+
+-- name: TagRuleGetByBucketIdAndId :one
+select id,
+       bucket_id,
+       tag_key,
+       tag_value,
+       action,
+       expire_after_days,
+       created_at
+from storage.tag_rules
+where bucket_id = sqlc.arg('bucket_id')
+  and id = sqlc.arg('id')
+limit 1;
+
+-- name: TagRuleListByBucketId :many
+select id,
+       bucket_id,
+       tag_key,
+       tag_value,
+       action,
+       expire_after_days,
+       created_at
+from storage.tag_rules
+where bucket_id = sqlc.arg('bucket_id')
+order by created_at, id;
+
+-- name: TagRuleDelete :execrows
+delete
+from storage.tag_rules
+where bucket_id = sqlc.arg('bucket_id')
+  and id = sqlc.arg('id');
+
+-- name: TagRuleGetDenyDeleteByObjectId :one
+-- returns the deny_delete rule protecting an object, no rows means the object can be deleted
+select rule.id,
+       rule.bucket_id,
+       rule.tag_key,
+       rule.tag_value,
+       rule.action,
+       rule.expire_after_days,
+       rule.created_at
+from storage.objects as object
+         inner join storage.object_tags as tag on tag.object_id = object.id
+         inner join storage.tag_rules as rule
+                    on rule.bucket_id = object.bucket_id and rule.tag_key = tag.key and rule.tag_value = tag.value
+where object.id = sqlc.arg('object_id')
+  and rule.action = 'deny_delete'
+order by rule.id
+limit 1;
+
+-- name: TagRuleGetDenyDeleteByBucketId :one
+-- returns a deny_delete rule protecting any object of a bucket, no rows means the bucket can be emptied
+select rule.id,
+       rule.bucket_id,
+       rule.tag_key,
+       rule.tag_value,
+       rule.action,
+       rule.expire_after_days,
+       rule.created_at
+from storage.tag_rules as rule
+where rule.bucket_id = sqlc.arg('bucket_id')
+  and rule.action = 'deny_delete'
+  and exists (select 1
+              from storage.object_tags as tag
+                       inner join storage.objects as object on object.id = tag.object_id
+              where object.bucket_id = rule.bucket_id
+                and tag.key = rule.tag_key
+                and tag.value = rule.tag_value)
+order by rule.id
+limit 1;
+
+-- name: ObjectListExpiredByTagRules :many
+-- objects past the expire rule of one of their tags, objects a deny_delete rule, a legal hold or retention protects are
+-- kept
+select distinct object.id,
+                object.bucket_id,
+                bucket.name as bucket_name,
+                bucket.storage_prefix as bucket_storage_prefix,
+                bucket.replica        as bucket_replica,
+                object.name
+from storage.objects as object
+         inner join storage.object_tags as tag on tag.object_id = object.id
+         inner join storage.tag_rules as rule
+                    on rule.bucket_id = object.bucket_id and rule.tag_key = tag.key and rule.tag_value = tag.value
+         inner join storage.buckets as bucket on bucket.id = object.bucket_id
+where rule.action = 'expire'
+  and object.upload_status = 'completed'
+  and object.created_at < now() - make_interval(days => rule.expire_after_days)
+  and not object.legal_hold
+  and (object.retain_until is null or object.retain_until <= now())
+  and not bucket.disabled
+  and not bucket.locked
+  and not exists (select 1
+                  from storage.object_tags as protected_tag
+                           inner join storage.tag_rules as protecting_rule
+                                      on protecting_rule.bucket_id = object.bucket_id and
+                                         protecting_rule.tag_key = protected_tag.key and
+                                         protecting_rule.tag_value = protected_tag.value
+                  where protected_tag.object_id = object.id
+                    and protecting_rule.action = 'deny_delete')
+order by object.id
+limit sqlc.arg('limit');
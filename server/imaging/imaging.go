@@ -0,0 +1,209 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	_ "image/gif"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+
+	// FitContain scales the image to fit inside the requested box keeping its aspect ratio, it never enlarges
+	FitContain = "contain"
+	// FitCover scales the image to fill the requested box keeping its aspect ratio and crops the overflow centered
+	FitCover = "cover"
+	// FitFill stretches the image to the requested box
+	FitFill = "fill"
+
+	DefaultQuality = 80
+)
+
+var (
+	ErrInvalidImage      = errors.New("invalid image")
+	ErrImageTooLarge     = errors.New("image dimensions exceed the allowed maximum")
+	ErrCropOutOfBounds   = errors.New("crop rectangle is outside of the image")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+)
+
+// defaultFormats are the mime types that can be rendered and the format they are rendered to unless one is requested,
+// gif has no encoder here so it becomes png
+var defaultFormats = map[string]string{
+	"image/jpeg": FormatJPEG,
+	"image/png":  FormatPNG,
+	"image/webp": FormatWebP,
+	"image/gif":  FormatPNG,
+}
+
+type Options struct {
+	// Width and Height of the output, when only one is set the other follows the aspect ratio
+	Width  int
+	Height int
+	Fit    string
+	// Crop is applied to the source before it is scaled
+	Crop    *image.Rectangle
+	Format  string
+	Quality int
+}
+
+// Key identifies the output of the options, options that render the same output share a key
+func (o *Options) Key() string {
+	crop := "none"
+	if o.Crop != nil {
+		crop = fmt.Sprintf("%d.%d.%d.%d", o.Crop.Min.X, o.Crop.Min.Y, o.Crop.Dx(), o.Crop.Dy())
+	}
+
+	quality := 0
+	if o.Format == FormatJPEG {
+		quality = o.Quality
+	}
+
+	return fmt.Sprintf("w%d-h%d-%s-c%s-q%d.%s", o.Width, o.Height, o.Fit, crop, quality, o.Format)
+}
+
+type Rendered struct {
+	Content     []byte
+	ContentType string
+}
+
+// DefaultFormat is the output format for a source of the mime type, ok is false when the mime type cannot be rendered
+func DefaultFormat(mimeType string) (string, bool) {
+	format, ok := defaultFormats[mimeType]
+	return format, ok
+}
+
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Render decodes the source, crops and scales it and encodes it in the requested format. sources wider or taller than
+// maxDimension are refused before their pixels are decoded
+func Render(source []byte, options *Options, maxDimension int) (*Rendered, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, fmt.Errorf("%w: %dx%d is larger than %dx%d", ErrImageTooLarge, config.Width, config.Height, maxDimension, maxDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	img, err = transform(img, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+
+	switch options.Format {
+	case FormatJPEG:
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: options.Quality})
+	case FormatPNG:
+		err = png.Encode(&buffer, img)
+	case FormatWebP:
+		// the webp encoder is lossless so quality does not apply
+		err = nativewebp.Encode(&buffer, img, nil)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, options.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rendered{
+		Content:     buffer.Bytes(),
+		ContentType: ContentType(options.Format),
+	}, nil
+}
+
+// transform crops and scales the image into a new rgba image, jpeg has no alpha channel so transparent pixels are
+// drawn over white instead of turning black
+func transform(img image.Image, options *Options) (image.Image, error) {
+	bounds := img.Bounds()
+
+	source := bounds
+	if options.Crop != nil {
+		source = options.Crop.Add(bounds.Min)
+		if !source.In(bounds) || source.Empty() {
+			return nil, fmt.Errorf("%w: %v is outside of %dx%d", ErrCropOutOfBounds, *options.Crop, bounds.Dx(), bounds.Dy())
+		}
+	}
+
+	source, width, height := scaledSize(source, options)
+
+	output := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	op := draw.Src
+	if options.Format == FormatJPEG {
+		draw.Draw(output, output.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+
+	if source.Dx() == width && source.Dy() == height {
+		draw.Draw(output, output.Bounds(), img, source.Min, op)
+	} else {
+		draw.CatmullRom.Scale(output, output.Bounds(), img, source, op, nil)
+	}
+
+	return output, nil
+}
+
+// scaledSize returns the part of the source that is drawn and the size it is drawn at
+func scaledSize(source image.Rectangle, options *Options) (image.Rectangle, int, int) {
+	sourceWidth, sourceHeight := source.Dx(), source.Dy()
+
+	if options.Width == 0 && options.Height == 0 {
+		return source, sourceWidth, sourceHeight
+	}
+
+	widthScale := float64(options.Width) / float64(sourceWidth)
+	heightScale := float64(options.Height) / float64(sourceHeight)
+
+	switch options.Fit {
+	case FitFill:
+		return source, options.Width, options.Height
+	case FitCover:
+		scale := math.Max(widthScale, heightScale)
+		visibleWidth := min(sourceWidth, max(1, int(math.Round(float64(options.Width)/scale))))
+		visibleHeight := min(sourceHeight, max(1, int(math.Round(float64(options.Height)/scale))))
+
+		offset := image.Pt((sourceWidth-visibleWidth)/2, (sourceHeight-visibleHeight)/2)
+		visible := image.Rectangle{Min: source.Min.Add(offset), Max: source.Min.Add(offset).Add(image.Pt(visibleWidth, visibleHeight))}
+
+		return visible, options.Width, options.Height
+	default:
+		scale := math.Min(widthScale, heightScale)
+		if options.Width == 0 {
+			scale = heightScale
+		}
+		if options.Height == 0 {
+			scale = widthScale
+		}
+		if scale >= 1 {
+			return source, sourceWidth, sourceHeight
+		}
+
+		width := max(1, int(math.Round(float64(sourceWidth)*scale)))
+		height := max(1, int(math.Round(float64(sourceHeight)*scale)))
+
+		return source, width, height
+	}
+}
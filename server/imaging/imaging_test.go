@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buffer bytes.Buffer
+	require.NoError(t, png.Encode(&buffer, img))
+
+	return buffer.Bytes()
+}
+
+func renderedSize(t *testing.T, rendered *Rendered) (string, int, int) {
+	t.Helper()
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(rendered.Content))
+	require.NoError(t, err)
+
+	return format, config.Width, config.Height
+}
+
+func TestRender_Sizes(t *testing.T) {
+	source := testImage(t, 400, 200)
+
+	tests := []struct {
+		name    string
+		options Options
+		width   int
+		height  int
+	}{
+		{name: "original", options: Options{}, width: 400, height: 200},
+		{name: "width only", options: Options{Width: 100}, width: 100, height: 50},
+		{name: "height only", options: Options{Height: 100}, width: 200, height: 100},
+		{name: "contain", options: Options{Width: 100, Height: 100, Fit: FitContain}, width: 100, height: 50},
+		{name: "contain never enlarges", options: Options{Width: 800, Height: 800, Fit: FitContain}, width: 400, height: 200},
+		{name: "cover", options: Options{Width: 100, Height: 100, Fit: FitCover}, width: 100, height: 100},
+		{name: "fill", options: Options{Width: 50, Height: 300, Fit: FitFill}, width: 50, height: 300},
+		{name: "crop", options: Options{Crop: &image.Rectangle{Min: image.Pt(10, 10), Max: image.Pt(110, 60)}}, width: 100, height: 50},
+		{name: "crop then scale", options: Options{Width: 50, Crop: &image.Rectangle{Min: image.Pt(0, 0), Max: image.Pt(100, 100)}}, width: 50, height: 50},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.Format = FormatPNG
+
+			rendered, err := Render(source, &test.options, 1000)
+			require.NoError(t, err)
+
+			_, width, height := renderedSize(t, rendered)
+			assert.Equal(t, test.width, width)
+			assert.Equal(t, test.height, height)
+		})
+	}
+}
+
+func TestRender_Formats(t *testing.T) {
+	source := testImage(t, 64, 32)
+
+	for _, format := range []string{FormatJPEG, FormatPNG, FormatWebP} {
+		t.Run(format, func(t *testing.T) {
+			rendered, err := Render(source, &Options{Width: 32, Format: format, Quality: DefaultQuality}, 1000)
+			require.NoError(t, err)
+			assert.Equal(t, "image/"+format, rendered.ContentType)
+
+			decodedFormat, width, height := renderedSize(t, rendered)
+			assert.Equal(t, format, decodedFormat)
+			assert.Equal(t, 32, width)
+			assert.Equal(t, 16, height)
+		})
+	}
+}
+
+func TestRender_Rejections(t *testing.T) {
+	source := testImage(t, 400, 200)
+
+	_, err := Render(source, &Options{Format: FormatPNG}, 300)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	_, err = Render([]byte("not an image"), &Options{Format: FormatPNG}, 1000)
+	assert.ErrorIs(t, err, ErrInvalidImage)
+
+	_, err = Render(source, &Options{Format: FormatPNG, Crop: &image.Rectangle{Min: image.Pt(350, 0), Max: image.Pt(450, 100)}}, 1000)
+	assert.ErrorIs(t, err, ErrCropOutOfBounds)
+
+	_, err = Render(source, &Options{Format: "bmp"}, 1000)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestOptions_Key(t *testing.T) {
+	png := &Options{Width: 100, Fit: FitContain, Format: FormatPNG, Quality: 50}
+	samePng := &Options{Width: 100, Fit: FitContain, Format: FormatPNG, Quality: 90}
+	assert.Equal(t, png.Key(), samePng.Key(), "quality only matters for jpeg")
+
+	jpeg := &Options{Width: 100, Fit: FitContain, Format: FormatJPEG, Quality: 50}
+	otherJpeg := &Options{Width: 100, Fit: FitContain, Format: FormatJPEG, Quality: 90}
+	assert.NotEqual(t, jpeg.Key(), otherJpeg.Key())
+
+	cropped := &Options{Width: 100, Fit: FitContain, Format: FormatPNG, Crop: &image.Rectangle{Max: image.Pt(10, 10)}}
+	assert.NotEqual(t, png.Key(), cropped.Key())
+}
+
+func TestDefaultFormat(t *testing.T) {
+	format, ok := DefaultFormat("image/gif")
+	assert.True(t, ok)
+	assert.Equal(t, FormatPNG, format)
+
+	_, ok = DefaultFormat("application/pdf")
+	assert.False(t, ok)
+}
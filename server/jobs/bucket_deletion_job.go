@@ -6,28 +6,43 @@ import (
 	"github.com/riverqueue/river"
 	"github.com/teapartydev/storage/server/database"
 	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
 )
 
 type BucketDeletion struct {
 	BucketId string `json:"bucket_id"`
+	// BypassGovernance deletes objects under governance retention, an admin asked for it when the job was queued
+	BypassGovernance bool                 `json:"bypass_governance,omitempty"`
+	TraceContext     tracing.TraceContext `json:"trace_context,omitempty"`
 }
 
 func (BucketDeletion) Kind() string {
 	return "bucket.deletion"
 }
 
+func (BucketDeletion) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketDeletion}
+}
+
 type BucketDeletionWorker struct {
-	queries *database.Queries
-	storage *storage.Storage
-	logger  *zap.Logger
+	queries    *database.Queries
+	storage    *storage.Storage
+	replicas   storage.Replicas
+	deleter    *bucketObjectsDeleter
+	operations *bucketOperationRecorder
+	logger     *zap.Logger
 	river.WorkerDefaults[BucketDeletion]
 }
 
-func (w *BucketDeletionWorker) Work(ctx context.Context, bucketDeletion *river.Job[BucketDeletion]) error {
+func (w *BucketDeletionWorker) Work(ctx context.Context, bucketDeletion *river.Job[BucketDeletion]) (err error) {
 	const op = "BucketDeletionWorker.Work"
 
+	ctx, span := tracing.StartJobSpan(ctx, bucketDeletion.Kind, bucketDeletion.ID, bucketDeletion.Attempt, bucketDeletion.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() { err = w.operations.finish(ctx, bucketDeletion.JobRow, bucketDeletion.Args.BucketId, err, op) }()
+
 	bucket, err := w.queries.BucketGetById(ctx, bucketDeletion.Args.BucketId)
 	if err != nil {
 		w.logger.Error(
@@ -39,56 +54,83 @@ func (w *BucketDeletionWorker) Work(ctx context.Context, bucketDeletion *river.J
 		return err
 	}
 
-	limit := int32(100)
-	offset := int32(0)
+	progress, err := w.deleter.deleteObjects(ctx, bucketDeletion.JobRow, bucket, bucketDeletion.Args.BypassGovernance, op)
+	if err != nil {
+		return err
+	}
+
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(bucket.ID, ""),
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete cached renders",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// a bucket still holding locked objects is kept and unlocked again instead of deleted
+	if progress.Skipped > 0 {
+		w.logger.Warn(
+			"keeping bucket holding locked objects",
+			zap.String("bucket_id", bucket.ID),
+			zap.Int64("kept", progress.Skipped),
+			zapfield.Operation(op),
+		)
 
-	for {
-		objects, err := w.queries.ObjectsListBucketIdPaged(ctx, &database.ObjectsListBucketIdPagedParams{
-			BucketID: bucket.ID,
-			Offset:   offset,
-			Limit:    limit,
+		_, err = w.queries.BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+			ID:        bucket.ID,
+			LockJobID: bucketDeletion.ID,
 		})
 		if err != nil {
 			w.logger.Error(
-				"failed to list objects",
+				"failed to unlock bucket from database",
 				zap.String("bucket_id", bucket.ID),
 				zapfield.Operation(op),
 				zap.Error(err),
 			)
 			return err
 		}
-		if len(objects) == 0 {
-			break
+
+		return nil
+	}
+
+	// the bucket row is the only record of content a cancelled relocation left behind
+	if bucket.StaleStoragePrefix != nil && *bucket.StaleStoragePrefix != bucket.StoragePrefix {
+		err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+			Bucket: *bucket.StaleStoragePrefix,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to delete stale content of bucket",
+				zap.String("bucket_id", bucket.ID),
+				zap.String("prefix", *bucket.StaleStoragePrefix),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
 		}
 
-		for _, object := range objects {
-			err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
-				Bucket: bucket.Name,
-				Name:   object.Name,
+		if replica := w.replicas.Get(bucket.Replica); replica != nil {
+			err = replica.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+				Bucket: *bucket.StaleStoragePrefix,
 			})
 			if err != nil {
 				w.logger.Error(
-					"failed to delete object from storage",
-					zap.String("bucket_name", bucket.Name),
-					zap.String("object_name", object.Name),
-					zapfield.Operation(op),
-					zap.Error(err),
-				)
-				return err
-			}
-			err = w.queries.ObjectDelete(ctx, object.ID)
-			if err != nil {
-				w.logger.Error(
-					"failed to delete object from database",
-					zap.String("object_id", object.ID),
+					"failed to delete stale replica content of bucket",
+					zap.String("bucket_id", bucket.ID),
+					zap.String("prefix", *bucket.StaleStoragePrefix),
+					zap.String("replica", *bucket.Replica),
 					zapfield.Operation(op),
 					zap.Error(err),
 				)
 				return err
 			}
 		}
-
-		offset += limit
 	}
 
 	err = w.queries.BucketDelete(ctx, bucket.ID)
@@ -108,10 +150,23 @@ func (w *BucketDeletionWorker) Work(ctx context.Context, bucketDeletion *river.J
 	return nil
 }
 
-func NewBucketDeletionWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *BucketDeletionWorker {
+func NewBucketDeletionWorker(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, logger *zap.Logger) *BucketDeletionWorker {
+	queries := database.New(db)
+
 	return &BucketDeletionWorker{
-		queries: database.New(db),
-		storage: storage,
-		logger:  logger,
+		queries:  queries,
+		storage:  storage,
+		replicas: replicas,
+		deleter: &bucketObjectsDeleter{
+			queries: queries,
+			storage: storage,
+			logger:  logger,
+		},
+		operations: &bucketOperationRecorder{
+			queries:       queries,
+			unlocksBucket: true,
+			logger:        logger,
+		},
+		logger: logger,
 	}
 }
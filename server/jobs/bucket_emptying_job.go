@@ -6,28 +6,42 @@ import (
 	"github.com/riverqueue/river"
 	"github.com/teapartydev/storage/server/database"
 	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
 )
 
 type BucketEmptying struct {
 	BucketId string `json:"bucket_id"`
+	// BypassGovernance deletes objects under governance retention, an admin asked for it when the job was queued
+	BypassGovernance bool                 `json:"bypass_governance,omitempty"`
+	TraceContext     tracing.TraceContext `json:"trace_context,omitempty"`
 }
 
 func (BucketEmptying) Kind() string {
 	return "bucket.emptying"
 }
 
+func (BucketEmptying) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketEmptying}
+}
+
 type BucketEmptyingWorker struct {
-	queries *database.Queries
-	storage *storage.Storage
-	logger  *zap.Logger
+	queries    *database.Queries
+	storage    *storage.Storage
+	deleter    *bucketObjectsDeleter
+	operations *bucketOperationRecorder
+	logger     *zap.Logger
 	river.WorkerDefaults[BucketEmptying]
 }
 
-func (w *BucketEmptyingWorker) Work(ctx context.Context, bucketEmpty *river.Job[BucketEmptying]) error {
+func (w *BucketEmptyingWorker) Work(ctx context.Context, bucketEmpty *river.Job[BucketEmptying]) (err error) {
 	const op = "BucketEmptyingWorker.Work"
 
+	ctx, span := tracing.StartJobSpan(ctx, bucketEmpty.Kind, bucketEmpty.ID, bucketEmpty.Attempt, bucketEmpty.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() { err = w.operations.finish(ctx, bucketEmpty.JobRow, bucketEmpty.Args.BucketId, err, op) }()
+
 	bucket, err := w.queries.BucketGetById(ctx, bucketEmpty.Args.BucketId)
 	if err != nil {
 		w.logger.Error(
@@ -39,59 +53,38 @@ func (w *BucketEmptyingWorker) Work(ctx context.Context, bucketEmpty *river.Job[
 		return err
 	}
 
-	limit := int32(100)
-	offset := int32(0)
-
-	for {
-		objects, err := w.queries.ObjectsListBucketIdPaged(ctx, &database.ObjectsListBucketIdPagedParams{
-			BucketID: bucket.ID,
-			Offset:   offset,
-			Limit:    limit,
-		})
-		if err != nil {
-			w.logger.Error(
-				"failed to list objects",
-				zap.String("bucket_id", bucket.ID),
-				zapfield.Operation(op),
-				zap.Error(err),
-			)
-			return err
-		}
-		if len(objects) == 0 {
-			break
-		}
+	progress, err := w.deleter.deleteObjects(ctx, bucketEmpty.JobRow, bucket, bucketEmpty.Args.BypassGovernance, op)
+	if err != nil {
+		return err
+	}
 
-		for _, object := range objects {
-			err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
-				Bucket: bucket.Name,
-				Name:   object.Name,
-			})
-			if err != nil {
-				w.logger.Error(
-					"failed to delete object from storage",
-					zap.String("bucket_name", bucket.Name),
-					zap.String("object_name", object.Name),
-					zapfield.Operation(op),
-					zap.Error(err),
-				)
-				return err
-			}
-			err = w.queries.ObjectDelete(ctx, object.ID)
-			if err != nil {
-				w.logger.Error(
-					"failed to delete object from database",
-					zap.String("object_id", object.ID),
-					zapfield.Operation(op),
-					zap.Error(err),
-				)
-				return err
-			}
-		}
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(bucket.ID, ""),
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete cached renders",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
 
-		offset += limit
+	if progress.Skipped > 0 {
+		w.logger.Warn(
+			"bucket emptied except for locked objects",
+			zap.String("bucket_id", bucket.ID),
+			zap.Int64("kept", progress.Skipped),
+			zapfield.Operation(op),
+		)
 	}
 
-	err = w.queries.BucketUnlock(ctx, bucket.ID)
+	_, err = w.queries.BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+		ID:        bucket.ID,
+		LockJobID: bucketEmpty.ID,
+	})
 	if err != nil {
 		w.logger.Error(
 			"failed to unlock bucket from database",
@@ -106,9 +99,21 @@ func (w *BucketEmptyingWorker) Work(ctx context.Context, bucketEmpty *river.Job[
 }
 
 func NewBucketEmptyingWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *BucketEmptyingWorker {
+	queries := database.New(db)
+
 	return &BucketEmptyingWorker{
-		queries: database.New(db),
+		queries: queries,
 		storage: storage,
-		logger:  logger,
+		deleter: &bucketObjectsDeleter{
+			queries: queries,
+			storage: storage,
+			logger:  logger,
+		},
+		operations: &bucketOperationRecorder{
+			queries:       queries,
+			unlocksBucket: true,
+			logger:        logger,
+		},
+		logger: logger,
 	}
 }
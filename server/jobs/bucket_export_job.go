@@ -0,0 +1,288 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/archive"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketExportPageSize is how many objects are read from the catalog at a time
+const bucketExportPageSize = 100
+
+type BucketExport struct {
+	BucketId     string               `json:"bucket_id"`
+	Archive      models.BucketArchive `json:"archive"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (BucketExport) Kind() string {
+	return "bucket.export"
+}
+
+func (BucketExport) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketExport}
+}
+
+// BucketExportWorker writes the catalog rows and content of the completed objects of a bucket into an archive. the
+// archive is streamed to its location and only appears there once it is complete, so a retried job starts over
+// instead of resuming. objects deleted while the export runs and objects archived in the cold tier are left out and
+// counted as skipped
+type BucketExportWorker struct {
+	queries    *database.Queries
+	storage    *storage.Storage
+	archives   *archive.Store
+	operations *bucketOperationRecorder
+	logger     *zap.Logger
+	river.WorkerDefaults[BucketExport]
+}
+
+func (w *BucketExportWorker) Work(ctx context.Context, bucketExport *river.Job[BucketExport]) (err error) {
+	const op = "BucketExportWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, bucketExport.Kind, bucketExport.ID, bucketExport.Attempt, bucketExport.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() { err = w.operations.finish(ctx, bucketExport.JobRow, bucketExport.Args.BucketId, err, op) }()
+
+	bucket, err := w.queries.BucketGetById(ctx, bucketExport.Args.BucketId)
+	if err != nil {
+		w.logger.Error(
+			"failed to get bucket",
+			zap.String("bucket_id", bucketExport.Args.BucketId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	count, err := w.queries.ObjectCountCompletedByBucketIdAndPrefix(ctx, &database.ObjectCountCompletedByBucketIdAndPrefixParams{
+		BucketID: bucket.ID,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to count objects",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	progress := &models.JobProgress{Total: count}
+	if err = saveJobProgress(ctx, w.queries, bucketExport.ID, progress, w.logger, op); err != nil {
+		return err
+	}
+
+	writer, err := w.archives.Create(ctx, &bucketExport.Args.Archive)
+	if err != nil {
+		if errors.Is(err, archive.ErrLocalArchivesDisabled) {
+			return river.JobCancel(err)
+		}
+		w.logger.Error(
+			"failed to create archive",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("archive_path", bucketExport.Args.Archive.Path),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			writer.Abort()
+		}
+	}()
+
+	err = writer.WriteManifest(&archive.Manifest{
+		Version:     archive.Version,
+		ExportedAt:  time.Now(),
+		Bucket:      archivedBucket(bucket),
+		ObjectCount: count,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		objects, err := w.queries.ObjectListCompletedByBucketIdAndPrefix(ctx, &database.ObjectListCompletedByBucketIdAndPrefixParams{
+			BucketID:   bucket.ID,
+			StartAfter: progress.Cursor,
+			Limit:      bucketExportPageSize,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to list objects",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+		if len(objects) == 0 {
+			break
+		}
+
+		for _, object := range objects {
+			exported, err := w.exportObject(ctx, writer, bucket, object, op)
+			if err != nil {
+				return err
+			}
+			if !exported {
+				progress.Skipped++
+			}
+		}
+
+		progress.Cursor = objects[len(objects)-1].Name
+		progress.Processed += int64(len(objects))
+
+		if err = saveJobProgress(ctx, w.queries, bucketExport.ID, progress, w.logger, op); err != nil {
+			return err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		w.logger.Error(
+			"failed to finish archive",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("archive_path", bucketExport.Args.Archive.Path),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	w.logger.Info(
+		"bucket exported",
+		zap.String("bucket_id", bucket.ID),
+		zap.String("archive_location", bucketExport.Args.Archive.Location),
+		zap.String("archive_path", bucketExport.Args.Archive.Path),
+		zap.Int64("exported", progress.Processed-progress.Skipped),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+// exportObject writes an object into the archive, it reports false for objects whose content is gone or archived in
+// the cold tier. archived content has to be restored before it can be exported
+func (w *BucketExportWorker) exportObject(ctx context.Context, writer *archive.Writer, bucket *database.StorageBucket, object *database.StorageObject, op string) (bool, error) {
+	if object.Tier != models.ObjectTierHot {
+		w.logger.Warn(
+			"skipping export of archived object",
+			zap.String("object_id", object.ID),
+			zap.String("tier", object.Tier),
+			zapfield.Operation(op),
+		)
+		return false, nil
+	}
+
+	content, err := w.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			w.logger.Warn(
+				"skipping export of object without content",
+				zap.String("object_id", object.ID),
+				zapfield.Operation(op),
+			)
+			return false, nil
+		}
+		if errors.Is(err, storage.ErrObjectArchived) {
+			w.logger.Warn(
+				"skipping export of archived object",
+				zap.String("object_id", object.ID),
+				zapfield.Operation(op),
+			)
+			return false, nil
+		}
+		return false, err
+	}
+	defer content.Body.Close()
+
+	tags, err := w.queries.ObjectTagListByObjectId(ctx, object.ID)
+	if err != nil {
+		w.logger.Error(
+			"failed to list object tags",
+			zap.String("object_id", object.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	archived := &archive.Object{
+		Id:            object.ID,
+		Name:          object.Name,
+		MimeType:      object.MimeType,
+		Size:          content.ContentLength,
+		Metadata:      object.Metadata,
+		LegalHold:     object.LegalHold,
+		RetentionMode: object.RetentionMode,
+		RetainUntil:   object.RetainUntil,
+		CreatedAt:     object.CreatedAt,
+	}
+
+	if len(tags) > 0 {
+		archived.Tags = make(map[string]string, len(tags))
+		for _, tag := range tags {
+			archived.Tags[tag.Key] = tag.Value
+		}
+	}
+
+	if err = writer.WriteObject(archived, content.Body); err != nil {
+		w.logger.Error(
+			"failed to write object into archive",
+			zap.String("object_id", object.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// archivedBucket is the configuration of a bucket as it is kept in its archives
+func archivedBucket(bucket *database.StorageBucket) *models.Bucket {
+	return &models.Bucket{
+		Id:                   bucket.ID,
+		Version:              bucket.Version,
+		Name:                 bucket.Name,
+		AllowedMimeTypes:     bucket.AllowedMimeTypes,
+		MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
+		Public:               bucket.Public,
+		Processors:           bucket.Processors,
+		ArchiveAfterDays:     bucket.ArchiveAfterDays,
+		DefaultRetentionMode: bucket.DefaultRetentionMode,
+		DefaultRetentionDays: bucket.DefaultRetentionDays,
+		Disabled:             bucket.Disabled,
+		CreatedAt:            bucket.CreatedAt,
+		UpdatedAt:            bucket.UpdatedAt,
+	}
+}
+
+func NewBucketExportWorker(db *pgxpool.Pool, storage *storage.Storage, archives *archive.Store, logger *zap.Logger) *BucketExportWorker {
+	queries := database.New(db)
+
+	return &BucketExportWorker{
+		queries:  queries,
+		storage:  storage,
+		archives: archives,
+		operations: &bucketOperationRecorder{
+			queries: queries,
+			logger:  logger,
+		},
+		logger: logger,
+	}
+}
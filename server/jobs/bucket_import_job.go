@@ -0,0 +1,414 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/samber/lo"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketImportPageSize is how many objects are listed from storage per checkpoint
+const bucketImportPageSize = 1000
+
+// errBucketImportSkipped rolls back the import of an object that turned out not to be importable
+var errBucketImportSkipped = errors.New("object skipped by the import")
+
+type BucketImport struct {
+	BucketId string `json:"bucket_id"`
+	// SourcePrefix is the storage prefix the content is read from, content outside the storage prefix of the bucket
+	// is copied under it. jobs queued without one read the storage prefix of the bucket
+	SourcePrefix string `json:"source_prefix,omitempty"`
+	Prefix       string `json:"prefix,omitempty"`
+	// Overwrite updates objects of the same name instead of skipping them
+	Overwrite    bool                 `json:"overwrite,omitempty"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (BucketImport) Kind() string {
+	return "bucket.import"
+}
+
+func (BucketImport) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketImport}
+}
+
+// bucketImportStorage is the part of storage an import reads and copies content with
+type bucketImportStorage interface {
+	ListObjects(ctx context.Context, objectsList *storage.ObjectsList) (*storage.ObjectsListPage, error)
+	HeadObject(ctx context.Context, objectHead *storage.ObjectHead) (*storage.ObjectInfo, error)
+	CopyObject(ctx context.Context, objectCopy *storage.ObjectCopy) (string, error)
+	CopyLargeObject(ctx context.Context, objectCopy *storage.ObjectCopy, size int64) (string, error)
+}
+
+// BucketImportWorker adds content already in storage to the catalog of a bucket. content under another prefix than
+// the one the bucket is stored under, like the name of a bucket stored under its id, is copied into the bucket first.
+// storage is listed in key order after the name of the last checkpoint and every object goes through the scan and processors of the bucket like an
+// upload, so a retried job resumes where the previous attempt stopped. storage cannot tell how many keys there are
+// without listing all of them, the progress has no total
+type BucketImportWorker struct {
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     bucketImportStorage
+	scan        bool
+	operations  *bucketOperationRecorder
+	logger      *zap.Logger
+	river.WorkerDefaults[BucketImport]
+}
+
+func (w *BucketImportWorker) Work(ctx context.Context, bucketImport *river.Job[BucketImport]) (err error) {
+	const op = "BucketImportWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, bucketImport.Kind, bucketImport.ID, bucketImport.Attempt, bucketImport.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() { err = w.operations.finish(ctx, bucketImport.JobRow, bucketImport.Args.BucketId, err, op) }()
+
+	bucket, err := w.queries.BucketGetById(ctx, bucketImport.Args.BucketId)
+	if err != nil {
+		w.logger.Error(
+			"failed to get bucket",
+			zap.String("bucket_id", bucketImport.Args.BucketId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	progress, err := models.ParseJobProgress(bucketImport.Metadata)
+	if err != nil || progress == nil {
+		progress = &models.JobProgress{}
+	} else {
+		w.logger.Info(
+			"resuming from job progress",
+			zap.Int64("job_id", bucketImport.ID),
+			zap.String("cursor", progress.Cursor),
+			zap.Int64("processed", progress.Processed),
+			zapfield.Operation(op),
+		)
+	}
+
+	sourcePrefix := bucketImport.Args.SourcePrefix
+	if sourcePrefix == "" {
+		sourcePrefix = bucket.StoragePrefix
+	}
+
+	for {
+		// a locked bucket is being emptied, deleted or restored, importing into it would interfere with that
+		if bucket.Locked {
+			return river.JobCancel(errors.New("bucket was locked while it was imported"))
+		}
+
+		page, err := w.storage.ListObjects(ctx, &storage.ObjectsList{
+			Bucket:     sourcePrefix,
+			Prefix:     bucketImport.Args.Prefix,
+			StartAfter: progress.Cursor,
+			MaxKeys:    bucketImportPageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, object := range page.Objects {
+			imported, err := w.importObject(ctx, bucket, sourcePrefix, object, bucketImport.Args.Overwrite, bucketImport.ID, op)
+			if err != nil {
+				return err
+			}
+			if !imported {
+				progress.Skipped++
+			}
+		}
+
+		if len(page.Objects) > 0 {
+			progress.Cursor = page.Objects[len(page.Objects)-1].Name
+			progress.Processed += int64(len(page.Objects))
+
+			if err = w.saveProgress(ctx, bucketImport.ID, progress, op); err != nil {
+				return err
+			}
+		}
+
+		if !page.Truncated {
+			return nil
+		}
+
+		bucket, err = w.queries.BucketGetById(ctx, bucket.ID)
+		if err != nil {
+			w.logger.Error(
+				"failed to get bucket",
+				zap.String("bucket_id", bucketImport.Args.BucketId),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+}
+
+// importObject adds or overwrites the object of a listed key. it reports false for keys it skipped, keys that are no
+// valid object or that the bucket does not allow are skipped as well as names that are taken when not overwriting and
+// objects under a legal hold, retention or deny_delete tag rule. content is only copied into the bucket inside the
+// transaction that catalogs it, once the name is known to be free or the object to be overwritable, so a skipped key
+// never replaced any content
+func (w *BucketImportWorker) importObject(ctx context.Context, bucket *database.StorageBucket, sourcePrefix string, listed *storage.ListedObject, overwrite bool, jobId int64, op string) (bool, error) {
+	if !models.IsValidObjectName(listed.Name) || listed.Size <= 0 {
+		return false, nil
+	}
+
+	if bucket.MaxAllowedObjectSize != nil && listed.Size > *bucket.MaxAllowedObjectSize {
+		return false, nil
+	}
+
+	existing, err := w.queries.ObjectGetByBucketIdAndName(ctx, &database.ObjectGetByBucketIdAndNameParams{
+		BucketID: bucket.ID,
+		Name:     listed.Name,
+	})
+	if err != nil && !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get object by name",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	if existing != nil {
+		if !overwrite {
+			return false, nil
+		}
+
+		overwritable, err := w.checkOverwritable(ctx, w.queries, existing.ID, op)
+		if err != nil || !overwritable {
+			return false, err
+		}
+	}
+
+	// list results carry no content type, it takes a head request per object
+	info, err := w.storage.HeadObject(ctx, &storage.ObjectHead{
+		Bucket: sourcePrefix,
+		Name:   listed.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	mimeType := info.ContentType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	if !lo.Contains(bucket.AllowedMimeTypes, models.BucketAllowedMimeTypesWildcard) && !lo.Contains(bucket.AllowedMimeTypes, mimeType) {
+		return false, nil
+	}
+
+	metadata, err := json.Marshal(map[string]any{
+		models.BucketImportMetadataKey: map[string]any{
+			"etag":          info.ETag,
+			"last_modified": info.LastModified,
+			"job_id":        jobId,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var id string
+		var err error
+
+		if existing != nil {
+			id = existing.ID
+
+			// the object is held until the transaction ends, a hold, retention or tag rule added since it was looked
+			// up keeps its content
+			overwritable, err := w.checkOverwritable(ctx, w.queries.WithTx(tx), id, op)
+			if err != nil {
+				return err
+			}
+			if !overwritable {
+				return errBucketImportSkipped
+			}
+
+			err = w.queries.WithTx(tx).ObjectUpdate(ctx, &database.ObjectUpdateParams{
+				ID:       id,
+				Size:     &info.ContentLength,
+				MimeType: &mimeType,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = w.queries.WithTx(tx).ObjectMergeMetadata(ctx, &database.ObjectMergeMetadataParams{
+				ID:       id,
+				Metadata: metadata,
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			id, err = w.queries.WithTx(tx).ObjectCreate(ctx, &database.ObjectCreateParams{
+				BucketID:     bucket.ID,
+				Name:         listed.Name,
+				ContentType:  &mimeType,
+				Size:         info.ContentLength,
+				Metadata:     metadata,
+				UploadStatus: models.ObjectUploadStatusPending,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// a failed copy rolls the catalog back, content copied before a failed commit is imported again by the retry
+		copied, err := w.copyIntoBucket(ctx, bucket, sourcePrefix, listed.Name, info.ContentLength, op)
+		if err != nil {
+			return err
+		}
+		if !copied {
+			return errBucketImportSkipped
+		}
+
+		status, params := CompletedUpload(ctx, id, mimeType, bucket.Processors, bucket.Replica, w.scan)
+
+		err = w.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
+			ID:           id,
+			UploadStatus: status,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(params) == 0 {
+			return nil
+		}
+
+		_, err = river.ClientFromContext[pgx.Tx](ctx).InsertManyTx(ctx, tx, params)
+		return err
+	})
+	if errors.Is(err, errBucketImportSkipped) || database.IsConflictError(err) {
+		// a conflict is an upload that took the name since it was looked up, before anything was copied
+		return false, nil
+	}
+	if err != nil {
+		w.logger.Error(
+			"failed to import object",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("object_name", listed.Name),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkOverwritable reports whether an import can overwrite an object, objects under a legal hold, retention or
+// deny_delete tag rule and objects that are gone are not overwritable. within a transaction the object is held until it
+// ends
+func (w *BucketImportWorker) checkOverwritable(ctx context.Context, queries *database.Queries, objectId string, op string) (bool, error) {
+	object, err := queries.ObjectGetLockForUpdate(ctx, objectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return false, nil
+		}
+		w.logger.Error(
+			"failed to get object to overwrite",
+			zap.String("object_id", objectId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	if models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, false) {
+		return false, nil
+	}
+
+	_, err = queries.TagRuleGetDenyDeleteByObjectId(ctx, objectId)
+	if err == nil {
+		return false, nil
+	}
+	if !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get deny delete tag rule of object",
+			zap.String("object_id", objectId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// copyIntoBucket copies a key from another prefix to the same name under the storage prefix of the bucket, keys under
+// the storage prefix of the bucket are left as they are. it reports false for keys that are gone or archived in a cold
+// storage class, those cannot be copied
+func (w *BucketImportWorker) copyIntoBucket(ctx context.Context, bucket *database.StorageBucket, sourcePrefix string, name string, size int64, op string) (bool, error) {
+	if sourcePrefix == bucket.StoragePrefix {
+		return true, nil
+	}
+
+	objectCopy := &storage.ObjectCopy{
+		SourceBucket:      sourcePrefix,
+		SourceName:        name,
+		DestinationBucket: bucket.StoragePrefix,
+		DestinationName:   name,
+	}
+
+	var err error
+	if size > storage.CopyObjectMaxSize {
+		_, err = w.storage.CopyLargeObject(ctx, objectCopy, size)
+	} else {
+		_, err = w.storage.CopyObject(ctx, objectCopy)
+	}
+	if errors.Is(err, storage.ErrObjectNotFound) || errors.Is(err, storage.ErrObjectArchived) {
+		return false, nil
+	}
+	if err != nil {
+		w.logger.Error(
+			"failed to copy object into bucket",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("object_name", name),
+			zap.String("source_prefix", sourcePrefix),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (w *BucketImportWorker) saveProgress(ctx context.Context, jobId int64, progress *models.JobProgress, op string) error {
+	return saveJobProgress(ctx, w.queries, jobId, progress, w.logger, op)
+}
+
+func NewBucketImportWorker(db *pgxpool.Pool, storage *storage.Storage, scan bool, logger *zap.Logger) *BucketImportWorker {
+	queries := database.New(db)
+
+	return &BucketImportWorker{
+		queries:     queries,
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		scan:        scan,
+		operations: &bucketOperationRecorder{
+			queries: queries,
+			logger:  logger,
+		},
+		logger: logger,
+	}
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/storage"
+	"go.uber.org/zap"
+)
+
+// fakeBucketImportStorage records the copies of an import, keys listed in archived are in a cold storage class
+type fakeBucketImportStorage struct {
+	archived    map[string]bool
+	copies      []*storage.ObjectCopy
+	largeCopies []*storage.ObjectCopy
+}
+
+func (s *fakeBucketImportStorage) ListObjects(context.Context, *storage.ObjectsList) (*storage.ObjectsListPage, error) {
+	return &storage.ObjectsListPage{}, nil
+}
+
+func (s *fakeBucketImportStorage) HeadObject(context.Context, *storage.ObjectHead) (*storage.ObjectInfo, error) {
+	return &storage.ObjectInfo{}, nil
+}
+
+func (s *fakeBucketImportStorage) CopyObject(_ context.Context, objectCopy *storage.ObjectCopy) (string, error) {
+	if s.archived[objectCopy.SourceName] {
+		return "", storage.ErrObjectArchived
+	}
+	s.copies = append(s.copies, objectCopy)
+	return "etag", nil
+}
+
+func (s *fakeBucketImportStorage) CopyLargeObject(_ context.Context, objectCopy *storage.ObjectCopy, _ int64) (string, error) {
+	s.largeCopies = append(s.largeCopies, objectCopy)
+	return "etag", nil
+}
+
+func TestBucketImportWorker_CopiesIntoFreshBucket(t *testing.T) {
+	store := &fakeBucketImportStorage{}
+	worker := &BucketImportWorker{storage: store, logger: zap.NewNop()}
+
+	// a freshly created bucket is stored under its id while the content to import is under its name
+	bucket := &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"}
+
+	copied, err := worker.copyIntoBucket(context.Background(), bucket, "avatar", "users/1.png", 1024, "test")
+	require.NoError(t, err)
+	assert.True(t, copied)
+
+	copied, err = worker.copyIntoBucket(context.Background(), bucket, "avatar", "users/2.mp4", storage.CopyObjectMaxSize+1, "test")
+	require.NoError(t, err)
+	assert.True(t, copied)
+
+	require.Len(t, store.copies, 1)
+	assert.Equal(t, &storage.ObjectCopy{
+		SourceBucket:      "avatar",
+		SourceName:        "users/1.png",
+		DestinationBucket: bucket.StoragePrefix,
+		DestinationName:   "users/1.png",
+	}, store.copies[0])
+
+	require.Len(t, store.largeCopies, 1)
+	assert.Equal(t, "users/2.mp4", store.largeCopies[0].DestinationName)
+	assert.Equal(t, bucket.StoragePrefix, store.largeCopies[0].DestinationBucket)
+}
+
+func TestBucketImportWorker_ImportsInPlace(t *testing.T) {
+	store := &fakeBucketImportStorage{}
+	worker := &BucketImportWorker{storage: store, logger: zap.NewNop()}
+
+	bucket := &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "avatar"}
+
+	copied, err := worker.copyIntoBucket(context.Background(), bucket, "avatar", "users/1.png", 1024, "test")
+	require.NoError(t, err)
+	assert.True(t, copied)
+	assert.Empty(t, store.copies)
+}
+
+func TestBucketImportWorker_SkipsArchivedContent(t *testing.T) {
+	store := &fakeBucketImportStorage{archived: map[string]bool{"users/1.png": true}}
+	worker := &BucketImportWorker{storage: store, logger: zap.NewNop()}
+
+	bucket := &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"}
+
+	copied, err := worker.copyIntoBucket(context.Background(), bucket, "avatar", "users/1.png", 1024, "test")
+	require.NoError(t, err)
+	assert.False(t, copied)
+	assert.Empty(t, store.copies)
+}
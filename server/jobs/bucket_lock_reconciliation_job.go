@@ -0,0 +1,309 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketLockReconciliationInterval is how often expired bucket lock leases are looked into, a lock is released at
+// most this long after its lease ran out
+const bucketLockReconciliationInterval = 5 * time.Minute
+
+// bucketLockReconciliationPageSize is how many expired locks are listed at a time
+const bucketLockReconciliationPageSize = 100
+
+// bucketLockReconcilerPrincipal is who the audit events of the lock reconciler are recorded for
+const bucketLockReconcilerPrincipal = "system:bucket_lock_reconciler"
+
+type BucketLockReconciliation struct {
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (BucketLockReconciliation) Kind() string {
+	return "bucket.lock_reconciliation"
+}
+
+func (BucketLockReconciliation) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketLockReconciliation}
+}
+
+// BucketLockReconciliationWorker recovers bucket locks whose lease ran out. a lock whose job is still queued or
+// running is left to it, a discarded job is retried while the lock has retries left, and any other lock is released
+// with its operation recorded as failed since nothing is going to release it anymore
+type BucketLockReconciliationWorker struct {
+	queries      *database.Queries
+	transaction  *database.Transaction
+	leaseTimeout int64
+	maxRetries   int32
+	logger       *zap.Logger
+	river.WorkerDefaults[BucketLockReconciliation]
+}
+
+func (w *BucketLockReconciliationWorker) Work(ctx context.Context, bucketLockReconciliation *river.Job[BucketLockReconciliation]) (err error) {
+	const op = "BucketLockReconciliationWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, bucketLockReconciliation.Kind, bucketLockReconciliation.ID, bucketLockReconciliation.Attempt, bucketLockReconciliation.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	// locks left to their job stay expired, paging by id keeps them from being looked into over and over in a pass
+	afterId := ""
+	for {
+		locks, err := w.queries.BucketListExpiredLocks(ctx, &database.BucketListExpiredLocksParams{
+			LeaseTimeout: w.leaseTimeout,
+			AfterID:      afterId,
+			Limit:        bucketLockReconciliationPageSize,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to list expired bucket locks",
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		// a lock that cannot be reconciled is logged and looked into again by the next pass, it does not hold up the
+		// others
+		for _, lock := range locks {
+			_ = w.reconcile(ctx, lock, op)
+		}
+
+		if len(locks) < bucketLockReconciliationPageSize {
+			return nil
+		}
+		afterId = locks[len(locks)-1].ID
+	}
+}
+
+// lockRecovery is what the reconciler does with an expired bucket lock
+type lockRecovery int
+
+const (
+	// lockRecoveryWait leaves the lock to its job, which is still going to release it
+	lockRecoveryWait lockRecovery = iota
+	// lockRecoveryRetry makes the discarded job of the lock available again
+	lockRecoveryRetry
+	// lockRecoveryRelease unlocks the bucket and fails the operation of the lock
+	lockRecoveryRelease
+)
+
+// decideLockRecovery picks what happens to an expired lock from the job holding it, jobRow is nil when that job is
+// gone. the reason is recorded with a released lock
+func decideLockRecovery(lockJobId *int64, jobRow *rivertype.JobRow, retries int32, maxRetries int32) (lockRecovery, string) {
+	// locks taken before leases were tracked have no job, nothing is ever going to release them
+	if lockJobId == nil {
+		return lockRecoveryRelease, "lock is not held by any job"
+	}
+
+	if jobRow == nil {
+		return lockRecoveryRelease, fmt.Sprintf("job %d holding the lock is gone", *lockJobId)
+	}
+
+	switch jobRow.State {
+	case rivertype.JobStateAvailable, rivertype.JobStateScheduled, rivertype.JobStateRetryable, rivertype.JobStateRunning:
+		// river rescues jobs stuck running, a job still queued releases the lock itself once it is worked
+		return lockRecoveryWait, ""
+	case rivertype.JobStateDiscarded:
+		if retries < maxRetries {
+			return lockRecoveryRetry, ""
+		}
+		return lockRecoveryRelease, fmt.Sprintf("job %d holding the lock was discarded after %d attempts", jobRow.ID, jobRow.Attempt)
+	default:
+		return lockRecoveryRelease, fmt.Sprintf("job %d holding the lock finished as %s without releasing it", jobRow.ID, jobRow.State)
+	}
+}
+
+func (w *BucketLockReconciliationWorker) reconcile(ctx context.Context, lock *database.BucketListExpiredLocksRow, op string) error {
+	var jobRow *rivertype.JobRow
+	if lock.LockJobID != nil {
+		var err error
+		jobRow, err = w.queries.JobGetById(ctx, *lock.LockJobID)
+		if err != nil && !database.IsNotFoundError(err) {
+			w.logger.Error(
+				"failed to get job holding bucket lock",
+				zap.String("bucket_id", lock.ID),
+				zap.Int64("job_id", *lock.LockJobID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	recovery, reason := decideLockRecovery(lock.LockJobID, jobRow, lock.LockRecoveryRetries, w.maxRetries)
+	switch recovery {
+	case lockRecoveryWait:
+		w.logger.Warn(
+			"bucket lock lease expired while its job is not finished",
+			zap.String("bucket_id", lock.ID),
+			zap.Int64("job_id", jobRow.ID),
+			zap.String("job_state", string(jobRow.State)),
+			zapfield.Operation(op),
+		)
+		return nil
+	case lockRecoveryRetry:
+		return w.retry(ctx, lock, jobRow, op)
+	default:
+		return w.release(ctx, lock, reason, op)
+	}
+}
+
+// retry makes the discarded job of the lock available again and renews its lease so the job has the lease timeout
+// to pick up from its last checkpoint
+func (w *BucketLockReconciliationWorker) retry(ctx context.Context, lock *database.BucketListExpiredLocksRow, jobRow *rivertype.JobRow, op string) error {
+	retried := false
+	err := w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		// no rows means the job left the discarded state since it was read, it is looked into again by the next pass
+		if _, err := w.queries.WithTx(tx).JobRetry(ctx, jobRow.ID); err != nil {
+			if database.IsNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+		retried = true
+
+		if err := w.queries.WithTx(tx).OperationReopenByJobId(ctx, jobRow.ID); err != nil {
+			return err
+		}
+
+		err := w.queries.WithTx(tx).BucketLockRecoveryRetry(ctx, &database.BucketLockRecoveryRetryParams{
+			ID:        lock.ID,
+			LockJobID: jobRow.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return w.audit(ctx, w.queries.WithTx(tx), models.AuditActionBucketLockRecoveryRetry, lock, map[string]any{
+			"job_id":  jobRow.ID,
+			"retry":   lock.LockRecoveryRetries + 1,
+			"retries": w.maxRetries,
+		})
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to retry job holding expired bucket lock",
+			zap.String("bucket_id", lock.ID),
+			zap.Int64("job_id", jobRow.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if !retried {
+		w.logger.Info(
+			"job holding expired bucket lock changed state before it was retried",
+			zap.String("bucket_id", lock.ID),
+			zap.Int64("job_id", jobRow.ID),
+			zapfield.Operation(op),
+		)
+		return nil
+	}
+
+	w.logger.Info(
+		"retried job holding expired bucket lock",
+		zap.String("bucket_id", lock.ID),
+		zap.Int64("job_id", jobRow.ID),
+		zap.Int32("retry", lock.LockRecoveryRetries+1),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+// release unlocks the bucket and records the operation of the lock as failed with reason. the objects the job did
+// not get to are left in the bucket. a bucket force unlocked or locked by another job since it was listed is left alone
+func (w *BucketLockReconciliationWorker) release(ctx context.Context, lock *database.BucketListExpiredLocksRow, reason string, op string) error {
+	released := false
+	err := w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		rows, err := w.queries.WithTx(tx).BucketUnlockExpired(ctx, &database.BucketUnlockExpiredParams{
+			ID:        lock.ID,
+			LockJobID: lock.LockJobID,
+		})
+		if err != nil || rows == 0 {
+			return err
+		}
+		released = true
+
+		if lock.LockJobID != nil {
+			state := models.OperationStateFailed
+			message := fmt.Sprintf("bucket lock released by the lock reconciler: %s", reason)
+
+			err := w.queries.WithTx(tx).OperationFinishByJobId(ctx, &database.OperationFinishByJobIdParams{
+				JobID: *lock.LockJobID,
+				State: &state,
+				Error: &message,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return w.audit(ctx, w.queries.WithTx(tx), models.AuditActionBucketLockReleased, lock, map[string]any{
+			"job_id":      lock.LockJobID,
+			"lock_reason": lock.LockReason,
+			"locked_at":   lock.LockedAt,
+			"reason":      reason,
+		})
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to release expired bucket lock",
+			zap.String("bucket_id", lock.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if !released {
+		return nil
+	}
+
+	w.logger.Warn(
+		"released expired bucket lock",
+		zap.String("bucket_id", lock.ID),
+		zap.String("reason", reason),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+func (w *BucketLockReconciliationWorker) audit(ctx context.Context, queries *database.Queries, action string, lock *database.BucketListExpiredLocksRow, details map[string]any) error {
+	detailsBytes, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return queries.AuditEventCreate(ctx, &database.AuditEventCreateParams{
+		Action:    action,
+		Principal: bucketLockReconcilerPrincipal,
+		BucketID:  &lock.ID,
+		Details:   detailsBytes,
+	})
+}
+
+func NewBucketLockReconciliationWorker(db *pgxpool.Pool, config *config.Config, logger *zap.Logger) *BucketLockReconciliationWorker {
+	return &BucketLockReconciliationWorker{
+		queries:      database.New(db),
+		transaction:  database.NewTransaction(db),
+		leaseTimeout: config.BucketLockLeaseTimeout,
+		maxRetries:   config.BucketLockRecoveryRetries,
+		logger:       logger,
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecideLockRecovery(t *testing.T) {
+	jobId := int64(42)
+
+	tests := []struct {
+		name      string
+		lockJobId *int64
+		jobRow    *rivertype.JobRow
+		retries   int32
+		expected  lockRecovery
+		reason    string
+	}{
+		{
+			name:     "Lock Without Job",
+			expected: lockRecoveryRelease,
+			reason:   "lock is not held by any job",
+		},
+		{
+			name:      "Job Gone",
+			lockJobId: &jobId,
+			expected:  lockRecoveryRelease,
+			reason:    "job 42 holding the lock is gone",
+		},
+		{
+			name:      "Job Available",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateAvailable},
+			expected:  lockRecoveryWait,
+		},
+		{
+			name:      "Job Scheduled",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateScheduled},
+			expected:  lockRecoveryWait,
+		},
+		{
+			name:      "Job Retryable",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateRetryable},
+			expected:  lockRecoveryWait,
+		},
+		{
+			name:      "Job Running",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateRunning},
+			expected:  lockRecoveryWait,
+		},
+		{
+			name:      "Job Discarded With Retries Left",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateDiscarded, Attempt: 25},
+			retries:   2,
+			expected:  lockRecoveryRetry,
+		},
+		{
+			name:      "Job Discarded Without Retries Left",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateDiscarded, Attempt: 25},
+			retries:   3,
+			expected:  lockRecoveryRelease,
+			reason:    "job 42 holding the lock was discarded after 25 attempts",
+		},
+		{
+			name:      "Job Completed",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateCompleted},
+			expected:  lockRecoveryRelease,
+			reason:    "job 42 holding the lock finished as completed without releasing it",
+		},
+		{
+			name:      "Job Cancelled",
+			lockJobId: &jobId,
+			jobRow:    &rivertype.JobRow{ID: jobId, State: rivertype.JobStateCancelled},
+			expected:  lockRecoveryRelease,
+			reason:    "job 42 holding the lock finished as cancelled without releasing it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recovery, reason := decideLockRecovery(tt.lockJobId, tt.jobRow, tt.retries, 3)
+			assert.Equal(t, tt.expected, recovery)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
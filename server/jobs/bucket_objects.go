@@ -0,0 +1,250 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// errBucketLockLost stops a job whose bucket was unlocked or locked for another job while it worked
+var errBucketLockLost = errors.New("bucket lock is no longer held by the job")
+
+// jobProgressStore records the checkpoints of jobs
+type jobProgressStore interface {
+	JobUpdateProgress(ctx context.Context, id int64, progress []byte) error
+}
+
+// bucketLockStore renews the leases of bucket locks
+type bucketLockStore interface {
+	BucketLockHeartbeat(ctx context.Context, arg *database.BucketLockHeartbeatParams) (int64, error)
+}
+
+// bucketObjectsCatalog is the part of the catalog bucketObjectsDeleter works with
+type bucketObjectsCatalog interface {
+	jobProgressStore
+	bucketLockStore
+	BucketGetObjectCountById(ctx context.Context, id string) (*database.BucketGetObjectCountByIdRow, error)
+	ObjectListByBucketIdAfterId(ctx context.Context, arg *database.ObjectListByBucketIdAfterIdParams) ([]*database.ObjectListByBucketIdAfterIdRow, error)
+	ObjectDeleteMany(ctx context.Context, ids []string) error
+}
+
+// bucketObjectsStorage is the part of storage bucketObjectsDeleter works with
+type bucketObjectsStorage interface {
+	DeleteObjects(ctx context.Context, objectsDelete *storage.ObjectsDelete) error
+}
+
+// bucketObjectsDeleter deletes every object of a bucket for the emptying and deletion workers. objects are listed by
+// id after the cursor of the last checkpoint, deleted from storage in a single request per batch and then from the
+// catalog, and a checkpoint is recorded after every batch so a retried job resumes where the previous attempt stopped.
+// every checkpoint also renews the lease of the bucket lock held by the job
+type bucketObjectsDeleter struct {
+	queries bucketObjectsCatalog
+	storage bucketObjectsStorage
+	logger  *zap.Logger
+}
+
+// deleteObjects deletes the objects of the bucket, except the ones under a legal hold or retention. it returns the
+// final progress of the job, the objects kept are counted as skipped
+func (d *bucketObjectsDeleter) deleteObjects(ctx context.Context, job *rivertype.JobRow, bucket *database.StorageBucket, bypassGovernance bool, op string) (*models.JobProgress, error) {
+	progress, err := models.ParseJobProgress(job.Metadata)
+	if err != nil {
+		d.logger.Warn(
+			"ignoring unreadable job progress",
+			zap.Int64("job_id", job.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		progress = nil
+	}
+
+	if progress == nil {
+		progress = &models.JobProgress{}
+
+		count, err := d.queries.BucketGetObjectCountById(ctx, bucket.ID)
+		if err == nil {
+			progress.Total = count.Count
+		} else if !database.IsNotFoundError(err) {
+			d.logger.Error(
+				"failed to count objects",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+
+		if err = d.saveProgress(ctx, job.ID, progress, op); err != nil {
+			return nil, err
+		}
+	} else {
+		d.logger.Info(
+			"resuming from job progress",
+			zap.Int64("job_id", job.ID),
+			zap.String("cursor", progress.Cursor),
+			zap.Int64("processed", progress.Processed),
+			zapfield.Operation(op),
+		)
+	}
+
+	if err = d.heartbeat(ctx, job.ID, bucket.ID, op); err != nil {
+		return nil, err
+	}
+
+	for {
+		objects, err := d.queries.ObjectListByBucketIdAfterId(ctx, &database.ObjectListByBucketIdAfterIdParams{
+			BucketID: bucket.ID,
+			AfterID:  progress.Cursor,
+			Limit:    storage.DeleteObjectsMaxCount,
+		})
+		if err != nil {
+			d.logger.Error(
+				"failed to list objects",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return progress, nil
+		}
+
+		ids := make([]string, 0, len(objects))
+		names := make([]string, 0, len(objects))
+		skipped := int64(0)
+
+		for _, object := range objects {
+			// a legal hold or retention may have been placed since the job was queued, those objects are kept
+			if models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, bypassGovernance) {
+				d.logger.Warn(
+					"skipping deletion of locked object",
+					zap.String("object_id", object.ID),
+					zapfield.Operation(op),
+				)
+				skipped++
+				continue
+			}
+
+			ids = append(ids, object.ID)
+			names = append(names, object.Name)
+		}
+
+		err = d.storage.DeleteObjects(ctx, &storage.ObjectsDelete{
+			Bucket: bucket.StoragePrefix,
+			Names:  names,
+		})
+		if err != nil {
+			d.logger.Error(
+				"failed to delete objects from storage",
+				zap.String("bucket_name", bucket.Name),
+				zap.Int("count", len(names)),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+
+		if err = enqueueReplicaDeletion(ctx, bucket.Replica, bucket.StoragePrefix, names, d.logger, op); err != nil {
+			return nil, err
+		}
+
+		if len(ids) > 0 {
+			err = d.queries.ObjectDeleteMany(ctx, ids)
+			if err != nil {
+				d.logger.Error(
+					"failed to delete objects from database",
+					zap.String("bucket_id", bucket.ID),
+					zap.Int("count", len(ids)),
+					zapfield.Operation(op),
+					zap.Error(err),
+				)
+				return nil, err
+			}
+		}
+
+		progress.Cursor = objects[len(objects)-1].ID
+		progress.Processed += int64(len(objects))
+		progress.Skipped += skipped
+
+		if err = d.saveProgress(ctx, job.ID, progress, op); err != nil {
+			return nil, err
+		}
+
+		if err = d.heartbeat(ctx, job.ID, bucket.ID, op); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *bucketObjectsDeleter) saveProgress(ctx context.Context, jobId int64, progress *models.JobProgress, op string) error {
+	return saveJobProgress(ctx, d.queries, jobId, progress, d.logger, op)
+}
+
+// saveJobProgress records the checkpoint of a job in its metadata
+func saveJobProgress(ctx context.Context, queries jobProgressStore, jobId int64, progress *models.JobProgress, logger *zap.Logger, op string) error {
+	progress.UpdatedAt = time.Now()
+
+	progressBytes, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	err = queries.JobUpdateProgress(ctx, jobId, progressBytes)
+	if err != nil {
+		logger.Error(
+			"failed to save job progress",
+			zap.Int64("job_id", jobId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// heartbeat renews the lease of the bucket lock held by the job
+func (d *bucketObjectsDeleter) heartbeat(ctx context.Context, jobId int64, bucketId string, op string) error {
+	return renewBucketLock(ctx, d.queries, jobId, bucketId, d.logger, op)
+}
+
+// renewBucketLock renews the lease of the bucket lock held by a job. a lock that was force unlocked or released by the
+// lock reconciler cancels the job instead of letting it change a bucket that is in use again
+func renewBucketLock(ctx context.Context, queries bucketLockStore, jobId int64, bucketId string, logger *zap.Logger, op string) error {
+	renewed, err := queries.BucketLockHeartbeat(ctx, &database.BucketLockHeartbeatParams{
+		ID:        bucketId,
+		LockJobID: jobId,
+	})
+	if err != nil {
+		logger.Error(
+			"failed to renew bucket lock",
+			zap.Int64("job_id", jobId),
+			zap.String("bucket_id", bucketId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if renewed == 0 {
+		logger.Warn(
+			"stopping job that lost its bucket lock",
+			zap.Int64("job_id", jobId),
+			zap.String("bucket_id", bucketId),
+			zapfield.Operation(op),
+		)
+		return river.JobCancel(fmt.Errorf("bucket '%s': %w", bucketId, errBucketLockLost))
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"go.uber.org/zap"
+)
+
+// fakeBucketObjectsCatalog keeps the objects of a single bucket sorted by id
+type fakeBucketObjectsCatalog struct {
+	objects    []*database.ObjectListByBucketIdAfterIdRow
+	progress   []*models.JobProgress
+	lockLost   bool
+	counted    bool
+	heartbeats int
+}
+
+func (c *fakeBucketObjectsCatalog) JobUpdateProgress(_ context.Context, _ int64, progress []byte) error {
+	var saved models.JobProgress
+	if err := json.Unmarshal(progress, &saved); err != nil {
+		return err
+	}
+	c.progress = append(c.progress, &saved)
+	return nil
+}
+
+func (c *fakeBucketObjectsCatalog) BucketLockHeartbeat(context.Context, *database.BucketLockHeartbeatParams) (int64, error) {
+	c.heartbeats++
+	if c.lockLost {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (c *fakeBucketObjectsCatalog) BucketGetObjectCountById(_ context.Context, id string) (*database.BucketGetObjectCountByIdRow, error) {
+	c.counted = true
+	return &database.BucketGetObjectCountByIdRow{ID: id, Count: int64(len(c.objects))}, nil
+}
+
+func (c *fakeBucketObjectsCatalog) ObjectListByBucketIdAfterId(_ context.Context, arg *database.ObjectListByBucketIdAfterIdParams) ([]*database.ObjectListByBucketIdAfterIdRow, error) {
+	start := sort.Search(len(c.objects), func(i int) bool { return c.objects[i].ID > arg.AfterID })
+	end := min(start+int(arg.Limit), len(c.objects))
+	return c.objects[start:end], nil
+}
+
+func (c *fakeBucketObjectsCatalog) ObjectDeleteMany(_ context.Context, ids []string) error {
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	kept := c.objects[:0:0]
+	for _, object := range c.objects {
+		if !deleted[object.ID] {
+			kept = append(kept, object)
+		}
+	}
+	c.objects = kept
+
+	return nil
+}
+
+type fakeBucketObjectsStorage struct {
+	deleted []string
+}
+
+func (s *fakeBucketObjectsStorage) DeleteObjects(_ context.Context, objectsDelete *storage.ObjectsDelete) error {
+	s.deleted = append(s.deleted, objectsDelete.Names...)
+	return nil
+}
+
+func newFakeObjects(count int, legalHold func(i int) bool) []*database.ObjectListByBucketIdAfterIdRow {
+	objects := make([]*database.ObjectListByBucketIdAfterIdRow, 0, count)
+	for i := 0; i < count; i++ {
+		objects = append(objects, &database.ObjectListByBucketIdAfterIdRow{
+			ID:        fmt.Sprintf("object_%05d", i),
+			Name:      fmt.Sprintf("avatars/%05d.png", i),
+			LegalHold: legalHold != nil && legalHold(i),
+		})
+	}
+	return objects
+}
+
+func newDeleterTest(objects []*database.ObjectListByBucketIdAfterIdRow) (*bucketObjectsDeleter, *fakeBucketObjectsCatalog, *fakeBucketObjectsStorage) {
+	catalog := &fakeBucketObjectsCatalog{objects: objects}
+	store := &fakeBucketObjectsStorage{}
+
+	return &bucketObjectsDeleter{queries: catalog, storage: store, logger: zap.NewNop()}, catalog, store
+}
+
+func TestBucketObjectsDeleter_ResumesFromSavedCursor(t *testing.T) {
+	deleter, catalog, store := newDeleterTest(newFakeObjects(5, nil))
+
+	metadata, err := json.Marshal(map[string]any{
+		"progress": models.JobProgress{Cursor: "object_00002", Total: 5, Processed: 3, Skipped: 1},
+	})
+	require.NoError(t, err)
+
+	progress, err := deleter.deleteObjects(context.Background(), &rivertype.JobRow{ID: 1, Metadata: metadata}, &database.StorageBucket{ID: "bucket_1", StoragePrefix: "bucket_1"}, false, "test")
+	require.NoError(t, err)
+
+	assert.False(t, catalog.counted, "a resumed job keeps the total it started with")
+	assert.Equal(t, []string{"avatars/00003.png", "avatars/00004.png"}, store.deleted)
+	assert.Len(t, catalog.objects, 3)
+	assert.Equal(t, "object_00004", progress.Cursor)
+	assert.Equal(t, int64(5), progress.Total)
+	assert.Equal(t, int64(5), progress.Processed)
+	assert.Equal(t, int64(1), progress.Skipped)
+}
+
+func TestBucketObjectsDeleter_AdvancesCursorPastLockedObjects(t *testing.T) {
+	count := storage.DeleteObjectsMaxCount + 500
+	retainUntil := time.Now().Add(time.Hour)
+	governance := models.ObjectRetentionModeGovernance
+
+	objects := newFakeObjects(count, func(i int) bool { return i%250 == 0 })
+	objects[1].RetentionMode = &governance
+	objects[1].RetainUntil = &retainUntil
+
+	deleter, catalog, store := newDeleterTest(objects)
+
+	progress, err := deleter.deleteObjects(context.Background(), &rivertype.JobRow{ID: 1}, &database.StorageBucket{ID: "bucket_1", StoragePrefix: "bucket_1"}, false, "test")
+	require.NoError(t, err)
+
+	kept := count/250 + 1
+	assert.True(t, catalog.counted)
+	assert.Len(t, catalog.objects, kept)
+	assert.Len(t, store.deleted, count-kept)
+	assert.Equal(t, int64(count), progress.Total)
+	assert.Equal(t, int64(count), progress.Processed)
+	assert.Equal(t, int64(kept), progress.Skipped)
+	assert.Equal(t, fmt.Sprintf("object_%05d", count-1), progress.Cursor)
+
+	// the first checkpoint is taken before any object is deleted, then one after every batch
+	require.Len(t, catalog.progress, 3)
+	assert.Equal(t, "", catalog.progress[0].Cursor)
+	assert.Equal(t, fmt.Sprintf("object_%05d", storage.DeleteObjectsMaxCount-1), catalog.progress[1].Cursor)
+	assert.Equal(t, int64(storage.DeleteObjectsMaxCount), catalog.progress[1].Processed)
+	assert.Equal(t, 3, catalog.heartbeats)
+}
+
+func TestBucketObjectsDeleter_BypassesGovernance(t *testing.T) {
+	retainUntil := time.Now().Add(time.Hour)
+	governance := models.ObjectRetentionModeGovernance
+
+	objects := newFakeObjects(2, nil)
+	objects[0].RetentionMode = &governance
+	objects[0].RetainUntil = &retainUntil
+
+	deleter, catalog, _ := newDeleterTest(objects)
+
+	progress, err := deleter.deleteObjects(context.Background(), &rivertype.JobRow{ID: 1}, &database.StorageBucket{ID: "bucket_1", StoragePrefix: "bucket_1"}, true, "test")
+	require.NoError(t, err)
+
+	assert.Empty(t, catalog.objects)
+	assert.Equal(t, int64(0), progress.Skipped)
+}
+
+func TestBucketObjectsDeleter_StopsWhenLockIsLost(t *testing.T) {
+	deleter, catalog, store := newDeleterTest(newFakeObjects(3, nil))
+	catalog.lockLost = true
+
+	_, err := deleter.deleteObjects(context.Background(), &rivertype.JobRow{ID: 1}, &database.StorageBucket{ID: "bucket_1", StoragePrefix: "bucket_1"}, false, "test")
+
+	assert.ErrorContains(t, err, errBucketLockLost.Error())
+	assert.Empty(t, store.deleted)
+	assert.Len(t, catalog.objects, 3)
+}
@@ -0,0 +1,437 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketRelocationPageSize is how many keys are listed from storage per checkpoint
+const bucketRelocationPageSize = 1000
+
+type BucketRelocation struct {
+	BucketId string `json:"bucket_id"`
+	// Prefix is the prefix the content of the bucket was stored under when the relocation was queued
+	Prefix       string               `json:"prefix"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (BucketRelocation) Kind() string {
+	return "bucket.relocation"
+}
+
+func (BucketRelocation) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketRelocation}
+}
+
+// BucketRelocationWorker moves the content of a bucket stored under its name to keys under its id, so the bucket can
+// be renamed. keys are copied in key order after the name of the last checkpoint, so a retried job resumes where the
+// previous attempt stopped. once every key is copied the bucket is switched over to its id and the keys under its old
+// prefix are deleted. the bucket is locked the whole time, the content under the old prefix is left as it is until
+// the switch. archived content is restored by storage before it is copied in its storage class, the job waits for it
+// and passes over the keys it copied already. the copies on the replica of the bucket stay under the old prefix, the
+// switch marks the objects pending replication and they are replicated again under the id. the bucket records the
+// prefix holding partial or old content until it is deleted, so the storage reconciliation deletes it when the
+// relocation is cancelled or given up on
+type BucketRelocationWorker struct {
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     *storage.Storage
+	replicas    storage.Replicas
+	copier      *bucketObjectsCopier
+	operations  *bucketOperationRecorder
+	logger      *zap.Logger
+	river.WorkerDefaults[BucketRelocation]
+}
+
+func (w *BucketRelocationWorker) Work(ctx context.Context, bucketRelocation *river.Job[BucketRelocation]) (err error) {
+	const op = "BucketRelocationWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, bucketRelocation.Kind, bucketRelocation.ID, bucketRelocation.Attempt, bucketRelocation.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() {
+		err = w.operations.finish(ctx, bucketRelocation.JobRow, bucketRelocation.Args.BucketId, err, op)
+	}()
+
+	bucket, err := w.queries.BucketGetById(ctx, bucketRelocation.Args.BucketId)
+	if err != nil {
+		w.logger.Error(
+			"failed to get bucket",
+			zap.String("bucket_id", bucketRelocation.Args.BucketId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err = renewBucketLock(ctx, w.queries, bucketRelocation.ID, bucket.ID, w.logger, op); err != nil {
+		return err
+	}
+
+	if bucketRelocation.Args.Prefix == bucket.ID {
+		return river.JobCancel(errors.New("bucket is already stored under its id"))
+	}
+
+	progress, err := models.ParseJobProgress(bucketRelocation.Metadata)
+	if err != nil || progress == nil {
+		progress = &models.JobProgress{}
+	} else {
+		w.logger.Info(
+			"resuming from job progress",
+			zap.Int64("job_id", bucketRelocation.ID),
+			zap.String("cursor", progress.Cursor),
+			zap.Int64("processed", progress.Processed),
+			zapfield.Operation(op),
+		)
+	}
+
+	// an attempt that stopped after the switch only has the old keys left to delete
+	if bucket.StoragePrefix != bucket.ID {
+		err = w.queries.BucketSetStaleStoragePrefix(ctx, &database.BucketSetStaleStoragePrefixParams{
+			ID:                 bucket.ID,
+			StaleStoragePrefix: &bucket.ID,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to record partial content of bucket",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		var pending int64
+		pending, err = w.copier.copyObjects(ctx, bucketRelocation.ID, bucket, bucketRelocation.Args.Prefix, progress, op)
+		if err != nil {
+			return err
+		}
+
+		// archived keys are copied by a later pass once storage restored them, the keys copied already are passed over
+		if pending > 0 {
+			w.logger.Info(
+				"waiting for archived content to be restored",
+				zap.String("bucket_id", bucket.ID),
+				zap.Int64("pending", pending),
+				zapfield.Operation(op),
+			)
+
+			if err = saveJobProgress(ctx, w.queries, bucketRelocation.ID, &models.JobProgress{}, w.logger, op); err != nil {
+				return err
+			}
+
+			return river.JobSnooze(objectTierRestorePollInterval)
+		}
+
+		// the replication queued after the switch pages through objects by id, the progress of the copy is reset before
+		// the switch so no attempt after it resumes from the name of a key. an attempt stopping in between passes over
+		// the keys copied already
+		progress = &models.JobProgress{}
+		if err = saveJobProgress(ctx, w.queries, bucketRelocation.ID, progress, w.logger, op); err != nil {
+			return err
+		}
+
+		err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+			if err := w.queries.WithTx(tx).BucketRelocate(ctx, bucket.ID); err != nil {
+				return err
+			}
+
+			// the copies on the replica are under the old prefix, replicaFallback must not read them under the new one
+			return w.queries.WithTx(tx).ObjectResetReplicationByBucketId(ctx, bucket.ID)
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to switch bucket over to its id",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	if err = w.replicateAgain(ctx, bucketRelocation.ID, bucket, progress, op); err != nil {
+		return err
+	}
+
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: bucketRelocation.Args.Prefix,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete objects under old prefix",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("prefix", bucketRelocation.Args.Prefix),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if replica := w.replicas.Get(bucket.Replica); replica != nil {
+		err = replica.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+			Bucket: bucketRelocation.Args.Prefix,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to delete replica objects under old prefix",
+				zap.String("bucket_id", bucket.ID),
+				zap.String("prefix", bucketRelocation.Args.Prefix),
+				zap.String("replica", *bucket.Replica),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	err = w.queries.BucketClearStaleStoragePrefix(ctx, &database.BucketClearStaleStoragePrefixParams{
+		ID:                 bucket.ID,
+		StaleStoragePrefix: &bucketRelocation.Args.Prefix,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to clear old prefix of bucket",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	_, err = w.queries.BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+		ID:        bucket.ID,
+		LockJobID: bucketRelocation.ID,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to unlock bucket from database",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	w.logger.Info(
+		"bucket relocated",
+		zap.String("bucket_id", bucket.ID),
+		zap.String("prefix", bucketRelocation.Args.Prefix),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+// replicateAgain queues the replication of the objects the switch left without a copy under the new prefix on the
+// replica of the bucket. objects are listed by id after the cursor of the last checkpoint, so a retried job resumes
+// where the previous attempt stopped
+func (w *BucketRelocationWorker) replicateAgain(ctx context.Context, jobId int64, bucket *database.StorageBucket, progress *models.JobProgress, op string) error {
+	if bucket.Replica == nil {
+		return nil
+	}
+
+	for {
+		ids, err := w.queries.ObjectListPendingReplicationByBucketIdAfterId(ctx, &database.ObjectListPendingReplicationByBucketIdAfterIdParams{
+			BucketID: bucket.ID,
+			AfterID:  progress.Cursor,
+			Limit:    bucketRelocationPageSize,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to list objects pending replication",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		params := make([]river.InsertManyParams, 0, len(ids))
+		for _, id := range ids {
+			params = append(params, NewObjectReplicationJobs(ctx, id, bucket.Replica)...)
+		}
+
+		if _, err = river.ClientFromContext[pgx.Tx](ctx).InsertMany(ctx, params); err != nil {
+			w.logger.Error(
+				"failed to create replication jobs",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		progress.Cursor = ids[len(ids)-1]
+		progress.Processed += int64(len(ids))
+
+		if err = saveJobProgress(ctx, w.queries, jobId, progress, w.logger, op); err != nil {
+			return err
+		}
+
+		if err = renewBucketLock(ctx, w.queries, jobId, bucket.ID, w.logger, op); err != nil {
+			return err
+		}
+	}
+}
+
+// bucketRelocationCatalog is the part of the catalog bucketObjectsCopier works with
+type bucketRelocationCatalog interface {
+	jobProgressStore
+	bucketLockStore
+}
+
+// bucketRelocationStorage is the part of storage bucketObjectsCopier works with
+type bucketRelocationStorage interface {
+	ListObjects(ctx context.Context, objectsList *storage.ObjectsList) (*storage.ObjectsListPage, error)
+	CopyObject(ctx context.Context, objectCopy *storage.ObjectCopy) (string, error)
+	CopyLargeObject(ctx context.Context, objectCopy *storage.ObjectCopy, size int64) (string, error)
+	RestoreObject(ctx context.Context, objectRestore *storage.ObjectRestore) error
+}
+
+// bucketObjectsCopier copies the content of a bucket under its id for the relocation worker. a checkpoint is recorded
+// after every page of keys and renews the lease of the bucket lock held by the job
+type bucketObjectsCopier struct {
+	queries bucketRelocationCatalog
+	storage bucketRelocationStorage
+	logger  *zap.Logger
+}
+
+// copyObjects copies every key under the old prefix to the same name under the id of the bucket, in the storage class
+// it is stored in. keys an earlier pass copied already are passed over and keys that are gone by the time they are
+// copied are counted as skipped. archived keys cannot be copied before storage restores them, their restore is
+// requested and the number of keys waiting for one is returned
+func (c *bucketObjectsCopier) copyObjects(ctx context.Context, jobId int64, bucket *database.StorageBucket, prefix string, progress *models.JobProgress, op string) (int64, error) {
+	var pending int64
+
+	for {
+		page, err := c.storage.ListObjects(ctx, &storage.ObjectsList{
+			Bucket:     prefix,
+			StartAfter: progress.Cursor,
+			MaxKeys:    bucketRelocationPageSize,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// only the relocation writes under the id of a bucket stored under its name, the keys it copied are a subset
+		// of the keys to copy and a page of them covers the page of keys to copy
+		copiedPage, err := c.storage.ListObjects(ctx, &storage.ObjectsList{
+			Bucket:     bucket.ID,
+			StartAfter: progress.Cursor,
+			MaxKeys:    bucketRelocationPageSize,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		copied := make(map[string]int64, len(copiedPage.Objects))
+		for _, object := range copiedPage.Objects {
+			copied[object.Name] = object.Size
+		}
+
+		for _, object := range page.Objects {
+			if size, ok := copied[object.Name]; ok && size == object.Size {
+				continue
+			}
+
+			objectCopy := &storage.ObjectCopy{
+				SourceBucket:      prefix,
+				SourceName:        object.Name,
+				DestinationBucket: bucket.ID,
+				DestinationName:   object.Name,
+				StorageClass:      object.StorageClass,
+			}
+
+			if object.Size > storage.CopyObjectMaxSize {
+				_, err = c.storage.CopyLargeObject(ctx, objectCopy, object.Size)
+			} else {
+				_, err = c.storage.CopyObject(ctx, objectCopy)
+			}
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				progress.Skipped++
+				continue
+			}
+			if errors.Is(err, storage.ErrObjectArchived) {
+				err = c.storage.RestoreObject(ctx, &storage.ObjectRestore{
+					Bucket: prefix,
+					Name:   object.Name,
+					Days:   objectTierRestoreDays,
+				})
+				if err != nil {
+					c.logger.Error(
+						"failed to restore archived object",
+						zap.String("bucket_id", bucket.ID),
+						zap.String("object_name", object.Name),
+						zapfield.Operation(op),
+						zap.Error(err),
+					)
+					return 0, err
+				}
+				pending++
+				continue
+			}
+			if err != nil {
+				c.logger.Error(
+					"failed to copy object",
+					zap.String("bucket_id", bucket.ID),
+					zap.String("object_name", object.Name),
+					zapfield.Operation(op),
+					zap.Error(err),
+				)
+				return 0, err
+			}
+		}
+
+		if len(page.Objects) > 0 {
+			progress.Cursor = page.Objects[len(page.Objects)-1].Name
+			progress.Processed += int64(len(page.Objects))
+
+			if err = saveJobProgress(ctx, c.queries, jobId, progress, c.logger, op); err != nil {
+				return 0, err
+			}
+		}
+
+		if err = renewBucketLock(ctx, c.queries, jobId, bucket.ID, c.logger, op); err != nil {
+			return 0, err
+		}
+
+		if !page.Truncated {
+			return pending, nil
+		}
+	}
+}
+
+func NewBucketRelocationWorker(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, logger *zap.Logger) *BucketRelocationWorker {
+	queries := database.New(db)
+
+	return &BucketRelocationWorker{
+		queries:     queries,
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		replicas:    replicas,
+		copier: &bucketObjectsCopier{
+			queries: queries,
+			storage: storage,
+			logger:  logger,
+		},
+		operations: &bucketOperationRecorder{
+			queries:       queries,
+			unlocksBucket: true,
+			logger:        logger,
+		},
+		logger: logger,
+	}
+}
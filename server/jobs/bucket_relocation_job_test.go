@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"go.uber.org/zap"
+)
+
+// fakeBucketRelocationStorage keeps keys by prefix, keys in the glacier storage class cannot be copied until they are
+// restored
+type fakeBucketRelocationStorage struct {
+	keys     map[string]map[string]*storage.ListedObject
+	restored map[string]bool
+	copies   []*storage.ObjectCopy
+}
+
+func (s *fakeBucketRelocationStorage) ListObjects(_ context.Context, objectsList *storage.ObjectsList) (*storage.ObjectsListPage, error) {
+	names := make([]string, 0, len(s.keys[objectsList.Bucket]))
+	for name := range s.keys[objectsList.Bucket] {
+		if name > objectsList.StartAfter && strings.HasPrefix(name, objectsList.Prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	page := &storage.ObjectsListPage{}
+	if len(names) > int(objectsList.MaxKeys) {
+		names = names[:objectsList.MaxKeys]
+		page.Truncated = true
+	}
+	for _, name := range names {
+		page.Objects = append(page.Objects, s.keys[objectsList.Bucket][name])
+	}
+
+	return page, nil
+}
+
+func (s *fakeBucketRelocationStorage) CopyObject(_ context.Context, objectCopy *storage.ObjectCopy) (string, error) {
+	source, ok := s.keys[objectCopy.SourceBucket][objectCopy.SourceName]
+	if !ok {
+		return "", storage.ErrObjectNotFound
+	}
+	if source.StorageClass == "GLACIER" && !s.restored[objectCopy.SourceName] {
+		return "", storage.ErrObjectArchived
+	}
+
+	if s.keys[objectCopy.DestinationBucket] == nil {
+		s.keys[objectCopy.DestinationBucket] = map[string]*storage.ListedObject{}
+	}
+	s.keys[objectCopy.DestinationBucket][objectCopy.DestinationName] = &storage.ListedObject{
+		Name:         objectCopy.DestinationName,
+		Size:         source.Size,
+		StorageClass: objectCopy.StorageClass,
+	}
+	s.copies = append(s.copies, objectCopy)
+
+	return "etag", nil
+}
+
+func (s *fakeBucketRelocationStorage) CopyLargeObject(ctx context.Context, objectCopy *storage.ObjectCopy, _ int64) (string, error) {
+	return s.CopyObject(ctx, objectCopy)
+}
+
+func (s *fakeBucketRelocationStorage) RestoreObject(_ context.Context, objectRestore *storage.ObjectRestore) error {
+	s.restored[objectRestore.Name] = true
+	return nil
+}
+
+func newRelocationTest(count int, storageClass func(i int) string) (*bucketObjectsCopier, *fakeBucketRelocationStorage, *fakeBucketObjectsCatalog) {
+	keys := make(map[string]*storage.ListedObject, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("avatars/%05d.png", i)
+		keys[name] = &storage.ListedObject{Name: name, Size: 1024, StorageClass: storageClass(i)}
+	}
+
+	store := &fakeBucketRelocationStorage{
+		keys:     map[string]map[string]*storage.ListedObject{"avatar": keys},
+		restored: map[string]bool{},
+	}
+	catalog := &fakeBucketObjectsCatalog{}
+
+	return &bucketObjectsCopier{queries: catalog, storage: store, logger: zap.NewNop()}, store, catalog
+}
+
+func TestBucketObjectsCopier_WaitsForArchivedContent(t *testing.T) {
+	copier, store, catalog := newRelocationTest(bucketRelocationPageSize+10, func(i int) string {
+		if i%100 == 0 {
+			return "GLACIER"
+		}
+		return ""
+	})
+	bucket := &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "avatar"}
+
+	pending, err := copier.copyObjects(context.Background(), 1, bucket, "avatar", &models.JobProgress{}, "test")
+	require.NoError(t, err)
+
+	archived := (bucketRelocationPageSize+10)/100 + 1
+	assert.Equal(t, int64(archived), pending)
+	assert.Len(t, store.restored, archived)
+	assert.Len(t, store.copies, bucketRelocationPageSize+10-archived)
+	assert.Equal(t, 2, catalog.heartbeats)
+
+	// the next pass only copies the restored keys, in the storage class they were archived in
+	store.copies = nil
+
+	pending, err = copier.copyObjects(context.Background(), 1, bucket, "avatar", &models.JobProgress{}, "test")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(0), pending)
+	require.Len(t, store.copies, archived)
+	for _, objectCopy := range store.copies {
+		assert.Equal(t, "GLACIER", objectCopy.StorageClass)
+		assert.Equal(t, bucket.ID, objectCopy.DestinationBucket)
+	}
+	assert.Len(t, store.keys[bucket.ID], bucketRelocationPageSize+10)
+}
+
+func TestBucketObjectsCopier_ResumesFromCursor(t *testing.T) {
+	copier, store, _ := newRelocationTest(5, func(int) string { return "" })
+	bucket := &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "avatar"}
+
+	progress := &models.JobProgress{Cursor: "avatars/00002.png", Processed: 3}
+
+	pending, err := copier.copyObjects(context.Background(), 1, bucket, "avatar", progress, "test")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(0), pending)
+	require.Len(t, store.copies, 2)
+	assert.Equal(t, "avatars/00003.png", store.copies[0].SourceName)
+	assert.Equal(t, "avatars/00004.png", progress.Cursor)
+	assert.Equal(t, int64(5), progress.Processed)
+}
@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/archive"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketRestoreCheckpointInterval is how often the restore records its progress and renews its bucket lock, objects
+// are restored one at a time and a large one can take a while
+const bucketRestoreCheckpointInterval = time.Minute
+
+type BucketRestore struct {
+	BucketId     string               `json:"bucket_id"`
+	Archive      models.BucketArchive `json:"archive"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (BucketRestore) Kind() string {
+	return "bucket.restore"
+}
+
+func (BucketRestore) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueBucketRestore}
+}
+
+// BucketRestoreWorker restores the objects of an archive into the bucket that was recreated and locked for it. objects
+// keep their id when the bucket kept its own, their metadata, mime type, tags, legal hold and retention, and go through
+// the scan and processors of the bucket like an upload. archives are read in order, so a retried job reads the archive from the start again and
+// passes over the objects restored already. the bucket is unlocked once every object is restored
+type BucketRestoreWorker struct {
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     *storage.Storage
+	archives    *archive.Store
+	scan        bool
+	operations  *bucketOperationRecorder
+	logger      *zap.Logger
+	river.WorkerDefaults[BucketRestore]
+}
+
+func (w *BucketRestoreWorker) Work(ctx context.Context, bucketRestore *river.Job[BucketRestore]) (err error) {
+	const op = "BucketRestoreWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, bucketRestore.Kind, bucketRestore.ID, bucketRestore.Attempt, bucketRestore.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() { err = w.operations.finish(ctx, bucketRestore.JobRow, bucketRestore.Args.BucketId, err, op) }()
+
+	bucket, err := w.queries.BucketGetById(ctx, bucketRestore.Args.BucketId)
+	if err != nil {
+		w.logger.Error(
+			"failed to get bucket",
+			zap.String("bucket_id", bucketRestore.Args.BucketId),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err = renewBucketLock(ctx, w.queries, bucketRestore.ID, bucket.ID, w.logger, op); err != nil {
+		return err
+	}
+
+	reader, err := w.archives.Open(ctx, &bucketRestore.Args.Archive)
+	if err != nil {
+		return w.archiveError(err, bucketRestore.Args.Archive.Path, op)
+	}
+	defer reader.Close()
+
+	manifest, err := reader.ReadManifest()
+	if err != nil {
+		return w.archiveError(err, bucketRestore.Args.Archive.Path, op)
+	}
+
+	progress := &models.JobProgress{Total: manifest.ObjectCount}
+	checkpointAt := time.Now()
+
+	for {
+		object, content, err := reader.NextObject()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return w.archiveError(err, bucketRestore.Args.Archive.Path, op)
+		}
+
+		if err = w.restoreObject(ctx, bucket, manifest.Bucket.Id, object, content, op); err != nil {
+			return err
+		}
+
+		progress.Cursor = object.Id
+		progress.Processed++
+
+		if time.Since(checkpointAt) >= bucketRestoreCheckpointInterval {
+			if err = saveJobProgress(ctx, w.queries, bucketRestore.ID, progress, w.logger, op); err != nil {
+				return err
+			}
+
+			if err = renewBucketLock(ctx, w.queries, bucketRestore.ID, bucket.ID, w.logger, op); err != nil {
+				return err
+			}
+
+			checkpointAt = time.Now()
+		}
+	}
+
+	if err = saveJobProgress(ctx, w.queries, bucketRestore.ID, progress, w.logger, op); err != nil {
+		return err
+	}
+
+	_, err = w.queries.BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+		ID:        bucket.ID,
+		LockJobID: bucketRestore.ID,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to unlock bucket from database",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// restoredObjectId is the id an archived object is restored under. objects keep their archived id when the bucket
+// kept its own, a copy restored next to the archived bucket gets new ones since the archived ids are still in use
+func restoredObjectId(bucketId string, archivedBucketId string, objectId string) *string {
+	if bucketId != archivedBucketId {
+		return nil
+	}
+	return &objectId
+}
+
+// restoreObject puts the content of an object into storage and then recreates its catalog row. an object restored by
+// an earlier attempt is passed over, an archived id taken by an object of another bucket stops the restore for good
+func (w *BucketRestoreWorker) restoreObject(ctx context.Context, bucket *database.StorageBucket, archivedBucketId string, object *archive.Object, content io.Reader, op string) error {
+	objectId := restoredObjectId(bucket.ID, archivedBucketId, object.Id)
+
+	var existing *database.StorageObject
+	var err error
+	if objectId != nil {
+		existing, err = w.queries.ObjectGetById(ctx, object.Id)
+	} else {
+		existing, err = w.queries.ObjectGetByBucketIdAndName(ctx, &database.ObjectGetByBucketIdAndNameParams{
+			BucketID: bucket.ID,
+			Name:     object.Name,
+		})
+	}
+	if err != nil && !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get restored object",
+			zap.String("object_id", object.Id),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if existing != nil {
+		if existing.BucketID == bucket.ID {
+			return nil
+		}
+		return river.JobCancel(fmt.Errorf("object id '%s' is in use by bucket '%s', objects are restored under the id they were archived with", object.Id, existing.BucketID))
+	}
+
+	_, err = w.storage.UploadObject(ctx, &storage.ObjectUpload{
+		Bucket:        bucket.StoragePrefix,
+		Name:          object.Name,
+		ContentType:   object.MimeType,
+		ContentLength: object.Size,
+		Content:       content,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to upload restored object",
+			zap.String("object_id", object.Id),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	metadata := []byte(object.Metadata)
+	if string(metadata) == "null" {
+		metadata = nil
+	}
+
+	err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		id, err := w.queries.WithTx(tx).ObjectRestore(ctx, &database.ObjectRestoreParams{
+			ID:            objectId,
+			BucketID:      bucket.ID,
+			Name:          object.Name,
+			MimeType:      object.MimeType,
+			Size:          object.Size,
+			Metadata:      metadata,
+			UploadStatus:  models.ObjectUploadStatusPending,
+			LegalHold:     object.LegalHold,
+			RetentionMode: object.RetentionMode,
+			RetainUntil:   object.RetainUntil,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(object.Tags) > 0 {
+			keys := make([]string, 0, len(object.Tags))
+			for key := range object.Tags {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			values := make([]string, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, object.Tags[key])
+			}
+
+			err = w.queries.WithTx(tx).ObjectTagCreateMany(ctx, &database.ObjectTagCreateManyParams{
+				ObjectID: id,
+				Keys:     keys,
+				Values:   values,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		status, params := CompletedUpload(ctx, id, object.MimeType, bucket.Processors, bucket.Replica, w.scan)
+
+		err = w.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
+			ID:           id,
+			UploadStatus: status,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(params) == 0 {
+			return nil
+		}
+
+		_, err = river.ClientFromContext[pgx.Tx](ctx).InsertManyTx(ctx, tx, params)
+		return err
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to restore object",
+			zap.String("object_id", object.Id),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// archiveError cancels the job for archives that are gone or cannot be restored, retrying does not change them
+func (w *BucketRestoreWorker) archiveError(err error, path string, op string) error {
+	if errors.Is(err, archive.ErrArchiveNotFound) || errors.Is(err, archive.ErrInvalidArchive) || errors.Is(err, archive.ErrLocalArchivesDisabled) {
+		w.logger.Error(
+			"cannot restore archive",
+			zap.String("archive_path", path),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return river.JobCancel(err)
+	}
+
+	w.logger.Error(
+		"failed to read archive",
+		zap.String("archive_path", path),
+		zapfield.Operation(op),
+		zap.Error(err),
+	)
+	return err
+}
+
+func NewBucketRestoreWorker(db *pgxpool.Pool, storage *storage.Storage, archives *archive.Store, scan bool, logger *zap.Logger) *BucketRestoreWorker {
+	queries := database.New(db)
+
+	return &BucketRestoreWorker{
+		queries:     queries,
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		archives:    archives,
+		scan:        scan,
+		operations: &bucketOperationRecorder{
+			queries:       queries,
+			unlocksBucket: true,
+			logger:        logger,
+		},
+		logger: logger,
+	}
+}
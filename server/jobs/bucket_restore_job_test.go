@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoredObjectId(t *testing.T) {
+	objectId := "object_01HPG4GN5JY2Z6S0638ERSG376"
+
+	tests := []struct {
+		name             string
+		bucketId         string
+		archivedBucketId string
+		expected         *string
+	}{
+		{
+			name:             "Archived Bucket Id Kept",
+			bucketId:         "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+			archivedBucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+			expected:         &objectId,
+		},
+		{
+			name:             "Copy Next To Archived Bucket",
+			bucketId:         "bucket_01HPG4GN5JY2Z6S0638ERSG377",
+			archivedBucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+		},
+		{
+			name:             "Archive Without Bucket Id",
+			bucketId:         "bucket_01HPG4GN5JY2Z6S0638ERSG377",
+			archivedBucketId: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, restoredObjectId(tt.bucketId, tt.archivedBucketId, objectId))
+		})
+	}
+}
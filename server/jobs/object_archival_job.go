@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// objectArchivalInterval is how often objects are moved to the cold tier, archive_after_days is counted in days so
+// objects are at most this late
+const objectArchivalInterval = 24 * time.Hour
+
+type ObjectArchival struct {
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectArchival) Kind() string {
+	return "object.archival"
+}
+
+func (ObjectArchival) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectArchival}
+}
+
+// ObjectArchivalWorker moves the objects that were not accessed for the archive_after_days of their bucket to the cold
+// tier. the content is copied onto itself in the cold storage class before the object is marked cold, so an object
+// is never marked cold while its content can still be read
+type ObjectArchivalWorker struct {
+	queries          *database.Queries
+	storage          *storage.Storage
+	coldStorageClass string
+	logger           *zap.Logger
+	river.WorkerDefaults[ObjectArchival]
+}
+
+func (w *ObjectArchivalWorker) Work(ctx context.Context, objectArchival *river.Job[ObjectArchival]) (err error) {
+	const op = "ObjectArchivalWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectArchival.Kind, objectArchival.ID, objectArchival.Attempt, objectArchival.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	// objects missing from storage stay in the hot tier, the cursor keeps them from being listed again this run
+	afterId := ""
+	for {
+		objects, err := w.queries.ObjectListArchivable(ctx, &database.ObjectListArchivableParams{
+			AfterID: afterId,
+			Limit:   100,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to list archivable objects",
+				zap.Error(err),
+				zapfield.Operation(op),
+			)
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+
+		for _, object := range objects {
+			afterId = object.ID
+
+			objectCopy := &storage.ObjectCopy{
+				SourceBucket:      object.BucketStoragePrefix,
+				SourceName:        object.Name,
+				DestinationBucket: object.BucketStoragePrefix,
+				DestinationName:   object.Name,
+				StorageClass:      w.coldStorageClass,
+			}
+
+			if object.Size > storage.CopyObjectMaxSize {
+				_, err = w.storage.CopyLargeObject(ctx, objectCopy, object.Size)
+			} else {
+				_, err = w.storage.CopyObject(ctx, objectCopy)
+			}
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				w.logger.Warn(
+					"skipping archival of object missing from storage",
+					zapfield.Operation(op),
+					zap.String("object_id", object.ID),
+				)
+				continue
+			}
+			if err != nil {
+				w.logger.Error(
+					"failed to copy object to the cold storage class",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("object_id", object.ID),
+				)
+				return err
+			}
+
+			_, err = w.queries.ObjectUpdateTier(ctx, &database.ObjectUpdateTierParams{
+				ID:       object.ID,
+				FromTier: models.ObjectTierHot,
+				Tier:     models.ObjectTierCold,
+			})
+			if err != nil {
+				w.logger.Error(
+					"failed to update object tier",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("object_id", object.ID),
+				)
+				return err
+			}
+
+			w.logger.Info(
+				"archived object",
+				zapfield.Operation(op),
+				zap.String("object_id", object.ID),
+				zap.String("bucket_id", object.BucketID),
+				zap.String("object_name", object.Name),
+			)
+		}
+	}
+}
+
+func NewObjectArchivalWorker(db *pgxpool.Pool, storage *storage.Storage, config *config.Config, logger *zap.Logger) *ObjectArchivalWorker {
+	return &ObjectArchivalWorker{
+		queries:          database.New(db),
+		storage:          storage,
+		coldStorageClass: config.S3ColdStorageClass,
+		logger:           logger,
+	}
+}
@@ -0,0 +1,411 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/samber/lo"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// objectBatchPageSize is how many pending items of a batch are listed at a time
+const objectBatchPageSize = 100
+
+type ObjectBatch struct {
+	BatchId      string               `json:"batch_id"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectBatch) Kind() string {
+	return "object.batch"
+}
+
+func (ObjectBatch) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectBatch}
+}
+
+// ObjectBatchWorker applies the operation of a batch to its pending items one by one. an item the operation is refused
+// for is recorded as failed and the batch goes on, an item is recorded as succeeded together with its change so a
+// retried job picks up exactly the items that are still pending
+type ObjectBatchWorker struct {
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     *storage.Storage
+	scan        bool
+	logger      *zap.Logger
+	river.WorkerDefaults[ObjectBatch]
+}
+
+func (w *ObjectBatchWorker) Work(ctx context.Context, objectBatch *river.Job[ObjectBatch]) (err error) {
+	const op = "ObjectBatchWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectBatch.Kind, objectBatch.ID, objectBatch.Attempt, objectBatch.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	batch, err := w.queries.ObjectBatchGetById(ctx, objectBatch.Args.BatchId)
+	if err != nil {
+		// the batch went away with its bucket
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object batch",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("batch_id", objectBatch.Args.BatchId),
+		)
+		return err
+	}
+
+	var options models.ObjectBatchOptions
+	if len(batch.Options) > 0 {
+		if err = json.Unmarshal(batch.Options, &options); err != nil {
+			w.logger.Error(
+				"failed to read object batch options",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("batch_id", batch.ID),
+			)
+			return err
+		}
+	}
+
+	var destination *database.StorageBucket
+	if batch.Operation == models.ObjectBatchOperationCopy && options.DestinationBucketId != nil {
+		destination, err = w.queries.BucketGetById(ctx, *options.DestinationBucketId)
+		if err != nil && !database.IsNotFoundError(err) {
+			w.logger.Error(
+				"failed to get destination bucket",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("bucket_id", *options.DestinationBucketId),
+			)
+			return err
+		}
+	}
+
+	cursor := ""
+
+	for {
+		items, err := w.queries.ObjectBatchItemListPendingAfterObjectId(ctx, &database.ObjectBatchItemListPendingAfterObjectIdParams{
+			BatchID:       batch.ID,
+			AfterObjectID: cursor,
+			Limit:         objectBatchPageSize,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to list pending object batch items",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("batch_id", batch.ID),
+			)
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		for _, item := range items {
+			if err = w.workItem(ctx, batch, &options, destination, item, op); err != nil {
+				return err
+			}
+		}
+
+		cursor = items[len(items)-1].ObjectID
+	}
+}
+
+func (w *ObjectBatchWorker) workItem(ctx context.Context, batch *database.StorageObjectBatch, options *models.ObjectBatchOptions, destination *database.StorageBucket, item *database.StorageObjectBatchItem, op string) error {
+	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, item.ObjectID)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return w.failItem(ctx, batch.ID, item.ObjectID, "object not found", op)
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", item.ObjectID),
+		)
+		return err
+	}
+
+	if object.BucketID != batch.BucketID {
+		return w.failItem(ctx, batch.ID, item.ObjectID, "object not found", op)
+	}
+
+	switch batch.Operation {
+	case models.ObjectBatchOperationDelete:
+		return w.deleteObject(ctx, batch.ID, object, options.BypassGovernance, op)
+	case models.ObjectBatchOperationCopy:
+		return w.copyObject(ctx, batch.ID, object, destination, options, op)
+	case models.ObjectBatchOperationTag:
+		return w.tagObject(ctx, batch.ID, object, options.Tags, op)
+	default:
+		return w.failItem(ctx, batch.ID, item.ObjectID, fmt.Sprintf("unknown operation '%s'", batch.Operation), op)
+	}
+}
+
+func (w *ObjectBatchWorker) deleteObject(ctx context.Context, batchId string, object *database.ObjectGetByIdWithBucketNameRow, bypassGovernance bool, op string) error {
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return w.failItem(ctx, batchId, object.ID, "upload of the object has not yet been completed", op)
+	}
+
+	rule, err := w.queries.TagRuleGetDenyDeleteByObjectId(ctx, object.ID)
+	if err == nil {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object is tagged '%s=%s' and tag rule '%s' denies deleting it", rule.TagKey, rule.TagValue, rule.ID), op)
+	}
+	if !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get deny delete tag rule of object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	if models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, bypassGovernance) {
+		return w.failItem(ctx, batchId, object.ID, "object is under a legal hold or retention", op)
+	}
+
+	err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete object from storage",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_name", object.BucketName),
+			zap.String("object_name", object.Name),
+		)
+		return err
+	}
+
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(object.BucketID, object.ID),
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete cached renders",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	if err = enqueueReplicaDeletion(ctx, object.BucketReplica, object.BucketStoragePrefix, []string{object.Name}, w.logger, op); err != nil {
+		return err
+	}
+
+	return w.succeedItem(ctx, batchId, object.ID, op, func(tx pgx.Tx) error {
+		return w.queries.WithTx(tx).ObjectDelete(ctx, object.ID)
+	})
+}
+
+func (w *ObjectBatchWorker) tagObject(ctx context.Context, batchId string, object *database.ObjectGetByIdWithBucketNameRow, tags map[string]string, op string) error {
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return w.failItem(ctx, batchId, object.ID, "upload of the object has not yet been completed", op)
+	}
+
+	return w.succeedItem(ctx, batchId, object.ID, op, func(tx pgx.Tx) error {
+		if err := w.queries.WithTx(tx).ObjectTagDeleteByObjectId(ctx, object.ID); err != nil {
+			return err
+		}
+
+		if len(tags) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, len(tags))
+		values := make([]string, 0, len(tags))
+		for key, value := range tags {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+
+		return w.queries.WithTx(tx).ObjectTagCreateMany(ctx, &database.ObjectTagCreateManyParams{
+			ObjectID: object.ID,
+			Keys:     keys,
+			Values:   values,
+		})
+	})
+}
+
+// copyObject copies an object to the destination bucket under the destination prefix. the copy is refused when the
+// policies of the destination bucket do not allow it or an object of the same name is already there
+func (w *ObjectBatchWorker) copyObject(ctx context.Context, batchId string, object *database.ObjectGetByIdWithBucketNameRow, destination *database.StorageBucket, options *models.ObjectBatchOptions, op string) error {
+	if object.UploadStatus != models.ObjectUploadStatusCompleted {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object is %s, only uploaded objects can be copied", object.UploadStatus), op)
+	}
+
+	if object.Tier != models.ObjectTierHot {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object is %s, only objects in the hot tier can be copied", object.Tier), op)
+	}
+
+	if destination == nil {
+		return w.failItem(ctx, batchId, object.ID, "destination bucket not found", op)
+	}
+
+	if destination.Disabled || destination.Locked {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("destination bucket '%s' is disabled or locked", destination.ID), op)
+	}
+
+	if !lo.Contains(destination.AllowedMimeTypes, models.BucketAllowedMimeTypesWildcard) && !lo.Contains(destination.AllowedMimeTypes, object.MimeType) {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("mime type '%s' is not allowed in the destination bucket", object.MimeType), op)
+	}
+
+	if destination.MaxAllowedObjectSize != nil && object.Size > *destination.MaxAllowedObjectSize {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object is larger than the %d bytes the destination bucket allows", *destination.MaxAllowedObjectSize), op)
+	}
+
+	name := lo.FromPtr(options.DestinationPrefix) + object.Name
+	if !models.IsValidObjectName(name) {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("invalid destination object name '%s'", name), op)
+	}
+
+	_, err := w.queries.ObjectGetByBucketIdAndName(ctx, &database.ObjectGetByBucketIdAndNameParams{
+		BucketID: destination.ID,
+		Name:     name,
+	})
+	if err == nil {
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object with name '%s' already exists in the destination bucket", name), op)
+	}
+	if !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get object by name",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_id", destination.ID),
+		)
+		return err
+	}
+
+	_, err = w.storage.CopyObject(ctx, &storage.ObjectCopy{
+		SourceBucket:      object.BucketStoragePrefix,
+		SourceName:        object.Name,
+		DestinationBucket: destination.StoragePrefix,
+		DestinationName:   name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return w.failItem(ctx, batchId, object.ID, "content of the object not found in storage", op)
+		}
+		w.logger.Error(
+			"failed to copy object in storage",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	err = w.succeedItem(ctx, batchId, object.ID, op, func(tx pgx.Tx) error {
+		id, err := w.queries.WithTx(tx).ObjectCreate(ctx, &database.ObjectCreateParams{
+			BucketID:     destination.ID,
+			Name:         name,
+			ContentType:  &object.MimeType,
+			Size:         object.Size,
+			Metadata:     object.Metadata,
+			UploadStatus: models.ObjectUploadStatusPending,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the copy goes through the scan and processors of the destination bucket like any other upload
+		status, params := CompletedUpload(ctx, id, object.MimeType, destination.Processors, destination.Replica, w.scan)
+
+		err = w.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
+			ID:           id,
+			UploadStatus: status,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(params) == 0 {
+			return nil
+		}
+
+		_, err = river.ClientFromContext[pgx.Tx](ctx).InsertManyTx(ctx, tx, params)
+		return err
+	})
+	if database.IsConflictError(err) {
+		// another upload took the name in the meantime
+		return w.failItem(ctx, batchId, object.ID, fmt.Sprintf("object with name '%s' already exists in the destination bucket", name), op)
+	}
+
+	return err
+}
+
+// succeedItem applies the change of an item and records it as succeeded in the same transaction
+func (w *ObjectBatchWorker) succeedItem(ctx context.Context, batchId string, objectId string, op string, change func(tx pgx.Tx) error) error {
+	err := w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		return w.queries.WithTx(tx).ObjectBatchItemUpdateStatus(ctx, &database.ObjectBatchItemUpdateStatusParams{
+			BatchID:  batchId,
+			ObjectID: objectId,
+			Status:   models.ObjectBatchItemStatusSucceeded,
+		})
+	})
+	if err != nil && !database.IsConflictError(err) {
+		w.logger.Error(
+			"failed to work object batch item",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("batch_id", batchId),
+			zap.String("object_id", objectId),
+		)
+	}
+
+	return err
+}
+
+func (w *ObjectBatchWorker) failItem(ctx context.Context, batchId string, objectId string, reason string, op string) error {
+	err := w.queries.ObjectBatchItemUpdateStatus(ctx, &database.ObjectBatchItemUpdateStatusParams{
+		BatchID:  batchId,
+		ObjectID: objectId,
+		Status:   models.ObjectBatchItemStatusFailed,
+		Error:    &reason,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to record failed object batch item",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("batch_id", batchId),
+			zap.String("object_id", objectId),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func NewObjectBatchWorker(db *pgxpool.Pool, storage *storage.Storage, scan bool, logger *zap.Logger) *ObjectBatchWorker {
+	return &ObjectBatchWorker{
+		queries:     database.New(db),
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		scan:        scan,
+		logger:      logger,
+	}
+}
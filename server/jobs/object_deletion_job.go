@@ -5,19 +5,28 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
 	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
 	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
 )
 
 type ObjectDeletion struct {
 	ObjectId string `json:"object_id"`
+	// BypassGovernance deletes the object under governance retention, an admin asked for it when the job was queued
+	BypassGovernance bool                 `json:"bypass_governance,omitempty"`
+	TraceContext     tracing.TraceContext `json:"trace_context,omitempty"`
 }
 
 func (ObjectDeletion) Kind() string {
 	return "object.deletion"
 }
 
+func (ObjectDeletion) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectDeletion}
+}
+
 type ObjectDeletionWorker struct {
 	queries *database.Queries
 	storage *storage.Storage
@@ -25,9 +34,12 @@ type ObjectDeletionWorker struct {
 	river.WorkerDefaults[ObjectDeletion]
 }
 
-func (w *ObjectDeletionWorker) Work(ctx context.Context, objectDeletion *river.Job[ObjectDeletion]) error {
+func (w *ObjectDeletionWorker) Work(ctx context.Context, objectDeletion *river.Job[ObjectDeletion]) (err error) {
 	const op = "ObjectDeletionWorker.Work"
 
+	ctx, span := tracing.StartJobSpan(ctx, objectDeletion.Kind, objectDeletion.ID, objectDeletion.Attempt, objectDeletion.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, objectDeletion.Args.ObjectId)
 	if err != nil {
 		if database.IsNotFoundError(err) {
@@ -42,8 +54,39 @@ func (w *ObjectDeletionWorker) Work(ctx context.Context, objectDeletion *river.J
 		return err
 	}
 
+	// a tag rule protecting the object may have been added since the deletion was requested
+	rule, err := w.queries.TagRuleGetDenyDeleteByObjectId(ctx, object.ID)
+	if err == nil {
+		w.logger.Warn(
+			"skipping deletion of object protected by a tag rule",
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+			zap.String("tag_rule_id", rule.ID),
+		)
+		return nil
+	}
+	if !database.IsNotFoundError(err) {
+		w.logger.Error(
+			"failed to get deny delete tag rule of object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	// so may a legal hold or retention
+	if models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, objectDeletion.Args.BypassGovernance) {
+		w.logger.Warn(
+			"skipping deletion of locked object",
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return nil
+	}
+
 	err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
-		Bucket: object.BucketName,
+		Bucket: object.BucketStoragePrefix,
 		Name:   object.Name,
 	})
 	if err != nil {
@@ -57,6 +100,24 @@ func (w *ObjectDeletionWorker) Work(ctx context.Context, objectDeletion *river.J
 		return err
 	}
 
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(object.BucketID, object.ID),
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete cached renders",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	if err = enqueueReplicaDeletion(ctx, object.BucketReplica, object.BucketStoragePrefix, []string{object.Name}, w.logger, op); err != nil {
+		return err
+	}
+
 	err = w.queries.ObjectDelete(ctx, objectDeletion.Args.ObjectId)
 	if err != nil {
 		w.logger.Error(
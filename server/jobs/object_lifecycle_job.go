@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// objectLifecycleInterval is how often expire tag rules are applied, expiry is counted in days so objects are at
+// most this late
+const objectLifecycleInterval = time.Hour
+
+type ObjectLifecycle struct {
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectLifecycle) Kind() string {
+	return "object.lifecycle"
+}
+
+func (ObjectLifecycle) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectLifecycle}
+}
+
+// NewPeriodicJobs returns the jobs the elected leader of the workers enqueues on a schedule
+func NewPeriodicJobs() []*river.PeriodicJob {
+	return []*river.PeriodicJob{
+		river.NewPeriodicJob(
+			river.PeriodicInterval(objectLifecycleInterval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return ObjectLifecycle{}, nil
+			},
+			&river.PeriodicJobOpts{RunOnStart: true},
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(bucketLockReconciliationInterval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return BucketLockReconciliation{}, nil
+			},
+			&river.PeriodicJobOpts{RunOnStart: true},
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(storageReconciliationInterval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return StorageReconciliation{}, nil
+			},
+			nil,
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(objectArchivalInterval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return ObjectArchival{}, nil
+			},
+			nil,
+		),
+	}
+}
+
+// ObjectLifecycleWorker deletes the objects past the expire tag rules of their bucket. objects are deleted right
+// here instead of through deletion jobs so a run never enqueues an object whose deletion is still waiting
+type ObjectLifecycleWorker struct {
+	queries *database.Queries
+	storage *storage.Storage
+	logger  *zap.Logger
+	river.WorkerDefaults[ObjectLifecycle]
+}
+
+func (w *ObjectLifecycleWorker) Work(ctx context.Context, objectLifecycle *river.Job[ObjectLifecycle]) (err error) {
+	const op = "ObjectLifecycleWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectLifecycle.Kind, objectLifecycle.ID, objectLifecycle.Attempt, objectLifecycle.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	// deleted objects drop out of the listing so every batch picks up where the previous one stopped
+	for {
+		objects, err := w.queries.ObjectListExpiredByTagRules(ctx, 100)
+		if err != nil {
+			w.logger.Error(
+				"failed to list expired objects",
+				zap.Error(err),
+				zapfield.Operation(op),
+			)
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+
+		for _, object := range objects {
+			err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
+				Bucket: object.BucketStoragePrefix,
+				Name:   object.Name,
+			})
+			if err != nil {
+				w.logger.Error(
+					"failed to delete expired object from storage",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("bucket_name", object.BucketName),
+					zap.String("object_name", object.Name),
+				)
+				return err
+			}
+
+			err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+				Bucket: storage.RenderCacheBucket,
+				Prefix: storage.RenderCachePrefix(object.BucketID, object.ID),
+			})
+			if err != nil {
+				w.logger.Error(
+					"failed to delete cached renders",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("object_id", object.ID),
+				)
+				return err
+			}
+
+			err = enqueueReplicaDeletion(ctx, object.BucketReplica, object.BucketStoragePrefix, []string{object.Name}, w.logger, op)
+			if err != nil {
+				return err
+			}
+
+			err = w.queries.ObjectDelete(ctx, object.ID)
+			if err != nil {
+				w.logger.Error(
+					"failed to delete expired object from database",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("object_id", object.ID),
+				)
+				return err
+			}
+
+			w.logger.Info(
+				"deleted expired object",
+				zapfield.Operation(op),
+				zap.String("object_id", object.ID),
+				zap.String("bucket_name", object.BucketName),
+				zap.String("object_name", object.Name),
+			)
+		}
+	}
+}
+
+func NewObjectLifecycleWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *ObjectLifecycleWorker {
+	return &ObjectLifecycleWorker{
+		queries: database.New(db),
+		storage: storage,
+		logger:  logger,
+	}
+}
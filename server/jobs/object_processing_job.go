@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/processing"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ObjectProcessing struct {
+	ObjectId     string               `json:"object_id"`
+	Processor    string               `json:"processor"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectProcessing) Kind() string {
+	return "object.processing"
+}
+
+func (ObjectProcessing) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectProcessing}
+}
+
+// NewObjectProcessingJobs returns a job for every processor enabled on the bucket that accepts the mime type of the
+// object. each processor runs as its own job so a failing one is retried without running the others again
+func NewObjectProcessingJobs(ctx context.Context, objectId string, mimeType string, processors []string) []river.InsertManyParams {
+	selected := processing.Select(processors, mimeType)
+
+	params := make([]river.InsertManyParams, 0, len(selected))
+	for _, processor := range selected {
+		params = append(params, river.InsertManyParams{
+			Args: ObjectProcessing{
+				ObjectId:     objectId,
+				Processor:    processor.Name(),
+				TraceContext: tracing.NewTraceContext(ctx),
+			},
+		})
+	}
+
+	return params
+}
+
+type ObjectProcessingWorker struct {
+	queries *database.Queries
+	storage *storage.Storage
+	logger  *zap.Logger
+	river.WorkerDefaults[ObjectProcessing]
+}
+
+func (w *ObjectProcessingWorker) Work(ctx context.Context, objectProcessing *river.Job[ObjectProcessing]) (err error) {
+	const op = "ObjectProcessingWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectProcessing.Kind, objectProcessing.ID, objectProcessing.Attempt, objectProcessing.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	processor, ok := processing.Lookup(objectProcessing.Args.Processor)
+	if !ok {
+		// the processor was removed since the job was enqueued, there is nothing left to run
+		w.logger.Warn(
+			"skipping unknown processor",
+			zapfield.Operation(op),
+			zap.String("processor", objectProcessing.Args.Processor),
+			zap.String("object_id", objectProcessing.Args.ObjectId),
+		)
+		return nil
+	}
+
+	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, objectProcessing.Args.ObjectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectProcessing.Args.ObjectId),
+		)
+		return err
+	}
+
+	if object.UploadStatus != models.ObjectUploadStatusCompleted {
+		return nil
+	}
+
+	content, err := w.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to get object content",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_name", object.BucketName),
+			zap.String("object_name", object.Name),
+		)
+		return err
+	}
+	defer content.Body.Close()
+
+	result, err := processor.Process(ctx, &processing.Object{
+		Id:       object.ID,
+		BucketId: object.BucketID,
+		Name:     object.Name,
+		MimeType: object.MimeType,
+		Size:     object.Size,
+	}, content.Body)
+	if err != nil {
+		if errors.Is(err, processing.ErrRejected) {
+			return w.rejectObject(ctx, object, processor.Name(), err)
+		}
+		w.logger.Error(
+			"failed to process object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("processor", processor.Name()),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	if len(result.Metadata) > 0 {
+		metadata, err := json.Marshal(result.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata of processor %q: %w", processor.Name(), err)
+		}
+
+		err = w.queries.ObjectMergeMetadata(ctx, &database.ObjectMergeMetadataParams{
+			ID:       object.ID,
+			Metadata: metadata,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to merge processed metadata",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("processor", processor.Name()),
+				zap.String("object_id", object.ID),
+			)
+			return err
+		}
+	}
+
+	if result.MimeType != nil {
+		err = w.queries.ObjectUpdate(ctx, &database.ObjectUpdateParams{
+			ID:       object.ID,
+			MimeType: result.MimeType,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to update object mime type",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("processor", processor.Name()),
+				zap.String("object_id", object.ID),
+			)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rejectObject removes an object a processor refused, the same way deleting it does. objects under a legal hold or
+// retention are kept
+func (w *ObjectProcessingWorker) rejectObject(ctx context.Context, object *database.ObjectGetByIdWithBucketNameRow, processor string, reason error) error {
+	const op = "ObjectProcessingWorker.rejectObject"
+
+	if models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, false) {
+		w.logger.Warn(
+			"keeping locked object rejected by processor",
+			zap.Error(reason),
+			zapfield.Operation(op),
+			zap.String("processor", processor),
+			zap.String("object_id", object.ID),
+		)
+		return nil
+	}
+
+	w.logger.Warn(
+		"deleting object rejected by processor",
+		zap.Error(reason),
+		zapfield.Operation(op),
+		zap.String("processor", processor),
+		zap.String("object_id", object.ID),
+	)
+
+	err := w.storage.DeleteObject(ctx, &storage.ObjectDelete{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete rejected object from storage",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_name", object.BucketName),
+			zap.String("object_name", object.Name),
+		)
+		return err
+	}
+
+	err = w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(object.BucketID, object.ID),
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete cached renders",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	if err = enqueueReplicaDeletion(ctx, object.BucketReplica, object.BucketStoragePrefix, []string{object.Name}, w.logger, op); err != nil {
+		return err
+	}
+
+	err = w.queries.ObjectDelete(ctx, object.ID)
+	if err != nil {
+		w.logger.Error(
+			"failed to delete rejected object from database",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func NewObjectProcessingWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *ObjectProcessingWorker {
+	return &ObjectProcessingWorker{
+		queries: database.New(db),
+		storage: storage,
+		logger:  logger,
+	}
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river"
+	"github.com/samber/lo"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ObjectReplicaDeletion struct {
+	Replica string `json:"replica"`
+	// Bucket is the prefix the content of the bucket is stored under on the replica as on the primary storage
+	Bucket       string               `json:"bucket"`
+	Names        []string             `json:"names"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectReplicaDeletion) Kind() string {
+	return "object.replica_deletion"
+}
+
+func (ObjectReplicaDeletion) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectReplicaDeletion}
+}
+
+// NewObjectReplicaDeletionJobs returns the jobs deleting objects deleted from the primary storage from the replica of
+// their bucket, one per batch of keys storage deletes at once, or no job when the bucket has no replica
+func NewObjectReplicaDeletionJobs(ctx context.Context, replica *string, bucket string, names ...string) []river.InsertManyParams {
+	if replica == nil {
+		return nil
+	}
+
+	var params []river.InsertManyParams
+	for _, chunk := range lo.Chunk(names, storage.DeleteObjectsMaxCount) {
+		params = append(params, river.InsertManyParams{
+			Args: ObjectReplicaDeletion{
+				Replica:      *replica,
+				Bucket:       bucket,
+				Names:        chunk,
+				TraceContext: tracing.NewTraceContext(ctx),
+			},
+		})
+	}
+
+	return params
+}
+
+// enqueueReplicaDeletion queues the deletion of objects deleted from the primary storage from the replica of their
+// bucket. it is called before the objects are deleted from the catalog, so a retried attempt queues it again instead
+// of leaving their copies on the replica
+func enqueueReplicaDeletion(ctx context.Context, replica *string, bucket string, names []string, logger *zap.Logger, op string) error {
+	params := NewObjectReplicaDeletionJobs(ctx, replica, bucket, names...)
+	if len(params) == 0 {
+		return nil
+	}
+
+	if _, err := river.ClientFromContext[pgx.Tx](ctx).InsertMany(ctx, params); err != nil {
+		logger.Error(
+			"failed to create replica deletion jobs",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("replica", *replica),
+			zap.String("bucket", bucket),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// ObjectReplicaDeletionWorker deletes objects from a replica once they are deleted from the primary storage. keys
+// that are already gone from the replica are not an error, a replication that never happened leaves nothing to delete
+type ObjectReplicaDeletionWorker struct {
+	replicas storage.Replicas
+	logger   *zap.Logger
+	river.WorkerDefaults[ObjectReplicaDeletion]
+}
+
+func (w *ObjectReplicaDeletionWorker) Work(ctx context.Context, objectReplicaDeletion *river.Job[ObjectReplicaDeletion]) (err error) {
+	const op = "ObjectReplicaDeletionWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectReplicaDeletion.Kind, objectReplicaDeletion.ID, objectReplicaDeletion.Attempt, objectReplicaDeletion.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	replica := w.replicas.Get(&objectReplicaDeletion.Args.Replica)
+	if replica == nil {
+		return river.JobCancel(errors.New("replica is not in the config"))
+	}
+
+	err = replica.DeleteObjects(ctx, &storage.ObjectsDelete{
+		Bucket: objectReplicaDeletion.Args.Bucket,
+		Names:  objectReplicaDeletion.Args.Names,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete objects from replica",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("replica", objectReplicaDeletion.Args.Replica),
+			zap.String("bucket", objectReplicaDeletion.Args.Bucket),
+			zap.Int("objects", len(objectReplicaDeletion.Args.Names)),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func NewObjectReplicaDeletionWorker(replicas storage.Replicas, logger *zap.Logger) *ObjectReplicaDeletionWorker {
+	return &ObjectReplicaDeletionWorker{
+		replicas: replicas,
+		logger:   logger,
+	}
+}
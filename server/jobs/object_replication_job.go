@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ObjectReplication struct {
+	ObjectId     string               `json:"object_id"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectReplication) Kind() string {
+	return "object.replication"
+}
+
+func (ObjectReplication) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectReplication}
+}
+
+// NewObjectReplicationJobs returns the job copying the completed content of an object to the replica of its bucket,
+// or no job when the bucket has no replica
+func NewObjectReplicationJobs(ctx context.Context, objectId string, replica *string) []river.InsertManyParams {
+	if replica == nil {
+		return nil
+	}
+
+	return []river.InsertManyParams{{
+		Args: ObjectReplication{
+			ObjectId:     objectId,
+			TraceContext: tracing.NewTraceContext(ctx),
+		},
+	}}
+}
+
+// ObjectReplicationWorker copies the content of an object to the replica of its bucket under the same key. every
+// completion of the content queues its own job, so content replaced while it was copied is copied again by the job
+// of the replacement. an object is marked failed once the last attempt fails and is left to the next completion
+type ObjectReplicationWorker struct {
+	queries  *database.Queries
+	storage  *storage.Storage
+	replicas storage.Replicas
+	metrics  *metrics.Metrics
+	logger   *zap.Logger
+	river.WorkerDefaults[ObjectReplication]
+}
+
+func (w *ObjectReplicationWorker) Work(ctx context.Context, objectReplication *river.Job[ObjectReplication]) (err error) {
+	const op = "ObjectReplicationWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectReplication.Kind, objectReplication.ID, objectReplication.Attempt, objectReplication.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, objectReplication.Args.ObjectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectReplication.Args.ObjectId),
+		)
+		return err
+	}
+
+	// the replica of the bucket may have been removed or the content replaced by a pending upload since
+	if object.BucketReplica == nil || object.ReplicationStatus == nil || object.UploadStatus != models.ObjectUploadStatusCompleted {
+		return nil
+	}
+
+	replica := w.replicas.Get(object.BucketReplica)
+	if replica == nil {
+		return river.JobCancel(errors.New("bucket replica is not in the config"))
+	}
+
+	defer func() {
+		if err != nil && objectReplication.Attempt >= objectReplication.MaxAttempts {
+			w.updateReplicationStatus(ctx, object.ID, models.ObjectReplicationStatusFailed, op)
+		}
+	}()
+
+	content, err := w.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			// deleted since, the deletion removes it from the replica as well
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object from storage",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+	defer content.Body.Close()
+
+	_, err = replica.UploadObject(ctx, &storage.ObjectUpload{
+		Bucket:        object.BucketStoragePrefix,
+		Name:          object.Name,
+		ContentType:   content.ContentType,
+		ContentLength: content.ContentLength,
+		Metadata:      content.Metadata,
+		Content:       content.Body,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to upload object to replica",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+			zap.String("replica", *object.BucketReplica),
+		)
+		return err
+	}
+
+	if err = w.updateReplicationStatus(ctx, object.ID, models.ObjectReplicationStatusReplicated, op); err != nil {
+		return err
+	}
+
+	w.metrics.ObserveReplicationLag(*object.BucketReplica, time.Since(objectReplication.CreatedAt))
+
+	return nil
+}
+
+func (w *ObjectReplicationWorker) updateReplicationStatus(ctx context.Context, objectId string, status string, op string) error {
+	err := w.queries.ObjectUpdateReplicationStatus(ctx, &database.ObjectUpdateReplicationStatusParams{
+		ID:                objectId,
+		ReplicationStatus: status,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to update object replication status",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectId),
+			zap.String("replication_status", status),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func NewObjectReplicationWorker(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, metrics *metrics.Metrics, logger *zap.Logger) *ObjectReplicationWorker {
+	return &ObjectReplicationWorker{
+		queries:  database.New(db),
+		storage:  storage,
+		replicas: replicas,
+		metrics:  metrics,
+		logger:   logger,
+	}
+}
@@ -0,0 +1,296 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/scanning"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ObjectScan struct {
+	ObjectId     string               `json:"object_id"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectScan) Kind() string {
+	return "object.scan"
+}
+
+func (ObjectScan) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectScan}
+}
+
+// CompletedUpload returns the upload status an object takes once its content is in storage and the jobs that follow.
+// with malware scanning the object is held in scanning and the processors and replication only run once the scan finds
+// it clean
+func CompletedUpload(ctx context.Context, objectId string, mimeType string, processors []string, replica *string, scan bool) (string, []river.InsertManyParams) {
+	if scan {
+		return models.ObjectUploadStatusScanning, []river.InsertManyParams{{
+			Args: ObjectScan{
+				ObjectId:     objectId,
+				TraceContext: tracing.NewTraceContext(ctx),
+			},
+		}}
+	}
+
+	params := NewObjectProcessingJobs(ctx, objectId, mimeType, processors)
+
+	return models.ObjectUploadStatusCompleted, append(params, NewObjectReplicationJobs(ctx, objectId, replica)...)
+}
+
+// errScannedObjectChanged is returned when the object changed between being read and the verdict being recorded
+var errScannedObjectChanged = errors.New("object changed while it was scanned")
+
+type ObjectScanWorker struct {
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     *storage.Storage
+	scanner     scanning.Scanner
+	logger      *zap.Logger
+	river.WorkerDefaults[ObjectScan]
+}
+
+func (w *ObjectScanWorker) Work(ctx context.Context, objectScan *river.Job[ObjectScan]) (err error) {
+	const op = "ObjectScanWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectScan.Kind, objectScan.ID, objectScan.Attempt, objectScan.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, objectScan.Args.ObjectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectScan.Args.ObjectId),
+		)
+		return err
+	}
+
+	// content overwritten while this scan waited is scanned by the job enqueued for it, so only objects still waiting
+	// for a scan are scanned
+	if object.UploadStatus != models.ObjectUploadStatusScanning {
+		return nil
+	}
+
+	// scanning was turned off since the job was enqueued, the object is released the way it would be without it
+	if w.scanner == nil {
+		if err = w.release(ctx, object, op); errors.Is(err, errScannedObjectChanged) {
+			return w.retryChanged(ctx, object.ID, op)
+		}
+		return err
+	}
+
+	content, err := w.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to get object content",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_name", object.BucketName),
+			zap.String("object_name", object.Name),
+		)
+		return err
+	}
+	defer content.Body.Close()
+
+	result, err := w.scanner.Scan(ctx, content.Body)
+	if err != nil {
+		w.logger.Error(
+			"failed to scan object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	// the verdict only holds for the content that was scanned, content replaced during the scan has its own job
+	current, err := w.storage.HeadObject(ctx, &storage.ObjectHead{
+		Bucket: object.BucketStoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to head object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_name", object.BucketName),
+			zap.String("object_name", object.Name),
+		)
+		return err
+	}
+
+	if current.ETag != content.ETag {
+		return nil
+	}
+
+	if result.Infected {
+		err = w.quarantine(ctx, object, result.Signature, op)
+	} else {
+		err = w.release(ctx, object, op)
+	}
+	if errors.Is(err, errScannedObjectChanged) {
+		return w.retryChanged(ctx, object.ID, op)
+	}
+
+	return err
+}
+
+// retryChanged decides what happens to a verdict that could not be recorded because the object changed. new content
+// leaves the object scanning and is scanned again, an object that left scanning has nothing left to scan
+func (w *ObjectScanWorker) retryChanged(ctx context.Context, objectId string, op string) error {
+	object, err := w.queries.ObjectGetById(ctx, objectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectId),
+		)
+		return err
+	}
+
+	if object.UploadStatus != models.ObjectUploadStatusScanning {
+		return nil
+	}
+
+	return errScannedObjectChanged
+}
+
+// release completes a clean object and enqueues the processors and replication of its bucket, unless the object
+// changed since it was read
+func (w *ObjectScanWorker) release(ctx context.Context, object *database.ObjectGetByIdWithBucketNameRow, op string) error {
+	bucket, err := w.queries.BucketGetById(ctx, object.BucketID)
+	if err != nil {
+		w.logger.Error(
+			"failed to get bucket",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("bucket_id", object.BucketID),
+		)
+		return err
+	}
+
+	err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		rows, err := w.queries.WithTx(tx).ObjectUpdateScannedUploadStatus(ctx, &database.ObjectUpdateScannedUploadStatusParams{
+			ID:           object.ID,
+			UploadStatus: models.ObjectUploadStatusCompleted,
+			Version:      object.Version,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errScannedObjectChanged
+		}
+
+		params := NewObjectProcessingJobs(ctx, object.ID, object.MimeType, bucket.Processors)
+		params = append(params, NewObjectReplicationJobs(ctx, object.ID, bucket.Replica)...)
+		if len(params) == 0 {
+			return nil
+		}
+
+		_, err = river.ClientFromContext[pgx.Tx](ctx).InsertManyTx(ctx, tx, params)
+		return err
+	})
+	if errors.Is(err, errScannedObjectChanged) {
+		return err
+	}
+	if err != nil {
+		w.logger.Error(
+			"failed to update object upload status to completed",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// quarantine keeps infected content in storage so it can be looked into but refuses to serve it, the signature is
+// recorded in the metadata of the object. like release it leaves an object that changed since it was read alone
+func (w *ObjectScanWorker) quarantine(ctx context.Context, object *database.ObjectGetByIdWithBucketNameRow, signature string, op string) error {
+	w.logger.Warn(
+		"quarantining infected object",
+		zapfield.Operation(op),
+		zap.String("object_id", object.ID),
+		zap.String("bucket_name", object.BucketName),
+		zap.String("object_name", object.Name),
+		zap.String("signature", signature),
+	)
+
+	metadata, err := json.Marshal(map[string]any{
+		"malware_scan": map[string]any{
+			"signature": signature,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		rows, err := w.queries.WithTx(tx).ObjectUpdateScannedUploadStatus(ctx, &database.ObjectUpdateScannedUploadStatusParams{
+			ID:           object.ID,
+			UploadStatus: models.ObjectUploadStatusQuarantined,
+			Version:      object.Version,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errScannedObjectChanged
+		}
+
+		return w.queries.WithTx(tx).ObjectMergeMetadata(ctx, &database.ObjectMergeMetadataParams{
+			ID:       object.ID,
+			Metadata: metadata,
+		})
+	})
+	if errors.Is(err, errScannedObjectChanged) {
+		return err
+	}
+	if err != nil {
+		w.logger.Error(
+			"failed to quarantine object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// NewObjectScanWorker creates the scan worker, scanner is nil when malware scanning is turned off
+func NewObjectScanWorker(db *pgxpool.Pool, storage *storage.Storage, scanner scanning.Scanner, logger *zap.Logger) *ObjectScanWorker {
+	return &ObjectScanWorker{
+		queries:     database.New(db),
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		scanner:     scanner,
+		logger:      logger,
+	}
+}
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+const (
+	// objectTierRestoreDays is how long storage keeps the temporary copy of restored content, it only has to outlive
+	// the copy back to the hot storage class
+	objectTierRestoreDays = 1
+	// objectTierRestorePollInterval is how often a restore still in progress in storage is checked, cold storage
+	// classes take minutes to hours to restore content
+	objectTierRestorePollInterval = 15 * time.Minute
+)
+
+type ObjectTierRestore struct {
+	ObjectId     string               `json:"object_id"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (ObjectTierRestore) Kind() string {
+	return "object.tier_restore"
+}
+
+func (ObjectTierRestore) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueObjectTierRestore}
+}
+
+// ObjectTierRestoreWorker brings a restoring object back to the hot tier. storage is asked for a temporary copy of
+// the archived content and the job snoozes until it is readable, then the content is copied onto itself in the
+// standard storage class and the object is marked hot
+type ObjectTierRestoreWorker struct {
+	queries *database.Queries
+	storage *storage.Storage
+	logger  *zap.Logger
+	river.WorkerDefaults[ObjectTierRestore]
+}
+
+func (w *ObjectTierRestoreWorker) Work(ctx context.Context, objectTierRestore *river.Job[ObjectTierRestore]) (err error) {
+	const op = "ObjectTierRestoreWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, objectTierRestore.Kind, objectTierRestore.ID, objectTierRestore.Attempt, objectTierRestore.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, objectTierRestore.Args.ObjectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		w.logger.Error(
+			"failed to get object",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", objectTierRestore.Args.ObjectId),
+		)
+		return err
+	}
+
+	// new content uploaded since is already in the hot tier
+	if object.Tier != models.ObjectTierRestoring {
+		return nil
+	}
+
+	objectCopy := &storage.ObjectCopy{
+		SourceBucket:      object.BucketStoragePrefix,
+		SourceName:        object.Name,
+		DestinationBucket: object.BucketStoragePrefix,
+		DestinationName:   object.Name,
+		StorageClass:      storage.StorageClassStandard,
+	}
+
+	if object.Size > storage.CopyObjectMaxSize {
+		_, err = w.storage.CopyLargeObject(ctx, objectCopy, object.Size)
+	} else {
+		_, err = w.storage.CopyObject(ctx, objectCopy)
+	}
+	if errors.Is(err, storage.ErrObjectArchived) {
+		err = w.storage.RestoreObject(ctx, &storage.ObjectRestore{
+			Bucket: object.BucketStoragePrefix,
+			Name:   object.Name,
+			Days:   objectTierRestoreDays,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to restore archived object",
+				zap.Error(err),
+				zapfield.Operation(op),
+				zap.String("object_id", object.ID),
+			)
+			return err
+		}
+		return river.JobSnooze(objectTierRestorePollInterval)
+	}
+	if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+		w.logger.Error(
+			"failed to copy object to the standard storage class",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	// content missing from storage has nothing left to restore, the object is not kept restoring forever
+	_, err = w.queries.ObjectUpdateTier(ctx, &database.ObjectUpdateTierParams{
+		ID:       object.ID,
+		FromTier: models.ObjectTierRestoring,
+		Tier:     models.ObjectTierHot,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to update object tier",
+			zap.Error(err),
+			zapfield.Operation(op),
+			zap.String("object_id", object.ID),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func NewObjectTierRestoreWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *ObjectTierRestoreWorker {
+	return &ObjectTierRestoreWorker{
+		queries: database.New(db),
+		storage: storage,
+		logger:  logger,
+	}
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// bucketOperationRecorder records the outcome of the bucket operation a job works once the job stops. jobs queued
+// before operations were tracked have none and are left alone by it. unlocksBucket is set for jobs that hold the lock
+// of their bucket
+type bucketOperationRecorder struct {
+	queries       *database.Queries
+	unlocksBucket bool
+	logger        *zap.Logger
+}
+
+// finish records how the work of a job ended and returns the error the job ends with. a job cancelled while running
+// unlocks its bucket since it stopped deleting, a job that ran out of attempts keeps the bucket locked so the half
+// emptied bucket is looked into before it is used again, or until the lock reconciler releases it
+func (r *bucketOperationRecorder) finish(ctx context.Context, job *rivertype.JobRow, bucketId string, err error, op string) error {
+	if err == nil {
+		return r.record(ctx, job.ID, models.OperationStateSucceeded, nil, op)
+	}
+
+	if errors.Is(context.Cause(ctx), river.ErrJobCancelledRemotely) {
+		// the context of the job is done, the cleanup still has to reach the database
+		ctx = context.WithoutCancel(ctx)
+
+		r.logger.Info(
+			"bucket operation cancelled",
+			zap.Int64("job_id", job.ID),
+			zap.String("bucket_id", bucketId),
+			zapfield.Operation(op),
+		)
+
+		if r.unlocksBucket {
+			_, unlockErr := r.queries.BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+				ID:        bucketId,
+				LockJobID: job.ID,
+			})
+			if unlockErr != nil {
+				r.logger.Error(
+					"failed to unlock bucket of cancelled operation",
+					zap.String("bucket_id", bucketId),
+					zapfield.Operation(op),
+					zap.Error(unlockErr),
+				)
+				return err
+			}
+		}
+
+		_ = r.record(ctx, job.ID, models.OperationStateCancelled, nil, op)
+		return err
+	}
+
+	// a snoozed job runs again, it is not done with the operation
+	if errors.Is(err, river.JobSnooze(0)) {
+		return err
+	}
+
+	// whoever took the lock away from the job already unlocked the bucket
+	if errors.Is(err, errBucketLockLost) {
+		_ = r.record(ctx, job.ID, models.OperationStateCancelled, nil, op)
+		return err
+	}
+
+	if job.Attempt >= job.MaxAttempts {
+		message := err.Error()
+		_ = r.record(ctx, job.ID, models.OperationStateFailed, &message, op)
+	}
+
+	return err
+}
+
+func (r *bucketOperationRecorder) record(ctx context.Context, jobId int64, state string, message *string, op string) error {
+	err := r.queries.OperationFinishByJobId(ctx, &database.OperationFinishByJobIdParams{
+		JobID: jobId,
+		State: &state,
+		Error: message,
+	})
+	if err != nil {
+		r.logger.Error(
+			"failed to record outcome of bucket operation",
+			zap.Int64("job_id", jobId),
+			zap.String("state", state),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
@@ -2,33 +2,45 @@ package jobs
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
 	"github.com/teapartydev/storage/server/database"
 	"github.com/teapartydev/storage/server/models"
 	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
 )
 
 type PreSignedUploadSessionCompletion struct {
-	ObjectId string `json:"object_id"`
+	ObjectId     string               `json:"object_id"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
 }
 
 func (PreSignedUploadSessionCompletion) Kind() string {
 	return "pre.signed.upload.session.completion"
 }
 
+func (PreSignedUploadSessionCompletion) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueuePreSignedUploadSessionCompletion}
+}
+
 type PreSignedUploadSessionCompletionWorker struct {
-	queries *database.Queries
-	storage *storage.Storage
-	logger  *zap.Logger
+	queries     *database.Queries
+	transaction *database.Transaction
+	storage     *storage.Storage
+	scan        bool
+	logger      *zap.Logger
 	river.WorkerDefaults[PreSignedUploadSessionCompletion]
 }
 
-func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSignedUploadSessionCompletion *river.Job[PreSignedUploadSessionCompletion]) error {
+func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSignedUploadSessionCompletion *river.Job[PreSignedUploadSessionCompletion]) (err error) {
 	const op = "PreSignedUploadSessionCompletionWorker.Work"
 
+	ctx, span := tracing.StartJobSpan(ctx, preSignedUploadSessionCompletion.Kind, preSignedUploadSessionCompletion.ID, preSignedUploadSessionCompletion.Attempt, preSignedUploadSessionCompletion.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	object, err := w.queries.ObjectGetByIdWithBucketName(ctx, preSignedUploadSessionCompletion.Args.ObjectId)
 	if err != nil {
 		if database.IsNotFoundError(err) {
@@ -43,7 +55,7 @@ func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSi
 		return err
 	}
 	objectExists, err := w.storage.CheckIfObjectExists(ctx, &storage.ObjectExistsCheck{
-		Bucket: object.BucketName,
+		Bucket: object.BucketStoragePrefix,
 		Name:   object.Name,
 	})
 	if err != nil {
@@ -58,10 +70,37 @@ func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSi
 	}
 
 	if objectExists {
-		if object.UploadStatus != models.ObjectUploadStatusCompleted {
-			err = w.queries.ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
-				ID:           object.ID,
-				UploadStatus: models.ObjectUploadStatusCompleted,
+		if object.UploadStatus == models.ObjectUploadStatusPending {
+			bucket, err := w.queries.BucketGetById(ctx, object.BucketID)
+			if err != nil {
+				w.logger.Error(
+					"failed to get bucket",
+					zap.Error(err),
+					zapfield.Operation(op),
+					zap.String("bucket_id", object.BucketID),
+				)
+				return err
+			}
+
+			// the status flips together with the follow up jobs being enqueued so a retry never completes an object
+			// without scanning or processing it
+			status, params := CompletedUpload(ctx, object.ID, object.MimeType, bucket.Processors, bucket.Replica, w.scan)
+
+			err = w.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+				err := w.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
+					ID:           object.ID,
+					UploadStatus: status,
+				})
+				if err != nil {
+					return err
+				}
+
+				if len(params) == 0 {
+					return nil
+				}
+
+				_, err = river.ClientFromContext[pgx.Tx](ctx).InsertManyTx(ctx, tx, params)
+				return err
 			})
 			if err != nil {
 				w.logger.Error(
@@ -75,7 +114,7 @@ func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSi
 		}
 	} else {
 		err = w.storage.DeleteObject(ctx, &storage.ObjectDelete{
-			Bucket: object.BucketName,
+			Bucket: object.BucketStoragePrefix,
 			Name:   object.Name,
 		})
 		if err != nil {
@@ -103,10 +142,14 @@ func (w *PreSignedUploadSessionCompletionWorker) Work(ctx context.Context, preSi
 	return nil
 }
 
-func NewPreSignedUploadSessionCompletionWorker(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *PreSignedUploadSessionCompletionWorker {
+// NewPreSignedUploadSessionCompletionWorker creates the completion worker, uploads are held for a malware scan when
+// scan is set
+func NewPreSignedUploadSessionCompletionWorker(db *pgxpool.Pool, storage *storage.Storage, scan bool, logger *zap.Logger) *PreSignedUploadSessionCompletionWorker {
 	return &PreSignedUploadSessionCompletionWorker{
-		queries: database.New(db),
-		storage: storage,
-		logger:  logger,
+		queries:     database.New(db),
+		transaction: database.NewTransaction(db),
+		storage:     storage,
+		scan:        scan,
+		logger:      logger,
 	}
 }
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/config"
+)
+
+// Every job kind runs on its own queue so a burst of slow bucket deletions cannot starve upload completion checks
+const (
+	QueueBucketDeletion                   = "bucket_deletion"
+	QueueBucketEmptying                   = "bucket_emptying"
+	QueueBucketExport                     = "bucket_export"
+	QueueBucketImport                     = "bucket_import"
+	QueueBucketLockReconciliation         = "bucket_lock_reconciliation"
+	QueueBucketRelocation                 = "bucket_relocation"
+	QueueBucketRestore                    = "bucket_restore"
+	QueueObjectArchival                   = "object_archival"
+	QueueObjectBatch                      = "object_batch"
+	QueueObjectDeletion                   = "object_deletion"
+	QueueObjectLifecycle                  = "object_lifecycle"
+	QueueObjectProcessing                 = "object_processing"
+	QueueObjectReplicaDeletion            = "object_replica_deletion"
+	QueueObjectReplication                = "object_replication"
+	QueueObjectScan                       = "object_scan"
+	QueueObjectTierRestore                = "object_tier_restore"
+	QueuePreSignedUploadSessionCompletion = "pre_signed_upload_session_completion"
+	QueueStorageReconciliation            = "storage_reconciliation"
+)
+
+// defaultQueueConcurrency holds the number of workers per queue unless overridden by job_queue_concurrency.
+// The default queue is kept so jobs enqueued before queues were split are still worked
+var defaultQueueConcurrency = map[string]int{
+	river.QueueDefault:                    10,
+	QueueBucketDeletion:                   5,
+	QueueBucketEmptying:                   5,
+	QueueBucketExport:                     1,
+	QueueBucketImport:                     2,
+	QueueBucketLockReconciliation:         1,
+	QueueBucketRelocation:                 2,
+	QueueBucketRestore:                    1,
+	QueueObjectArchival:                   1,
+	QueueObjectBatch:                      5,
+	QueueObjectDeletion:                   25,
+	QueueObjectLifecycle:                  1,
+	QueueObjectProcessing:                 10,
+	QueueObjectReplicaDeletion:            10,
+	QueueObjectReplication:                10,
+	QueueObjectScan:                       10,
+	QueueObjectTierRestore:                10,
+	QueuePreSignedUploadSessionCompletion: 50,
+	QueueStorageReconciliation:            1,
+}
+
+func NewQueues(config *config.Config) (map[string]river.QueueConfig, error) {
+	queues := make(map[string]river.QueueConfig, len(defaultQueueConcurrency))
+
+	for queue, maxWorkers := range defaultQueueConcurrency {
+		queues[queue] = river.QueueConfig{MaxWorkers: maxWorkers}
+	}
+
+	for queue, maxWorkers := range config.JobQueueConcurrency {
+		if _, ok := defaultQueueConcurrency[queue]; !ok {
+			return nil, fmt.Errorf("unknown job queue %q in job_queue_concurrency", queue)
+		}
+
+		queues[queue] = river.QueueConfig{MaxWorkers: maxWorkers}
+	}
+
+	return queues, nil
+}
@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/reconciliation"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// storageReconciliationInterval is how often storage is compared with the catalog, every run lists all content
+// so it is not run on start
+const storageReconciliationInterval = 24 * time.Hour
+
+type StorageReconciliation struct {
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+func (StorageReconciliation) Kind() string {
+	return "storage.reconciliation"
+}
+
+func (StorageReconciliation) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{Queue: QueueStorageReconciliation}
+}
+
+// StorageReconciliationWorker compares every bucket in storage with the catalog and fixes what the configured
+// policies say, and deletes the content relocations left behind. locked buckets are skipped, their objects are being
+// deleted or moved
+type StorageReconciliationWorker struct {
+	queries    *database.Queries
+	storage    *storage.Storage
+	replicas   storage.Replicas
+	reconciler *reconciliation.Reconciler
+	options    *reconciliation.Options
+	logger     *zap.Logger
+	river.WorkerDefaults[StorageReconciliation]
+}
+
+func (w *StorageReconciliationWorker) Work(ctx context.Context, storageReconciliation *river.Job[StorageReconciliation]) (err error) {
+	const op = "StorageReconciliationWorker.Work"
+
+	ctx, span := tracing.StartJobSpan(ctx, storageReconciliation.Kind, storageReconciliation.ID, storageReconciliation.Attempt, storageReconciliation.Args.TraceContext)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	buckets, err := w.queries.BucketListAll(ctx)
+	if err != nil {
+		w.logger.Error(
+			"failed to list buckets",
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	for _, bucket := range buckets {
+		if bucket.Locked {
+			continue
+		}
+
+		if err = w.deleteStaleContent(ctx, bucket, op); err != nil {
+			return err
+		}
+
+		report, err := w.reconciler.ReconcileBucket(ctx, bucket, w.options)
+		if err != nil {
+			w.logger.Error(
+				"failed to reconcile bucket",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		if report.OrphanCount == 0 && report.MissingCount == 0 {
+			continue
+		}
+
+		w.logger.Warn(
+			"bucket storage and catalog differ",
+			zap.String("bucket_id", bucket.ID),
+			zap.Int64("orphans", report.OrphanCount),
+			zap.Int64("missing", report.MissingCount),
+			zap.Int64("orphans_deleted", report.OrphansDeleted),
+			zap.Int64("missing_deleted", report.MissingDeleted),
+			zapfield.Operation(op),
+		)
+	}
+
+	return nil
+}
+
+// deleteStaleContent deletes the content a cancelled relocation left under the stale prefix of a bucket, in storage
+// and on its replica. the bucket is never relocated again before the stale prefix is cleared, nothing else writes
+// under it
+func (w *StorageReconciliationWorker) deleteStaleContent(ctx context.Context, bucket *database.StorageBucket, op string) error {
+	if bucket.StaleStoragePrefix == nil || *bucket.StaleStoragePrefix == bucket.StoragePrefix {
+		return nil
+	}
+
+	err := w.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: *bucket.StaleStoragePrefix,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to delete stale content of bucket",
+			zap.String("bucket_id", bucket.ID),
+			zap.String("prefix", *bucket.StaleStoragePrefix),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if replica := w.replicas.Get(bucket.Replica); replica != nil {
+		err = replica.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+			Bucket: *bucket.StaleStoragePrefix,
+		})
+		if err != nil {
+			w.logger.Error(
+				"failed to delete stale replica content of bucket",
+				zap.String("bucket_id", bucket.ID),
+				zap.String("prefix", *bucket.StaleStoragePrefix),
+				zap.String("replica", *bucket.Replica),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	err = w.queries.BucketClearStaleStoragePrefix(ctx, &database.BucketClearStaleStoragePrefixParams{
+		ID:                 bucket.ID,
+		StaleStoragePrefix: bucket.StaleStoragePrefix,
+	})
+	if err != nil {
+		w.logger.Error(
+			"failed to clear stale prefix of bucket",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	w.logger.Info(
+		"deleted stale content of bucket",
+		zap.String("bucket_id", bucket.ID),
+		zap.String("prefix", *bucket.StaleStoragePrefix),
+		zapfield.Operation(op),
+	)
+
+	return nil
+}
+
+func NewStorageReconciliationWorker(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, config *config.Config, logger *zap.Logger) *StorageReconciliationWorker {
+	return &StorageReconciliationWorker{
+		queries:    database.New(db),
+		storage:    storage,
+		replicas:   replicas,
+		reconciler: reconciliation.NewReconciler(db, storage, logger),
+		options: &reconciliation.Options{
+			OrphanPolicy:  config.StorageReconciliationOrphans,
+			MissingPolicy: config.StorageReconciliationMissing,
+			GracePeriod:   time.Duration(config.StorageReconciliationGracePeriod) * time.Second,
+		},
+		logger: logger,
+	}
+}
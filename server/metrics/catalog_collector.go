@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// catalogScrapeTimeout bounds the catalog queries run on every scrape so a slow database does not stall prometheus
+const catalogScrapeTimeout = 5 * time.Second
+
+type CatalogCollector struct {
+	queries *database.Queries
+	logger  *zap.Logger
+
+	pendingUploads     *prometheus.Desc
+	pendingReplication *prometheus.Desc
+	bucketSize         *prometheus.Desc
+	bucketObjects      *prometheus.Desc
+}
+
+func NewCatalogCollector(db *pgxpool.Pool, logger *zap.Logger) *CatalogCollector {
+	return &CatalogCollector{
+		queries: database.New(db),
+		logger:  logger,
+		pendingUploads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "objects", "pending_uploads"),
+			"Number of objects with an upload that has not been completed yet.",
+			nil, nil,
+		),
+		pendingReplication: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "replication", "pending_objects"),
+			"Number of completed objects not copied to their replica yet by replica.",
+			[]string{"replica"}, nil,
+		),
+		bucketSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bucket", "size_bytes"),
+			"Total size of all objects in a bucket in bytes.",
+			[]string{"bucket_id", "bucket_name"}, nil,
+		),
+		bucketObjects: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bucket", "objects"),
+			"Number of objects in a bucket.",
+			[]string{"bucket_id", "bucket_name"}, nil,
+		),
+	}
+}
+
+func (c *CatalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pendingUploads
+	ch <- c.pendingReplication
+	ch <- c.bucketSize
+	ch <- c.bucketObjects
+}
+
+func (c *CatalogCollector) Collect(ch chan<- prometheus.Metric) {
+	const op = "CatalogCollector.Collect"
+
+	ctx, cancel := context.WithTimeout(context.Background(), catalogScrapeTimeout)
+	defer cancel()
+
+	pendingUploads, err := c.queries.ObjectCountByUploadStatus(ctx, models.ObjectUploadStatusPending)
+	if err != nil {
+		c.logger.Error("failed to count pending uploads", zap.Error(err), zapfield.Operation(op))
+		ch <- prometheus.NewInvalidMetric(c.pendingUploads, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.pendingUploads, prometheus.GaugeValue, float64(pendingUploads))
+	}
+
+	pendingReplication, err := c.queries.ObjectCountPendingReplicationByReplica(ctx)
+	if err != nil {
+		c.logger.Error("failed to count objects pending replication", zap.Error(err), zapfield.Operation(op))
+		ch <- prometheus.NewInvalidMetric(c.pendingReplication, err)
+	} else {
+		for _, pending := range pendingReplication {
+			ch <- prometheus.MustNewConstMetric(c.pendingReplication, prometheus.GaugeValue, float64(pending.Count), pending.Replica)
+		}
+	}
+
+	bucketSizes, err := c.queries.BucketListSizes(ctx)
+	if err != nil {
+		c.logger.Error("failed to list bucket sizes", zap.Error(err), zapfield.Operation(op))
+		ch <- prometheus.NewInvalidMetric(c.bucketSize, err)
+		return
+	}
+
+	for _, bucketSize := range bucketSizes {
+		ch <- prometheus.MustNewConstMetric(c.bucketSize, prometheus.GaugeValue, float64(bucketSize.Size), bucketSize.ID, bucketSize.Name)
+		ch <- prometheus.MustNewConstMetric(c.bucketObjects, prometheus.GaugeValue, float64(bucketSize.Count), bucketSize.ID, bucketSize.Name)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type DatabaseCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount            *prometheus.Desc
+	acquireDuration         *prometheus.Desc
+	acquiredConns           *prometheus.Desc
+	canceledAcquireCount    *prometheus.Desc
+	constructingConns       *prometheus.Desc
+	emptyAcquireCount       *prometheus.Desc
+	idleConns               *prometheus.Desc
+	maxConns                *prometheus.Desc
+	totalConns              *prometheus.Desc
+	newConnsCount           *prometheus.Desc
+	maxLifetimeDestroyCount *prometheus.Desc
+	maxIdleDestroyCount     *prometheus.Desc
+}
+
+func NewDatabaseCollector(pool *pgxpool.Pool) *DatabaseCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+
+	return &DatabaseCollector{
+		pool:                    pool,
+		acquireCount:            desc("acquire_count_total", "Cumulative count of successful connection acquires from the pool."),
+		acquireDuration:         desc("acquire_duration_seconds_total", "Total time spent acquiring connections from the pool."),
+		acquiredConns:           desc("acquired_conns", "Number of currently acquired connections in the pool."),
+		canceledAcquireCount:    desc("canceled_acquire_count_total", "Cumulative count of acquires from the pool that were canceled by a context."),
+		constructingConns:       desc("constructing_conns", "Number of connections with construction in progress in the pool."),
+		emptyAcquireCount:       desc("empty_acquire_count_total", "Cumulative count of successful acquires that waited for a connection because the pool was empty."),
+		idleConns:               desc("idle_conns", "Number of currently idle connections in the pool."),
+		maxConns:                desc("max_conns", "Maximum size of the pool."),
+		totalConns:              desc("total_conns", "Total number of connections currently in the pool."),
+		newConnsCount:           desc("new_conns_count_total", "Cumulative count of new connections opened."),
+		maxLifetimeDestroyCount: desc("max_lifetime_destroy_count_total", "Cumulative count of connections destroyed because they exceeded max connection lifetime."),
+		maxIdleDestroyCount:     desc("max_idle_destroy_count_total", "Cumulative count of connections destroyed because they exceeded max connection idle time."),
+	}
+}
+
+func (c *DatabaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.acquiredConns
+	ch <- c.canceledAcquireCount
+	ch <- c.constructingConns
+	ch <- c.emptyAcquireCount
+	ch <- c.idleConns
+	ch <- c.maxConns
+	ch <- c.totalConns
+	ch <- c.newConnsCount
+	ch <- c.maxLifetimeDestroyCount
+	ch <- c.maxIdleDestroyCount
+}
+
+func (c *DatabaseCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.newConnsCount, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeDestroyCount, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleDestroyCount, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
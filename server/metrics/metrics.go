@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/riverqueue/river"
+	"go.uber.org/zap"
+)
+
+const namespace = "hyperdrift"
+
+type Metrics struct {
+	registry *prometheus.Registry
+	logger   *zap.Logger
+
+	httpRequestsTotal   *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+
+	jobsTotal        *prometheus.CounterVec
+	jobFailuresTotal *prometheus.CounterVec
+	jobRunDuration   *prometheus.HistogramVec
+	jobQueueWaitTime *prometheus.HistogramVec
+
+	storageOperationDuration    *prometheus.HistogramVec
+	storageOperationErrorsTotal *prometheus.CounterVec
+
+	replicationLag *prometheus.HistogramVec
+}
+
+func NewMetrics(logger *zap.Logger) *Metrics {
+	registry := prometheus.NewRegistry()
+
+	m := &Metrics{
+		registry: registry,
+		logger:   logger,
+		httpRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Total number of http requests handled by route, method and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		jobsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "total",
+			Help:      "Total number of jobs worked by kind and outcome.",
+		}, []string{"kind", "outcome"}),
+		jobFailuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "failures_total",
+			Help:      "Total number of failed job attempts by kind and resulting job state.",
+		}, []string{"kind", "state"}),
+		jobRunDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "run_duration_seconds",
+			Help:      "Time spent running jobs by kind.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+		}, []string{"kind"}),
+		jobQueueWaitTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "queue_wait_duration_seconds",
+			Help:      "Time jobs spent available in the queue before being worked by kind.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+		}, []string{"kind"}),
+		storageOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of s3 storage operations by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		storageOperationErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_errors_total",
+			Help:      "Total number of failed s3 storage operations by operation.",
+		}, []string{"operation"}),
+		replicationLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "replication",
+			Name:      "lag_seconds",
+			Help:      "Time from the content of an object being completed to it being copied to its replica by replica.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 300, 900, 1800, 3600, 21600, 86400},
+		}, []string{"replica"}),
+	}
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestsTotal,
+		m.httpRequestDuration,
+		m.jobsTotal,
+		m.jobFailuresTotal,
+		m.jobRunDuration,
+		m.jobQueueWaitTime,
+		m.storageOperationDuration,
+		m.storageOperationErrorsTotal,
+		m.replicationLag,
+	)
+
+	return m
+}
+
+// Register adds extra collectors such as the database pool and catalog collectors to the registry
+func (m *Metrics) Register(collectors ...prometheus.Collector) error {
+	for _, collector := range collectors {
+		if err := m.registry.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler returns the http handler serving the prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		Registry: m.registry,
+	})
+}
+
+func (m *Metrics) ObserveHttpRequest(method string, route string, status int, duration time.Duration) {
+	statusCode := strconv.Itoa(status)
+
+	m.httpRequestsTotal.WithLabelValues(method, route, statusCode).Inc()
+	m.httpRequestDuration.WithLabelValues(method, route, statusCode).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveStorageOperation(operation string, start time.Time, err error) {
+	m.storageOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		m.storageOperationErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+func (m *Metrics) ObserveReplicationLag(replica string, lag time.Duration) {
+	m.replicationLag.WithLabelValues(replica).Observe(lag.Seconds())
+}
+
+// CollectJobEvents records job metrics from a river event subscription until the subscription is closed
+func (m *Metrics) CollectJobEvents(events <-chan *river.Event) {
+	for event := range events {
+		if event == nil || event.Job == nil {
+			continue
+		}
+
+		kind := event.Job.Kind
+
+		m.jobsTotal.WithLabelValues(kind, string(event.Kind)).Inc()
+
+		if event.Kind == river.EventKindJobFailed {
+			m.jobFailuresTotal.WithLabelValues(kind, string(event.Job.State)).Inc()
+		}
+
+		if event.JobStats != nil {
+			m.jobRunDuration.WithLabelValues(kind).Observe(event.JobStats.RunDuration.Seconds())
+			m.jobQueueWaitTime.WithLabelValues(kind).Observe(event.JobStats.QueueWaitDuration.Seconds())
+		}
+	}
+}
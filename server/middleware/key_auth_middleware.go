@@ -4,10 +4,13 @@ import (
 	"errors"
 	"github.com/gofiber/fiber/v2/middleware/keyauth"
 	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/services"
 	"github.com/teapartydev/storage/server/utils"
 )
 
-func KeyAuth(config *config.Config) fiber.Handler {
+// KeyAuth accepts the master service_api_key from config as well as any active key issued through the api key service.
+// the key a request is authenticated with becomes the principal of its user context, the master key is an admin
+func KeyAuth(config *config.Config, apiKeyService *services.ApiKeyService) fiber.Handler {
 	return keyauth.New(keyauth.Config{
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
 			if errors.Is(err, keyauth.ErrMissingOrMalformedAPIKey) {
@@ -28,6 +31,10 @@ func KeyAuth(config *config.Config) fiber.Handler {
 		KeyLookup: "header:X-STORAGE-API-KEY",
 		Validator: func(ctx *fiber.Ctx, apiKey string) (bool, error) {
 			if apiKey == config.ServiceApiKey {
+				ctx.SetUserContext(utils.WithPrincipal(ctx.UserContext(), utils.Principal{Id: utils.PrincipalService, Admin: true}))
+				return true, nil
+			} else if key, err := apiKeyService.ValidateApiKey(ctx.UserContext(), apiKey); err == nil {
+				ctx.SetUserContext(utils.WithPrincipal(ctx.UserContext(), utils.Principal{Id: key.Id, Admin: key.Admin}))
 				return true, nil
 			} else {
 				return false, ctx.Status(fiber.StatusUnauthorized).JSON(&HttpError{
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/teapartydev/storage/server/utils"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +19,7 @@ func Logger(logger *zap.Logger) fiber.Handler {
 					zap.String("ip", ctx.IP()),
 					zap.String("user-agent", ctx.Get("User-Agent")),
 					zap.String("request-id", utils.RequestId(ctx.Context())),
+					zap.String("trace-id", trace.SpanContextFromContext(ctx.UserContext()).TraceID().String()),
 				}
 			},
 		},
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/metrics"
+)
+
+// Metrics records request counts and latencies. it has to be registered before the logger middleware
+// so the error handler has already written the final status code when the request is observed
+func Metrics(metrics *metrics.Metrics) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+
+		err := ctx.Next()
+
+		metrics.ObserveHttpRequest(ctx.Method(), ctx.Route().Path, ctx.Response().StatusCode(), time.Since(start))
+
+		return err
+	}
+}
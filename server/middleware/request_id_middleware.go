@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/oklog/ulid/v2"
+	"github.com/teapartydev/storage/server/utils"
 )
 
 func RequestId() fiber.Handler {
@@ -14,3 +15,13 @@ func RequestId() fiber.Handler {
 		ContextKey: "request_id",
 	})
 }
+
+// RequestContext copies the request id into the user context handed to services
+// so it follows the request into the database, storage and job layers
+func RequestContext() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.SetUserContext(utils.WithRequestId(ctx.UserContext(), utils.RequestId(ctx.Context())))
+
+		return ctx.Next()
+	}
+}
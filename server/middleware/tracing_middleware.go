@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type headerCarrier struct {
+	ctx *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.ctx.Get(key)
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h.ctx.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	for key := range h.ctx.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Tracing starts a server span for every request and stores it on the user context so services
+// continue the trace. it must be registered after RequestContext so the request id is already set
+func Tracing() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		spanCtx := otel.GetTextMapPropagator().Extract(ctx.UserContext(), headerCarrier{ctx: ctx})
+
+		spanCtx, span := tracing.Tracer().Start(spanCtx, fmt.Sprintf("%s %s", ctx.Method(), ctx.Path()),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Method()),
+				semconv.URLPath(ctx.Path()),
+				semconv.ClientAddress(ctx.IP()),
+				semconv.UserAgentOriginal(ctx.Get(fiber.HeaderUserAgent)),
+				attribute.String("request_id", utils.RequestId(ctx.Context())),
+			),
+		)
+		defer span.End()
+
+		ctx.SetUserContext(spanCtx)
+
+		err := ctx.Next()
+
+		route := ctx.Route().Path
+		span.SetName(fmt.Sprintf("%s %s", ctx.Method(), route))
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(ctx.Response().StatusCode()))
+		}
+
+		return err
+	}
+}
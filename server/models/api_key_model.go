@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ApiKeyPrefix = "hds_"
+)
+
+type ApiKey struct {
+	Id         string     `json:"id" example:"apikey_01HPG4GN5JY2Z6S0638ERSG375"`
+	Name       string     `json:"name" example:"billing-service"`
+	KeyPrefix  string     `json:"key_prefix" example:"hds_3f9a1c"`
+	Admin      bool       `json:"admin" example:"false"`
+	LastUsedAt *time.Time `json:"last_used_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+	RevokedAt  *time.Time `json:"revoked_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+	CreatedAt  time.Time  `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+}
+
+// ApiKeyCreated is only returned when a key is created since the plain key is never stored
+type ApiKeyCreated struct {
+	ApiKey
+	Key string `json:"key" example:"hds_3f9a1c0d4e5b6a7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"`
+	// `s3_credentials` is only set when the s3 gateway is configured
+	S3Credentials *S3Credentials `json:"s3_credentials" extensions:"x-nullable"`
+}
+
+// S3Credentials sign requests to the s3 gateway, the access key id is the id of the api key
+type S3Credentials struct {
+	AccessKeyId     string `json:"access_key_id" example:"apikey_01HPG4GN5JY2Z6S0638ERSG375"`
+	SecretAccessKey string `json:"secret_access_key" example:"5b0e4c1f2a3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7"`
+}
+
+type ApiKeyCreate struct {
+	// `name` identifies who the key was handed out to and must be unique
+	Name string `json:"name" example:"billing-service"`
+	// `admin` keys can bypass governance retention, every bypass is audited
+	Admin bool `json:"admin" example:"false"`
+}
+
+func (a *ApiKeyCreate) IsValid() error {
+	if !IsNotEmptyTrimmedString(a.Name) {
+		return fmt.Errorf("api key name cannot be empty. api key name is required to create api key")
+	}
+
+	if len(a.Name) > 128 {
+		return fmt.Errorf("api key name cannot be longer than 128 characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestApiKeyCreate_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKey   *ApiKeyCreate
+		expected error
+	}{
+		{
+			name:     "Valid ApiKeyCreate",
+			apiKey:   &ApiKeyCreate{Name: "billing-service"},
+			expected: nil,
+		},
+		{
+			name:     "Invalid ApiKeyCreate (Empty Name)",
+			apiKey:   &ApiKeyCreate{Name: "  "},
+			expected: fmt.Errorf("api key name cannot be empty. api key name is required to create api key"),
+		},
+		{
+			name:     "Invalid ApiKeyCreate (Long Name)",
+			apiKey:   &ApiKeyCreate{Name: strings.Repeat("a", 129)},
+			expected: fmt.Errorf("api key name cannot be longer than 128 characters"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.apiKey.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+const (
+	// BucketArchiveLocationStorage keeps archives in storage next to the buckets, BucketArchiveLocationLocal keeps them
+	// in the archive directory of the server
+	BucketArchiveLocationStorage = "storage"
+	BucketArchiveLocationLocal   = "local"
+
+	BucketArchiveFormatTar = "tar"
+	BucketArchiveFormatZip = "zip"
+
+	AuditActionBucketExport  = "bucket.export"
+	AuditActionBucketRestore = "bucket.restore"
+)
+
+type BucketArchive struct {
+	//	`location` is where the archive is kept, `storage` archives are kept in storage next to the buckets and `local`
+	//	archives in the archive directory the server is configured with
+	Location string `json:"location" enum:"storage,local" example:"storage"`
+	//	`path` of the archive within its location, it ends in `.tar` or `.zip` which is the format of the archive
+	Path string `json:"path" example:"offboarding/avatar-2024-02-13.tar"`
+}
+
+func (b *BucketArchive) IsValid() error {
+	if b.Location != BucketArchiveLocationStorage && b.Location != BucketArchiveLocationLocal {
+		return errors.New("location must be one of 'storage' or 'local'")
+	}
+
+	if !IsValidObjectName(b.Path) {
+		return errors.New("path must be a valid object name")
+	}
+
+	if path.Clean(b.Path) != b.Path || strings.HasPrefix(b.Path, "../") {
+		return errors.New("path cannot contain empty, '.' or '..' segments")
+	}
+
+	if b.Format() == "" {
+		return errors.New("path must end in '.tar' or '.zip'")
+	}
+
+	return nil
+}
+
+// Format is the format of the archive, it is empty for paths without a known extension
+func (b *BucketArchive) Format() string {
+	switch path.Ext(b.Path) {
+	case ".tar":
+		return BucketArchiveFormatTar
+	case ".zip":
+		return BucketArchiveFormatZip
+	default:
+		return ""
+	}
+}
+
+type BucketRestore struct {
+	Archive BucketArchive `json:"archive"`
+	//	`name` restores the bucket under a new name, if set to `null` the bucket keeps the name it was archived with
+	Name *string `json:"name" example:"avatar-restored" extensions:"x-nullable"`
+}
+
+func (b *BucketRestore) IsValid() error {
+	if err := b.Archive.IsValid(); err != nil {
+		return err
+	}
+
+	if b.Name != nil && !IsValidBucketName(*b.Name) {
+		return errors.New("bucket name is not valid. it must start and end with an alphanumeric character, and can include alphanumeric characters, hyphens, and dots. The total length must be between 3 and 63 characters")
+	}
+
+	return nil
+}
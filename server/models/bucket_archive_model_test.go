@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketArchive_IsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		bucketArchive *BucketArchive
+		expected      error
+	}{
+		{
+			name: "Valid BucketArchive (Tar In Storage)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationStorage,
+				Path:     "offboarding/avatar.tar",
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid BucketArchive (Local Zip)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationLocal,
+				Path:     "avatar.zip",
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketArchive (Location)",
+			bucketArchive: &BucketArchive{
+				Location: "ftp",
+				Path:     "avatar.tar",
+			},
+			expected: errors.New("location must be one of 'storage' or 'local'"),
+		},
+		{
+			name: "Invalid BucketArchive (Absolute Path)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationLocal,
+				Path:     "/etc/avatar.tar",
+			},
+			expected: errors.New("path must be a valid object name"),
+		},
+		{
+			name: "Invalid BucketArchive (Parent Segment)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationLocal,
+				Path:     "../avatar.tar",
+			},
+			expected: errors.New("path cannot contain empty, '.' or '..' segments"),
+		},
+		{
+			name: "Invalid BucketArchive (Nested Parent Segment)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationLocal,
+				Path:     "backups/../../avatar.tar",
+			},
+			expected: errors.New("path cannot contain empty, '.' or '..' segments"),
+		},
+		{
+			name: "Invalid BucketArchive (Format)",
+			bucketArchive: &BucketArchive{
+				Location: BucketArchiveLocationStorage,
+				Path:     "avatar.tar.gz",
+			},
+			expected: errors.New("path must end in '.tar' or '.zip'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bucketArchive.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestBucketRestore_IsValid(t *testing.T) {
+	name := "avatar-restored"
+	invalidName := "Avatar_Restored"
+
+	tests := []struct {
+		name          string
+		bucketRestore *BucketRestore
+		expected      error
+	}{
+		{
+			name: "Valid BucketRestore (Archived Name)",
+			bucketRestore: &BucketRestore{
+				Archive: BucketArchive{Location: BucketArchiveLocationStorage, Path: "avatar.tar"},
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid BucketRestore (New Name)",
+			bucketRestore: &BucketRestore{
+				Archive: BucketArchive{Location: BucketArchiveLocationStorage, Path: "avatar.tar"},
+				Name:    &name,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketRestore (Archive)",
+			bucketRestore: &BucketRestore{
+				Archive: BucketArchive{Location: BucketArchiveLocationStorage, Path: "avatar"},
+			},
+			expected: errors.New("path must end in '.tar' or '.zip'"),
+		},
+		{
+			name: "Invalid BucketRestore (Name)",
+			bucketRestore: &BucketRestore{
+				Archive: BucketArchive{Location: BucketArchiveLocationStorage, Path: "avatar.tar"},
+				Name:    &invalidName,
+			},
+			expected: errors.New("bucket name is not valid. it must start and end with an alphanumeric character, and can include alphanumeric characters, hyphens, and dots. The total length must be between 3 and 63 characters"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bucketRestore.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
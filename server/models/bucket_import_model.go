@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// BucketImportMetadataKey is the metadata key imported objects record the storage attributes they were imported with
+const BucketImportMetadataKey = "import"
+
+type BucketImport struct {
+	//	`prefix` selects the content of the bucket to import, an empty prefix imports all of it
+	Prefix string `json:"prefix" example:"invoices/2023/"`
+	//	`source_prefix` is the storage prefix the content is in, it defaults to the name of the bucket and can only be
+	//	the name of the bucket or a prefix its content is stored under. content under another prefix than the one the
+	//	bucket is stored under is copied into the bucket and left where it is
+	SourcePrefix string `json:"source_prefix" example:"invoices"`
+	//	`overwrite` updates objects of the same name with the size and content type in storage instead of skipping them.
+	//	objects under a legal hold, retention or deny_delete tag rule are always skipped
+	Overwrite bool `json:"overwrite" example:"false"`
+}
+
+func (b *BucketImport) IsValid() error {
+	if strings.HasPrefix(b.Prefix, "/") {
+		return errors.New("prefix cannot start with '/'. object names never start with '/'")
+	}
+
+	if strings.ContainsRune(b.Prefix, '\n') || strings.ContainsRune(b.Prefix, '\t') {
+		return errors.New("prefix cannot contain new lines or tabs")
+	}
+
+	return nil
+}
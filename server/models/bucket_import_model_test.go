@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketImport_IsValid(t *testing.T) {
+	tests := []struct {
+		name         string
+		bucketImport *BucketImport
+		expected     error
+	}{
+		{
+			name:         "Valid BucketImport (Whole Bucket)",
+			bucketImport: &BucketImport{},
+			expected:     nil,
+		},
+		{
+			name: "Valid BucketImport (Prefix With Overwrite)",
+			bucketImport: &BucketImport{
+				Prefix:    "invoices/2023/",
+				Overwrite: true,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketImport (Leading Slash)",
+			bucketImport: &BucketImport{
+				Prefix: "/invoices/",
+			},
+			expected: errors.New("prefix cannot start with '/'. object names never start with '/'"),
+		},
+		{
+			name: "Invalid BucketImport (Tab)",
+			bucketImport: &BucketImport{
+				Prefix: "invoices\t2023/",
+			},
+			expected: errors.New("prefix cannot contain new lines or tabs"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bucketImport.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
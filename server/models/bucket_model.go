@@ -8,25 +8,44 @@ import (
 )
 
 const (
-	BucketLockedReasonBucketDeletion = "bucket.deletion"
-	BucketLockedReasonBucketEmptying = "bucket.emptying"
+	BucketLockedReasonBucketDeletion   = "bucket.deletion"
+	BucketLockedReasonBucketEmptying   = "bucket.emptying"
+	BucketLockedReasonBucketRestore    = "bucket.restore"
+	BucketLockedReasonBucketRelocation = "bucket.relocation"
 
 	BucketAllowedMimeTypesWildcard = "*/*"
+
+	AuditActionBucketRename            = "bucket.rename"
+	AuditActionBucketUnlock            = "bucket.unlock"
+	AuditActionBucketLockReleased      = "bucket.lock.released"
+	AuditActionBucketLockRecoveryRetry = "bucket.lock.recovery_retry"
 )
 
 type Bucket struct {
-	Id                   string     `json:"id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
-	Version              int32      `json:"version" example:"0"`
-	Name                 string     `json:"name" example:"avatar"`
+	Id      string `json:"id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	Version int32  `json:"version" example:"0"`
+	Name    string `json:"name" example:"avatar"`
+	//	`storage_prefix` is the prefix the content of the bucket is stored under. it is the id of the bucket, except for
+	//	buckets created before content was stored by id which keep their name until they are relocated. only buckets
+	//	stored under their id can be renamed
+	StoragePrefix        string     `json:"storage_prefix" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
 	AllowedMimeTypes     []string   `json:"allowed_mime_types" example:"image/jpeg, image/png, video/mp4, audio/wav"`
 	MaxAllowedObjectSize *int64     `json:"max_allowed_object_size" example:"10485760" extensions:"x-nullable"`
 	Public               bool       `json:"public" example:"false"`
+	Processors           []string   `json:"processors" example:"content_type, image, checksum"`
+	DefaultRetentionMode *string    `json:"default_retention_mode" enum:"governance,compliance" example:"compliance" extensions:"x-nullable"`
+	DefaultRetentionDays *int32     `json:"default_retention_days" example:"2555" extensions:"x-nullable"`
+	Replica              *string    `json:"replica" example:"backup" extensions:"x-nullable"`
+	ArchiveAfterDays     *int32     `json:"archive_after_days" example:"90" extensions:"x-nullable"`
 	Disabled             bool       `json:"disabled" example:"false"`
 	Locked               bool       `json:"locked" example:"false"`
-	LockReason           *string    `json:"lock_reason" enum:"bucket.deletion,bucket.emptying" example:"bucket.deletion" extensions:"x-nullable"`
+	LockReason           *string    `json:"lock_reason" enum:"bucket.deletion,bucket.emptying,bucket.restore,bucket.relocation" example:"bucket.deletion" extensions:"x-nullable"`
 	LockedAt             *time.Time `json:"locked_at" default:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
-	CreatedAt            time.Time  `json:"created_at" default:"2024-02-13T08:14:49.952238+05:30"`
-	UpdatedAt            *time.Time `json:"updated_at" default:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
+	//	`lock_job_id` is the job holding the lock, it renews `lock_heartbeat_at` while it works
+	LockJobId       *int64     `json:"lock_job_id" example:"1024" extensions:"x-nullable"`
+	LockHeartbeatAt *time.Time `json:"lock_heartbeat_at" default:"2024-02-13T08:17:49.952238+05:30" extensions:"x-nullable"`
+	CreatedAt       time.Time  `json:"created_at" default:"2024-02-13T08:14:49.952238+05:30"`
+	UpdatedAt       *time.Time `json:"updated_at" default:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
 }
 
 type BucketSize struct {
@@ -60,6 +79,30 @@ type BucketCreate struct {
 		if public is false the bucket will only accessible with authentication. if set to `null` defaults to `false`
 	*/
 	Public bool `json:"public" default:"false" example:"false" extensions:"x-nullable"`
+	/*
+		`processors` names the processors that run on every object once its upload completes, each processor only
+		runs on the mime types it handles. if set to `null` or an empty list no processors run
+	*/
+	Processors []string `json:"processors" example:"content_type, image, checksum" extensions:"x-nullable"`
+	/*
+		`default_retention_mode` and `default_retention_days` put every object uploaded into the bucket under retention
+		for that many days. `governance` retention can be bypassed by admin keys, `compliance` retention cannot be
+		bypassed by anyone. both are `null` when the bucket has no default retention
+	*/
+	DefaultRetentionMode *string `json:"default_retention_mode" enum:"governance,compliance" example:"compliance" extensions:"x-nullable"`
+	DefaultRetentionDays *int32  `json:"default_retention_days" example:"2555" extensions:"x-nullable"`
+	/*
+		`replica` names one of the replicas configured on the server, completed objects are copied to it in the
+		background and downloads fall back to it when the primary storage fails. if set to `null` objects are not
+		replicated
+	*/
+	Replica *string `json:"replica" example:"backup" extensions:"x-nullable"`
+	/*
+		`archive_after_days` moves completed objects that were not downloaded for that many days to the cold tier,
+		objects never downloaded count from their creation. cold objects have to be restored before they can be
+		downloaded again. if set to `null` objects are never archived
+	*/
+	ArchiveAfterDays *int32 `json:"archive_after_days" example:"90" extensions:"x-nullable"`
 }
 
 func (b *BucketCreate) IsValid() error {
@@ -96,6 +139,24 @@ func (b *BucketCreate) IsValid() error {
 		}
 	}
 
+	if err := validateBucketProcessors(b.Processors); err != nil {
+		return err
+	}
+
+	if b.DefaultRetentionMode != nil || b.DefaultRetentionDays != nil {
+		if err := validateBucketDefaultRetention(b.DefaultRetentionMode, b.DefaultRetentionDays); err != nil {
+			return err
+		}
+	}
+
+	if b.Replica != nil && !IsNotEmptyTrimmedString(*b.Replica) {
+		return fmt.Errorf("bucket replica cannot be empty. set it to null to create a bucket without a replica")
+	}
+
+	if b.ArchiveAfterDays != nil && *b.ArchiveAfterDays < 1 {
+		return fmt.Errorf("bucket archive_after_days must be at least 1")
+	}
+
 	return nil
 }
 
@@ -127,6 +188,28 @@ type BucketUpdate struct {
 		if public is false the bucket will only accessible with authentication. if set to `null` defaults to `false`
 	*/
 	Public *bool `json:"public" example:"false" extensions:"x-nullable"`
+	/*
+		`processors` names the processors that run on every object once its upload completes. if set to `null` the
+		processors are left unchanged, an empty list turns processing off
+	*/
+	Processors []string `json:"processors" example:"content_type, image, checksum" extensions:"x-nullable"`
+	/*
+		`default_retention_mode` and `default_retention_days` replace the default retention of the bucket, objects
+		already uploaded keep their retention. if the mode is set to `null` the default retention is left unchanged,
+		an empty mode with `null` days removes it
+	*/
+	DefaultRetentionMode *string `json:"default_retention_mode" enum:"governance,compliance" example:"compliance" extensions:"x-nullable"`
+	DefaultRetentionDays *int32  `json:"default_retention_days" example:"2555" extensions:"x-nullable"`
+	/*
+		`replica` replaces the replica completed objects are copied to, objects already uploaded are only copied when
+		their content changes. if set to `null` the replica is left unchanged, an empty replica stops replication
+	*/
+	Replica *string `json:"replica" example:"backup" extensions:"x-nullable"`
+	/*
+		`archive_after_days` replaces the days after which objects that were not downloaded are moved to the cold tier,
+		objects already archived stay archived. if set to `null` it is left unchanged, `0` stops archiving
+	*/
+	ArchiveAfterDays *int32 `json:"archive_after_days" example:"90" extensions:"x-nullable"`
 }
 
 func (b *BucketUpdate) IsValid() error {
@@ -159,5 +242,79 @@ func (b *BucketUpdate) IsValid() error {
 		}
 	}
 
+	if err := validateBucketProcessors(b.Processors); err != nil {
+		return err
+	}
+
+	if b.DefaultRetentionMode == nil {
+		if b.DefaultRetentionDays != nil {
+			return fmt.Errorf("bucket default_retention_days cannot be set without default_retention_mode")
+		}
+	} else if *b.DefaultRetentionMode == "" {
+		if b.DefaultRetentionDays != nil {
+			return fmt.Errorf("bucket default_retention_days must be null when the default retention is removed")
+		}
+	} else if err := validateBucketDefaultRetention(b.DefaultRetentionMode, b.DefaultRetentionDays); err != nil {
+		return err
+	}
+
+	if b.ArchiveAfterDays != nil && *b.ArchiveAfterDays < 0 {
+		return fmt.Errorf("bucket archive_after_days cannot be negative. set it to 0 to stop archiving")
+	}
+
+	return nil
+}
+
+type BucketRename struct {
+	Id string `json:"-" params:"id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	/*
+		`name` is the new name of the bucket, it follows the same rules as the name a bucket is created with.
+		only buckets whose content is stored under their id can be renamed, see `storage_prefix`
+	*/
+	Name string `json:"name" example:"avatar-archive"`
+}
+
+func (b *BucketRename) IsValid() error {
+	if !IsNotEmptyTrimmedString(b.Id) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to rename bucket")
+	}
+
+	if !IsNotEmptyTrimmedString(b.Name) {
+		return fmt.Errorf("bucket name cannot be empty. bucket name is required to rename bucket")
+	}
+
+	if !IsValidBucketName(b.Name) {
+		return fmt.Errorf("bucket name is not valid. it must start and end with an alphanumeric character, and can include alphanumeric characters, hyphens, and dots. The total length must be between 3 and 63 characters")
+	}
+
+	return nil
+}
+
+func (b *BucketRename) PreSave() {
+	b.Name = strings.TrimSpace(b.Name)
+}
+
+func validateBucketDefaultRetention(mode *string, days *int32) error {
+	if mode == nil || !IsValidRetentionMode(*mode) {
+		return fmt.Errorf("bucket default_retention_mode must be one of '%s' or '%s'", ObjectRetentionModeGovernance, ObjectRetentionModeCompliance)
+	}
+
+	if days == nil || *days < 1 {
+		return fmt.Errorf("bucket default_retention_days must be at least 1")
+	}
+
+	return nil
+}
+
+func validateBucketProcessors(processors []string) error {
+	for i, processor := range processors {
+		if !IsNotEmptyTrimmedString(processor) {
+			return fmt.Errorf("bucket processors cannot contain empty names")
+		}
+		if lo.Contains[string](processors[:i], processor) {
+			return fmt.Errorf("bucket processors cannot contain '%s' more than once", processor)
+		}
+	}
+
 	return nil
 }
@@ -83,6 +83,88 @@ func TestBucketCreate_IsValid(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name: "Valid BucketCreate (Processors)",
+			bucket: &BucketCreate{
+				Name:       "avatar",
+				Processors: []string{"content_type", "image"},
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketCreate (Empty Processor)",
+			bucket: &BucketCreate{
+				Name:       "avatar",
+				Processors: []string{"content_type", " "},
+			},
+			expected: fmt.Errorf("bucket processors cannot contain empty names"),
+		},
+		{
+			name: "Invalid BucketCreate (Duplicate Processor)",
+			bucket: &BucketCreate{
+				Name:       "avatar",
+				Processors: []string{"image", "checksum", "image"},
+			},
+			expected: fmt.Errorf("bucket processors cannot contain 'image' more than once"),
+		},
+		{
+			name: "Valid BucketCreate (Default Retention)",
+			bucket: &BucketCreate{
+				Name:                 "records",
+				DefaultRetentionMode: func() *string { v := ObjectRetentionModeCompliance; return &v }(),
+				DefaultRetentionDays: func() *int32 { v := int32(2555); return &v }(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketCreate (Default Retention Without Days)",
+			bucket: &BucketCreate{
+				Name:                 "records",
+				DefaultRetentionMode: func() *string { v := ObjectRetentionModeGovernance; return &v }(),
+			},
+			expected: fmt.Errorf("bucket default_retention_days must be at least 1"),
+		},
+		{
+			name: "Invalid BucketCreate (Invalid Default Retention Mode)",
+			bucket: &BucketCreate{
+				Name:                 "records",
+				DefaultRetentionMode: func() *string { v := "forever"; return &v }(),
+				DefaultRetentionDays: func() *int32 { v := int32(30); return &v }(),
+			},
+			expected: fmt.Errorf("bucket default_retention_mode must be one of 'governance' or 'compliance'"),
+		},
+		{
+			name: "Valid BucketCreate (Replica)",
+			bucket: &BucketCreate{
+				Name:    "avatar",
+				Replica: func() *string { v := "backup"; return &v }(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketCreate (Empty Replica)",
+			bucket: &BucketCreate{
+				Name:    "avatar",
+				Replica: func() *string { v := " "; return &v }(),
+			},
+			expected: fmt.Errorf("bucket replica cannot be empty. set it to null to create a bucket without a replica"),
+		},
+		{
+			name: "Valid BucketCreate (Archive After Days)",
+			bucket: &BucketCreate{
+				Name:             "avatar",
+				ArchiveAfterDays: func() *int32 { v := int32(90); return &v }(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketCreate (Zero Archive After Days)",
+			bucket: &BucketCreate{
+				Name:             "avatar",
+				ArchiveAfterDays: func() *int32 { v := int32(0); return &v }(),
+			},
+			expected: fmt.Errorf("bucket archive_after_days must be at least 1"),
+		},
 		{
 			name: "Valid BucketCreate (Null Public)",
 			bucket: &BucketCreate{
@@ -133,6 +215,38 @@ func TestBucketUpdate_IsValid(t *testing.T) {
 			},
 			expected: fmt.Errorf("bucket id cannot be empty. bucket id is required to update bucket"),
 		},
+		{
+			name: "Valid BucketUpdate (Stop Archiving)",
+			bucket: &BucketUpdate{
+				Id:               "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ArchiveAfterDays: func() *int32 { v := int32(0); return &v }(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketUpdate (Negative Archive After Days)",
+			bucket: &BucketUpdate{
+				Id:               "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ArchiveAfterDays: func() *int32 { v := int32(-1); return &v }(),
+			},
+			expected: fmt.Errorf("bucket archive_after_days cannot be negative. set it to 0 to stop archiving"),
+		},
+		{
+			name: "Valid BucketUpdate (Empty Processors)",
+			bucket: &BucketUpdate{
+				Id:         "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Processors: []string{},
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketUpdate (Duplicate Processor)",
+			bucket: &BucketUpdate{
+				Id:         "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Processors: []string{"checksum", "checksum"},
+			},
+			expected: fmt.Errorf("bucket processors cannot contain 'checksum' more than once"),
+		},
 		{
 			name: "Invalid BucketUpdate (Invalid MIME Type)",
 			bucket: &BucketUpdate{
@@ -176,6 +290,31 @@ func TestBucketUpdate_IsValid(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name: "Valid BucketUpdate (Remove Default Retention)",
+			bucket: &BucketUpdate{
+				Id:                   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				DefaultRetentionMode: func() *string { v := ""; return &v }(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketUpdate (Default Retention Days Without Mode)",
+			bucket: &BucketUpdate{
+				Id:                   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				DefaultRetentionDays: func() *int32 { v := int32(30); return &v }(),
+			},
+			expected: fmt.Errorf("bucket default_retention_days cannot be set without default_retention_mode"),
+		},
+		{
+			name: "Invalid BucketUpdate (Zero Default Retention Days)",
+			bucket: &BucketUpdate{
+				Id:                   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				DefaultRetentionMode: func() *string { v := ObjectRetentionModeGovernance; return &v }(),
+				DefaultRetentionDays: func() *int32 { v := int32(0); return &v }(),
+			},
+			expected: fmt.Errorf("bucket default_retention_days must be at least 1"),
+		},
 		{
 			name: "Valid BucketUpdate (Null Public)",
 			bucket: &BucketUpdate{
@@ -194,3 +333,51 @@ func TestBucketUpdate_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketRename_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		bucket   *BucketRename
+		expected error
+	}{
+		{
+			name: "Valid BucketRename",
+			bucket: &BucketRename{
+				Id:   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name: "avatar-archive",
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid BucketRename (Empty Id)",
+			bucket: &BucketRename{
+				Id:   "",
+				Name: "avatar-archive",
+			},
+			expected: fmt.Errorf("bucket id cannot be empty. bucket id is required to rename bucket"),
+		},
+		{
+			name: "Invalid BucketRename (Empty Name)",
+			bucket: &BucketRename{
+				Id:   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name: " ",
+			},
+			expected: fmt.Errorf("bucket name cannot be empty. bucket name is required to rename bucket"),
+		},
+		{
+			name: "Invalid BucketRename (Invalid Name)",
+			bucket: &BucketRename{
+				Id:   "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name: "invalid_name!",
+			},
+			expected: fmt.Errorf("bucket name is not valid. it must start and end with an alphanumeric character, and can include alphanumeric characters, hyphens, and dots. The total length must be between 3 and 63 characters"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bucket.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+type Health struct {
+	Status string                  `json:"status" enum:"up,down" example:"up"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty" extensions:"x-nullable"`
+}
+
+type HealthCheck struct {
+	Status     string  `json:"status" enum:"up,down" example:"up"`
+	Message    *string `json:"message" example:"database migrations are at version 4 of 4" extensions:"x-nullable"`
+	DurationMs int64   `json:"duration_ms" example:"3"`
+}
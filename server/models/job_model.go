@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Job struct {
+	Id          int64           `json:"id" example:"1024"`
+	Kind        string          `json:"kind" example:"bucket.deletion"`
+	Queue       string          `json:"queue" example:"bucket_deletion"`
+	State       string          `json:"state" enum:"available,cancelled,completed,discarded,retryable,running,scheduled" example:"running"`
+	Attempt     int             `json:"attempt" example:"1"`
+	MaxAttempts int             `json:"max_attempts" example:"25"`
+	Args        json.RawMessage `json:"args" swaggertype:"object"`
+	Errors      []*JobError     `json:"errors"`
+	Progress    *JobProgress    `json:"progress" extensions:"x-nullable"`
+	CreatedAt   time.Time       `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	ScheduledAt time.Time       `json:"scheduled_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	AttemptedAt *time.Time      `json:"attempted_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+	FinalizedAt *time.Time      `json:"finalized_at" example:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
+}
+
+type JobError struct {
+	At      time.Time `json:"at" example:"2024-02-13T08:16:49.952238+05:30"`
+	Attempt int       `json:"attempt" example:"1"`
+	Error   string    `json:"error" example:"failed to delete object"`
+}
+
+// JobProgress is the checkpoint a long running job records after every batch it works through, a retried job resumes
+// after the cursor instead of starting over
+type JobProgress struct {
+	// `cursor` is the id of the last item the job is done with
+	Cursor string `json:"cursor" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	// `total` is the number of items there were to work through when the job first started
+	Total int64 `json:"total" example:"1500000"`
+	// `processed` counts the items the job is done with, `skipped` the ones among them it had to leave alone
+	Processed int64     `json:"processed" example:"42000"`
+	Skipped   int64     `json:"skipped" example:"3"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-02-13T08:16:49.952238+05:30"`
+}
+
+// ParseJobProgress reads the progress a job recorded in its metadata, it is nil for jobs that recorded none
+func ParseJobProgress(metadata []byte) (*JobProgress, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	var jobMetadata struct {
+		Progress *JobProgress `json:"progress"`
+	}
+	if err := json.Unmarshal(metadata, &jobMetadata); err != nil {
+		return nil, err
+	}
+
+	return jobMetadata.Progress, nil
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ObjectBatchOperationDelete = "delete"
+	ObjectBatchOperationCopy   = "copy"
+	ObjectBatchOperationTag    = "tag"
+
+	ObjectBatchItemStatusPending   = "pending"
+	ObjectBatchItemStatusSucceeded = "succeeded"
+	ObjectBatchItemStatusFailed    = "failed"
+
+	// ObjectBatchMaxObjectIds bounds the object ids a batch can list, larger sets are selected by prefix
+	ObjectBatchMaxObjectIds = 1000
+	// ObjectBatchMaxItems bounds the objects a prefix can select into a single batch
+	ObjectBatchMaxItems = 100000
+)
+
+var objectBatchOperations = []string{ObjectBatchOperationDelete, ObjectBatchOperationCopy, ObjectBatchOperationTag}
+
+// ObjectBatchCreate applies one operation to many objects of a bucket in a single background job. the objects are
+// either listed by id or selected by a name prefix
+type ObjectBatchCreate struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`operation` is one of `delete`, `copy` or `tag`
+	Operation string `json:"operation" enum:"delete,copy,tag" example:"delete"`
+	//	`object_ids` lists at most 1000 objects, it cannot be combined with `prefix`
+	ObjectIds []string `json:"object_ids" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`prefix` selects every uploaded object whose name starts with it, an empty prefix selects the whole bucket
+	Prefix *string `json:"prefix" example:"invoices/2024/" extensions:"x-nullable"`
+	ObjectBatchOptions
+}
+
+// ObjectBatchOptions are the parameters of the operation of a batch, only the ones of its operation are set
+type ObjectBatchOptions struct {
+	//	`destination_bucket_id` is the bucket objects are copied to, required to copy
+	DestinationBucketId *string `json:"destination_bucket_id,omitempty" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375" extensions:"x-nullable"`
+	//	`destination_prefix` is put in front of the names of the copies, required to copy within the same bucket
+	DestinationPrefix *string `json:"destination_prefix,omitempty" example:"archive/" extensions:"x-nullable"`
+	//	`tags` replace every tag of the objects when tagging, an empty object removes them all
+	Tags map[string]string `json:"tags,omitempty" example:"retention:legal"`
+	//	`bypass_governance_retention` deletes objects under governance retention, only admin api keys can set it
+	BypassGovernance bool `json:"bypass_governance_retention,omitempty"`
+}
+
+func (c *ObjectBatchCreate) IsValid() error {
+	if !IsNotEmptyTrimmedString(c.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to create an object batch")
+	}
+
+	if !IsValidObjectBatchOperation(c.Operation) {
+		return fmt.Errorf("invalid operation '%s'. operation must be one of %v", c.Operation, objectBatchOperations)
+	}
+
+	if c.Prefix != nil && len(c.ObjectIds) > 0 {
+		return fmt.Errorf("object ids and prefix cannot be combined. either list the objects or select them by prefix")
+	}
+
+	if c.Prefix == nil && len(c.ObjectIds) == 0 {
+		return fmt.Errorf("object ids or prefix are required to select the objects of the batch")
+	}
+
+	if len(c.ObjectIds) > ObjectBatchMaxObjectIds {
+		return fmt.Errorf("a batch can list at most %d object ids. select more objects by prefix", ObjectBatchMaxObjectIds)
+	}
+
+	for _, objectId := range c.ObjectIds {
+		if !IsNotEmptyTrimmedString(objectId) {
+			return fmt.Errorf("object ids cannot be empty")
+		}
+	}
+
+	if c.BypassGovernance && c.Operation != ObjectBatchOperationDelete {
+		return fmt.Errorf("governance retention can only be bypassed to delete objects")
+	}
+
+	if c.Operation == ObjectBatchOperationCopy {
+		if c.DestinationBucketId == nil || !IsNotEmptyTrimmedString(*c.DestinationBucketId) {
+			return fmt.Errorf("destination bucket id cannot be empty. destination bucket id is required to copy objects")
+		}
+
+		if *c.DestinationBucketId == c.BucketId && (c.DestinationPrefix == nil || *c.DestinationPrefix == "") {
+			return fmt.Errorf("destination prefix cannot be empty when copying objects within the same bucket")
+		}
+	} else if c.DestinationBucketId != nil || c.DestinationPrefix != nil {
+		return fmt.Errorf("destination bucket id and destination prefix can only be given to copy objects")
+	}
+
+	if c.Operation == ObjectBatchOperationTag {
+		if len(c.Tags) > ObjectTagsMaxCount {
+			return fmt.Errorf("an object can have at most %d tags", ObjectTagsMaxCount)
+		}
+
+		for key, value := range c.Tags {
+			if err := isValidTag(key, value); err != nil {
+				return err
+			}
+		}
+	} else if len(c.Tags) > 0 {
+		return fmt.Errorf("tags can only be given to tag objects")
+	}
+
+	return nil
+}
+
+func IsValidObjectBatchOperation(operation string) bool {
+	for _, objectBatchOperation := range objectBatchOperations {
+		if objectBatchOperation == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectBatch reports how far a batch got. `state` is the state of the job working it, the counts break its items
+// down by status and `items` hold the result of every item, narrowed by the item status asked for
+type ObjectBatch struct {
+	Id        string             `json:"id" example:"batch_01HPG4GN5JY2Z6S0638ERSG375"`
+	BucketId  string             `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	Operation string             `json:"operation" enum:"delete,copy,tag" example:"delete"`
+	Options   ObjectBatchOptions `json:"options"`
+	JobId     *int64             `json:"job_id" example:"1024" extensions:"x-nullable"`
+	State     string             `json:"state" enum:"available,cancelled,completed,discarded,retryable,running,scheduled" example:"running"`
+	Total     int64              `json:"total" example:"250"`
+	Pending   int64              `json:"pending" example:"120"`
+	Succeeded int64              `json:"succeeded" example:"127"`
+	Failed    int64              `json:"failed" example:"3"`
+	Items     []*ObjectBatchItem `json:"items"`
+	CreatedAt time.Time          `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+}
+
+// ObjectBatchItem is the result of the operation of a batch on one object, `error` says why a failed item failed
+type ObjectBatchItem struct {
+	ObjectId  string     `json:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	Name      string     `json:"name" example:"invoices/2024/0001.pdf"`
+	Status    string     `json:"status" enum:"pending,succeeded,failed" example:"failed"`
+	Error     *string    `json:"error" example:"object is under a legal hold" extensions:"x-nullable"`
+	UpdatedAt *time.Time `json:"updated_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectBatchCreate_IsValid(t *testing.T) {
+	stringPtr := func(v string) *string { return &v }
+
+	tooMany := make([]string, ObjectBatchMaxObjectIds+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("object_%d", i)
+	}
+
+	tests := []struct {
+		name     string
+		create   *ObjectBatchCreate
+		expected error
+	}{
+		{
+			name: "Valid ObjectBatchCreate (Delete By Ids)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationDelete,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid ObjectBatchCreate (Copy By Prefix)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationCopy,
+				Prefix:    stringPtr("invoices/"),
+				ObjectBatchOptions: ObjectBatchOptions{
+					DestinationBucketId: stringPtr("bucket_01HPG4GN5JY2Z6S0638ERSG376"),
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid ObjectBatchCreate (Tag Whole Bucket)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationTag,
+				Prefix:    stringPtr(""),
+				ObjectBatchOptions: ObjectBatchOptions{
+					Tags: map[string]string{"retention": "legal"},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Unknown Operation)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: "move",
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+			},
+			expected: fmt.Errorf("invalid operation 'move'. operation must be one of [delete copy tag]"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Ids And Prefix)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationDelete,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+				Prefix:    stringPtr("invoices/"),
+			},
+			expected: fmt.Errorf("object ids and prefix cannot be combined. either list the objects or select them by prefix"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (No Selection)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationDelete,
+			},
+			expected: fmt.Errorf("object ids or prefix are required to select the objects of the batch"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Too Many Ids)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationDelete,
+				ObjectIds: tooMany,
+			},
+			expected: fmt.Errorf("a batch can list at most 1000 object ids. select more objects by prefix"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Bypass Governance To Tag)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationTag,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+				ObjectBatchOptions: ObjectBatchOptions{
+					BypassGovernance: true,
+				},
+			},
+			expected: fmt.Errorf("governance retention can only be bypassed to delete objects"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Copy Without Destination)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationCopy,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+			},
+			expected: fmt.Errorf("destination bucket id cannot be empty. destination bucket id is required to copy objects"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Copy Onto Itself)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationCopy,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+				ObjectBatchOptions: ObjectBatchOptions{
+					DestinationBucketId: stringPtr("bucket_01HPG4GN5JY2Z6S0638ERSG375"),
+				},
+			},
+			expected: fmt.Errorf("destination prefix cannot be empty when copying objects within the same bucket"),
+		},
+		{
+			name: "Invalid ObjectBatchCreate (Tags To Delete)",
+			create: &ObjectBatchCreate{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Operation: ObjectBatchOperationDelete,
+				ObjectIds: []string{"object_01HPG4GN5JY2Z6S0638ERSG375"},
+				ObjectBatchOptions: ObjectBatchOptions{
+					Tags: map[string]string{"retention": "legal"},
+				},
+			},
+			expected: fmt.Errorf("tags can only be given to tag objects"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.create.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
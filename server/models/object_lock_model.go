@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ObjectRetentionModeGovernance protects an object until its retention ends, admin keys can bypass it
+	ObjectRetentionModeGovernance = "governance"
+	// ObjectRetentionModeCompliance protects an object until its retention ends, nobody can bypass or shorten it
+	ObjectRetentionModeCompliance = "compliance"
+
+	// audit actions recorded for changes to object locks and for every bypass of governance retention
+	AuditActionObjectLegalHold       = "object.legal_hold"
+	AuditActionObjectRetention       = "object.retention"
+	AuditActionObjectRetentionBypass = "object.retention.bypass"
+	AuditActionBucketRetentionBypass = "bucket.retention.bypass"
+)
+
+func IsValidRetentionMode(mode string) bool {
+	return mode == ObjectRetentionModeGovernance || mode == ObjectRetentionModeCompliance
+}
+
+// IsObjectLocked reports whether an object under a legal hold or retention cannot be deleted or overwritten yet,
+// governance retention does not count when it is bypassed
+func IsObjectLocked(legalHold bool, retentionMode *string, retainUntil *time.Time, bypassGovernance bool) bool {
+	if legalHold {
+		return true
+	}
+
+	if retentionMode == nil || retainUntil == nil || !retainUntil.After(time.Now()) {
+		return false
+	}
+
+	return *retentionMode == ObjectRetentionModeCompliance || !bypassGovernance
+}
+
+type ObjectLegalHoldPut struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	ObjectId string `json:"-" params:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`legal_hold` protects the object from being deleted or overwritten until it is released, regardless of its
+	//	retention
+	LegalHold bool `json:"legal_hold" example:"true"`
+}
+
+func (p *ObjectLegalHoldPut) IsValid() error {
+	if !IsNotEmptyTrimmedString(p.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to set the legal hold of an object")
+	}
+
+	if !IsNotEmptyTrimmedString(p.ObjectId) {
+		return fmt.Errorf("object id cannot be empty. object id is required to set the legal hold of an object")
+	}
+
+	return nil
+}
+
+type ObjectRetentionPut struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	ObjectId string `json:"-" params:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`mode` and `retain_until` protect the object from being deleted or overwritten until then, both `null`
+	//	remove the retention. retention can always be extended, `compliance` retention can never be shortened,
+	//	changed to `governance` or removed
+	Mode        *string    `json:"mode" enum:"governance,compliance" example:"compliance" extensions:"x-nullable"`
+	RetainUntil *time.Time `json:"retain_until" example:"2031-02-13T08:14:49.952238+05:30" extensions:"x-nullable"`
+	//	`bypass_governance` shortens or removes `governance` retention, only admin keys can bypass it and every
+	//	bypass is audited
+	BypassGovernance bool `json:"bypass_governance" example:"false"`
+}
+
+func (p *ObjectRetentionPut) IsValid() error {
+	if !IsNotEmptyTrimmedString(p.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to set the retention of an object")
+	}
+
+	if !IsNotEmptyTrimmedString(p.ObjectId) {
+		return fmt.Errorf("object id cannot be empty. object id is required to set the retention of an object")
+	}
+
+	if (p.Mode == nil) != (p.RetainUntil == nil) {
+		return fmt.Errorf("mode and retain_until must either both be set or both be null")
+	}
+
+	if p.Mode != nil && !IsValidRetentionMode(*p.Mode) {
+		return fmt.Errorf("invalid retention mode '%s'. mode must be one of '%s' or '%s'", *p.Mode, ObjectRetentionModeGovernance, ObjectRetentionModeCompliance)
+	}
+
+	if p.RetainUntil != nil && !p.RetainUntil.After(time.Now()) {
+		return fmt.Errorf("retain_until must be in the future")
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsObjectLocked(t *testing.T) {
+	governance := ObjectRetentionModeGovernance
+	compliance := ObjectRetentionModeCompliance
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name             string
+		legalHold        bool
+		retentionMode    *string
+		retainUntil      *time.Time
+		bypassGovernance bool
+		expected         bool
+	}{
+		{name: "Unlocked", expected: false},
+		{name: "Legal Hold", legalHold: true, bypassGovernance: true, expected: true},
+		{name: "Compliance Retention", retentionMode: &compliance, retainUntil: &future, bypassGovernance: true, expected: true},
+		{name: "Expired Compliance Retention", retentionMode: &compliance, retainUntil: &past, expected: false},
+		{name: "Governance Retention", retentionMode: &governance, retainUntil: &future, expected: true},
+		{name: "Bypassed Governance Retention", retentionMode: &governance, retainUntil: &future, bypassGovernance: true, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsObjectLocked(tt.legalHold, tt.retentionMode, tt.retainUntil, tt.bypassGovernance))
+		})
+	}
+}
+
+func TestObjectLegalHoldPut_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		put      *ObjectLegalHoldPut
+		expected error
+	}{
+		{
+			name: "Valid ObjectLegalHoldPut",
+			put: &ObjectLegalHoldPut{
+				BucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId:  "object_01HPG4GN5JY2Z6S0638ERSG375",
+				LegalHold: true,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectLegalHoldPut (Empty Object Id)",
+			put: &ObjectLegalHoldPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: " ",
+			},
+			expected: fmt.Errorf("object id cannot be empty. object id is required to set the legal hold of an object"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.put.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestObjectRetentionPut_IsValid(t *testing.T) {
+	compliance := ObjectRetentionModeCompliance
+	invalid := "forever"
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		put      *ObjectRetentionPut
+		expected error
+	}{
+		{
+			name: "Valid ObjectRetentionPut",
+			put: &ObjectRetentionPut{
+				BucketId:    "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId:    "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Mode:        &compliance,
+				RetainUntil: &future,
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid ObjectRetentionPut (Remove Retention)",
+			put: &ObjectRetentionPut{
+				BucketId:         "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId:         "object_01HPG4GN5JY2Z6S0638ERSG375",
+				BypassGovernance: true,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectRetentionPut (Mode Without Retain Until)",
+			put: &ObjectRetentionPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Mode:     &compliance,
+			},
+			expected: fmt.Errorf("mode and retain_until must either both be set or both be null"),
+		},
+		{
+			name: "Invalid ObjectRetentionPut (Invalid Mode)",
+			put: &ObjectRetentionPut{
+				BucketId:    "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId:    "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Mode:        &invalid,
+				RetainUntil: &future,
+			},
+			expected: fmt.Errorf("invalid retention mode 'forever'. mode must be one of 'governance' or 'compliance'"),
+		},
+		{
+			name: "Invalid ObjectRetentionPut (Retain Until In The Past)",
+			put: &ObjectRetentionPut{
+				BucketId:    "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId:    "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Mode:        &compliance,
+				RetainUntil: &past,
+			},
+			expected: fmt.Errorf("retain_until must be in the future"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.put.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
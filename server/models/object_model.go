@@ -2,28 +2,52 @@ package models
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"time"
 )
 
 const (
 	ObjectUploadStatusPending   = "pending"
 	ObjectUploadStatusCompleted = "completed"
+	// ObjectUploadStatusScanning holds uploaded content until the malware scan finds it clean
+	ObjectUploadStatusScanning = "scanning"
+	// ObjectUploadStatusQuarantined marks content the malware scan found infected, it is kept but never served
+	ObjectUploadStatusQuarantined = "quarantined"
+
+	// ObjectReplicationStatusPending marks completed content not copied to the replica of its bucket yet
+	ObjectReplicationStatusPending    = "pending"
+	ObjectReplicationStatusReplicated = "replicated"
+	// ObjectReplicationStatusFailed marks content the replication gave up on, it is only on the primary storage
+	ObjectReplicationStatusFailed = "failed"
+
+	ObjectTierHot = "hot"
+	// ObjectTierCold marks content moved to the cold storage class, it has to be restored before it can be downloaded
+	ObjectTierCold = "cold"
+	// ObjectTierRestoring marks cold content on its way back to the hot tier
+	ObjectTierRestoring = "restoring"
 
 	ObjectDefaultMimeType = "application/octet-stream"
 )
 
 type Object struct {
-	Id             string         `json:"id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
-	Version        int32          `json:"version" example:"0"`
-	BucketId       string         `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
-	Name           string         `json:"name" example:"user/david/avatar.jpg"`
-	MimeType       string         `json:"mime_type" example:"image/jpeg"`
-	Size           int64          `json:"size" example:"1218077"`
-	Metadata       map[string]any `json:"metadata" extensions:"x-nullable"`
-	UploadStatus   string         `json:"upload_status" enum:"pending,completed" example:"pending"`
-	LastAccessedAt *time.Time     `json:"last_accessed_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
-	CreatedAt      time.Time      `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
-	UpdatedAt      *time.Time     `json:"updated_at" example:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
+	Id                string         `json:"id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	Version           int32          `json:"version" example:"0"`
+	BucketId          string         `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	Name              string         `json:"name" example:"user/david/avatar.jpg"`
+	MimeType          string         `json:"mime_type" example:"image/jpeg"`
+	Size              int64          `json:"size" example:"1218077"`
+	Metadata          map[string]any `json:"metadata" extensions:"x-nullable"`
+	UploadStatus      string         `json:"upload_status" enum:"pending,scanning,completed,quarantined" example:"pending"`
+	LegalHold         bool           `json:"legal_hold" example:"false"`
+	RetentionMode     *string        `json:"retention_mode" enum:"governance,compliance" example:"compliance" extensions:"x-nullable"`
+	RetainUntil       *time.Time     `json:"retain_until" example:"2031-02-13T08:14:49.952238+05:30" extensions:"x-nullable"`
+	ReplicationStatus *string        `json:"replication_status" enum:"pending,replicated,failed" example:"replicated" extensions:"x-nullable"`
+	ReplicatedAt      *time.Time     `json:"replicated_at" example:"2024-02-13T08:14:52.952238+05:30" extensions:"x-nullable"`
+	Tier              string         `json:"tier" enum:"hot,cold,restoring" example:"hot"`
+	LastAccessedAt    *time.Time     `json:"last_accessed_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+	CreatedAt         time.Time      `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	UpdatedAt         *time.Time     `json:"updated_at" example:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
 }
 
 type PreSignedUploadSession struct {
@@ -73,3 +97,396 @@ func (p *PreSignedUploadSessionCreate) IsValid() error {
 	}
 	return nil
 }
+
+// ObjectPut writes a whole object in one request, it is how the s3 gateway uploads objects
+type ObjectPut struct {
+	BucketId string         `json:"bucket_id"`
+	Name     string         `json:"name"`
+	MimeType *string        `json:"mime_type"`
+	Size     int64          `json:"size"`
+	Metadata map[string]any `json:"metadata"`
+	Content  io.Reader      `json:"-"`
+}
+
+func (p *ObjectPut) IsValid() error {
+	if !IsNotEmptyTrimmedString(p.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to put an object")
+	}
+
+	if !IsValidObjectName(p.Name) {
+		return fmt.Errorf("invalid object name '%s'. object name cannot start or end with '/' and must be between 1 and 961 characters", p.Name)
+	}
+
+	if p.MimeType != nil && !IsValidMimeType(*p.MimeType) {
+		return fmt.Errorf("invalid mime type '%s'. mime type must be in the format 'type/subtype'", *p.MimeType)
+	}
+
+	if p.Size <= 0 {
+		return fmt.Errorf("object size must be greater than 0")
+	}
+
+	return nil
+}
+
+// StoredObject is an object along with the etag storage assigned to its content
+type StoredObject struct {
+	Object
+	ETag string `json:"etag"`
+}
+
+// ObjectContent streams the content of an object, the caller must close Body
+type ObjectContent struct {
+	StoredObject
+	ContentLength int64         `json:"content_length"`
+	ContentRange  *string       `json:"content_range"`
+	Body          io.ReadCloser `json:"-"`
+}
+
+// ObjectCopy copies an object server side. the copy keeps the mime type and metadata of the source unless
+// ReplaceMetadata is set, a nil MimeType is then inferred from the destination name
+type ObjectCopy struct {
+	SourceBucketId      string         `json:"source_bucket_id"`
+	SourceName          string         `json:"source_name"`
+	DestinationBucketId string         `json:"destination_bucket_id"`
+	DestinationName     string         `json:"destination_name"`
+	ReplaceMetadata     bool           `json:"replace_metadata"`
+	MimeType            *string        `json:"mime_type"`
+	Metadata            map[string]any `json:"metadata"`
+}
+
+func (c *ObjectCopy) IsValid() error {
+	if !IsNotEmptyTrimmedString(c.SourceBucketId) || !IsNotEmptyTrimmedString(c.DestinationBucketId) {
+		return fmt.Errorf("source and destination bucket ids cannot be empty. both are required to copy an object")
+	}
+
+	if !IsNotEmptyTrimmedString(c.SourceName) {
+		return fmt.Errorf("source object name cannot be empty. source object name is required to copy an object")
+	}
+
+	if !IsValidObjectName(c.DestinationName) {
+		return fmt.Errorf("invalid object name '%s'. object name cannot start or end with '/' and must be between 1 and 961 characters", c.DestinationName)
+	}
+
+	if c.MimeType != nil && !IsValidMimeType(*c.MimeType) {
+		return fmt.Errorf("invalid mime type '%s'. mime type must be in the format 'type/subtype'", *c.MimeType)
+	}
+
+	return nil
+}
+
+type MultipartUploadCreate struct {
+	BucketId string         `json:"bucket_id"`
+	Name     string         `json:"name"`
+	MimeType *string        `json:"mime_type"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+func (m *MultipartUploadCreate) IsValid() error {
+	if !IsNotEmptyTrimmedString(m.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to create a multipart upload")
+	}
+
+	if !IsValidObjectName(m.Name) {
+		return fmt.Errorf("invalid object name '%s'. object name cannot start or end with '/' and must be between 1 and 961 characters", m.Name)
+	}
+
+	if m.MimeType != nil && !IsValidMimeType(*m.MimeType) {
+		return fmt.Errorf("invalid mime type '%s'. mime type must be in the format 'type/subtype'", *m.MimeType)
+	}
+
+	return nil
+}
+
+type MultipartUploadPart struct {
+	BucketId   string    `json:"bucket_id"`
+	Name       string    `json:"name"`
+	UploadId   string    `json:"upload_id"`
+	PartNumber int32     `json:"part_number"`
+	Size       int64     `json:"size"`
+	Content    io.Reader `json:"-"`
+}
+
+func (m *MultipartUploadPart) IsValid() error {
+	if !IsNotEmptyTrimmedString(m.UploadId) {
+		return fmt.Errorf("upload id cannot be empty. upload id is required to upload a part")
+	}
+
+	if m.PartNumber < 1 || m.PartNumber > 10000 {
+		return fmt.Errorf("part number must be between 1 and 10000")
+	}
+
+	if m.Size <= 0 {
+		return fmt.Errorf("part size must be greater than 0")
+	}
+
+	return nil
+}
+
+type MultipartUploadComplete struct {
+	BucketId string                         `json:"bucket_id"`
+	Name     string                         `json:"name"`
+	UploadId string                         `json:"upload_id"`
+	Parts    []MultipartUploadCompletedPart `json:"parts"`
+}
+
+type MultipartUploadCompletedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+func (m *MultipartUploadComplete) IsValid() error {
+	if !IsNotEmptyTrimmedString(m.UploadId) {
+		return fmt.Errorf("upload id cannot be empty. upload id is required to complete a multipart upload")
+	}
+
+	if len(m.Parts) == 0 {
+		return fmt.Errorf("at least one part is required to complete a multipart upload")
+	}
+
+	for i, part := range m.Parts {
+		if i > 0 && part.PartNumber <= m.Parts[i-1].PartNumber {
+			return fmt.Errorf("parts must be listed in ascending order of part number")
+		}
+	}
+
+	return nil
+}
+
+const (
+	ObjectRenderMaxDimension = 4096
+
+	ObjectRenderFitContain = "contain"
+	ObjectRenderFitCover   = "cover"
+	ObjectRenderFitFill    = "fill"
+)
+
+// ObjectRender asks for an image object resized, cropped or converted, the crop is given in source pixels and applied
+// before the resize
+type ObjectRender struct {
+	BucketId   string  `json:"-" params:"bucket_id"`
+	ObjectId   string  `json:"-" params:"object_id"`
+	Width      *int    `query:"width"`
+	Height     *int    `query:"height"`
+	Fit        *string `query:"fit"`
+	CropX      *int    `query:"crop_x"`
+	CropY      *int    `query:"crop_y"`
+	CropWidth  *int    `query:"crop_width"`
+	CropHeight *int    `query:"crop_height"`
+	Format     *string `query:"format"`
+	Quality    *int    `query:"quality"`
+}
+
+func (r *ObjectRender) IsValid() error {
+	if !IsNotEmptyTrimmedString(r.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to render an object")
+	}
+
+	if !IsNotEmptyTrimmedString(r.ObjectId) {
+		return fmt.Errorf("object id cannot be empty. object id is required to render an object")
+	}
+
+	if r.Width != nil && (*r.Width < 1 || *r.Width > ObjectRenderMaxDimension) {
+		return fmt.Errorf("width must be between 1 and %d", ObjectRenderMaxDimension)
+	}
+
+	if r.Height != nil && (*r.Height < 1 || *r.Height > ObjectRenderMaxDimension) {
+		return fmt.Errorf("height must be between 1 and %d", ObjectRenderMaxDimension)
+	}
+
+	if r.Fit != nil {
+		switch *r.Fit {
+		case ObjectRenderFitContain:
+		case ObjectRenderFitCover, ObjectRenderFitFill:
+			if r.Width == nil || r.Height == nil {
+				return fmt.Errorf("fit '%s' requires both width and height", *r.Fit)
+			}
+		default:
+			return fmt.Errorf("invalid fit '%s'. fit must be one of 'contain', 'cover' or 'fill'", *r.Fit)
+		}
+	}
+
+	crop := []*int{r.CropX, r.CropY, r.CropWidth, r.CropHeight}
+	set := 0
+	for _, value := range crop {
+		if value != nil {
+			set++
+		}
+	}
+	if set != 0 && set != len(crop) {
+		return fmt.Errorf("crop_x, crop_y, crop_width and crop_height must be given together")
+	}
+	if set != 0 && (*r.CropX < 0 || *r.CropY < 0 || *r.CropWidth < 1 || *r.CropHeight < 1) {
+		return fmt.Errorf("crop_x and crop_y cannot be negative and crop_width and crop_height must be greater than 0")
+	}
+
+	if r.Format != nil && *r.Format != "jpeg" && *r.Format != "png" && *r.Format != "webp" {
+		return fmt.Errorf("invalid format '%s'. format must be one of 'jpeg', 'png' or 'webp'", *r.Format)
+	}
+
+	if r.Quality != nil && (*r.Quality < 1 || *r.Quality > 100) {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// RenderedObject streams a rendered image, the caller must close Body
+type RenderedObject struct {
+	MimeType      string        `json:"mime_type"`
+	ContentLength int64         `json:"content_length"`
+	ETag          string        `json:"etag"`
+	Body          io.ReadCloser `json:"-"`
+}
+
+const (
+	ObjectMetadataModeReplace = "replace"
+	ObjectMetadataModeMerge   = "merge"
+)
+
+type ObjectUpdate struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	Id       string `json:"-" params:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`version` is the version of the object the update is based on. the update is refused with a conflict when the
+	//	object changed since, get the object again and retry with its new version
+	Version *int32 `json:"version" example:"3"`
+	//	`mime_type` if set to `null` the mime type is left unchanged, it must be allowed by the bucket
+	MimeType *string `json:"mime_type" example:"image/jpeg" extensions:"x-nullable"`
+	//	`metadata` if set to `null` the metadata is left unchanged
+	Metadata map[string]any `json:"metadata" extensions:"x-nullable"`
+	//	`metadata_mode` is `replace` to replace the metadata with `metadata`, which is the default,
+	//	or `merge` to set the top level keys of `metadata` and keep the others
+	MetadataMode string `json:"metadata_mode" enum:"replace,merge" example:"merge"`
+}
+
+func (u *ObjectUpdate) IsValid() error {
+	if !IsNotEmptyTrimmedString(u.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to update an object")
+	}
+
+	if !IsNotEmptyTrimmedString(u.Id) {
+		return fmt.Errorf("object id cannot be empty. object id is required to update an object")
+	}
+
+	if u.Version == nil {
+		return fmt.Errorf("version cannot be empty. the version the update is based on is required to update an object")
+	}
+
+	if u.MimeType != nil && !IsValidMimeType(*u.MimeType) {
+		return fmt.Errorf("invalid mime type '%s'. mime type must be in the format 'type/subtype'", *u.MimeType)
+	}
+
+	if u.MetadataMode != "" && u.MetadataMode != ObjectMetadataModeReplace && u.MetadataMode != ObjectMetadataModeMerge {
+		return fmt.Errorf("invalid metadata_mode '%s'. metadata_mode must be one of 'replace' or 'merge'", u.MetadataMode)
+	}
+
+	if u.MimeType == nil && u.Metadata == nil {
+		return fmt.Errorf("nothing to update. at least one of mime_type or metadata is required to update an object")
+	}
+
+	return nil
+}
+
+func (u *ObjectUpdate) PreSave() {
+	if u.MetadataMode == "" {
+		u.MetadataMode = ObjectMetadataModeReplace
+	}
+}
+
+const (
+	ObjectMetadataSearchMaxConditions = 20
+
+	ObjectMetadataOperatorEqual          = "eq"
+	ObjectMetadataOperatorNotEqual       = "ne"
+	ObjectMetadataOperatorGreater        = "gt"
+	ObjectMetadataOperatorGreaterOrEqual = "gte"
+	ObjectMetadataOperatorLess           = "lt"
+	ObjectMetadataOperatorLessOrEqual    = "lte"
+	ObjectMetadataOperatorExists         = "exists"
+	ObjectMetadataOperatorNotExists      = "not_exists"
+)
+
+// ObjectMetadataSearch finds the objects of a bucket whose metadata contains a document and matches every condition
+type ObjectMetadataSearch struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`contains` matches objects whose metadata contains the document, nested objects match when they contain the
+	//	given keys and arrays when they contain the given elements
+	Contains   map[string]any            `json:"contains" extensions:"x-nullable"`
+	Conditions []ObjectMetadataCondition `json:"conditions" extensions:"x-nullable"`
+	//	`tags` matches objects carrying every one of the tags
+	Tags   map[string]string `json:"tags" example:"retention:legal" extensions:"x-nullable"`
+	Limit  int32             `json:"limit" example:"100"`
+	Offset int32             `json:"offset" example:"0"`
+}
+
+type ObjectMetadataCondition struct {
+	//	`key` names the value the condition is on, nested keys are separated by dots like `image.width`
+	Key string `json:"key" example:"image.width"`
+	//	`operator` compares the value with `value`. `gt`, `gte`, `lt` and `lte` only match values of the same json
+	//	type as `value`, `ne` also matches objects without the key and `exists` and `not_exists` ignore `value`
+	Operator string `json:"operator" enum:"eq,ne,gt,gte,lt,lte,exists,not_exists" example:"gte"`
+	Value    any    `json:"value" example:"1024"`
+}
+
+func (s *ObjectMetadataSearch) IsValid() error {
+	if !IsNotEmptyTrimmedString(s.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to search objects")
+	}
+
+	if s.Limit < 0 {
+		return fmt.Errorf("limit cannot be less than 0")
+	}
+
+	if s.Offset < 0 {
+		return fmt.Errorf("offset cannot be less than 0")
+	}
+
+	if len(s.Conditions) > ObjectMetadataSearchMaxConditions {
+		return fmt.Errorf("at most %d metadata conditions can be given", ObjectMetadataSearchMaxConditions)
+	}
+
+	for _, condition := range s.Conditions {
+		if err := condition.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Tags) > ObjectTagsMaxCount {
+		return fmt.Errorf("at most %d tags can be given", ObjectTagsMaxCount)
+	}
+
+	for key, value := range s.Tags {
+		if err := isValidTag(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ObjectMetadataCondition) IsValid() error {
+	for _, part := range strings.Split(c.Key, ".") {
+		if !IsNotEmptyTrimmedString(part) {
+			return fmt.Errorf("invalid metadata key '%s'. keys cannot be empty and nested keys are separated by single dots", c.Key)
+		}
+	}
+
+	switch c.Operator {
+	case ObjectMetadataOperatorExists, ObjectMetadataOperatorNotExists:
+	case ObjectMetadataOperatorEqual, ObjectMetadataOperatorNotEqual:
+	case ObjectMetadataOperatorGreater, ObjectMetadataOperatorGreaterOrEqual, ObjectMetadataOperatorLess, ObjectMetadataOperatorLessOrEqual:
+		switch c.Value.(type) {
+		case float64, float32, int, int32, int64, string:
+		default:
+			return fmt.Errorf("metadata operator '%s' on key '%s' requires a number or string value", c.Operator, c.Key)
+		}
+	default:
+		return fmt.Errorf("invalid metadata operator '%s'. operator must be one of 'eq', 'ne', 'gt', 'gte', 'lt', 'lte', 'exists' or 'not_exists'", c.Operator)
+	}
+
+	return nil
+}
+
+// Path splits the key of the condition into one key per level of nesting
+func (c *ObjectMetadataCondition) Path() []string {
+	return strings.Split(c.Key, ".")
+}
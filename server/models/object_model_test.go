@@ -91,3 +91,401 @@ func TestPreSignedUploadSessionCreate_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestObjectPut_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		put      *ObjectPut
+		expected error
+	}{
+		{
+			name: "Valid ObjectPut",
+			put: &ObjectPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name:     "user/david/avatar.jpg",
+				Size:     1218077,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectPut (Empty BucketId)",
+			put: &ObjectPut{
+				Name: "user/david/avatar.jpg",
+				Size: 1218077,
+			},
+			expected: fmt.Errorf("bucket id cannot be empty. bucket id is required to put an object"),
+		},
+		{
+			name: "Invalid ObjectPut (Invalid Name)",
+			put: &ObjectPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name:     "user/david/",
+				Size:     1218077,
+			},
+			expected: fmt.Errorf("invalid object name 'user/david/'. object name cannot start or end with '/' and must be between 1 and 961 characters"),
+		},
+		{
+			name: "Invalid ObjectPut (Empty Content)",
+			put: &ObjectPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Name:     "user/david/avatar.jpg",
+				Size:     0,
+			},
+			expected: fmt.Errorf("object size must be greater than 0"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.put.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestObjectCopy_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		copy     *ObjectCopy
+		expected error
+	}{
+		{
+			name: "Valid ObjectCopy",
+			copy: &ObjectCopy{
+				SourceBucketId:      "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				SourceName:          "user/david/avatar.jpg",
+				DestinationBucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				DestinationName:     "user/david/avatar-copy.jpg",
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectCopy (Empty Destination Bucket)",
+			copy: &ObjectCopy{
+				SourceBucketId:  "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				SourceName:      "user/david/avatar.jpg",
+				DestinationName: "user/david/avatar-copy.jpg",
+			},
+			expected: fmt.Errorf("source and destination bucket ids cannot be empty. both are required to copy an object"),
+		},
+		{
+			name: "Invalid ObjectCopy (Invalid MIME Type)",
+			copy: &ObjectCopy{
+				SourceBucketId:      "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				SourceName:          "user/david/avatar.jpg",
+				DestinationBucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				DestinationName:     "user/david/avatar-copy.jpg",
+				ReplaceMetadata:     true,
+				MimeType: func() *string {
+					v := "jpeg"
+					return &v
+				}(),
+			},
+			expected: fmt.Errorf("invalid mime type 'jpeg'. mime type must be in the format 'type/subtype'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.copy.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestMultipartUploadPart_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		part     *MultipartUploadPart
+		expected error
+	}{
+		{
+			name:     "Valid MultipartUploadPart",
+			part:     &MultipartUploadPart{UploadId: "upload", PartNumber: 1, Size: 5242880},
+			expected: nil,
+		},
+		{
+			name:     "Invalid MultipartUploadPart (Part Number Too Large)",
+			part:     &MultipartUploadPart{UploadId: "upload", PartNumber: 10001, Size: 5242880},
+			expected: fmt.Errorf("part number must be between 1 and 10000"),
+		},
+		{
+			name:     "Invalid MultipartUploadPart (Empty UploadId)",
+			part:     &MultipartUploadPart{PartNumber: 1, Size: 5242880},
+			expected: fmt.Errorf("upload id cannot be empty. upload id is required to upload a part"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.part.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestMultipartUploadComplete_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		complete *MultipartUploadComplete
+		expected error
+	}{
+		{
+			name: "Valid MultipartUploadComplete",
+			complete: &MultipartUploadComplete{
+				UploadId: "upload",
+				Parts:    []MultipartUploadCompletedPart{{PartNumber: 1, ETag: `"a"`}, {PartNumber: 3, ETag: `"b"`}},
+			},
+			expected: nil,
+		},
+		{
+			name:     "Invalid MultipartUploadComplete (No Parts)",
+			complete: &MultipartUploadComplete{UploadId: "upload"},
+			expected: fmt.Errorf("at least one part is required to complete a multipart upload"),
+		},
+		{
+			name: "Invalid MultipartUploadComplete (Parts Out Of Order)",
+			complete: &MultipartUploadComplete{
+				UploadId: "upload",
+				Parts:    []MultipartUploadCompletedPart{{PartNumber: 2, ETag: `"a"`}, {PartNumber: 1, ETag: `"b"`}},
+			},
+			expected: fmt.Errorf("parts must be listed in ascending order of part number"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.complete.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestObjectRender_IsValid(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	stringPtr := func(v string) *string { return &v }
+
+	tests := []struct {
+		name     string
+		render   *ObjectRender
+		expected error
+	}{
+		{
+			name: "Valid ObjectRender",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Width:    intPtr(320),
+				Height:   intPtr(240),
+				Fit:      stringPtr(ObjectRenderFitCover),
+				Format:   stringPtr("webp"),
+				Quality:  intPtr(75),
+			},
+			expected: nil,
+		},
+		{
+			name:     "Invalid ObjectRender (Empty ObjectId)",
+			render:   &ObjectRender{BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375"},
+			expected: fmt.Errorf("object id cannot be empty. object id is required to render an object"),
+		},
+		{
+			name: "Invalid ObjectRender (Width Too Large)",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Width:    intPtr(5000),
+			},
+			expected: fmt.Errorf("width must be between 1 and 4096"),
+		},
+		{
+			name: "Invalid ObjectRender (Cover Without Height)",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Width:    intPtr(320),
+				Fit:      stringPtr(ObjectRenderFitCover),
+			},
+			expected: fmt.Errorf("fit 'cover' requires both width and height"),
+		},
+		{
+			name: "Invalid ObjectRender (Partial Crop)",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				CropX:    intPtr(0),
+				CropY:    intPtr(0),
+			},
+			expected: fmt.Errorf("crop_x, crop_y, crop_width and crop_height must be given together"),
+		},
+		{
+			name: "Invalid ObjectRender (Unsupported Format)",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Format:   stringPtr("gif"),
+			},
+			expected: fmt.Errorf("invalid format 'gif'. format must be one of 'jpeg', 'png' or 'webp'"),
+		},
+		{
+			name: "Invalid ObjectRender (Quality Out Of Range)",
+			render: &ObjectRender{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Quality:  intPtr(0),
+			},
+			expected: fmt.Errorf("quality must be between 1 and 100"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.render.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestObjectUpdate_IsValid(t *testing.T) {
+	int32Ptr := func(v int32) *int32 { return &v }
+	stringPtr := func(v string) *string { return &v }
+
+	tests := []struct {
+		name     string
+		update   *ObjectUpdate
+		expected error
+	}{
+		{
+			name: "Valid ObjectUpdate (Metadata)",
+			update: &ObjectUpdate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:       "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Version:  int32Ptr(1),
+				Metadata: map[string]any{"owner": "tea"},
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid ObjectUpdate (Mime Type Merge)",
+			update: &ObjectUpdate{
+				BucketId:     "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:           "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Version:      int32Ptr(1),
+				MimeType:     stringPtr("image/png"),
+				MetadataMode: ObjectMetadataModeMerge,
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectUpdate (Missing Version)",
+			update: &ObjectUpdate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:       "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Metadata: map[string]any{"owner": "tea"},
+			},
+			expected: fmt.Errorf("version cannot be empty. the version the update is based on is required to update an object"),
+		},
+		{
+			name: "Invalid ObjectUpdate (Invalid Mime Type)",
+			update: &ObjectUpdate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:       "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Version:  int32Ptr(1),
+				MimeType: stringPtr("png"),
+			},
+			expected: fmt.Errorf("invalid mime type 'png'. mime type must be in the format 'type/subtype'"),
+		},
+		{
+			name: "Invalid ObjectUpdate (Invalid Metadata Mode)",
+			update: &ObjectUpdate{
+				BucketId:     "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:           "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Version:      int32Ptr(1),
+				Metadata:     map[string]any{"owner": "tea"},
+				MetadataMode: "append",
+			},
+			expected: fmt.Errorf("invalid metadata_mode 'append'. metadata_mode must be one of 'replace' or 'merge'"),
+		},
+		{
+			name: "Invalid ObjectUpdate (Nothing To Update)",
+			update: &ObjectUpdate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Id:       "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Version:  int32Ptr(1),
+			},
+			expected: fmt.Errorf("nothing to update. at least one of mime_type or metadata is required to update an object"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.update.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestObjectMetadataSearch_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		search   *ObjectMetadataSearch
+		expected error
+	}{
+		{
+			name: "Valid ObjectMetadataSearch",
+			search: &ObjectMetadataSearch{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Contains: map[string]any{"owner": "tea"},
+				Conditions: []ObjectMetadataCondition{
+					{Key: "image.width", Operator: ObjectMetadataOperatorGreaterOrEqual, Value: float64(1024)},
+					{Key: "reviewed", Operator: ObjectMetadataOperatorExists},
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectMetadataSearch (Empty Key Part)",
+			search: &ObjectMetadataSearch{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Conditions: []ObjectMetadataCondition{
+					{Key: "image..width", Operator: ObjectMetadataOperatorEqual, Value: float64(1024)},
+				},
+			},
+			expected: fmt.Errorf("invalid metadata key 'image..width'. keys cannot be empty and nested keys are separated by single dots"),
+		},
+		{
+			name: "Invalid ObjectMetadataSearch (Ordering On Object)",
+			search: &ObjectMetadataSearch{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Conditions: []ObjectMetadataCondition{
+					{Key: "image", Operator: ObjectMetadataOperatorLess, Value: map[string]any{"width": float64(1)}},
+				},
+			},
+			expected: fmt.Errorf("metadata operator 'lt' on key 'image' requires a number or string value"),
+		},
+		{
+			name: "Invalid ObjectMetadataSearch (Unknown Operator)",
+			search: &ObjectMetadataSearch{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Conditions: []ObjectMetadataCondition{
+					{Key: "owner", Operator: "like", Value: "tea"},
+				},
+			},
+			expected: fmt.Errorf("invalid metadata operator 'like'. operator must be one of 'eq', 'ne', 'gt', 'gte', 'lt', 'lte', 'exists' or 'not_exists'"),
+		},
+		{
+			name: "Invalid ObjectMetadataSearch (Negative Limit)",
+			search: &ObjectMetadataSearch{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				Limit:    -1,
+			},
+			expected: fmt.Errorf("limit cannot be less than 0"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.search.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
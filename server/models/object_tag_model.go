@@ -0,0 +1,154 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ObjectTagsMaxCount      = 10
+	ObjectTagKeyMaxLength   = 128
+	ObjectTagValueMaxLength = 256
+
+	// TagRuleActionDenyDelete refuses to delete or overwrite objects carrying the tag, buckets holding any of them
+	// cannot be emptied or deleted
+	TagRuleActionDenyDelete = "deny_delete"
+	// TagRuleActionExpire deletes objects carrying the tag once they are older than the expiry of the rule
+	TagRuleActionExpire = "expire"
+)
+
+// ObjectTags are the key value pairs an object is classified by, unlike metadata they are kept in their own indexed
+// table so objects can be listed by them and rules can select objects by them
+type ObjectTags struct {
+	ObjectId string            `json:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	Tags     map[string]string `json:"tags" example:"retention:legal"`
+}
+
+type ObjectTagsPut struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	ObjectId string `json:"-" params:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`tags` replace every tag of the object, an empty object removes them all. at most 10 tags are allowed,
+	//	keys cannot be empty and are at most 128 characters long, values are at most 256 characters long
+	Tags map[string]string `json:"tags" example:"retention:legal"`
+}
+
+func (p *ObjectTagsPut) IsValid() error {
+	if !IsNotEmptyTrimmedString(p.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to tag an object")
+	}
+
+	if !IsNotEmptyTrimmedString(p.ObjectId) {
+		return fmt.Errorf("object id cannot be empty. object id is required to tag an object")
+	}
+
+	if len(p.Tags) > ObjectTagsMaxCount {
+		return fmt.Errorf("an object can have at most %d tags", ObjectTagsMaxCount)
+	}
+
+	for key, value := range p.Tags {
+		if err := isValidTag(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseTagFilters parses `key=value` filters into the tags listed objects must carry
+func ParseTagFilters(filters []string) (map[string]string, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	if len(filters) > ObjectTagsMaxCount {
+		return nil, fmt.Errorf("at most %d tag filters can be given", ObjectTagsMaxCount)
+	}
+
+	tags := make(map[string]string, len(filters))
+
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag filter '%s'. tag filters must be in the format 'key=value'", filter)
+		}
+
+		if err := isValidTag(key, value); err != nil {
+			return nil, err
+		}
+
+		if existing, ok := tags[key]; ok && existing != value {
+			return nil, fmt.Errorf("tag '%s' is filtered by more than one value", key)
+		}
+
+		tags[key] = value
+	}
+
+	return tags, nil
+}
+
+func isValidTag(key string, value string) error {
+	if !IsNotEmptyTrimmedString(key) {
+		return fmt.Errorf("tag key cannot be empty")
+	}
+
+	if utf8.RuneCountInString(key) > ObjectTagKeyMaxLength {
+		return fmt.Errorf("tag key '%s' is too long. tag keys can be at most %d characters long", key, ObjectTagKeyMaxLength)
+	}
+
+	if utf8.RuneCountInString(value) > ObjectTagValueMaxLength {
+		return fmt.Errorf("value of tag '%s' is too long. tag values can be at most %d characters long", key, ObjectTagValueMaxLength)
+	}
+
+	return nil
+}
+
+// TagRule applies an action to the objects of a bucket that carry a tag
+type TagRule struct {
+	Id              string    `json:"id" example:"tagrule_01HPG4GN5JY2Z6S0638ERSG375"`
+	BucketId        string    `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	TagKey          string    `json:"tag_key" example:"retention"`
+	TagValue        string    `json:"tag_value" example:"legal"`
+	Action          string    `json:"action" enum:"deny_delete,expire" example:"deny_delete"`
+	ExpireAfterDays *int32    `json:"expire_after_days" example:"30" extensions:"x-nullable"`
+	CreatedAt       time.Time `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+}
+
+type TagRuleCreate struct {
+	BucketId string `json:"-" params:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	//	`tag_key` and `tag_value` select the objects of the bucket carrying the tag
+	TagKey   string `json:"tag_key" example:"retention"`
+	TagValue string `json:"tag_value" example:"legal"`
+	//	`action` is `deny_delete` to refuse deleting or overwriting the selected objects and emptying or deleting
+	//	the bucket while it holds any, or `expire` to delete the selected objects `expire_after_days` days after
+	//	they were created. an object selected by both is kept
+	Action string `json:"action" enum:"deny_delete,expire" example:"deny_delete"`
+	//	`expire_after_days` is required by `expire` rules and must be `null` for the others
+	ExpireAfterDays *int32 `json:"expire_after_days" example:"30" extensions:"x-nullable"`
+}
+
+func (c *TagRuleCreate) IsValid() error {
+	if !IsNotEmptyTrimmedString(c.BucketId) {
+		return fmt.Errorf("bucket id cannot be empty. bucket id is required to create a tag rule")
+	}
+
+	if err := isValidTag(c.TagKey, c.TagValue); err != nil {
+		return err
+	}
+
+	switch c.Action {
+	case TagRuleActionDenyDelete:
+		if c.ExpireAfterDays != nil {
+			return fmt.Errorf("expire_after_days can only be set on '%s' rules", TagRuleActionExpire)
+		}
+	case TagRuleActionExpire:
+		if c.ExpireAfterDays == nil || *c.ExpireAfterDays < 1 {
+			return fmt.Errorf("expire_after_days must be at least 1 for '%s' rules", TagRuleActionExpire)
+		}
+	default:
+		return fmt.Errorf("invalid action '%s'. action must be one of '%s' or '%s'", c.Action, TagRuleActionDenyDelete, TagRuleActionExpire)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectTagsPut_IsValid(t *testing.T) {
+	tooMany := make(map[string]string, ObjectTagsMaxCount+1)
+	for i := 0; i <= ObjectTagsMaxCount; i++ {
+		tooMany[fmt.Sprintf("key-%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name     string
+		put      *ObjectTagsPut
+		expected error
+	}{
+		{
+			name: "Valid ObjectTagsPut",
+			put: &ObjectTagsPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Tags:     map[string]string{"retention": "legal", "reviewed": ""},
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid ObjectTagsPut (No Tags)",
+			put: &ObjectTagsPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid ObjectTagsPut (Too Many Tags)",
+			put: &ObjectTagsPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Tags:     tooMany,
+			},
+			expected: fmt.Errorf("an object can have at most 10 tags"),
+		},
+		{
+			name: "Invalid ObjectTagsPut (Empty Key)",
+			put: &ObjectTagsPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Tags:     map[string]string{" ": "legal"},
+			},
+			expected: fmt.Errorf("tag key cannot be empty"),
+		},
+		{
+			name: "Invalid ObjectTagsPut (Value Too Long)",
+			put: &ObjectTagsPut{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				ObjectId: "object_01HPG4GN5JY2Z6S0638ERSG375",
+				Tags:     map[string]string{"retention": strings.Repeat("a", ObjectTagValueMaxLength+1)},
+			},
+			expected: fmt.Errorf("value of tag 'retention' is too long. tag values can be at most 256 characters long"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.put.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestParseTagFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     []string
+		expected    map[string]string
+		expectedErr error
+	}{
+		{
+			name:     "No Filters",
+			filters:  nil,
+			expected: nil,
+		},
+		{
+			name:     "Filters",
+			filters:  []string{"retention=legal", "team=finance=eu", "reviewed="},
+			expected: map[string]string{"retention": "legal", "team": "finance=eu", "reviewed": ""},
+		},
+		{
+			name:        "Missing Separator",
+			filters:     []string{"retention"},
+			expectedErr: fmt.Errorf("invalid tag filter 'retention'. tag filters must be in the format 'key=value'"),
+		},
+		{
+			name:        "Conflicting Values",
+			filters:     []string{"retention=legal", "retention=none"},
+			expectedErr: fmt.Errorf("tag 'retention' is filtered by more than one value"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ParseTagFilters(tt.filters)
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expected, tags)
+		})
+	}
+}
+
+func TestTagRuleCreate_IsValid(t *testing.T) {
+	int32Ptr := func(v int32) *int32 { return &v }
+
+	tests := []struct {
+		name     string
+		create   *TagRuleCreate
+		expected error
+	}{
+		{
+			name: "Valid TagRuleCreate (Deny Delete)",
+			create: &TagRuleCreate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				TagKey:   "retention",
+				TagValue: "legal",
+				Action:   TagRuleActionDenyDelete,
+			},
+			expected: nil,
+		},
+		{
+			name: "Valid TagRuleCreate (Expire)",
+			create: &TagRuleCreate{
+				BucketId:        "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				TagKey:          "retention",
+				TagValue:        "temporary",
+				Action:          TagRuleActionExpire,
+				ExpireAfterDays: int32Ptr(30),
+			},
+			expected: nil,
+		},
+		{
+			name: "Invalid TagRuleCreate (Expire Without Days)",
+			create: &TagRuleCreate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				TagKey:   "retention",
+				TagValue: "temporary",
+				Action:   TagRuleActionExpire,
+			},
+			expected: fmt.Errorf("expire_after_days must be at least 1 for 'expire' rules"),
+		},
+		{
+			name: "Invalid TagRuleCreate (Deny Delete With Days)",
+			create: &TagRuleCreate{
+				BucketId:        "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				TagKey:          "retention",
+				TagValue:        "legal",
+				Action:          TagRuleActionDenyDelete,
+				ExpireAfterDays: int32Ptr(30),
+			},
+			expected: fmt.Errorf("expire_after_days can only be set on 'expire' rules"),
+		},
+		{
+			name: "Invalid TagRuleCreate (Unknown Action)",
+			create: &TagRuleCreate{
+				BucketId: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+				TagKey:   "retention",
+				TagValue: "legal",
+				Action:   "archive",
+			},
+			expected: fmt.Errorf("invalid action 'archive'. action must be one of 'deny_delete' or 'expire'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.create.IsValid()
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
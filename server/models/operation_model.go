@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	OperationTypeBucketEmpty    = "bucket.empty"
+	OperationTypeBucketDelete   = "bucket.delete"
+	OperationTypeBucketImport   = "bucket.import"
+	OperationTypeBucketExport   = "bucket.export"
+	OperationTypeBucketRestore  = "bucket.restore"
+	OperationTypeBucketRelocate = "bucket.relocate"
+
+	OperationStateQueued     = "queued"
+	OperationStateRunning    = "running"
+	OperationStateCancelling = "cancelling"
+	OperationStateSucceeded  = "succeeded"
+	OperationStateFailed     = "failed"
+	OperationStateCancelled  = "cancelled"
+)
+
+// Operation is a long running change to a bucket that is worked in the background. it follows the job working it
+// until it finishes and keeps its outcome after the job is gone
+type Operation struct {
+	Id       string `json:"id" example:"operation_01HPG4GN5JY2Z6S0638ERSG375"`
+	Type     string `json:"type" enum:"bucket.empty,bucket.delete,bucket.import,bucket.export,bucket.restore,bucket.relocate" example:"bucket.empty"`
+	BucketId string `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	JobId    int64  `json:"job_id" example:"1024"`
+	//	`state` is `cancelling` while a running operation is asked to stop and has not stopped yet
+	State    string       `json:"state" enum:"queued,running,cancelling,succeeded,failed,cancelled" example:"running"`
+	Progress *JobProgress `json:"progress" extensions:"x-nullable"`
+	//	`errors` are the errors of the attempts of the job, the last one is why a failed operation failed
+	Errors            []*JobError `json:"errors"`
+	CreatedAt         time.Time   `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	StartedAt         *time.Time  `json:"started_at" example:"2024-02-13T08:16:49.952238+05:30" extensions:"x-nullable"`
+	CancelRequestedAt *time.Time  `json:"cancel_requested_at" example:"2024-02-13T08:17:02.952238+05:30" extensions:"x-nullable"`
+	FinishedAt        *time.Time  `json:"finished_at" example:"2024-02-13T08:18:21.47635+05:30" extensions:"x-nullable"`
+}
+
+// OperationLocksBucket tells whether operations of operationType lock their bucket until they finish
+func OperationLocksBucket(operationType string) bool {
+	return operationType == OperationTypeBucketEmpty || operationType == OperationTypeBucketDelete ||
+		operationType == OperationTypeBucketRestore || operationType == OperationTypeBucketRelocate
+}
+
+// IsFinishedOperationState tells whether an operation in state is done for good
+func IsFinishedOperationState(state string) bool {
+	return state == OperationStateSucceeded || state == OperationStateFailed || state == OperationStateCancelled
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	// ReconciliationPolicyReport only reports what the reconciliation found, ReconciliationPolicyDelete deletes it
+	ReconciliationPolicyReport = "report"
+	ReconciliationPolicyDelete = "delete"
+
+	// ReconciliationMaxReportEntries bounds the orphans and missing objects a report lists, the counts cover all of them
+	ReconciliationMaxReportEntries = 1000
+
+	AuditActionStorageReconciliation = "storage.reconciliation"
+)
+
+// ReconciliationReport compares the content of a bucket in storage with its catalog. orphans are content without an
+// object, missing objects are completed objects whose content is gone. anything changed within the grace period is
+// left out since uploads and deletions in flight look the same for a moment
+type ReconciliationReport struct {
+	BucketId   string `json:"bucket_id" example:"bucket_01HPG4GN5JY2Z6S0638ERSG375"`
+	BucketName string `json:"bucket_name" example:"avatar"`
+	//	`dry_run` reports are never fixed, the policies only tell what a scheduled run would fix
+	DryRun         bool                     `json:"dry_run" example:"true"`
+	OrphanPolicy   string                   `json:"orphan_policy" enum:"report,delete" example:"report"`
+	MissingPolicy  string                   `json:"missing_policy" enum:"report,delete" example:"report"`
+	StorageObjects int64                    `json:"storage_objects" example:"1024"`
+	CatalogObjects int64                    `json:"catalog_objects" example:"1022"`
+	OrphanCount    int64                    `json:"orphan_count" example:"3"`
+	MissingCount   int64                    `json:"missing_count" example:"1"`
+	OrphansDeleted int64                    `json:"orphans_deleted" example:"0"`
+	MissingDeleted int64                    `json:"missing_deleted" example:"0"`
+	Orphans        []*ReconciliationOrphan  `json:"orphans"`
+	Missing        []*ReconciliationMissing `json:"missing"`
+	StartedAt      time.Time                `json:"started_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	FinishedAt     time.Time                `json:"finished_at" example:"2024-02-13T08:14:51.47635+05:30"`
+}
+
+type ReconciliationOrphan struct {
+	Name         string     `json:"name" example:"avatars/01HPG4GN5JY2Z6S0638ERSG375.png"`
+	Size         int64      `json:"size" example:"1048576"`
+	LastModified *time.Time `json:"last_modified" example:"2024-02-13T08:14:49.952238+05:30" extensions:"x-nullable"`
+}
+
+type ReconciliationMissing struct {
+	ObjectId     string    `json:"object_id" example:"object_01HPG4GN5JY2Z6S0638ERSG375"`
+	Name         string    `json:"name" example:"avatars/01HPG4GN5JY2Z6S0638ERSG375.png"`
+	UploadStatus string    `json:"upload_status" enum:"scanning,completed,quarantined" example:"completed"`
+	CreatedAt    time.Time `json:"created_at" example:"2024-02-13T08:14:49.952238+05:30"`
+	//	`kept` objects are under a legal hold or retention, their rows are never deleted by the reconciliation
+	Kept bool `json:"kept" example:"false"`
+}
@@ -0,0 +1,667 @@
+// Package generator builds the openapi 3 document from the swag style annotations on the controllers,
+// the general api info on main and the struct tags of the models referenced by the annotations
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/samber/lo"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	openApiVersion = "3.0.3"
+
+	schemaRefPrefix = "#/components/schemas/"
+)
+
+var (
+	paramPattern    = regexp.MustCompile(`^(\S+)\s+(path|query|header|body)\s+(\S+)\s+(true|false)(?:\s+"([^"]*)")?$`)
+	responsePattern = regexp.MustCompile(`^(\d{3})(?:\s+\{(object|array)\}\s+(\S+))?(?:\s+"([^"]*)")?$`)
+	routerPattern   = regexp.MustCompile(`^(\S+)\s+\[(\w+)\]$`)
+	pathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+	mimeTypeAliases = map[string]string{
+		"json":         "application/json",
+		"html":         "text/html",
+		"plain":        "text/plain",
+		"octet-stream": "application/octet-stream",
+	}
+)
+
+type generator struct {
+	root     string
+	fileSet  *token.FileSet
+	types    map[string]map[string]*ast.TypeSpec
+	document *Document
+}
+
+// Generate reads main.go and the controllers package under root and returns the openapi document they describe
+func Generate(root string) (*Document, error) {
+	g := &generator{
+		root:    root,
+		fileSet: token.NewFileSet(),
+		types:   make(map[string]map[string]*ast.TypeSpec),
+		document: &Document{
+			OpenApi: openApiVersion,
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				Schemas:         make(map[string]*Schema),
+				SecuritySchemes: make(map[string]*SecurityScheme),
+			},
+		},
+	}
+
+	if err := g.parseGeneralInfo(filepath.Join(root, "main.go")); err != nil {
+		return nil, err
+	}
+
+	if err := g.parseOperations(filepath.Join(root, "controllers")); err != nil {
+		return nil, err
+	}
+
+	return g.document, nil
+}
+
+// Render generates the document under root and encodes it the way it is committed to openapi/openapi.json
+func Render(root string) ([]byte, error) {
+	document, err := Generate(root)
+	if err != nil {
+		return nil, err
+	}
+
+	documentBytes, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(documentBytes, '\n'), nil
+}
+
+func (g *generator) parseGeneralInfo(path string) error {
+	file, err := parser.ParseFile(g.fileSet, path, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	var securityScheme *SecurityScheme
+	for _, commentGroup := range file.Comments {
+		for _, line := range annotationLines(commentGroup) {
+			attribute, value := splitAnnotation(line)
+			switch attribute {
+			case "@title":
+				g.document.Info.Title = value
+			case "@version":
+				g.document.Info.Version = value
+			case "@description":
+				g.document.Info.Description = value
+			case "@license.name":
+				g.document.Info.License = &License{Name: value}
+			case "@securitydefinitions.apikey":
+				securityScheme = &SecurityScheme{Type: "apiKey"}
+				g.document.Components.SecuritySchemes[value] = securityScheme
+			case "@in", "@name":
+				if securityScheme == nil {
+					return fmt.Errorf("%s: %s must follow a @securityDefinitions annotation", path, attribute)
+				}
+				if attribute == "@in" {
+					securityScheme.In = value
+				} else {
+					securityScheme.Name = value
+				}
+			}
+		}
+	}
+
+	if g.document.Info.Title == "" || g.document.Info.Version == "" {
+		return fmt.Errorf("%s: @title and @version are required", path)
+	}
+
+	return nil
+}
+
+func (g *generator) parseOperations(dir string) error {
+	packages, err := parser.ParseDir(g.fileSet, dir, isSourceFile, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	operationIds := make(map[string]string)
+
+	for _, pkg := range packages {
+		for _, fileName := range sortedKeys(pkg.Files) {
+			for _, decl := range pkg.Files[fileName].Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Doc == nil {
+					continue
+				}
+
+				path, method, operation, err := g.parseOperation(funcDecl)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", g.fileSet.Position(funcDecl.Pos()), funcDecl.Name.Name, err)
+				}
+				if operation == nil {
+					continue
+				}
+
+				if existing, ok := operationIds[operation.OperationId]; ok {
+					return fmt.Errorf("operation id %s is used by both %s and %s %s", operation.OperationId, existing, method, path)
+				}
+				operationIds[operation.OperationId] = method + " " + path
+
+				if _, ok := g.document.Paths[path]; !ok {
+					g.document.Paths[path] = make(PathItem)
+				}
+				if _, ok := g.document.Paths[path][method]; ok {
+					return fmt.Errorf("%s %s is documented more than once", method, path)
+				}
+				g.document.Paths[path][method] = operation
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseOperation returns a nil operation when the function carries no @Router annotation
+func (g *generator) parseOperation(funcDecl *ast.FuncDecl) (string, string, *Operation, error) {
+	operation := &Operation{
+		OperationId: funcDecl.Name.Name,
+		Responses:   make(map[string]*Response),
+	}
+
+	var path, method string
+	var accept, produce []string
+
+	for _, line := range annotationLines(funcDecl.Doc) {
+		attribute, value := splitAnnotation(line)
+		switch attribute {
+		case "@summary":
+			operation.Summary = value
+		case "@description":
+			operation.Description = value
+		case "@tags":
+			operation.Tags = splitList(value)
+		case "@accept":
+			accept = mimeTypes(value)
+		case "@produce":
+			produce = mimeTypes(value)
+		case "@security":
+			operation.Security = append(operation.Security, map[string][]string{value: {}})
+		case "@router":
+			match := routerPattern.FindStringSubmatch(value)
+			if match == nil {
+				return "", "", nil, fmt.Errorf("invalid @Router annotation %q", value)
+			}
+			path, method = match[1], strings.ToLower(match[2])
+		}
+	}
+
+	if path == "" {
+		return "", "", nil, nil
+	}
+
+	if len(accept) == 0 {
+		accept = []string{mimeTypeAliases["json"]}
+	}
+	if len(produce) == 0 {
+		produce = []string{mimeTypeAliases["json"]}
+	}
+
+	for _, line := range annotationLines(funcDecl.Doc) {
+		attribute, value := splitAnnotation(line)
+		switch attribute {
+		case "@param":
+			if err := g.parseParam(operation, value, accept); err != nil {
+				return "", "", nil, err
+			}
+		case "@success", "@failure":
+			if err := g.parseResponse(operation, value, produce); err != nil {
+				return "", "", nil, err
+			}
+		}
+	}
+
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		if !operation.hasParameter(match[1], "path") {
+			return "", "", nil, fmt.Errorf("path parameter %s of %s is not documented with @Param", match[1], path)
+		}
+	}
+
+	if len(operation.Responses) == 0 {
+		return "", "", nil, fmt.Errorf("%s %s has no @Success or @Failure annotations", method, path)
+	}
+
+	return path, method, operation, nil
+}
+
+func (g *generator) parseParam(operation *Operation, value string, accept []string) error {
+	match := paramPattern.FindStringSubmatch(value)
+	if match == nil {
+		return fmt.Errorf("invalid @Param annotation %q", value)
+	}
+
+	name, in, dataType, description := match[1], match[2], match[3], match[5]
+	required := match[4] == "true"
+
+	schema, err := g.dataTypeSchema(dataType)
+	if err != nil {
+		return err
+	}
+
+	if in == "body" {
+		if operation.RequestBody != nil {
+			return fmt.Errorf("only one body parameter is allowed, found %s", name)
+		}
+		operation.RequestBody = &RequestBody{
+			Description: description,
+			Required:    required,
+			Content:     content(accept, schema),
+		}
+		return nil
+	}
+
+	operation.Parameters = append(operation.Parameters, &Parameter{
+		Name:        name,
+		In:          in,
+		Description: description,
+		Required:    required || in == "path",
+		Schema:      schema,
+	})
+
+	return nil
+}
+
+func (g *generator) parseResponse(operation *Operation, value string, produce []string) error {
+	match := responsePattern.FindStringSubmatch(value)
+	if match == nil {
+		return fmt.Errorf("invalid response annotation %q", value)
+	}
+
+	code, container, dataType, description := match[1], match[2], match[3], match[4]
+
+	statusCode, _ := strconv.Atoi(code)
+	if description == "" {
+		description = http.StatusText(statusCode)
+	}
+
+	response := &Response{Description: description}
+
+	if dataType != "" {
+		schema, err := g.dataTypeSchema(dataType)
+		if err != nil {
+			return err
+		}
+		if container == "array" {
+			schema = &Schema{Type: "array", Items: schema}
+		}
+		response.Content = content(produce, schema)
+	}
+
+	operation.Responses[code] = response
+
+	return nil
+}
+
+// dataTypeSchema resolves the type used in @Param and response annotations, either a primitive or a package qualified type
+func (g *generator) dataTypeSchema(dataType string) (*Schema, error) {
+	if schema := primitiveSchema(dataType); schema != nil {
+		return schema, nil
+	}
+
+	pkg, name, ok := strings.Cut(dataType, ".")
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s, types must be primitives or package qualified like models.Bucket", dataType)
+	}
+
+	return g.schemaRef(pkg, name)
+}
+
+// schemaRef adds the named type to the components the first time it is referenced and returns a reference to it
+func (g *generator) schemaRef(pkg string, name string) (*Schema, error) {
+	key := pkg + "." + name
+	ref := &Schema{Ref: schemaRefPrefix + key}
+
+	if _, ok := g.document.Components.Schemas[key]; ok {
+		return ref, nil
+	}
+
+	typeSpec, err := g.lookupType(pkg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// a placeholder is stored first so self referencing types do not recurse forever
+	g.document.Components.Schemas[key] = &Schema{}
+
+	schema, err := g.typeSchema(pkg, typeSpec.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	g.document.Components.Schemas[key] = schema
+
+	return ref, nil
+}
+
+func (g *generator) lookupType(pkg string, name string) (*ast.TypeSpec, error) {
+	if _, ok := g.types[pkg]; !ok {
+		packages, err := parser.ParseDir(g.fileSet, filepath.Join(g.root, pkg), isSourceFile, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		types := make(map[string]*ast.TypeSpec)
+		for _, parsedPackage := range packages {
+			for _, file := range parsedPackage.Files {
+				for _, decl := range file.Decls {
+					genDecl, ok := decl.(*ast.GenDecl)
+					if !ok || genDecl.Tok != token.TYPE {
+						continue
+					}
+					for _, spec := range genDecl.Specs {
+						typeSpec := spec.(*ast.TypeSpec)
+						types[typeSpec.Name.Name] = typeSpec
+					}
+				}
+			}
+		}
+		g.types[pkg] = types
+	}
+
+	typeSpec, ok := g.types[pkg][name]
+	if !ok {
+		return nil, fmt.Errorf("type %s.%s does not exist", pkg, name)
+	}
+
+	return typeSpec, nil
+}
+
+func (g *generator) typeSchema(pkg string, expr ast.Expr) (*Schema, error) {
+	switch typeExpr := expr.(type) {
+	case *ast.Ident:
+		if typeExpr.Name == "any" {
+			return &Schema{}, nil
+		}
+		if schema := primitiveSchema(typeExpr.Name); schema != nil {
+			return schema, nil
+		}
+		return g.schemaRef(pkg, typeExpr.Name)
+	case *ast.StarExpr:
+		return g.typeSchema(pkg, typeExpr.X)
+	case *ast.ArrayType:
+		items, err := g.typeSchema(pkg, typeExpr.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		values, err := g.typeSchema(pkg, typeExpr.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.InterfaceType:
+		return &Schema{}, nil
+	case *ast.SelectorExpr:
+		selectorPkg, ok := typeExpr.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported type expression %T", typeExpr.X)
+		}
+		if selectorPkg.Name == "time" && typeExpr.Sel.Name == "Time" {
+			return &Schema{Type: "string", Format: "date-time"}, nil
+		}
+		if selectorPkg.Name == "json" && typeExpr.Sel.Name == "RawMessage" {
+			return &Schema{Type: "object"}, nil
+		}
+		return g.schemaRef(selectorPkg.Name, typeExpr.Sel.Name)
+	case *ast.StructType:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		if err := g.addFields(pkg, schema, typeExpr); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type expression %T", expr)
+}
+
+func (g *generator) addFields(pkg string, schema *Schema, structType *ast.StructType) error {
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			tag = reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		}
+
+		// embedded structs are flattened the same way encoding/json does
+		if len(field.Names) == 0 {
+			embeddedPkg, embeddedName, err := embeddedType(pkg, field.Type)
+			if err != nil {
+				return err
+			}
+			embedded, err := g.lookupType(embeddedPkg, embeddedName)
+			if err != nil {
+				return err
+			}
+			embeddedStruct, ok := embedded.Type.(*ast.StructType)
+			if !ok {
+				return fmt.Errorf("embedded type %s.%s is not a struct", embeddedPkg, embeddedName)
+			}
+			if err = g.addFields(embeddedPkg, schema, embeddedStruct); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, fieldName := range field.Names {
+			if !fieldName.IsExported() {
+				continue
+			}
+
+			name := fieldName.Name
+			if jsonName, _, _ := strings.Cut(tag.Get("json"), ","); jsonName == "-" {
+				continue
+			} else if jsonName != "" {
+				name = jsonName
+			}
+
+			fieldSchema, err := g.fieldSchema(pkg, field, tag)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", fieldName.Name, err)
+			}
+
+			schema.Properties[name] = fieldSchema
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) fieldSchema(pkg string, field *ast.Field, tag reflect.StructTag) (*Schema, error) {
+	schema, err := g.typeSchema(pkg, field.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	description := commentText(field.Doc)
+	if description == "" {
+		description = commentText(field.Comment)
+	}
+	nullable := lo.Contains(splitList(tag.Get("extensions")), "x-nullable")
+
+	// siblings of $ref are ignored in openapi 3.0 so references are wrapped to carry the field level keywords
+	if schema.Ref != "" && (description != "" || nullable) {
+		schema = &Schema{AllOf: []*Schema{schema}}
+	}
+
+	schema.Description = description
+	schema.Nullable = nullable
+
+	if example, ok := tag.Lookup("example"); ok {
+		if schema.Example, err = tagValue(schema, example); err != nil {
+			return nil, fmt.Errorf("example: %w", err)
+		}
+	}
+
+	if defaultValue, ok := tag.Lookup("default"); ok {
+		if schema.Default, err = tagValue(schema, defaultValue); err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+	}
+
+	if enum, ok := tag.Lookup("enum"); ok {
+		for _, value := range splitList(enum) {
+			enumValue, err := scalarValue(schema.Type, value)
+			if err != nil {
+				return nil, fmt.Errorf("enum: %w", err)
+			}
+			schema.Enum = append(schema.Enum, enumValue)
+		}
+	}
+
+	return schema, nil
+}
+
+func embeddedType(pkg string, expr ast.Expr) (string, string, error) {
+	switch typeExpr := expr.(type) {
+	case *ast.Ident:
+		return pkg, typeExpr.Name, nil
+	case *ast.StarExpr:
+		return embeddedType(pkg, typeExpr.X)
+	case *ast.SelectorExpr:
+		if selectorPkg, ok := typeExpr.X.(*ast.Ident); ok {
+			return selectorPkg.Name, typeExpr.Sel.Name, nil
+		}
+	}
+	return "", "", fmt.Errorf("unsupported embedded type expression %T", expr)
+}
+
+// tagValue converts an example or default tag into the json type of the schema, arrays use the comma separated swag format
+func tagValue(schema *Schema, value string) (any, error) {
+	if schema.Type != "array" {
+		return scalarValue(schema.Type, value)
+	}
+
+	values := make([]any, 0)
+	for _, item := range splitList(value) {
+		itemValue, err := scalarValue(schema.Items.Type, item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, itemValue)
+	}
+
+	return values, nil
+}
+
+func scalarValue(schemaType string, value string) (any, error) {
+	switch schemaType {
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
+
+func primitiveSchema(name string) *Schema {
+	switch name {
+	case "string":
+		return &Schema{Type: "string"}
+	case "int", "integer", "int64", "uint64":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "int32", "uint32", "int16", "uint16", "int8", "uint8":
+		return &Schema{Type: "integer", Format: "int32"}
+	case "number", "float64":
+		return &Schema{Type: "number", Format: "double"}
+	case "float32":
+		return &Schema{Type: "number", Format: "float"}
+	case "bool", "boolean":
+		return &Schema{Type: "boolean"}
+	}
+	return nil
+}
+
+func content(mimeTypes []string, schema *Schema) map[string]*MediaType {
+	mediaTypes := make(map[string]*MediaType, len(mimeTypes))
+	for _, mimeType := range mimeTypes {
+		mediaTypes[mimeType] = &MediaType{Schema: schema}
+	}
+	return mediaTypes
+}
+
+func mimeTypes(value string) []string {
+	var result []string
+	for _, mimeType := range splitList(value) {
+		if alias, ok := mimeTypeAliases[mimeType]; ok {
+			mimeType = alias
+		}
+		result = append(result, mimeType)
+	}
+	return result
+}
+
+// annotationLines returns the comment lines that start with @, with the comment markers stripped
+func annotationLines(commentGroup *ast.CommentGroup) []string {
+	if commentGroup == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, comment := range commentGroup.List {
+		line := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if strings.HasPrefix(line, "@") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitAnnotation returns the lower cased attribute and the rest of the line
+func splitAnnotation(line string) (string, string) {
+	attribute, value, _ := strings.Cut(line, " ")
+	return strings.ToLower(attribute), strings.TrimSpace(value)
+}
+
+// commentText joins a doc comment into a single line description
+func commentText(commentGroup *ast.CommentGroup) string {
+	if commentGroup == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(commentGroup.Text()), " ")
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func isSourceFile(info fs.FileInfo) bool {
+	return !strings.HasSuffix(info.Name(), "_test.go")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
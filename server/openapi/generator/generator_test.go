@@ -0,0 +1,44 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/openapi"
+)
+
+func TestRender_MatchesEmbeddedDocument(t *testing.T) {
+	document, err := Render("../..")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(document), string(openapi.Spec()), "openapi/openapi.json is out of date, run `go generate ./openapi`")
+}
+
+func TestGenerate_ModelSchemas(t *testing.T) {
+	document, err := Generate("../..")
+	require.NoError(t, err)
+
+	bucket := document.Components.Schemas["models.Bucket"]
+	require.NotNil(t, bucket)
+
+	assert.Equal(t, "array", bucket.Properties["allowed_mime_types"].Type)
+	assert.Equal(t, []any{"image/jpeg", "image/png", "video/mp4", "audio/wav"}, bucket.Properties["allowed_mime_types"].Example)
+	assert.Equal(t, int64(10485760), bucket.Properties["max_allowed_object_size"].Example)
+	assert.True(t, bucket.Properties["max_allowed_object_size"].Nullable)
+	assert.False(t, bucket.Properties["name"].Nullable)
+	assert.Equal(t, []any{"bucket.deletion", "bucket.emptying", "bucket.restore", "bucket.relocation"}, bucket.Properties["lock_reason"].Enum)
+	assert.Equal(t, "date-time", bucket.Properties["created_at"].Format)
+
+	preSignedUploadSessionCreate := document.Components.Schemas["models.PreSignedUploadSessionCreate"]
+	require.NotNil(t, preSignedUploadSessionCreate)
+	assert.NotContains(t, preSignedUploadSessionCreate.Properties, "BucketId", "fields tagged json:\"-\" are not part of the body")
+
+	health := document.Components.Schemas["models.Health"]
+	require.NotNil(t, health)
+	assert.Equal(t, "#/components/schemas/models.HealthCheck", health.Properties["checks"].AdditionalProperties.Ref)
+
+	httpError := document.Components.Schemas["middleware.HttpError"]
+	require.NotNil(t, httpError)
+	assert.Equal(t, "status_code return a http status code depending on the error", httpError.Properties["status_code"].Description)
+}
@@ -0,0 +1,25 @@
+// Package openapi embeds the openapi document generated from the controller annotations and the page that renders it.
+// run `go generate ./openapi` after changing an annotation or a model referenced by one
+package openapi
+
+import (
+	_ "embed"
+)
+
+//go:generate go run ../cmd/openapi-gen -root .. -out openapi.json
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// Spec returns the generated openapi 3 document
+func Spec() []byte {
+	return spec
+}
+
+// Docs returns the swagger ui page that loads the document from /api/v1/openapi.json
+func Docs() []byte {
+	return docs
+}
@@ -0,0 +1,41 @@
+package processing
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+const ProcessorChecksum = "checksum"
+
+// ChecksumProcessor hashes the whole content into the checksums key of the metadata. the md5 is kept alongside the
+// sha256 since it is what most clients compare against, etags of multipart uploads are not a checksum of the content
+type ChecksumProcessor struct{}
+
+func (*ChecksumProcessor) Name() string {
+	return ProcessorChecksum
+}
+
+func (*ChecksumProcessor) Accepts(mimeType string) bool {
+	return true
+}
+
+func (*ChecksumProcessor) Process(ctx context.Context, object *Object, content io.Reader) (*Result, error) {
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), content); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Metadata: map[string]any{
+			"checksums": map[string]any{
+				"md5":    hex.EncodeToString(md5Hash.Sum(nil)),
+				"sha256": hex.EncodeToString(sha256Hash.Sum(nil)),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,127 @@
+package processing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const ProcessorContentType = "content_type"
+
+const sniffLength = 512
+
+// mimeTypeAliases maps mime types that name the same content onto the name http.DetectContentType reports
+var mimeTypeAliases = map[string]string{
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"audio/wav":                    "audio/wave",
+	"audio/x-wav":                  "audio/wave",
+	"audio/mp3":                    "audio/mpeg",
+	"application/gzip":             "application/x-gzip",
+	"application/x-pdf":            "application/pdf",
+	"application/x-zip-compressed": "application/zip",
+	"image/vnd.microsoft.icon":     "image/x-icon",
+}
+
+// detectedFamilies lists, for detected types that several formats share, the declared types the content may have.
+// a * matches any run of characters
+var detectedFamilies = map[string][]string{
+	"text/plain": {
+		"text/*", "application/json", "application/*+json", "application/x-ndjson", "application/xml",
+		"application/*+xml", "application/javascript", "application/x-javascript", "application/yaml",
+		"application/x-yaml", "application/sql", "application/csv", "application/x-sh", "image/svg+xml",
+	},
+	"text/xml":        {"application/xml", "application/*+xml", "image/svg+xml", "text/*"},
+	"text/html":       {"application/xhtml+xml", "image/svg+xml", "text/*"},
+	"application/zip": {"application/java-archive", "application/epub+zip", "application/vnd.openxmlformats-officedocument.*", "application/vnd.oasis.opendocument.*", "application/vnd.android.package-archive", "application/vnd.ms-*"},
+	"video/mp4":       {"video/*", "audio/mp4", "audio/x-m4a", "audio/aac"},
+	"application/ogg": {"audio/ogg", "video/ogg", "audio/opus"},
+	"video/webm":      {"audio/webm"},
+	"audio/mpeg":      {"audio/mpeg3", "audio/x-mpeg-3"},
+}
+
+// ContentTypeProcessor sniffs the content type from the leading bytes of the content. objects whose content does
+// not match their declared mime type are rejected, objects declared as application/octet-stream take the detected
+// type instead
+type ContentTypeProcessor struct{}
+
+func (*ContentTypeProcessor) Name() string {
+	return ProcessorContentType
+}
+
+func (*ContentTypeProcessor) Accepts(mimeType string) bool {
+	return true
+}
+
+func (*ContentTypeProcessor) Process(ctx context.Context, object *Object, content io.Reader) (*Result, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	detected := baseMimeType(http.DetectContentType(head[:n]))
+	declared := baseMimeType(object.MimeType)
+
+	result := &Result{Metadata: map[string]any{"detected_mime_type": detected}}
+
+	if declared == "application/octet-stream" {
+		if detected != "application/octet-stream" {
+			result.MimeType = &detected
+		}
+		return result, nil
+	}
+
+	if !MimeTypesMatch(declared, detected) {
+		return nil, fmt.Errorf("%w: declared mime type '%s' does not match the detected mime type '%s'", ErrRejected, object.MimeType, detected)
+	}
+
+	return result, nil
+}
+
+// MimeTypesMatch reports whether content detected as the detected mime type can be of the declared mime type, an
+// unrecognized detection matches anything
+func MimeTypesMatch(declared string, detected string) bool {
+	declared = canonicalMimeType(baseMimeType(declared))
+	detected = canonicalMimeType(baseMimeType(detected))
+
+	if detected == "application/octet-stream" || declared == detected {
+		return true
+	}
+
+	for _, pattern := range detectedFamilies[detected] {
+		if matchesPattern(declared, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesPattern matches a mime type against a pattern with at most one *, so application/*+json matches
+// application/ld+json
+func matchesPattern(mimeType string, pattern string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return mimeType == pattern
+	}
+	return len(mimeType) >= len(prefix)+len(suffix) && strings.HasPrefix(mimeType, prefix) && strings.HasSuffix(mimeType, suffix)
+}
+
+func baseMimeType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	return mediaType
+}
+
+func canonicalMimeType(mimeType string) string {
+	if alias, ok := mimeTypeAliases[mimeType]; ok {
+		return alias
+	}
+	return mimeType
+}
@@ -0,0 +1,172 @@
+package processing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	tiffTypeAscii    = 2
+	tiffTypeShort    = 3
+	tiffTypeLong     = 4
+	tiffTypeRational = 5
+
+	exifIfdPointerTag = 0x8769
+)
+
+// exifTags are the tags kept from the exif of a jpeg, keyed by tag number
+var exifTags = map[uint16]string{
+	0x010f: "make",
+	0x0110: "model",
+	0x0112: "orientation",
+	0x0131: "software",
+	0x0132: "date_time",
+	0x829a: "exposure_time",
+	0x829d: "f_number",
+	0x8827: "iso",
+	0x9003: "date_time_original",
+	0x920a: "focal_length",
+	0xa002: "pixel_x_dimension",
+	0xa003: "pixel_y_dimension",
+}
+
+// parseJpegExif returns the known tags of the first exif segment found in the leading bytes of a jpeg. exif is
+// written by cameras and editors of every quality so anything malformed ends the parse with what was read so far
+func parseJpegExif(data []byte) map[string]any {
+	tiff := findExifSegment(data)
+	if len(tiff) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	if order.Uint16(tiff[2:4]) != 42 {
+		return nil
+	}
+
+	tags := make(map[string]any)
+
+	exifOffset := readIfd(tiff, order, order.Uint32(tiff[4:8]), tags)
+	if exifOffset != 0 {
+		readIfd(tiff, order, exifOffset, tags)
+	}
+
+	return tags
+}
+
+// findExifSegment walks the jpeg markers up to the start of the scan and returns the tiff structure of the exif app1
+// segment
+func findExifSegment(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil
+	}
+
+	position := 2
+	for position+4 <= len(data) {
+		if data[position] != 0xff {
+			return nil
+		}
+
+		marker := data[position+1]
+		// start of scan, the headers are over
+		if marker == 0xda {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[position+2 : position+4]))
+		if length < 2 || position+2+length > len(data) {
+			return nil
+		}
+
+		segment := data[position+4 : position+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+
+		position += 2 + length
+	}
+
+	return nil
+}
+
+// readIfd adds the known tags of the image file directory at offset and returns the offset of the exif sub directory
+// when the directory points to one
+func readIfd(tiff []byte, order binary.ByteOrder, offset uint32, tags map[string]any) uint32 {
+	if int(offset)+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	entries := tiff[offset+2:]
+
+	var exifOffset uint32
+
+	for i := 0; i < count && (i+1)*12 <= len(entries); i++ {
+		entry := entries[i*12 : (i+1)*12]
+
+		tag := order.Uint16(entry[0:2])
+		valueType := order.Uint16(entry[2:4])
+		valueCount := order.Uint32(entry[4:8])
+
+		if tag == exifIfdPointerTag && valueType == tiffTypeLong {
+			exifOffset = order.Uint32(entry[8:12])
+			continue
+		}
+
+		name, ok := exifTags[tag]
+		if !ok || valueCount == 0 {
+			continue
+		}
+
+		if value, ok := readTagValue(tiff, order, entry, valueType, valueCount); ok {
+			tags[name] = value
+		}
+	}
+
+	return exifOffset
+}
+
+func readTagValue(tiff []byte, order binary.ByteOrder, entry []byte, valueType uint16, valueCount uint32) (any, bool) {
+	switch valueType {
+	case tiffTypeAscii:
+		// values of up to four bytes are stored in the entry itself
+		value := entry[8:12]
+		if valueCount > 4 {
+			start := order.Uint32(entry[8:12])
+			if uint64(start)+uint64(valueCount) > uint64(len(tiff)) {
+				return nil, false
+			}
+			value = tiff[start : start+valueCount]
+		} else {
+			value = value[:valueCount]
+		}
+		text := strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+		return text, text != ""
+	case tiffTypeShort:
+		return int(order.Uint16(entry[8:10])), true
+	case tiffTypeLong:
+		return int64(order.Uint32(entry[8:12])), true
+	case tiffTypeRational:
+		start := order.Uint32(entry[8:12])
+		if uint64(start)+8 > uint64(len(tiff)) {
+			return nil, false
+		}
+		numerator := order.Uint32(tiff[start:])
+		denominator := order.Uint32(tiff[start+4:])
+		if denominator == 0 {
+			return nil, false
+		}
+		return float64(numerator) / float64(denominator), true
+	default:
+		return nil, false
+	}
+}
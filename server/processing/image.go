@@ -0,0 +1,75 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const ProcessorImage = "image"
+
+// exifSearchLength bounds how much of the content is kept to look for exif, jpeg keeps it in an app1 segment of at
+// most 64KB which comes before the frame header
+const exifSearchLength = 256 * 1024
+
+// ImageProcessor extracts the dimensions of images and the exif of jpegs into the image key of the metadata, content
+// that does not decode as an image is rejected. gps tags are left out so the location a photo was taken at is not
+// exposed through the metadata
+type ImageProcessor struct{}
+
+func (*ImageProcessor) Name() string {
+	return ProcessorImage
+}
+
+func (*ImageProcessor) Accepts(mimeType string) bool {
+	switch canonicalMimeType(baseMimeType(mimeType)) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+func (*ImageProcessor) Process(ctx context.Context, object *Object, content io.Reader) (*Result, error) {
+	// decoding the config only reads the headers, what it read is kept to look for exif in
+	var head bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(content, &limitedWriter{buffer: &head, limit: exifSearchLength}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: content of mime type '%s' is not a valid image: %v", ErrRejected, object.MimeType, err)
+	}
+
+	details := map[string]any{
+		"width":  config.Width,
+		"height": config.Height,
+		"format": format,
+	}
+
+	if format == "jpeg" {
+		if exif := parseJpegExif(head.Bytes()); len(exif) > 0 {
+			details["exif"] = exif
+		}
+	}
+
+	return &Result{Metadata: map[string]any{"image": details}}, nil
+}
+
+// limitedWriter keeps the first limit bytes written to it and drops the rest without failing the write
+type limitedWriter struct {
+	buffer *bytes.Buffer
+	limit  int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if remaining := w.limit - w.buffer.Len(); remaining > 0 {
+		w.buffer.Write(p[:min(len(p), remaining)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,99 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ErrRejected is returned by processors when the content of an object must not be kept, the object is deleted
+var ErrRejected = errors.New("object rejected")
+
+// Object is the object a processor runs on, its content is passed separately as a stream
+type Object struct {
+	Id       string
+	BucketId string
+	Name     string
+	MimeType string
+	Size     int64
+}
+
+type Result struct {
+	// Metadata is merged into the top level of the object metadata
+	Metadata map[string]any
+	// MimeType replaces the mime type of the object when set
+	MimeType *string
+}
+
+// Processor runs on the content of an object once its upload completes. processors run in their own jobs, possibly
+// at the same time on the same object, so they must only touch the metadata keys they own
+type Processor interface {
+	Name() string
+	// Accepts reports whether the processor runs on objects of the mime type
+	Accepts(mimeType string) bool
+	// Process reads as much of the content as it needs, the content does not have to be read to the end
+	Process(ctx context.Context, object *Object, content io.Reader) (*Result, error)
+}
+
+var (
+	processorsMu sync.RWMutex
+	processors   = make(map[string]Processor)
+)
+
+// Register makes a processor available to buckets under its name, it panics when the name is taken since that is a
+// programming error
+func Register(processor Processor) {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+
+	if _, ok := processors[processor.Name()]; ok {
+		panic(fmt.Sprintf("processing: processor %q registered twice", processor.Name()))
+	}
+
+	processors[processor.Name()] = processor
+}
+
+func Lookup(name string) (Processor, bool) {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+
+	processor, ok := processors[name]
+	return processor, ok
+}
+
+// Names returns the names of every registered processor in sorted order
+func Names() []string {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+
+	names := make([]string, 0, len(processors))
+	for name := range processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Select returns the processors among names that run on objects of the mime type, unknown names are skipped so a
+// processor that is no longer registered does not block uploads to buckets that still name it
+func Select(names []string, mimeType string) []Processor {
+	var selected []Processor
+	for _, name := range names {
+		processor, ok := Lookup(name)
+		if ok && processor.Accepts(mimeType) {
+			selected = append(selected, processor)
+		}
+	}
+
+	return selected
+}
+
+func init() {
+	Register(&ContentTypeProcessor{})
+	Register(&ImageProcessor{})
+	Register(&ChecksumProcessor{})
+}
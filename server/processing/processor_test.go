@@ -0,0 +1,194 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeImage(t *testing.T, format string, width int, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	var buffer bytes.Buffer
+	switch format {
+	case "png":
+		require.NoError(t, png.Encode(&buffer, img))
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buffer, img, nil))
+	}
+
+	return buffer.Bytes()
+}
+
+// exifJpeg splices an exif app1 segment holding make, orientation, a gps pointer and an exif sub directory with the
+// original date and f number right after the start of image marker
+func exifJpeg(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	order := binary.LittleEndian
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00")
+
+	entry := func(tag uint16, valueType uint16, count uint32, value uint32) []byte {
+		e := make([]byte, 12)
+		order.PutUint16(e[0:], tag)
+		order.PutUint16(e[2:], valueType)
+		order.PutUint32(e[4:], count)
+		order.PutUint32(e[8:], value)
+		return e
+	}
+
+	// ifd0 at 8 with 4 entries takes 2 + 48 + 4 bytes, its values follow at 62
+	const makeOffset = 62
+	const exifIfdOffset = makeOffset + 6
+	tiff = append(tiff, 4, 0)
+	tiff = append(tiff, entry(0x010f, tiffTypeAscii, 6, makeOffset)...)
+	tiff = append(tiff, entry(0x0112, tiffTypeShort, 1, 6)...)
+	tiff = append(tiff, entry(0x8825, tiffTypeLong, 1, 0)...)
+	tiff = append(tiff, entry(exifIfdPointerTag, tiffTypeLong, 1, exifIfdOffset)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("Canon\x00")...)
+
+	// the exif ifd with 2 entries takes 2 + 24 + 4 bytes, its values follow
+	const dateOffset = exifIfdOffset + 30
+	const fNumberOffset = dateOffset + 20
+	tiff = append(tiff, 2, 0)
+	tiff = append(tiff, entry(0x9003, tiffTypeAscii, 20, dateOffset)...)
+	tiff = append(tiff, entry(0x829d, tiffTypeRational, 1, fNumberOffset)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("2024:02:13 08:14:49\x00")...)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 28)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 10)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	plain := encodeImage(t, "jpeg", width, height)
+
+	return append(append(append([]byte{}, plain[:2]...), app1...), plain[2:]...)
+}
+
+func TestSelect(t *testing.T) {
+	names := func(processors []Processor) []string {
+		var result []string
+		for _, processor := range processors {
+			result = append(result, processor.Name())
+		}
+		return result
+	}
+
+	all := []string{ProcessorContentType, ProcessorImage, ProcessorChecksum, "unknown"}
+
+	assert.Equal(t, []string{ProcessorContentType, ProcessorImage, ProcessorChecksum}, names(Select(all, "image/png")))
+	assert.Equal(t, []string{ProcessorContentType, ProcessorChecksum}, names(Select(all, "application/pdf")))
+	assert.Empty(t, Select(nil, "image/png"))
+}
+
+func TestRegister_PanicsOnDuplicateName(t *testing.T) {
+	assert.Panics(t, func() { Register(&ChecksumProcessor{}) })
+}
+
+func TestMimeTypesMatch(t *testing.T) {
+	tests := []struct {
+		declared string
+		detected string
+		match    bool
+	}{
+		{declared: "image/png", detected: "image/png", match: true},
+		{declared: "image/jpg", detected: "image/jpeg", match: true},
+		{declared: "application/json", detected: "text/plain; charset=utf-8", match: true},
+		{declared: "application/ld+json", detected: "text/plain; charset=utf-8", match: true},
+		{declared: "text/csv", detected: "text/plain; charset=utf-8", match: true},
+		{declared: "image/svg+xml", detected: "text/xml; charset=utf-8", match: true},
+		{declared: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", detected: "application/zip", match: true},
+		{declared: "video/quicktime", detected: "video/mp4", match: true},
+		{declared: "application/x-custom", detected: "application/octet-stream", match: true},
+		{declared: "image/png", detected: "image/jpeg", match: false},
+		{declared: "image/png", detected: "text/plain; charset=utf-8", match: false},
+		{declared: "application/pdf", detected: "application/zip", match: false},
+		{declared: "text/plain", detected: "application/x-msdownload", match: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.declared+" "+test.detected, func(t *testing.T) {
+			assert.Equal(t, test.match, MimeTypesMatch(test.declared, test.detected))
+		})
+	}
+}
+
+func TestContentTypeProcessor(t *testing.T) {
+	processor := &ContentTypeProcessor{}
+	content := encodeImage(t, "png", 4, 4)
+
+	result, err := processor.Process(context.Background(), &Object{MimeType: "image/png"}, bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Nil(t, result.MimeType)
+	assert.Equal(t, "image/png", result.Metadata["detected_mime_type"])
+
+	_, err = processor.Process(context.Background(), &Object{MimeType: "application/pdf"}, bytes.NewReader(content))
+	assert.ErrorIs(t, err, ErrRejected)
+
+	result, err = processor.Process(context.Background(), &Object{MimeType: "application/octet-stream"}, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NotNil(t, result.MimeType)
+	assert.Equal(t, "image/png", *result.MimeType)
+
+	result, err = processor.Process(context.Background(), &Object{MimeType: "text/plain"}, strings.NewReader("hi"))
+	require.NoError(t, err)
+	assert.Nil(t, result.MimeType)
+}
+
+func TestImageProcessor(t *testing.T) {
+	processor := &ImageProcessor{}
+
+	result, err := processor.Process(context.Background(), &Object{MimeType: "image/png"}, bytes.NewReader(encodeImage(t, "png", 30, 20)))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"width": 30, "height": 20, "format": "png"}, result.Metadata["image"])
+
+	result, err = processor.Process(context.Background(), &Object{MimeType: "image/jpeg"}, bytes.NewReader(exifJpeg(t, 16, 8)))
+	require.NoError(t, err)
+
+	details := result.Metadata["image"].(map[string]any)
+	assert.Equal(t, 16, details["width"])
+	assert.Equal(t, 8, details["height"])
+	assert.Equal(t, map[string]any{
+		"make":               "Canon",
+		"orientation":        6,
+		"date_time_original": "2024:02:13 08:14:49",
+		"f_number":           2.8,
+	}, details["exif"])
+
+	_, err = processor.Process(context.Background(), &Object{MimeType: "image/png"}, strings.NewReader("not an image"))
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func TestParseJpegExif_Malformed(t *testing.T) {
+	valid := exifJpeg(t, 8, 8)
+
+	for length := 0; length < 200; length++ {
+		assert.NotPanics(t, func() { parseJpegExif(valid[:length]) })
+	}
+
+	assert.Nil(t, parseJpegExif(encodeImage(t, "png", 4, 4)))
+}
+
+func TestChecksumProcessor(t *testing.T) {
+	result, err := (&ChecksumProcessor{}).Process(context.Background(), &Object{}, strings.NewReader("hello world"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"md5":    "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	}, result.Metadata["checksums"])
+}
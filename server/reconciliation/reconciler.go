@@ -0,0 +1,422 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// pageSize is how many objects are read from storage and from the catalog at a time
+const pageSize = 1000
+
+// reconcilerPrincipal is who the audit events of fixes are recorded for
+const reconcilerPrincipal = "system:storage_reconciler"
+
+// Options tell a reconciliation what to fix. a dry run fixes nothing whatever the policies say
+type Options struct {
+	DryRun        bool
+	OrphanPolicy  string
+	MissingPolicy string
+	GracePeriod   time.Duration
+}
+
+// Reconciler compares the content of a bucket in storage with its catalog. both are walked in the byte order of
+// object names and merged, so a bucket of any size is compared a page at a time
+type Reconciler struct {
+	queries *database.Queries
+	storage *storage.Storage
+	logger  *zap.Logger
+}
+
+func NewReconciler(db *pgxpool.Pool, storage *storage.Storage, logger *zap.Logger) *Reconciler {
+	return &Reconciler{
+		queries: database.New(db),
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// ReconcileBucket reports the orphans and missing objects of the bucket and deletes them when the policies say so
+func (r *Reconciler) ReconcileBucket(ctx context.Context, bucket *database.StorageBucket, options *Options) (*models.ReconciliationReport, error) {
+	const op = "Reconciler.ReconcileBucket"
+
+	report := &models.ReconciliationReport{
+		BucketId:      bucket.ID,
+		BucketName:    bucket.Name,
+		DryRun:        options.DryRun,
+		OrphanPolicy:  options.OrphanPolicy,
+		MissingPolicy: options.MissingPolicy,
+		Orphans:       make([]*models.ReconciliationOrphan, 0),
+		Missing:       make([]*models.ReconciliationMissing, 0),
+		StartedAt:     time.Now(),
+	}
+
+	cutoff := report.StartedAt.Add(-options.GracePeriod)
+	deleteOrphans := !options.DryRun && options.OrphanPolicy == models.ReconciliationPolicyDelete
+	deleteMissing := !options.DryRun && options.MissingPolicy == models.ReconciliationPolicyDelete
+
+	orphanNames := make([]string, 0, storage.DeleteObjectsMaxCount)
+	missingObjects := make([]*database.ObjectListByBucketIdAfterNameRow, 0, pageSize)
+
+	onOrphan := func(object *storage.ListedObject) error {
+		if object.LastModified != nil && object.LastModified.After(cutoff) {
+			return nil
+		}
+
+		report.OrphanCount++
+		if len(report.Orphans) < models.ReconciliationMaxReportEntries {
+			report.Orphans = append(report.Orphans, &models.ReconciliationOrphan{
+				Name:         object.Name,
+				Size:         object.Size,
+				LastModified: object.LastModified,
+			})
+		}
+
+		if !deleteOrphans {
+			return nil
+		}
+
+		orphanNames = append(orphanNames, object.Name)
+		if len(orphanNames) < storage.DeleteObjectsMaxCount {
+			return nil
+		}
+
+		deleted, err := r.deleteOrphans(ctx, bucket, orphanNames, op)
+		report.OrphansDeleted += deleted
+		orphanNames = orphanNames[:0]
+		return err
+	}
+
+	onMissing := func(object *database.ObjectListByBucketIdAfterNameRow) error {
+		// pending objects have no content until their upload completes
+		if object.UploadStatus == models.ObjectUploadStatusPending {
+			return nil
+		}
+
+		changedAt := object.CreatedAt
+		if object.UpdatedAt != nil {
+			changedAt = *object.UpdatedAt
+		}
+		if changedAt.After(cutoff) {
+			return nil
+		}
+
+		kept := models.IsObjectLocked(object.LegalHold, object.RetentionMode, object.RetainUntil, false)
+
+		report.MissingCount++
+		if len(report.Missing) < models.ReconciliationMaxReportEntries {
+			report.Missing = append(report.Missing, &models.ReconciliationMissing{
+				ObjectId:     object.ID,
+				Name:         object.Name,
+				UploadStatus: object.UploadStatus,
+				CreatedAt:    object.CreatedAt,
+				Kept:         kept,
+			})
+		}
+
+		// the row of a locked object is its only record once the content is gone
+		if !deleteMissing || kept {
+			return nil
+		}
+
+		missingObjects = append(missingObjects, object)
+		if len(missingObjects) < pageSize {
+			return nil
+		}
+
+		deleted, err := r.deleteMissing(ctx, bucket, missingObjects, op)
+		report.MissingDeleted += deleted
+		missingObjects = missingObjects[:0]
+		return err
+	}
+
+	listed := pages(func(after string) ([]*storage.ListedObject, bool, error) {
+		page, err := r.storage.ListObjects(ctx, &storage.ObjectsList{
+			Bucket:     bucket.StoragePrefix,
+			StartAfter: after,
+			MaxKeys:    pageSize,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		report.StorageObjects += int64(len(page.Objects))
+		return page.Objects, page.Truncated, nil
+	}, func(object *storage.ListedObject) string { return object.Name })
+
+	cataloged := pages(func(after string) ([]*database.ObjectListByBucketIdAfterNameRow, bool, error) {
+		objects, err := r.queries.ObjectListByBucketIdAfterName(ctx, &database.ObjectListByBucketIdAfterNameParams{
+			BucketID:  bucket.ID,
+			AfterName: after,
+			Limit:     pageSize,
+		})
+		if err != nil {
+			r.logger.Error(
+				"failed to list objects",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return nil, false, err
+		}
+		report.CatalogObjects += int64(len(objects))
+		return objects, len(objects) == pageSize, nil
+	}, func(object *database.ObjectListByBucketIdAfterNameRow) string { return object.Name })
+
+	if err := merge(listed, cataloged, onOrphan, onMissing); err != nil {
+		return nil, err
+	}
+
+	if len(orphanNames) > 0 {
+		deleted, err := r.deleteOrphans(ctx, bucket, orphanNames, op)
+		report.OrphansDeleted += deleted
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(missingObjects) > 0 {
+		deleted, err := r.deleteMissing(ctx, bucket, missingObjects, op)
+		report.MissingDeleted += deleted
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	if report.OrphansDeleted > 0 || report.MissingDeleted > 0 {
+		if err := r.audit(ctx, report); err != nil {
+			r.logger.Error(
+				"failed to record audit event of reconciliation",
+				zap.String("bucket_id", bucket.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// deleteOrphans deletes orphaned content from storage. names that got an object since they were listed are kept,
+// their upload is in flight
+func (r *Reconciler) deleteOrphans(ctx context.Context, bucket *database.StorageBucket, names []string, op string) (int64, error) {
+	cataloged, err := r.queries.ObjectListNamesByBucketIdAndNames(ctx, &database.ObjectListNamesByBucketIdAndNamesParams{
+		BucketID: bucket.ID,
+		Names:    names,
+	})
+	if err != nil {
+		r.logger.Error(
+			"failed to recheck orphaned objects",
+			zap.String("bucket_id", bucket.ID),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	skip := make(map[string]struct{}, len(cataloged))
+	for _, name := range cataloged {
+		skip[name] = struct{}{}
+	}
+
+	orphans := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := skip[name]; !ok {
+			orphans = append(orphans, name)
+		}
+	}
+	if len(orphans) == 0 {
+		return 0, nil
+	}
+
+	err = r.storage.DeleteObjects(ctx, &storage.ObjectsDelete{
+		Bucket: bucket.StoragePrefix,
+		Names:  orphans,
+	})
+	if err != nil {
+		r.logger.Error(
+			"failed to delete orphaned objects from storage",
+			zap.String("bucket_name", bucket.Name),
+			zap.Int("count", len(orphans)),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	r.logger.Warn(
+		"deleted orphaned objects from storage",
+		zap.String("bucket_id", bucket.ID),
+		zap.Int("count", len(orphans)),
+		zapfield.Operation(op),
+	)
+
+	return int64(len(orphans)), nil
+}
+
+// deleteMissing deletes the objects whose content is gone from the catalog. content is looked up once more right
+// before, a rename or copy in flight may have put it back
+func (r *Reconciler) deleteMissing(ctx context.Context, bucket *database.StorageBucket, objects []*database.ObjectListByBucketIdAfterNameRow, op string) (int64, error) {
+	ids := make([]string, 0, len(objects))
+
+	for _, object := range objects {
+		_, err := r.storage.HeadObject(ctx, &storage.ObjectHead{
+			Bucket: bucket.StoragePrefix,
+			Name:   object.Name,
+		})
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, storage.ErrObjectNotFound) {
+			return 0, err
+		}
+
+		err = r.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+			Bucket: storage.RenderCacheBucket,
+			Prefix: storage.RenderCachePrefix(bucket.ID, object.ID),
+		})
+		if err != nil {
+			r.logger.Error(
+				"failed to delete cached renders",
+				zap.String("object_id", object.ID),
+				zapfield.Operation(op),
+				zap.Error(err),
+			)
+			return 0, err
+		}
+
+		ids = append(ids, object.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := r.queries.ObjectDeleteMany(ctx, ids); err != nil {
+		r.logger.Error(
+			"failed to delete objects without content from database",
+			zap.String("bucket_id", bucket.ID),
+			zap.Int("count", len(ids)),
+			zapfield.Operation(op),
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	r.logger.Warn(
+		"deleted objects without content from database",
+		zap.String("bucket_id", bucket.ID),
+		zap.Int("count", len(ids)),
+		zapfield.Operation(op),
+	)
+
+	return int64(len(ids)), nil
+}
+
+func (r *Reconciler) audit(ctx context.Context, report *models.ReconciliationReport) error {
+	detailsBytes, err := json.Marshal(map[string]any{
+		"orphan_policy":   report.OrphanPolicy,
+		"missing_policy":  report.MissingPolicy,
+		"orphans_deleted": report.OrphansDeleted,
+		"missing_deleted": report.MissingDeleted,
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.queries.AuditEventCreate(ctx, &database.AuditEventCreateParams{
+		Action:    models.AuditActionStorageReconciliation,
+		Principal: reconcilerPrincipal,
+		BucketID:  &report.BucketId,
+		Details:   detailsBytes,
+	})
+}
+
+// pages turns a paged listing into a function that returns one item at a time and nil once the listing is done.
+// fetch returns the page after the given name and whether more pages follow
+func pages[T any](fetch func(after string) ([]*T, bool, error), name func(*T) string) func() (*T, error) {
+	var page []*T
+	after := ""
+	more := true
+
+	return func() (*T, error) {
+		if len(page) == 0 {
+			if !more {
+				return nil, nil
+			}
+
+			var err error
+			page, more, err = fetch(after)
+			if err != nil {
+				return nil, err
+			}
+			if len(page) == 0 {
+				more = false
+				return nil, nil
+			}
+
+			after = name(page[len(page)-1])
+		}
+
+		item := page[0]
+		page = page[1:]
+
+		return item, nil
+	}
+}
+
+// merge walks content listed from storage and objects from the catalog, both in the byte order of their names, and
+// hands content without an object to onOrphan and objects without content to onMissing
+func merge(
+	nextListed func() (*storage.ListedObject, error),
+	nextCataloged func() (*database.ObjectListByBucketIdAfterNameRow, error),
+	onOrphan func(object *storage.ListedObject) error,
+	onMissing func(object *database.ObjectListByBucketIdAfterNameRow) error,
+) error {
+	listed, err := nextListed()
+	if err != nil {
+		return err
+	}
+
+	cataloged, err := nextCataloged()
+	if err != nil {
+		return err
+	}
+
+	for listed != nil || cataloged != nil {
+		switch {
+		case cataloged == nil || (listed != nil && listed.Name < cataloged.Name):
+			if err = onOrphan(listed); err != nil {
+				return err
+			}
+			if listed, err = nextListed(); err != nil {
+				return err
+			}
+		case listed == nil || cataloged.Name < listed.Name:
+			if err = onMissing(cataloged); err != nil {
+				return err
+			}
+			if cataloged, err = nextCataloged(); err != nil {
+				return err
+			}
+		default:
+			if listed, err = nextListed(); err != nil {
+				return err
+			}
+			if cataloged, err = nextCataloged(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package reconciliation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/storage"
+)
+
+func listedPages(names ...[]string) func() (*storage.ListedObject, error) {
+	calls := 0
+	return pages(func(after string) ([]*storage.ListedObject, bool, error) {
+		if calls >= len(names) {
+			return nil, false, nil
+		}
+		page := make([]*storage.ListedObject, 0, len(names[calls]))
+		for _, name := range names[calls] {
+			page = append(page, &storage.ListedObject{Name: name})
+		}
+		calls++
+		return page, calls < len(names), nil
+	}, func(object *storage.ListedObject) string { return object.Name })
+}
+
+func catalogedPages(names ...[]string) func() (*database.ObjectListByBucketIdAfterNameRow, error) {
+	calls := 0
+	return pages(func(after string) ([]*database.ObjectListByBucketIdAfterNameRow, bool, error) {
+		if calls >= len(names) {
+			return nil, false, nil
+		}
+		page := make([]*database.ObjectListByBucketIdAfterNameRow, 0, len(names[calls]))
+		for _, name := range names[calls] {
+			page = append(page, &database.ObjectListByBucketIdAfterNameRow{Name: name})
+		}
+		calls++
+		return page, calls < len(names), nil
+	}, func(object *database.ObjectListByBucketIdAfterNameRow) string { return object.Name })
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name            string
+		listed          [][]string
+		cataloged       [][]string
+		expectedOrphans []string
+		expectedMissing []string
+	}{
+		{
+			name:      "In Sync",
+			listed:    [][]string{{"a.png", "b.png"}},
+			cataloged: [][]string{{"a.png", "b.png"}},
+		},
+		{
+			name:            "Orphans And Missing Across Pages",
+			listed:          [][]string{{"a.png", "c.png"}, {"d.png", "f.png"}},
+			cataloged:       [][]string{{"a.png", "b.png", "d.png"}, {"e.png"}},
+			expectedOrphans: []string{"c.png", "f.png"},
+			expectedMissing: []string{"b.png", "e.png"},
+		},
+		{
+			name:            "Empty Storage",
+			cataloged:       [][]string{{"a.png", "b.png"}},
+			expectedMissing: []string{"a.png", "b.png"},
+		},
+		{
+			name:            "Empty Catalog",
+			listed:          [][]string{{"A.png", "a.png"}},
+			expectedOrphans: []string{"A.png", "a.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var orphans, missing []string
+
+			err := merge(
+				listedPages(tt.listed...),
+				catalogedPages(tt.cataloged...),
+				func(object *storage.ListedObject) error {
+					orphans = append(orphans, object.Name)
+					return nil
+				},
+				func(object *database.ObjectListByBucketIdAfterNameRow) error {
+					missing = append(missing, object.Name)
+					return nil
+				},
+			)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOrphans, orphans)
+			assert.Equal(t, tt.expectedMissing, missing)
+		})
+	}
+}
+
+func TestMerge_StopsOnError(t *testing.T) {
+	listErr := errors.New("list failed")
+
+	err := merge(
+		func() (*storage.ListedObject, error) { return nil, listErr },
+		catalogedPages([]string{"a.png"}),
+		func(object *storage.ListedObject) error { return nil },
+		func(object *database.ObjectListByBucketIdAfterNameRow) error { return nil },
+	)
+
+	assert.ErrorIs(t, err, listErr)
+}
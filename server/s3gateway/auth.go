@@ -0,0 +1,365 @@
+package s3gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+const (
+	signingAlgorithm      = "AWS4-HMAC-SHA256"
+	signingTerminator     = "aws4_request"
+	signingService        = "s3"
+	amzDateFormat         = "20060102T150405Z"
+	scopeDateFormat       = "20060102"
+	maxClockSkew          = 15 * time.Minute
+	maxPresignedExpiry    = 7 * 24 * time.Hour
+	unsignedPayload       = "UNSIGNED-PAYLOAD"
+	streamingPayload      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingTrailer      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedBody = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptyPayloadHash      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// signature is a parsed sigv4 signature from either the authorization header or a presigned url
+type signature struct {
+	accessKeyId   string
+	scope         string
+	scopeDate     string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	expires       time.Duration
+	presigned     bool
+	payloadHash   string
+}
+
+// signingContext is what an authenticated request keeps to verify the signatures of streamed payload chunks
+type signingContext struct {
+	accessKeyId   string
+	signingKey    []byte
+	seedSignature string
+	amzDate       string
+	scope         string
+	payloadHash   string
+}
+
+// authenticate verifies the sigv4 signature of a request against the s3 credentials of the api key named by its
+// access key id. the payload itself is verified while it is read, see newPayloadReader
+func (g *Gateway) authenticate(ctx *fiber.Ctx) (*signingContext, error) {
+	sig, err := parseSignature(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig.region != g.region {
+		return nil, newS3Error("AuthorizationHeaderMalformed", fiber.StatusBadRequest, "the authorization header is malformed; the region '%s' is wrong; expecting '%s'", sig.region, g.region)
+	}
+
+	if sig.service != signingService {
+		return nil, newS3Error("AuthorizationHeaderMalformed", fiber.StatusBadRequest, "the authorization header is malformed; incorrect service '%s'. this endpoint belongs to '%s'", sig.service, signingService)
+	}
+
+	requestTime, err := time.Parse(amzDateFormat, sig.amzDate)
+	if err != nil {
+		return nil, errAccessDenied("x-amz-date '%s' must be in the iso 8601 basic format yyyymmddThhmmssZ", sig.amzDate)
+	}
+
+	if requestTime.Format(scopeDateFormat) != sig.scopeDate {
+		return nil, newS3Error("AuthorizationHeaderMalformed", fiber.StatusBadRequest, "the authorization header is malformed; credential scope date '%s' does not match the request date", sig.scopeDate)
+	}
+
+	now := g.now()
+
+	if sig.presigned {
+		if requestTime.Sub(now) > maxClockSkew {
+			return nil, errRequestTimeTooSkewed
+		}
+		if now.After(requestTime.Add(sig.expires)) {
+			return nil, errExpiredRequest
+		}
+	} else if requestTime.Sub(now) > maxClockSkew || now.Sub(requestTime) > maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	credentials, err := g.credentials.ValidateS3AccessKey(ctx.UserContext(), sig.accessKeyId)
+	if err != nil {
+		var srvError srverr.ServiceError
+		if errors.As(err, &srvError) && (errors.Is(srvError.ErrorCode, srverr.NotFoundError) || errors.Is(srvError.ErrorCode, srverr.InvalidInputError)) {
+			return nil, errInvalidAccessKeyId
+		}
+		return nil, err
+	}
+
+	signingKey := deriveSigningKey(credentials.SecretAccessKey, sig.scopeDate, sig.region, sig.service)
+
+	canonicalRequest, err := buildCanonicalRequest(ctx, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		sig.amzDate,
+		sig.scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	expected := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	return &signingContext{
+		accessKeyId:   sig.accessKeyId,
+		signingKey:    signingKey,
+		seedSignature: sig.signature,
+		amzDate:       sig.amzDate,
+		scope:         sig.scope,
+		payloadHash:   sig.payloadHash,
+	}, nil
+}
+
+func parseSignature(ctx *fiber.Ctx) (*signature, error) {
+	query := ctx.Request().URI().QueryArgs()
+
+	if algorithm := string(query.Peek("X-Amz-Algorithm")); algorithm != "" {
+		if algorithm != signingAlgorithm {
+			return nil, errInvalidArgument("x-amz-algorithm '%s' is not supported. only %s is supported", algorithm, signingAlgorithm)
+		}
+
+		sig := &signature{
+			signature:   string(query.Peek("X-Amz-Signature")),
+			amzDate:     string(query.Peek("X-Amz-Date")),
+			presigned:   true,
+			payloadHash: unsignedPayload,
+		}
+
+		if err := sig.parseCredential(string(query.Peek("X-Amz-Credential"))); err != nil {
+			return nil, err
+		}
+
+		sig.signedHeaders = strings.Split(string(query.Peek("X-Amz-SignedHeaders")), ";")
+
+		expires, err := strconv.ParseInt(string(query.Peek("X-Amz-Expires")), 10, 64)
+		if err != nil || expires < 1 || time.Duration(expires)*time.Second > maxPresignedExpiry {
+			return nil, errAccessDenied("x-amz-expires must be a number of seconds between 1 and %d", int64(maxPresignedExpiry.Seconds()))
+		}
+		sig.expires = time.Duration(expires) * time.Second
+
+		if payloadHash := string(query.Peek("X-Amz-Content-Sha256")); payloadHash != "" {
+			sig.payloadHash = payloadHash
+		}
+
+		if sig.signature == "" || sig.amzDate == "" {
+			return nil, errAccessDenied("query-string authentication requires the x-amz-signature and x-amz-date parameters")
+		}
+
+		return sig, nil
+	}
+
+	authorization := ctx.Get(fiber.HeaderAuthorization)
+	if authorization == "" {
+		return nil, errAccessDenied("anonymous access is not allowed. requests must be signed with signature version 4")
+	}
+
+	if !strings.HasPrefix(authorization, signingAlgorithm+" ") {
+		return nil, errInvalidRequest("the authorization mechanism you have provided is not supported. please use %s", signingAlgorithm)
+	}
+
+	sig := &signature{
+		amzDate:     ctx.Get("X-Amz-Date"),
+		payloadHash: ctx.Get("X-Amz-Content-Sha256"),
+	}
+
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, signingAlgorithm+" "), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "Credential":
+			if err := sig.parseCredential(value); err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+
+	if sig.accessKeyId == "" || len(sig.signedHeaders) == 0 || sig.signature == "" {
+		return nil, newS3Error("AuthorizationHeaderMalformed", fiber.StatusBadRequest, "the authorization header is malformed; it must contain credential, signed headers and signature")
+	}
+
+	if sig.amzDate == "" {
+		date, err := http.ParseTime(ctx.Get(fiber.HeaderDate))
+		if err != nil {
+			return nil, errAccessDenied("aws authentication requires a valid date or x-amz-date header")
+		}
+		sig.amzDate = date.UTC().Format(amzDateFormat)
+	}
+
+	if sig.payloadHash == "" {
+		return nil, errInvalidRequest("missing required header for this request: x-amz-content-sha256")
+	}
+
+	return sig, nil
+}
+
+func (s *signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != signingTerminator {
+		return newS3Error("AuthorizationHeaderMalformed", fiber.StatusBadRequest, "the authorization header is malformed; the credential '%s' is mal-formed; expecting '<access key id>/<yyyymmdd>/<region>/s3/aws4_request'", credential)
+	}
+
+	s.accessKeyId = parts[0]
+	s.scopeDate = parts[1]
+	s.region = parts[2]
+	s.service = parts[3]
+	s.scope = strings.Join(parts[1:], "/")
+
+	return nil
+}
+
+func buildCanonicalRequest(ctx *fiber.Ctx, sig *signature) (string, error) {
+	path, err := url.PathUnescape(string(ctx.Request().URI().PathOriginal()))
+	if err != nil {
+		return "", errInvalidArgument("the request path is not correctly escaped")
+	}
+
+	canonicalQuery, err := canonicalQueryString(string(ctx.Request().URI().QueryString()), sig.presigned)
+	if err != nil {
+		return "", err
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range sig.signedHeaders {
+		values := ctx.Request().Header.PeekAll(name)
+		// requests in absolute form carry the host in the request line instead of a host header
+		if strings.EqualFold(name, fiber.HeaderHost) {
+			values = [][]byte{ctx.Request().Host()}
+		}
+		if len(values) == 0 {
+			return "", errAccessDenied("signed header '%s' is missing from the request", name)
+		}
+
+		trimmed := make([]string, 0, len(values))
+		for _, value := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(string(value)), " "))
+		}
+
+		canonicalHeaders.WriteString(strings.ToLower(name))
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(strings.Join(trimmed, ","))
+		canonicalHeaders.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		ctx.Method(),
+		escapeURIComponent(path, false),
+		canonicalQuery,
+		canonicalHeaders.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash,
+	}, "\n"), nil
+}
+
+// canonicalQueryString decodes and re-encodes the query the way sigv4 does, the signature of a presigned url is not part
+// of what was signed
+func canonicalQueryString(rawQuery string, presigned bool) (string, error) {
+	if rawQuery == "" {
+		return "", nil
+	}
+
+	pairs := make([][2]string, 0)
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+
+		key, err := url.PathUnescape(rawKey)
+		if err != nil {
+			return "", errInvalidArgument("the query parameter '%s' is not correctly escaped", rawKey)
+		}
+
+		value, err := url.PathUnescape(rawValue)
+		if err != nil {
+			return "", errInvalidArgument("the value of query parameter '%s' is not correctly escaped", key)
+		}
+
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+
+		pairs = append(pairs, [2]string{escapeURIComponent(key, true), escapeURIComponent(value, true)})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] == pairs[j][0] {
+			return pairs[i][1] < pairs[j][1]
+		}
+		return pairs[i][0] < pairs[j][0]
+	})
+
+	encoded := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		encoded = append(encoded, pair[0]+"="+pair[1])
+	}
+
+	return strings.Join(encoded, "&"), nil
+}
+
+// escapeURIComponent percent encodes everything except the unreserved characters, slashes are kept in paths
+func escapeURIComponent(value string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			escaped.WriteByte(c)
+			continue
+		}
+		escaped.WriteByte('%')
+		escaped.WriteByte(hexDigits[c>>4])
+		escaped.WriteByte(hexDigits[c&15])
+	}
+
+	return escaped.String()
+}
+
+func deriveSigningKey(secretAccessKey string, scopeDate string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), []byte(scopeDate))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte(signingTerminator))
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
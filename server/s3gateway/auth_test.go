@@ -0,0 +1,240 @@
+package s3gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+)
+
+const (
+	testAccessKeyId     = "api_key_01HPG4GN5JY2Z6S0638ERSG375"
+	testSecretAccessKey = "c2f1a7b4e0d94f6a8d2b9e3c5a1f7d6e4b8c0a2f9e7d5c3b1a0f8e6d4c2b0a9f"
+	testRegion          = "us-east-1"
+)
+
+var testSigningTime = time.Date(2024, 2, 17, 6, 6, 9, 0, time.UTC)
+
+type fakeCredentials map[string]string
+
+func (f fakeCredentials) ValidateS3AccessKey(ctx context.Context, accessKeyId string) (*models.S3Credentials, error) {
+	secretAccessKey, ok := f[accessKeyId]
+	if !ok {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, "api key not found", "fakeCredentials.ValidateS3AccessKey", "", nil)
+	}
+
+	return &models.S3Credentials{AccessKeyId: accessKeyId, SecretAccessKey: secretAccessKey}, nil
+}
+
+// newAuthTestApp authenticates every request and echoes its verified payload
+func newAuthTestApp() *fiber.App {
+	gateway := &Gateway{
+		credentials: fakeCredentials{testAccessKeyId: testSecretAccessKey},
+		region:      testRegion,
+		now:         func() time.Time { return testSigningTime.Add(time.Minute) },
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.All("/*", func(ctx *fiber.Ctx) error {
+		signing, err := gateway.authenticate(ctx)
+		if err != nil {
+			return err
+		}
+
+		body, err := newPayloadReader(requestBody(ctx), signing)
+		if err != nil {
+			return err
+		}
+
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Send(content)
+	})
+
+	return app
+}
+
+func signRequest(t *testing.T, req *http.Request, secretAccessKey string, payloadHash string, signingTime time.Time) {
+	t.Helper()
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signer := v4.NewSigner(func(options *v4.SignerOptions) {
+		options.DisableURIPathEscaping = true
+	})
+
+	err := signer.SignHTTP(context.Background(), aws.Credentials{
+		AccessKeyID:     testAccessKeyId,
+		SecretAccessKey: secretAccessKey,
+	}, req, payloadHash, "s3", testRegion, signingTime)
+	require.NoError(t, err)
+}
+
+func errorCode(t *testing.T, res *http.Response) string {
+	t.Helper()
+
+	var response errorResponse
+	require.NoError(t, xml.NewDecoder(res.Body).Decode(&response))
+
+	return response.Code
+}
+
+func TestAuthenticate_HeaderSignature(t *testing.T) {
+	app := newAuthTestApp()
+
+	req := httptest.NewRequest(fiber.MethodGet, "http://localhost/photos/2024/summer%20trip/%C3%A4.jpg?list-type=2&prefix=a%2Fb&max-keys=10", nil)
+	signRequest(t, req, testSecretAccessKey, emptyPayloadHash, testSigningTime)
+
+	res, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+}
+
+func TestAuthenticate_SignedPayload(t *testing.T) {
+	app := newAuthTestApp()
+
+	req := httptest.NewRequest(fiber.MethodPut, "http://localhost/photos/a.txt", strings.NewReader("hello world"))
+	signRequest(t, req, testSecretAccessKey, hashHex([]byte("hello world")), testSigningTime)
+
+	res, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+
+	content, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(content))
+
+	req = httptest.NewRequest(fiber.MethodPut, "http://localhost/photos/a.txt", strings.NewReader("hello there"))
+	signRequest(t, req, testSecretAccessKey, hashHex([]byte("hello world")), testSigningTime)
+
+	res, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "XAmzContentSHA256Mismatch", errorCode(t, res))
+}
+
+func TestAuthenticate_Rejections(t *testing.T) {
+	app := newAuthTestApp()
+
+	tests := []struct {
+		name       string
+		sign       func(req *http.Request)
+		statusCode int
+		code       string
+	}{
+		{
+			name:       "anonymous",
+			sign:       func(req *http.Request) {},
+			statusCode: fiber.StatusForbidden,
+			code:       "AccessDenied",
+		},
+		{
+			name: "wrong secret",
+			sign: func(req *http.Request) {
+				signRequest(t, req, "wrong-secret", emptyPayloadHash, testSigningTime)
+			},
+			statusCode: fiber.StatusForbidden,
+			code:       "SignatureDoesNotMatch",
+		},
+		{
+			name: "tampered query",
+			sign: func(req *http.Request) {
+				signRequest(t, req, testSecretAccessKey, emptyPayloadHash, testSigningTime)
+				header := req.Header
+				*req = *httptest.NewRequest(fiber.MethodGet, "http://localhost/photos?list-type=2&prefix=other", nil)
+				req.Header = header
+			},
+			statusCode: fiber.StatusForbidden,
+			code:       "SignatureDoesNotMatch",
+		},
+		{
+			name: "unknown access key",
+			sign: func(req *http.Request) {
+				signRequest(t, req, testSecretAccessKey, emptyPayloadHash, testSigningTime)
+				req.Header.Set(fiber.HeaderAuthorization, strings.Replace(req.Header.Get(fiber.HeaderAuthorization), testAccessKeyId, "api_key_unknown", 1))
+			},
+			statusCode: fiber.StatusForbidden,
+			code:       "InvalidAccessKeyId",
+		},
+		{
+			name: "skewed clock",
+			sign: func(req *http.Request) {
+				signRequest(t, req, testSecretAccessKey, emptyPayloadHash, testSigningTime.Add(-time.Hour))
+			},
+			statusCode: fiber.StatusForbidden,
+			code:       "RequestTimeTooSkewed",
+		},
+		{
+			name: "signature version 2",
+			sign: func(req *http.Request) {
+				req.Header.Set(fiber.HeaderAuthorization, "AWS "+testAccessKeyId+":c2lnbmF0dXJl")
+			},
+			statusCode: fiber.StatusBadRequest,
+			code:       "InvalidRequest",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "http://localhost/photos?list-type=2&prefix=a", nil)
+			test.sign(req)
+
+			res, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, test.statusCode, res.StatusCode)
+			assert.Equal(t, test.code, errorCode(t, res))
+		})
+	}
+}
+
+func TestAuthenticate_PresignedUrl(t *testing.T) {
+	app := newAuthTestApp()
+
+	presign := func(signingTime time.Time) *http.Request {
+		req := httptest.NewRequest(fiber.MethodGet, "http://localhost/photos/a%20b.jpg?X-Amz-Expires=300", nil)
+
+		signedUrl, _, err := v4.NewSigner(func(options *v4.SignerOptions) {
+			options.DisableURIPathEscaping = true
+		}).PresignHTTP(context.Background(), aws.Credentials{
+			AccessKeyID:     testAccessKeyId,
+			SecretAccessKey: testSecretAccessKey,
+		}, req, unsignedPayload, "s3", testRegion, signingTime)
+		require.NoError(t, err)
+
+		return httptest.NewRequest(fiber.MethodGet, signedUrl, nil)
+	}
+
+	res, err := app.Test(presign(testSigningTime))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+
+	res, err = app.Test(presign(testSigningTime.Add(-10 * time.Minute)))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, res.StatusCode)
+	assert.Equal(t, "AccessDenied", errorCode(t, res))
+}
+
+func TestCanonicalQueryString(t *testing.T) {
+	canonical, err := canonicalQueryString("prefix=a%2Fb%20c&list-type=2&delimiter=%2F&uploads", false)
+	require.NoError(t, err)
+	assert.Equal(t, "delimiter=%2F&list-type=2&prefix=a%2Fb%20c&uploads=", canonical)
+
+	canonical, err = canonicalQueryString("X-Amz-Signature=abc&X-Amz-Date=20240217T060609Z", true)
+	require.NoError(t, err)
+	assert.Equal(t, "X-Amz-Date=20240217T060609Z", canonical)
+}
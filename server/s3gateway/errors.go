@@ -0,0 +1,127 @@
+package s3gateway
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/utils"
+)
+
+// S3Error is an error in the shape s3 clients understand, the code decides how clients retry or report it
+type S3Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newS3Error(code string, statusCode int, format string, args ...any) *S3Error {
+	return &S3Error{
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		StatusCode: statusCode,
+	}
+}
+
+func errAccessDenied(format string, args ...any) *S3Error {
+	return newS3Error("AccessDenied", fiber.StatusForbidden, format, args...)
+}
+
+func errInvalidArgument(format string, args ...any) *S3Error {
+	return newS3Error("InvalidArgument", fiber.StatusBadRequest, format, args...)
+}
+
+func errInvalidRequest(format string, args ...any) *S3Error {
+	return newS3Error("InvalidRequest", fiber.StatusBadRequest, format, args...)
+}
+
+func errNotImplemented(format string, args ...any) *S3Error {
+	return newS3Error("NotImplemented", fiber.StatusNotImplemented, format, args...)
+}
+
+var (
+	errSignatureDoesNotMatch    = newS3Error("SignatureDoesNotMatch", fiber.StatusForbidden, "the request signature we calculated does not match the signature you provided")
+	errInvalidAccessKeyId       = newS3Error("InvalidAccessKeyId", fiber.StatusForbidden, "the access key id you provided does not exist in our records")
+	errRequestTimeTooSkewed     = newS3Error("RequestTimeTooSkewed", fiber.StatusForbidden, "the difference between the request time and the server's time is too large")
+	errExpiredRequest           = newS3Error("AccessDenied", fiber.StatusForbidden, "request has expired")
+	errMissingContentLength     = newS3Error("MissingContentLength", fiber.StatusLengthRequired, "you must provide the content-length http header")
+	errContentSHA256Mismatch    = newS3Error("XAmzContentSHA256Mismatch", fiber.StatusBadRequest, "the provided x-amz-content-sha256 header does not match what was computed")
+	errIncompleteBody           = newS3Error("IncompleteBody", fiber.StatusBadRequest, "you did not provide the number of bytes specified by the content-length http header")
+	errMalformedXML             = newS3Error("MalformedXML", fiber.StatusBadRequest, "the xml you provided was not well-formed or did not validate against our published schema")
+	errMethodNotAllowed         = newS3Error("MethodNotAllowed", fiber.StatusMethodNotAllowed, "the specified method is not allowed against this resource")
+	errNoSuchBucket             = newS3Error("NoSuchBucket", fiber.StatusNotFound, "the specified bucket does not exist")
+	errNoSuchKey                = newS3Error("NoSuchKey", fiber.StatusNotFound, "the specified key does not exist")
+	errNoSuchUpload             = newS3Error("NoSuchUpload", fiber.StatusNotFound, "the specified multipart upload does not exist")
+	errInvalidRange             = newS3Error("InvalidRange", fiber.StatusRequestedRangeNotSatisfiable, "the requested range is not satisfiable")
+	errInvalidPart              = newS3Error("InvalidPart", fiber.StatusBadRequest, "one or more of the specified parts could not be found")
+	errInternalError            = newS3Error("InternalError", fiber.StatusInternalServerError, "we encountered an internal error, please try again")
+	errInvalidBucketName        = newS3Error("InvalidBucketName", fiber.StatusBadRequest, "the specified bucket is not valid")
+	errInvalidContinuationToken = newS3Error("InvalidArgument", fiber.StatusBadRequest, "the continuation token provided is incorrect")
+)
+
+// toS3Error maps service errors onto s3 errors, notFound is the error a not found service error stands for since
+// the services do not tell buckets, keys and uploads apart
+func toS3Error(err error, notFound *S3Error) *S3Error {
+	var s3Error *S3Error
+	if errors.As(err, &s3Error) {
+		return s3Error
+	}
+
+	var srvError srverr.ServiceError
+	if !errors.As(err, &srvError) {
+		return errInternalError
+	}
+
+	// a payload that fails verification while streaming into storage surfaces as the cause of the service error
+	if errors.As(srvError.InternalError, &s3Error) {
+		return s3Error
+	}
+
+	switch {
+	case errors.Is(srvError.InternalError, storage.ErrMultipartUploadNotFound):
+		return errNoSuchUpload
+	case errors.Is(srvError.InternalError, storage.ErrInvalidRange):
+		return errInvalidRange
+	case errors.Is(srvError.InternalError, storage.ErrInvalidPart):
+		return errInvalidPart
+	case errors.Is(srvError.ErrorCode, srverr.NotFoundError):
+		return notFound
+	case errors.Is(srvError.ErrorCode, srverr.InvalidInputError), errors.Is(srvError.ErrorCode, srverr.BadRequestError):
+		return errInvalidArgument("%s", srvError.Message)
+	case errors.Is(srvError.ErrorCode, srverr.ForbiddenError):
+		return errAccessDenied("%s", srvError.Message)
+	case errors.Is(srvError.ErrorCode, srverr.ConflictError):
+		return newS3Error("OperationAborted", fiber.StatusConflict, "%s", srvError.Message)
+	default:
+		return errInternalError
+	}
+}
+
+// ErrorHandler writes errors as s3 xml error documents, responses to head requests carry no body so only the status is set
+func ErrorHandler(ctx *fiber.Ctx, err error) error {
+	s3Error := toS3Error(err, errInternalError)
+
+	var fiberError *fiber.Error
+	if errors.As(err, &fiberError) {
+		s3Error = newS3Error("InvalidRequest", fiberError.Code, "%s", fiberError.Message)
+	}
+
+	ctx.Status(s3Error.StatusCode)
+
+	if ctx.Method() == fiber.MethodHead {
+		return nil
+	}
+
+	return writeXML(ctx, &errorResponse{
+		Code:      s3Error.Code,
+		Message:   s3Error.Message,
+		Resource:  ctx.Path(),
+		RequestId: utils.RequestId(ctx.Context()),
+	})
+}
@@ -0,0 +1,369 @@
+package s3gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/services"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"go.uber.org/zap"
+)
+
+// maxXMLBodySize bounds the xml documents clients send, a complete multipart upload with 10000 parts is about 1mb
+const maxXMLBodySize = 2 << 20
+
+// maxDeleteObjects is the most keys a single delete objects request may name
+const maxDeleteObjects = 1000
+
+// unsupportedSubresources are the query parameters selecting s3 apis the gateway does not implement, requests naming
+// them fail instead of being mistaken for plain object or bucket requests
+var unsupportedSubresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption", "intelligent-tiering", "inventory",
+	"legal-hold", "lifecycle", "logging", "metrics", "notification", "object-lock", "ownershipControls", "policy",
+	"policyStatus", "publicAccessBlock", "replication", "requestPayment", "restore", "retention", "select",
+	"tagging", "torrent", "versionId", "versioning", "versions", "website",
+}
+
+// CredentialsValidator resolves the access key id of a signed request to its s3 credentials
+type CredentialsValidator interface {
+	ValidateS3AccessKey(ctx context.Context, accessKeyId string) (*models.S3Credentials, error)
+}
+
+// Gateway serves the subset of the s3 rest api that tools like aws-cli and rclone need on top of the bucket and
+// object services, so bucket policies and the object catalog apply to s3 clients as they do to the http api.
+// only path style requests are supported, clients have to be configured to use them
+type Gateway struct {
+	bucketService *services.BucketService
+	objectService *services.ObjectService
+	credentials   CredentialsValidator
+	region        string
+	logger        *zap.Logger
+	now           func() time.Time
+}
+
+func NewGateway(bucketService *services.BucketService, objectService *services.ObjectService, credentials CredentialsValidator, config *config.Config, logger *zap.Logger) *Gateway {
+	return &Gateway{
+		bucketService: bucketService,
+		objectService: objectService,
+		credentials:   credentials,
+		region:        config.S3GatewayRegion,
+		logger:        logger,
+		now:           time.Now,
+	}
+}
+
+// RegisterRoutes routes every request through a single handler since s3 selects operations by method, path depth
+// and query parameters rather than by path
+func (g *Gateway) RegisterRoutes(app *fiber.App) {
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.Set("X-Amz-Request-Id", utils.RequestId(ctx.Context()))
+		return ctx.Next()
+	})
+
+	app.All("/*", g.HandleRequest)
+}
+
+func (g *Gateway) HandleRequest(ctx *fiber.Ctx) error {
+	signing, err := g.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	bucketName, key, err := splitPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	if subresource := findUnsupportedSubresource(ctx); subresource != "" {
+		return errNotImplemented("the '%s' subresource is not supported by this gateway", subresource)
+	}
+
+	method := ctx.Method()
+
+	if bucketName == "" {
+		if method != fiber.MethodGet {
+			return errMethodNotAllowed
+		}
+		return g.listBuckets(ctx, signing)
+	}
+
+	bucket, err := g.getBucket(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		switch {
+		case method == fiber.MethodHead:
+			return ctx.SendStatus(fiber.StatusOK)
+		case method == fiber.MethodGet && hasQuery(ctx, "location"):
+			return g.getBucketLocation(ctx)
+		case method == fiber.MethodGet && hasQuery(ctx, "uploads"):
+			return errNotImplemented("listing multipart uploads is not supported by this gateway")
+		case method == fiber.MethodGet:
+			return g.listObjects(ctx, bucket)
+		case method == fiber.MethodPost && hasQuery(ctx, "delete"):
+			return g.deleteObjects(ctx, bucket, signing)
+		case method == fiber.MethodPut, method == fiber.MethodDelete:
+			return errNotImplemented("buckets are managed through the http api")
+		default:
+			return errMethodNotAllowed
+		}
+	}
+
+	switch {
+	case method == fiber.MethodHead:
+		return g.headObject(ctx, bucket, key)
+	case method == fiber.MethodGet && hasQuery(ctx, "uploadId"):
+		return errNotImplemented("listing parts is not supported by this gateway")
+	case method == fiber.MethodGet:
+		return g.getObject(ctx, bucket, key)
+	case method == fiber.MethodPut && hasQuery(ctx, "uploadId"):
+		if ctx.Get("X-Amz-Copy-Source") != "" {
+			return errNotImplemented("copying parts is not supported by this gateway")
+		}
+		return g.uploadPart(ctx, bucket, key, signing)
+	case method == fiber.MethodPut && ctx.Get("X-Amz-Copy-Source") != "":
+		return g.copyObject(ctx, bucket, key)
+	case method == fiber.MethodPut:
+		return g.putObject(ctx, bucket, key, signing)
+	case method == fiber.MethodPost && hasQuery(ctx, "uploads"):
+		return g.createMultipartUpload(ctx, bucket, key)
+	case method == fiber.MethodPost && hasQuery(ctx, "uploadId"):
+		return g.completeMultipartUpload(ctx, bucket, key, signing)
+	case method == fiber.MethodDelete && hasQuery(ctx, "uploadId"):
+		return g.abortMultipartUpload(ctx, bucket, key)
+	case method == fiber.MethodDelete:
+		return g.deleteObject(ctx, bucket, key)
+	default:
+		return errMethodNotAllowed
+	}
+}
+
+func (g *Gateway) listBuckets(ctx *fiber.Ctx, signing *signingContext) error {
+	buckets, err := g.bucketService.ListAllBuckets(ctx.UserContext())
+	if err != nil && !isNotFound(err) {
+		return toS3Error(err, errInternalError)
+	}
+
+	result := &listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{
+			Id:          signing.accessKeyId,
+			DisplayName: signing.accessKeyId,
+		},
+		Buckets: make([]bucketResult, 0, len(buckets)),
+	}
+
+	for _, bucket := range buckets {
+		result.Buckets = append(result.Buckets, bucketResult{
+			Name:         bucket.Name,
+			CreationDate: formatTime(bucket.CreatedAt),
+		})
+	}
+
+	return writeXML(ctx, result)
+}
+
+func (g *Gateway) getBucketLocation(ctx *fiber.Ctx) error {
+	// us-east-1 is reported as an empty location constraint for historical reasons
+	location := g.region
+	if location == "us-east-1" {
+		location = ""
+	}
+
+	return writeXML(ctx, &locationConstraint{
+		Xmlns:    s3Namespace,
+		Location: location,
+	})
+}
+
+func (g *Gateway) listObjects(ctx *fiber.Ctx, bucket *models.Bucket) error {
+	prefix := ctx.Query("prefix")
+	delimiter := ctx.Query("delimiter")
+	encodingType := ctx.Query("encoding-type")
+
+	if encodingType != "" && encodingType != "url" {
+		return errInvalidArgument("invalid encoding method specified in request")
+	}
+
+	maxKeys := maxListKeys
+	if value := ctx.Query("max-keys"); value != "" {
+		parsed, err := parseNonNegativeInt(value)
+		if err != nil {
+			return errInvalidArgument("provided max-keys not an integer or within integer range")
+		}
+		maxKeys = min(parsed, maxListKeys)
+	}
+
+	fetch := func(fetchCtx context.Context, startAfter string, limit int32) ([]*models.Object, error) {
+		return g.objectService.ListObjectsByPrefix(fetchCtx, bucket.Id, prefix, startAfter, limit)
+	}
+
+	encode := func(value string) string {
+		if encodingType == "" {
+			return value
+		}
+		return strings.ReplaceAll(url.QueryEscape(value), "%2F", "/")
+	}
+
+	if ctx.Query("list-type") == "2" {
+		startAfter := ctx.Query("start-after")
+		continuationToken := ctx.Query("continuation-token")
+
+		position := startAfter
+		if continuationToken != "" {
+			decoded, err := decodeContinuationToken(continuationToken)
+			if err != nil {
+				return errInvalidContinuationToken
+			}
+			position = max(decoded, startAfter)
+		}
+
+		page, err := listObjects(ctx.UserContext(), fetch, prefix, delimiter, position, maxKeys)
+		if err != nil {
+			return toS3Error(err, errNoSuchBucket)
+		}
+
+		result := &listBucketV2Result{
+			Xmlns:             s3Namespace,
+			Name:              bucket.Name,
+			Prefix:            encode(prefix),
+			Delimiter:         encode(delimiter),
+			StartAfter:        encode(startAfter),
+			ContinuationToken: continuationToken,
+			EncodingType:      encodingType,
+			MaxKeys:           maxKeys,
+			KeyCount:          len(page.objects) + len(page.commonPrefixes),
+			IsTruncated:       page.truncated,
+			Contents:          toObjectResults(page.objects, encode),
+			CommonPrefixes:    toCommonPrefixResults(page.commonPrefixes, encode),
+		}
+
+		if page.truncated {
+			result.NextContinuationToken = encodeContinuationToken(page.last)
+		}
+
+		return writeXML(ctx, result)
+	}
+
+	marker := ctx.Query("marker")
+
+	page, err := listObjects(ctx.UserContext(), fetch, prefix, delimiter, marker, maxKeys)
+	if err != nil {
+		return toS3Error(err, errNoSuchBucket)
+	}
+
+	result := &listBucketV1Result{
+		Xmlns:          s3Namespace,
+		Name:           bucket.Name,
+		Prefix:         encode(prefix),
+		Delimiter:      encode(delimiter),
+		Marker:         encode(marker),
+		EncodingType:   encodingType,
+		MaxKeys:        maxKeys,
+		IsTruncated:    page.truncated,
+		Contents:       toObjectResults(page.objects, encode),
+		CommonPrefixes: toCommonPrefixResults(page.commonPrefixes, encode),
+	}
+
+	if page.truncated {
+		result.NextMarker = encode(page.last)
+	}
+
+	return writeXML(ctx, result)
+}
+
+func (g *Gateway) deleteObjects(ctx *fiber.Ctx, bucket *models.Bucket, signing *signingContext) error {
+	request := &deleteObjects{}
+	if err := readXML(ctx, signing, request); err != nil {
+		return err
+	}
+
+	if len(request.Objects) == 0 || len(request.Objects) > maxDeleteObjects {
+		return errMalformedXML
+	}
+
+	result := &deleteResult{Xmlns: s3Namespace}
+
+	for _, object := range request.Objects {
+		err := g.objectService.DeleteObjectByName(ctx.UserContext(), bucket.Id, object.Key)
+		if err != nil && !isNotFound(err) {
+			s3Error := toS3Error(err, errNoSuchKey)
+			result.Errors = append(result.Errors, deleteError{
+				Key:     object.Key,
+				Code:    s3Error.Code,
+				Message: s3Error.Message,
+			})
+			continue
+		}
+
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, object)
+		}
+	}
+
+	return writeXML(ctx, result)
+}
+
+func (g *Gateway) getBucket(ctx *fiber.Ctx, name string) (*models.Bucket, error) {
+	bucket, err := g.bucketService.GetBucketByName(ctx.UserContext(), name)
+	if err != nil {
+		return nil, toS3Error(err, errNoSuchBucket)
+	}
+
+	return bucket, nil
+}
+
+// splitPath splits a path style request path into the bucket name and the object key
+func splitPath(ctx *fiber.Ctx) (string, string, error) {
+	path, err := url.PathUnescape(string(ctx.Request().URI().PathOriginal()))
+	if err != nil {
+		return "", "", errInvalidArgument("the request path is not correctly escaped")
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+
+	if bucketName != "" && !models.IsValidBucketName(bucketName) {
+		return "", "", errInvalidBucketName
+	}
+
+	return bucketName, key, nil
+}
+
+func findUnsupportedSubresource(ctx *fiber.Ctx) string {
+	for _, subresource := range unsupportedSubresources {
+		if hasQuery(ctx, subresource) {
+			return subresource
+		}
+	}
+
+	return ""
+}
+
+func hasQuery(ctx *fiber.Ctx, key string) bool {
+	return ctx.Request().URI().QueryArgs().Has(key)
+}
+
+func isNotFound(err error) bool {
+	var srvError srverr.ServiceError
+	return errors.As(err, &srvError) && errors.Is(srvError.ErrorCode, srverr.NotFoundError)
+}
+
+// requestBody returns the streamed body of large requests and the buffered body of small ones
+func requestBody(ctx *fiber.Ctx) io.Reader {
+	if stream := ctx.Request().BodyStream(); stream != nil {
+		return stream
+	}
+
+	return bytes.NewReader(ctx.Request().Body())
+}
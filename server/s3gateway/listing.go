@@ -0,0 +1,89 @@
+package s3gateway
+
+import (
+	"context"
+	"strings"
+
+	"github.com/teapartydev/storage/server/models"
+)
+
+// maxListKeys is the most keys s3 returns in one listing page
+const maxListKeys = 1000
+
+// afterPrefix sorts after every valid utf-8 name starting with a common prefix, listing resumes from it to skip
+// the objects grouped into that prefix
+const afterPrefix = "\U0010FFFF"
+
+// listFetcher returns up to limit objects whose name sorts after startAfter in binary order
+type listFetcher func(ctx context.Context, startAfter string, limit int32) ([]*models.Object, error)
+
+type listPage struct {
+	objects        []*models.Object
+	commonPrefixes []string
+	truncated      bool
+	// last is the last key or common prefix of the page, listing continues after it
+	last string
+}
+
+// listObjects pages through the catalog and groups names containing the delimiter after the prefix into common
+// prefixes the way s3 does. keys and common prefixes together count towards maxKeys
+func listObjects(ctx context.Context, fetch listFetcher, prefix string, delimiter string, startAfter string, maxKeys int) (*listPage, error) {
+	page := &listPage{}
+	cursor := startAfter
+	count := 0
+
+	limit := int32(maxListKeys)
+	if maxKeys+1 < maxListKeys {
+		limit = int32(maxKeys + 1)
+	}
+
+	for {
+		objects, err := fetch(ctx, cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		jumped := false
+
+		for _, object := range objects {
+			cursor = object.Name
+
+			commonPrefix := ""
+			if delimiter != "" {
+				if index := strings.Index(object.Name[len(prefix):], delimiter); index >= 0 {
+					commonPrefix = object.Name[:len(prefix)+index+len(delimiter)]
+				}
+			}
+
+			// a page can end on a common prefix, the names under it are already covered by that page
+			if commonPrefix != "" && strings.HasPrefix(startAfter, commonPrefix) {
+				cursor = commonPrefix + afterPrefix
+				jumped = true
+				break
+			}
+
+			if count == maxKeys {
+				page.truncated = true
+				return page, nil
+			}
+
+			count++
+
+			if commonPrefix == "" {
+				page.objects = append(page.objects, object)
+				page.last = object.Name
+				continue
+			}
+
+			page.commonPrefixes = append(page.commonPrefixes, commonPrefix)
+			page.last = commonPrefix
+			cursor = commonPrefix + afterPrefix
+			jumped = true
+			break
+		}
+
+		if !jumped && int32(len(objects)) < limit {
+			return page, nil
+		}
+	}
+}
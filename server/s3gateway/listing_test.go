@@ -0,0 +1,117 @@
+package s3gateway
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teapartydev/storage/server/models"
+)
+
+// fakeCatalog answers listings the way ObjectListCompletedByBucketIdAndPrefix does, in binary order after startAfter
+func fakeCatalog(prefix string, names ...string) listFetcher {
+	sort.Strings(names)
+
+	return func(ctx context.Context, startAfter string, limit int32) ([]*models.Object, error) {
+		var objects []*models.Object
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) && name > startAfter && int32(len(objects)) < limit {
+				objects = append(objects, &models.Object{Name: name})
+			}
+		}
+		return objects, nil
+	}
+}
+
+func names(objects []*models.Object) []string {
+	var result []string
+	for _, object := range objects {
+		result = append(result, object.Name)
+	}
+	return result
+}
+
+var catalogNames = []string{
+	"a.txt",
+	"photos/2023/a.jpg",
+	"photos/2023/b.jpg",
+	"photos/2024/a.jpg",
+	"photos/cover.jpg",
+	"videos/a.mp4",
+	"z.txt",
+}
+
+func TestListObjects_WithoutDelimiter(t *testing.T) {
+	page, err := listObjects(context.Background(), fakeCatalog("photos/", catalogNames...), "photos/", "", "", 1000)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"photos/2023/a.jpg", "photos/2023/b.jpg", "photos/2024/a.jpg", "photos/cover.jpg"}, names(page.objects))
+	assert.Empty(t, page.commonPrefixes)
+	assert.False(t, page.truncated)
+}
+
+func TestListObjects_GroupsCommonPrefixes(t *testing.T) {
+	page, err := listObjects(context.Background(), fakeCatalog("", catalogNames...), "", "/", "", 1000)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a.txt", "z.txt"}, names(page.objects))
+	assert.Equal(t, []string{"photos/", "videos/"}, page.commonPrefixes)
+	assert.False(t, page.truncated)
+
+	page, err = listObjects(context.Background(), fakeCatalog("photos/", catalogNames...), "photos/", "/", "", 1000)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"photos/cover.jpg"}, names(page.objects))
+	assert.Equal(t, []string{"photos/2023/", "photos/2024/"}, page.commonPrefixes)
+}
+
+func TestListObjects_PagesAcrossCommonPrefixes(t *testing.T) {
+	fetch := fakeCatalog("", catalogNames...)
+
+	var keys []string
+	position := ""
+
+	for pages := 0; pages < 10; pages++ {
+		page, err := listObjects(context.Background(), fetch, "", "/", position, 1)
+		require.NoError(t, err)
+
+		keys = append(keys, names(page.objects)...)
+		keys = append(keys, page.commonPrefixes...)
+
+		if !page.truncated {
+			break
+		}
+		position = page.last
+	}
+
+	assert.Equal(t, []string{"a.txt", "photos/", "videos/", "z.txt"}, keys)
+}
+
+func TestListObjects_Truncates(t *testing.T) {
+	page, err := listObjects(context.Background(), fakeCatalog("", catalogNames...), "", "", "", 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a.txt", "photos/2023/a.jpg", "photos/2023/b.jpg"}, names(page.objects))
+	assert.True(t, page.truncated)
+	assert.Equal(t, "photos/2023/b.jpg", page.last)
+
+	page, err = listObjects(context.Background(), fakeCatalog("", catalogNames...), "", "", "", len(catalogNames))
+	require.NoError(t, err)
+
+	assert.Len(t, page.objects, len(catalogNames))
+	assert.False(t, page.truncated, "a page ending exactly on the last key is not truncated")
+}
+
+func TestContinuationToken(t *testing.T) {
+	token := encodeContinuationToken("photos/2023/ä b.jpg")
+
+	decoded, err := decodeContinuationToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/2023/ä b.jpg", decoded)
+
+	_, err = decodeContinuationToken("not a token!")
+	assert.Error(t, err)
+}
@@ -0,0 +1,385 @@
+package s3gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teapartydev/storage/server/models"
+)
+
+const metadataHeaderPrefix = "x-amz-meta-"
+
+func (g *Gateway) headObject(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	object, err := g.objectService.StatObject(ctx.UserContext(), bucket.Id, key)
+	if err != nil {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	setObjectHeaders(ctx, object)
+	ctx.Response().Header.SetContentLength(int(object.Size))
+
+	return nil
+}
+
+func (g *Gateway) getObject(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	var byteRange *string
+	if value := ctx.Get(fiber.HeaderRange); value != "" {
+		byteRange = &value
+	}
+
+	content, err := g.objectService.OpenObject(ctx.UserContext(), bucket.Id, key, byteRange)
+	if err != nil {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	setObjectHeaders(ctx, &content.StoredObject)
+
+	if content.ContentRange != nil {
+		ctx.Set(fiber.HeaderContentRange, *content.ContentRange)
+		ctx.Status(fiber.StatusPartialContent)
+	}
+
+	// fasthttp closes the body once it has been written to the connection
+	ctx.Response().SetBodyStream(content.Body, int(content.ContentLength))
+
+	return nil
+}
+
+func (g *Gateway) putObject(ctx *fiber.Ctx, bucket *models.Bucket, key string, signing *signingContext) error {
+	size, err := contentLength(ctx, signing)
+	if err != nil {
+		return err
+	}
+
+	body, err := newPayloadReader(requestBody(ctx), signing)
+	if err != nil {
+		return err
+	}
+
+	object, err := g.objectService.PutObject(ctx.UserContext(), &models.ObjectPut{
+		BucketId: bucket.Id,
+		Name:     key,
+		MimeType: requestMimeType(ctx),
+		Size:     size,
+		Metadata: requestMetadata(ctx),
+		Content:  body,
+	})
+	if err != nil {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	ctx.Set(fiber.HeaderETag, object.ETag)
+
+	return nil
+}
+
+func (g *Gateway) copyObject(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	copySource, err := url.PathUnescape(ctx.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return errInvalidArgument("x-amz-copy-source is not correctly escaped")
+	}
+
+	if strings.Contains(copySource, "?versionId=") {
+		return errNotImplemented("copying object versions is not supported by this gateway")
+	}
+
+	sourceBucketName, sourceKey, found := strings.Cut(strings.TrimPrefix(copySource, "/"), "/")
+	if !found || sourceBucketName == "" || sourceKey == "" {
+		return errInvalidArgument("x-amz-copy-source must be in the format <bucket>/<key>")
+	}
+
+	sourceBucket, err := g.getBucket(ctx, sourceBucketName)
+	if err != nil {
+		return err
+	}
+
+	objectCopy := &models.ObjectCopy{
+		SourceBucketId:      sourceBucket.Id,
+		SourceName:          sourceKey,
+		DestinationBucketId: bucket.Id,
+		DestinationName:     key,
+	}
+
+	switch directive := ctx.Get("X-Amz-Metadata-Directive"); directive {
+	case "", "COPY":
+	case "REPLACE":
+		objectCopy.ReplaceMetadata = true
+		objectCopy.MimeType = requestMimeType(ctx)
+		objectCopy.Metadata = requestMetadata(ctx)
+	default:
+		return errInvalidArgument("unknown metadata directive '%s'", directive)
+	}
+
+	object, err := g.objectService.CopyObject(ctx.UserContext(), objectCopy)
+	if err != nil {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	return writeXML(ctx, &copyObjectResult{
+		Xmlns:        s3Namespace,
+		LastModified: formatTime(lastModified(&object.Object)),
+		ETag:         object.ETag,
+	})
+}
+
+func (g *Gateway) deleteObject(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	// deleting a key that does not exist succeeds in s3
+	err := g.objectService.DeleteObjectByName(ctx.UserContext(), bucket.Id, key)
+	if err != nil && !isNotFound(err) {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (g *Gateway) createMultipartUpload(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	uploadId, err := g.objectService.CreateMultipartUpload(ctx.UserContext(), &models.MultipartUploadCreate{
+		BucketId: bucket.Id,
+		Name:     key,
+		MimeType: requestMimeType(ctx),
+		Metadata: requestMetadata(ctx),
+	})
+	if err != nil {
+		return toS3Error(err, errNoSuchKey)
+	}
+
+	return writeXML(ctx, &initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket.Name,
+		Key:      key,
+		UploadId: uploadId,
+	})
+}
+
+func (g *Gateway) uploadPart(ctx *fiber.Ctx, bucket *models.Bucket, key string, signing *signingContext) error {
+	partNumber, err := parseNonNegativeInt(ctx.Query("partNumber"))
+	if err != nil || partNumber > 10000 {
+		return errInvalidArgument("part number must be an integer between 1 and 10000, inclusive")
+	}
+
+	size, err := contentLength(ctx, signing)
+	if err != nil {
+		return err
+	}
+
+	body, err := newPayloadReader(requestBody(ctx), signing)
+	if err != nil {
+		return err
+	}
+
+	eTag, err := g.objectService.UploadPart(ctx.UserContext(), &models.MultipartUploadPart{
+		BucketId:   bucket.Id,
+		Name:       key,
+		UploadId:   ctx.Query("uploadId"),
+		PartNumber: int32(partNumber),
+		Size:       size,
+		Content:    body,
+	})
+	if err != nil {
+		return toS3Error(err, errNoSuchUpload)
+	}
+
+	ctx.Set(fiber.HeaderETag, eTag)
+
+	return nil
+}
+
+func (g *Gateway) completeMultipartUpload(ctx *fiber.Ctx, bucket *models.Bucket, key string, signing *signingContext) error {
+	request := &completeMultipartUpload{}
+	if err := readXML(ctx, signing, request); err != nil {
+		return err
+	}
+
+	parts := make([]models.MultipartUploadCompletedPart, 0, len(request.Parts))
+	for _, part := range request.Parts {
+		parts = append(parts, models.MultipartUploadCompletedPart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	object, err := g.objectService.CompleteMultipartUpload(ctx.UserContext(), &models.MultipartUploadComplete{
+		BucketId: bucket.Id,
+		Name:     key,
+		UploadId: ctx.Query("uploadId"),
+		Parts:    parts,
+	})
+	if err != nil {
+		return toS3Error(err, errNoSuchUpload)
+	}
+
+	return writeXML(ctx, &completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + bucket.Name + "/" + key,
+		Bucket:   bucket.Name,
+		Key:      key,
+		ETag:     object.ETag,
+	})
+}
+
+func (g *Gateway) abortMultipartUpload(ctx *fiber.Ctx, bucket *models.Bucket, key string) error {
+	err := g.objectService.AbortMultipartUpload(ctx.UserContext(), bucket.Id, key, ctx.Query("uploadId"))
+	if err != nil {
+		return toS3Error(err, errNoSuchUpload)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func setObjectHeaders(ctx *fiber.Ctx, object *models.StoredObject) {
+	ctx.Set(fiber.HeaderContentType, object.MimeType)
+	ctx.Set(fiber.HeaderETag, object.ETag)
+	ctx.Set(fiber.HeaderLastModified, lastModified(&object.Object).UTC().Format(http.TimeFormat))
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+
+	for key, value := range object.Metadata {
+		if stringValue, ok := value.(string); ok {
+			ctx.Set(metadataHeaderPrefix+key, stringValue)
+			continue
+		}
+		if valueBytes, err := json.Marshal(value); err == nil {
+			ctx.Set(metadataHeaderPrefix+key, string(valueBytes))
+		}
+	}
+}
+
+func lastModified(object *models.Object) time.Time {
+	if object.UpdatedAt != nil {
+		return *object.UpdatedAt
+	}
+
+	return object.CreatedAt
+}
+
+// contentLength is the size of the object in the body, aws-chunked bodies carry it in x-amz-decoded-content-length
+// since their content length includes the chunk framing
+func contentLength(ctx *fiber.Ctx, signing *signingContext) (int64, error) {
+	if strings.HasPrefix(signing.payloadHash, "STREAMING-") {
+		size, err := strconv.ParseInt(ctx.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || size < 0 {
+			return 0, errMissingContentLength
+		}
+		return size, nil
+	}
+
+	size := ctx.Request().Header.ContentLength()
+	if size < 0 {
+		return 0, errMissingContentLength
+	}
+
+	return int64(size), nil
+}
+
+func requestMimeType(ctx *fiber.Ctx) *string {
+	mimeType := ctx.Get(fiber.HeaderContentType)
+	if mimeType == "" {
+		return nil
+	}
+
+	return &mimeType
+}
+
+func requestMetadata(ctx *fiber.Ctx) map[string]any {
+	var metadata map[string]any
+
+	ctx.Request().Header.VisitAll(func(key []byte, value []byte) {
+		name := strings.ToLower(string(key))
+		if !strings.HasPrefix(name, metadataHeaderPrefix) {
+			return
+		}
+
+		if metadata == nil {
+			metadata = make(map[string]any)
+		}
+		metadata[strings.TrimPrefix(name, metadataHeaderPrefix)] = string(value)
+	})
+
+	return metadata
+}
+
+func readXML(ctx *fiber.Ctx, signing *signingContext, value any) error {
+	body, err := newPayloadReader(requestBody(ctx), signing)
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(io.LimitReader(body, maxXMLBodySize+1))
+	if err != nil {
+		var s3Error *S3Error
+		if errors.As(err, &s3Error) {
+			return s3Error
+		}
+		return errIncompleteBody
+	}
+
+	if len(content) > maxXMLBodySize {
+		return errMalformedXML
+	}
+
+	if err = xml.Unmarshal(content, value); err != nil {
+		return errMalformedXML
+	}
+
+	return nil
+}
+
+func toObjectResults(objects []*models.Object, encode func(string) string) []objectResult {
+	results := make([]objectResult, 0, len(objects))
+
+	for _, object := range objects {
+		results = append(results, objectResult{
+			Key:          encode(object.Name),
+			LastModified: formatTime(lastModified(object)),
+			Size:         object.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+
+	return results
+}
+
+func toCommonPrefixResults(commonPrefixes []string, encode func(string) string) []commonPrefixResult {
+	results := make([]commonPrefixResult, 0, len(commonPrefixes))
+
+	for _, commonPrefix := range commonPrefixes {
+		results = append(results, commonPrefixResult{Prefix: encode(commonPrefix)})
+	}
+
+	return results
+}
+
+// continuation tokens are opaque to clients, they encode the last key or common prefix of the previous page
+func encodeContinuationToken(last string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(last))
+}
+
+func decodeContinuationToken(token string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decoded), nil
+}
+
+func parseNonNegativeInt(value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if parsed < 0 {
+		return 0, strconv.ErrRange
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,251 @@
+package s3gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxChunkHeaderLength bounds the chunk header lines of aws-chunked payloads, real headers are below 100 bytes
+const maxChunkHeaderLength = 4096
+
+// newPayloadReader wraps the request body so the payload is verified against the x-amz-content-sha256 mode it was
+// signed with while it streams into storage. a mismatch surfaces as a read error which aborts the upload
+func newPayloadReader(body io.Reader, signing *signingContext) (io.Reader, error) {
+	switch signing.payloadHash {
+	case unsignedPayload:
+		return body, nil
+	case streamingPayload:
+		return newChunkedReader(body, signing), nil
+	case streamingUnsignedBody:
+		return newChunkedReader(body, nil), nil
+	case streamingTrailer:
+		return nil, errNotImplemented("signed trailing checksums are not supported, disable request checksums in the client")
+	}
+
+	expected, err := hex.DecodeString(signing.payloadHash)
+	if err != nil || len(expected) != sha256.Size {
+		return nil, errInvalidArgument("x-amz-content-sha256 must be %s, %s, %s or the hex encoded sha256 of the payload", unsignedPayload, streamingPayload, streamingUnsignedBody)
+	}
+
+	return &hashingReader{reader: body, hash: sha256.New(), expected: expected}, nil
+}
+
+// hashingReader fails the last read when the payload does not match the signed sha256
+type hashingReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF && !bytes.Equal(r.hash.Sum(nil), r.expected) {
+		return n, errContentSHA256Mismatch
+	}
+
+	return n, err
+}
+
+// chunkedReader decodes aws-chunked payloads. when signing is set every chunk signature is verified, each one chains
+// off the previous signature starting with the signature of the request itself
+type chunkedReader struct {
+	reader            *bufio.Reader
+	signing           *signingContext
+	previousSignature string
+	chunkSignature    string
+	chunkHash         hash.Hash
+	remaining         int64
+	done              bool
+	err               error
+}
+
+func newChunkedReader(body io.Reader, signing *signingContext) *chunkedReader {
+	r := &chunkedReader{
+		reader:    bufio.NewReader(body),
+		signing:   signing,
+		chunkHash: sha256.New(),
+	}
+
+	if signing != nil {
+		r.previousSignature = signing.seedSignature
+	}
+
+	return r
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.done {
+		return 0, io.EOF
+	}
+
+	if r.remaining == 0 {
+		if r.err = r.readChunkHeader(); r.err != nil {
+			return 0, r.err
+		}
+
+		if r.done {
+			return 0, io.EOF
+		}
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.chunkHash.Write(p[:n])
+	r.remaining -= int64(n)
+
+	if err == io.EOF {
+		if r.remaining > 0 {
+			r.err = errIncompleteBody
+			return n, r.err
+		}
+		err = nil
+	}
+	if err != nil {
+		r.err = err
+		return n, err
+	}
+
+	if r.remaining == 0 {
+		if r.err = r.finishChunk(); r.err != nil {
+			return n, r.err
+		}
+	}
+
+	return n, nil
+}
+
+func (r *chunkedReader) readChunkHeader() error {
+	line, err := r.readLine()
+	if err == io.EOF {
+		return errIncompleteBody
+	}
+	if err != nil {
+		return err
+	}
+
+	sizeField, extension, _ := strings.Cut(line, ";")
+
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return errInvalidArgument("aws-chunked payload has an invalid chunk size '%s'", sizeField)
+	}
+
+	if r.signing != nil {
+		key, value, _ := strings.Cut(extension, "=")
+		if key != "chunk-signature" || value == "" {
+			return errSignatureDoesNotMatch
+		}
+		r.chunkSignature = value
+	}
+
+	r.remaining = size
+	r.chunkHash.Reset()
+
+	if size > 0 {
+		return nil
+	}
+
+	if err = r.verifyChunk(); err != nil {
+		return err
+	}
+
+	// the final chunk is followed by optional trailers and an empty line
+	for {
+		line, err = r.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+	}
+
+	r.done = true
+
+	return nil
+}
+
+func (r *chunkedReader) finishChunk() error {
+	line, err := r.readLine()
+	if err == io.EOF {
+		return errIncompleteBody
+	}
+	if err != nil {
+		return err
+	}
+
+	if line != "" {
+		return errIncompleteBody
+	}
+
+	return r.verifyChunk()
+}
+
+func (r *chunkedReader) verifyChunk() error {
+	if r.signing == nil {
+		return nil
+	}
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm + "-PAYLOAD",
+		r.signing.amzDate,
+		r.signing.scope,
+		r.previousSignature,
+		emptyPayloadHash,
+		hex.EncodeToString(r.chunkHash.Sum(nil)),
+	}, "\n")
+
+	expected := hex.EncodeToString(hmacSHA256(r.signing.signingKey, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(r.chunkSignature)) {
+		return errSignatureDoesNotMatch
+	}
+
+	r.previousSignature = r.chunkSignature
+
+	return nil
+}
+
+func (r *chunkedReader) readLine() (string, error) {
+	var line []byte
+
+	for {
+		fragment, isPrefix, err := r.reader.ReadLine()
+		if err == io.EOF && len(line) > 0 {
+			return "", errIncompleteBody
+		}
+		if err == io.EOF {
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		line = append(line, fragment...)
+		if len(line) > maxChunkHeaderLength {
+			return "", errInvalidArgument("aws-chunked payload has a chunk header longer than %d bytes", maxChunkHeaderLength)
+		}
+
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package s3gateway
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the signatures below are the streaming upload example from the aws sigv4 documentation
+const (
+	exampleSecretAccessKey = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	exampleSeedSignature   = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+	exampleChunkSignature1 = "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"
+	exampleChunkSignature2 = "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"
+	exampleFinalSignature  = "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"
+)
+
+func exampleSigningContext() *signingContext {
+	return &signingContext{
+		signingKey:    deriveSigningKey(exampleSecretAccessKey, "20130524", "us-east-1", "s3"),
+		seedSignature: exampleSeedSignature,
+		amzDate:       "20130524T000000Z",
+		scope:         "20130524/us-east-1/s3/aws4_request",
+		payloadHash:   streamingPayload,
+	}
+}
+
+func exampleChunkedBody(finalSignature string) string {
+	return fmt.Sprintf("10000;chunk-signature=%s\r\n%s\r\n400;chunk-signature=%s\r\n%s\r\n0;chunk-signature=%s\r\n\r\n",
+		exampleChunkSignature1, strings.Repeat("a", 65536),
+		exampleChunkSignature2, strings.Repeat("a", 1024),
+		finalSignature,
+	)
+}
+
+func TestChunkedReader_VerifiesChunkSignatures(t *testing.T) {
+	reader, err := newPayloadReader(strings.NewReader(exampleChunkedBody(exampleFinalSignature)), exampleSigningContext())
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 66560), string(content))
+}
+
+func TestChunkedReader_RejectsTamperedChunk(t *testing.T) {
+	body := strings.Replace(exampleChunkedBody(exampleFinalSignature), "aaaa", "aaab", 1)
+
+	reader, err := newPayloadReader(strings.NewReader(body), exampleSigningContext())
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(reader)
+	assert.Equal(t, errSignatureDoesNotMatch, err)
+}
+
+func TestChunkedReader_RejectsWrongFinalSignature(t *testing.T) {
+	reader, err := newPayloadReader(strings.NewReader(exampleChunkedBody(exampleChunkSignature2)), exampleSigningContext())
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(reader)
+	assert.Equal(t, errSignatureDoesNotMatch, err)
+}
+
+func TestChunkedReader_RejectsTruncatedBody(t *testing.T) {
+	body := exampleChunkedBody(exampleFinalSignature)
+
+	reader, err := newPayloadReader(strings.NewReader(body[:1000]), exampleSigningContext())
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(reader)
+	assert.Equal(t, errIncompleteBody, err)
+}
+
+func TestChunkedReader_DecodesUnsignedPayloadWithTrailer(t *testing.T) {
+	body := "5\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n"
+
+	reader, err := newPayloadReader(strings.NewReader(body), &signingContext{payloadHash: streamingUnsignedBody})
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(content))
+}
+
+func TestHashingReader(t *testing.T) {
+	signing := &signingContext{payloadHash: hashHex([]byte("hello world"))}
+
+	reader, err := newPayloadReader(strings.NewReader("hello world"), signing)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(content))
+
+	reader, err = newPayloadReader(strings.NewReader("hello there"), signing)
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(reader)
+	assert.Equal(t, errContentSHA256Mismatch, err)
+}
+
+func TestNewPayloadReader_RejectsUnsupportedModes(t *testing.T) {
+	_, err := newPayloadReader(strings.NewReader(""), &signingContext{payloadHash: streamingTrailer})
+	assert.ErrorContains(t, err, "NotImplemented")
+
+	_, err = newPayloadReader(strings.NewReader(""), &signingContext{payloadHash: "not-a-hash"})
+	assert.ErrorContains(t, err, "InvalidArgument")
+}
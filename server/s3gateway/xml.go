@@ -0,0 +1,160 @@
+package s3gateway
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// s3 serializes every timestamp in responses bodies as iso 8601 with milliseconds in utc
+const s3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestId string   `xml:"RequestId"`
+}
+
+type owner struct {
+	Id          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name       `xml:"ListAllMyBucketsResult"`
+	Xmlns   string         `xml:"xmlns,attr"`
+	Owner   owner          `xml:"Owner"`
+	Buckets []bucketResult `xml:"Buckets>Bucket"`
+}
+
+type bucketResult struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:",chardata"`
+}
+
+type objectResult struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefixResult struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketV2Result struct {
+	XMLName               xml.Name             `xml:"ListBucketResult"`
+	Xmlns                 string               `xml:"xmlns,attr"`
+	Name                  string               `xml:"Name"`
+	Prefix                string               `xml:"Prefix"`
+	Delimiter             string               `xml:"Delimiter,omitempty"`
+	StartAfter            string               `xml:"StartAfter,omitempty"`
+	ContinuationToken     string               `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string               `xml:"NextContinuationToken,omitempty"`
+	EncodingType          string               `xml:"EncodingType,omitempty"`
+	MaxKeys               int                  `xml:"MaxKeys"`
+	KeyCount              int                  `xml:"KeyCount"`
+	IsTruncated           bool                 `xml:"IsTruncated"`
+	Contents              []objectResult       `xml:"Contents"`
+	CommonPrefixes        []commonPrefixResult `xml:"CommonPrefixes"`
+}
+
+type listBucketV1Result struct {
+	XMLName        xml.Name             `xml:"ListBucketResult"`
+	Xmlns          string               `xml:"xmlns,attr"`
+	Name           string               `xml:"Name"`
+	Prefix         string               `xml:"Prefix"`
+	Delimiter      string               `xml:"Delimiter,omitempty"`
+	Marker         string               `xml:"Marker"`
+	NextMarker     string               `xml:"NextMarker,omitempty"`
+	EncodingType   string               `xml:"EncodingType,omitempty"`
+	MaxKeys        int                  `xml:"MaxKeys"`
+	IsTruncated    bool                 `xml:"IsTruncated"`
+	Contents       []objectResult       `xml:"Contents"`
+	CommonPrefixes []commonPrefixResult `xml:"CommonPrefixes"`
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadId string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+type completePart struct {
+	PartNumber int32  `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+type deleteObjects struct {
+	XMLName xml.Name       `xml:"Delete"`
+	Quiet   bool           `xml:"Quiet"`
+	Objects []deleteObject `xml:"Object"`
+}
+
+type deleteObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name       `xml:"DeleteResult"`
+	Xmlns   string         `xml:"xmlns,attr"`
+	Deleted []deleteObject `xml:"Deleted"`
+	Errors  []deleteError  `xml:"Error"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(s3TimeFormat)
+}
+
+func writeXML(ctx *fiber.Ctx, value any) error {
+	body, err := xml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationXML)
+
+	return ctx.Send(append([]byte(xml.Header), body...))
+}
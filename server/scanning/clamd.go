@@ -0,0 +1,115 @@
+package scanning
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks content is streamed to clamd in, it must stay below the StreamMaxLength
+// clamd is configured with
+const clamdChunkSize = 64 * 1024
+
+// ErrClamdSizeLimit is returned when content is larger than clamd accepts, clamd has to be configured with a larger
+// StreamMaxLength to scan it
+var ErrClamdSizeLimit = errors.New("content exceeds the clamd stream size limit")
+
+// ClamdScanner scans content with a clamd daemon over tcp using the INSTREAM command
+type ClamdScanner struct {
+	address string
+	timeout time.Duration
+}
+
+func NewClamdScanner(address string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, content io.Reader) (*Result, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// clamd answers as soon as it goes over its size limit and closes the connection, the reply is read even when
+	// streaming fails so that case is reported as such instead of as a broken pipe
+	streamErr := streamToClamd(conn, content)
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		if streamErr != nil {
+			return nil, fmt.Errorf("failed to stream content to clamd: %w", streamErr)
+		}
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamdReply(strings.TrimRight(reply, "\x00"))
+}
+
+func streamToClamd(conn net.Conn, content io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(content, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, writeErr := conn.Write(chunk[:4+n]); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// a zero length chunk ends the stream
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseClamdReply reads replies of the form "stream: OK", "stream: <signature> FOUND" and "<message> ERROR"
+func parseClamdReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(reply)
+
+	switch {
+	case strings.HasSuffix(reply, "ERROR"):
+		if strings.Contains(reply, "size limit exceeded") {
+			return nil, ErrClamdSizeLimit
+		}
+		return nil, fmt.Errorf("clamd failed to scan content: %s", reply)
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if _, after, ok := strings.Cut(signature, ": "); ok {
+			signature = after
+		}
+		return &Result{Infected: true, Signature: signature}, nil
+	case strings.HasSuffix(reply, ": OK"):
+		return &Result{}, nil
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+}
@@ -0,0 +1,34 @@
+package scanning
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// Eicar is the standard antivirus test file, every scanner reports it as infected without it being harmful
+const Eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// EicarSignature is the signature clamd reports for the eicar test file
+const EicarSignature = "Eicar-Test-Signature"
+
+// FakeScanner stands in for clamd where none runs, such as in tests and local development. it reports content
+// holding the eicar test string as infected and everything else as clean
+type FakeScanner struct{}
+
+func NewFakeScanner() *FakeScanner {
+	return &FakeScanner{}
+}
+
+func (*FakeScanner) Scan(ctx context.Context, content io.Reader) (*Result, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Contains(data, []byte(Eicar)) {
+		return &Result{Infected: true, Signature: EicarSignature}, nil
+	}
+
+	return &Result{}, nil
+}
@@ -0,0 +1,18 @@
+package scanning
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the verdict of a scan, Signature names the malware found in infected content
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner scans object content for malware. an error means the content could not be scanned, not that it is
+// infected, so the scan can be retried
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (*Result, error)
+}
@@ -0,0 +1,157 @@
+package scanning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd accepts INSTREAM sessions and answers them with reply, the streamed content is sent on received unless
+// the previous content was not taken yet
+func fakeClamd(t *testing.T, reply func(content []byte) string) (string, <-chan []byte) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+			command, err := reader.ReadString(0)
+			if err != nil || command != "zINSTREAM\x00" {
+				conn.Close()
+				continue
+			}
+
+			var content bytes.Buffer
+			for {
+				var length uint32
+				if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+					break
+				}
+				if length == 0 {
+					break
+				}
+				if _, err := io.CopyN(&content, reader, int64(length)); err != nil {
+					break
+				}
+			}
+
+			select {
+			case received <- content.Bytes():
+			default:
+			}
+			conn.Write([]byte(reply(content.Bytes()) + "\x00"))
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestClamdScanner_Scan(t *testing.T) {
+	address, received := fakeClamd(t, func(content []byte) string {
+		if bytes.Contains(content, []byte(Eicar)) {
+			return "stream: Eicar-Test-Signature FOUND"
+		}
+		return "stream: OK"
+	})
+
+	scanner := NewClamdScanner(address, time.Second*5)
+
+	// larger than a chunk so the content is streamed in several
+	clean := strings.Repeat("clean content ", clamdChunkSize/7)
+	result, err := scanner.Scan(context.Background(), strings.NewReader(clean))
+	require.NoError(t, err)
+	assert.Equal(t, &Result{}, result)
+	assert.Equal(t, clean, string(<-received))
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader("header "+Eicar))
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Infected: true, Signature: "Eicar-Test-Signature"}, result)
+	<-received
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(""))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+	assert.Empty(t, <-received)
+}
+
+func TestClamdScanner_ScanErrors(t *testing.T) {
+	address, _ := fakeClamd(t, func(content []byte) string {
+		if len(content) > 10 {
+			return "INSTREAM size limit exceeded. ERROR"
+		}
+		return "Can't allocate memory ERROR"
+	})
+
+	scanner := NewClamdScanner(address, time.Second*5)
+
+	_, err := scanner.Scan(context.Background(), strings.NewReader("more than ten bytes"))
+	assert.ErrorIs(t, err, ErrClamdSizeLimit)
+
+	_, err = scanner.Scan(context.Background(), strings.NewReader("small"))
+	assert.ErrorContains(t, err, "Can't allocate memory")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClamdScanner(closedAddress, time.Second).Scan(context.Background(), strings.NewReader("content"))
+	assert.ErrorContains(t, err, "failed to connect to clamd")
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply    string
+		expected *Result
+		err      bool
+	}{
+		{reply: "stream: OK", expected: &Result{}},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND\n", expected: &Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{reply: "INSTREAM size limit exceeded. ERROR", err: true},
+		{reply: "UNKNOWN COMMAND", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.reply, func(t *testing.T) {
+			result, err := parseClamdReply(test.reply)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestFakeScanner_Scan(t *testing.T) {
+	scanner := NewFakeScanner()
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(Eicar))
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Infected: true, Signature: EicarSignature}, result)
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ApiKeyService struct {
+	query  *database.Queries
+	config *config.Config
+	logger *zap.Logger
+}
+
+func NewApiKeyService(db *pgxpool.Pool, config *config.Config, logger *zap.Logger) *ApiKeyService {
+	return &ApiKeyService{
+		query:  database.New(db),
+		config: config,
+		logger: logger,
+	}
+}
+
+// CreateApiKey stores only the hash of the generated key, the plain key is returned once and cannot be recovered
+func (as *ApiKeyService) CreateApiKey(ctx context.Context, apiKeyCreate *models.ApiKeyCreate) (*models.ApiKeyCreated, error) {
+	const op = "ApiKeyService.CreateApiKey"
+	reqId := utils.RequestId(ctx)
+
+	if err := apiKeyCreate.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	key, keyPrefix, err := generateApiKey()
+	if err != nil {
+		as.logger.Error("failed to generate api key", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to create api key", op, reqId, err)
+	}
+
+	id, err := as.query.ApiKeyCreate(ctx, &database.ApiKeyCreateParams{
+		Name:      apiKeyCreate.Name,
+		KeyPrefix: keyPrefix,
+		KeyHash:   hashApiKey(key),
+		Admin:     apiKeyCreate.Admin,
+	})
+	if err != nil {
+		if database.IsConflictError(err) {
+			return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("api key with name '%s' already exists", apiKeyCreate.Name), op, reqId, err)
+		}
+		as.logger.Error("failed to create api key", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to create api key", op, reqId, err)
+	}
+
+	apiKey, err := as.GetApiKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeyCreated := &models.ApiKeyCreated{
+		ApiKey: *apiKey,
+		Key:    key,
+	}
+
+	if as.config.S3GatewaySecret != "" {
+		apiKeyCreated.S3Credentials = &models.S3Credentials{
+			AccessKeyId:     apiKey.Id,
+			SecretAccessKey: deriveS3SecretAccessKey(as.config.S3GatewaySecret, hashApiKey(key)),
+		}
+	}
+
+	return apiKeyCreated, nil
+}
+
+func (as *ApiKeyService) GetApiKey(ctx context.Context, id string) (*models.ApiKey, error) {
+	const op = "ApiKeyService.GetApiKey"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "api key id cannot be empty. api key id is required to get api key", op, reqId, nil)
+	}
+
+	apiKey, err := as.query.ApiKeyGetById(ctx, id)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("api key '%s' not found", id), op, reqId, err)
+		}
+		as.logger.Error("failed to get api key", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get api key", op, reqId, err)
+	}
+
+	return toApiKeyModel(apiKey), nil
+}
+
+func (as *ApiKeyService) ListAllApiKeys(ctx context.Context) ([]*models.ApiKey, error) {
+	const op = "ApiKeyService.ListAllApiKeys"
+	reqId := utils.RequestId(ctx)
+
+	apiKeys, err := as.query.ApiKeyListAll(ctx)
+	if err != nil {
+		as.logger.Error("failed to list all api keys", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to list all api keys", op, reqId, err)
+	}
+
+	result := make([]*models.ApiKey, 0, len(apiKeys))
+
+	for _, apiKey := range apiKeys {
+		result = append(result, toApiKeyModel(apiKey))
+	}
+
+	return result, nil
+}
+
+func (as *ApiKeyService) RevokeApiKey(ctx context.Context, id string) error {
+	const op = "ApiKeyService.RevokeApiKey"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return srverr.NewServiceError(srverr.InvalidInputError, "api key id cannot be empty. api key id is required to revoke api key", op, reqId, nil)
+	}
+
+	revoked, err := as.query.ApiKeyRevoke(ctx, id)
+	if err != nil {
+		as.logger.Error("failed to revoke api key", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to revoke api key", op, reqId, err)
+	}
+
+	if revoked == 0 {
+		return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("active api key '%s' not found", id), op, reqId, nil)
+	}
+
+	return nil
+}
+
+// ValidateApiKey resolves a plain key to its active api key and records that it was used
+func (as *ApiKeyService) ValidateApiKey(ctx context.Context, key string) (*models.ApiKey, error) {
+	const op = "ApiKeyService.ValidateApiKey"
+	reqId := utils.RequestId(ctx)
+
+	apiKey, err := as.query.ApiKeyGetActiveByKeyHash(ctx, hashApiKey(key))
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, "api key not found or revoked", op, reqId, err)
+		}
+		as.logger.Error("failed to get api key by hash", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to validate api key", op, reqId, err)
+	}
+
+	if err = as.query.ApiKeyUpdateLastUsedAt(ctx, apiKey.ID); err != nil {
+		as.logger.Warn("failed to update api key last used at", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+	}
+
+	return toApiKeyModel(apiKey), nil
+}
+
+// GetS3Credentials recomputes the s3 credentials of an active api key
+func (as *ApiKeyService) GetS3Credentials(ctx context.Context, id string) (*models.S3Credentials, error) {
+	const op = "ApiKeyService.GetS3Credentials"
+
+	apiKey, err := as.getActiveApiKey(ctx, id, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.toS3Credentials(ctx, apiKey, op)
+}
+
+// ValidateS3AccessKey resolves the access key id of a signed s3 gateway request to its credentials and records
+// that the api key was used
+func (as *ApiKeyService) ValidateS3AccessKey(ctx context.Context, accessKeyId string) (*models.S3Credentials, error) {
+	const op = "ApiKeyService.ValidateS3AccessKey"
+	reqId := utils.RequestId(ctx)
+
+	apiKey, err := as.getActiveApiKey(ctx, accessKeyId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := as.toS3Credentials(ctx, apiKey, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = as.query.ApiKeyUpdateLastUsedAt(ctx, apiKey.ID); err != nil {
+		as.logger.Warn("failed to update api key last used at", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+	}
+
+	return credentials, nil
+}
+
+func (as *ApiKeyService) getActiveApiKey(ctx context.Context, id string, op string) (*database.StorageApiKey, error) {
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "api key id cannot be empty. api key id is required", op, reqId, nil)
+	}
+
+	apiKey, err := as.query.ApiKeyGetById(ctx, id)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("active api key '%s' not found", id), op, reqId, err)
+		}
+		as.logger.Error("failed to get api key", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get api key", op, reqId, err)
+	}
+
+	if apiKey.RevokedAt != nil {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("active api key '%s' not found", id), op, reqId, nil)
+	}
+
+	return apiKey, nil
+}
+
+func (as *ApiKeyService) toS3Credentials(ctx context.Context, apiKey *database.StorageApiKey, op string) (*models.S3Credentials, error) {
+	if as.config.S3GatewaySecret == "" {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, "s3 credentials are not available. s3_gateway_secret is not configured", op, utils.RequestId(ctx), nil)
+	}
+
+	return &models.S3Credentials{
+		AccessKeyId:     apiKey.ID,
+		SecretAccessKey: deriveS3SecretAccessKey(as.config.S3GatewaySecret, apiKey.KeyHash),
+	}, nil
+}
+
+func toApiKeyModel(apiKey *database.StorageApiKey) *models.ApiKey {
+	return &models.ApiKey{
+		Id:         apiKey.ID,
+		Name:       apiKey.Name,
+		KeyPrefix:  apiKey.KeyPrefix,
+		Admin:      apiKey.Admin,
+		LastUsedAt: apiKey.LastUsedAt,
+		RevokedAt:  apiKey.RevokedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
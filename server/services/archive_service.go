@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/archive"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ArchiveService struct {
+	query       *database.Queries
+	transaction *database.Transaction
+	job         *river.Client[pgx.Tx]
+	archives    *archive.Store
+	config      *config.Config
+	logger      *zap.Logger
+}
+
+func NewArchiveService(db *pgxpool.Pool, storage *storage.Storage, job *river.Client[pgx.Tx], config *config.Config, logger *zap.Logger) *ArchiveService {
+	return &ArchiveService{
+		query:       database.New(db),
+		transaction: database.NewTransaction(db),
+		job:         job,
+		archives:    archive.NewStore(storage, config),
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// ExportBucket queues the export of a bucket into an archive for an admin, the returned operation reports the
+// progress. an archive already at the path is replaced once the export finished
+func (as *ArchiveService) ExportBucket(ctx context.Context, id string, bucketArchive *models.BucketArchive) (*models.Operation, error) {
+	const op = "ArchiveService.ExportBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin keys can export a bucket", op, reqId, nil)
+	}
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to export bucket", op, reqId, nil)
+	}
+
+	if err := bucketArchive.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if err := as.checkLocation(bucketArchive); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	var operationId string
+
+	err := as.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		bucket, err := as.query.WithTx(tx).BucketGetById(ctx, id)
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found for export", id), op, reqId, err)
+			}
+			as.logger.Error("failed to get bucket for export", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to export bucket", op, reqId, err)
+		}
+
+		if bucket.Locked {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be exported", bucket.ID, *bucket.LockReason), op, reqId, nil)
+		}
+
+		jobRow, err := as.job.InsertTx(ctx, tx, &jobs.BucketExport{
+			BucketId:     bucket.ID,
+			Archive:      *bucketArchive,
+			TraceContext: tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			as.logger.Error("failed to create bucket export job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket export job", op, reqId, err)
+		}
+
+		operationId, err = createOperation(ctx, as.query.WithTx(tx), models.OperationTypeBucketExport, bucket.ID, jobRow, as.logger, op)
+		if err != nil {
+			return err
+		}
+
+		return createAuditEvent(ctx, as.query.WithTx(tx), models.AuditActionBucketExport, bucket.ID, nil, map[string]any{
+			"job_id":           jobRow.ID,
+			"archive_location": bucketArchive.Location,
+			"archive_path":     bucketArchive.Path,
+		}, as.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getOperation(ctx, as.query, operationId, as.logger, op)
+}
+
+// RestoreBucket recreates the bucket of an archive for an admin and queues the restore of its objects, the returned
+// operation reports the progress. the bucket and its objects keep their archived ids unless the id of the bucket is
+// taken, and the bucket stays locked until every object is restored
+func (as *ArchiveService) RestoreBucket(ctx context.Context, bucketRestore *models.BucketRestore) (*models.Operation, error) {
+	const op = "ArchiveService.RestoreBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin keys can restore a bucket", op, reqId, nil)
+	}
+
+	if err := bucketRestore.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if err := as.checkLocation(&bucketRestore.Archive); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	manifest, err := as.readManifest(ctx, &bucketRestore.Archive)
+	if err != nil {
+		switch {
+		case errors.Is(err, archive.ErrArchiveNotFound):
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("archive '%s' not found", bucketRestore.Archive.Path), op, reqId, err)
+		case errors.Is(err, archive.ErrInvalidArchive):
+			return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+		}
+		as.logger.Error("failed to read archive manifest", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to restore bucket", op, reqId, err)
+	}
+
+	archived := manifest.Bucket
+
+	name := archived.Name
+	if bucketRestore.Name != nil {
+		name = *bucketRestore.Name
+	}
+
+	if !models.IsValidBucketName(name) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, fmt.Sprintf("archived bucket name '%s' is not valid, restore it under a new name", name), op, reqId, nil)
+	}
+
+	if err = validateProcessors(archived.Processors); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	var operationId string
+
+	err = as.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		// the archived id is only kept when it is free, restoring a copy next to the archived bucket gives it and its
+		// objects new ones
+		var archivedId *string
+		if archived.Id != "" {
+			_, err := as.query.WithTx(tx).BucketGetById(ctx, archived.Id)
+			if database.IsNotFoundError(err) {
+				archivedId = &archived.Id
+			} else if err != nil {
+				as.logger.Error("failed to get archived bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+				return srverr.NewServiceError(srverr.UnknownError, "failed to restore bucket", op, reqId, err)
+			}
+		}
+
+		bucketId, err := as.query.WithTx(tx).BucketRestore(ctx, &database.BucketRestoreParams{
+			ID:                   archivedId,
+			Name:                 name,
+			AllowedMimeTypes:     archived.AllowedMimeTypes,
+			MaxAllowedObjectSize: archived.MaxAllowedObjectSize,
+			Public:               archived.Public,
+			Processors:           archived.Processors,
+			DefaultRetentionMode: archived.DefaultRetentionMode,
+			DefaultRetentionDays: archived.DefaultRetentionDays,
+			ArchiveAfterDays:     archived.ArchiveAfterDays,
+		})
+		if err != nil {
+			if database.IsConflictError(err) {
+				return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket with name '%s' already exists", name), op, reqId, err)
+			}
+			as.logger.Error("failed to create restored bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to restore bucket", op, reqId, err)
+		}
+
+		jobRow, err := as.job.InsertTx(ctx, tx, &jobs.BucketRestore{
+			BucketId:     bucketId,
+			Archive:      bucketRestore.Archive,
+			TraceContext: tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			as.logger.Error("failed to create bucket restore job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket restore job", op, reqId, err)
+		}
+
+		rows, err := as.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
+			ID:         bucketId,
+			LockReason: models.BucketLockedReasonBucketRestore,
+			LockJobID:  &jobRow.ID,
+		})
+		if err != nil {
+			as.logger.Error("failed to lock bucket for restore", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to lock bucket for restore", op, reqId, err)
+		}
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' was locked by another operation and cannot be restored", bucketId), op, reqId, nil)
+		}
+
+		operationId, err = createOperation(ctx, as.query.WithTx(tx), models.OperationTypeBucketRestore, bucketId, jobRow, as.logger, op)
+		if err != nil {
+			return err
+		}
+
+		return createAuditEvent(ctx, as.query.WithTx(tx), models.AuditActionBucketRestore, bucketId, nil, map[string]any{
+			"job_id":               jobRow.ID,
+			"archive_location":     bucketRestore.Archive.Location,
+			"archive_path":         bucketRestore.Archive.Path,
+			"archived_bucket_id":   archived.Id,
+			"archived_bucket_name": archived.Name,
+			"exported_at":          manifest.ExportedAt,
+		}, as.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getOperation(ctx, as.query, operationId, as.logger, op)
+}
+
+// checkLocation refuses local archives when the server has no archive directory
+func (as *ArchiveService) checkLocation(bucketArchive *models.BucketArchive) error {
+	if bucketArchive.Location == models.BucketArchiveLocationLocal && as.config.BucketArchiveDirectory == "" {
+		return archive.ErrLocalArchivesDisabled
+	}
+
+	return nil
+}
+
+func (as *ArchiveService) readManifest(ctx context.Context, bucketArchive *models.BucketArchive) (*archive.Manifest, error) {
+	reader, err := as.archives.Open(ctx, bucketArchive)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return reader.ReadManifest()
+}
@@ -2,30 +2,39 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
+	"github.com/samber/lo"
+	"github.com/teapartydev/storage/server/config"
 	"github.com/teapartydev/storage/server/database"
 	"github.com/teapartydev/storage/server/jobs"
 	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/processing"
 	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/utils"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
+	"sort"
+	"strings"
 )
 
 type BucketService struct {
 	query       *database.Queries
 	transaction *database.Transaction
 	job         *river.Client[pgx.Tx]
+	config      *config.Config
 	logger      *zap.Logger
 }
 
-func NewBucketService(db *pgxpool.Pool, job *river.Client[pgx.Tx], logger *zap.Logger) *BucketService {
+func NewBucketService(db *pgxpool.Pool, job *river.Client[pgx.Tx], config *config.Config, logger *zap.Logger) *BucketService {
 	return &BucketService{
 		query:       database.New(db),
 		transaction: database.NewTransaction(db),
 		job:         job,
+		config:      config,
 		logger:      logger,
 	}
 }
@@ -38,6 +47,14 @@ func (bs *BucketService) CreateBucket(ctx context.Context, bucketCreate *models.
 		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
 	}
 
+	if err := validateProcessors(bucketCreate.Processors); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if err := validateReplica(bs.config, bucketCreate.Replica); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
 	bucketCreate.PreSave()
 
 	id, err := bs.query.BucketCreate(ctx, &database.BucketCreateParams{
@@ -45,6 +62,11 @@ func (bs *BucketService) CreateBucket(ctx context.Context, bucketCreate *models.
 		AllowedMimeTypes:     bucketCreate.AllowedMimeTypes,
 		MaxAllowedObjectSize: bucketCreate.MaxAllowedObjectSize,
 		Public:               bucketCreate.Public,
+		Processors:           bucketCreate.Processors,
+		DefaultRetentionMode: bucketCreate.DefaultRetentionMode,
+		DefaultRetentionDays: bucketCreate.DefaultRetentionDays,
+		Replica:              bucketCreate.Replica,
+		ArchiveAfterDays:     bucketCreate.ArchiveAfterDays,
 	})
 	if err != nil {
 		if database.IsConflictError(err) {
@@ -70,6 +92,14 @@ func (bs *BucketService) UpdateBucket(ctx context.Context, bucketUpdate *models.
 		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
 	}
 
+	if err := validateProcessors(bucketUpdate.Processors); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if err := validateReplica(bs.config, bucketUpdate.Replica); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
 	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
 		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, bucketUpdate.Id)
 		if err != nil {
@@ -100,11 +130,49 @@ func (bs *BucketService) UpdateBucket(ctx context.Context, bucketUpdate *models.
 			bucket.Public = *bucketUpdate.Public
 		}
 
+		if bucketUpdate.Processors != nil {
+			bucket.Processors = bucketUpdate.Processors
+		}
+
+		// an empty mode removes the default retention
+		if bucketUpdate.DefaultRetentionMode != nil {
+			bucket.DefaultRetentionMode = nil
+			bucket.DefaultRetentionDays = nil
+			if *bucketUpdate.DefaultRetentionMode != "" {
+				bucket.DefaultRetentionMode = bucketUpdate.DefaultRetentionMode
+				bucket.DefaultRetentionDays = bucketUpdate.DefaultRetentionDays
+			}
+		}
+
+		// an empty replica stops replication
+		if bucketUpdate.Replica != nil {
+			bucket.Replica = nil
+			if *bucketUpdate.Replica != "" {
+				bucket.Replica = bucketUpdate.Replica
+			}
+		}
+
+		// zero days stops archiving
+		if bucketUpdate.ArchiveAfterDays != nil {
+			bucket.ArchiveAfterDays = nil
+			if *bucketUpdate.ArchiveAfterDays != 0 {
+				bucket.ArchiveAfterDays = bucketUpdate.ArchiveAfterDays
+			}
+		}
+
 		err = bs.query.WithTx(tx).BucketUpdate(ctx, &database.BucketUpdateParams{
-			ID:                   bucket.ID,
-			AllowedMimeTypes:     bucket.AllowedMimeTypes,
-			MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
-			Public:               &bucket.Public,
+			ID:                     bucket.ID,
+			AllowedMimeTypes:       bucket.AllowedMimeTypes,
+			MaxAllowedObjectSize:   bucket.MaxAllowedObjectSize,
+			Public:                 &bucket.Public,
+			Processors:             bucket.Processors,
+			UpdateDefaultRetention: bucketUpdate.DefaultRetentionMode != nil,
+			DefaultRetentionMode:   bucket.DefaultRetentionMode,
+			DefaultRetentionDays:   bucket.DefaultRetentionDays,
+			UpdateReplica:          bucketUpdate.Replica != nil,
+			Replica:                bucket.Replica,
+			UpdateArchiveAfterDays: bucketUpdate.ArchiveAfterDays != nil,
+			ArchiveAfterDays:       bucket.ArchiveAfterDays,
 		})
 		if err != nil {
 			bs.logger.Error("failed to update bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
@@ -125,6 +193,68 @@ func (bs *BucketService) UpdateBucket(ctx context.Context, bucketUpdate *models.
 	return bucket, nil
 }
 
+// RenameBucket changes the name of a bucket. only buckets whose content is stored under their id can be renamed,
+// buckets still stored under their name have to be relocated first
+func (bs *BucketService) RenameBucket(ctx context.Context, bucketRename *models.BucketRename) (*models.Bucket, error) {
+	const op = "BucketService.RenameBucket"
+	reqId := utils.RequestId(ctx)
+
+	bucketRename.PreSave()
+
+	if err := bucketRename.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, bucketRename.Id)
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found for rename", bucketRename.Id), op, reqId, err)
+			}
+			bs.logger.Error("failed to get bucket by id for rename", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to rename bucket", op, reqId, err)
+		}
+
+		if bucket.Disabled {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is disabled and cannot be renamed", bucket.ID), op, reqId, nil)
+		}
+
+		if bucket.Locked {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be renamed", bucket.ID, *bucket.LockReason), op, reqId, nil)
+		}
+
+		if bucket.StoragePrefix != bucket.ID {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' is stored under its name and has to be relocated before it can be renamed", bucket.ID), op, reqId, nil)
+		}
+
+		if bucket.Name == bucketRename.Name {
+			return nil
+		}
+
+		err = bs.query.WithTx(tx).BucketRename(ctx, &database.BucketRenameParams{
+			ID:   bucket.ID,
+			Name: bucketRename.Name,
+		})
+		if err != nil {
+			if database.IsConflictError(err) {
+				return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket with name '%s' already exists", bucketRename.Name), op, reqId, err)
+			}
+			bs.logger.Error("failed to rename bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to rename bucket", op, reqId, err)
+		}
+
+		return createAuditEvent(ctx, bs.query.WithTx(tx), models.AuditActionBucketRename, bucket.ID, nil, map[string]any{
+			"old_name": bucket.Name,
+			"new_name": bucketRename.Name,
+		}, bs.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.GetBucket(ctx, bucketRename.Id)
+}
+
 func (bs *BucketService) EnableBucket(ctx context.Context, id string) (*models.Bucket, error) {
 	const op = "BucketService.EnableBucket"
 	reqId := utils.RequestId(ctx)
@@ -217,14 +347,18 @@ func (bs *BucketService) DisableBucket(ctx context.Context, id string) (*models.
 	return bucket, nil
 }
 
-func (bs *BucketService) EmptyBucket(ctx context.Context, id string) error {
+// EmptyBucket locks a bucket and queues the deletion of every object in it, the returned operation reports the
+// progress. governance retention of the objects is bypassed when bypassGovernance is set by an admin
+func (bs *BucketService) EmptyBucket(ctx context.Context, id string, bypassGovernance bool) (*models.Operation, error) {
 	const op = "BucketService.EmptyBucket"
 	reqId := utils.RequestId(ctx)
 
 	if !models.IsNotEmptyTrimmedString(id) {
-		return srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to empty bucket", op, reqId, nil)
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to empty bucket", op, reqId, nil)
 	}
 
+	var operationId string
+
 	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
 		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, id)
 		if err != nil {
@@ -243,42 +377,62 @@ func (bs *BucketService) EmptyBucket(ctx context.Context, id string) error {
 			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be emptied", bucket.ID, *bucket.LockReason), op, reqId, nil)
 		}
 
-		err = bs.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
+		if err = checkBucketEmptiable(ctx, bs.query.WithTx(tx), bucket.ID, bs.logger, op); err != nil {
+			return err
+		}
+
+		if err = checkBucketUnlocked(ctx, bs.query.WithTx(tx), bucket.ID, bypassGovernance, bs.logger, op); err != nil {
+			return err
+		}
+
+		jobRow, err := bs.job.InsertTx(ctx, tx, &jobs.BucketEmptying{
+			BucketId:         bucket.ID,
+			BypassGovernance: governanceBypass(ctx, bypassGovernance),
+			TraceContext:     tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			bs.logger.Error("failed to create bucket emptying job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket emptying job", op, reqId, err)
+		}
+
+		// the lock is leased to the job, the job renews it while it works and releases only a lock it still holds
+		rows, err := bs.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
 			ID:         bucket.ID,
 			LockReason: models.BucketLockedReasonBucketEmptying,
+			LockJobID:  &jobRow.ID,
 		})
 		if err != nil {
 			bs.logger.Error("failed to lock bucket for emptying", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
 			return srverr.NewServiceError(srverr.UnknownError, "failed to lock bucket for emptying", op, reqId, err)
 		}
-
-		_, err = bs.job.InsertTx(ctx, tx, &jobs.BucketEmptying{
-			BucketId: bucket.ID,
-		}, nil)
-		if err != nil {
-			bs.logger.Error("failed to create bucket emptying job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
-			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket emptying job", op, reqId, err)
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' was locked by another operation and cannot be emptied", bucket.ID), op, reqId, nil)
 		}
 
-		return nil
+		operationId, err = createOperation(ctx, bs.query.WithTx(tx), models.OperationTypeBucketEmpty, bucket.ID, jobRow, bs.logger, op)
+		return err
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return getOperation(ctx, bs.query, operationId, bs.logger, op)
 }
 
-func (bs *BucketService) DeleteBucket(ctx context.Context, id string) error {
+// DeleteBucket locks a bucket and queues the deletion of it and every object in it, the returned operation reports
+// the progress. governance retention of the objects is bypassed when bypassGovernance is set by an admin
+func (bs *BucketService) DeleteBucket(ctx context.Context, id string, bypassGovernance bool) (*models.Operation, error) {
 	const op = "BucketService.DeleteBucket"
 	reqId := utils.RequestId(ctx)
 
 	if !models.IsNotEmptyTrimmedString(id) {
-		return srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to delete bucket", op, reqId, nil)
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to delete bucket", op, reqId, nil)
 	}
 
+	var operationId string
+
 	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
-		bucket, err := bs.query.BucketGetById(ctx, id)
+		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, id)
 		if err != nil {
 			if database.IsNotFoundError(err) {
 				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found for deletion", id), op, reqId, err)
@@ -295,30 +449,275 @@ func (bs *BucketService) DeleteBucket(ctx context.Context, id string) error {
 			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be deleted", bucket.ID, *bucket.LockReason), op, reqId, nil)
 		}
 
-		err = bs.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
+		if err = checkBucketEmptiable(ctx, bs.query.WithTx(tx), bucket.ID, bs.logger, op); err != nil {
+			return err
+		}
+
+		if err = checkBucketUnlocked(ctx, bs.query.WithTx(tx), bucket.ID, bypassGovernance, bs.logger, op); err != nil {
+			return err
+		}
+
+		jobRow, err := bs.job.InsertTx(ctx, tx, jobs.BucketDeletion{
+			BucketId:         bucket.ID,
+			BypassGovernance: governanceBypass(ctx, bypassGovernance),
+			TraceContext:     tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			bs.logger.Error("failed to create bucket deletion job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket deletion job", op, reqId, err)
+		}
+
+		rows, err := bs.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
 			ID:         bucket.ID,
 			LockReason: models.BucketLockedReasonBucketDeletion,
+			LockJobID:  &jobRow.ID,
 		})
 		if err != nil {
 			bs.logger.Error("failed to lock bucket for deletion", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
 			return srverr.NewServiceError(srverr.UnknownError, "failed to lock bucket for deletion", op, reqId, err)
 		}
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' was locked by another operation and cannot be deleted", bucket.ID), op, reqId, nil)
+		}
+
+		operationId, err = createOperation(ctx, bs.query.WithTx(tx), models.OperationTypeBucketDelete, bucket.ID, jobRow, bs.logger, op)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getOperation(ctx, bs.query, operationId, bs.logger, op)
+}
+
+// ImportBucket queues the import of content already in storage into the catalog of a bucket, the returned operation
+// reports the progress. content is read from the name of the bucket unless a source prefix is given
+func (bs *BucketService) ImportBucket(ctx context.Context, id string, bucketImport *models.BucketImport) (*models.Operation, error) {
+	const op = "BucketService.ImportBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to import into bucket", op, reqId, nil)
+	}
+
+	if err := bucketImport.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	var operationId string
+
+	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, id)
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found for import", id), op, reqId, err)
+			}
+			bs.logger.Error("failed to get bucket for import", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to import into bucket", op, reqId, err)
+		}
+
+		if bucket.Disabled {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is disabled and cannot be imported into", bucket.ID), op, reqId, nil)
+		}
+
+		if bucket.Locked {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be imported into", bucket.ID, *bucket.LockReason), op, reqId, nil)
+		}
+
+		sourcePrefix, err := bucketImportSource(bucket, bucketImport.SourcePrefix)
+		if err != nil {
+			return srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+		}
+
+		claimants, err := bs.query.WithTx(tx).BucketListClaimingPrefix(ctx, &database.BucketListClaimingPrefixParams{
+			BucketID: bucket.ID,
+			Prefix:   sourcePrefix,
+		})
+		if err != nil {
+			bs.logger.Error("failed to list buckets claiming source prefix", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to import into bucket", op, reqId, err)
+		}
 
-		_, err = bs.job.InsertTx(ctx, tx, jobs.BucketDeletion{
-			BucketId: bucket.ID,
+		for _, claimant := range claimants {
+			if claim := bucketPrefixClaim(claimant, sourcePrefix); claim != "" {
+				return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("source_prefix '%s' is the %s of bucket '%s'", sourcePrefix, claim, claimant.ID), op, reqId, nil)
+			}
+		}
+
+		jobRow, err := bs.job.InsertTx(ctx, tx, &jobs.BucketImport{
+			BucketId:     bucket.ID,
+			SourcePrefix: sourcePrefix,
+			Prefix:       bucketImport.Prefix,
+			Overwrite:    bucketImport.Overwrite,
+			TraceContext: tracing.NewTraceContext(ctx),
 		}, nil)
 		if err != nil {
-			bs.logger.Error("failed to create bucket deletion job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
-			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket deletion job", op, reqId, err)
+			bs.logger.Error("failed to create bucket import job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket import job", op, reqId, err)
 		}
 
-		return nil
+		operationId, err = createOperation(ctx, bs.query.WithTx(tx), models.OperationTypeBucketImport, bucket.ID, jobRow, bs.logger, op)
+		return err
 	})
 	if err != nil {
+		return nil, err
+	}
+
+	return getOperation(ctx, bs.query, operationId, bs.logger, op)
+}
+
+// RelocateBucket locks a bucket stored under its name and queues the move of its content under its id, the returned
+// operation reports the progress. a relocated bucket can be renamed
+func (bs *BucketService) RelocateBucket(ctx context.Context, id string) (*models.Operation, error) {
+	const op = "BucketService.RelocateBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin keys can relocate a bucket", op, reqId, nil)
+	}
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to relocate bucket", op, reqId, nil)
+	}
+
+	var operationId string
+
+	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		bucket, err := bs.query.WithTx(tx).BucketGetById(ctx, id)
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found for relocation", id), op, reqId, err)
+			}
+			bs.logger.Error("failed to get bucket for relocation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to relocate bucket", op, reqId, err)
+		}
+
+		if bucket.Locked {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' is locked for '%s' and cannot be relocated", bucket.ID, *bucket.LockReason), op, reqId, nil)
+		}
+
+		if bucket.StoragePrefix == bucket.ID {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' is already stored under its id", bucket.ID), op, reqId, nil)
+		}
+
+		// the content left behind by an earlier relocation is deleted by the storage reconciliation, a relocation
+		// copying under the same prefix in the meantime would lose keys
+		if bucket.StaleStoragePrefix != nil {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' still holds content of an earlier relocation under '%s' and cannot be relocated until it is deleted", bucket.ID, *bucket.StaleStoragePrefix), op, reqId, nil)
+		}
+
+		jobRow, err := bs.job.InsertTx(ctx, tx, &jobs.BucketRelocation{
+			BucketId:     bucket.ID,
+			Prefix:       bucket.StoragePrefix,
+			TraceContext: tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			bs.logger.Error("failed to create bucket relocation job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create bucket relocation job", op, reqId, err)
+		}
+
+		// uploads are turned away while the content moves, reads keep going to the old prefix until the switch
+		rows, err := bs.query.WithTx(tx).BucketLock(ctx, &database.BucketLockParams{
+			ID:         bucket.ID,
+			LockReason: models.BucketLockedReasonBucketRelocation,
+			LockJobID:  &jobRow.ID,
+		})
+		if err != nil {
+			bs.logger.Error("failed to lock bucket for relocation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to lock bucket for relocation", op, reqId, err)
+		}
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' was locked by another operation and cannot be relocated", bucket.ID), op, reqId, nil)
+		}
+
+		operationId, err = createOperation(ctx, bs.query.WithTx(tx), models.OperationTypeBucketRelocate, bucket.ID, jobRow, bs.logger, op)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return getOperation(ctx, bs.query, operationId, bs.logger, op)
+}
+
+// UnlockBucket force unlocks a bucket for an admin. the job holding the lock is cancelled and its operation recorded
+// as cancelled, a job already deleting stops at its next checkpoint once it sees the lock is gone
+func (bs *BucketService) UnlockBucket(ctx context.Context, id string) (*models.Bucket, error) {
+	const op = "BucketService.UnlockBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin keys can force unlock a bucket", op, reqId, nil)
+	}
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to unlock bucket", op, reqId, nil)
+	}
+
+	var bucket *database.BucketGetLockForUpdateRow
+	err := bs.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var err error
+		bucket, err = bs.query.WithTx(tx).BucketGetLockForUpdate(ctx, id)
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found", id), op, reqId, err)
+			}
+			bs.logger.Error("failed to get bucket for unlocking", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to unlock bucket", op, reqId, err)
+		}
+
+		if !bucket.Locked {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("bucket '%s' is not locked", bucket.ID), op, reqId, nil)
+		}
+
+		// only the lock that was read is released, a lock without a job never matches a job id
+		rows, err := bs.query.WithTx(tx).BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+			ID:        bucket.ID,
+			LockJobID: lo.FromPtr(bucket.LockJobID),
+		})
+		if err != nil {
+			bs.logger.Error("failed to unlock bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to unlock bucket", op, reqId, err)
+		}
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("lock of bucket '%s' changed while it was unlocked", bucket.ID), op, reqId, nil)
+		}
+
+		if bucket.LockJobID != nil {
+			// a job that is already gone or finished has nothing left to cancel
+			if _, err = bs.job.JobCancelTx(ctx, tx, *bucket.LockJobID); err != nil && !errors.Is(err, river.ErrNotFound) {
+				bs.logger.Error("failed to cancel job holding bucket lock", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+				return srverr.NewServiceError(srverr.UnknownError, "failed to unlock bucket", op, reqId, err)
+			}
+
+			state := models.OperationStateCancelled
+			message := "bucket force unlocked by an admin"
+
+			err := bs.query.WithTx(tx).OperationFinishByJobId(ctx, &database.OperationFinishByJobIdParams{
+				JobID: *bucket.LockJobID,
+				State: &state,
+				Error: &message,
+			})
+			if err != nil {
+				bs.logger.Error("failed to finish operation of unlocked bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+				return srverr.NewServiceError(srverr.UnknownError, "failed to unlock bucket", op, reqId, err)
+			}
+		}
+
+		return createAuditEvent(ctx, bs.query.WithTx(tx), models.AuditActionBucketUnlock, bucket.ID, nil, map[string]any{
+			"job_id":            bucket.LockJobID,
+			"lock_reason":       bucket.LockReason,
+			"locked_at":         bucket.LockedAt,
+			"lock_heartbeat_at": bucket.LockHeartbeatAt,
+		}, bs.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bs.logger.Warn("bucket force unlocked", zap.String("bucket_id", bucket.ID), zapfield.Operation(op), zapfield.RequestId(reqId))
+
+	return bs.GetBucket(ctx, bucket.ID)
 }
 
 func (bs *BucketService) GetBucket(ctx context.Context, id string) (*models.Bucket, error) {
@@ -342,13 +741,63 @@ func (bs *BucketService) GetBucket(ctx context.Context, id string) (*models.Buck
 		Id:                   bucket.ID,
 		Version:              bucket.Version,
 		Name:                 bucket.Name,
+		StoragePrefix:        bucket.StoragePrefix,
 		AllowedMimeTypes:     bucket.AllowedMimeTypes,
 		MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
 		Public:               bucket.Public,
+		Processors:           bucket.Processors,
+		Replica:              bucket.Replica,
+		ArchiveAfterDays:     bucket.ArchiveAfterDays,
+		DefaultRetentionMode: bucket.DefaultRetentionMode,
+		DefaultRetentionDays: bucket.DefaultRetentionDays,
 		Disabled:             bucket.Disabled,
 		Locked:               bucket.Locked,
 		LockReason:           bucket.LockReason,
 		LockedAt:             bucket.LockedAt,
+		LockJobId:            bucket.LockJobID,
+		LockHeartbeatAt:      bucket.LockHeartbeatAt,
+		CreatedAt:            bucket.CreatedAt,
+		UpdatedAt:            bucket.UpdatedAt,
+	}, nil
+}
+
+// GetBucketByName is used by the s3 gateway where buckets are addressed by name
+func (bs *BucketService) GetBucketByName(ctx context.Context, name string) (*models.Bucket, error) {
+	const op = "BucketService.GetBucketByName"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(name) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket name cannot be empty. bucket name is required to get bucket", op, reqId, nil)
+	}
+
+	bucket, err := bs.query.BucketGetByName(ctx, name)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found", name), op, reqId, err)
+		}
+		bs.logger.Error("failed to get bucket by name", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get bucket", op, reqId, err)
+	}
+
+	return &models.Bucket{
+		Id:                   bucket.ID,
+		Version:              bucket.Version,
+		Name:                 bucket.Name,
+		StoragePrefix:        bucket.StoragePrefix,
+		AllowedMimeTypes:     bucket.AllowedMimeTypes,
+		MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
+		Public:               bucket.Public,
+		Processors:           bucket.Processors,
+		Replica:              bucket.Replica,
+		ArchiveAfterDays:     bucket.ArchiveAfterDays,
+		DefaultRetentionMode: bucket.DefaultRetentionMode,
+		DefaultRetentionDays: bucket.DefaultRetentionDays,
+		Disabled:             bucket.Disabled,
+		Locked:               bucket.Locked,
+		LockReason:           bucket.LockReason,
+		LockedAt:             bucket.LockedAt,
+		LockJobId:            bucket.LockJobID,
+		LockHeartbeatAt:      bucket.LockHeartbeatAt,
 		CreatedAt:            bucket.CreatedAt,
 		UpdatedAt:            bucket.UpdatedAt,
 	}, nil
@@ -398,13 +847,21 @@ func (bs *BucketService) ListAllBuckets(ctx context.Context) ([]*models.Bucket,
 			Id:                   bucket.ID,
 			Version:              bucket.Version,
 			Name:                 bucket.Name,
+			StoragePrefix:        bucket.StoragePrefix,
 			AllowedMimeTypes:     bucket.AllowedMimeTypes,
 			MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
 			Public:               bucket.Public,
+			Processors:           bucket.Processors,
+			Replica:              bucket.Replica,
+			ArchiveAfterDays:     bucket.ArchiveAfterDays,
+			DefaultRetentionMode: bucket.DefaultRetentionMode,
+			DefaultRetentionDays: bucket.DefaultRetentionDays,
 			Disabled:             bucket.Disabled,
 			Locked:               bucket.Locked,
 			LockReason:           bucket.LockReason,
 			LockedAt:             bucket.LockedAt,
+			LockJobId:            bucket.LockJobID,
+			LockHeartbeatAt:      bucket.LockHeartbeatAt,
 			CreatedAt:            bucket.CreatedAt,
 			UpdatedAt:            bucket.UpdatedAt,
 		})
@@ -437,13 +894,21 @@ func (bs *BucketService) SearchBuckets(ctx context.Context, name string) ([]*mod
 			Id:                   bucket.ID,
 			Version:              bucket.Version,
 			Name:                 bucket.Name,
+			StoragePrefix:        bucket.StoragePrefix,
 			AllowedMimeTypes:     bucket.AllowedMimeTypes,
 			MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
 			Public:               bucket.Public,
+			Processors:           bucket.Processors,
+			Replica:              bucket.Replica,
+			ArchiveAfterDays:     bucket.ArchiveAfterDays,
+			DefaultRetentionMode: bucket.DefaultRetentionMode,
+			DefaultRetentionDays: bucket.DefaultRetentionDays,
 			Disabled:             bucket.Disabled,
 			Locked:               bucket.Locked,
 			LockReason:           bucket.LockReason,
 			LockedAt:             bucket.LockedAt,
+			LockJobId:            bucket.LockJobID,
+			LockHeartbeatAt:      bucket.LockHeartbeatAt,
 			CreatedAt:            bucket.CreatedAt,
 			UpdatedAt:            bucket.UpdatedAt,
 		})
@@ -451,3 +916,61 @@ func (bs *BucketService) SearchBuckets(ctx context.Context, name string) ([]*mod
 
 	return result, nil
 }
+
+// validateProcessors checks the processors of a bucket against the registry, which the models cannot see
+func validateProcessors(processors []string) error {
+	for _, processor := range processors {
+		if _, ok := processing.Lookup(processor); !ok {
+			return fmt.Errorf("unknown bucket processor '%s'. available processors are %s", processor, strings.Join(processing.Names(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// validateReplica checks the replica of a bucket against the replicas in the config, which the models cannot see. an
+// empty replica is left to the update to remove
+func validateReplica(config *config.Config, replica *string) error {
+	if replica == nil || *replica == "" {
+		return nil
+	}
+
+	if _, ok := config.Replicas[*replica]; !ok {
+		names := lo.Keys(config.Replicas)
+		sort.Strings(names)
+		return fmt.Errorf("unknown bucket replica '%s'. available replicas are [%s]", *replica, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// bucketImportSource is the storage prefix an import reads from, the name of the bucket unless one is given. a bucket
+// only imports from its name, the prefix it is stored under or the prefix a relocation left its content under
+func bucketImportSource(bucket *database.StorageBucket, sourcePrefix string) (string, error) {
+	if sourcePrefix == "" {
+		sourcePrefix = bucket.Name
+	}
+
+	if sourcePrefix != bucket.Name && sourcePrefix != bucket.StoragePrefix && (bucket.StaleStoragePrefix == nil || sourcePrefix != *bucket.StaleStoragePrefix) {
+		return "", fmt.Errorf("source_prefix '%s' is not valid. content is imported from the name of the bucket or the prefix it is stored under", sourcePrefix)
+	}
+
+	return sourcePrefix, nil
+}
+
+// bucketPrefixClaim names what of another bucket a prefix is, its name, id or a prefix holding its content, or is
+// empty when the prefix is none of them. content under a prefix another bucket claims is never imported
+func bucketPrefixClaim(claimant *database.BucketListClaimingPrefixRow, prefix string) string {
+	switch {
+	case claimant.StoragePrefix == prefix:
+		return "storage prefix"
+	case claimant.StaleStoragePrefix != nil && *claimant.StaleStoragePrefix == prefix:
+		return "stale storage prefix"
+	case claimant.ID == prefix:
+		return "id"
+	case claimant.Name == prefix:
+		return "name"
+	default:
+		return ""
+	}
+}
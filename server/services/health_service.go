@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/samber/lo"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+type HealthService struct {
+	db           *pgxpool.Pool
+	storage      *storage.Storage
+	job          *river.Client[pgx.Tx]
+	worksJobs    bool
+	jobStarted   atomic.Bool
+	shuttingDown atomic.Bool
+	logger       *zap.Logger
+}
+
+// NewHealthService takes worksJobs to tell whether this process works jobs or only inserts them, in which case
+// the job client is never started and is left out of readiness
+func NewHealthService(db *pgxpool.Pool, storage *storage.Storage, job *river.Client[pgx.Tx], worksJobs bool, logger *zap.Logger) *HealthService {
+	return &HealthService{
+		db:        db,
+		storage:   storage,
+		job:       job,
+		worksJobs: worksJobs,
+		logger:    logger,
+	}
+}
+
+// MarkJobClientStarted is called once the river client has been started so readiness can report on it
+func (hs *HealthService) MarkJobClientStarted() {
+	hs.jobStarted.Store(true)
+}
+
+// MarkShuttingDown flips readiness to failing so load balancers stop routing new requests during shutdown
+func (hs *HealthService) MarkShuttingDown() {
+	hs.shuttingDown.Store(true)
+}
+
+func (hs *HealthService) Liveness() *models.Health {
+	return &models.Health{
+		Status: models.HealthStatusUp,
+	}
+}
+
+func (hs *HealthService) Readiness(ctx context.Context) *models.Health {
+	checks := map[string]func(ctx context.Context) (string, error){
+		"database":   hs.checkDatabase,
+		"storage":    hs.checkStorage,
+		"migrations": hs.checkMigrations,
+	}
+
+	if hs.worksJobs {
+		checks["jobs"] = hs.checkJobs
+	}
+
+	health := &models.Health{
+		Status: models.HealthStatusUp,
+		Checks: make(map[string]*models.HealthCheck, len(checks)+1),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) (string, error)) {
+			defer wg.Done()
+
+			healthCheck := hs.runCheck(ctx, name, check)
+
+			mu.Lock()
+			health.Checks[name] = healthCheck
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	if hs.shuttingDown.Load() {
+		health.Checks["shutdown"] = &models.HealthCheck{
+			Status:  models.HealthStatusDown,
+			Message: lo.ToPtr("service is shutting down"),
+		}
+	}
+
+	for _, healthCheck := range health.Checks {
+		if healthCheck.Status != models.HealthStatusUp {
+			health.Status = models.HealthStatusDown
+		}
+	}
+
+	return health
+}
+
+func (hs *HealthService) runCheck(ctx context.Context, name string, check func(ctx context.Context) (string, error)) *models.HealthCheck {
+	const op = "HealthService.runCheck"
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	message, err := check(ctx)
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		hs.logger.Warn("readiness check failed", zap.String("check", name), zap.Error(err), zapfield.Operation(op))
+		return &models.HealthCheck{
+			Status:     models.HealthStatusDown,
+			Message:    lo.ToPtr(err.Error()),
+			DurationMs: duration,
+		}
+	}
+
+	return &models.HealthCheck{
+		Status:     models.HealthStatusUp,
+		Message:    lo.EmptyableToPtr(message),
+		DurationMs: duration,
+	}
+}
+
+func (hs *HealthService) checkDatabase(ctx context.Context) (string, error) {
+	if err := hs.db.Ping(ctx); err != nil {
+		return "", fmt.Errorf("database ping failed: %w", err)
+	}
+
+	return "", nil
+}
+
+func (hs *HealthService) checkStorage(ctx context.Context) (string, error) {
+	if err := hs.storage.CheckBucket(ctx); err != nil {
+		return "", fmt.Errorf("storage bucket is not reachable: %w", err)
+	}
+
+	return "", nil
+}
+
+func (hs *HealthService) checkMigrations(ctx context.Context) (string, error) {
+	migrationStatus, err := database.GetMigrationStatus(ctx, hs.db)
+	if err != nil {
+		return "", fmt.Errorf("failed to get database migration status: %w", err)
+	}
+
+	if !migrationStatus.IsUpToDate() {
+		return "", fmt.Errorf("database migrations are at version %d of %d", migrationStatus.CurrentVersion, migrationStatus.LatestVersion)
+	}
+
+	return fmt.Sprintf("database migrations are at version %d of %d", migrationStatus.CurrentVersion, migrationStatus.LatestVersion), nil
+}
+
+func (hs *HealthService) checkJobs(_ context.Context) (string, error) {
+	if !hs.jobStarted.Load() {
+		return "", fmt.Errorf("job client has not been started")
+	}
+
+	select {
+	case <-hs.job.Stopped():
+		return "", fmt.Errorf("job client has been stopped")
+	default:
+	}
+
+	return "", nil
+}
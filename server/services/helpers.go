@@ -1,6 +1,10 @@
 package services
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/samber/lo"
@@ -26,6 +30,37 @@ func bytesToMetadata(metadataBytes []byte) map[string]any {
 	return metadata
 }
 
+// metadataToStrings flattens metadata into the string values s3 keeps, values that are not strings are json encoded
+func metadataToStrings(metadata map[string]any) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		if stringValue, ok := value.(string); ok {
+			result[key] = stringValue
+			continue
+		}
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		result[key] = string(valueBytes)
+	}
+	return result
+}
+
+func stringsToMetadata(metadata map[string]string) map[string]any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		result[key] = value
+	}
+	return result
+}
+
 func determineMimeType(bucket *models.Bucket, preSignedUploadSessionCreate *models.PreSignedUploadSessionCreate) (*string, error) {
 	if preSignedUploadSessionCreate.MimeType != nil {
 		if !models.IsNotEmptyTrimmedString(*preSignedUploadSessionCreate.MimeType) {
@@ -65,3 +100,28 @@ func determineMimeType(bucket *models.Bucket, preSignedUploadSessionCreate *mode
 		}
 	}
 }
+
+// generateApiKey returns a new random api key along with the short prefix that is kept to recognise it later
+func generateApiKey() (key string, prefix string, err error) {
+	randomBytes := make([]byte, 32)
+	if _, err = rand.Read(randomBytes); err != nil {
+		return "", "", err
+	}
+
+	key = models.ApiKeyPrefix + hex.EncodeToString(randomBytes)
+
+	return key, key[:len(models.ApiKeyPrefix)+6], nil
+}
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// deriveS3SecretAccessKey derives the s3 secret of an api key from its stored hash, so the secret can be recomputed
+// to verify signatures without ever storing the plain key or the secret
+func deriveS3SecretAccessKey(gatewaySecret string, keyHash string) string {
+	mac := hmac.New(sha256.New, []byte(gatewaySecret))
+	mac.Write([]byte(keyHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
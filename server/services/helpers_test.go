@@ -1,11 +1,18 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"github.com/riverqueue/river/rivertype"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/teapartydev/storage/server/database"
 	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMetadataConversion(t *testing.T) {
@@ -122,3 +129,270 @@ func TestDetermineMimeType(t *testing.T) {
 	_, err = determineMimeType(mimeTypeEmptyStringTest.bucket, mimeTypeEmptyStringTest.preSignedUploadSessionCreate)
 	assert.Equal(t, mimeTypeEmptyStringTest.expectedError, err)
 }
+
+func TestGenerateApiKey(t *testing.T) {
+	key, prefix, err := generateApiKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, models.ApiKeyPrefix), "Api key should start with the api key prefix")
+	assert.True(t, strings.HasPrefix(key, prefix), "Api key should start with its display prefix")
+	assert.Len(t, key, len(models.ApiKeyPrefix)+64)
+
+	otherKey, _, err := generateApiKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, otherKey, "Generated api keys should be unique")
+}
+
+func TestHashApiKey(t *testing.T) {
+	assert.Equal(t, hashApiKey("hds_key"), hashApiKey("hds_key"), "Hashing should be deterministic")
+	assert.NotEqual(t, hashApiKey("hds_key"), hashApiKey("hds_other_key"))
+	assert.Len(t, hashApiKey("hds_key"), 64)
+}
+
+func TestDeriveS3SecretAccessKey(t *testing.T) {
+	secret := deriveS3SecretAccessKey("gateway-secret-0123456789abcdefghij", hashApiKey("hds_key"))
+	assert.Len(t, secret, 64)
+	assert.Equal(t, secret, deriveS3SecretAccessKey("gateway-secret-0123456789abcdefghij", hashApiKey("hds_key")), "Derivation should be deterministic")
+	assert.NotEqual(t, secret, deriveS3SecretAccessKey("gateway-secret-0123456789abcdefghij", hashApiKey("hds_other_key")))
+	assert.NotEqual(t, secret, deriveS3SecretAccessKey("other-gateway-secret-0123456789abcd", hashApiKey("hds_key")), "Rotating the gateway secret should change every derived key")
+}
+
+func TestMetadataToStrings(t *testing.T) {
+	metadata := metadataToStrings(map[string]any{
+		"owner": "david",
+		"tags":  []any{"a", "b"},
+		"count": float64(2),
+	})
+	assert.Equal(t, map[string]string{"owner": "david", "tags": `["a","b"]`, "count": "2"}, metadata)
+	assert.Nil(t, metadataToStrings(nil))
+
+	assert.Equal(t, map[string]any{"owner": "david"}, stringsToMetadata(map[string]string{"owner": "david"}))
+}
+
+func TestCheckObjectServable(t *testing.T) {
+	tests := []struct {
+		status    string
+		tier      string
+		errorCode error
+	}{
+		{status: models.ObjectUploadStatusPending, tier: models.ObjectTierHot},
+		{status: models.ObjectUploadStatusCompleted, tier: models.ObjectTierHot},
+		{status: models.ObjectUploadStatusScanning, tier: models.ObjectTierHot, errorCode: srverr.ConflictError},
+		{status: models.ObjectUploadStatusQuarantined, tier: models.ObjectTierHot, errorCode: srverr.ForbiddenError},
+		{status: models.ObjectUploadStatusCompleted, tier: models.ObjectTierCold, errorCode: srverr.ConflictError},
+		{status: models.ObjectUploadStatusCompleted, tier: models.ObjectTierRestoring, errorCode: srverr.ConflictError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.tier, func(t *testing.T) {
+			err := checkObjectServable(context.Background(), &database.StorageObject{ID: "object_01HPG4GN5JY2Z6S0638ERSG375", UploadStatus: tt.status, Tier: tt.tier}, "test")
+			if tt.errorCode == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var serviceError srverr.ServiceError
+			assert.ErrorAs(t, err, &serviceError)
+			assert.ErrorIs(t, serviceError.ErrorCode, tt.errorCode)
+		})
+	}
+}
+
+func TestGovernanceBypass(t *testing.T) {
+	admin := utils.WithPrincipal(context.Background(), utils.Principal{Id: "api_key_admin", Admin: true})
+	user := utils.WithPrincipal(context.Background(), utils.Principal{Id: "api_key_user"})
+
+	assert.True(t, governanceBypass(admin, true))
+	assert.False(t, governanceBypass(admin, false))
+	assert.False(t, governanceBypass(user, true))
+	assert.False(t, governanceBypass(context.Background(), true))
+}
+
+func TestCheckLegalHoldChange(t *testing.T) {
+	admin := utils.WithPrincipal(context.Background(), utils.Principal{Id: "api_key_admin", Admin: true})
+	user := utils.WithPrincipal(context.Background(), utils.Principal{Id: "api_key_user"})
+
+	held := &database.StorageObject{ID: "object_01HPG4GN5JY2Z6S0638ERSG375", LegalHold: true}
+	free := &database.StorageObject{ID: "object_01HPG4GN5JY2Z6S0638ERSG375"}
+
+	var serviceError srverr.ServiceError
+	assert.ErrorAs(t, checkLegalHoldChange(user, held, false, "test"), &serviceError)
+	assert.ErrorIs(t, serviceError.ErrorCode, srverr.ForbiddenError)
+
+	assert.NoError(t, checkLegalHoldChange(admin, held, false, "test"))
+	assert.NoError(t, checkLegalHoldChange(user, held, true, "test"))
+	assert.NoError(t, checkLegalHoldChange(user, free, true, "test"))
+	assert.NoError(t, checkLegalHoldChange(user, free, false, "test"))
+}
+
+func TestBucketImportSource(t *testing.T) {
+	staleStoragePrefix := "avatar"
+
+	tests := []struct {
+		name         string
+		bucket       *database.StorageBucket
+		sourcePrefix string
+		expected     string
+		valid        bool
+	}{
+		{
+			name:     "Fresh Bucket Defaults To Its Name",
+			bucket:   &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"},
+			expected: "avatar",
+			valid:    true,
+		},
+		{
+			name:     "Bucket Stored Under Its Name",
+			bucket:   &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "avatar"},
+			expected: "avatar",
+			valid:    true,
+		},
+		{
+			name:         "Fresh Bucket From Its Own Prefix",
+			bucket:       &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"},
+			sourcePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+			expected:     "bucket_01HPG4GN5JY2Z6S0638ERSG375",
+			valid:        true,
+		},
+		{
+			name:         "Stale Prefix Of A Cancelled Relocation",
+			bucket:       &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatars", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375", StaleStoragePrefix: &staleStoragePrefix},
+			sourcePrefix: "avatar",
+			expected:     "avatar",
+			valid:        true,
+		},
+		{
+			name:         "Other Bucket Id",
+			bucket:       &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"},
+			sourcePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG376",
+		},
+		{
+			name:         "Other Bucket Name",
+			bucket:       &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG375"},
+			sourcePrefix: "invoices",
+		},
+		{
+			name:         "Archives",
+			bucket:       &database.StorageBucket{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG375", Name: "avatar", StoragePrefix: "avatar"},
+			sourcePrefix: "_archives",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourcePrefix, err := bucketImportSource(tt.bucket, tt.sourcePrefix)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sourcePrefix)
+		})
+	}
+}
+
+func TestBucketPrefixClaim(t *testing.T) {
+	staleStoragePrefix := "avatar"
+
+	tests := []struct {
+		name     string
+		claimant *database.BucketListClaimingPrefixRow
+		prefix   string
+		expected string
+	}{
+		{
+			name:     "Storage Prefix Of Bucket Stored Under Its Name",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "avatar", StoragePrefix: "avatar"},
+			prefix:   "avatar",
+			expected: "storage prefix",
+		},
+		{
+			name:     "Stale Prefix Of Relocated Bucket",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG376", StaleStoragePrefix: &staleStoragePrefix},
+			prefix:   "avatar",
+			expected: "stale storage prefix",
+		},
+		{
+			name:     "Stale Prefix Of Renamed Bucket",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "avatars", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG376", StaleStoragePrefix: &staleStoragePrefix},
+			prefix:   "avatar",
+			expected: "stale storage prefix",
+		},
+		{
+			name:     "Id Of Bucket",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "avatars", StoragePrefix: "avatars"},
+			prefix:   "bucket_01HPG4GN5JY2Z6S0638ERSG376",
+			expected: "id",
+		},
+		{
+			name:     "Name Of Bucket Stored Under Its Id",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "avatar", StoragePrefix: "bucket_01HPG4GN5JY2Z6S0638ERSG376"},
+			prefix:   "avatar",
+			expected: "name",
+		},
+		{
+			name:     "Unrelated Bucket",
+			claimant: &database.BucketListClaimingPrefixRow{ID: "bucket_01HPG4GN5JY2Z6S0638ERSG376", Name: "invoices", StoragePrefix: "invoices"},
+			prefix:   "avatar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, bucketPrefixClaim(tt.claimant, tt.prefix))
+		})
+	}
+}
+
+func TestOperationState(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		operation *database.StorageOperation
+		jobRow    *rivertype.JobRow
+		expected  string
+	}{
+		{
+			name:      "Queued",
+			operation: &database.StorageOperation{},
+			jobRow:    &rivertype.JobRow{State: rivertype.JobStateAvailable},
+			expected:  models.OperationStateQueued,
+		},
+		{
+			name:      "Running",
+			operation: &database.StorageOperation{},
+			jobRow:    &rivertype.JobRow{State: rivertype.JobStateRetryable},
+			expected:  models.OperationStateRunning,
+		},
+		{
+			name:      "Cancelling",
+			operation: &database.StorageOperation{CancelRequestedAt: &now},
+			jobRow:    &rivertype.JobRow{State: rivertype.JobStateRunning},
+			expected:  models.OperationStateCancelling,
+		},
+		{
+			name:      "Succeeded By Job",
+			operation: &database.StorageOperation{},
+			jobRow:    &rivertype.JobRow{State: rivertype.JobStateCompleted},
+			expected:  models.OperationStateSucceeded,
+		},
+		{
+			name:      "Recorded State Wins",
+			operation: &database.StorageOperation{State: lo.ToPtr(models.OperationStateCancelled), CancelRequestedAt: &now},
+			jobRow:    &rivertype.JobRow{State: rivertype.JobStateRunning},
+			expected:  models.OperationStateCancelled,
+		},
+		{
+			name:      "Pruned Job Without Recorded State",
+			operation: &database.StorageOperation{},
+			jobRow:    nil,
+			expected:  models.OperationStateFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, operationState(tt.operation, tt.jobRow))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+var jobStates = []rivertype.JobState{
+	rivertype.JobStateAvailable,
+	rivertype.JobStateCancelled,
+	rivertype.JobStateCompleted,
+	rivertype.JobStateDiscarded,
+	rivertype.JobStateRetryable,
+	rivertype.JobStateRunning,
+	rivertype.JobStateScheduled,
+}
+
+type JobService struct {
+	query  *database.Queries
+	job    *river.Client[pgx.Tx]
+	logger *zap.Logger
+}
+
+func NewJobService(db *pgxpool.Pool, job *river.Client[pgx.Tx], logger *zap.Logger) *JobService {
+	return &JobService{
+		query:  database.New(db),
+		job:    job,
+		logger: logger,
+	}
+}
+
+// ListJobs lists jobs in a single state, optionally narrowed to a queue, most recent first
+func (js *JobService) ListJobs(ctx context.Context, state string, queue string, limit int) ([]*models.Job, error) {
+	const op = "JobService.ListJobs"
+	reqId := utils.RequestId(ctx)
+
+	jobState := rivertype.JobState(state)
+	if !isValidJobState(jobState) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, fmt.Sprintf("invalid job state '%s'. job state must be one of %v", state, jobStates), op, reqId, nil)
+	}
+
+	if limit < 1 || limit > 1000 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "limit must be between 1 and 1000", op, reqId, nil)
+	}
+
+	params := river.NewJobListParams().
+		State(jobState).
+		OrderBy(river.JobListOrderByTime, river.SortOrderDesc).
+		First(limit)
+
+	if models.IsNotEmptyTrimmedString(queue) {
+		params = params.Queues(queue)
+	}
+
+	jobRows, err := js.job.JobList(ctx, params)
+	if err != nil {
+		js.logger.Error("failed to list jobs", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to list jobs", op, reqId, err)
+	}
+
+	result := make([]*models.Job, 0, len(jobRows))
+
+	for _, jobRow := range jobRows {
+		result = append(result, toJobModel(jobRow))
+	}
+
+	return result, nil
+}
+
+func (js *JobService) GetJob(ctx context.Context, id int64) (*models.Job, error) {
+	const op = "JobService.GetJob"
+	reqId := utils.RequestId(ctx)
+
+	jobRow, err := js.query.JobGetById(ctx, id)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("job '%d' not found", id), op, reqId, err)
+		}
+		js.logger.Error("failed to get job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get job", op, reqId, err)
+	}
+
+	return toJobModel(jobRow), nil
+}
+
+// RetryJob makes a job available to be worked again right away. running jobs cannot be retried
+func (js *JobService) RetryJob(ctx context.Context, id int64) (*models.Job, error) {
+	const op = "JobService.RetryJob"
+	reqId := utils.RequestId(ctx)
+
+	jobRow, err := js.query.JobRetry(ctx, id)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			if _, err := js.GetJob(ctx, id); err != nil {
+				return nil, err
+			}
+			return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("job '%d' is running or already available and cannot be retried", id), op, reqId, nil)
+		}
+		js.logger.Error("failed to retry job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to retry job", op, reqId, err)
+	}
+
+	js.logger.Info("job retried", zap.Int64("job_id", id), zapfield.Operation(op), zapfield.RequestId(reqId))
+
+	return toJobModel(jobRow), nil
+}
+
+// CancelJob cancels a job that has not finished. running jobs are cancelled by the client working them
+func (js *JobService) CancelJob(ctx context.Context, id int64) (*models.Job, error) {
+	const op = "JobService.CancelJob"
+	reqId := utils.RequestId(ctx)
+
+	jobRow, err := js.job.JobCancel(ctx, id)
+	if err != nil {
+		if errors.Is(err, river.ErrNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("job '%d' not found", id), op, reqId, err)
+		}
+		js.logger.Error("failed to cancel job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to cancel job", op, reqId, err)
+	}
+
+	js.logger.Info("job cancelled", zap.Int64("job_id", id), zapfield.Operation(op), zapfield.RequestId(reqId))
+
+	return toJobModel(jobRow), nil
+}
+
+func isValidJobState(state rivertype.JobState) bool {
+	for _, jobState := range jobStates {
+		if jobState == state {
+			return true
+		}
+	}
+	return false
+}
+
+func toJobModel(jobRow *rivertype.JobRow) *models.Job {
+	jobErrors := make([]*models.JobError, 0, len(jobRow.Errors))
+
+	for _, attemptError := range jobRow.Errors {
+		jobErrors = append(jobErrors, &models.JobError{
+			At:      attemptError.At,
+			Attempt: attemptError.Attempt,
+			Error:   attemptError.Error,
+		})
+	}
+
+	// progress is only informational, metadata that cannot be read leaves it out
+	progress, _ := models.ParseJobProgress(jobRow.Metadata)
+
+	return &models.Job{
+		Id:          jobRow.ID,
+		Kind:        jobRow.Kind,
+		Queue:       jobRow.Queue,
+		State:       string(jobRow.State),
+		Attempt:     jobRow.Attempt,
+		MaxAttempts: jobRow.MaxAttempts,
+		Args:        jobRow.EncodedArgs,
+		Errors:      jobErrors,
+		Progress:    progress,
+		CreatedAt:   jobRow.CreatedAt,
+		ScheduledAt: jobRow.ScheduledAt,
+		AttemptedAt: jobRow.AttemptedAt,
+		FinalizedAt: jobRow.FinalizedAt,
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// CreateObjectBatch checks the request and the objects it selects up front and queues a single job that applies the
+// operation to every one of them. rules that may change until the job gets to an object, like tag rules and locks,
+// are checked per object by the job and reported as failed items
+func (os *ObjectService) CreateObjectBatch(ctx context.Context, objectBatchCreate *models.ObjectBatchCreate) (*models.ObjectBatch, error) {
+	const op = "ObjectService.CreateObjectBatch"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectBatchCreate.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if objectBatchCreate.BypassGovernance && !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin api keys can bypass governance retention", op, reqId, nil)
+	}
+
+	bucket, err := os.getBucketById(ctx, objectBatchCreate.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if objectBatchCreate.Operation == models.ObjectBatchOperationCopy {
+		if _, err = os.getBucketById(ctx, *objectBatchCreate.DestinationBucketId, op); err != nil {
+			return nil, err
+		}
+	}
+
+	objectIds, names, err := os.getObjectBatchItems(ctx, bucket.Id, objectBatchCreate, op)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := json.Marshal(objectBatchCreate.ObjectBatchOptions)
+	if err != nil {
+		os.logger.Error("failed to marshal object batch options", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+	}
+
+	var batchId string
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var err error
+		batchId, err = os.queries.WithTx(tx).ObjectBatchCreate(ctx, &database.ObjectBatchCreateParams{
+			BucketID:  bucket.Id,
+			Operation: objectBatchCreate.Operation,
+			Options:   options,
+		})
+		if err != nil {
+			os.logger.Error("failed to create object batch", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+		}
+
+		if objectBatchCreate.Prefix != nil {
+			_, err = os.queries.WithTx(tx).ObjectBatchItemCreateByPrefix(ctx, &database.ObjectBatchItemCreateByPrefixParams{
+				BatchID:  batchId,
+				BucketID: bucket.Id,
+				Prefix:   *objectBatchCreate.Prefix,
+			})
+		} else {
+			err = os.queries.WithTx(tx).ObjectBatchItemCreateMany(ctx, &database.ObjectBatchItemCreateManyParams{
+				BatchID:   batchId,
+				ObjectIds: objectIds,
+				Names:     names,
+			})
+		}
+		if err != nil {
+			os.logger.Error("failed to create object batch items", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+		}
+
+		jobRow, err := os.job.InsertTx(ctx, tx, jobs.ObjectBatch{
+			BatchId:      batchId,
+			TraceContext: tracing.NewTraceContext(ctx),
+		}, nil)
+		if err != nil {
+			os.logger.Error("failed to create object batch job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+		}
+
+		err = os.queries.WithTx(tx).ObjectBatchUpdateJobId(ctx, &database.ObjectBatchUpdateJobIdParams{
+			ID:    batchId,
+			JobID: &jobRow.ID,
+		})
+		if err != nil {
+			os.logger.Error("failed to update object batch job id", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.GetObjectBatch(ctx, bucket.Id, batchId, "", 0, 0)
+}
+
+// getObjectBatchItems checks the objects a batch selects. listed objects must all exist in the bucket and be uploaded,
+// they are returned along with their names. objects selected by prefix are only counted, they are recorded as items
+// straight from the catalog
+func (os *ObjectService) getObjectBatchItems(ctx context.Context, bucketId string, objectBatchCreate *models.ObjectBatchCreate, op string) ([]string, []string, error) {
+	reqId := utils.RequestId(ctx)
+
+	if objectBatchCreate.Prefix != nil {
+		count, err := os.queries.ObjectCountCompletedByBucketIdAndPrefix(ctx, &database.ObjectCountCompletedByBucketIdAndPrefixParams{
+			BucketID: bucketId,
+			Prefix:   *objectBatchCreate.Prefix,
+		})
+		if err != nil {
+			os.logger.Error("failed to count objects by prefix", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return nil, nil, srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+		}
+
+		if count == 0 {
+			return nil, nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("no uploaded objects found with prefix '%s'", *objectBatchCreate.Prefix), op, reqId, nil)
+		}
+
+		if count > models.ObjectBatchMaxItems {
+			return nil, nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("prefix '%s' selects %d objects. a batch can hold at most %d objects", *objectBatchCreate.Prefix, count, models.ObjectBatchMaxItems), op, reqId, nil)
+		}
+
+		return nil, nil, nil
+	}
+
+	objects, err := os.queries.ObjectListByBucketIdAndIds(ctx, &database.ObjectListByBucketIdAndIdsParams{
+		BucketID: bucketId,
+		Ids:      objectBatchCreate.ObjectIds,
+	})
+	if err != nil {
+		os.logger.Error("failed to list objects by ids", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, nil, srverr.NewServiceError(srverr.UnknownError, "failed to create object batch", op, reqId, err)
+	}
+
+	found := make(map[string]*database.StorageObject, len(objects))
+	for _, object := range objects {
+		found[object.ID] = object
+	}
+
+	objectIds := make([]string, 0, len(found))
+	names := make([]string, 0, len(found))
+	missing := make([]string, 0)
+	pending := make([]string, 0)
+	listed := make(map[string]bool, len(objectBatchCreate.ObjectIds))
+
+	for _, objectId := range objectBatchCreate.ObjectIds {
+		// an object listed twice is worked once
+		if listed[objectId] {
+			continue
+		}
+		listed[objectId] = true
+
+		object, ok := found[objectId]
+		if !ok {
+			missing = append(missing, objectId)
+			continue
+		}
+
+		if object.UploadStatus == models.ObjectUploadStatusPending {
+			pending = append(pending, objectId)
+			continue
+		}
+
+		objectIds = append(objectIds, object.ID)
+		names = append(names, object.Name)
+	}
+
+	if len(missing) > 0 {
+		return nil, nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("objects [%s] not found in bucket '%s'", strings.Join(missing, ", "), bucketId), op, reqId, nil)
+	}
+
+	if len(pending) > 0 {
+		return nil, nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for objects [%s]", strings.Join(pending, ", ")), op, reqId, nil)
+	}
+
+	return objectIds, names, nil
+}
+
+// GetObjectBatch returns how far a batch got along with the results of its items, narrowed to the items of status
+// when it is set. a zero limit leaves the items out
+func (os *ObjectService) GetObjectBatch(ctx context.Context, bucketId string, batchId string, status string, limit int32, offset int32) (*models.ObjectBatch, error) {
+	const op = "ObjectService.GetObjectBatch"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(batchId) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "batch_id cannot be empty. batch_id is required to get object batch", op, reqId, nil)
+	}
+
+	if status != "" && status != models.ObjectBatchItemStatusPending && status != models.ObjectBatchItemStatusSucceeded && status != models.ObjectBatchItemStatusFailed {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, fmt.Sprintf("invalid item status '%s'. item status must be one of pending, succeeded or failed", status), op, reqId, nil)
+	}
+
+	if limit < 0 || limit > 1000 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "limit must be between 0 and 1000", op, reqId, nil)
+	}
+
+	if offset < 0 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "offset cannot be negative", op, reqId, nil)
+	}
+
+	batch, err := os.queries.ObjectBatchGetByBucketIdAndId(ctx, &database.ObjectBatchGetByBucketIdAndIdParams{
+		BucketID: bucketId,
+		ID:       batchId,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object batch '%s' not found", batchId), op, reqId, err)
+		}
+		os.logger.Error("failed to get object batch", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object batch", op, reqId, err)
+	}
+
+	result := &models.ObjectBatch{
+		Id:        batch.ID,
+		BucketId:  batch.BucketID,
+		Operation: batch.Operation,
+		JobId:     batch.JobID,
+		Items:     make([]*models.ObjectBatchItem, 0),
+		CreatedAt: batch.CreatedAt,
+	}
+
+	if len(batch.Options) > 0 {
+		if err = json.Unmarshal(batch.Options, &result.Options); err != nil {
+			os.logger.Error("failed to unmarshal object batch options", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object batch", op, reqId, err)
+		}
+	}
+
+	if batch.JobID != nil {
+		jobRow, err := os.queries.JobGetById(ctx, *batch.JobID)
+		if err != nil && !database.IsNotFoundError(err) {
+			os.logger.Error("failed to get object batch job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object batch", op, reqId, err)
+		}
+		// river prunes finalized jobs after a while, the counts still tell how the batch went
+		if jobRow != nil {
+			result.State = string(jobRow.State)
+		}
+	}
+
+	counts, err := os.queries.ObjectBatchItemCountByBatchId(ctx, batch.ID)
+	if err != nil {
+		os.logger.Error("failed to count object batch items", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object batch", op, reqId, err)
+	}
+
+	for _, count := range counts {
+		result.Total += count.Count
+		switch count.Status {
+		case models.ObjectBatchItemStatusPending:
+			result.Pending = count.Count
+		case models.ObjectBatchItemStatusSucceeded:
+			result.Succeeded = count.Count
+		case models.ObjectBatchItemStatusFailed:
+			result.Failed = count.Count
+		}
+	}
+
+	if limit == 0 {
+		return result, nil
+	}
+
+	var statusFilter *string
+	if status != "" {
+		statusFilter = &status
+	}
+
+	items, err := os.queries.ObjectBatchItemListByBatchId(ctx, &database.ObjectBatchItemListByBatchIdParams{
+		BatchID: batch.ID,
+		Status:  statusFilter,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		os.logger.Error("failed to list object batch items", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object batch", op, reqId, err)
+	}
+
+	for _, item := range items {
+		result.Items = append(result.Items, &models.ObjectBatchItem{
+			ObjectId:  item.ObjectID,
+			Name:      item.Name,
+			Status:    item.Status,
+			Error:     item.Error,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+
+	return result, nil
+}
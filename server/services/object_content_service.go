@@ -0,0 +1,813 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// the methods in this file move object content through the service instead of handing out pre-signed urls,
+// they back the s3 gateway and apply the same bucket policies as the pre-signed upload sessions
+
+// putObjectCompletionDelay is when the completion job cleans up an object whose upload never finished,
+// for example because the process stopped while streaming it
+const putObjectCompletionDelay = time.Hour
+
+// GetObjectByName returns an object of a bucket by its name
+func (os *ObjectService) GetObjectByName(ctx context.Context, bucketId string, name string) (*models.Object, error) {
+	const op = "ObjectService.GetObjectByName"
+
+	if _, err := os.getBucketById(ctx, bucketId, op); err != nil {
+		return nil, err
+	}
+
+	object, err := os.getObjectByName(ctx, bucketId, name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return toObjectModel(object), nil
+}
+
+// ListObjectsByPrefix lists the completed objects whose name starts with prefix and sorts after startAfter in
+// binary order. an empty page is not an error since listing past the last object is how callers stop paging
+func (os *ObjectService) ListObjectsByPrefix(ctx context.Context, bucketId string, prefix string, startAfter string, limit int32) ([]*models.Object, error) {
+	const op = "ObjectService.ListObjectsByPrefix"
+	reqId := utils.RequestId(ctx)
+
+	if limit < 1 || limit > 1000 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "limit must be between 1 and 1000", op, reqId, nil)
+	}
+
+	if _, err := os.getBucketById(ctx, bucketId, op); err != nil {
+		return nil, err
+	}
+
+	objects, err := os.queries.ObjectListCompletedByBucketIdAndPrefix(ctx, &database.ObjectListCompletedByBucketIdAndPrefixParams{
+		BucketID:   bucketId,
+		Prefix:     prefix,
+		StartAfter: startAfter,
+		Limit:      limit,
+	})
+	if err != nil {
+		os.logger.Error("failed to list objects by prefix", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to list objects", op, reqId, err)
+	}
+
+	result := make([]*models.Object, 0, len(objects))
+
+	for _, object := range objects {
+		result = append(result, toObjectModel(object))
+	}
+
+	return result, nil
+}
+
+// PutObject streams the content into storage and records it in the catalog. an existing completed object with the
+// same name is overwritten along with its mime type and metadata
+func (os *ObjectService) PutObject(ctx context.Context, objectPut *models.ObjectPut) (*models.StoredObject, error) {
+	const op = "ObjectService.PutObject"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectPut.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	bucket, err := os.getBucketById(ctx, objectPut.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType, err := os.checkObjectPolicies(ctx, bucket, objectPut.Name, objectPut.MimeType, objectPut.Size, op)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := os.findObjectByName(ctx, bucket.Id, objectPut.Name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	objectUpload := &storage.ObjectUpload{
+		Bucket:        bucket.StoragePrefix,
+		Name:          objectPut.Name,
+		ContentType:   *mimeType,
+		ContentLength: objectPut.Size,
+		Content:       objectPut.Content,
+	}
+
+	if existing != nil {
+		eTag, err := os.storage.UploadObject(ctx, objectUpload)
+		if err != nil {
+			os.logger.Error("failed to upload object to storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return nil, srverr.NewServiceError(srverr.UnknownError, "failed to put object", op, reqId, err)
+		}
+
+		if err = os.updateObjectContent(ctx, bucket, existing.ID, *mimeType, objectPut.Size, metadataToBytes(objectPut.Metadata), op); err != nil {
+			return nil, err
+		}
+
+		return os.getStoredObject(ctx, existing.ID, eTag, op)
+	}
+
+	var id string
+
+	// the pending object and its completion job are committed before the upload starts so an upload that never
+	// finishes is cleaned up instead of holding the name forever
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		id, err = os.queries.WithTx(tx).ObjectCreate(ctx, &database.ObjectCreateParams{
+			BucketID:     bucket.Id,
+			Name:         objectPut.Name,
+			ContentType:  mimeType,
+			Size:         objectPut.Size,
+			Metadata:     metadataToBytes(objectPut.Metadata),
+			UploadStatus: models.ObjectUploadStatusPending,
+		})
+		if err != nil {
+			if database.IsConflictError(err) {
+				return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object with name '%s' already exists", objectPut.Name), op, reqId, err)
+			}
+			os.logger.Error("failed to create object in database", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to put object", op, reqId, err)
+		}
+
+		_, err = os.job.InsertTx(ctx, tx, jobs.PreSignedUploadSessionCompletion{
+			ObjectId:     id,
+			TraceContext: tracing.NewTraceContext(ctx),
+		}, &river.InsertOpts{
+			ScheduledAt: time.Now().Add(putObjectCompletionDelay),
+		})
+		if err != nil {
+			os.logger.Error("failed to create object upload completion job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to put object", op, reqId, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eTag, err := os.storage.UploadObject(ctx, objectUpload)
+	if err != nil {
+		os.logger.Error("failed to upload object to storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		if deleteErr := os.queries.ObjectDelete(ctx, id); deleteErr != nil {
+			os.logger.Error("failed to delete object of failed upload", zap.Error(deleteErr), zapfield.Operation(op), zapfield.RequestId(reqId))
+		}
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to put object", op, reqId, err)
+	}
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		return os.completeUpload(ctx, tx, id, *mimeType, bucket, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.getStoredObject(ctx, id, eTag, op)
+}
+
+// StatObject returns a completed object along with the etag of its content
+func (os *ObjectService) StatObject(ctx context.Context, bucketId string, name string) (*models.StoredObject, error) {
+	const op = "ObjectService.StatObject"
+	reqId := utils.RequestId(ctx)
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := os.getCompletedObjectByName(ctx, bucketId, name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	objectHead := &storage.ObjectHead{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+	}
+
+	objectInfo, err := os.storage.HeadObject(ctx, objectHead)
+	if replica := os.replicaFallback(ctx, bucket, object, err, op); replica != nil {
+		objectInfo, err = replica.HeadObject(ctx, objectHead)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("content of object '%s' not found in storage", object.ID), op, reqId, err)
+		}
+		os.logger.Error("failed to head object in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	return &models.StoredObject{
+		Object: *toObjectModel(object),
+		ETag:   objectInfo.ETag,
+	}, nil
+}
+
+// OpenObject opens the content of a completed object, byteRange is an optional http range header value
+func (os *ObjectService) OpenObject(ctx context.Context, bucketId string, name string, byteRange *string) (*models.ObjectContent, error) {
+	const op = "ObjectService.OpenObject"
+	reqId := utils.RequestId(ctx)
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := os.getCompletedObjectByName(ctx, bucketId, name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	objectGet := &storage.ObjectGet{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+		Range:  byteRange,
+	}
+
+	content, err := os.storage.GetObject(ctx, objectGet)
+	if replica := os.replicaFallback(ctx, bucket, object, err, op); replica != nil {
+		content, err = replica.GetObject(ctx, objectGet)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("content of object '%s' not found in storage", object.ID), op, reqId, err)
+		}
+		if errors.Is(err, storage.ErrInvalidRange) {
+			return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("range '%s' is not satisfiable for object '%s'", *byteRange, object.ID), op, reqId, err)
+		}
+		os.logger.Error("failed to get object from storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	os.touchObject(ctx, object.ID, op)
+
+	return &models.ObjectContent{
+		StoredObject: models.StoredObject{
+			Object: *toObjectModel(object),
+			ETag:   content.ETag,
+		},
+		ContentLength: content.ContentLength,
+		ContentRange:  content.ContentRange,
+		Body:          content.Body,
+	}, nil
+}
+
+// replicaFallback returns the replica to read the content of an object from when reading it from the primary storage
+// failed with err, or nil when it did not fail or the object has no copy on a replica. missing content and ranges that
+// are not satisfiable are answers of the primary storage, not failures
+func (os *ObjectService) replicaFallback(ctx context.Context, bucket *models.Bucket, object *database.StorageObject, err error, op string) *storage.Storage {
+	if err == nil || errors.Is(err, storage.ErrObjectNotFound) || errors.Is(err, storage.ErrInvalidRange) {
+		return nil
+	}
+
+	if object.ReplicationStatus == nil || *object.ReplicationStatus != models.ObjectReplicationStatusReplicated {
+		return nil
+	}
+
+	replica := os.replicas.Get(bucket.Replica)
+	if replica == nil {
+		return nil
+	}
+
+	os.logger.Warn(
+		"reading object from replica after primary storage failed",
+		zap.Error(err),
+		zapfield.Operation(op),
+		zapfield.RequestId(utils.RequestId(ctx)),
+		zap.String("object_id", object.ID),
+		zap.String("replica", *bucket.Replica),
+	)
+
+	return replica
+}
+
+// CopyObject copies a completed object server side, the destination bucket policies apply to the copy
+func (os *ObjectService) CopyObject(ctx context.Context, objectCopy *models.ObjectCopy) (*models.StoredObject, error) {
+	const op = "ObjectService.CopyObject"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectCopy.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if objectCopy.SourceBucketId == objectCopy.DestinationBucketId && objectCopy.SourceName == objectCopy.DestinationName && !objectCopy.ReplaceMetadata {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, "an object can only be copied onto itself when its mime type and metadata are replaced", op, reqId, nil)
+	}
+
+	sourceBucket, err := os.getBucketById(ctx, objectCopy.SourceBucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	destinationBucket, err := os.getBucketById(ctx, objectCopy.DestinationBucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := os.getCompletedObjectByName(ctx, sourceBucket.Id, objectCopy.SourceName, op)
+	if err != nil {
+		return nil, err
+	}
+
+	requestedMimeType := &source.MimeType
+	metadata := source.Metadata
+	if objectCopy.ReplaceMetadata {
+		requestedMimeType = objectCopy.MimeType
+		metadata = metadataToBytes(objectCopy.Metadata)
+	}
+
+	mimeType, err := os.checkObjectPolicies(ctx, destinationBucket, objectCopy.DestinationName, requestedMimeType, source.Size, op)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := os.findObjectByName(ctx, destinationBucket.Id, objectCopy.DestinationName, op)
+	if err != nil {
+		return nil, err
+	}
+
+	storageObjectCopy := &storage.ObjectCopy{
+		SourceBucket:      sourceBucket.StoragePrefix,
+		SourceName:        source.Name,
+		DestinationBucket: destinationBucket.StoragePrefix,
+		DestinationName:   objectCopy.DestinationName,
+	}
+	if objectCopy.ReplaceMetadata {
+		storageObjectCopy.ContentType = mimeType
+	}
+
+	eTag, err := os.storage.CopyObject(ctx, storageObjectCopy)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("content of object '%s' not found in storage", source.ID), op, reqId, err)
+		}
+		os.logger.Error("failed to copy object in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to copy object", op, reqId, err)
+	}
+
+	id, err := os.saveCompletedObject(ctx, destinationBucket, objectCopy.DestinationName, existing, *mimeType, source.Size, metadata, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.getStoredObject(ctx, id, eTag, op)
+}
+
+// DeleteObjectByName deletes an object from storage and the catalog right away instead of through the deletion job,
+// s3 clients expect a deleted key to be gone as soon as the delete returns
+func (os *ObjectService) DeleteObjectByName(ctx context.Context, bucketId string, name string) error {
+	const op = "ObjectService.DeleteObjectByName"
+	reqId := utils.RequestId(ctx)
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return err
+	}
+
+	object, err := os.getObjectByName(ctx, bucketId, name, op)
+	if err != nil {
+		return err
+	}
+
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for object '%s'. delete operation can only be performed on objects that have been uploaded", object.ID), op, reqId, nil)
+	}
+
+	if err = checkObjectDeletable(ctx, os.queries, object.ID, os.logger, op); err != nil {
+		return err
+	}
+
+	if err = checkObjectUnlocked(ctx, os.queries, object, false, os.logger, op); err != nil {
+		return err
+	}
+
+	err = os.storage.DeleteObject(ctx, &storage.ObjectDelete{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		os.logger.Error("failed to delete object from storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to delete object", op, reqId, err)
+	}
+
+	os.deleteRenders(ctx, bucket.Id, object.ID, op)
+
+	if params := jobs.NewObjectReplicaDeletionJobs(ctx, bucket.Replica, bucket.StoragePrefix, object.Name); len(params) > 0 {
+		if _, err = os.job.InsertMany(ctx, params); err != nil {
+			os.logger.Error("failed to create replica deletion job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to delete object", op, reqId, err)
+		}
+	}
+
+	if err = os.queries.ObjectDelete(ctx, object.ID); err != nil {
+		os.logger.Error("failed to delete object from database", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to delete object", op, reqId, err)
+	}
+
+	return nil
+}
+
+// CreateMultipartUpload starts a multipart upload in storage, the object is only recorded in the catalog once the
+// upload is completed since its size is not known before that
+func (os *ObjectService) CreateMultipartUpload(ctx context.Context, multipartUploadCreate *models.MultipartUploadCreate) (string, error) {
+	const op = "ObjectService.CreateMultipartUpload"
+	reqId := utils.RequestId(ctx)
+
+	if err := multipartUploadCreate.IsValid(); err != nil {
+		return "", srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	bucket, err := os.getBucketById(ctx, multipartUploadCreate.BucketId, op)
+	if err != nil {
+		return "", err
+	}
+
+	mimeType, err := os.checkObjectPolicies(ctx, bucket, multipartUploadCreate.Name, multipartUploadCreate.MimeType, 0, op)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = os.findObjectByName(ctx, bucket.Id, multipartUploadCreate.Name, op); err != nil {
+		return "", err
+	}
+
+	uploadId, err := os.storage.CreateMultipartUpload(ctx, &storage.MultipartUploadCreate{
+		Bucket:      bucket.StoragePrefix,
+		Name:        multipartUploadCreate.Name,
+		ContentType: *mimeType,
+		Metadata:    metadataToStrings(multipartUploadCreate.Metadata),
+	})
+	if err != nil {
+		os.logger.Error("failed to create multipart upload in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return "", srverr.NewServiceError(srverr.UnknownError, "failed to create multipart upload", op, reqId, err)
+	}
+
+	return uploadId, nil
+}
+
+// UploadPart streams one part of a multipart upload and returns its etag
+func (os *ObjectService) UploadPart(ctx context.Context, multipartUploadPart *models.MultipartUploadPart) (string, error) {
+	const op = "ObjectService.UploadPart"
+	reqId := utils.RequestId(ctx)
+
+	if err := multipartUploadPart.IsValid(); err != nil {
+		return "", srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	bucket, err := os.getBucketById(ctx, multipartUploadPart.BucketId, op)
+	if err != nil {
+		return "", err
+	}
+
+	if bucket.MaxAllowedObjectSize != nil && multipartUploadPart.Size > *bucket.MaxAllowedObjectSize {
+		return "", srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object size is too large. max allowed object size is %d bytes", *bucket.MaxAllowedObjectSize), op, reqId, nil)
+	}
+
+	eTag, err := os.storage.UploadPart(ctx, &storage.MultipartUploadPart{
+		Bucket:        bucket.StoragePrefix,
+		Name:          multipartUploadPart.Name,
+		UploadId:      multipartUploadPart.UploadId,
+		PartNumber:    multipartUploadPart.PartNumber,
+		ContentLength: multipartUploadPart.Size,
+		Content:       multipartUploadPart.Content,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrMultipartUploadNotFound) {
+			return "", srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("multipart upload '%s' not found", multipartUploadPart.UploadId), op, reqId, err)
+		}
+		os.logger.Error("failed to upload part to storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return "", srverr.NewServiceError(srverr.UnknownError, "failed to upload part", op, reqId, err)
+	}
+
+	return eTag, nil
+}
+
+// CompleteMultipartUpload assembles the parts and records the object in the catalog. the total size is checked
+// against the bucket before assembling so an oversized upload never replaces an existing object
+func (os *ObjectService) CompleteMultipartUpload(ctx context.Context, multipartUploadComplete *models.MultipartUploadComplete) (*models.StoredObject, error) {
+	const op = "ObjectService.CompleteMultipartUpload"
+	reqId := utils.RequestId(ctx)
+
+	if err := multipartUploadComplete.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	bucket, err := os.getBucketById(ctx, multipartUploadComplete.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	multipartUpload := &storage.MultipartUpload{
+		Bucket:   bucket.StoragePrefix,
+		Name:     multipartUploadComplete.Name,
+		UploadId: multipartUploadComplete.UploadId,
+	}
+
+	uploadedParts, err := os.storage.ListParts(ctx, multipartUpload)
+	if err != nil {
+		if errors.Is(err, storage.ErrMultipartUploadNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("multipart upload '%s' not found", multipartUploadComplete.UploadId), op, reqId, err)
+		}
+		os.logger.Error("failed to list parts in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to complete multipart upload", op, reqId, err)
+	}
+
+	uploadedPartSizes := make(map[int32]int64, len(uploadedParts))
+	for _, part := range uploadedParts {
+		uploadedPartSizes[part.PartNumber] = part.Size
+	}
+
+	var size int64
+	parts := make([]storage.CompletedPart, 0, len(multipartUploadComplete.Parts))
+	for _, part := range multipartUploadComplete.Parts {
+		partSize, ok := uploadedPartSizes[part.PartNumber]
+		if !ok {
+			return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("part %d has not been uploaded", part.PartNumber), op, reqId, storage.ErrInvalidPart)
+		}
+		size += partSize
+		parts = append(parts, storage.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	if size <= 0 {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, "object size must be greater than 0", op, reqId, nil)
+	}
+
+	if bucket.MaxAllowedObjectSize != nil && size > *bucket.MaxAllowedObjectSize {
+		if err = os.storage.AbortMultipartUpload(ctx, multipartUpload); err != nil {
+			os.logger.Warn("failed to abort oversized multipart upload", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		}
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object size is too large. max allowed object size is %d bytes", *bucket.MaxAllowedObjectSize), op, reqId, nil)
+	}
+
+	existing, err := os.findObjectByName(ctx, bucket.Id, multipartUploadComplete.Name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	eTag, err := os.storage.CompleteMultipartUpload(ctx, &storage.MultipartUploadComplete{
+		Bucket:   bucket.StoragePrefix,
+		Name:     multipartUploadComplete.Name,
+		UploadId: multipartUploadComplete.UploadId,
+		Parts:    parts,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrMultipartUploadNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("multipart upload '%s' not found", multipartUploadComplete.UploadId), op, reqId, err)
+		}
+		if errors.Is(err, storage.ErrInvalidPart) {
+			return nil, srverr.NewServiceError(srverr.BadRequestError, err.Error(), op, reqId, err)
+		}
+		os.logger.Error("failed to complete multipart upload in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to complete multipart upload", op, reqId, err)
+	}
+
+	// the mime type and metadata were given when the upload was created and are only kept by storage until now
+	objectInfo, err := os.storage.HeadObject(ctx, &storage.ObjectHead{
+		Bucket: bucket.StoragePrefix,
+		Name:   multipartUploadComplete.Name,
+	})
+	if err != nil {
+		os.logger.Error("failed to head completed multipart upload in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to complete multipart upload", op, reqId, err)
+	}
+
+	id, err := os.saveCompletedObject(ctx, bucket, multipartUploadComplete.Name, existing, objectInfo.ContentType, size, metadataToBytes(stringsToMetadata(objectInfo.Metadata)), op)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.getStoredObject(ctx, id, eTag, op)
+}
+
+func (os *ObjectService) AbortMultipartUpload(ctx context.Context, bucketId string, name string, uploadId string) error {
+	const op = "ObjectService.AbortMultipartUpload"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(uploadId) {
+		return srverr.NewServiceError(srverr.InvalidInputError, "upload id cannot be empty. upload id is required to abort a multipart upload", op, reqId, nil)
+	}
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return err
+	}
+
+	err = os.storage.AbortMultipartUpload(ctx, &storage.MultipartUpload{
+		Bucket:   bucket.StoragePrefix,
+		Name:     name,
+		UploadId: uploadId,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrMultipartUploadNotFound) {
+			return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("multipart upload '%s' not found", uploadId), op, reqId, err)
+		}
+		os.logger.Error("failed to abort multipart upload in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to abort multipart upload", op, reqId, err)
+	}
+
+	return nil
+}
+
+// checkObjectPolicies applies the mime type and size policies of the bucket and returns the mime type to store,
+// a size of 0 skips the size check for uploads whose size is not known yet
+func (os *ObjectService) checkObjectPolicies(ctx context.Context, bucket *models.Bucket, name string, mimeType *string, size int64, op string) (*string, error) {
+	reqId := utils.RequestId(ctx)
+
+	mimeType, err := determineMimeType(bucket, &models.PreSignedUploadSessionCreate{
+		Name:     name,
+		MimeType: mimeType,
+	})
+	if err != nil {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, err.Error(), op, reqId, err)
+	}
+
+	if bucket.MaxAllowedObjectSize != nil && size > *bucket.MaxAllowedObjectSize {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object size is too large. max allowed object size is %d bytes", *bucket.MaxAllowedObjectSize), op, reqId, nil)
+	}
+
+	return mimeType, nil
+}
+
+// findObjectByName looks up the object an upload would overwrite. it returns nil when the object does not exist, a
+// conflict while another upload of it is pending and forbidden when a tag rule, a legal hold or retention protects it
+func (os *ObjectService) findObjectByName(ctx context.Context, bucketId string, name string, op string) (*database.StorageObject, error) {
+	reqId := utils.RequestId(ctx)
+
+	object, err := os.queries.ObjectGetByBucketIdAndName(ctx, &database.ObjectGetByBucketIdAndNameParams{
+		BucketID: bucketId,
+		Name:     name,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, nil
+		}
+		os.logger.Error("failed to get object by name", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("an upload of object '%s' is already in progress", name), op, reqId, nil)
+	}
+
+	if err = checkObjectDeletable(ctx, os.queries, object.ID, os.logger, op); err != nil {
+		return nil, err
+	}
+
+	if err = checkObjectUnlocked(ctx, os.queries, object, false, os.logger, op); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func (os *ObjectService) getObjectByName(ctx context.Context, bucketId string, name string, op string) (*database.StorageObject, error) {
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(name) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "object name cannot be empty. object name is required", op, reqId, nil)
+	}
+
+	object, err := os.queries.ObjectGetByBucketIdAndName(ctx, &database.ObjectGetByBucketIdAndNameParams{
+		BucketID: bucketId,
+		Name:     name,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", name), op, reqId, err)
+		}
+		os.logger.Error("failed to get object by name", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	return object, nil
+}
+
+func (os *ObjectService) getCompletedObjectByName(ctx context.Context, bucketId string, name string, op string) (*database.StorageObject, error) {
+	object, err := os.getObjectByName(ctx, bucketId, name, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkObjectServable(ctx, object, op); err != nil {
+		return nil, err
+	}
+
+	if object.UploadStatus != models.ObjectUploadStatusCompleted {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' upload has not been completed", object.ID), op, utils.RequestId(ctx), nil)
+	}
+
+	return object, nil
+}
+
+// saveCompletedObject records content that is already in storage, updating the existing object when there is one
+func (os *ObjectService) saveCompletedObject(ctx context.Context, bucket *models.Bucket, name string, existing *database.StorageObject, mimeType string, size int64, metadata []byte, op string) (string, error) {
+	reqId := utils.RequestId(ctx)
+
+	if existing != nil {
+		// renders are keyed by the etag of the content they came from so the ones of the replaced content are unreachable
+		os.deleteRenders(ctx, bucket.Id, existing.ID, op)
+		return existing.ID, os.updateObjectContent(ctx, bucket, existing.ID, mimeType, size, metadata, op)
+	}
+
+	var id string
+
+	err := os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var err error
+		id, err = os.queries.WithTx(tx).ObjectCreate(ctx, &database.ObjectCreateParams{
+			BucketID:    bucket.Id,
+			Name:        name,
+			ContentType: &mimeType,
+			Size:        size,
+			Metadata:    metadata,
+			// completed right below in the same transaction, held for a malware scan when scanning is on
+			UploadStatus: models.ObjectUploadStatusPending,
+		})
+		if err != nil {
+			if database.IsConflictError(err) {
+				return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object with name '%s' already exists", name), op, reqId, err)
+			}
+			os.logger.Error("failed to create object in database", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to save object", op, reqId, err)
+		}
+
+		return os.completeUpload(ctx, tx, id, mimeType, bucket, op)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// updateObjectContent records new content of an existing object and completes its upload again so the new content is
+// scanned and processed, the metadata the previous content was processed into is replaced along with the rest
+func (os *ObjectService) updateObjectContent(ctx context.Context, bucket *models.Bucket, id string, mimeType string, size int64, metadata []byte, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	return os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		err := os.queries.WithTx(tx).ObjectUpdate(ctx, &database.ObjectUpdateParams{
+			ID:       id,
+			Size:     &size,
+			MimeType: &mimeType,
+			Metadata: metadata,
+		})
+		if err != nil {
+			os.logger.Error("failed to update object in database", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to save object", op, reqId, err)
+		}
+
+		return os.completeUpload(ctx, tx, id, mimeType, bucket, op)
+	})
+}
+
+func (os *ObjectService) getStoredObject(ctx context.Context, id string, eTag string, op string) (*models.StoredObject, error) {
+	reqId := utils.RequestId(ctx)
+
+	object, err := os.queries.ObjectGetById(ctx, id)
+	if err != nil {
+		os.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	return &models.StoredObject{
+		Object: *toObjectModel(object),
+		ETag:   eTag,
+	}, nil
+}
+
+func toObjectModel(object *database.StorageObject) *models.Object {
+	return &models.Object{
+		Id:                object.ID,
+		Version:           object.Version,
+		BucketId:          object.BucketID,
+		Name:              object.Name,
+		MimeType:          object.MimeType,
+		Size:              object.Size,
+		Metadata:          bytesToMetadata(object.Metadata),
+		UploadStatus:      object.UploadStatus,
+		LegalHold:         object.LegalHold,
+		RetentionMode:     object.RetentionMode,
+		RetainUntil:       object.RetainUntil,
+		ReplicationStatus: object.ReplicationStatus,
+		ReplicatedAt:      object.ReplicatedAt,
+		Tier:              object.Tier,
+		LastAccessedAt:    object.LastAccessedAt,
+		CreatedAt:         object.CreatedAt,
+		UpdatedAt:         object.UpdatedAt,
+	}
+}
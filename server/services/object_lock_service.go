@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// PutObjectLegalHold places or releases the legal hold of an object, every change is audited. holds are released by
+// admin keys only
+func (os *ObjectService) PutObjectLegalHold(ctx context.Context, objectLegalHoldPut *models.ObjectLegalHoldPut) (*models.Object, error) {
+	const op = "ObjectService.PutObjectLegalHold"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectLegalHoldPut.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	object, err := os.getTaggableObject(ctx, objectLegalHoldPut.BucketId, objectLegalHoldPut.ObjectId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkLegalHoldChange(ctx, object, objectLegalHoldPut.LegalHold, op); err != nil {
+		return nil, err
+	}
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		rows, err := os.queries.WithTx(tx).ObjectUpdateLegalHold(ctx, &database.ObjectUpdateLegalHoldParams{
+			ID:           object.ID,
+			LegalHold:    objectLegalHoldPut.LegalHold,
+			AllowRelease: utils.PrincipalFromContext(ctx).Admin,
+		})
+		if err != nil {
+			os.logger.Error("failed to update object legal hold", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to update object legal hold", op, reqId, err)
+		}
+		if rows == 0 {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' was placed under legal hold since it was read, only admin keys can release it", object.ID), op, reqId, nil)
+		}
+
+		return createAuditEvent(ctx, os.queries.WithTx(tx), models.AuditActionObjectLegalHold, object.BucketID, &object.ID, map[string]any{
+			"legal_hold": objectLegalHoldPut.LegalHold,
+		}, os.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.GetObject(ctx, object.BucketID, object.ID)
+}
+
+// PutObjectRetention replaces the retention of an object. retention can always be extended, compliance retention can
+// never be shortened or removed and governance retention only by admin keys bypassing it
+func (os *ObjectService) PutObjectRetention(ctx context.Context, objectRetentionPut *models.ObjectRetentionPut) (*models.Object, error) {
+	const op = "ObjectService.PutObjectRetention"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectRetentionPut.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	object, err := os.getTaggableObject(ctx, objectRetentionPut.BucketId, objectRetentionPut.ObjectId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	bypassed, err := checkRetentionChange(ctx, object, objectRetentionPut, op)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		err := os.queries.WithTx(tx).ObjectUpdateRetention(ctx, &database.ObjectUpdateRetentionParams{
+			ID:            object.ID,
+			RetentionMode: objectRetentionPut.Mode,
+			RetainUntil:   objectRetentionPut.RetainUntil,
+		})
+		if err != nil {
+			os.logger.Error("failed to update object retention", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to update object retention", op, reqId, err)
+		}
+
+		action := models.AuditActionObjectRetention
+		if bypassed {
+			action = models.AuditActionObjectRetentionBypass
+		}
+
+		return createAuditEvent(ctx, os.queries.WithTx(tx), action, object.BucketID, &object.ID, map[string]any{
+			"previous_mode":         object.RetentionMode,
+			"previous_retain_until": object.RetainUntil,
+			"mode":                  objectRetentionPut.Mode,
+			"retain_until":          objectRetentionPut.RetainUntil,
+		}, os.logger, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.GetObject(ctx, object.BucketID, object.ID)
+}
+
+// checkLegalHoldChange refuses to release the legal hold of an object for keys that are not admin keys, anyone able
+// to release a hold could delete the object right after
+func checkLegalHoldChange(ctx context.Context, object *database.StorageObject, legalHold bool, op string) error {
+	if !object.LegalHold || legalHold || utils.PrincipalFromContext(ctx).Admin {
+		return nil
+	}
+
+	return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is under legal hold, only admin keys can release it", object.ID), op, utils.RequestId(ctx), nil)
+}
+
+// checkRetentionChange refuses to weaken the active retention of an object, it reports whether governance retention
+// is bypassed to allow the change
+func checkRetentionChange(ctx context.Context, object *database.StorageObject, objectRetentionPut *models.ObjectRetentionPut, op string) (bool, error) {
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsObjectLocked(false, object.RetentionMode, object.RetainUntil, false) {
+		return false, nil
+	}
+
+	extended := objectRetentionPut.RetainUntil != nil && !objectRetentionPut.RetainUntil.Before(*object.RetainUntil)
+	if extended && (*objectRetentionPut.Mode == *object.RetentionMode || *objectRetentionPut.Mode == models.ObjectRetentionModeCompliance) {
+		return false, nil
+	}
+
+	if *object.RetentionMode == models.ObjectRetentionModeCompliance {
+		return false, srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is under compliance retention until %s, compliance retention can only be extended", object.ID, object.RetainUntil.Format(time.RFC3339)), op, reqId, nil)
+	}
+
+	if err := checkGovernanceBypass(ctx, objectRetentionPut.BypassGovernance, fmt.Sprintf("object '%s' is under governance retention until %s and it can only be extended", object.ID, object.RetainUntil.Format(time.RFC3339)), op); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkObjectUnlocked refuses to delete or overwrite an object under a legal hold or retention. governance retention
+// is bypassed when asked for by an admin, the bypass is audited
+func checkObjectUnlocked(ctx context.Context, queries *database.Queries, object *database.StorageObject, bypassGovernance bool, logger *zap.Logger, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	if object.LegalHold {
+		return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is under legal hold and cannot be deleted or overwritten", object.ID), op, reqId, nil)
+	}
+
+	if !models.IsObjectLocked(false, object.RetentionMode, object.RetainUntil, false) {
+		return nil
+	}
+
+	if *object.RetentionMode == models.ObjectRetentionModeCompliance {
+		return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is under compliance retention until %s and cannot be deleted or overwritten", object.ID, object.RetainUntil.Format(time.RFC3339)), op, reqId, nil)
+	}
+
+	if err := checkGovernanceBypass(ctx, bypassGovernance, fmt.Sprintf("object '%s' is under governance retention until %s and cannot be deleted or overwritten", object.ID, object.RetainUntil.Format(time.RFC3339)), op); err != nil {
+		return err
+	}
+
+	return createAuditEvent(ctx, queries, models.AuditActionObjectRetentionBypass, object.BucketID, &object.ID, map[string]any{
+		"operation":    op,
+		"retain_until": object.RetainUntil,
+	}, logger, op)
+}
+
+// checkBucketUnlocked refuses to empty or delete a bucket holding objects under a legal hold or retention. governance
+// retention is bypassed when asked for by an admin, the bypass is audited
+func checkBucketUnlocked(ctx context.Context, queries *database.Queries, bucketId string, bypassGovernance bool, logger *zap.Logger, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	bypass := governanceBypass(ctx, bypassGovernance)
+
+	object, err := queries.ObjectGetLockedByBucketId(ctx, &database.ObjectGetLockedByBucketIdParams{
+		BucketID:         bucketId,
+		BypassGovernance: bypass,
+	})
+	if err == nil {
+		if object.LegalHold {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' holds object '%s' under legal hold and cannot be emptied or deleted", bucketId, object.ID), op, reqId, nil)
+		}
+
+		if *object.RetentionMode == models.ObjectRetentionModeCompliance {
+			return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' holds object '%s' under compliance retention until %s and cannot be emptied or deleted", bucketId, object.ID, object.RetainUntil.Format(time.RFC3339)), op, reqId, nil)
+		}
+
+		return checkGovernanceBypass(ctx, bypassGovernance, fmt.Sprintf("bucket '%s' holds object '%s' under governance retention until %s and cannot be emptied or deleted", bucketId, object.ID, object.RetainUntil.Format(time.RFC3339)), op)
+	}
+	if !database.IsNotFoundError(err) {
+		logger.Error("failed to get locked object of bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to check the object locks of the bucket", op, reqId, err)
+	}
+
+	if !bypass {
+		return nil
+	}
+
+	// the bypass is only audited when the bucket holds objects under governance retention
+	_, err = queries.ObjectGetLockedByBucketId(ctx, &database.ObjectGetLockedByBucketIdParams{
+		BucketID:         bucketId,
+		BypassGovernance: false,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		logger.Error("failed to get locked object of bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to check the object locks of the bucket", op, reqId, err)
+	}
+
+	return createAuditEvent(ctx, queries, models.AuditActionBucketRetentionBypass, bucketId, nil, map[string]any{
+		"operation": op,
+	}, logger, op)
+}
+
+// governanceBypass is whether governance retention is bypassed, only admins bypass it when they ask to. jobs are
+// queued with it rather than with what was asked so a later check in the job never bypasses for a non-admin
+func governanceBypass(ctx context.Context, bypassGovernance bool) bool {
+	return bypassGovernance && utils.PrincipalFromContext(ctx).Admin
+}
+
+// checkGovernanceBypass allows what governance retention refuses when an admin asks to bypass it
+func checkGovernanceBypass(ctx context.Context, bypassGovernance bool, locked string, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	if !bypassGovernance {
+		return srverr.NewServiceError(srverr.ForbiddenError, locked+". admin api keys can bypass governance retention", op, reqId, nil)
+	}
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return srverr.NewServiceError(srverr.ForbiddenError, locked+". only admin api keys can bypass governance retention", op, reqId, nil)
+	}
+
+	return nil
+}
+
+// createAuditEvent records who did what to a bucket or object, the event is written with the queries of the change
+// so both are committed together
+func createAuditEvent(ctx context.Context, queries *database.Queries, action string, bucketId string, objectId *string, details map[string]any, logger *zap.Logger, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	// only authenticated requests change locks, the principal is never expected to be missing
+	principal := utils.PrincipalFromContext(ctx)
+	if principal.Id == "" {
+		principal.Id = "unknown"
+	}
+
+	detailsBytes, err := json.Marshal(details)
+	if err != nil {
+		logger.Error("failed to marshal audit event details", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to record audit event", op, reqId, err)
+	}
+
+	err = queries.AuditEventCreate(ctx, &database.AuditEventCreateParams{
+		Action:    action,
+		Principal: principal.Id,
+		BucketID:  &bucketId,
+		ObjectID:  objectId,
+		Details:   detailsBytes,
+		RequestID: &reqId,
+	})
+	if err != nil {
+		logger.Error("failed to create audit event", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to record audit event", op, reqId, err)
+	}
+
+	logger.Info("recorded audit event", zap.String("action", action), zap.String("principal", principal.Id), zapfield.Operation(op), zapfield.RequestId(reqId))
+
+	return nil
+}
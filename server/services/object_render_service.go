@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/imaging"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// RenderObject renders an image object with the requested options. renders are cached in storage under a key derived
+// from the options and the etag of the source, so a source that is overwritten is rendered again instead of serving a
+// stale variant
+func (os *ObjectService) RenderObject(ctx context.Context, objectRender *models.ObjectRender) (*models.RenderedObject, error) {
+	const op = "ObjectService.RenderObject"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectRender.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	bucket, err := os.getBucketById(ctx, objectRender.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := os.queries.ObjectGetById(ctx, objectRender.ObjectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", objectRender.ObjectId), op, reqId, err)
+		}
+		os.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	if object.BucketID != bucket.Id {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", objectRender.ObjectId), op, reqId, nil)
+	}
+
+	if err = checkObjectServable(ctx, object, op); err != nil {
+		return nil, err
+	}
+
+	if object.UploadStatus != models.ObjectUploadStatusCompleted {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for object '%s'. only uploaded objects can be rendered", object.ID), op, reqId, nil)
+	}
+
+	options, err := renderOptions(objectRender, object.MimeType)
+	if err != nil {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object '%s' cannot be rendered. %s", object.ID, err.Error()), op, reqId, err)
+	}
+
+	if object.Size > os.config.ImageRenderMaxInputSize {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object '%s' is too large to be rendered. the maximum size is %d bytes", object.ID, os.config.ImageRenderMaxInputSize), op, reqId, nil)
+	}
+
+	sourceInfo, err := os.storage.HeadObject(ctx, &storage.ObjectHead{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("content of object '%s' not found in storage", object.ID), op, reqId, err)
+		}
+		os.logger.Error("failed to head object in storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to render object", op, reqId, err)
+	}
+
+	cacheName := renderCacheName(bucket.Id, object.ID, sourceInfo.ETag, options)
+
+	cached, err := os.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: storage.RenderCacheBucket,
+		Name:   cacheName,
+	})
+	if err == nil {
+		os.touchObject(ctx, object.ID, op)
+
+		return &models.RenderedObject{
+			MimeType:      cached.ContentType,
+			ContentLength: cached.ContentLength,
+			ETag:          cached.ETag,
+			Body:          cached.Body,
+		}, nil
+	}
+	if !errors.Is(err, storage.ErrObjectNotFound) {
+		os.logger.Warn("failed to get cached render, rendering again", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+	}
+
+	source, err := os.storage.GetObject(ctx, &storage.ObjectGet{
+		Bucket: bucket.StoragePrefix,
+		Name:   object.Name,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("content of object '%s' not found in storage", object.ID), op, reqId, err)
+		}
+		os.logger.Error("failed to get object from storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to render object", op, reqId, err)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(source.Body, os.config.ImageRenderMaxInputSize+1))
+	_ = source.Body.Close()
+	if err != nil {
+		os.logger.Error("failed to read object from storage", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to render object", op, reqId, err)
+	}
+	if int64(len(content)) > os.config.ImageRenderMaxInputSize {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object '%s' is too large to be rendered. the maximum size is %d bytes", object.ID, os.config.ImageRenderMaxInputSize), op, reqId, nil)
+	}
+
+	rendered, err := imaging.Render(content, options, os.config.ImageRenderMaxInputDimension)
+	if err != nil {
+		if errors.Is(err, imaging.ErrInvalidImage) || errors.Is(err, imaging.ErrImageTooLarge) || errors.Is(err, imaging.ErrCropOutOfBounds) {
+			return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object '%s' cannot be rendered. %s", object.ID, err.Error()), op, reqId, err)
+		}
+		os.logger.Error("failed to render object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to render object", op, reqId, err)
+	}
+
+	eTag := fmt.Sprintf(`"%x"`, sha256.Sum256(rendered.Content))
+
+	// the source may have been overwritten between the head and the get, its render must not be cached under the
+	// etag of the content it replaced
+	if source.ETag == sourceInfo.ETag {
+		cachedETag, err := os.storage.UploadObject(ctx, &storage.ObjectUpload{
+			Bucket:        storage.RenderCacheBucket,
+			Name:          cacheName,
+			ContentType:   rendered.ContentType,
+			ContentLength: int64(len(rendered.Content)),
+			Content:       bytes.NewReader(rendered.Content),
+		})
+		if err != nil {
+			os.logger.Warn("failed to cache render", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		} else {
+			eTag = cachedETag
+		}
+	}
+
+	os.touchObject(ctx, object.ID, op)
+
+	return &models.RenderedObject{
+		MimeType:      rendered.ContentType,
+		ContentLength: int64(len(rendered.Content)),
+		ETag:          eTag,
+		Body:          io.NopCloser(bytes.NewReader(rendered.Content)),
+	}, nil
+}
+
+// deleteRenders drops the cached renders of an object, failing to do so only leaves unreachable variants behind so it
+// is logged rather than failing the caller
+func (os *ObjectService) deleteRenders(ctx context.Context, bucketId string, objectId string, op string) {
+	err := os.storage.DeleteObjectsByPrefix(ctx, &storage.ObjectsDeleteByPrefix{
+		Bucket: storage.RenderCacheBucket,
+		Prefix: storage.RenderCachePrefix(bucketId, objectId),
+	})
+	if err != nil {
+		os.logger.Warn("failed to delete cached renders", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(utils.RequestId(ctx)))
+	}
+}
+
+func (os *ObjectService) touchObject(ctx context.Context, objectId string, op string) {
+	if err := os.queries.ObjectUpdateLastAccessedAt(ctx, objectId); err != nil {
+		os.logger.Warn("failed to update object last accessed at", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(utils.RequestId(ctx)))
+	}
+}
+
+func renderOptions(objectRender *models.ObjectRender, mimeType string) (*imaging.Options, error) {
+	format, ok := imaging.DefaultFormat(mimeType)
+	if !ok {
+		return nil, fmt.Errorf("mime type '%s' is not an image that can be rendered", mimeType)
+	}
+
+	options := &imaging.Options{
+		Fit:     imaging.FitContain,
+		Format:  format,
+		Quality: imaging.DefaultQuality,
+	}
+
+	if objectRender.Width != nil {
+		options.Width = *objectRender.Width
+	}
+	if objectRender.Height != nil {
+		options.Height = *objectRender.Height
+	}
+	if objectRender.Fit != nil {
+		options.Fit = *objectRender.Fit
+	}
+	if objectRender.CropX != nil {
+		crop := image.Rect(*objectRender.CropX, *objectRender.CropY, *objectRender.CropX+*objectRender.CropWidth, *objectRender.CropY+*objectRender.CropHeight)
+		options.Crop = &crop
+	}
+	if objectRender.Format != nil {
+		options.Format = *objectRender.Format
+	}
+	if objectRender.Quality != nil {
+		options.Quality = *objectRender.Quality
+	}
+
+	return options, nil
+}
+
+func renderCacheName(bucketId string, objectId string, sourceETag string, options *imaging.Options) string {
+	hash := sha256.Sum256([]byte(sourceETag + "\n" + options.Key()))
+
+	return storage.RenderCachePrefix(bucketId, objectId) + hex.EncodeToString(hash[:]) + "." + options.Format
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/teapartydev/storage/server/utils"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/teapartydev/storage/server/models"
 	"github.com/teapartydev/storage/server/srverr"
 	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
 	"go.uber.org/zap"
 )
@@ -22,16 +24,18 @@ type ObjectService struct {
 	queries     *database.Queries
 	transaction *database.Transaction
 	storage     *storage.Storage
+	replicas    storage.Replicas
 	job         *river.Client[pgx.Tx]
 	config      *config.Config
 	logger      *zap.Logger
 }
 
-func NewObjectService(db *pgxpool.Pool, storage *storage.Storage, job *river.Client[pgx.Tx], config *config.Config, logger *zap.Logger) *ObjectService {
+func NewObjectService(db *pgxpool.Pool, storage *storage.Storage, replicas storage.Replicas, job *river.Client[pgx.Tx], config *config.Config, logger *zap.Logger) *ObjectService {
 	return &ObjectService{
 		queries:     database.New(db),
 		transaction: database.NewTransaction(db),
 		storage:     storage,
+		replicas:    replicas,
 		job:         job,
 		config:      config,
 		logger:      logger,
@@ -71,7 +75,7 @@ func (os *ObjectService) CreatePreSignedUploadSession(ctx context.Context, preSi
 		}
 
 		preSignedObject, err = os.storage.CreatePreSignedUploadObject(ctx, &storage.PreSignedUploadObjectCreate{
-			Bucket:        bucket.Name,
+			Bucket:        bucket.StoragePrefix,
 			Name:          preSignedUploadSessionCreate.Name,
 			ExpiresIn:     preSignedUploadSessionCreate.ExpiresIn,
 			ContentType:   *preSignedUploadSessionCreate.MimeType,
@@ -99,7 +103,8 @@ func (os *ObjectService) CreatePreSignedUploadSession(ctx context.Context, preSi
 		}
 
 		_, err = os.job.InsertTx(ctx, tx, jobs.PreSignedUploadSessionCompletion{
-			ObjectId: id,
+			ObjectId:     id,
+			TraceContext: tracing.NewTraceContext(ctx),
 		}, &river.InsertOpts{
 			ScheduledAt: time.Unix(preSignedObject.ExpiresAt, 0).Add(time.Minute * 1),
 		})
@@ -148,12 +153,12 @@ func (os *ObjectService) CompletePreSignedUploadSession(ctx context.Context, buc
 		return srverr.NewServiceError(srverr.UnknownError, "failed to complete pre-signed upload session", op, reqId, err)
 	}
 
-	if object.UploadStatus == models.ObjectUploadStatusCompleted {
+	if object.UploadStatus != models.ObjectUploadStatusPending {
 		return srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload session has already been completed for object '%s'", objectId), op, reqId, nil)
 	}
 
 	objectExists, err := os.storage.CheckIfObjectExists(ctx, &storage.ObjectExistsCheck{
-		Bucket: bucket.Name,
+		Bucket: bucket.StoragePrefix,
 		Name:   object.Name,
 	})
 	if err != nil {
@@ -161,23 +166,66 @@ func (os *ObjectService) CompletePreSignedUploadSession(ctx context.Context, buc
 		return srverr.NewServiceError(srverr.UnknownError, "failed to complete pre-signed upload session", op, reqId, err)
 	}
 
-	if objectExists {
-		err = os.queries.ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
-			ID:           object.ID,
-			UploadStatus: models.ObjectUploadStatusCompleted,
-		})
-		if err != nil {
-			os.logger.Error("failed to update object upload status in database to completed", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
-			return srverr.NewServiceError(srverr.UnknownError, "failed to complete pre-signed upload session", op, reqId, err)
-		}
-	} else {
+	if !objectExists {
 		return srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("object '%s' has not yet been uploaded to storage", objectId), op, reqId, nil)
 	}
 
+	return os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		return os.completeUpload(ctx, tx, object.ID, object.MimeType, bucket, op)
+	})
+}
+
+// completeUpload marks an object whose content is in storage as uploaded and enqueues the jobs that follow, with
+// malware scanning the object is held in scanning until the scan finds it clean
+func (os *ObjectService) completeUpload(ctx context.Context, tx pgx.Tx, objectId string, mimeType string, bucket *models.Bucket, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	status, params := jobs.CompletedUpload(ctx, objectId, mimeType, bucket.Processors, bucket.Replica, os.config.MalwareScanningEnabled())
+
+	err := os.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
+		ID:           objectId,
+		UploadStatus: status,
+	})
+	if err != nil {
+		os.logger.Error("failed to update object upload status in database", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to complete object upload", op, reqId, err)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	if _, err = os.job.InsertManyTx(ctx, tx, params); err != nil {
+		os.logger.Error("failed to create upload completion jobs", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to complete object upload", op, reqId, err)
+	}
+
 	return nil
 }
 
-func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, bucketId string, objectId string, expiresIn int64) (*models.PreSignedDownloadSession, error) {
+// checkObjectServable refuses objects whose content must not be served, either because it is still being scanned
+// for malware, because the scan found it infected or because it is archived in the cold tier
+func checkObjectServable(ctx context.Context, object *database.StorageObject, op string) error {
+	switch object.UploadStatus {
+	case models.ObjectUploadStatusScanning:
+		return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' is being scanned for malware and cannot be downloaded yet", object.ID), op, utils.RequestId(ctx), nil)
+	case models.ObjectUploadStatusQuarantined:
+		return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is quarantined and cannot be downloaded", object.ID), op, utils.RequestId(ctx), nil)
+	}
+
+	switch object.Tier {
+	case models.ObjectTierCold:
+		return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' is archived and must be restored before it can be downloaded", object.ID), op, utils.RequestId(ctx), nil)
+	case models.ObjectTierRestoring:
+		return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' is being restored and cannot be downloaded yet", object.ID), op, utils.RequestId(ctx), nil)
+	default:
+		return nil
+	}
+}
+
+// CreatePreSignedDownloadSession returns a url the content of an object can be downloaded from. archived objects
+// cannot be downloaded, their restore to the hot tier is started instead when restore is set
+func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, bucketId string, objectId string, expiresIn int64, restore bool) (*models.PreSignedDownloadSession, error) {
 	const op = "ObjectService.CreatePreSignedDownloadSession"
 	reqId := utils.RequestId(ctx)
 
@@ -204,6 +252,10 @@ func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, buc
 		return nil, err
 	}
 
+	// set when the upload of a pending object is completed or the restore of an archived object is started here, the
+	// refusal is returned once that is committed
+	var notServable error
+
 	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
 		object, err := os.queries.WithTx(tx).ObjectGetById(ctx, objectId)
 		if err != nil {
@@ -216,7 +268,7 @@ func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, buc
 
 		if object.UploadStatus == models.ObjectUploadStatusPending {
 			objectExists, err := os.storage.CheckIfObjectExists(ctx, &storage.ObjectExistsCheck{
-				Bucket: bucket.Name,
+				Bucket: bucket.StoragePrefix,
 				Name:   object.Name,
 			})
 			if err != nil {
@@ -224,23 +276,37 @@ func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, buc
 				return srverr.NewServiceError(srverr.UnknownError, "failed to create pre-signed download session", op, reqId, err)
 			}
 
-			if objectExists {
-				err = os.queries.WithTx(tx).ObjectUpdateUploadStatus(ctx, &database.ObjectUpdateUploadStatusParams{
-					ID:           object.ID,
-					UploadStatus: models.ObjectUploadStatusCompleted,
-				})
-				if err != nil {
-					os.logger.Error("failed to update object upload status in database to completed", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
-					return srverr.NewServiceError(srverr.UnknownError, "failed to create pre-signed download session", op, reqId, err)
-				}
-			} else {
+			if !objectExists {
 				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' upload has not been completed", object.ID), op, reqId, nil)
 			}
+
+			if err = os.completeUpload(ctx, tx, object.ID, object.MimeType, bucket, op); err != nil {
+				return err
+			}
+
+			if os.config.MalwareScanningEnabled() {
+				object.UploadStatus = models.ObjectUploadStatusScanning
+				notServable = checkObjectServable(ctx, object, op)
+				return nil
+			}
+		}
+
+		if object.Tier == models.ObjectTierCold && restore {
+			if err = os.startTierRestore(ctx, tx, object.ID, op); err != nil {
+				return err
+			}
+			notServable = srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' is archived, a restore to the hot tier has been started", object.ID), op, reqId, nil)
+			return nil
+		}
+
+		if err = checkObjectServable(ctx, object, op); err != nil {
+			return err
 		}
 
 		preSignedObject, err := os.storage.CreatePreSignedDownloadObject(ctx, &storage.PreSignedDownloadObjectCreate{
-			Bucket: bucket.Name,
-			Name:   object.Name,
+			Bucket:      bucket.StoragePrefix,
+			Name:        object.Name,
+			ContentType: &object.MimeType,
 		})
 		if err != nil {
 			os.logger.Error("failed to create pre-signed download url", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
@@ -265,10 +331,16 @@ func (os *ObjectService) CreatePreSignedDownloadSession(ctx context.Context, buc
 		return nil, err
 	}
 
+	if notServable != nil {
+		return nil, notServable
+	}
+
 	return &preSignedDownloadObject, nil
 }
 
-func (os *ObjectService) DeleteObject(ctx context.Context, bucketId string, objectId string) error {
+// DeleteObject queues an object for deletion. governance retention of the object is bypassed when bypassGovernance is
+// set by an admin
+func (os *ObjectService) DeleteObject(ctx context.Context, bucketId string, objectId string, bypassGovernance bool) error {
 	const op = "ObjectService.DeleteObject"
 	reqId := utils.RequestId(ctx)
 
@@ -299,8 +371,18 @@ func (os *ObjectService) DeleteObject(ctx context.Context, bucketId string, obje
 			return srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for object '%s'. delete operation can only be performed on objects that have been uploaded", object.ID), op, reqId, nil)
 		}
 
+		if err = checkObjectDeletable(ctx, os.queries.WithTx(tx), object.ID, os.logger, op); err != nil {
+			return err
+		}
+
+		if err = checkObjectUnlocked(ctx, os.queries.WithTx(tx), object, bypassGovernance, os.logger, op); err != nil {
+			return err
+		}
+
 		_, err = os.job.InsertTx(ctx, tx, jobs.ObjectDeletion{
-			ObjectId: object.ID,
+			ObjectId:         object.ID,
+			BypassGovernance: governanceBypass(ctx, bypassGovernance),
+			TraceContext:     tracing.NewTraceContext(ctx),
 		}, nil)
 		if err != nil {
 			os.logger.Error("failed create object deletion job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
@@ -342,21 +424,82 @@ func (os *ObjectService) GetObject(ctx context.Context, bucketId string, objectI
 		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
 	}
 
-	return &models.Object{
-		Id:             object.ID,
-		BucketId:       object.BucketID,
-		Name:           object.Name,
-		MimeType:       object.MimeType,
-		Size:           object.Size,
-		Metadata:       bytesToMetadata(object.Metadata),
-		UploadStatus:   object.UploadStatus,
-		LastAccessedAt: object.LastAccessedAt,
-		CreatedAt:      object.CreatedAt,
-		UpdatedAt:      object.UpdatedAt,
-	}, nil
+	return toObjectModel(object), nil
+}
+
+// UpdateObject changes the mime type and metadata of an object, as long as it did not change since the version the
+// update is based on
+func (os *ObjectService) UpdateObject(ctx context.Context, objectUpdate *models.ObjectUpdate) (*models.Object, error) {
+	const op = "ObjectService.UpdateObject"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectUpdate.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	objectUpdate.PreSave()
+
+	bucket, err := os.getBucketById(ctx, objectUpdate.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := os.queries.ObjectGetByBucketIdAndId(ctx, &database.ObjectGetByBucketIdAndIdParams{
+		BucketID: bucket.Id,
+		ID:       objectUpdate.Id,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", objectUpdate.Id), op, reqId, err)
+		}
+		os.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to update object", op, reqId, err)
+	}
+
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for object '%s'. only uploaded objects can be updated", object.ID), op, reqId, nil)
+	}
+
+	if object.Version != *objectUpdate.Version {
+		return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' was modified since version %d, its version is now %d", object.ID, *objectUpdate.Version, object.Version), op, reqId, nil)
+	}
+
+	params := &database.ObjectUpdateMetadataParams{
+		ID:            object.ID,
+		Version:       object.Version,
+		MergeMetadata: objectUpdate.MetadataMode == models.ObjectMetadataModeMerge,
+	}
+
+	if objectUpdate.MimeType != nil {
+		params.MimeType, err = determineMimeType(bucket, &models.PreSignedUploadSessionCreate{
+			Name:     object.Name,
+			MimeType: objectUpdate.MimeType,
+		})
+		if err != nil {
+			return nil, srverr.NewServiceError(srverr.BadRequestError, err.Error(), op, reqId, err)
+		}
+	}
+
+	if objectUpdate.Metadata != nil {
+		params.Metadata = metadataToBytes(objectUpdate.Metadata)
+	}
+
+	updated, err := os.queries.ObjectUpdateMetadata(ctx, params)
+	if err != nil {
+		os.logger.Error("failed to update object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to update object", op, reqId, err)
+	}
+
+	// the object changed between reading it and the update
+	if updated == 0 {
+		return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' was modified since version %d", object.ID, *objectUpdate.Version), op, reqId, nil)
+	}
+
+	return os.GetObject(ctx, bucket.Id, object.ID)
 }
 
-func (os *ObjectService) SearchObjects(ctx context.Context, bucketId string, objectPath string, limit int32, offset int32) ([]*models.Object, error) {
+// SearchObjects finds the objects of a bucket whose name contains objectPath and that carry every tag of tags
+func (os *ObjectService) SearchObjects(ctx context.Context, bucketId string, objectPath string, tags map[string]string, limit int32, offset int32) ([]*models.Object, error) {
 	const op = "ObjectService.SearchObjects"
 	reqId := utils.RequestId(ctx)
 
@@ -380,9 +523,13 @@ func (os *ObjectService) SearchObjects(ctx context.Context, bucketId string, obj
 		limit = 100
 	}
 
+	tagKeys, tagValues := tagsToArrays(tags)
+
 	objects, err := os.queries.ObjectSearchByBucketIdAndObjectPath(ctx, &database.ObjectSearchByBucketIdAndObjectPathParams{
 		BucketID:   bucketId,
 		ObjectPath: objectPath,
+		TagKeys:    tagKeys,
+		TagValues:  tagValues,
 		Limit:      limit,
 		Offset:     offset,
 	})
@@ -398,23 +545,156 @@ func (os *ObjectService) SearchObjects(ctx context.Context, bucketId string, obj
 
 	for _, object := range objects {
 		result = append(result, &models.Object{
-			Id:             object.ID,
-			Version:        object.Version,
-			BucketId:       object.BucketID,
-			Name:           object.Name,
-			MimeType:       object.MimeType,
-			Size:           object.Size,
-			Metadata:       bytesToMetadata(object.Metadata),
-			UploadStatus:   object.UploadStatus,
-			LastAccessedAt: object.LastAccessedAt,
-			CreatedAt:      object.CreatedAt,
-			UpdatedAt:      object.UpdatedAt,
+			Id:                object.ID,
+			Version:           object.Version,
+			BucketId:          object.BucketID,
+			Name:              object.Name,
+			MimeType:          object.MimeType,
+			Size:              object.Size,
+			Metadata:          bytesToMetadata(object.Metadata),
+			UploadStatus:      object.UploadStatus,
+			LegalHold:         object.LegalHold,
+			RetentionMode:     object.RetentionMode,
+			RetainUntil:       object.RetainUntil,
+			ReplicationStatus: object.ReplicationStatus,
+			ReplicatedAt:      object.ReplicatedAt,
+			Tier:              object.Tier,
+			LastAccessedAt:    object.LastAccessedAt,
+			CreatedAt:         object.CreatedAt,
+			UpdatedAt:         object.UpdatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// ListObjects lists the objects of a bucket that carry every tag of tags ordered by name, no tags lists every object
+func (os *ObjectService) ListObjects(ctx context.Context, bucketId string, tags map[string]string, limit int32, offset int32) ([]*models.Object, error) {
+	const op = "ObjectService.ListObjects"
+	reqId := utils.RequestId(ctx)
+
+	if limit < 0 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "limit cannot be less than 0", op, reqId, nil)
+	}
+
+	if offset < 0 {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "offset cannot be less than 0", op, reqId, nil)
+	}
+
+	if limit == 0 {
+		limit = 100
+	}
+
+	_, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	tagKeys, tagValues := tagsToArrays(tags)
+
+	objects, err := os.queries.ObjectListByBucketIdPaged(ctx, &database.ObjectListByBucketIdPagedParams{
+		BucketID:  bucketId,
+		TagKeys:   tagKeys,
+		TagValues: tagValues,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		os.logger.Error("failed to list objects", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to list objects", op, reqId, err)
+	}
+	if len(objects) == 0 {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("no objects found for bucket '%s'", bucketId), op, reqId, nil)
+	}
+
+	var result []*models.Object
+
+	for _, object := range objects {
+		result = append(result, &models.Object{
+			Id:                object.ID,
+			Version:           object.Version,
+			BucketId:          object.BucketID,
+			Name:              object.Name,
+			MimeType:          object.MimeType,
+			Size:              object.Size,
+			Metadata:          bytesToMetadata(object.Metadata),
+			UploadStatus:      object.UploadStatus,
+			LegalHold:         object.LegalHold,
+			RetentionMode:     object.RetentionMode,
+			RetainUntil:       object.RetainUntil,
+			ReplicationStatus: object.ReplicationStatus,
+			ReplicatedAt:      object.ReplicatedAt,
+			Tier:              object.Tier,
+			LastAccessedAt:    object.LastAccessedAt,
+			CreatedAt:         object.CreatedAt,
+			UpdatedAt:         object.UpdatedAt,
 		})
 	}
 
 	return result, nil
 }
 
+// SearchObjectsByMetadata finds the objects of a bucket whose metadata contains a document and matches every
+// condition, ordered by name
+func (os *ObjectService) SearchObjectsByMetadata(ctx context.Context, objectMetadataSearch *models.ObjectMetadataSearch) ([]*models.Object, error) {
+	const op = "ObjectService.SearchObjectsByMetadata"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectMetadataSearch.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	_, err := os.getBucketById(ctx, objectMetadataSearch.BucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &database.ObjectSearchByMetadataParams{
+		BucketID: objectMetadataSearch.BucketId,
+		Limit:    objectMetadataSearch.Limit,
+		Offset:   objectMetadataSearch.Offset,
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	if objectMetadataSearch.Contains != nil {
+		params.Contains = metadataToBytes(objectMetadataSearch.Contains)
+	}
+
+	params.TagKeys, params.TagValues = tagsToArrays(objectMetadataSearch.Tags)
+
+	for _, condition := range objectMetadataSearch.Conditions {
+		value, err := json.Marshal(condition.Value)
+		if err != nil {
+			return nil, srverr.NewServiceError(srverr.InvalidInputError, fmt.Sprintf("value of metadata condition on key '%s' is not valid json", condition.Key), op, reqId, err)
+		}
+
+		params.Conditions = append(params.Conditions, database.ObjectMetadataCondition{
+			Path:     condition.Path(),
+			Operator: condition.Operator,
+			Value:    value,
+		})
+	}
+
+	objects, err := os.queries.ObjectSearchByMetadata(ctx, params)
+	if err != nil {
+		os.logger.Error("failed to search objects by metadata", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to search objects", op, reqId, err)
+	}
+	if len(objects) == 0 {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("no objects found for bucket '%s' matching the metadata search", objectMetadataSearch.BucketId), op, reqId, nil)
+	}
+
+	result := make([]*models.Object, 0, len(objects))
+	for _, object := range objects {
+		result = append(result, toObjectModel(object))
+	}
+
+	return result, nil
+}
+
 func (os *ObjectService) getBucketById(ctx context.Context, bucketId string, op string) (*models.Bucket, error) {
 	reqId := utils.RequestId(ctx)
 
@@ -443,13 +723,21 @@ func (os *ObjectService) getBucketById(ctx context.Context, bucketId string, op
 		Id:                   bucket.ID,
 		Version:              bucket.Version,
 		Name:                 bucket.Name,
+		StoragePrefix:        bucket.StoragePrefix,
 		AllowedMimeTypes:     bucket.AllowedMimeTypes,
 		MaxAllowedObjectSize: bucket.MaxAllowedObjectSize,
 		Public:               bucket.Public,
+		Processors:           bucket.Processors,
+		Replica:              bucket.Replica,
+		ArchiveAfterDays:     bucket.ArchiveAfterDays,
+		DefaultRetentionMode: bucket.DefaultRetentionMode,
+		DefaultRetentionDays: bucket.DefaultRetentionDays,
 		Disabled:             bucket.Disabled,
 		Locked:               bucket.Locked,
 		LockReason:           bucket.LockReason,
 		LockedAt:             bucket.LockedAt,
+		LockJobId:            bucket.LockJobID,
+		LockHeartbeatAt:      bucket.LockHeartbeatAt,
 		CreatedAt:            bucket.CreatedAt,
 		UpdatedAt:            bucket.UpdatedAt,
 	}, nil
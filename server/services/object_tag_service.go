@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// GetObjectTags returns the tags of an object, an object without tags has an empty set of them
+func (os *ObjectService) GetObjectTags(ctx context.Context, bucketId string, objectId string) (*models.ObjectTags, error) {
+	const op = "ObjectService.GetObjectTags"
+	reqId := utils.RequestId(ctx)
+
+	object, err := os.getTaggableObject(ctx, bucketId, objectId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := os.queries.ObjectTagListByObjectId(ctx, object.ID)
+	if err != nil {
+		os.logger.Error("failed to list object tags", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object tags", op, reqId, err)
+	}
+
+	return toObjectTagsModel(object.ID, tags), nil
+}
+
+// PutObjectTags replaces every tag of an object
+func (os *ObjectService) PutObjectTags(ctx context.Context, objectTagsPut *models.ObjectTagsPut) (*models.ObjectTags, error) {
+	const op = "ObjectService.PutObjectTags"
+	reqId := utils.RequestId(ctx)
+
+	if err := objectTagsPut.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	object, err := os.getTaggableObject(ctx, objectTagsPut.BucketId, objectTagsPut.ObjectId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		return os.replaceObjectTags(ctx, tx, object.ID, objectTagsPut.Tags, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.GetObjectTags(ctx, objectTagsPut.BucketId, object.ID)
+}
+
+// DeleteObjectTags removes every tag of an object
+func (os *ObjectService) DeleteObjectTags(ctx context.Context, bucketId string, objectId string) error {
+	const op = "ObjectService.DeleteObjectTags"
+	reqId := utils.RequestId(ctx)
+
+	object, err := os.getTaggableObject(ctx, bucketId, objectId, op)
+	if err != nil {
+		return err
+	}
+
+	if err = os.queries.ObjectTagDeleteByObjectId(ctx, object.ID); err != nil {
+		os.logger.Error("failed to delete object tags", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to delete object tags", op, reqId, err)
+	}
+
+	return nil
+}
+
+func (os *ObjectService) replaceObjectTags(ctx context.Context, tx pgx.Tx, objectId string, tags map[string]string, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	err := os.queries.WithTx(tx).ObjectTagDeleteByObjectId(ctx, objectId)
+	if err != nil {
+		os.logger.Error("failed to delete object tags", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to save object tags", op, reqId, err)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys, values := tagsToArrays(tags)
+
+	err = os.queries.WithTx(tx).ObjectTagCreateMany(ctx, &database.ObjectTagCreateManyParams{
+		ObjectID: objectId,
+		Keys:     keys,
+		Values:   values,
+	})
+	if err != nil {
+		os.logger.Error("failed to create object tags", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to save object tags", op, reqId, err)
+	}
+
+	return nil
+}
+
+func (os *ObjectService) getTaggableObject(ctx context.Context, bucketId string, objectId string, op string) (*database.StorageObject, error) {
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(objectId) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "object_id cannot be empty. object_id is required", op, reqId, nil)
+	}
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := os.queries.ObjectGetByBucketIdAndId(ctx, &database.ObjectGetByBucketIdAndIdParams{
+		BucketID: bucket.Id,
+		ID:       objectId,
+	})
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", objectId), op, reqId, err)
+		}
+		os.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get object", op, reqId, err)
+	}
+
+	if object.UploadStatus == models.ObjectUploadStatusPending {
+		return nil, srverr.NewServiceError(srverr.BadRequestError, fmt.Sprintf("upload has not yet been completed for object '%s'. only uploaded objects can be tagged", object.ID), op, reqId, nil)
+	}
+
+	return object, nil
+}
+
+// checkObjectDeletable refuses to delete or overwrite an object a deny_delete tag rule of its bucket protects
+func checkObjectDeletable(ctx context.Context, queries *database.Queries, objectId string, logger *zap.Logger, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	rule, err := queries.TagRuleGetDenyDeleteByObjectId(ctx, objectId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		logger.Error("failed to get deny delete tag rule of object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to check the tag rules of the object", op, reqId, err)
+	}
+
+	return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("object '%s' is tagged '%s=%s' and tag rule '%s' denies deleting or overwriting it", objectId, rule.TagKey, rule.TagValue, rule.ID), op, reqId, nil)
+}
+
+// tagsToArrays splits tags into the parallel key and value arrays the tag queries take
+func tagsToArrays(tags map[string]string) ([]string, []string) {
+	keys := make([]string, 0, len(tags))
+	values := make([]string, 0, len(tags))
+	for key, value := range tags {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values
+}
+
+func toObjectTagsModel(objectId string, tags []*database.StorageObjectTag) *models.ObjectTags {
+	result := &models.ObjectTags{
+		ObjectId: objectId,
+		Tags:     make(map[string]string, len(tags)),
+	}
+	for _, tag := range tags {
+		result.Tags[tag.Key] = tag.Value
+	}
+	return result
+}
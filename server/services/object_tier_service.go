@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/jobs"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/tracing"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// RestoreObject brings an archived object back to the hot tier. the restore runs in the background, the object stays
+// restoring and cannot be downloaded until it is done. an object already restoring is returned as is
+func (os *ObjectService) RestoreObject(ctx context.Context, bucketId string, objectId string) (*models.Object, error) {
+	const op = "ObjectService.RestoreObject"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(objectId) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "object_id cannot be empty. object_id is required to restore object", op, reqId, nil)
+	}
+
+	bucket, err := os.getBucketById(ctx, bucketId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+		object, err := os.queries.WithTx(tx).ObjectGetByBucketIdAndId(ctx, &database.ObjectGetByBucketIdAndIdParams{
+			BucketID: bucket.Id,
+			ID:       objectId,
+		})
+		if err != nil {
+			if database.IsNotFoundError(err) {
+				return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("object '%s' not found", objectId), op, reqId, err)
+			}
+			os.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return srverr.NewServiceError(srverr.UnknownError, "failed to restore object", op, reqId, err)
+		}
+
+		switch object.Tier {
+		case models.ObjectTierHot:
+			return srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("object '%s' is not archived", object.ID), op, reqId, nil)
+		case models.ObjectTierRestoring:
+			return nil
+		default:
+			return os.startTierRestore(ctx, tx, object.ID, op)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.GetObject(ctx, bucket.Id, objectId)
+}
+
+// startTierRestore moves a cold object to the restoring tier and queues the job bringing its content back. nothing
+// is queued when the object left the cold tier since it was read
+func (os *ObjectService) startTierRestore(ctx context.Context, tx pgx.Tx, objectId string, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	rows, err := os.queries.WithTx(tx).ObjectUpdateTier(ctx, &database.ObjectUpdateTierParams{
+		ID:       objectId,
+		FromTier: models.ObjectTierCold,
+		Tier:     models.ObjectTierRestoring,
+	})
+	if err != nil {
+		os.logger.Error("failed to update object tier", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to restore object", op, reqId, err)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	_, err = os.job.InsertTx(ctx, tx, jobs.ObjectTierRestore{
+		ObjectId:     objectId,
+		TraceContext: tracing.NewTraceContext(ctx),
+	}, nil)
+	if err != nil {
+		os.logger.Error("failed to create object tier restore job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to restore object", op, reqId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type OperationService struct {
+	query       *database.Queries
+	transaction *database.Transaction
+	job         *river.Client[pgx.Tx]
+	logger      *zap.Logger
+}
+
+func NewOperationService(db *pgxpool.Pool, job *river.Client[pgx.Tx], logger *zap.Logger) *OperationService {
+	return &OperationService{
+		query:       database.New(db),
+		transaction: database.NewTransaction(db),
+		job:         job,
+		logger:      logger,
+	}
+}
+
+func (ops *OperationService) GetOperation(ctx context.Context, id string) (*models.Operation, error) {
+	const op = "OperationService.GetOperation"
+
+	return getOperation(ctx, ops.query, id, ops.logger, op)
+}
+
+// CancelOperation stops an operation that has not finished. an operation whose job has not started yet is cancelled
+// and its bucket unlocked right away, a running one is asked to stop and its job unlocks the bucket once it stopped
+// deleting so the bucket is never unlocked while objects are still being removed from it
+func (ops *OperationService) CancelOperation(ctx context.Context, id string) (*models.Operation, error) {
+	const op = "OperationService.CancelOperation"
+	reqId := utils.RequestId(ctx)
+
+	operation, err := getOperation(ctx, ops.query, id, ops.logger, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if models.IsFinishedOperationState(operation.State) {
+		return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("operation '%s' already %s and cannot be cancelled", operation.Id, operation.State), op, reqId, nil)
+	}
+
+	if err = ops.query.OperationRequestCancel(ctx, operation.Id); err != nil {
+		ops.logger.Error("failed to request cancellation of operation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to cancel operation", op, reqId, err)
+	}
+
+	jobRow, err := ops.job.JobCancel(ctx, operation.JobId)
+	if err != nil {
+		if errors.Is(err, river.ErrNotFound) {
+			return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("job %d of operation '%s' is gone and cannot be cancelled", operation.JobId, operation.Id), op, reqId, err)
+		}
+		ops.logger.Error("failed to cancel operation job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to cancel operation", op, reqId, err)
+	}
+
+	// a job that was not running is finalized by the cancel, nothing else is going to unlock its bucket
+	if jobRow.State == rivertype.JobStateCancelled {
+		err = ops.transaction.WithTransaction(ctx, func(tx pgx.Tx) error {
+			if models.OperationLocksBucket(operation.Type) {
+				_, err := ops.query.WithTx(tx).BucketUnlockByLockJobId(ctx, &database.BucketUnlockByLockJobIdParams{
+					ID:        operation.BucketId,
+					LockJobID: operation.JobId,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			state := models.OperationStateCancelled
+
+			return ops.query.WithTx(tx).OperationFinishByJobId(ctx, &database.OperationFinishByJobIdParams{
+				JobID: operation.JobId,
+				State: &state,
+			})
+		})
+		if err != nil {
+			ops.logger.Error("failed to unlock bucket of cancelled operation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+			return nil, srverr.NewServiceError(srverr.UnknownError, "failed to cancel operation", op, reqId, err)
+		}
+	}
+
+	ops.logger.Info("operation cancelled", zap.String("operation_id", operation.Id), zap.Int64("job_id", operation.JobId), zapfield.Operation(op), zapfield.RequestId(reqId))
+
+	return getOperation(ctx, ops.query, id, ops.logger, op)
+}
+
+// createOperation records the operation a bucket job just queued in tx works
+func createOperation(ctx context.Context, queries *database.Queries, operationType string, bucketId string, jobRow *rivertype.JobRow, logger *zap.Logger, op string) (string, error) {
+	reqId := utils.RequestId(ctx)
+
+	id, err := queries.OperationCreate(ctx, &database.OperationCreateParams{
+		Type:     operationType,
+		BucketID: bucketId,
+		JobID:    jobRow.ID,
+	})
+	if err != nil {
+		logger.Error("failed to create operation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return "", srverr.NewServiceError(srverr.UnknownError, "failed to create operation", op, reqId, err)
+	}
+
+	return id, nil
+}
+
+func getOperation(ctx context.Context, queries *database.Queries, id string, logger *zap.Logger, op string) (*models.Operation, error) {
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "operation id cannot be empty. operation id is required", op, reqId, nil)
+	}
+
+	operation, err := queries.OperationGetById(ctx, id)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("operation '%s' not found", id), op, reqId, err)
+		}
+		logger.Error("failed to get operation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get operation", op, reqId, err)
+	}
+
+	// river prunes finalized jobs after a while, the operation then only has what it recorded itself
+	jobRow, err := queries.JobGetById(ctx, operation.JobID)
+	if err != nil && !database.IsNotFoundError(err) {
+		logger.Error("failed to get operation job", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get operation", op, reqId, err)
+	}
+
+	return toOperationModel(operation, jobRow), nil
+}
+
+func toOperationModel(operation *database.StorageOperation, jobRow *rivertype.JobRow) *models.Operation {
+	result := &models.Operation{
+		Id:                operation.ID,
+		Type:              operation.Type,
+		BucketId:          operation.BucketID,
+		JobId:             operation.JobID,
+		State:             operationState(operation, jobRow),
+		Errors:            make([]*models.JobError, 0),
+		CreatedAt:         operation.CreatedAt,
+		CancelRequestedAt: operation.CancelRequestedAt,
+		FinishedAt:        operation.FinishedAt,
+	}
+
+	if jobRow != nil {
+		job := toJobModel(jobRow)
+		result.Progress = job.Progress
+		result.Errors = job.Errors
+		result.StartedAt = job.AttemptedAt
+	}
+
+	if len(result.Errors) == 0 && operation.Error != nil && operation.FinishedAt != nil {
+		result.Errors = append(result.Errors, &models.JobError{
+			At:    *operation.FinishedAt,
+			Error: *operation.Error,
+		})
+	}
+
+	return result
+}
+
+// operationState prefers the outcome the operation recorded and otherwise follows its job
+func operationState(operation *database.StorageOperation, jobRow *rivertype.JobRow) string {
+	if operation.State != nil {
+		return *operation.State
+	}
+
+	// river only prunes finalized jobs, one that is gone finished without the operation recording how
+	if jobRow == nil {
+		return models.OperationStateFailed
+	}
+
+	switch jobRow.State {
+	case rivertype.JobStateCompleted:
+		return models.OperationStateSucceeded
+	case rivertype.JobStateDiscarded:
+		return models.OperationStateFailed
+	case rivertype.JobStateCancelled:
+		return models.OperationStateCancelled
+	}
+
+	if operation.CancelRequestedAt != nil {
+		return models.OperationStateCancelling
+	}
+
+	if jobRow.State == rivertype.JobStateAvailable || jobRow.State == rivertype.JobStateScheduled {
+		return models.OperationStateQueued
+	}
+
+	return models.OperationStateRunning
+}
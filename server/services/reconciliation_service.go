@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/reconciliation"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/storage"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+type ReconciliationService struct {
+	query      *database.Queries
+	reconciler *reconciliation.Reconciler
+	config     *config.Config
+	logger     *zap.Logger
+}
+
+func NewReconciliationService(db *pgxpool.Pool, storage *storage.Storage, config *config.Config, logger *zap.Logger) *ReconciliationService {
+	return &ReconciliationService{
+		query:      database.New(db),
+		reconciler: reconciliation.NewReconciler(db, storage, logger),
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// ReconcileBucket compares a bucket in storage with its catalog without fixing anything. the report carries the
+// configured policies so it shows what the scheduled reconciliation is going to fix
+func (rs *ReconciliationService) ReconcileBucket(ctx context.Context, bucketId string) (*models.ReconciliationReport, error) {
+	const op = "ReconciliationService.ReconcileBucket"
+	reqId := utils.RequestId(ctx)
+
+	if !utils.PrincipalFromContext(ctx).Admin {
+		return nil, srverr.NewServiceError(srverr.ForbiddenError, "only admin keys can reconcile a bucket", op, reqId, nil)
+	}
+
+	if !models.IsNotEmptyTrimmedString(bucketId) {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, "bucket id cannot be empty. bucket id is required to reconcile bucket", op, reqId, nil)
+	}
+
+	bucket, err := rs.query.BucketGetById(ctx, bucketId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("bucket '%s' not found", bucketId), op, reqId, err)
+		}
+		rs.logger.Error("failed to get bucket for reconciliation", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to reconcile bucket", op, reqId, err)
+	}
+
+	report, err := rs.reconciler.ReconcileBucket(ctx, bucket, &reconciliation.Options{
+		DryRun:        true,
+		OrphanPolicy:  rs.config.StorageReconciliationOrphans,
+		MissingPolicy: rs.config.StorageReconciliationMissing,
+		GracePeriod:   time.Duration(rs.config.StorageReconciliationGracePeriod) * time.Second,
+	})
+	if err != nil {
+		rs.logger.Error("failed to reconcile bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to reconcile bucket", op, reqId, err)
+	}
+
+	return report, nil
+}
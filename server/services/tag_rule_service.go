@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/teapartydev/storage/server/database"
+	"github.com/teapartydev/storage/server/models"
+	"github.com/teapartydev/storage/server/srverr"
+	"github.com/teapartydev/storage/server/utils"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.uber.org/zap"
+)
+
+// CreateTagRule adds a rule applying an action to the objects of a bucket that carry a tag
+func (bs *BucketService) CreateTagRule(ctx context.Context, tagRuleCreate *models.TagRuleCreate) (*models.TagRule, error) {
+	const op = "BucketService.CreateTagRule"
+	reqId := utils.RequestId(ctx)
+
+	if err := tagRuleCreate.IsValid(); err != nil {
+		return nil, srverr.NewServiceError(srverr.InvalidInputError, err.Error(), op, reqId, err)
+	}
+
+	if _, err := bs.GetBucket(ctx, tagRuleCreate.BucketId); err != nil {
+		return nil, err
+	}
+
+	id, err := bs.query.TagRuleCreate(ctx, &database.TagRuleCreateParams{
+		BucketID:        tagRuleCreate.BucketId,
+		TagKey:          tagRuleCreate.TagKey,
+		TagValue:        tagRuleCreate.TagValue,
+		Action:          tagRuleCreate.Action,
+		ExpireAfterDays: tagRuleCreate.ExpireAfterDays,
+	})
+	if err != nil {
+		if database.IsConflictError(err) {
+			return nil, srverr.NewServiceError(srverr.ConflictError, fmt.Sprintf("a '%s' rule for tag '%s=%s' already exists", tagRuleCreate.Action, tagRuleCreate.TagKey, tagRuleCreate.TagValue), op, reqId, err)
+		}
+		bs.logger.Error("failed to create tag rule", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to create tag rule", op, reqId, err)
+	}
+
+	tagRule, err := bs.query.TagRuleGetByBucketIdAndId(ctx, &database.TagRuleGetByBucketIdAndIdParams{
+		BucketID: tagRuleCreate.BucketId,
+		ID:       id,
+	})
+	if err != nil {
+		bs.logger.Error("failed to get tag rule", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to get tag rule", op, reqId, err)
+	}
+
+	return toTagRuleModel(tagRule), nil
+}
+
+func (bs *BucketService) ListTagRules(ctx context.Context, bucketId string) ([]*models.TagRule, error) {
+	const op = "BucketService.ListTagRules"
+	reqId := utils.RequestId(ctx)
+
+	if _, err := bs.GetBucket(ctx, bucketId); err != nil {
+		return nil, err
+	}
+
+	tagRules, err := bs.query.TagRuleListByBucketId(ctx, bucketId)
+	if err != nil {
+		bs.logger.Error("failed to list tag rules", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return nil, srverr.NewServiceError(srverr.UnknownError, "failed to list tag rules", op, reqId, err)
+	}
+	if len(tagRules) == 0 {
+		return nil, srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("no tag rules found for bucket '%s'", bucketId), op, reqId, nil)
+	}
+
+	result := make([]*models.TagRule, 0, len(tagRules))
+	for _, tagRule := range tagRules {
+		result = append(result, toTagRuleModel(tagRule))
+	}
+
+	return result, nil
+}
+
+func (bs *BucketService) DeleteTagRule(ctx context.Context, bucketId string, id string) error {
+	const op = "BucketService.DeleteTagRule"
+	reqId := utils.RequestId(ctx)
+
+	if !models.IsNotEmptyTrimmedString(id) {
+		return srverr.NewServiceError(srverr.InvalidInputError, "tag rule id cannot be empty. tag rule id is required to delete a tag rule", op, reqId, nil)
+	}
+
+	if _, err := bs.GetBucket(ctx, bucketId); err != nil {
+		return err
+	}
+
+	deleted, err := bs.query.TagRuleDelete(ctx, &database.TagRuleDeleteParams{
+		BucketID: bucketId,
+		ID:       id,
+	})
+	if err != nil {
+		bs.logger.Error("failed to delete tag rule", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to delete tag rule", op, reqId, err)
+	}
+	if deleted == 0 {
+		return srverr.NewServiceError(srverr.NotFoundError, fmt.Sprintf("tag rule '%s' not found", id), op, reqId, nil)
+	}
+
+	return nil
+}
+
+// checkBucketEmptiable refuses to empty or delete a bucket holding objects a deny_delete tag rule protects
+func checkBucketEmptiable(ctx context.Context, queries *database.Queries, bucketId string, logger *zap.Logger, op string) error {
+	reqId := utils.RequestId(ctx)
+
+	rule, err := queries.TagRuleGetDenyDeleteByBucketId(ctx, bucketId)
+	if err != nil {
+		if database.IsNotFoundError(err) {
+			return nil
+		}
+		logger.Error("failed to get deny delete tag rule of bucket", zap.Error(err), zapfield.Operation(op), zapfield.RequestId(reqId))
+		return srverr.NewServiceError(srverr.UnknownError, "failed to check the tag rules of the bucket", op, reqId, err)
+	}
+
+	return srverr.NewServiceError(srverr.ForbiddenError, fmt.Sprintf("bucket '%s' holds objects tagged '%s=%s' and tag rule '%s' denies deleting them", bucketId, rule.TagKey, rule.TagValue, rule.ID), op, reqId, nil)
+}
+
+func toTagRuleModel(tagRule *database.StorageTagRule) *models.TagRule {
+	return &models.TagRule{
+		Id:              tagRule.ID,
+		BucketId:        tagRule.BucketID,
+		TagKey:          tagRule.TagKey,
+		TagValue:        tagRule.TagValue,
+		Action:          tagRule.Action,
+		ExpireAfterDays: tagRule.ExpireAfterDays,
+		CreatedAt:       tagRule.CreatedAt,
+	}
+}
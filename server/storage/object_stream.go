@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// objectWriterPartSize is the size of the parts an ObjectWriter uploads, s3 takes at most 10000 parts so objects
+	// written through it stay below 640 GiB
+	objectWriterPartSize = 64 << 20
+
+	// objectReaderChunkSize is how much an ObjectReaderAt reads ahead with every request
+	objectReaderChunkSize = 8 << 20
+)
+
+// ObjectWriter streams content of unknown length into storage as a multipart upload. parts are buffered in memory,
+// the object only appears once Close completed the upload and Abort discards what was uploaded so far
+type ObjectWriter struct {
+	ctx      context.Context
+	storage  *Storage
+	bucket   string
+	name     string
+	uploadId string
+	buffer   []byte
+	parts    []CompletedPart
+	err      error
+}
+
+func (s *Storage) NewObjectWriter(ctx context.Context, objectWrite *ObjectWrite) (*ObjectWriter, error) {
+	uploadId, err := s.CreateMultipartUpload(ctx, &MultipartUploadCreate{
+		Bucket:      objectWrite.Bucket,
+		Name:        objectWrite.Name,
+		ContentType: objectWrite.ContentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectWriter{
+		ctx:      ctx,
+		storage:  s,
+		bucket:   objectWrite.Bucket,
+		name:     objectWrite.Name,
+		uploadId: uploadId,
+		buffer:   make([]byte, 0, objectWriterPartSize),
+	}, nil
+}
+
+func (w *ObjectWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buffer[len(w.buffer):cap(w.buffer)], p)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		p = p[n:]
+		written += n
+
+		if len(w.buffer) == cap(w.buffer) {
+			if w.err = w.uploadPart(); w.err != nil {
+				return written, w.err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close uploads what is left as the last part and completes the upload
+func (w *ObjectWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if len(w.buffer) > 0 || len(w.parts) == 0 {
+		if w.err = w.uploadPart(); w.err != nil {
+			return w.err
+		}
+	}
+
+	_, w.err = w.storage.CompleteMultipartUpload(w.ctx, &MultipartUploadComplete{
+		Bucket:   w.bucket,
+		Name:     w.name,
+		UploadId: w.uploadId,
+		Parts:    w.parts,
+	})
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = io.ErrClosedPipe
+	return nil
+}
+
+// Abort discards the parts uploaded so far, the context of the writer may be done by then so it takes its own
+func (w *ObjectWriter) Abort(ctx context.Context) error {
+	w.err = io.ErrClosedPipe
+
+	return w.storage.AbortMultipartUpload(ctx, &MultipartUpload{
+		Bucket:   w.bucket,
+		Name:     w.name,
+		UploadId: w.uploadId,
+	})
+}
+
+func (w *ObjectWriter) uploadPart() error {
+	partNumber := int32(len(w.parts) + 1)
+
+	etag, err := w.storage.UploadPart(w.ctx, &MultipartUploadPart{
+		Bucket:        w.bucket,
+		Name:          w.name,
+		UploadId:      w.uploadId,
+		PartNumber:    partNumber,
+		ContentLength: int64(len(w.buffer)),
+		Content:       bytes.NewReader(w.buffer),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.parts = append(w.parts, CompletedPart{
+		PartNumber: partNumber,
+		ETag:       etag,
+		Size:       int64(len(w.buffer)),
+	})
+	w.buffer = w.buffer[:0]
+
+	return nil
+}
+
+// ObjectReaderAt reads an object at any offset through range requests. it reads ahead in chunks so reading through
+// the object in order takes a request per chunk. it is not safe for concurrent use
+type ObjectReaderAt struct {
+	ctx         context.Context
+	storage     *Storage
+	bucket      string
+	name        string
+	size        int64
+	chunk       []byte
+	chunkOffset int64
+}
+
+func (s *Storage) NewObjectReaderAt(ctx context.Context, objectHead *ObjectHead) (*ObjectReaderAt, error) {
+	info, err := s.HeadObject(ctx, objectHead)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectReaderAt{
+		ctx:     ctx,
+		storage: s,
+		bucket:  objectHead.Bucket,
+		name:    objectHead.Name,
+		size:    info.ContentLength,
+	}, nil
+}
+
+// Size is the size of the object when the reader was created
+func (r *ObjectReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *ObjectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off < r.size {
+		if off < r.chunkOffset || off >= r.chunkOffset+int64(len(r.chunk)) {
+			if err := r.readChunk(off); err != nil {
+				return n, err
+			}
+		}
+
+		copied := copy(p[n:], r.chunk[off-r.chunkOffset:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *ObjectReaderAt) readChunk(off int64) error {
+	end := min(off+objectReaderChunkSize, r.size)
+
+	content, err := r.storage.GetObject(r.ctx, &ObjectGet{
+		Bucket: r.bucket,
+		Name:   r.name,
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, end-1)),
+	})
+	if err != nil {
+		return err
+	}
+	defer content.Body.Close()
+
+	if r.chunk == nil {
+		r.chunk = make([]byte, objectReaderChunkSize)
+	}
+	r.chunk = r.chunk[:end-off]
+	r.chunkOffset = off
+
+	if _, err = io.ReadFull(content.Body, r.chunk); err != nil {
+		r.chunk = r.chunk[:0]
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/metrics"
+	"go.uber.org/zap"
+)
+
+// Replicas holds the storage of every replica in the config by name. keys are the same on a replica as on the primary
+// storage, so the content of an object is found under its bucket and name on either
+type Replicas map[string]*Storage
+
+func NewReplicaStorage(s3Client *s3.Client, name string, replica config.ReplicaConfig, config *config.Config, metrics *metrics.Metrics, logger *zap.Logger) *Storage {
+	return &Storage{
+		s3Client:          s3Client,
+		s3PreSignedClient: s3.NewPresignClient(s3Client),
+		bucket:            replica.S3Bucket,
+		replica:           name,
+		config:            config,
+		metrics:           metrics,
+		logger:            logger.With(zap.String("replica", name)),
+	}
+}
+
+// Get returns the storage of the named replica, or nil when name is nil or names no replica in the config
+func (r Replicas) Get(name *string) *Storage {
+	if name == nil {
+		return nil
+	}
+
+	return r[*name]
+}
@@ -5,51 +5,82 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/metrics"
+	"github.com/teapartydev/storage/server/tracing"
 	"github.com/teapartydev/storage/server/zapfield"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+var (
+	ErrObjectNotFound          = errors.New("object not found in storage")
+	ErrInvalidRange            = errors.New("requested range is not satisfiable")
+	ErrMultipartUploadNotFound = errors.New("multipart upload not found")
+	ErrInvalidPart             = errors.New("invalid multipart upload part")
+	// ErrObjectArchived is returned for content in a storage class that has to be restored before it can be read
+	ErrObjectArchived = errors.New("object is archived in storage")
+)
+
+// StorageClassStandard is the storage class content is uploaded in and restored to
+const StorageClassStandard = "STANDARD"
+
 type Storage struct {
 	s3Client          *s3.Client
 	s3PreSignedClient *s3.PresignClient
 	bucket            string
 	config            *config.Config
+	metrics           *metrics.Metrics
 	logger            *zap.Logger
+	// replica is the name of the replica the storage writes to, it is empty for the primary storage
+	replica string
 }
 
-func NewStorage(s3Client *s3.Client, config *config.Config, logger *zap.Logger) *Storage {
+func NewStorage(s3Client *s3.Client, config *config.Config, metrics *metrics.Metrics, logger *zap.Logger) *Storage {
 	return &Storage{
 		s3Client:          s3Client,
 		s3PreSignedClient: s3.NewPresignClient(s3Client),
 		bucket:            config.S3Bucket,
 		config:            config,
+		metrics:           metrics,
 		logger:            logger,
 	}
 }
 
-func (s *Storage) UploadObject(ctx context.Context, objectUpload *ObjectUpload) error {
+// UploadObject streams the content into storage without buffering it, so ContentLength must be the exact size
+// of the content. it returns the etag of the stored object
+func (s *Storage) UploadObject(ctx context.Context, objectUpload *ObjectUpload) (string, error) {
 	const op = "Storage.UploadObject"
 
 	key := createS3Key(objectUpload.Bucket, objectUpload.Name)
 
-	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		ContentType: aws.String(objectUpload.ContentType),
-		Body:        objectUpload.Content,
-	})
+	ctx, done := s.instrument(ctx, "put_object", key)
+	output, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(objectUpload.ContentType),
+		ContentLength: aws.Int64(objectUpload.ContentLength),
+		Metadata:      objectUpload.Metadata,
+		Body:          objectUpload.Content,
+	}, withUnsignedPayload)
+	done(err)
 	if err != nil {
 		s.logger.Error("failed to put object", zap.Error(err), zapfield.Operation(op))
-		return err
+		return "", err
 	}
 
-	return nil
+	return aws.ToString(output.ETag), nil
 }
 
 func (s *Storage) CreatePreSignedUploadObject(ctx context.Context, preSignedUploadObjectCreate *PreSignedUploadObjectCreate) (*PreSignedObject, error) {
@@ -65,6 +96,7 @@ func (s *Storage) CreatePreSignedUploadObject(ctx context.Context, preSignedUplo
 
 	key := createS3Key(preSignedUploadObjectCreate.Bucket, preSignedUploadObjectCreate.Name)
 
+	ctx, done := s.instrument(ctx, "presign_put_object", key)
 	preSignedPutObject, err := s.s3PreSignedClient.PresignPutObject(ctx, &s3.PutObjectInput{
 		Bucket:        aws.String(s.bucket),
 		Key:           aws.String(key),
@@ -73,6 +105,7 @@ func (s *Storage) CreatePreSignedUploadObject(ctx context.Context, preSignedUplo
 	},
 		s3.WithPresignExpires(expiresIn),
 	)
+	done(err)
 	if err != nil {
 		s.logger.Error("failed to create pre-signed put object", zap.Error(err), zapfield.Operation(op))
 		return nil, err
@@ -98,12 +131,15 @@ func (s *Storage) CreatePreSignedDownloadObject(ctx context.Context, preSignedDo
 
 	key := createS3Key(preSignedDownloadObjectCreate.Bucket, preSignedDownloadObjectCreate.Name)
 
+	ctx, done := s.instrument(ctx, "presign_get_object", key)
 	preSignedGetObject, err := s.s3PreSignedClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+		Bucket:              aws.String(s.bucket),
+		Key:                 aws.String(key),
+		ResponseContentType: preSignedDownloadObjectCreate.ContentType,
 	},
 		s3.WithPresignExpires(expiresIn),
 	)
+	done(err)
 	if err != nil {
 		s.logger.Error("failed to create pre-signed get object", zap.Error(err), zapfield.Operation(op))
 		return nil, err
@@ -121,6 +157,7 @@ func (s *Storage) CheckIfObjectExists(ctx context.Context, objectExistsCheck *Ob
 
 	key := createS3Key(objectExistsCheck.Bucket, objectExistsCheck.Name)
 
+	ctx, done := s.instrument(ctx, "head_object", key)
 	_, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -128,11 +165,14 @@ func (s *Storage) CheckIfObjectExists(ctx context.Context, objectExistsCheck *Ob
 	if err != nil {
 		var responseError *awshttp.ResponseError
 		if errors.As(err, &responseError) && responseError.ResponseError.HTTPStatusCode() == http.StatusNotFound {
+			done(nil)
 			return false, nil
 		}
+		done(err)
 		s.logger.Error("failed to head object", zap.Error(err), zapfield.Operation(op))
 		return false, err
 	}
+	done(nil)
 
 	return true, nil
 }
@@ -142,10 +182,12 @@ func (s *Storage) DeleteObject(ctx context.Context, objectDelete *ObjectDelete)
 
 	key := createS3Key(objectDelete.Bucket, objectDelete.Name)
 
+	ctx, done := s.instrument(ctx, "delete_object", key)
 	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
+	done(err)
 	if err != nil {
 		s.logger.Error("failed to delete object", zap.Error(err), zapfield.Operation(op))
 		return err
@@ -154,6 +196,587 @@ func (s *Storage) DeleteObject(ctx context.Context, objectDelete *ObjectDelete)
 	return nil
 }
 
+// DeleteObjectsMaxCount is the most keys s3 deletes in a single DeleteObjects request
+const DeleteObjectsMaxCount = 1000
+
+// DeleteObjects deletes a batch of objects in a single request, names that do not exist in storage are not an error
+func (s *Storage) DeleteObjects(ctx context.Context, objectsDelete *ObjectsDelete) error {
+	const op = "Storage.DeleteObjects"
+
+	if len(objectsDelete.Names) == 0 {
+		return nil
+	}
+
+	if len(objectsDelete.Names) > DeleteObjectsMaxCount {
+		return fmt.Errorf("cannot delete %d objects at once, at most %d objects can be deleted in one request", len(objectsDelete.Names), DeleteObjectsMaxCount)
+	}
+
+	identifiers := make([]types.ObjectIdentifier, 0, len(objectsDelete.Names))
+	for _, name := range objectsDelete.Names {
+		identifiers = append(identifiers, types.ObjectIdentifier{Key: aws.String(createS3Key(objectsDelete.Bucket, name))})
+	}
+
+	if err := s.deleteObjects(ctx, identifiers, createS3Key(objectsDelete.Bucket, "")); err != nil {
+		s.logger.Error("failed to delete objects", zap.Error(err), zapfield.Operation(op))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteObjectsByPrefix deletes every object whose name starts with the prefix, a page of keys at a time
+func (s *Storage) DeleteObjectsByPrefix(ctx context.Context, objectsDeleteByPrefix *ObjectsDeleteByPrefix) error {
+	const op = "Storage.DeleteObjectsByPrefix"
+
+	prefix := createS3Key(objectsDeleteByPrefix.Bucket, objectsDeleteByPrefix.Prefix)
+
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		listCtx, done := s.instrument(ctx, "list_objects", prefix)
+		page, err := paginator.NextPage(listCtx)
+		done(err)
+		if err != nil {
+			s.logger.Error("failed to list objects", zap.Error(err), zapfield.Operation(op))
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		identifiers := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			identifiers = append(identifiers, types.ObjectIdentifier{Key: object.Key})
+		}
+
+		if err = s.deleteObjects(ctx, identifiers, prefix); err != nil {
+			s.logger.Error("failed to delete objects", zap.Error(err), zapfield.Operation(op))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListObjects lists a page of the objects of a bucket whose names start with Prefix in key order, starting after the
+// name in StartAfter so a page is never shifted by objects deleted in between
+func (s *Storage) ListObjects(ctx context.Context, objectsList *ObjectsList) (*ObjectsListPage, error) {
+	const op = "Storage.ListObjects"
+
+	prefix := createS3Key(objectsList.Bucket, "")
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(prefix + objectsList.Prefix),
+		MaxKeys: aws.Int32(objectsList.MaxKeys),
+	}
+	if objectsList.StartAfter != "" {
+		input.StartAfter = aws.String(createS3Key(objectsList.Bucket, objectsList.StartAfter))
+	}
+
+	ctx, done := s.instrument(ctx, "list_objects", prefix)
+	output, err := s.s3Client.ListObjectsV2(ctx, input)
+	done(err)
+	if err != nil {
+		s.logger.Error("failed to list objects", zap.Error(err), zapfield.Operation(op))
+		return nil, err
+	}
+
+	page := &ObjectsListPage{
+		Objects:   make([]*ListedObject, 0, len(output.Contents)),
+		Truncated: aws.ToBool(output.IsTruncated),
+	}
+
+	for _, object := range output.Contents {
+		page.Objects = append(page.Objects, &ListedObject{
+			Name:         strings.TrimPrefix(aws.ToString(object.Key), prefix),
+			Size:         aws.ToInt64(object.Size),
+			ETag:         aws.ToString(object.ETag),
+			LastModified: object.LastModified,
+			StorageClass: string(object.StorageClass),
+		})
+	}
+
+	return page, nil
+}
+
+// deleteObjects deletes up to DeleteObjectsMaxCount keys in quiet mode, so the output only lists the keys that failed
+func (s *Storage) deleteObjects(ctx context.Context, identifiers []types.ObjectIdentifier, prefix string) error {
+	ctx, done := s.instrument(ctx, "delete_objects", prefix)
+	output, err := s.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &types.Delete{Objects: identifiers, Quiet: aws.Bool(true)},
+	})
+	if err == nil && len(output.Errors) > 0 {
+		err = fmt.Errorf("failed to delete %d objects, first error: %s", len(output.Errors), aws.ToString(output.Errors[0].Message))
+	}
+	done(err)
+
+	return err
+}
+
+// GetObject opens the object for reading, Range is passed through as an http range header
+func (s *Storage) GetObject(ctx context.Context, objectGet *ObjectGet) (*ObjectContent, error) {
+	const op = "Storage.GetObject"
+
+	key := createS3Key(objectGet.Bucket, objectGet.Name)
+
+	ctx, done := s.instrument(ctx, "get_object", key)
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  objectGet.Range,
+	})
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrInvalidRange) {
+			done(nil)
+			return nil, err
+		}
+		done(err)
+		s.logger.Error("failed to get object", zap.Error(err), zapfield.Operation(op))
+		return nil, err
+	}
+	done(nil)
+
+	return &ObjectContent{
+		ObjectInfo: ObjectInfo{
+			ContentType:   aws.ToString(output.ContentType),
+			ContentLength: aws.ToInt64(output.ContentLength),
+			ETag:          aws.ToString(output.ETag),
+			LastModified:  output.LastModified,
+			Metadata:      output.Metadata,
+		},
+		ContentRange: output.ContentRange,
+		Body:         output.Body,
+	}, nil
+}
+
+func (s *Storage) HeadObject(ctx context.Context, objectHead *ObjectHead) (*ObjectInfo, error) {
+	const op = "Storage.HeadObject"
+
+	key := createS3Key(objectHead.Bucket, objectHead.Name)
+
+	ctx, done := s.instrument(ctx, "head_object", key)
+	output, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrObjectNotFound) {
+			done(nil)
+			return nil, err
+		}
+		done(err)
+		s.logger.Error("failed to head object", zap.Error(err), zapfield.Operation(op))
+		return nil, err
+	}
+	done(nil)
+
+	return &ObjectInfo{
+		ContentType:   aws.ToString(output.ContentType),
+		ContentLength: aws.ToInt64(output.ContentLength),
+		ETag:          aws.ToString(output.ETag),
+		LastModified:  output.LastModified,
+		Metadata:      output.Metadata,
+	}, nil
+}
+
+// CopyObject copies server side within the storage bucket and returns the etag of the copy
+func (s *Storage) CopyObject(ctx context.Context, objectCopy *ObjectCopy) (string, error) {
+	const op = "Storage.CopyObject"
+
+	key := createS3Key(objectCopy.DestinationBucket, objectCopy.DestinationName)
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(url.PathEscape(s.bucket) + "/" + escapeKey(createS3Key(objectCopy.SourceBucket, objectCopy.SourceName))),
+	}
+	if objectCopy.ContentType != nil {
+		input.ContentType = objectCopy.ContentType
+		input.Metadata = objectCopy.Metadata
+		input.MetadataDirective = types.MetadataDirectiveReplace
+	}
+	if objectCopy.StorageClass != "" {
+		input.StorageClass = types.StorageClass(objectCopy.StorageClass)
+	}
+
+	ctx, done := s.instrument(ctx, "copy_object", key)
+	output, err := s.s3Client.CopyObject(ctx, input)
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrObjectArchived) {
+			done(nil)
+			return "", err
+		}
+		done(err)
+		s.logger.Error("failed to copy object", zap.Error(err), zapfield.Operation(op))
+		return "", err
+	}
+	done(nil)
+
+	return aws.ToString(output.CopyObjectResult.ETag), nil
+}
+
+// RestoreObject asks storage for a temporary readable copy of archived content. storage makes the copy in the
+// background, a restore that is already in progress is not an error
+func (s *Storage) RestoreObject(ctx context.Context, objectRestore *ObjectRestore) error {
+	const op = "Storage.RestoreObject"
+
+	key := createS3Key(objectRestore.Bucket, objectRestore.Name)
+
+	ctx, done := s.instrument(ctx, "restore_object", key)
+	_, err := s.s3Client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &types.RestoreRequest{
+			Days: aws.Int32(objectRestore.Days),
+		},
+	})
+	if err != nil {
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) && apiError.ErrorCode() == "RestoreAlreadyInProgress" {
+			done(nil)
+			return nil
+		}
+		err = translateError(err)
+		done(err)
+		s.logger.Error("failed to restore object", zap.Error(err), zapfield.Operation(op))
+		return err
+	}
+	done(nil)
+
+	return nil
+}
+
+// CopyObjectMaxSize is the largest object CopyObject copies in a single request, larger objects take CopyLargeObject
+const CopyObjectMaxSize = 5 << 30
+
+// copyPartSize is the size of the ranges CopyLargeObject copies one part at a time
+const copyPartSize = 512 << 20
+
+// CopyLargeObject copies an object of size bytes server side in parts, keeping the content type and metadata of the
+// source. the multipart upload is aborted when a part fails to copy
+func (s *Storage) CopyLargeObject(ctx context.Context, objectCopy *ObjectCopy, size int64) (string, error) {
+	const op = "Storage.CopyLargeObject"
+
+	source, err := s.HeadObject(ctx, &ObjectHead{
+		Bucket: objectCopy.SourceBucket,
+		Name:   objectCopy.SourceName,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	uploadId, err := s.CreateMultipartUpload(ctx, &MultipartUploadCreate{
+		Bucket:       objectCopy.DestinationBucket,
+		Name:         objectCopy.DestinationName,
+		ContentType:  source.ContentType,
+		Metadata:     source.Metadata,
+		StorageClass: objectCopy.StorageClass,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	multipartUpload := &MultipartUpload{
+		Bucket:   objectCopy.DestinationBucket,
+		Name:     objectCopy.DestinationName,
+		UploadId: uploadId,
+	}
+
+	key := createS3Key(objectCopy.DestinationBucket, objectCopy.DestinationName)
+	copySource := url.PathEscape(s.bucket) + "/" + escapeKey(createS3Key(objectCopy.SourceBucket, objectCopy.SourceName))
+
+	parts := make([]CompletedPart, 0, size/copyPartSize+1)
+	for start, partNumber := int64(0), int32(1); start < size; start, partNumber = start+copyPartSize, partNumber+1 {
+		end := min(start+copyPartSize, size) - 1
+
+		partCtx, done := s.instrument(ctx, "upload_part_copy", key)
+		output, err := s.s3Client.UploadPartCopy(partCtx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        aws.String(uploadId),
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      aws.String(copySource),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		})
+		if err != nil {
+			err = translateError(err)
+			if errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrObjectArchived) {
+				done(nil)
+			} else {
+				done(err)
+				s.logger.Error("failed to copy part", zap.Error(err), zapfield.Operation(op))
+			}
+			_ = s.AbortMultipartUpload(context.WithoutCancel(ctx), multipartUpload)
+			return "", err
+		}
+		done(nil)
+
+		parts = append(parts, CompletedPart{
+			PartNumber: partNumber,
+			ETag:       aws.ToString(output.CopyPartResult.ETag),
+		})
+	}
+
+	etag, err := s.CompleteMultipartUpload(ctx, &MultipartUploadComplete{
+		Bucket:   objectCopy.DestinationBucket,
+		Name:     objectCopy.DestinationName,
+		UploadId: uploadId,
+		Parts:    parts,
+	})
+	if err != nil {
+		_ = s.AbortMultipartUpload(context.WithoutCancel(ctx), multipartUpload)
+		return "", err
+	}
+
+	return etag, nil
+}
+
+func (s *Storage) CreateMultipartUpload(ctx context.Context, multipartUploadCreate *MultipartUploadCreate) (string, error) {
+	const op = "Storage.CreateMultipartUpload"
+
+	key := createS3Key(multipartUploadCreate.Bucket, multipartUploadCreate.Name)
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(multipartUploadCreate.ContentType),
+		Metadata:    multipartUploadCreate.Metadata,
+	}
+	if multipartUploadCreate.StorageClass != "" {
+		input.StorageClass = types.StorageClass(multipartUploadCreate.StorageClass)
+	}
+
+	ctx, done := s.instrument(ctx, "create_multipart_upload", key)
+	output, err := s.s3Client.CreateMultipartUpload(ctx, input)
+	done(err)
+	if err != nil {
+		s.logger.Error("failed to create multipart upload", zap.Error(err), zapfield.Operation(op))
+		return "", err
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+// UploadPart streams one part of a multipart upload and returns the etag of the part
+func (s *Storage) UploadPart(ctx context.Context, multipartUploadPart *MultipartUploadPart) (string, error) {
+	const op = "Storage.UploadPart"
+
+	key := createS3Key(multipartUploadPart.Bucket, multipartUploadPart.Name)
+
+	ctx, done := s.instrument(ctx, "upload_part", key)
+	output, err := s.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(multipartUploadPart.UploadId),
+		PartNumber:    aws.Int32(multipartUploadPart.PartNumber),
+		ContentLength: aws.Int64(multipartUploadPart.ContentLength),
+		Body:          multipartUploadPart.Content,
+	}, withUnsignedPayload)
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrMultipartUploadNotFound) {
+			done(nil)
+			return "", err
+		}
+		done(err)
+		s.logger.Error("failed to upload part", zap.Error(err), zapfield.Operation(op))
+		return "", err
+	}
+	done(nil)
+
+	return aws.ToString(output.ETag), nil
+}
+
+// ListParts returns every part uploaded so far, ordered by part number
+func (s *Storage) ListParts(ctx context.Context, multipartUpload *MultipartUpload) ([]CompletedPart, error) {
+	const op = "Storage.ListParts"
+
+	key := createS3Key(multipartUpload.Bucket, multipartUpload.Name)
+
+	ctx, done := s.instrument(ctx, "list_parts", key)
+
+	var parts []CompletedPart
+	paginator := s3.NewListPartsPaginator(s.s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(multipartUpload.UploadId),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			err = translateError(err)
+			if errors.Is(err, ErrMultipartUploadNotFound) {
+				done(nil)
+				return nil, err
+			}
+			done(err)
+			s.logger.Error("failed to list parts", zap.Error(err), zapfield.Operation(op))
+			return nil, err
+		}
+
+		for _, part := range output.Parts {
+			parts = append(parts, CompletedPart{
+				PartNumber: aws.ToInt32(part.PartNumber),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+			})
+		}
+	}
+	done(nil)
+
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the object and returns the etag of the object
+func (s *Storage) CompleteMultipartUpload(ctx context.Context, multipartUploadComplete *MultipartUploadComplete) (string, error) {
+	const op = "Storage.CompleteMultipartUpload"
+
+	key := createS3Key(multipartUploadComplete.Bucket, multipartUploadComplete.Name)
+
+	completedParts := make([]types.CompletedPart, 0, len(multipartUploadComplete.Parts))
+	for _, part := range multipartUploadComplete.Parts {
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	ctx, done := s.instrument(ctx, "complete_multipart_upload", key)
+	output, err := s.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(multipartUploadComplete.UploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrMultipartUploadNotFound) || errors.Is(err, ErrInvalidPart) {
+			done(nil)
+			return "", err
+		}
+		done(err)
+		s.logger.Error("failed to complete multipart upload", zap.Error(err), zapfield.Operation(op))
+		return "", err
+	}
+	done(nil)
+
+	return aws.ToString(output.ETag), nil
+}
+
+func (s *Storage) AbortMultipartUpload(ctx context.Context, multipartUpload *MultipartUpload) error {
+	const op = "Storage.AbortMultipartUpload"
+
+	key := createS3Key(multipartUpload.Bucket, multipartUpload.Name)
+
+	ctx, done := s.instrument(ctx, "abort_multipart_upload", key)
+	_, err := s.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(multipartUpload.UploadId),
+	})
+	if err != nil {
+		err = translateError(err)
+		if errors.Is(err, ErrMultipartUploadNotFound) {
+			done(nil)
+			return err
+		}
+		done(err)
+		s.logger.Error("failed to abort multipart upload", zap.Error(err), zapfield.Operation(op))
+		return err
+	}
+	done(nil)
+
+	return nil
+}
+
+func (s *Storage) CheckBucket(ctx context.Context) error {
+	const op = "Storage.CheckBucket"
+
+	ctx, done := s.instrument(ctx, "head_bucket", "")
+	_, err := s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	done(err)
+	if err != nil {
+		s.logger.Error("failed to head bucket", zap.Error(err), zapfield.Operation(op))
+		return err
+	}
+
+	return nil
+}
+
+// instrument starts a span for a storage operation and returns a function that records
+// the operation latency and outcome on both the span and the storage metrics
+func (s *Storage) instrument(ctx context.Context, operation string, key string) (context.Context, func(err error)) {
+	start := time.Now()
+
+	ctx, span := tracing.Tracer().Start(ctx, "s3."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("s3.bucket", s.bucket),
+			attribute.String("s3.key", key),
+		),
+	)
+
+	// replicas are observed apart so a slow or failing replica does not hide in the operations of the primary
+	if s.replica != "" {
+		operation = "replica_" + operation
+	}
+
+	return ctx, func(err error) {
+		s.metrics.ObserveStorageOperation(operation, start, err)
+		tracing.EndSpan(span, err)
+	}
+}
+
+// withUnsignedPayload lets uploads stream bodies that cannot be read twice to compute the payload hash
+func withUnsignedPayload(options *s3.Options) {
+	options.APIOptions = append(options.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+}
+
+// translateError maps the s3 errors callers need to react to onto the storage errors
+func translateError(err error) error {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		switch apiError.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+		case "InvalidRange":
+			return fmt.Errorf("%w: %w", ErrInvalidRange, err)
+		case "NoSuchUpload":
+			return fmt.Errorf("%w: %w", ErrMultipartUploadNotFound, err)
+		case "InvalidObjectState":
+			return fmt.Errorf("%w: %w", ErrObjectArchived, err)
+		case "InvalidPart", "InvalidPartOrder", "EntityTooSmall":
+			return fmt.Errorf("%w: %s", ErrInvalidPart, apiError.ErrorMessage())
+		}
+	}
+
+	var responseError *awshttp.ResponseError
+	if errors.As(err, &responseError) && responseError.ResponseError.HTTPStatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+	}
+
+	return err
+}
+
+// escapeKey escapes every segment of a key for use in the copy source header
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 func createS3Key(bucket string, name string) string {
 	return fmt.Sprintf(`%s/%s`, bucket, name)
 }
@@ -1,12 +1,34 @@
 package storage
 
-import "io"
+import (
+	"io"
+	"time"
+)
+
+// RenderCacheBucket keeps rendered variants of image objects alongside the buckets, bucket names cannot start with an
+// underscore so its keys never collide with object keys
+const RenderCacheBucket = "_renders"
+
+// ArchiveBucket keeps bucket archives alongside the buckets, like the render cache its keys never collide with object
+// keys
+const ArchiveBucket = "_archives"
+
+// RenderCachePrefix is the prefix the rendered variants of an object are cached under, an empty object id covers every
+// object of the bucket
+func RenderCachePrefix(bucketId string, objectId string) string {
+	if objectId == "" {
+		return bucketId + "/"
+	}
+	return bucketId + "/" + objectId + "/"
+}
 
 type ObjectUpload struct {
-	Bucket      string    `json:"bucket"`
-	Name        string    `json:"name"`
-	ContentType string    `json:"content_type"`
-	Content     io.Reader `json:"content"`
+	Bucket        string            `json:"bucket"`
+	Name          string            `json:"name"`
+	ContentType   string            `json:"content_type"`
+	ContentLength int64             `json:"content_length"`
+	Metadata      map[string]string `json:"metadata"`
+	Content       io.Reader         `json:"content"`
 }
 
 type ObjectRename struct {
@@ -15,8 +37,15 @@ type ObjectRename struct {
 }
 
 type ObjectCopy struct {
-	OldPath string `json:"old_path"`
-	NewPath string `json:"new_path"`
+	SourceBucket      string `json:"source_bucket"`
+	SourceName        string `json:"source_name"`
+	DestinationBucket string `json:"destination_bucket"`
+	DestinationName   string `json:"destination_name"`
+	// ContentType and Metadata replace the ones of the source when ContentType is set
+	ContentType *string           `json:"content_type"`
+	Metadata    map[string]string `json:"metadata"`
+	// StorageClass is the storage class of the copy, the copy takes the default storage class when it is empty
+	StorageClass string `json:"storage_class"`
 }
 
 type ObjectMove struct {
@@ -42,6 +71,9 @@ type PreSignedDownloadObjectCreate struct {
 	Bucket    string `json:"bucket"`
 	Name      string `json:"name"`
 	ExpiresIn *int64 `json:"expires_in"`
+	// ContentType overrides the content type stored with the content, so a mime type changed in the catalog is the
+	// one served
+	ContentType *string `json:"content_type"`
 }
 
 type ObjectExistsCheck struct {
@@ -54,6 +86,115 @@ type ObjectDelete struct {
 	Name   string `json:"name"`
 }
 
+// ObjectsDelete names at most DeleteObjectsMaxCount objects of a bucket to delete in one request
+type ObjectsDelete struct {
+	Bucket string   `json:"bucket"`
+	Names  []string `json:"names"`
+}
+
+type ObjectsDeleteByPrefix struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+}
+
+// ObjectsList asks for at most MaxKeys objects of a bucket whose names start with Prefix and sort after StartAfter
+type ObjectsList struct {
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix"`
+	StartAfter string `json:"start_after"`
+	MaxKeys    int32  `json:"max_keys"`
+}
+
+// ObjectsListPage holds objects in the byte order of their names, Truncated tells whether more follow
+type ObjectsListPage struct {
+	Objects   []*ListedObject `json:"objects"`
+	Truncated bool            `json:"truncated"`
+}
+
+type ListedObject struct {
+	Name         string     `json:"name"`
+	Size         int64      `json:"size"`
+	ETag         string     `json:"etag"`
+	LastModified *time.Time `json:"last_modified"`
+	// StorageClass is empty when storage does not report one, which is the standard storage class
+	StorageClass string `json:"storage_class"`
+}
+
 type BucketEmpty struct {
 	Bucket string `json:"bucket"`
 }
+
+type ObjectGet struct {
+	Bucket string  `json:"bucket"`
+	Name   string  `json:"name"`
+	Range  *string `json:"range"`
+}
+
+type ObjectHead struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+}
+
+type ObjectInfo struct {
+	ContentType   string            `json:"content_type"`
+	ContentLength int64             `json:"content_length"`
+	ETag          string            `json:"etag"`
+	LastModified  *time.Time        `json:"last_modified"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// ObjectContent is the body of an object, the caller must close Body
+type ObjectContent struct {
+	ObjectInfo
+	ContentRange *string       `json:"content_range"`
+	Body         io.ReadCloser `json:"-"`
+}
+
+type ObjectWrite struct {
+	Bucket      string `json:"bucket"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+}
+
+type MultipartUploadCreate struct {
+	Bucket       string            `json:"bucket"`
+	Name         string            `json:"name"`
+	ContentType  string            `json:"content_type"`
+	Metadata     map[string]string `json:"metadata"`
+	StorageClass string            `json:"storage_class"`
+}
+
+type MultipartUploadPart struct {
+	Bucket        string    `json:"bucket"`
+	Name          string    `json:"name"`
+	UploadId      string    `json:"upload_id"`
+	PartNumber    int32     `json:"part_number"`
+	ContentLength int64     `json:"content_length"`
+	Content       io.Reader `json:"content"`
+}
+
+type MultipartUploadComplete struct {
+	Bucket   string          `json:"bucket"`
+	Name     string          `json:"name"`
+	UploadId string          `json:"upload_id"`
+	Parts    []CompletedPart `json:"parts"`
+}
+
+type CompletedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size"`
+}
+
+// ObjectRestore makes a temporary copy of archived content readable for Days days
+type ObjectRestore struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+	Days   int32  `json:"days"`
+}
+
+type MultipartUpload struct {
+	Bucket   string `json:"bucket"`
+	Name     string `json:"name"`
+	UploadId string `json:"upload_id"`
+}
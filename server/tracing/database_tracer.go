@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DatabaseTracer implements pgx.QueryTracer and creates a span for every query executed through the pool
+type DatabaseTracer struct{}
+
+func NewDatabaseTracer() *DatabaseTracer {
+	return &DatabaseTracer{}
+}
+
+func (t *DatabaseTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = Tracer().Start(ctx, queryName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatement(data.SQL),
+			attribute.String("db.name", conn.Config().Database),
+		),
+	)
+
+	return ctx
+}
+
+func (t *DatabaseTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		return
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+// queryName uses the sqlc query name annotation (`-- name: BucketGetById :one`) as the span name when present
+func queryName(sql string) string {
+	const prefix = "-- name: "
+
+	if strings.HasPrefix(sql, prefix) {
+		fields := strings.Fields(strings.TrimPrefix(sql, prefix))
+		if len(fields) > 0 {
+			return "db." + fields[0]
+		}
+	}
+
+	return "db.query"
+}
@@ -0,0 +1,15 @@
+package tracing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryName(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("db.BucketGetById", queryName("-- name: BucketGetById :one\nselect id from storage.buckets where id = $1"), "sqlc annotated query")
+	a.Equal("db.ObjectDelete", queryName("-- name: ObjectDelete :exec\ndelete from storage.objects where id = $1"), "sqlc annotated exec query")
+	a.Equal("db.query", queryName("select 1"), "query without annotation")
+	a.Equal("db.query", queryName("-- name: "), "empty annotation")
+}
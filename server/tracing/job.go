@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceContext is embedded into job args to carry the w3c trace context of the request that enqueued the job
+type TraceContext map[string]string
+
+func NewTraceContext(ctx context.Context) TraceContext {
+	carrier := propagation.MapCarrier{}
+
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	return TraceContext(carrier)
+}
+
+// StartJobSpan starts the span for a job run as a child of the span that enqueued the job
+func StartJobSpan(ctx context.Context, kind string, jobId int64, attempt int, traceContext TraceContext) (context.Context, trace.Span) {
+	if traceContext != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(traceContext))
+	}
+
+	return Tracer().Start(ctx, "job."+kind,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("job.kind", kind),
+			attribute.Int64("job.id", jobId),
+			attribute.Int("job.attempt", attempt),
+		),
+	)
+}
+
+// EndSpan records the error on the span if any and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
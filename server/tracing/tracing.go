@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/teapartydev/storage/server/config"
+	"github.com/teapartydev/storage/server/zapfield"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const instrumentationName = "github.com/teapartydev/storage/server"
+
+// Tracer returns the tracer shared by the http, database, storage and job instrumentation
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+func NewTracerProvider(config *config.Config, logger *zap.Logger) *sdktrace.TracerProvider {
+	const op = "tracing.NewTracerProvider"
+
+	serviceResource, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(config.ServiceName),
+			semconv.ServiceInstanceID(config.ServiceId),
+			semconv.DeploymentEnvironment(config.ServiceEnvironment),
+		),
+	)
+	if err != nil {
+		logger.Fatal("error creating tracing resource", zap.Error(err), zapfield.Operation(op))
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(serviceResource),
+	}
+
+	switch config.TracingExporter {
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			logger.Fatal("error creating stdout trace exporter", zap.Error(err), zapfield.Operation(op))
+		}
+		options = append(options,
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(*config.TracingSampleRatio))),
+		)
+	case "otlp":
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.TracingOtlpEndpoint))
+		if err != nil {
+			logger.Fatal("error creating otlp trace exporter", zap.Error(err), zapfield.Operation(op))
+		}
+		options = append(options,
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(*config.TracingSampleRatio))),
+		)
+	default:
+		options = append(options, sdktrace.WithSampler(sdktrace.NeverSample()))
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(options...)
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tracerProvider
+}
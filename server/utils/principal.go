@@ -0,0 +1,29 @@
+package utils
+
+import "context"
+
+const (
+	// PrincipalService is the principal of requests authenticated with the master service api key
+	PrincipalService = "service"
+	// PrincipalCli is the principal of the admin cli, which calls the services directly
+	PrincipalCli = "cli"
+)
+
+// Principal is who a request acts for, audit events record its id and only admins can bypass governance retention
+type Principal struct {
+	Id    string
+	Admin bool
+}
+
+func PrincipalFromContext(ctx context.Context) Principal {
+	var principal Principal
+	if p, ok := ctx.Value("principal").(Principal); ok {
+		principal = p
+	}
+
+	return principal
+}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, "principal", principal)
+}
@@ -10,3 +10,7 @@ func RequestId(ctx context.Context) string {
 
 	return requestId
 }
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, "request_id", requestId)
+}